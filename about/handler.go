@@ -0,0 +1,35 @@
+// Package about provides GET /about, which reports the running build and
+// where the instance keeps its files, to help check that container volumes
+// are mounted where they are expected.
+package about
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"swucol/datadir"
+	"swucol/version"
+)
+
+// aboutResponse is the JSON body served by Handler.
+type aboutResponse struct {
+	version.Info
+	Paths datadir.Layout `json:"paths"`
+}
+
+// Handler returns an http.HandlerFunc that handles GET /about. Returns 200 OK
+// with the build details served by GET /version and the absolute paths of
+// layout as JSON.
+func Handler(layout datadir.Layout) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		response := aboutResponse{Info: version.Get(), Paths: layout}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(response); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode about response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
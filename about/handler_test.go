@@ -0,0 +1,34 @@
+package about_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/about"
+	"swucol/datadir"
+	"swucol/version"
+)
+
+func TestHandler_ReportsVersionAndPaths(t *testing.T) {
+	layout, err := datadir.New(t.TempDir())
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	about.Handler(layout)(recorder, httptest.NewRequest(http.MethodGet, "/about", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	var body struct {
+		version.Info
+		Paths datadir.Layout `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, version.Get(), body.Info)
+	assert.Equal(t, layout, body.Paths)
+}
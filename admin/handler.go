@@ -0,0 +1,305 @@
+// Package admin provides the HTTP handlers behind the /admin maintenance page:
+// database vacuum, integrity check, reindex, backup and restore, and merging
+// of duplicate cards. The page also manages API tokens through the apitokens
+// handlers.
+package admin
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"swucol/csrf"
+	"swucol/database"
+	"swucol/internal/httpjson"
+	"swucol/models"
+	"swucol/templates"
+)
+
+// maxUploadMemory is the part of an uploaded backup kept in memory; the rest
+// is buffered to disk by the multipart reader.
+const maxUploadMemory = 32 << 20
+
+// adminPage is the view model rendered by the admin template.
+type adminPage struct {
+	Theme        string
+	CSRFToken    string
+	DatabaseSize int64
+	Duplicates   []database.DuplicateCards
+	Tokens       []models.APIToken
+}
+
+// PageHandler returns an http.HandlerFunc that serves the maintenance page at
+// GET /admin. Returns 500 Internal Server Error for database errors or if
+// template rendering fails.
+func PageHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		size, err := db.DatabaseSize()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error reading database size", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		duplicates, err := db.GetDuplicateCards()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading duplicate cards", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		tokens, err := db.GetAPITokens()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading API tokens", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		page := adminPage{
+			Theme:        db.Settings().Theme,
+			CSRFToken:    csrf.Token(request.Context()),
+			DatabaseSize: size,
+			Duplicates:   duplicates,
+			Tokens:       tokens,
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "admin", page); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render admin template", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "failed to render page", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// vacuumResult reports the database size before and after a vacuum.
+type vacuumResult struct {
+	SizeBefore int64 `json:"size_before"`
+	SizeAfter  int64 `json:"size_after"`
+}
+
+// VacuumHandler returns an http.HandlerFunc that handles POST
+// /admin/db/vacuum. Returns 200 OK with the database size in bytes before and
+// after the vacuum as JSON, and 500 Internal Server Error for database errors.
+func VacuumHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "POST /admin/db/vacuum received")
+
+		var result vacuumResult
+		var err error
+		if result.SizeBefore, err = db.DatabaseSize(); err == nil {
+			if err = db.Vacuum(); err == nil {
+				result.SizeAfter, err = db.DatabaseSize()
+			}
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database vacuum failed", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "database vacuumed", "size_before", result.SizeBefore, "size_after", result.SizeAfter)
+
+		httpjson.Write(responseWriter, request, http.StatusOK, result)
+	}
+}
+
+// integrityResult reports the outcome of an integrity check.
+type integrityResult struct {
+	OK       bool     `json:"ok"`
+	Problems []string `json:"problems"`
+}
+
+// IntegrityCheckHandler returns an http.HandlerFunc that handles POST
+// /admin/db/integrity-check. Returns 200 OK with whether the database is
+// intact and the problems found as JSON, and 500 Internal Server Error if the
+// check cannot run.
+func IntegrityCheckHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "POST /admin/db/integrity-check received")
+
+		problems, err := db.IntegrityCheck()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database integrity check failed", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		if len(problems) > 0 {
+			slog.WarnContext(request.Context(), "database integrity check found problems", "problems", problems)
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, integrityResult{OK: len(problems) == 0, Problems: problems})
+	}
+}
+
+// ReindexHandler returns an http.HandlerFunc that handles POST
+// /admin/db/reindex. Returns 204 No Content once every index has been rebuilt,
+// and 500 Internal Server Error for database errors.
+func ReindexHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "POST /admin/db/reindex received")
+
+		if err := db.Reindex(); err != nil {
+			slog.ErrorContext(request.Context(), "database reindex failed", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "database reindexed")
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// BackupHandler returns an http.HandlerFunc that handles GET
+// /admin/db/backup. Responds with a consistent copy of the database file as
+// an attachment named after the current time, or 500 Internal Server Error if
+// the copy cannot be written.
+func BackupHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "GET /admin/db/backup received")
+
+		tempDir, err := os.MkdirTemp("", "swucol-backup-")
+		if err != nil {
+			slog.ErrorContext(request.Context(), "failed to create backup directory", "error", err)
+			http.Error(responseWriter, "backup failed", http.StatusInternalServerError)
+			return
+		}
+		defer os.RemoveAll(tempDir)
+
+		snapshotPath := filepath.Join(tempDir, "swucol.db")
+		if err := db.SnapshotTo(snapshotPath); err != nil {
+			slog.ErrorContext(request.Context(), "failed to snapshot database for backup", "error", err)
+			http.Error(responseWriter, "backup failed", http.StatusInternalServerError)
+			return
+		}
+
+		snapshot, err := os.Open(snapshotPath)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "failed to open database backup", "error", err)
+			http.Error(responseWriter, "backup failed", http.StatusInternalServerError)
+			return
+		}
+		defer snapshot.Close()
+
+		takenAt := time.Now().UTC()
+		fileName := "swucol-" + takenAt.Format("20060102-150405") + ".db"
+
+		responseWriter.Header().Set("Content-Type", "application/vnd.sqlite3")
+		responseWriter.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+		http.ServeContent(responseWriter, request, fileName, takenAt, snapshot)
+	}
+}
+
+// RestoreHandler returns an http.HandlerFunc that handles POST
+// /admin/db/restore. It accepts a multipart/form-data upload with a "file"
+// field holding a database file, such as one downloaded from
+// /admin/db/backup, and replaces the collection with its contents. Returns
+// 204 No Content on success, 400 Bad Request if the file is missing or is not
+// a collection database, and 500 Internal Server Error for database errors.
+func RestoreHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "POST /admin/db/restore received")
+
+		if err := request.ParseMultipartForm(maxUploadMemory); err != nil {
+			slog.ErrorContext(request.Context(), "failed to parse multipart form", "error", err)
+			http.Error(responseWriter, "invalid form data", http.StatusBadRequest)
+			return
+		}
+		defer request.MultipartForm.RemoveAll()
+
+		file, fileHeader, err := request.FormFile("file")
+		if err != nil {
+			slog.ErrorContext(request.Context(), "file field missing from restore form", "error", err)
+			http.Error(responseWriter, "file field is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		slog.InfoContext(request.Context(), "restore file received", "filename", fileHeader.Filename, "size_bytes", fileHeader.Size)
+
+		// SQLite can only attach a file on disk, so the upload is copied to a
+		// temporary file first.
+		tempFile, err := os.CreateTemp("", "swucol-restore-*.db")
+		if err != nil {
+			slog.ErrorContext(request.Context(), "failed to create restore file", "error", err)
+			http.Error(responseWriter, "restore failed", http.StatusInternalServerError)
+			return
+		}
+		defer os.Remove(tempFile.Name())
+
+		_, copyErr := io.Copy(tempFile, file)
+		if closeErr := tempFile.Close(); copyErr == nil {
+			copyErr = closeErr
+		}
+		if copyErr != nil {
+			slog.ErrorContext(request.Context(), "failed to write restore file", "error", copyErr)
+			http.Error(responseWriter, "restore failed", http.StatusInternalServerError)
+			return
+		}
+
+		if err := db.RestoreFrom(tempFile.Name()); err != nil {
+			if errors.Is(err, database.ErrInvalidRestoreSource) {
+				slog.WarnContext(request.Context(), "rejected restore file", "filename", fileHeader.Filename, "error", err)
+				http.Error(responseWriter, "file is not a collection database", http.StatusBadRequest)
+				return
+			}
+			slog.ErrorContext(request.Context(), "database restore failed", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "database restored", "filename", fileHeader.Filename)
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DuplicatesHandler returns an http.HandlerFunc that handles GET
+// /admin/db/duplicates. Returns 200 OK with every card name shared by more
+// than one card and the IDs of those cards as JSON, and 500 Internal Server
+// Error for database errors.
+func DuplicatesHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		duplicates, err := db.GetDuplicateCards()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading duplicate cards", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, duplicates)
+	}
+}
+
+// mergeResult reports how many duplicate cards a merge deleted.
+type mergeResult struct {
+	Merged int `json:"merged"`
+}
+
+// MergeDuplicatesHandler returns an http.HandlerFunc that handles POST
+// /admin/db/duplicates/merge. Each group of cards sharing a name is folded
+// into one card as described by database.MergeDuplicateCards. Returns 200 OK
+// with the number of cards deleted as JSON, and 500 Internal Server Error for
+// database errors.
+func MergeDuplicatesHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "POST /admin/db/duplicates/merge received")
+
+		merged, err := db.MergeDuplicateCards()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error merging duplicate cards", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "duplicate cards merged", "merged", merged)
+
+		httpjson.Write(responseWriter, request, http.StatusOK, mergeResult{Merged: merged})
+	}
+}
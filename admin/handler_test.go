@@ -0,0 +1,156 @@
+package admin_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/admin"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/templates"
+	"swucol/version"
+)
+
+// newRestoreRequest builds a multipart POST to /admin/db/restore whose "file"
+// field holds content.
+func newRestoreRequest(t *testing.T, content []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "backup.db")
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	request := httptest.NewRequest(http.MethodPost, "/admin/db/restore", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return request
+}
+
+func TestPageHandler_ListsDuplicateCardsAndTokens(t *testing.T) {
+	db := testdb.New(t)
+	_, err := db.Connection().Exec("INSERT INTO cards (name) VALUES ('Luke Skywalker, Jedi Knight'), ('Luke Skywalker, Jedi Knight')")
+	require.NoError(t, err)
+	_, _, err = db.CreateAPIToken("sync script", []string{models.ScopeRead, models.ScopeWrite}, models.RoleAdmin)
+	require.NoError(t, err)
+
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	admin.PageHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/admin", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Luke Skywalker, Jedi Knight (2 cards)")
+	assert.Contains(t, recorder.Body.String(), "hx-confirm")
+	assert.Contains(t, recorder.Body.String(), "sync script (admin; read, write)")
+	assert.Contains(t, recorder.Body.String(), "SWU Collection Manager "+version.Get().String())
+}
+
+func TestIntegrityCheckHandler_HealthyDatabase_ReportsOK(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := httptest.NewRecorder()
+	admin.IntegrityCheckHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/admin/db/integrity-check", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"ok": true, "problems": []}`, recorder.Body.String())
+}
+
+func TestVacuumHandler_ReportsSizes(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := httptest.NewRecorder()
+	admin.VacuumHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/admin/db/vacuum", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var result map[string]int64
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.Positive(t, result["size_before"])
+	assert.Positive(t, result["size_after"])
+}
+
+func TestReindexHandler_ReturnsNoContent(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := httptest.NewRecorder()
+	admin.ReindexHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/admin/db/reindex", nil))
+
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+}
+
+func TestBackupAndRestoreHandlers_RoundTripCollection(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight"}))
+
+	backupRecorder := httptest.NewRecorder()
+	admin.BackupHandler(db)(backupRecorder, httptest.NewRequest(http.MethodGet, "/admin/db/backup", nil))
+
+	require.Equal(t, http.StatusOK, backupRecorder.Code)
+	assert.Contains(t, backupRecorder.Header().Get("Content-Disposition"), "attachment")
+	backup, err := io.ReadAll(backupRecorder.Body)
+	require.NoError(t, err)
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel"}))
+
+	restoreRecorder := httptest.NewRecorder()
+	admin.RestoreHandler(db)(restoreRecorder, newRestoreRequest(t, backup))
+
+	require.Equal(t, http.StatusNoContent, restoreRecorder.Code, restoreRecorder.Body.String())
+	cards, err := db.GetAllCards()
+	require.NoError(t, err)
+	require.Len(t, cards, 1)
+	assert.Equal(t, "Luke Skywalker, Jedi Knight", cards[0].Name)
+}
+
+func TestRestoreHandler_NotADatabase_ReturnsBadRequest(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := httptest.NewRecorder()
+	admin.RestoreHandler(db)(recorder, newRestoreRequest(t, []byte("Name,Set\n")))
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "not a collection database")
+}
+
+func TestRestoreHandler_MissingFile_ReturnsBadRequest(t *testing.T) {
+	db := testdb.New(t)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.Close())
+	request := httptest.NewRequest(http.MethodPost, "/admin/db/restore", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	recorder := httptest.NewRecorder()
+	admin.RestoreHandler(db)(recorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestMergeDuplicatesHandler_ReportsMergedCount(t *testing.T) {
+	db := testdb.New(t)
+	_, err := db.Connection().Exec("INSERT INTO cards (name, owned) VALUES ('Luke Skywalker, Jedi Knight', 1), ('Luke Skywalker, Jedi Knight', 2)")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	admin.MergeDuplicatesHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/admin/db/duplicates/merge", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"merged": 1}`, recorder.Body.String())
+
+	duplicatesRecorder := httptest.NewRecorder()
+	admin.DuplicatesHandler(db)(duplicatesRecorder, httptest.NewRequest(http.MethodGet, "/admin/db/duplicates", nil))
+	assert.JSONEq(t, `[]`, duplicatesRecorder.Body.String())
+}
@@ -0,0 +1,191 @@
+// Package apitokens lets scripts call the JSON API with bearer tokens. Tokens
+// are created and revoked under /admin/tokens and carry scopes limiting what
+// they may do: read for GET requests, import for CSV imports, and write for
+// every other change. A token's role limits it further; package roles
+// enforces it. Tokens never reach the token management routes themselves.
+package apitokens
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"swucol/database"
+	"swucol/internal/httpjson"
+	"swucol/models"
+)
+
+// managementPath is the path prefix of the token management routes, which
+// tokens may not call.
+const managementPath = "/admin/tokens"
+
+// contextKey is the context key type for the authenticated token.
+type contextKey struct{}
+
+// FromContext returns the token the request was authenticated with, and
+// false when it carried none.
+func FromContext(ctx context.Context) (models.APIToken, bool) {
+	token, ok := ctx.Value(contextKey{}).(models.APIToken)
+	return token, ok
+}
+
+// requiredScope returns the scope a token needs to make request.
+func requiredScope(request *http.Request) string {
+	switch {
+	case request.Method == http.MethodGet || request.Method == http.MethodHead:
+		return models.ScopeRead
+	case IsImportPath(request.URL.Path):
+		return models.ScopeImport
+	default:
+		return models.ScopeWrite
+	}
+}
+
+// IsImportPath reports whether path is one of the CSV import routes: POST
+// /cards/import and its HTML form, POST /cards/import/set/{setcode} and POST
+// /imports/{id}/rerun.
+func IsImportPath(path string) bool {
+	switch {
+	case path == "/cards/import" || path == "/cards/import/html" || strings.HasPrefix(path, "/cards/import/set/"):
+		return true
+	default:
+		return strings.HasPrefix(path, "/imports/") && strings.HasSuffix(path, "/rerun")
+	}
+}
+
+// Middleware authenticates requests carrying an "Authorization: Bearer"
+// header against db's tokens and makes the token available through
+// FromContext. Returns 401 Unauthorized for unknown or revoked tokens, and 403
+// Forbidden when the token lacks the scope the request needs or the request
+// is for the token management routes. Requests without the header are served
+// unchanged.
+func Middleware(db *database.Database, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		secret, ok := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			next.ServeHTTP(responseWriter, request)
+			return
+		}
+
+		token, err := db.AuthenticateAPIToken(strings.TrimSpace(secret))
+		if errors.Is(err, database.ErrAPITokenNotFound) {
+			slog.WarnContext(request.Context(), "rejected unknown or revoked API token", "path", request.URL.Path)
+			responseWriter.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(responseWriter, "invalid API token", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error authenticating API token", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		if strings.HasPrefix(request.URL.Path, managementPath) {
+			slog.WarnContext(request.Context(), "API token used on token management route", "token_id", token.ID, "path", request.URL.Path)
+			http.Error(responseWriter, "API tokens cannot manage tokens", http.StatusForbidden)
+			return
+		}
+
+		scope := requiredScope(request)
+		if !slices.Contains(token.Scopes, scope) {
+			slog.WarnContext(request.Context(), "API token lacks scope", "token_id", token.ID, "scope", scope, "path", request.URL.Path)
+			http.Error(responseWriter, "API token lacks the "+scope+" scope", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(responseWriter, request.WithContext(context.WithValue(request.Context(), contextKey{}, token)))
+	})
+}
+
+// ListHandler returns an http.HandlerFunc that handles GET /admin/tokens.
+// Returns 200 OK with every token, without secrets, as JSON, and 500 Internal
+// Server Error for database errors.
+func ListHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		tokens, err := db.GetAPITokens()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading API tokens", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, tokens)
+	}
+}
+
+// createdToken is the response to a token creation: the token and, this one
+// time only, its secret.
+type createdToken struct {
+	models.APIToken
+	Secret string `json:"secret"`
+}
+
+// CreateHandler returns an http.HandlerFunc that handles POST /admin/tokens
+// with form values "name", "role" and one "scope" per scope. Returns 201
+// Created with the token and its secret as JSON, 400 Bad Request for a missing
+// name or unknown scopes or role, and 500 Internal Server Error for database
+// errors.
+func CreateHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "POST /admin/tokens received")
+
+		if err := request.ParseForm(); err != nil {
+			http.Error(responseWriter, "invalid form data", http.StatusBadRequest)
+			return
+		}
+
+		name := request.PostForm.Get("name")
+		scopes := request.PostForm["scope"]
+		role := request.PostForm.Get("role")
+		if err := database.ValidateAPIToken(name, scopes, role); err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		token, secret, err := db.CreateAPIToken(name, scopes, role)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error creating API token", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "API token created", "token_id", token.ID, "name", token.Name, "scopes", token.Scopes, "role", token.Role)
+
+		httpjson.Write(responseWriter, request, http.StatusCreated, createdToken{APIToken: token, Secret: secret})
+	}
+}
+
+// RevokeHandler returns an http.HandlerFunc that handles DELETE
+// /admin/tokens/{id}. Returns 204 No Content once the token is revoked, 400
+// Bad Request for an invalid id, 404 Not Found if no such token exists, and
+// 500 Internal Server Error for database errors.
+func RevokeHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, err := strconv.Atoi(request.PathValue("id"))
+		if err != nil || id <= 0 {
+			http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "DELETE /admin/tokens/{id} received", "token_id", id)
+
+		err = db.RevokeAPIToken(id)
+		if errors.Is(err, database.ErrAPITokenNotFound) {
+			http.Error(responseWriter, "API token not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error revoking API token", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "API token revoked", "token_id", id)
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,146 @@
+package apitokens_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/apitokens"
+	"swucol/database"
+	"swucol/internal/testdb"
+	"swucol/models"
+)
+
+// serveWithToken sends a request through Middleware with secret as its bearer
+// token and returns the response. The wrapped handler responds 200 OK with
+// the authenticated token's name.
+func serveWithToken(t *testing.T, db *database.Database, method, target, secret string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	handler := apitokens.Middleware(db, http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		token, _ := apitokens.FromContext(request.Context())
+		responseWriter.Write([]byte(token.Name))
+	}))
+
+	request := httptest.NewRequest(method, target, nil)
+	if secret != "" {
+		request.Header.Set("Authorization", "Bearer "+secret)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func TestMiddleware_EnforcesScopes(t *testing.T) {
+	db := testdb.New(t)
+	_, readSecret, err := db.CreateAPIToken("reader", []string{models.ScopeRead}, models.RoleViewer)
+	require.NoError(t, err)
+	_, importSecret, err := db.CreateAPIToken("importer", []string{models.ScopeImport}, models.RoleAdmin)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		secret   string
+		expected int
+	}{
+		{name: "read token reads", method: http.MethodGet, target: "/cards/search?q=luke", secret: readSecret, expected: http.StatusOK},
+		{name: "read token cannot write", method: http.MethodPost, target: "/cards/1/increment", secret: readSecret, expected: http.StatusForbidden},
+		{name: "import token imports", method: http.MethodPost, target: "/cards/import", secret: importSecret, expected: http.StatusOK},
+		{name: "import token imports a set", method: http.MethodPost, target: "/cards/import/set/SOR", secret: importSecret, expected: http.StatusOK},
+		{name: "import token cannot read", method: http.MethodGet, target: "/cards/search", secret: importSecret, expected: http.StatusForbidden},
+		{name: "tokens cannot manage tokens", method: http.MethodGet, target: "/admin/tokens", secret: readSecret, expected: http.StatusForbidden},
+		{name: "unknown token", method: http.MethodGet, target: "/cards/search", secret: "swucol_UNKNOWN", expected: http.StatusUnauthorized},
+		{name: "no token", method: http.MethodPost, target: "/cards/1/increment", expected: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serveWithToken(t, db, test.method, test.target, test.secret)
+
+			assert.Equal(t, test.expected, recorder.Code, recorder.Body.String())
+		})
+	}
+}
+
+func TestMiddleware_MakesTokenAvailable(t *testing.T) {
+	db := testdb.New(t)
+	_, secret, err := db.CreateAPIToken("reader", []string{models.ScopeRead}, models.RoleViewer)
+	require.NoError(t, err)
+
+	recorder := serveWithToken(t, db, http.MethodGet, "/cards/search", secret)
+
+	assert.Equal(t, "reader", recorder.Body.String())
+}
+
+func TestCreateHandler_ReturnsSecretThatAuthenticates(t *testing.T) {
+	db := testdb.New(t)
+
+	form := url.Values{"name": {"sync script"}, "scope": {"read", "write"}, "role": {"editor"}}
+	request := httptest.NewRequest(http.MethodPost, "/admin/tokens", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	apitokens.CreateHandler(db)(recorder, request)
+
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	var created struct {
+		ID     int      `json:"id"`
+		Scopes []string `json:"scopes"`
+		Role   string   `json:"role"`
+		Secret string   `json:"secret"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &created))
+	assert.Equal(t, []string{"read", "write"}, created.Scopes)
+	assert.Equal(t, "editor", created.Role)
+
+	token, err := db.AuthenticateAPIToken(created.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, token.ID)
+}
+
+func TestCreateHandler_InvalidScope_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	form := url.Values{"name": {"script"}, "scope": {"admin"}, "role": {"admin"}}
+	request := httptest.NewRequest(http.MethodPost, "/admin/tokens", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	apitokens.CreateHandler(db)(recorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestRevokeHandler_RevokesTokenAndListShowsIt(t *testing.T) {
+	db := testdb.New(t)
+	token, secret, err := db.CreateAPIToken("script", []string{models.ScopeRead}, models.RoleViewer)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /admin/tokens/{id}", apitokens.RevokeHandler(db))
+	mux.HandleFunc("GET /admin/tokens", apitokens.ListHandler(db))
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/admin/tokens/"+strconv.Itoa(token.ID), nil))
+	require.Equal(t, http.StatusNoContent, recorder.Code)
+
+	assert.Equal(t, http.StatusUnauthorized, serveWithToken(t, db, http.MethodGet, "/cards/search", secret).Code)
+
+	listRecorder := httptest.NewRecorder()
+	mux.ServeHTTP(listRecorder, httptest.NewRequest(http.MethodGet, "/admin/tokens", nil))
+	require.Equal(t, http.StatusOK, listRecorder.Code)
+	assert.Contains(t, listRecorder.Body.String(), `"revoked_at"`)
+	assert.NotContains(t, listRecorder.Body.String(), secret)
+
+	missingRecorder := httptest.NewRecorder()
+	mux.ServeHTTP(missingRecorder, httptest.NewRequest(http.MethodDelete, "/admin/tokens/999", nil))
+	assert.Equal(t, http.StatusNotFound, missingRecorder.Code)
+}
@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	"swucol/database"
+	"swucol/models"
+)
+
+// ActivityDays is the number of days, ending today, covered by GET
+// /audit/activity and the activity heatmap.
+const ActivityDays = 365
+
+// Heatmap dimensions, in SVG user units. Each day is a square cell of
+// heatmapCell with heatmapGap between cells; month labels take the first
+// heatmapLabelHeight units at the top.
+const (
+	heatmapCell        = 11
+	heatmapGap         = 2
+	heatmapLabelHeight = 12
+)
+
+// heatmapLevels is the number of shades for days with activity; days without
+// any are level 0.
+const heatmapLevels = 4
+
+// Activity returns the number of audit events on each of the ActivityDays UTC
+// days ending on now's date, oldest first, including days without any.
+func Activity(db *database.Database, now time.Time) ([]models.ActivityDay, error) {
+	now = now.UTC()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	start := end.AddDate(0, 0, -ActivityDays)
+
+	counts, err := db.GetAuditDailyCounts(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]models.ActivityDay, ActivityDays)
+	for i := range days {
+		date := start.AddDate(0, 0, i).Format(dateLayout)
+		days[i] = models.ActivityDay{Date: date, Count: counts[date]}
+	}
+
+	return days, nil
+}
+
+// HeatmapCell is one day of a Heatmap at X, Y. Level is 0 for a day without
+// activity and 1 to 4 otherwise, relative to the busiest day.
+type HeatmapCell struct {
+	X, Y  int
+	Date  string
+	Count int
+	Level int
+	Label string
+}
+
+// HeatmapLabel is a month name shown above the first week starting in it.
+type HeatmapLabel struct {
+	X    int
+	Text string
+}
+
+// Heatmap is a GitHub-style calendar of activity laid out for an SVG viewBox
+// of Width by Height: one column per week, Sunday at the top.
+type Heatmap struct {
+	Width, Height int
+	// CellSize is the side of each day's square.
+	CellSize int
+	Cells    []HeatmapCell
+	Months   []HeatmapLabel
+	// Total is the number of events over every day.
+	Total int
+}
+
+// BuildHeatmap lays out days, which must be consecutive and ordered oldest
+// first, as Activity returns them. Returns an empty heatmap for no days.
+func BuildHeatmap(days []models.ActivityDay) Heatmap {
+	heatmap := Heatmap{Height: heatmapLabelHeight + 7*(heatmapCell+heatmapGap), CellSize: heatmapCell}
+	if len(days) == 0 {
+		return heatmap
+	}
+
+	busiest := 0
+	for _, day := range days {
+		busiest = max(busiest, day.Count)
+		heatmap.Total += day.Count
+	}
+
+	first, err := time.Parse(dateLayout, days[0].Date)
+	if err != nil {
+		return heatmap
+	}
+	offset := int(first.Weekday())
+
+	lastMonth := time.Month(0)
+	for i, day := range days {
+		date := first.AddDate(0, 0, i)
+		week, weekday := (offset+i)/7, (offset+i)%7
+		x := week * (heatmapCell + heatmapGap)
+
+		if weekday == 0 && date.Month() != lastMonth {
+			// Label each month above the first week that starts in it.
+			heatmap.Months = append(heatmap.Months, HeatmapLabel{X: x, Text: date.Format("Jan")})
+			lastMonth = date.Month()
+		}
+
+		heatmap.Cells = append(heatmap.Cells, HeatmapCell{
+			X:     x,
+			Y:     heatmapLabelHeight + weekday*(heatmapCell+heatmapGap),
+			Date:  day.Date,
+			Count: day.Count,
+			Level: level(day.Count, busiest),
+			Label: changesLabel(date, day.Count),
+		})
+		heatmap.Width = x + heatmapCell
+	}
+
+	return heatmap
+}
+
+// changesLabel is the tooltip of the day at date with count events.
+func changesLabel(date time.Time, count int) string {
+	if count == 1 {
+		return date.Format("Jan 2 2006") + ": 1 change"
+	}
+	return fmt.Sprintf("%s: %d changes", date.Format("Jan 2 2006"), count)
+}
+
+// level returns the shade of a day with count events when the busiest day
+// had busiest: 0 for none, and 1 to heatmapLevels in proportion otherwise.
+func level(count, busiest int) int {
+	if count <= 0 || busiest <= 0 {
+		return 0
+	}
+	return (count*heatmapLevels + busiest - 1) / busiest
+}
@@ -0,0 +1,58 @@
+package audit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/audit"
+	"swucol/internal/testdb"
+	"swucol/models"
+)
+
+func TestActivity_CountsEveryDayOfTheLastYear(t *testing.T) {
+	db := testdb.New(t)
+	now := time.Date(2024, 6, 15, 18, 0, 0, 0, time.UTC)
+	recordEvents(t, db, "phone", now.Add(-time.Hour), 3)
+	recordEvents(t, db, "phone", now.AddDate(0, 0, -10), 1)
+	recordEvents(t, db, "phone", now.AddDate(-1, 0, 0), 2)
+
+	days, err := audit.Activity(db, now)
+	require.NoError(t, err)
+
+	require.Len(t, days, audit.ActivityDays)
+	assert.Equal(t, models.ActivityDay{Date: "2024-06-15", Count: 3}, days[len(days)-1])
+	assert.Equal(t, models.ActivityDay{Date: "2024-06-05", Count: 1}, days[len(days)-11])
+	assert.Equal(t, "2023-06-17", days[0].Date, "expected events older than a year to be left out")
+	assert.Zero(t, days[0].Count)
+}
+
+func TestBuildHeatmap_LaysOutWeeksWithLevels(t *testing.T) {
+	// 2024-03-01 was a Friday.
+	days := []models.ActivityDay{
+		{Date: "2024-03-01", Count: 0},
+		{Date: "2024-03-02", Count: 1},
+		{Date: "2024-03-03", Count: 8},
+		{Date: "2024-03-04", Count: 4},
+	}
+
+	heatmap := audit.BuildHeatmap(days)
+
+	require.Len(t, heatmap.Cells, 4)
+	assert.Equal(t, 13, heatmap.Total)
+	assert.Equal(t, []int{0, 1, 4, 2}, []int{heatmap.Cells[0].Level, heatmap.Cells[1].Level, heatmap.Cells[2].Level, heatmap.Cells[3].Level})
+	assert.Equal(t, heatmap.Cells[0].X, heatmap.Cells[1].X, "expected Friday and Saturday in the same week")
+	assert.Greater(t, heatmap.Cells[2].X, heatmap.Cells[1].X, "expected Sunday to start a new week")
+	assert.Greater(t, heatmap.Cells[1].Y, heatmap.Cells[0].Y)
+	assert.Equal(t, "Mar 2 2024: 1 change", heatmap.Cells[1].Label)
+	assert.Equal(t, []audit.HeatmapLabel{{X: heatmap.Cells[2].X, Text: "Mar"}}, heatmap.Months)
+}
+
+func TestBuildHeatmap_NoDays_ReturnsEmptyHeatmap(t *testing.T) {
+	heatmap := audit.BuildHeatmap(nil)
+
+	assert.Empty(t, heatmap.Cells)
+	assert.Zero(t, heatmap.Total)
+}
@@ -0,0 +1,159 @@
+// Package audit keeps the log of changes to the collection, so that shared
+// instances can see who changed what. Subscribe records the changes handlers
+// publish on the event bus (see package eventbus); the user is the name of
+// the API token the request was made with (see package roles). GET /audit
+// and its HTML page list the events with filters, pages of PageSize events,
+// and CSV export.
+package audit
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"swucol/database"
+	"swucol/eventbus"
+	"swucol/models"
+	"swucol/roles"
+)
+
+// PageSize is the number of events on a page of GET /audit and its HTML page.
+const PageSize = 50
+
+// dateLayout is the layout of the from and to filter parameters.
+const dateLayout = "2006-01-02"
+
+// Actions lists the audit event actions, for validating the action filter.
+var Actions = []string{
+	models.AuditIncrement,
+	models.AuditDecrement,
+	models.AuditArchive,
+	models.AuditUnarchive,
+	models.AuditBulk,
+	models.AuditImport,
+	models.AuditSync,
+}
+
+// Record stores event as made by the request's user. A failure is logged
+// rather than returned: the change it records has already been made, and
+// losing its log entry should not fail the request.
+func Record(ctx context.Context, db *database.Database, event models.AuditEvent) {
+	event.User = roles.User(ctx)
+	if err := db.RecordAuditEvent(event); err != nil {
+		slog.ErrorContext(ctx, "failed to record audit event", "action", event.Action, "card_id", event.CardID, "error", err)
+	}
+}
+
+// Subscribe records the collection changes published on eventbus.Default in
+// db until the returned function is called: owned count changes, archiving,
+// bulk updates, and imports that added cards.
+func Subscribe(db *database.Database) func() {
+	unsubscribes := []func(){
+		eventbus.Subscribe(eventbus.OwnedChanged, func(ctx context.Context, event eventbus.Event) {
+			detail := "owned " + strconv.Itoa(event.Owned)
+			if event.Cause == models.AuditSync {
+				detail = fmt.Sprintf("owned %d → %d", event.Previous, event.Owned)
+			}
+			Record(ctx, db, models.AuditEvent{Action: event.Cause, CardID: event.CardID, CardName: event.CardName, Detail: detail})
+		}),
+		eventbus.Subscribe(eventbus.CardArchived, func(ctx context.Context, event eventbus.Event) {
+			Record(ctx, db, models.AuditEvent{Action: models.AuditArchive, CardID: event.CardID, CardName: event.CardName, Detail: "owned " + strconv.Itoa(event.Owned)})
+		}),
+		eventbus.Subscribe(eventbus.CardUnarchived, func(ctx context.Context, event eventbus.Event) {
+			Record(ctx, db, models.AuditEvent{Action: models.AuditUnarchive, CardID: event.CardID, CardName: event.CardName, Detail: "owned " + strconv.Itoa(event.Owned)})
+		}),
+		eventbus.Subscribe(eventbus.CardsUpdated, func(ctx context.Context, event eventbus.Event) {
+			Record(ctx, db, models.AuditEvent{Action: models.AuditBulk, Detail: event.Detail})
+		}),
+		eventbus.Subscribe(eventbus.ImportCompleted, func(ctx context.Context, event eventbus.Event) {
+			if event.Count > 0 {
+				Record(ctx, db, models.AuditEvent{Action: models.AuditImport, Detail: fmt.Sprintf("%d cards added", event.Count)})
+			}
+		}),
+	}
+
+	return func() {
+		for _, unsubscribe := range unsubscribes {
+			unsubscribe()
+		}
+	}
+}
+
+// ParseFilter reads the "card", "user", "action", "from" and "to" query
+// parameters into a filter. from and to are dates (2006-01-02, UTC), both
+// inclusive. Returns an error suitable for a 400 response for an unknown
+// action, a malformed date, or to before from.
+func ParseFilter(query url.Values) (database.AuditFilter, error) {
+	filter := database.AuditFilter{
+		Card:   strings.TrimSpace(query.Get("card")),
+		User:   strings.TrimSpace(query.Get("user")),
+		Action: strings.TrimSpace(query.Get("action")),
+	}
+
+	if filter.Action != "" && !slices.Contains(Actions, filter.Action) {
+		return database.AuditFilter{}, fmt.Errorf("action must be one of: %s", strings.Join(Actions, ", "))
+	}
+
+	var err error
+	if filter.From, err = parseDate(query.Get("from")); err != nil {
+		return database.AuditFilter{}, errors.New("from must be a date such as 2024-03-01")
+	}
+	if filter.To, err = parseDate(query.Get("to")); err != nil {
+		return database.AuditFilter{}, errors.New("to must be a date such as 2024-03-31")
+	}
+	if !filter.To.IsZero() {
+		// The filter's To is exclusive, so the whole of the to date matches.
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+		return database.AuditFilter{}, errors.New("to must not be before from")
+	}
+
+	return filter, nil
+}
+
+// parseDate parses a date parameter, returning the zero time when raw is
+// blank.
+func parseDate(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(dateLayout, raw)
+}
+
+// WriteCSV writes events to writer as CSV with a header row.
+func WriteCSV(writer io.Writer, events []models.AuditEvent) error {
+	csvWriter := csv.NewWriter(writer)
+
+	if err := csvWriter.Write([]string{"Time", "User", "Action", "Card ID", "Card", "Detail"}); err != nil {
+		return fmt.Errorf("write audit CSV: %w", err)
+	}
+
+	for _, event := range events {
+		cardID := ""
+		if event.CardID > 0 {
+			cardID = strconv.Itoa(event.CardID)
+		}
+		record := []string{event.OccurredAt.UTC().Format(time.RFC3339), event.User, event.Action, cardID, event.CardName, event.Detail}
+		if err := csvWriter.Write(record); err != nil {
+			return fmt.Errorf("write audit CSV: %w", err)
+		}
+	}
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("write audit CSV: %w", err)
+	}
+
+	return nil
+}
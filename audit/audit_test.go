@@ -0,0 +1,95 @@
+package audit_test
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/audit"
+	"swucol/database"
+	"swucol/eventbus"
+	"swucol/internal/testdb"
+	"swucol/models"
+)
+
+func TestParseFilter_ReadsFiltersWithInclusiveDates(t *testing.T) {
+	filter, err := audit.ParseFilter(url.Values{
+		"card":   {" luke "},
+		"user":   {"phone"},
+		"action": {"increment"},
+		"from":   {"2024-03-01"},
+		"to":     {"2024-03-31"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "luke", filter.Card)
+	assert.Equal(t, "phone", filter.User)
+	assert.Equal(t, models.AuditIncrement, filter.Action)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), filter.From)
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), filter.To)
+}
+
+func TestParseFilter_RejectsInvalidValues(t *testing.T) {
+	tests := map[string]url.Values{
+		"unknown action": {"action": {"delete"}},
+		"malformed from": {"from": {"March 1"}},
+		"malformed to":   {"to": {"2024-13-01"}},
+		"to before from": {"from": {"2024-03-02"}, "to": {"2024-03-01"}},
+	}
+
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := audit.ParseFilter(query)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestWriteCSV_WritesHeaderAndEvents(t *testing.T) {
+	var builder strings.Builder
+	events := []models.AuditEvent{
+		{OccurredAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), User: "phone", Action: models.AuditIncrement, CardID: 4, CardName: "Luke Skywalker, Jedi Knight", Detail: "owned 2"},
+		{OccurredAt: time.Date(2024, 3, 2, 9, 30, 0, 0, time.UTC), Action: models.AuditImport, Detail: "3 cards added"},
+	}
+
+	require.NoError(t, audit.WriteCSV(&builder, events))
+
+	assert.Equal(t, "Time,User,Action,Card ID,Card,Detail\n"+
+		"2024-03-01T12:00:00Z,phone,increment,4,\"Luke Skywalker, Jedi Knight\",owned 2\n"+
+		"2024-03-02T09:30:00Z,,import,,,3 cards added\n", builder.String())
+}
+
+func TestSubscribe_RecordsPublishedChanges(t *testing.T) {
+	db := testdb.New(t)
+	unsubscribe := audit.Subscribe(db)
+
+	ctx := context.Background()
+	eventbus.Publish(ctx, eventbus.Event{Type: eventbus.OwnedChanged, CardID: 7, CardName: "Luke Skywalker", Cause: models.AuditIncrement, Owned: 3})
+	eventbus.Publish(ctx, eventbus.Event{Type: eventbus.OwnedChanged, CardName: "Han Solo", Cause: models.AuditSync, Previous: 1, Owned: 4})
+	eventbus.Publish(ctx, eventbus.Event{Type: eventbus.CardArchived, CardID: 7, CardName: "Luke Skywalker", Owned: 3})
+	eventbus.Publish(ctx, eventbus.Event{Type: eventbus.ImportCompleted, Count: 0})
+	eventbus.Publish(ctx, eventbus.Event{Type: eventbus.ImportCompleted, Count: 12})
+	eventbus.Publish(ctx, eventbus.Event{Type: eventbus.CardInserted, CardName: "Leia Organa"})
+	unsubscribe()
+	eventbus.Publish(ctx, eventbus.Event{Type: eventbus.CardsUpdated, Count: 2, Detail: "archive on 2 cards"})
+
+	events, err := db.GetAuditEvents(database.AuditFilter{}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 4, "expected no events for empty imports, inserted cards, or after unsubscribing")
+
+	details := make([]string, len(events))
+	for i, event := range events {
+		details[i] = event.Action + ": " + event.CardName + " " + event.Detail
+	}
+	assert.ElementsMatch(t, []string{
+		"increment: Luke Skywalker owned 3",
+		"sync: Han Solo owned 1 → 4",
+		"archive: Luke Skywalker owned 3",
+		"import:  12 cards added",
+	}, details)
+}
@@ -0,0 +1,228 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"swucol/csrf"
+	"swucol/database"
+	"swucol/models"
+	"swucol/templates"
+)
+
+// auditList is the JSON response of GET /audit: one page of events, newest
+// first, and the number of events and pages matching the filters.
+type auditList struct {
+	Events []models.AuditEvent `json:"events"`
+	Total  int                 `json:"total"`
+	Page   int                 `json:"page"`
+	Pages  int                 `json:"pages"`
+}
+
+// auditPage is the view model rendered by the audit template. Query holds the
+// raw filter parameters, to fill the filter form and build the page links.
+type auditPage struct {
+	auditList
+	Query     url.Values
+	Users     []string
+	Actions   []string
+	PrevPage  string
+	NextPage  string
+	ExportURL string
+	Error     string
+	Theme     string
+	CSRFToken string
+}
+
+// ListHandler returns an http.HandlerFunc that handles GET /audit. It reads
+// the filters described by ParseFilter and the optional "page" parameter,
+// which defaults to 1. Returns 200 OK with a JSON auditList, or with
+// "format=csv" every matching event as a CSV attachment. Returns 400 Bad
+// Request for invalid filters or page, and 500 Internal Server Error for
+// database errors.
+func ListHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+
+		filter, err := ParseFilter(query)
+		if err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if query.Get("format") == "csv" {
+			events, err := db.GetAuditEvents(filter, 0, 0)
+			if err != nil {
+				slog.ErrorContext(request.Context(), "database error exporting audit events", "error", err)
+				http.Error(responseWriter, "database error", http.StatusInternalServerError)
+				return
+			}
+
+			responseWriter.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			responseWriter.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+			if err := WriteCSV(responseWriter, events); err != nil {
+				slog.ErrorContext(request.Context(), "failed to write audit CSV", "error", err)
+			}
+			return
+		}
+
+		page, err := parsePage(query.Get("page"))
+		if err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		list, err := loadList(db, filter, page)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading audit events", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(list); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode audit response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ActivityHandler returns an http.HandlerFunc that handles GET
+// /audit/activity. Returns 200 OK with a JSON array of the number of audit
+// events on each of the last ActivityDays days (UTC), oldest first and
+// including days without any, and 500 Internal Server Error for database
+// errors.
+func ActivityHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		days, err := Activity(db, time.Now())
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading audit activity", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(days); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode audit activity response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// PageHandler returns an http.HandlerFunc that serves the audit log page at
+// GET /audit/html, with the same filters and page parameter as GET /audit.
+// Invalid filters are shown on the page above an empty list. Returns 500
+// Internal Server Error for database errors or if template rendering fails.
+func PageHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+
+		users, err := db.GetAuditUsers()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading audit users", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		page := auditPage{
+			auditList: auditList{Events: []models.AuditEvent{}, Page: 1},
+			Query:     query,
+			Users:     users,
+			Actions:   Actions,
+			Theme:     db.Settings().Theme,
+			CSRFToken: csrf.Token(request.Context()),
+		}
+
+		filter, err := ParseFilter(query)
+		number := 1
+		if err == nil {
+			number, err = parsePage(query.Get("page"))
+		}
+
+		if err != nil {
+			page.Error = err.Error()
+		} else {
+			page.auditList, err = loadList(db, filter, number)
+			if err != nil {
+				slog.ErrorContext(request.Context(), "database error loading audit events", "error", err)
+				templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+				return
+			}
+
+			if page.Page > 1 {
+				page.PrevPage = pageURL(query, page.Page-1)
+			}
+			if page.Page < page.Pages {
+				page.NextPage = pageURL(query, page.Page+1)
+			}
+
+			export := withoutPage(query)
+			export.Set("format", "csv")
+			page.ExportURL = "/audit?" + export.Encode()
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "audit", page); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render audit template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// loadList loads the given 1-based page of events matching filter.
+func loadList(db *database.Database, filter database.AuditFilter, page int) (auditList, error) {
+	total, err := db.CountAuditEvents(filter)
+	if err != nil {
+		return auditList{}, err
+	}
+
+	events, err := db.GetAuditEvents(filter, PageSize, (page-1)*PageSize)
+	if err != nil {
+		return auditList{}, err
+	}
+
+	return auditList{Events: events, Total: total, Page: page, Pages: (total + PageSize - 1) / PageSize}, nil
+}
+
+// parsePage parses the optional page parameter, which defaults to 1.
+// Returns an error suitable for a 400 response when it is not a positive
+// integer.
+func parsePage(rawPage string) (int, error) {
+	if rawPage == "" {
+		return 1, nil
+	}
+
+	page, err := strconv.Atoi(rawPage)
+	if err != nil || page <= 0 {
+		return 0, errors.New("page must be a positive integer")
+	}
+
+	return page, nil
+}
+
+// pageURL returns the audit page's URL for page with the filters in query.
+func pageURL(query url.Values, page int) string {
+	values := withoutPage(query)
+	values.Set("page", strconv.Itoa(page))
+	return "/audit/html?" + values.Encode()
+}
+
+// withoutPage returns a copy of query without its page and format
+// parameters.
+func withoutPage(query url.Values) url.Values {
+	values := url.Values{}
+	for key, value := range query {
+		if key != "page" && key != "format" {
+			values[key] = value
+		}
+	}
+	return values
+}
@@ -0,0 +1,130 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/audit"
+	"swucol/database"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/templates"
+)
+
+// recordEvents stores count increments of a card by user, a minute apart
+// from start.
+func recordEvents(t *testing.T, db *database.Database, user string, start time.Time, count int) {
+	t.Helper()
+
+	for i := range count {
+		require.NoError(t, db.RecordAuditEvent(models.AuditEvent{
+			OccurredAt: start.Add(time.Duration(i) * time.Minute),
+			User:       user,
+			Action:     models.AuditIncrement,
+			CardID:     1,
+			CardName:   "Luke Skywalker, Jedi Knight",
+			Detail:     "owned " + strconv.Itoa(i+1),
+		}))
+	}
+}
+
+func TestListHandler_FiltersAndPages(t *testing.T) {
+	db := testdb.New(t)
+	recordEvents(t, db, "phone", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), audit.PageSize+5)
+	recordEvents(t, db, "laptop", time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC), 3)
+
+	recorder := httptest.NewRecorder()
+	audit.ListHandler(db)(recorder, httptest.NewRequest(http.MethodGet, "/audit?user=phone&to=2024-03-31&page=2", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	var list struct {
+		Events []models.AuditEvent `json:"events"`
+		Total  int                 `json:"total"`
+		Page   int                 `json:"page"`
+		Pages  int                 `json:"pages"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &list))
+	assert.Equal(t, audit.PageSize+5, list.Total)
+	assert.Equal(t, 2, list.Page)
+	assert.Equal(t, 2, list.Pages)
+	require.Len(t, list.Events, 5)
+	assert.Equal(t, "owned 5", list.Events[0].Detail, "expected newest first")
+	assert.Equal(t, "phone", list.Events[0].User)
+}
+
+func TestListHandler_CSVExportsEveryMatchingEvent(t *testing.T) {
+	db := testdb.New(t)
+	recordEvents(t, db, "phone", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), audit.PageSize+5)
+	recordEvents(t, db, "laptop", time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC), 3)
+
+	recorder := httptest.NewRecorder()
+	audit.ListHandler(db)(recorder, httptest.NewRequest(http.MethodGet, "/audit?format=csv&user=laptop", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Contains(t, recorder.Header().Get("Content-Disposition"), "audit.csv")
+	assert.Equal(t, "Time,User,Action,Card ID,Card,Detail\n"+
+		"2024-04-01T12:02:00Z,laptop,increment,1,\"Luke Skywalker, Jedi Knight\",owned 3\n"+
+		"2024-04-01T12:01:00Z,laptop,increment,1,\"Luke Skywalker, Jedi Knight\",owned 2\n"+
+		"2024-04-01T12:00:00Z,laptop,increment,1,\"Luke Skywalker, Jedi Knight\",owned 1\n", recorder.Body.String())
+}
+
+func TestListHandler_InvalidFilter_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	for _, target := range []string{"/audit?action=delete", "/audit?page=0", "/audit?from=yesterday"} {
+		recorder := httptest.NewRecorder()
+		audit.ListHandler(db)(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, target)
+	}
+}
+
+func TestActivityHandler_ReturnsDailyCounts(t *testing.T) {
+	db := testdb.New(t)
+	recordEvents(t, db, "phone", time.Now().UTC().Add(-time.Minute), 1)
+
+	recorder := httptest.NewRecorder()
+	audit.ActivityHandler(db)(recorder, httptest.NewRequest(http.MethodGet, "/audit/activity", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var days []models.ActivityDay
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &days))
+	require.Len(t, days, audit.ActivityDays)
+	assert.Equal(t, 1, days[len(days)-1].Count+days[len(days)-2].Count, "expected the event today, or yesterday just after midnight")
+}
+
+func TestPageHandler_RendersEventsWithPageAndExportLinks(t *testing.T) {
+	db := testdb.New(t)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+	recordEvents(t, db, "phone", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), audit.PageSize+1)
+
+	recorder := httptest.NewRecorder()
+	audit.PageHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/audit/html?card=luke", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	body := recorder.Body.String()
+	assert.Contains(t, body, "Page 1 of 2 · 51 events")
+	assert.Contains(t, body, `href="/audit/html?card=luke&amp;page=2"`)
+	assert.Contains(t, body, `href="/audit?card=luke&amp;format=csv"`)
+	assert.Contains(t, body, `<option value="phone" >phone</option>`)
+}
+
+func TestPageHandler_InvalidFilter_ShowsError(t *testing.T) {
+	db := testdb.New(t)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	audit.PageHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/audit/html?from=yesterday", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "from must be a date such as 2024-03-01")
+}
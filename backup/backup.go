@@ -0,0 +1,299 @@
+// Package backup uploads database snapshots and card images to S3-compatible
+// object storage for off-site safety, and restores them again.
+//
+// Objects are laid out under the configured prefix as:
+//
+//	snapshots/{timestamp}/swucol.db
+//	images/{file}
+//
+// Images are only uploaded when not already present, since image files never
+// change once downloaded.
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"swucol/database"
+)
+
+// snapshotFileName is the object name used for each database snapshot.
+const snapshotFileName = "swucol.db"
+
+// snapshotTimestampLayout names snapshot folders so that lexical order is
+// chronological.
+const snapshotTimestampLayout = "20060102T150405.000000000Z"
+
+// Config describes the bucket backups are written to.
+type Config struct {
+	// Endpoint is the base URL of the storage service, e.g.
+	// https://s3.us-east-1.amazonaws.com or http://localhost:9000 for MinIO.
+	Endpoint string
+	Bucket   string
+	Region   string
+	// Prefix is prepended to every object key. It may be empty.
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	// Interval is the time between scheduled backups.
+	Interval time.Duration
+	// StagingDir is where snapshots are written before they are uploaded.
+	// The system temporary directory is used when it is empty.
+	StagingDir string
+}
+
+// ConfigFromEnv reads the backup configuration from SWUCOL_BACKUP_* environment
+// variables. Backups are optional: when SWUCOL_BACKUP_BUCKET is unset, it
+// returns false and no error. Region defaults to us-east-1 and Interval to 24
+// hours. Returns an error when the bucket is set but the configuration is
+// incomplete or invalid.
+func ConfigFromEnv() (Config, bool, error) {
+	config := Config{
+		Endpoint:  os.Getenv("SWUCOL_BACKUP_ENDPOINT"),
+		Bucket:    os.Getenv("SWUCOL_BACKUP_BUCKET"),
+		Region:    os.Getenv("SWUCOL_BACKUP_REGION"),
+		Prefix:    os.Getenv("SWUCOL_BACKUP_PREFIX"),
+		AccessKey: os.Getenv("SWUCOL_BACKUP_ACCESS_KEY"),
+		SecretKey: os.Getenv("SWUCOL_BACKUP_SECRET_KEY"),
+		Interval:  24 * time.Hour,
+	}
+
+	if config.Bucket == "" {
+		return Config{}, false, nil
+	}
+
+	if config.Endpoint == "" || config.AccessKey == "" || config.SecretKey == "" {
+		return Config{}, false, errors.New("SWUCOL_BACKUP_ENDPOINT, SWUCOL_BACKUP_ACCESS_KEY and SWUCOL_BACKUP_SECRET_KEY must be set when SWUCOL_BACKUP_BUCKET is set")
+	}
+
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	if rawInterval := os.Getenv("SWUCOL_BACKUP_INTERVAL"); rawInterval != "" {
+		interval, err := time.ParseDuration(rawInterval)
+		if err != nil || interval <= 0 {
+			return Config{}, false, fmt.Errorf("SWUCOL_BACKUP_INTERVAL must be a positive duration, got %q", rawInterval)
+		}
+		config.Interval = interval
+	}
+
+	return config, true, nil
+}
+
+// Backuper writes snapshots to and restores them from a bucket.
+type Backuper struct {
+	client     *s3Client
+	prefix     string
+	stagingDir string
+}
+
+// New returns a Backuper for config that sends requests with httpClient.
+func New(httpClient *http.Client, config Config) *Backuper {
+	return &Backuper{
+		client:     newS3Client(httpClient, config),
+		prefix:     config.Prefix,
+		stagingDir: config.StagingDir,
+	}
+}
+
+// Backup uploads a consistent snapshot of db and any images in imagesDir
+// that are not yet in the bucket. Returns the key of the uploaded snapshot.
+func (backuper *Backuper) Backup(ctx context.Context, db *database.Database, imagesDir string) (string, error) {
+	stagingDir := backuper.stagingDir
+	if stagingDir == "" {
+		stagingDir = os.TempDir()
+	}
+
+	snapshotPath := filepath.Join(stagingDir, fmt.Sprintf("swucol-snapshot-%d.db", time.Now().UnixNano()))
+	defer os.Remove(snapshotPath)
+
+	if err := db.SnapshotTo(snapshotPath); err != nil {
+		return "", fmt.Errorf("snapshot database: %w", err)
+	}
+
+	snapshot, err := os.ReadFile(snapshotPath)
+	if err != nil {
+		return "", fmt.Errorf("read snapshot: %w", err)
+	}
+
+	snapshotKey := backuper.prefix + "snapshots/" + time.Now().UTC().Format(snapshotTimestampLayout) + "/" + snapshotFileName
+	if err := backuper.client.PutObject(ctx, snapshotKey, snapshot); err != nil {
+		return "", fmt.Errorf("upload snapshot: %w", err)
+	}
+
+	uploaded, err := backuper.uploadImages(ctx, imagesDir)
+	if err != nil {
+		return "", fmt.Errorf("upload images: %w", err)
+	}
+
+	slog.Info("backup complete", "snapshot", snapshotKey, "snapshot_bytes", len(snapshot), "images_uploaded", uploaded)
+
+	return snapshotKey, nil
+}
+
+// uploadImages uploads every file in imagesDir whose key is not already in
+// the bucket and returns how many were uploaded. A missing imagesDir is
+// treated as empty.
+func (backuper *Backuper) uploadImages(ctx context.Context, imagesDir string) (int, error) {
+	entries, err := os.ReadDir(imagesDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read images directory: %w", err)
+	}
+
+	imagesPrefix := backuper.prefix + "images/"
+	existingKeys, err := backuper.client.ListKeys(ctx, imagesPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	existing := make(map[string]bool, len(existingKeys))
+	for _, key := range existingKeys {
+		existing[key] = true
+	}
+
+	uploaded := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		key := imagesPrefix + entry.Name()
+		if existing[key] {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(imagesDir, entry.Name()))
+		if err != nil {
+			return uploaded, fmt.Errorf("read image %q: %w", entry.Name(), err)
+		}
+
+		if err := backuper.client.PutObject(ctx, key, data); err != nil {
+			return uploaded, err
+		}
+		uploaded++
+	}
+
+	return uploaded, nil
+}
+
+// LatestSnapshot returns the key of the most recent snapshot in the bucket.
+// Returns an error when the bucket holds no snapshots.
+func (backuper *Backuper) LatestSnapshot(ctx context.Context) (string, error) {
+	keys, err := backuper.client.ListKeys(ctx, backuper.prefix+"snapshots/")
+	if err != nil {
+		return "", err
+	}
+
+	snapshots := []string{}
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/"+snapshotFileName) {
+			snapshots = append(snapshots, key)
+		}
+	}
+
+	if len(snapshots) == 0 {
+		return "", errors.New("no snapshots found in bucket")
+	}
+
+	sort.Strings(snapshots)
+	return snapshots[len(snapshots)-1], nil
+}
+
+// Restore downloads the snapshot stored under snapshotKey (the latest snapshot
+// when empty) to dbPath, replacing any existing file, and downloads every
+// backed-up image that is missing from imagesDir. Any write-ahead log files
+// next to dbPath are removed. The database at dbPath must not be open while
+// restoring. Returns the key of the restored snapshot.
+func (backuper *Backuper) Restore(ctx context.Context, dbPath, imagesDir, snapshotKey string) (string, error) {
+	if snapshotKey == "" {
+		latest, err := backuper.LatestSnapshot(ctx)
+		if err != nil {
+			return "", fmt.Errorf("find latest snapshot: %w", err)
+		}
+		snapshotKey = latest
+	}
+
+	snapshot, err := backuper.client.GetObject(ctx, snapshotKey)
+	if err != nil {
+		return "", fmt.Errorf("download snapshot: %w", err)
+	}
+
+	temporaryPath := dbPath + ".restore"
+	if err := os.WriteFile(temporaryPath, snapshot, 0o644); err != nil {
+		return "", fmt.Errorf("write snapshot: %w", err)
+	}
+
+	// A write-ahead log left behind by an unclean shutdown belongs to the old
+	// file and must not be replayed into the restored one.
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			os.Remove(temporaryPath)
+			return "", fmt.Errorf("remove stale write-ahead log: %w", err)
+		}
+	}
+
+	if err := os.Rename(temporaryPath, dbPath); err != nil {
+		os.Remove(temporaryPath)
+		return "", fmt.Errorf("replace database file: %w", err)
+	}
+
+	restored, err := backuper.restoreImages(ctx, imagesDir)
+	if err != nil {
+		return "", fmt.Errorf("restore images: %w", err)
+	}
+
+	slog.Info("restore complete", "snapshot", snapshotKey, "images_restored", restored)
+
+	return snapshotKey, nil
+}
+
+// restoreImages downloads every backed-up image that does not exist in
+// imagesDir and returns how many were written.
+func (backuper *Backuper) restoreImages(ctx context.Context, imagesDir string) (int, error) {
+	imagesPrefix := backuper.prefix + "images/"
+	keys, err := backuper.client.ListKeys(ctx, imagesPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(imagesDir, 0o755); err != nil {
+		return 0, fmt.Errorf("create images directory: %w", err)
+	}
+
+	restored := 0
+	for _, key := range keys {
+		fileName := strings.TrimPrefix(key, imagesPrefix)
+		if fileName == "" || strings.Contains(fileName, "/") {
+			continue
+		}
+
+		filePath := filepath.Join(imagesDir, fileName)
+		if _, err := os.Stat(filePath); err == nil {
+			continue
+		}
+
+		data, err := backuper.client.GetObject(ctx, key)
+		if err != nil {
+			return restored, err
+		}
+
+		if err := os.WriteFile(filePath, data, 0o644); err != nil {
+			return restored, fmt.Errorf("write image %q: %w", fileName, err)
+		}
+		restored++
+	}
+
+	return restored, nil
+}
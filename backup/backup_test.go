@@ -0,0 +1,231 @@
+package backup_test
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/backup"
+	"swucol/database"
+	"swucol/models"
+)
+
+// fakeBucket is an in-memory stand-in for an S3-compatible bucket that
+// supports path-style PUT, GET, and ListObjectsV2 requests.
+type fakeBucket struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	puts    int
+}
+
+func (fake *fakeBucket) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	if !strings.HasPrefix(request.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-access/") {
+		http.Error(responseWriter, "missing signature", http.StatusForbidden)
+		return
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	key := strings.TrimPrefix(strings.TrimPrefix(request.URL.Path, "/"+fake.bucket), "/")
+
+	switch {
+	case request.Method == http.MethodPut:
+		body, _ := io.ReadAll(request.Body)
+		fake.objects[key] = body
+		fake.puts++
+	case request.Method == http.MethodGet && key == "":
+		type content struct {
+			Key string `xml:"Key"`
+		}
+		var result struct {
+			XMLName  xml.Name  `xml:"ListBucketResult"`
+			Contents []content `xml:"Contents"`
+		}
+		keys := []string{}
+		for objectKey := range fake.objects {
+			if strings.HasPrefix(objectKey, request.URL.Query().Get("prefix")) {
+				keys = append(keys, objectKey)
+			}
+		}
+		sort.Strings(keys)
+		for _, objectKey := range keys {
+			result.Contents = append(result.Contents, content{Key: objectKey})
+		}
+		xml.NewEncoder(responseWriter).Encode(result)
+	case request.Method == http.MethodGet:
+		body, ok := fake.objects[key]
+		if !ok {
+			http.Error(responseWriter, "no such key", http.StatusNotFound)
+			return
+		}
+		responseWriter.Write(body)
+	default:
+		http.Error(responseWriter, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+// newFakeBackuper starts a fake bucket server and returns it with a Backuper
+// configured to use it.
+func newFakeBackuper(t *testing.T) (*fakeBucket, *backup.Backuper) {
+	t.Helper()
+
+	fake := &fakeBucket{bucket: "swucol", objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	backuper := backup.New(server.Client(), backup.Config{
+		Endpoint:  server.URL,
+		Bucket:    "swucol",
+		Region:    "us-east-1",
+		Prefix:    "home/",
+		AccessKey: "test-access",
+		SecretKey: "test-secret",
+	})
+
+	return fake, backuper
+}
+
+// newTestDatabase creates a migrated Database backed by a file at filePath.
+func newTestDatabase(t *testing.T, filePath string) *database.Database {
+	t.Helper()
+
+	db, err := database.New(filePath)
+	require.NoError(t, err, "expected no error opening test database")
+
+	require.NoError(t, db.RunMigrations())
+
+	return db
+}
+
+func TestBackupAndRestore_RoundTripsDatabaseAndImages(t *testing.T) {
+	fake, backuper := newFakeBackuper(t)
+
+	sourceDir := t.TempDir()
+	db := newTestDatabase(t, filepath.Join(sourceDir, "swucol.db"))
+	defer db.Shutdown()
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight"}))
+
+	imagesDir := filepath.Join(sourceDir, "images")
+	require.NoError(t, os.MkdirAll(imagesDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(imagesDir, "SOR005.png"), []byte("png-data"), 0o644))
+
+	snapshotKey, err := backuper.Backup(context.Background(), db, imagesDir)
+
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(snapshotKey, "home/snapshots/"))
+	assert.Contains(t, fake.objects, "home/images/SOR005.png")
+
+	restoreDir := t.TempDir()
+	restoredPath := filepath.Join(restoreDir, "swucol.db")
+	restoredImages := filepath.Join(restoreDir, "images")
+	require.NoError(t, os.WriteFile(restoredPath+"-wal", []byte("stale"), 0o644))
+
+	restoredKey, err := backuper.Restore(context.Background(), restoredPath, restoredImages, "")
+
+	require.NoError(t, err)
+	assert.Equal(t, snapshotKey, restoredKey, "expected latest snapshot to be restored")
+	assert.NoFileExists(t, restoredPath+"-wal", "expected the stale write-ahead log to be removed")
+
+	image, err := os.ReadFile(filepath.Join(restoredImages, "SOR005.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "png-data", string(image))
+
+	restored := newTestDatabase(t, restoredPath)
+	defer restored.Shutdown()
+	exists, err := restored.CardExistsByName("Luke Skywalker, Jedi Knight")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestBackup_SkipsImagesAlreadyInBucket(t *testing.T) {
+	fake, backuper := newFakeBackuper(t)
+
+	dir := t.TempDir()
+	db := newTestDatabase(t, filepath.Join(dir, "swucol.db"))
+	defer db.Shutdown()
+
+	imagesDir := filepath.Join(dir, "images")
+	require.NoError(t, os.MkdirAll(imagesDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(imagesDir, "SOR005.png"), []byte("png-data"), 0o644))
+
+	_, err := backuper.Backup(context.Background(), db, imagesDir)
+	require.NoError(t, err)
+	_, err = backuper.Backup(context.Background(), db, imagesDir)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, fake.puts, "expected two snapshots and one image upload")
+}
+
+func TestRestore_NoSnapshots_ReturnsError(t *testing.T) {
+	_, backuper := newFakeBackuper(t)
+
+	dir := t.TempDir()
+	_, err := backuper.Restore(context.Background(), filepath.Join(dir, "swucol.db"), filepath.Join(dir, "images"), "")
+
+	assert.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "swucol.db"))
+}
+
+func TestConfigFromEnv_BucketUnset_ReturnsDisabled(t *testing.T) {
+	t.Setenv("SWUCOL_BACKUP_BUCKET", "")
+
+	_, enabled, err := backup.ConfigFromEnv()
+
+	require.NoError(t, err)
+	assert.False(t, enabled)
+}
+
+func TestConfigFromEnv_AppliesDefaults(t *testing.T) {
+	t.Setenv("SWUCOL_BACKUP_BUCKET", "swucol")
+	t.Setenv("SWUCOL_BACKUP_ENDPOINT", "http://localhost:9000")
+	t.Setenv("SWUCOL_BACKUP_ACCESS_KEY", "access")
+	t.Setenv("SWUCOL_BACKUP_SECRET_KEY", "secret")
+	t.Setenv("SWUCOL_BACKUP_REGION", "")
+	t.Setenv("SWUCOL_BACKUP_INTERVAL", "")
+
+	config, enabled, err := backup.ConfigFromEnv()
+
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, "us-east-1", config.Region)
+	assert.Equal(t, 24*time.Hour, config.Interval)
+}
+
+func TestConfigFromEnv_IncompleteOrInvalid_ReturnsError(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		interval string
+	}{
+		{name: "missing secret", secret: "", interval: ""},
+		{name: "invalid interval", secret: "secret", interval: "daily"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SWUCOL_BACKUP_BUCKET", "swucol")
+			t.Setenv("SWUCOL_BACKUP_ENDPOINT", "http://localhost:9000")
+			t.Setenv("SWUCOL_BACKUP_ACCESS_KEY", "access")
+			t.Setenv("SWUCOL_BACKUP_SECRET_KEY", tt.secret)
+			t.Setenv("SWUCOL_BACKUP_INTERVAL", tt.interval)
+
+			_, _, err := backup.ConfigFromEnv()
+
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Client is a minimal S3 client supporting the handful of operations the
+// backup needs (put, get, list) against AWS S3 or any S3-compatible store such
+// as MinIO. Requests use path-style addressing and AWS Signature Version 4.
+type s3Client struct {
+	httpClient *http.Client
+	endpoint   string
+	bucket     string
+	region     string
+	accessKey  string
+	secretKey  string
+	now        func() time.Time
+}
+
+// newS3Client returns an s3Client for the bucket described by config.
+func newS3Client(httpClient *http.Client, config Config) *s3Client {
+	return &s3Client{
+		httpClient: httpClient,
+		endpoint:   strings.TrimRight(config.Endpoint, "/"),
+		bucket:     config.Bucket,
+		region:     config.Region,
+		accessKey:  config.AccessKey,
+		secretKey:  config.SecretKey,
+		now:        time.Now,
+	}
+}
+
+// PutObject uploads body under key.
+func (client *s3Client) PutObject(ctx context.Context, key string, body []byte) error {
+	response, err := client.do(ctx, http.MethodPut, key, nil, body)
+	if err != nil {
+		return fmt.Errorf("put object %q: %w", key, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("put object %q: unexpected status code: %d", key, response.StatusCode)
+	}
+
+	return nil
+}
+
+// GetObject downloads the object stored under key.
+func (client *s3Client) GetObject(ctx context.Context, key string) ([]byte, error) {
+	response, err := client.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("get object %q: %w", key, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get object %q: unexpected status code: %d", key, response.StatusCode)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get object %q: read body: %w", key, err)
+	}
+
+	return body, nil
+}
+
+// listObjectsResult is the subset of the ListObjectsV2 response that is used.
+type listObjectsResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// ListKeys returns every key in the bucket that starts with prefix, following
+// continuation tokens until the listing is complete.
+func (client *s3Client) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	continuationToken := ""
+
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		response, err := client.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("list objects: %w", err)
+		}
+
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			return nil, fmt.Errorf("list objects: unexpected status code: %d", response.StatusCode)
+		}
+
+		var result listObjectsResult
+		err = xml.NewDecoder(response.Body).Decode(&result)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list objects: decode: %w", err)
+		}
+
+		for _, content := range result.Contents {
+			keys = append(keys, content.Key)
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+// do sends a signed request for key (the bucket itself when key is empty).
+func (client *s3Client) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	path := "/" + client.bucket
+	if key != "" {
+		path += "/" + key
+	}
+
+	endpoint, err := url.Parse(client.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint: %w", err)
+	}
+
+	canonicalURI := uriEncode(path, false)
+	canonicalQuery := canonicalQueryString(query)
+
+	target := endpoint.Scheme + "://" + endpoint.Host + canonicalURI
+	if canonicalQuery != "" {
+		target += "?" + canonicalQuery
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	client.sign(request, endpoint.Host, canonicalURI, canonicalQuery, body)
+
+	return client.httpClient.Do(request)
+}
+
+// sign adds AWS Signature Version 4 headers to request.
+func (client *s3Client) sign(request *http.Request, host, canonicalURI, canonicalQuery string, body []byte) {
+	now := client.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := sha256.Sum256(body)
+	payloadHashHex := hex.EncodeToString(payloadHash[:])
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHashHex)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + host + "\n" +
+		"x-amz-content-sha256:" + payloadHashHex + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		canonicalURI,
+		canonicalQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHashHex,
+	}, "\n")
+
+	scope := date + "/" + client.region + "/s3/aws4_request"
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey(client.secretKey, date, client.region, "s3"), stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		client.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+// signingKey derives the Signature Version 4 signing key for the given date
+// (YYYYMMDD), region and service.
+func signingKey(secretKey, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data using key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQueryString encodes query with keys sorted and every key and value
+// URI-encoded, as Signature Version 4 requires.
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes every byte of value except the RFC 3986
+// unreserved characters. Slashes are left alone unless encodeSlash is true.
+func uriEncode(value string, encodeSlash bool) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			builder.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			builder.WriteByte(c)
+		default:
+			fmt.Fprintf(&builder, "%%%02X", c)
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,62 @@
+// Package binder lays the collection out as the pages of a physical card
+// binder: nine pocket pages filled in set and collector number order.
+package binder
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"swucol/models"
+)
+
+// PocketsPerPage is the number of cards on a binder page, three rows of
+// three.
+const PocketsPerPage = 9
+
+// Page is one binder page. Number counts from 1.
+type Page struct {
+	Number int
+	Cards  []models.Card
+}
+
+// compareCollectorNumbers orders collector numbers numerically, so that "9"
+// comes before "10"; numbers that are not plain integers sort after those
+// that are, in string order.
+func compareCollectorNumbers(a, b string) int {
+	aNumber, aErr := strconv.Atoi(a)
+	bNumber, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return cmp.Compare(aNumber, bNumber)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// Layout sorts cards by set code, then collector number, then name, and
+// splits them into pages of PocketsPerPage. Returns an empty slice (never
+// nil) when there are no cards.
+func Layout(cards []models.Card) []Page {
+	sorted := slices.Clone(cards)
+	slices.SortStableFunc(sorted, func(a, b models.Card) int {
+		return cmp.Or(
+			strings.Compare(a.Set, b.Set),
+			compareCollectorNumbers(a.Number, b.Number),
+			strings.Compare(a.Name, b.Name),
+		)
+	})
+
+	pages := make([]Page, 0, (len(sorted)+PocketsPerPage-1)/PocketsPerPage)
+	for start := 0; start < len(sorted); start += PocketsPerPage {
+		end := min(start+PocketsPerPage, len(sorted))
+		pages = append(pages, Page{Number: len(pages) + 1, Cards: sorted[start:end]})
+	}
+
+	return pages
+}
@@ -0,0 +1,57 @@
+package binder_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/binder"
+	"swucol/models"
+)
+
+// names returns the names of cards, in order.
+func names(cards []models.Card) []string {
+	result := make([]string, len(cards))
+	for i, card := range cards {
+		result[i] = card.Name
+	}
+	return result
+}
+
+func TestLayout_OrdersBySetThenNumericCollectorNumber(t *testing.T) {
+	cards := []models.Card{
+		{Name: "SOR 10", Set: "SOR", Number: "10"},
+		{Name: "SHD 2", Set: "SHD", Number: "2"},
+		{Name: "SOR 9", Set: "SOR", Number: "9"},
+		{Name: "SOR T01", Set: "SOR", Number: "T01"},
+		{Name: "SOR 009 alt", Set: "SOR", Number: "009"},
+	}
+
+	pages := binder.Layout(cards)
+
+	require.Len(t, pages, 1)
+	assert.Equal(t, []string{"SHD 2", "SOR 009 alt", "SOR 9", "SOR 10", "SOR T01"}, names(pages[0].Cards))
+}
+
+func TestLayout_SplitsIntoNumberedPagesOfNine(t *testing.T) {
+	cards := make([]models.Card, 20)
+	for i := range cards {
+		cards[i] = models.Card{Name: "card", Set: "SOR", Number: string(rune('a' + i))}
+	}
+
+	pages := binder.Layout(cards)
+
+	require.Len(t, pages, 3)
+	assert.Equal(t, []int{1, 2, 3}, []int{pages[0].Number, pages[1].Number, pages[2].Number})
+	assert.Len(t, pages[0].Cards, binder.PocketsPerPage)
+	assert.Len(t, pages[1].Cards, binder.PocketsPerPage)
+	assert.Len(t, pages[2].Cards, 2)
+}
+
+func TestLayout_NoCards_ReturnsEmptySlice(t *testing.T) {
+	pages := binder.Layout(nil)
+
+	assert.NotNil(t, pages)
+	assert.Empty(t, pages)
+}
@@ -0,0 +1,51 @@
+package binder
+
+import (
+	"log/slog"
+	"net/http"
+
+	"swucol/database"
+	"swucol/templates"
+)
+
+// binderPage is the view model rendered by the binder template.
+type binderPage struct {
+	Pages []Page
+	Sets  []string
+	Set   string
+	Theme string
+}
+
+// PageHandler returns an http.HandlerFunc that serves the binder view at GET
+// /binder: every non-archived card, or with the optional "set" query
+// parameter those of one set, laid out in numbered nine-pocket pages. Cards
+// not owned are shown as empty pockets. Returns 500 Internal Server Error if
+// the database query or template rendering fails.
+func PageHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		set := request.URL.Query().Get("set")
+
+		cards, err := db.GetCardsBySet(set)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading binder cards", "set", set, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		sets, err := db.GetSetCodes()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading set codes", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		page := binderPage{Pages: Layout(cards), Sets: sets, Set: set, Theme: db.Settings().Theme}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "binder", page); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render binder template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package binder_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/binder"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/templates"
+)
+
+func TestPageHandler_RendersPagesForSet(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCards([]models.NewCard{
+		{Name: "Luke Skywalker, Jedi Knight", Set: "SOR", Number: "005"},
+		{Name: "Chewbacca, Walking Carpet", Set: "SHD", Number: "010"},
+	}))
+
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	binder.PageHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/binder?set=SOR", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, "Page 1")
+	assert.Contains(t, body, "SOR 005 · missing")
+	assert.NotContains(t, body, "SHD 010")
+	assert.Contains(t, body, `<option value="SHD" >SHD</option>`)
+}
@@ -0,0 +1,366 @@
+// Package buylist compares what the configured vendors pay for the cards on
+// the excess list, the copies owned beyond the wishlist minimums. Each
+// vendor's buylist is fetched as a whole and cached in the database, so
+// comparisons never wait on a vendor.
+package buylist
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"swucol/database"
+	"swucol/models"
+)
+
+// Vendor is a buylist source: a name shown to the user and the URL its
+// buylist is fetched from.
+type Vendor struct {
+	Name string
+	URL  string
+}
+
+// ParseVendors parses a comma-separated list of name=URL pairs, such as
+// "acme=https://acme.example/buylist.json". Surrounding spaces are removed.
+// Returns an error if an entry has no name, a name is repeated, or a URL is
+// not an absolute http or https URL. An empty list configures no vendors.
+func ParseVendors(list string) ([]Vendor, error) {
+	vendors := make([]Vendor, 0)
+	for entry := range strings.SplitSeq(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, rawURL, ok := strings.Cut(entry, "=")
+		name, rawURL = strings.TrimSpace(name), strings.TrimSpace(rawURL)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("buylist vendor %q must be name=URL", entry)
+		}
+
+		parsed, err := url.Parse(rawURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("buylist vendor %q URL is not an http or https URL", name)
+		}
+
+		if slices.ContainsFunc(vendors, func(vendor Vendor) bool { return vendor.Name == name }) {
+			return nil, fmt.Errorf("buylist vendor %q is listed twice", name)
+		}
+
+		vendors = append(vendors, Vendor{Name: name, URL: rawURL})
+	}
+
+	return vendors, nil
+}
+
+// buylistEntry is one card in a vendor's buylist JSON: the card and the price
+// paid for a copy in the vendor's currency.
+type buylistEntry struct {
+	Name   string  `json:"name"`
+	Set    string  `json:"set"`
+	Number string  `json:"number"`
+	Price  float64 `json:"price"`
+}
+
+// Fetch downloads the buylist of vendor, a JSON array of
+// {"name","set","number","price"} objects. Entries without a name or a
+// positive price are skipped; set and number are optional.
+func Fetch(ctx context.Context, httpClient *http.Client, vendor Vendor) ([]models.BuylistPrice, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, vendor.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build buylist request: %w", err)
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("fetch buylist: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch buylist: unexpected status %d", response.StatusCode)
+	}
+
+	var entries []buylistEntry
+	if err := json.NewDecoder(response.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("decode buylist: %w", err)
+	}
+
+	prices := make([]models.BuylistPrice, 0, len(entries))
+	for _, entry := range entries {
+		name := strings.TrimSpace(entry.Name)
+		cents := int(math.Round(entry.Price * 100))
+		if name == "" || cents <= 0 {
+			continue
+		}
+
+		prices = append(prices, models.BuylistPrice{
+			Vendor:     vendor.Name,
+			Name:       name,
+			Set:        strings.ToUpper(strings.TrimSpace(entry.Set)),
+			Number:     strings.TrimSpace(entry.Number),
+			PriceCents: cents,
+		})
+	}
+
+	return prices, nil
+}
+
+// Refresher fetches the configured vendors' buylists into the database cache.
+type Refresher struct {
+	db         *database.Database
+	httpClient *http.Client
+	vendors    []Vendor
+	maxAge     time.Duration
+
+	// mutex keeps refreshes from running concurrently and fetching every
+	// buylist twice.
+	mutex sync.Mutex
+}
+
+// NewRefresher returns a Refresher that fetches the buylists of vendors with
+// httpClient, treating cached buylists younger than maxAge as fresh.
+func NewRefresher(db *database.Database, httpClient *http.Client, vendors []Vendor, maxAge time.Duration) *Refresher {
+	return &Refresher{db: db, httpClient: httpClient, vendors: vendors, maxAge: maxAge}
+}
+
+// Vendors returns the configured vendors.
+func (refresher *Refresher) Vendors() []Vendor {
+	return refresher.vendors
+}
+
+// RefreshResult reports the refresh of one vendor's buylist. Refreshed is
+// false when the cached buylist was still fresh or the fetch failed, in which
+// case Error says why and the previous cache is kept.
+type RefreshResult struct {
+	Vendor    string    `json:"vendor"`
+	Refreshed bool      `json:"refreshed"`
+	Prices    int       `json:"prices"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Refresh fetches the buylist of every vendor whose cache is older than the
+// maximum age at now, or of every vendor when force is set. One vendor failing
+// does not stop the others. Returns an error only when the cache cannot be
+// read or written.
+func (refresher *Refresher) Refresh(ctx context.Context, now time.Time, force bool) ([]RefreshResult, error) {
+	refresher.mutex.Lock()
+	defer refresher.mutex.Unlock()
+
+	results := make([]RefreshResult, 0, len(refresher.vendors))
+	for _, vendor := range refresher.vendors {
+		result := RefreshResult{Vendor: vendor.Name}
+
+		fetchedAt, err := refresher.db.GetBuylistRefreshTime(vendor.Name)
+		if err != nil {
+			return nil, err
+		}
+		result.FetchedAt = fetchedAt
+
+		if !force && !fetchedAt.IsZero() && now.Sub(fetchedAt) < refresher.maxAge {
+			results = append(results, result)
+			continue
+		}
+
+		prices, err := Fetch(ctx, refresher.httpClient, vendor)
+		if err != nil {
+			slog.WarnContext(ctx, "buylist refresh failed", "vendor", vendor.Name, "error", err)
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		if err := refresher.db.ReplaceBuylistPrices(vendor.Name, prices, now); err != nil {
+			return nil, err
+		}
+
+		slog.InfoContext(ctx, "buylist refreshed", "vendor", vendor.Name, "prices", len(prices))
+
+		result.Refreshed, result.Prices, result.FetchedAt = true, len(prices), now
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// Offer is what one vendor pays for a copy of a card, in cents.
+type Offer struct {
+	Vendor     string `json:"vendor"`
+	PriceCents int    `json:"price_cents"`
+}
+
+// Price returns the offer formatted with two decimal places.
+func (offer Offer) Price() string {
+	return FormatCents(offer.PriceCents)
+}
+
+// CardOffers is an excess card with every vendor's offer for it, highest
+// first. Best is the highest offer; its Vendor is empty when no vendor buys
+// the card.
+type CardOffers struct {
+	CardID  int     `json:"card_id"`
+	Name    string  `json:"name"`
+	Set     string  `json:"set"`
+	Number  string  `json:"number"`
+	Surplus int     `json:"surplus"`
+	Offers  []Offer `json:"offers"`
+	Best    Offer   `json:"best"`
+}
+
+// VendorTotal is what one vendor pays for the whole excess list: the number
+// of cards it buys and the sum of its offers times each card's surplus.
+type VendorTotal struct {
+	Vendor     string `json:"vendor"`
+	Cards      int    `json:"cards"`
+	TotalCents int    `json:"total_cents"`
+}
+
+// Total returns the total formatted with two decimal places.
+func (total VendorTotal) Total() string {
+	return FormatCents(total.TotalCents)
+}
+
+// Comparison is the buylist comparison of the excess list. Totals are
+// ordered by total, highest first, so the first pays most for the whole list.
+// SplitTotalCents is the most the list fetches when each card goes to the
+// vendor paying most for it.
+type Comparison struct {
+	Cards           []CardOffers  `json:"cards"`
+	Totals          []VendorTotal `json:"totals"`
+	SplitTotalCents int           `json:"split_total_cents"`
+}
+
+// SplitTotal returns SplitTotalCents formatted with two decimal places.
+func (comparison Comparison) SplitTotal() string {
+	return FormatCents(comparison.SplitTotalCents)
+}
+
+// priceKey identifies a printing in a vendor's buylist.
+type priceKey struct {
+	set    string
+	number string
+}
+
+// PriceIndex indexes one price list, such as a vendor's buylist: entries
+// with a set and number by printing, and entries with a name only by
+// lower-cased name, keeping the highest price for a name listed more than
+// once.
+type PriceIndex struct {
+	byPrinting map[priceKey]int
+	byName     map[string]int
+}
+
+// NewPriceIndex indexes prices, which should all come from one source.
+func NewPriceIndex(prices []models.BuylistPrice) *PriceIndex {
+	index := &PriceIndex{byPrinting: map[priceKey]int{}, byName: map[string]int{}}
+	for _, price := range prices {
+		if price.Set != "" && price.Number != "" {
+			index.byPrinting[priceKey{strings.ToUpper(price.Set), price.Number}] = price.PriceCents
+			continue
+		}
+		name := strings.ToLower(price.Name)
+		index.byName[name] = max(index.byName[name], price.PriceCents)
+	}
+	return index
+}
+
+// Lookup returns the price of card: its printing's price when the list has
+// it, otherwise the price listed for the name alone.
+func (index *PriceIndex) Lookup(card models.Card) (int, bool) {
+	if card.Set != "" && card.Number != "" {
+		if cents, ok := index.byPrinting[priceKey{strings.ToUpper(card.Set), card.Number}]; ok {
+			return cents, true
+		}
+	}
+	cents, ok := index.byName[strings.ToLower(card.Name)]
+	return cents, ok
+}
+
+// Compare matches each of excess against every vendor's prices, by set and
+// number where both sides have them and otherwise by name, and totals the
+// offers. vendors sets which vendors are totalled, in order; prices of other
+// vendors are ignored. Cards are ordered by their best offer times surplus,
+// highest first.
+func Compare(excess []models.ExcessCard, prices []models.BuylistPrice, vendors []string) Comparison {
+	byVendor := make(map[string][]models.BuylistPrice, len(vendors))
+	for _, price := range prices {
+		byVendor[price.Vendor] = append(byVendor[price.Vendor], price)
+	}
+
+	indexes := make(map[string]*PriceIndex, len(vendors))
+	for _, vendor := range vendors {
+		indexes[vendor] = NewPriceIndex(byVendor[vendor])
+	}
+
+	comparison := Comparison{Cards: make([]CardOffers, 0, len(excess)), Totals: make([]VendorTotal, 0, len(vendors))}
+	totals := make(map[string]*VendorTotal, len(vendors))
+	for _, vendor := range vendors {
+		totals[vendor] = &VendorTotal{Vendor: vendor}
+	}
+
+	for _, card := range excess {
+		offers := CardOffers{
+			CardID:  card.ID,
+			Name:    card.Name,
+			Set:     card.Set,
+			Number:  card.Number,
+			Surplus: card.Surplus,
+			Offers:  []Offer{},
+		}
+
+		for _, vendor := range vendors {
+			cents, ok := indexes[vendor].Lookup(card.Card)
+			if !ok {
+				continue
+			}
+			offers.Offers = append(offers.Offers, Offer{Vendor: vendor, PriceCents: cents})
+			totals[vendor].Cards++
+			totals[vendor].TotalCents += cents * card.Surplus
+		}
+
+		slices.SortStableFunc(offers.Offers, func(a, b Offer) int { return cmp.Compare(b.PriceCents, a.PriceCents) })
+		if len(offers.Offers) > 0 {
+			offers.Best = offers.Offers[0]
+			comparison.SplitTotalCents += offers.Best.PriceCents * card.Surplus
+		}
+
+		comparison.Cards = append(comparison.Cards, offers)
+	}
+
+	slices.SortStableFunc(comparison.Cards, func(a, b CardOffers) int {
+		return cmp.Compare(b.Best.PriceCents*b.Surplus, a.Best.PriceCents*a.Surplus)
+	})
+
+	for _, vendor := range vendors {
+		comparison.Totals = append(comparison.Totals, *totals[vendor])
+	}
+	slices.SortStableFunc(comparison.Totals, func(a, b VendorTotal) int { return cmp.Compare(b.TotalCents, a.TotalCents) })
+
+	return comparison
+}
+
+// ExcessCards pairs each card with the Surplus of copies it has beyond the
+// minimum owned threshold in settings for its card type.
+func ExcessCards(cards []models.Card, settings models.Settings) []models.ExcessCard {
+	excess := make([]models.ExcessCard, 0, len(cards))
+	for _, card := range cards {
+		excess = append(excess, models.ExcessCard{Card: card, Surplus: card.Owned - database.MinimumOwned(settings, card.Type)})
+	}
+	return excess
+}
+
+// FormatCents formats cents with two decimal places, such as 1.05.
+func FormatCents(cents int) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
@@ -0,0 +1,109 @@
+package buylist_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/buylist"
+	"swucol/internal/testdb"
+	"swucol/models"
+)
+
+func TestParseVendors(t *testing.T) {
+	vendors, err := buylist.ParseVendors(" acme=https://acme.example/buylist.json, rebels = http://rebels.example/buy ")
+	require.NoError(t, err)
+	assert.Equal(t, []buylist.Vendor{
+		{Name: "acme", URL: "https://acme.example/buylist.json"},
+		{Name: "rebels", URL: "http://rebels.example/buy"},
+	}, vendors)
+
+	vendors, err = buylist.ParseVendors("")
+	require.NoError(t, err)
+	assert.Empty(t, vendors)
+
+	for _, list := range []string{"https://acme.example", "acme=ftp://acme.example", "a=https://a.example,a=https://b.example"} {
+		_, err := buylist.ParseVendors(list)
+		assert.Error(t, err, list)
+	}
+}
+
+func TestCompare_PicksBestVendorPerCardAndInTotal(t *testing.T) {
+	excess := []models.ExcessCard{
+		{Card: models.Card{ID: 1, Name: "Darth Vader, Dark Lord of the Sith", Set: "SOR", Number: "010"}, Surplus: 1},
+		{Card: models.Card{ID: 2, Name: "Battlefield Marine", Set: "SOR", Number: "095"}, Surplus: 4},
+		{Card: models.Card{ID: 3, Name: "Admiral Ackbar", Set: "SOR", Number: "200"}, Surplus: 2},
+	}
+	prices := []models.BuylistPrice{
+		{Vendor: "acme", Name: "Darth Vader, Dark Lord of the Sith", Set: "SOR", Number: "010", PriceCents: 900},
+		{Vendor: "acme", Name: "Battlefield Marine", Set: "SOR", Number: "095", PriceCents: 5},
+		{Vendor: "rebels", Name: "darth vader, dark lord of the sith", PriceCents: 1000},
+		{Vendor: "rebels", Name: "Battlefield Marine", Set: "SOR", Number: "999", PriceCents: 50},
+		{Vendor: "ignored", Name: "Admiral Ackbar", PriceCents: 100},
+	}
+
+	comparison := buylist.Compare(excess, prices, []string{"acme", "rebels"})
+
+	require.Len(t, comparison.Cards, 3)
+	assert.Equal(t, "Darth Vader, Dark Lord of the Sith", comparison.Cards[0].Name)
+	assert.Equal(t, buylist.Offer{Vendor: "rebels", PriceCents: 1000}, comparison.Cards[0].Best, "a name-only entry matches any printing")
+	assert.Equal(t, []buylist.Offer{{Vendor: "rebels", PriceCents: 1000}, {Vendor: "acme", PriceCents: 900}}, comparison.Cards[0].Offers)
+	assert.Equal(t, buylist.Offer{Vendor: "acme", PriceCents: 5}, comparison.Cards[1].Best, "another printing's price does not match")
+	assert.Empty(t, comparison.Cards[2].Offers, "unconfigured vendors are ignored")
+	assert.Empty(t, comparison.Cards[2].Best.Vendor)
+
+	assert.Equal(t, []buylist.VendorTotal{
+		{Vendor: "rebels", Cards: 1, TotalCents: 1000},
+		{Vendor: "acme", Cards: 2, TotalCents: 920},
+	}, comparison.Totals)
+	assert.Equal(t, 1020, comparison.SplitTotalCents)
+	assert.Equal(t, "10.20", comparison.SplitTotal())
+}
+
+func TestRefresher_CachesUntilMaxAge(t *testing.T) {
+	db := testdb.New(t)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		requests.Add(1)
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.Write([]byte(`[{"name": "Battlefield Marine", "set": "sor", "number": "095", "price": 0.25}, {"name": "", "price": 1}, {"name": "Free Card", "price": 0}]`))
+	}))
+	t.Cleanup(server.Close)
+
+	broken := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		http.Error(responseWriter, "down", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(broken.Close)
+
+	refresher := buylist.NewRefresher(db, server.Client(), []buylist.Vendor{{Name: "acme", URL: server.URL}, {Name: "broken", URL: broken.URL}}, time.Hour)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	results, err := refresher.Refresh(context.Background(), now, false)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Refreshed)
+	assert.Equal(t, 1, results[0].Prices)
+	assert.False(t, results[1].Refreshed)
+	assert.Contains(t, results[1].Error, "503")
+
+	prices, err := db.GetBuylistPrices()
+	require.NoError(t, err)
+	assert.Equal(t, []models.BuylistPrice{{Vendor: "acme", Name: "Battlefield Marine", Set: "SOR", Number: "095", PriceCents: 25}}, prices)
+
+	results, err = refresher.Refresh(context.Background(), now.Add(30*time.Minute), false)
+	require.NoError(t, err)
+	assert.False(t, results[0].Refreshed, "a fresh cache is not refetched")
+	assert.Equal(t, int32(1), requests.Load())
+
+	results, err = refresher.Refresh(context.Background(), now.Add(30*time.Minute), true)
+	require.NoError(t, err)
+	assert.True(t, results[0].Refreshed, "force refetches a fresh cache")
+	assert.Equal(t, int32(2), requests.Load())
+}
@@ -0,0 +1,121 @@
+package buylist
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"swucol/csrf"
+	"swucol/database"
+	"swucol/roles"
+	"swucol/templates"
+)
+
+// buylistPage is the view model rendered by the buylist template.
+type buylistPage struct {
+	Comparison Comparison
+	Vendors    []Vendor
+	Theme      string
+	Role       string
+	CSRFToken  string
+}
+
+// CompareHandler returns an http.HandlerFunc that handles GET /buylist.
+// Returns 200 OK with the JSON Comparison of the cached buylists for the
+// excess list, and 500 Internal Server Error for database errors. It never
+// fetches; POST /buylist/refresh does.
+func CompareHandler(db *database.Database, refresher *Refresher) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		comparison, ok := loadComparison(responseWriter, request, db, refresher, nil)
+		if !ok {
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(comparison); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode buylist comparison response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// RefreshHandler returns an http.HandlerFunc that handles POST
+// /buylist/refresh, fetching the buylists whose cache has expired, or every
+// buylist with ?force=true. Returns 200 OK with a JSON array of per-vendor
+// RefreshResults (a vendor that fails reports its error there), and 500
+// Internal Server Error when the cache cannot be read or written.
+func RefreshHandler(refresher *Refresher) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		force := request.URL.Query().Get("force") == "true"
+
+		results, err := refresher.Refresh(request.Context(), time.Now(), force)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error refreshing buylists", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(results); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode buylist refresh response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// PageHandler returns an http.HandlerFunc that serves the buylist comparison
+// page at GET /buylist/html. Returns 500 Internal Server Error if the
+// database query or template rendering fails.
+func PageHandler(db *database.Database, refresher *Refresher, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		comparison, ok := loadComparison(responseWriter, request, db, refresher, tmpl)
+		if !ok {
+			return
+		}
+
+		page := buylistPage{
+			Comparison: comparison,
+			Vendors:    refresher.Vendors(),
+			Theme:      db.Settings().Theme,
+			Role:       roles.FromContext(request.Context()),
+			CSRFToken:  csrf.Token(request.Context()),
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "buylist", page); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render buylist template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// loadComparison compares the cached buylists of the configured vendors for
+// the excess list. It responds with 500 Internal Server Error, rendered by
+// templates.RenderError with tmpl (nil for the JSON handler), and returns
+// false when a query fails.
+func loadComparison(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, refresher *Refresher, tmpl templates.Renderer) (Comparison, bool) {
+	cards, err := db.GetExcessCards()
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error loading excess cards", "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+		return Comparison{}, false
+	}
+
+	prices, err := db.GetBuylistPrices()
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error loading buylist prices", "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+		return Comparison{}, false
+	}
+
+	vendors := make([]string, len(refresher.Vendors()))
+	for i, vendor := range refresher.Vendors() {
+		vendors[i] = vendor.Name
+	}
+
+	return Compare(ExcessCards(cards, db.Settings()), prices, vendors), true
+}
@@ -0,0 +1,62 @@
+package buylist_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/buylist"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/roles"
+	"swucol/templates"
+)
+
+func TestCompareAndPageHandlers_UseCachedPricesForExcessCards(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCards([]models.NewCard{
+		{Name: "Battlefield Marine", Set: "SOR", Number: "095", Mainboard: true},
+		{Name: "Admiral Ackbar", Set: "SOR", Number: "200", Mainboard: true},
+	}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{"Battlefield Marine": 9, "Admiral Ackbar": 2}))
+	require.NoError(t, db.ReplaceBuylistPrices("acme", []models.BuylistPrice{
+		{Name: "Battlefield Marine", Set: "SOR", Number: "095", PriceCents: 25},
+		{Name: "Admiral Ackbar", PriceCents: 300},
+	}, time.Now()))
+
+	refresher := buylist.NewRefresher(db, http.DefaultClient, []buylist.Vendor{{Name: "acme", URL: "https://acme.example"}}, time.Hour)
+
+	recorder := httptest.NewRecorder()
+	buylist.CompareHandler(db, refresher)(recorder, httptest.NewRequest(http.MethodGet, "/buylist", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var comparison buylist.Comparison
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &comparison))
+	require.Len(t, comparison.Cards, 1, "only cards owned beyond the minimum are compared")
+	assert.Equal(t, "Battlefield Marine", comparison.Cards[0].Name)
+	assert.Equal(t, 3, comparison.Cards[0].Surplus)
+	assert.Equal(t, []buylist.VendorTotal{{Vendor: "acme", Cards: 1, TotalCents: 75}}, comparison.Totals)
+
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	pageRecorder := httptest.NewRecorder()
+	buylist.PageHandler(db, refresher, tmpl)(pageRecorder, httptest.NewRequest(http.MethodGet, "/buylist/html", nil))
+
+	require.Equal(t, http.StatusOK, pageRecorder.Code)
+	assert.Contains(t, pageRecorder.Body.String(), "acme: 0.25")
+	assert.Contains(t, pageRecorder.Body.String(), "<td>0.75</td>")
+
+	viewerRecorder := httptest.NewRecorder()
+	roles.Middleware(db, models.RoleViewer, buylist.PageHandler(db, refresher, tmpl)).ServeHTTP(viewerRecorder, httptest.NewRequest(http.MethodGet, "/buylist/html", nil))
+
+	require.Equal(t, http.StatusOK, viewerRecorder.Code)
+	assert.Contains(t, viewerRecorder.Body.String(), ".role-editor, .role-admin {")
+	assert.Contains(t, viewerRecorder.Body.String(), `class="refresh-btn role-editor"`)
+	assert.NotContains(t, pageRecorder.Body.String(), ".role-editor, .role-admin {")
+}
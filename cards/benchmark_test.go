@@ -0,0 +1,68 @@
+package cards_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"swucol/cards"
+	"swucol/database"
+	"swucol/images"
+	"swucol/internal/testdb"
+	"swucol/loadtest"
+)
+
+func BenchmarkImportCardsHandler(b *testing.B) {
+	const count = 1000
+	csv := loadtest.CSV(count)
+
+	// With an image on disk for every card, the import downloads nothing and
+	// the benchmark measures parsing and inserting.
+	imagesDir := b.TempDir()
+	seeded, _ := loadtest.Cards(count)
+	for _, card := range seeded {
+		path, err := images.FilePath(imagesDir, card.Set, card.Number)
+		require.NoError(b, err)
+		require.NoError(b, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(b, os.WriteFile(path, nil, 0o644))
+	}
+
+	for b.Loop() {
+		b.StopTimer()
+		db, err := database.New(filepath.Join(b.TempDir(), "bench.db"))
+		require.NoError(b, err)
+		require.NoError(b, db.RunMigrations())
+		handler := cards.ImportCardsHandler(db, cards.NewImportLock(), http.DefaultClient, imagesDir, []string{"http://127.0.0.1:0"})
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/cards/import", strings.NewReader(csv))
+		b.StartTimer()
+
+		handler(recorder, request)
+
+		b.StopTimer()
+		if recorder.Code != http.StatusOK {
+			b.Fatalf("import returned status %d: %s", recorder.Code, recorder.Body.String())
+		}
+		db.Shutdown()
+		b.StartTimer()
+	}
+}
+
+func BenchmarkSearchCardsHTMLHandler(b *testing.B) {
+	db := testdb.New(b)
+	require.NoError(b, loadtest.Seed(db, 5000))
+	handler := cards.SearchCardsHTMLHandler(db, newTestTemplates(b))
+
+	for b.Loop() {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodGet, "/cards/search/html?q=trooper+01", nil))
+		if recorder.Code != http.StatusOK {
+			b.Fatalf("search returned status %d", recorder.Code)
+		}
+	}
+}
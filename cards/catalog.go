@@ -0,0 +1,219 @@
+package cards
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"swucol/cardservice"
+	"swucol/database"
+	"swucol/models"
+)
+
+// maxCatalogResponse caps the size of a set listing read from the catalog.
+const maxCatalogResponse = 16 << 20
+
+// errSetNotInCatalog is returned by fetchCatalogSet when the catalog has no
+// cards for the requested set.
+var errSetNotInCatalog = errors.New("set not found in catalog")
+
+// catalogCard is a card as listed by the online catalog's set endpoint. The
+// catalog lists Cost, Power and HP as strings, empty when the card has none.
+type catalogCard struct {
+	Set         string   `json:"Set"`
+	Number      string   `json:"Number"`
+	Name        string   `json:"Name"`
+	Subtitle    string   `json:"Subtitle"`
+	Type        string   `json:"Type"`
+	Aspects     []string `json:"Aspects"`
+	Rarity      string   `json:"Rarity"`
+	VariantType string   `json:"VariantType"`
+	Artist      string   `json:"Artist"`
+	Cost        string   `json:"Cost"`
+	Power       string   `json:"Power"`
+	HP          string   `json:"HP"`
+	Traits      []string `json:"Traits"`
+	FrontText   string   `json:"FrontText"`
+}
+
+// catalogListing is the body returned by the catalog's set endpoint.
+type catalogListing struct {
+	Data []catalogCard `json:"data"`
+}
+
+// catalogCardToCSV converts a catalog card into the CSV row an export of the
+// same card would contain, so that it can go through the CSV import pipeline.
+func catalogCardToCSV(card catalogCard) models.CardCSV {
+	return models.CardCSV{
+		Set:         card.Set,
+		CardNumber:  card.Number,
+		CardName:    card.Name,
+		CardTitle:   card.Subtitle,
+		CardType:    card.Type,
+		Aspects:     strings.Join(card.Aspects, "|"),
+		VariantType: card.VariantType,
+		Rarity:      card.Rarity,
+		Artist:      card.Artist,
+	}
+}
+
+// catalogCardToAttributes returns the gameplay attributes of card. Numbers
+// the catalog leaves empty or that are not whole numbers, such as the "-"
+// some listings use, are left nil.
+func catalogCardToAttributes(card catalogCard) models.CardAttributes {
+	return models.CardAttributes{
+		Cost:   parseCatalogNumber(card.Cost),
+		Power:  parseCatalogNumber(card.Power),
+		HP:     parseCatalogNumber(card.HP),
+		Traits: strings.Join(card.Traits, "|"),
+		Text:   strings.TrimSpace(card.FrontText),
+	}
+}
+
+// parseCatalogNumber parses a numeric attribute of a catalog card, returning
+// nil when value is not a whole number.
+func parseCatalogNumber(value string) *int {
+	number, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return nil
+	}
+	return &number
+}
+
+// fetchCatalogSet downloads the listing of setCode from the catalog at
+// catalogBaseURL ({catalogBaseURL}/{set}, with the set code in lower case)
+// and returns its cards. Returns errSetNotInCatalog when the catalog does not
+// know the set or lists no cards for it.
+func fetchCatalogSet(ctx context.Context, client *http.Client, catalogBaseURL, setCode string) ([]catalogCard, error) {
+	target := strings.TrimRight(catalogBaseURL, "/") + "/" + url.PathEscape(strings.ToLower(setCode))
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("get set listing: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, errSetNotInCatalog
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	var listing catalogListing
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxCatalogResponse)).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("decode set listing: %w", err)
+	}
+
+	if len(listing.Data) == 0 {
+		return nil, errSetNotInCatalog
+	}
+
+	return listing.Data, nil
+}
+
+// catalogCardsToService converts the cards of a set listing into the
+// cardservice catalog cards ImportCatalog imports.
+func catalogCardsToService(catalogCards []catalogCard) []cardservice.CatalogCard {
+	converted := make([]cardservice.CatalogCard, len(catalogCards))
+	for i, card := range catalogCards {
+		converted[i] = cardservice.CatalogCard{CSV: catalogCardToCSV(card), Attributes: catalogCardToAttributes(card)}
+	}
+	return converted
+}
+
+// ImportSetHandler returns an http.HandlerFunc that handles POST
+// /cards/import/set/{setcode}. It fetches the full listing of the set from
+// the online catalog at catalogBaseURL and imports every card with an owned
+// count of 0, downloading images from imageBaseURLs into imagesDir as a CSV
+// import does, so that a new set can be added without an export. With the
+// optional "unreleased=true" or "release=YYYY-MM-DD" query parameters the set
+// is imported as a spoiler-season preview and marked unreleased, until the
+// release date when one is given; see database.MarkSetUnreleased. Returns
+// 200 OK with the same JSON summary as POST /cards/import, 400 Bad Request
+// for an invalid set code or release date, 404 Not Found when the catalog has no such set, 409
+// Conflict when another import holds lock, 502 Bad Gateway when the catalog
+// cannot be read, and 500 Internal Server Error for database errors.
+func ImportSetHandler(db *database.Database, lock *ImportLock, httpClient *http.Client, imagesDir string, imageBaseURLs []string, catalogBaseURL string) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		setCode := strings.ToUpper(strings.TrimSpace(request.PathValue("setcode")))
+		slog.InfoContext(request.Context(), "POST /cards/import/set/{setcode} received", "set", setCode)
+
+		if !cardservice.IsSetCode(setCode) {
+			http.Error(responseWriter, "setcode must be 2 to 5 letters or digits", http.StatusBadRequest)
+			return
+		}
+
+		releaseDate := strings.TrimSpace(request.URL.Query().Get("release"))
+		if releaseDate != "" {
+			if _, err := time.Parse(database.ReleaseDateLayout, releaseDate); err != nil {
+				http.Error(responseWriter, "release must be a date in YYYY-MM-DD form", http.StatusBadRequest)
+				return
+			}
+		}
+		unreleased := releaseDate != "" || request.URL.Query().Get("unreleased") == "true"
+
+		if !lock.tryAcquire("catalog", importModeInsert, false) {
+			slog.WarnContext(request.Context(), "import rejected, another import is in progress")
+			http.Error(responseWriter, errImportInProgress.message, errImportInProgress.statusCode)
+			return
+		}
+		var (
+			failure  *importError
+			imported *cardservice.ImportResult
+		)
+		defer func() { recordImport(request.Context(), db, lock.release(failure), setCode, nil, imported, nil) }()
+
+		catalogCards, err := fetchCatalogSet(request.Context(), httpClient, catalogBaseURL, setCode)
+		if err != nil {
+			if errors.Is(err, errSetNotInCatalog) {
+				failure = &importError{statusCode: http.StatusNotFound, message: "set " + setCode + " not found in catalog"}
+			} else {
+				slog.ErrorContext(request.Context(), "failed to fetch set from catalog", "set", setCode, "error", err)
+				failure = &importError{statusCode: http.StatusBadGateway, message: "failed to fetch set from catalog"}
+			}
+			http.Error(responseWriter, failure.message, failure.statusCode)
+			return
+		}
+
+		service := cardservice.NewImportService(db, httpClient, imagesDir, imageBaseURLs)
+		result, err := service.ImportCatalog(request.Context(), catalogCardsToService(catalogCards))
+		if err != nil {
+			failure = importErrorFrom(err)
+			slog.ErrorContext(request.Context(), "catalog import failed", "set", setCode, "status", failure.statusCode, "message", failure.message)
+			http.Error(responseWriter, failure.message, failure.statusCode)
+			return
+		}
+
+		if unreleased {
+			if err := db.MarkSetUnreleased(setCode, releaseDate); err != nil {
+				slog.ErrorContext(request.Context(), "database error marking set unreleased", "set", setCode, "error", err)
+				failure = &importError{statusCode: http.StatusInternalServerError, message: "database error"}
+				http.Error(responseWriter, failure.message, failure.statusCode)
+				return
+			}
+			slog.InfoContext(request.Context(), "set marked unreleased", "set", setCode, "release_date", releaseDate)
+		}
+		imported = result
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(result); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode import response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package cards_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/cards"
+	"swucol/database"
+	"swucol/internal/testdb"
+)
+
+// sorListing is a catalog set listing with a leader, a unit, a Hyperspace
+// printing of the unit, and a token the import does not support.
+const sorListing = `{"data": [
+	{"Set": "SOR", "Number": "005", "Name": "Luke Skywalker", "Subtitle": "Faithful Friend", "Type": "Leader", "Aspects": ["Vigilance", "Heroism"], "Rarity": "Common", "VariantType": "Normal"},
+	{"Set": "SOR", "Number": "190", "Name": "Cantina Braggart", "Type": "Unit", "Aspects": [], "Rarity": "Common", "VariantType": "Normal", "Cost": "1", "Power": "1", "HP": "2", "Traits": ["UNDERWORLD"], "FrontText": "Ambush"},
+	{"Set": "SOR", "Number": "442", "Name": "Cantina Braggart", "Type": "Unit", "Aspects": [], "Rarity": "Common", "VariantType": "Hyperspace"},
+	{"Set": "SOR", "Number": "T01", "Name": "Experience", "Type": "Token Upgrade", "Rarity": "Special", "VariantType": "Normal"}
+]}`
+
+// newCatalogServer serves sorListing at /cards/sor, 404 for other sets, and
+// fake image data under /images.
+func newCatalogServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cards/sor", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(sorListing))
+	})
+	mux.HandleFunc("GET /cards/{set}", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("GET /images/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fake-png-data"))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// postImportSet sends POST /cards/import/set/{setcode} to ImportSetHandler
+// using server as both the catalog and the image host.
+func postImportSet(t *testing.T, db *database.Database, server *httptest.Server, setCode string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/import/set/"+setCode, nil)
+	request.SetPathValue("setcode", setCode)
+	recorder := httptest.NewRecorder()
+
+	cards.ImportSetHandler(db, cards.NewImportLock(), server.Client(), t.TempDir(), []string{server.URL + "/images"}, server.URL+"/cards")(recorder, request)
+
+	return recorder
+}
+
+func TestImportSetHandler_ImportsEveryCardWithOwnedZero(t *testing.T) {
+	db := testdb.New(t)
+	server := newCatalogServer(t)
+
+	recorder := postImportSet(t, db, server, "sor")
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	var result struct {
+		Inserted         int `json:"inserted"`
+		SkippedDuplicate int `json:"skipped_duplicate"`
+		RowErrors        []struct {
+			Line int `json:"line"`
+		} `json:"row_errors"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.Equal(t, 2, result.Inserted)
+	assert.Equal(t, 1, result.SkippedDuplicate)
+	require.Len(t, result.RowErrors, 1)
+	assert.Equal(t, 4, result.RowErrors[0].Line)
+
+	allCards, err := db.GetAllCards()
+	require.NoError(t, err)
+	require.Len(t, allCards, 2)
+	for _, card := range allCards {
+		assert.Zero(t, card.Owned, card.Name)
+		if card.Name == "Luke Skywalker, Faithful Friend" {
+			assert.Equal(t, "Vigilance|Heroism", card.Aspects)
+			assert.False(t, card.Mainboard)
+		} else {
+			assert.Equal(t, "190", card.Number, "expected the normal printing's number")
+			require.NotNil(t, card.Cost)
+			assert.Equal(t, 1, *card.Cost)
+			require.NotNil(t, card.HP)
+			assert.Equal(t, 2, *card.HP)
+			assert.Equal(t, "UNDERWORLD", card.Traits)
+			assert.Equal(t, "Ambush", card.Text)
+		}
+	}
+}
+
+func TestImportSetHandler_ExistingCardsAreSkipped(t *testing.T) {
+	db := testdb.New(t)
+	server := newCatalogServer(t)
+
+	require.Equal(t, http.StatusOK, postImportSet(t, db, server, "SOR").Code)
+	recorder := postImportSet(t, db, server, "SOR")
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"inserted":0`)
+	assert.Contains(t, recorder.Body.String(), `"skipped_existing":2`)
+}
+
+func TestImportSetHandler_UnknownSet_Returns404(t *testing.T) {
+	db := testdb.New(t)
+	server := newCatalogServer(t)
+
+	recorder := postImportSet(t, db, server, "XYZ")
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestImportSetHandler_InvalidSetCode_Returns400(t *testing.T) {
+	db := testdb.New(t)
+	server := newCatalogServer(t)
+
+	recorder := postImportSet(t, db, server, "not-a-set")
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestImportSetHandler_CatalogUnavailable_Returns502(t *testing.T) {
+	db := testdb.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	recorder := postImportSet(t, db, server, "SOR")
+
+	assert.Equal(t, http.StatusBadGateway, recorder.Code)
+}
+
+func TestImportSetHandler_ReleaseDate_MarksSetUnreleased(t *testing.T) {
+	db := testdb.New(t)
+	server := newCatalogServer(t)
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/import/set/SOR?release=2999-01-01", nil)
+	request.SetPathValue("setcode", "SOR")
+	recorder := httptest.NewRecorder()
+	cards.ImportSetHandler(db, cards.NewImportLock(), server.Client(), t.TempDir(), []string{server.URL + "/images"}, server.URL+"/cards")(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	allCards, err := db.GetAllCards()
+	require.NoError(t, err)
+	require.Len(t, allCards, 2)
+	for _, card := range allCards {
+		assert.True(t, card.Unreleased, card.Name)
+	}
+}
+
+func TestImportSetHandler_InvalidReleaseDate_Returns400(t *testing.T) {
+	db := testdb.New(t)
+	server := newCatalogServer(t)
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/import/set/SOR?release=soon", nil)
+	request.SetPathValue("setcode", "SOR")
+	recorder := httptest.NewRecorder()
+	cards.ImportSetHandler(db, cards.NewImportLock(), server.Client(), t.TempDir(), []string{server.URL + "/images"}, server.URL+"/cards")(recorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -2,39 +2,41 @@
 package cards
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/csv"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"html/template"
 	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 
+	"swucol/cardservice"
+	"swucol/csrf"
 	"swucol/database"
+	"swucol/fallback"
+	"swucol/i18n"
 	"swucol/models"
+	"swucol/roles"
+	"swucol/search"
+	"swucol/snippet"
+	"swucol/templates"
 )
 
-// utf8BOM is the three-byte UTF-8 byte order mark prepended by some editors
-// and spreadsheet applications (e.g. Excel) when exporting CSV files.
-var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+// recentCardsLimit is the maximum number of cards returned by the recent
+// activity endpoints.
+const recentCardsLimit = 20
 
-// csvColumnCount is the expected number of columns in a valid card CSV.
-const csvColumnCount = 13
+// recentActivityLimit is the number of cards listed in each column of the
+// index page's recent activity section.
+const recentActivityLimit = 5
 
-// csvHeaderSet is the value expected in the first column of the header row.
-const csvHeaderSet = "Set"
-
-// imageDownloadInterval is the minimum duration between image downloads to
-// stay within the rate limit of 10 images per second.
-const imageDownloadInterval = 100 * time.Millisecond
+// recentCompletionsLimit is the number of cards listed in the wishlist page's
+// recently completed section.
+const recentCompletionsLimit = 10
 
 // importError wraps an error with an HTTP status code so callers can return
 // the correct error response without inspecting error strings.
@@ -48,257 +50,68 @@ func (e *importError) Error() string {
 	return e.message
 }
 
-// parseCardsCSV reads a CSV from reader and returns a slice of CardCSV records.
-// The first row must be the header row. Returns an error if the CSV is empty,
-// malformed, or has an unexpected number of columns. A UTF-8 BOM at the start
-// of the stream is silently stripped before parsing.
-func parseCardsCSV(reader io.Reader) ([]models.CardCSV, error) {
-	if reader == nil {
-		return nil, errors.New("reader must not be nil")
-	}
-
-	// Wrap in a buffered reader so we can peek ahead and strip any UTF-8 BOM
-	// that Excel and similar tools prepend to CSV exports.
-	buffered := bufio.NewReader(reader)
-	if peeked, err := buffered.Peek(len(utf8BOM)); err == nil && bytes.Equal(peeked, utf8BOM) {
-		buffered.Discard(len(utf8BOM))
-	}
-
-	csvReader := csv.NewReader(buffered)
-
-	header, err := csvReader.Read()
-	if err != nil {
-		return nil, fmt.Errorf("read CSV header: %w", err)
-	}
-
-	if len(header) != csvColumnCount || header[0] != csvHeaderSet {
-		return nil, errors.New("CSV header does not match expected format")
-	}
-
-	var cards []models.CardCSV
-	for {
-		record, err := csvReader.Read()
-		if errors.Is(err, io.EOF) {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("read CSV record: %w", err)
-		}
-
-		cards = append(cards, models.CardCSV{
-			Set:             record[0],
-			CardNumber:      record[1],
-			CardName:        record[2],
-			CardTitle:       record[3],
-			CardType:        record[4],
-			Aspects:         record[5],
-			VariantType:     record[6],
-			Rarity:          record[7],
-			Foil:            record[8],
-			Stamp:           record[9],
-			Artist:          record[10],
-			OwnedCount:      record[11],
-			GroupOwnedCount: record[12],
-		})
-	}
-
-	return cards, nil
-}
-
-// cardCSVToName converts a CardCSV record to the card name used in the database.
-// The name is formed by combining CardName and CardTitle with a comma-space
-// separator. If CardTitle is empty, only CardName is returned.
-func cardCSVToName(card models.CardCSV) string {
-	if strings.TrimSpace(card.CardTitle) == "" {
-		return card.CardName
+// cause classifies the failure by its status code for
+// metrics.ImportFailures.
+func (e *importError) cause() string {
+	switch e.statusCode {
+	case http.StatusBadRequest:
+		return "invalid"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusConflict:
+		return "confirm"
+	case http.StatusBadGateway:
+		return "upstream"
 	}
-	return card.CardName + ", " + card.CardTitle
+	return "internal"
 }
 
-// cardCSVToMainboard returns false if the card's type is "Leader" or "Base"
-// (case-insensitive), and true for all other card types. Leaders and Bases are
-// not part of the main deck in Star Wars: Unlimited.
-func cardCSVToMainboard(card models.CardCSV) bool {
-	cardType := strings.TrimSpace(card.CardType)
-	return !strings.EqualFold(cardType, "leader") && !strings.EqualFold(cardType, "base")
+// importErrorFrom converts err, returned by the cardservice package, into an
+// importError with the status code reporting it.
+func importErrorFrom(err error) *importError {
+	return &importError{statusCode: serviceStatusCode(err), message: err.Error()}
 }
 
-// buildImageURL constructs the remote image URL for a card using the given
-// base URL, set, and card number. Returns an error if any argument is empty.
-func buildImageURL(imageBaseURL, set, cardNumber string) (string, error) {
-	if imageBaseURL == "" {
-		return "", errors.New("image base URL must not be empty")
-	}
-	if set == "" {
-		return "", errors.New("set must not be empty")
-	}
-	if cardNumber == "" {
-		return "", errors.New("card number must not be empty")
+// serviceStatusCode returns the status code reporting err, returned by the
+// cardservice package.
+func serviceStatusCode(err error) int {
+	switch cardservice.KindOf(err) {
+	case cardservice.KindInvalid:
+		return http.StatusBadRequest
+	case cardservice.KindNotFound:
+		return http.StatusNotFound
+	case cardservice.KindConfirm:
+		return http.StatusConflict
+	case cardservice.KindUpstream:
+		return http.StatusBadGateway
 	}
-	return fmt.Sprintf("%s/%s/%s.png", imageBaseURL, set, cardNumber), nil
+	return http.StatusInternalServerError
 }
 
-// buildImageFilePath constructs the local file path where a card image is
-// saved, using the provided images directory, set, and card number.
-// Returns an error if any argument is empty.
-func buildImageFilePath(imagesDir, set, cardNumber string) (string, error) {
-	if imagesDir == "" {
-		return "", errors.New("images directory must not be empty")
-	}
-	if set == "" {
-		return "", errors.New("set must not be empty")
-	}
-	if cardNumber == "" {
-		return "", errors.New("card number must not be empty")
-	}
-	return filepath.Join(imagesDir, set+cardNumber+".png"), nil
+// writeServiceError responds with err, returned by the cardservice package,
+// and the status code reporting it.
+func writeServiceError(responseWriter http.ResponseWriter, err error) {
+	http.Error(responseWriter, err.Error(), serviceStatusCode(err))
 }
 
-// downloadCardImage downloads the image at imageURL and writes it to destPath.
-// The parent directory of destPath is created if it does not already exist.
-// Returns an error if the HTTP request fails, the server returns a non-200
-// status, or the file cannot be written.
-func downloadCardImage(httpClient *http.Client, imageURL, destPath string) error {
-	if httpClient == nil {
-		return errors.New("http client must not be nil")
-	}
-	if imageURL == "" {
-		return errors.New("image URL must not be empty")
-	}
-	if destPath == "" {
-		return errors.New("destination path must not be empty")
-	}
-
-	resp, err := httpClient.Get(imageURL)
-	if err != nil {
-		return fmt.Errorf("download image: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("image download returned status %d", resp.StatusCode)
-	}
-
-	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
-		return fmt.Errorf("create image directory: %w", err)
-	}
-
-	file, err := os.Create(destPath)
+// importCards runs an insert import of the CSV read from reader with options
+// through service.
+func importCards(ctx context.Context, service *cardservice.ImportService, reader io.Reader, options importOptions) (*cardservice.ImportResult, *importError) {
+	result, err := service.Import(ctx, reader, cardservice.ImportOptions{Lenient: options.lenient, UseOwnedCount: options.useOwnedCount})
 	if err != nil {
-		return fmt.Errorf("create image file: %w", err)
-	}
-	defer file.Close()
-
-	if _, err := io.Copy(file, resp.Body); err != nil {
-		return fmt.Errorf("write image file: %w", err)
+		return nil, importErrorFrom(err)
 	}
-
-	return nil
+	return result, nil
 }
 
-// importCards parses a CSV from reader, and inserts any cards not already in
-// the database. For each new card, it attempts to download the image from
-// imageBaseURL and save it to imagesDir. Downloads are rate-limited to 10 per
-// second. If a download fails, the card is inserted with an empty image. If
-// the image already exists on disk, the download is skipped. Cards that
-// already exist in the database or appear more than once in the CSV are
-// silently skipped. Returns an *importError with a status code of 400 for
-// invalid CSV input or 500 for unexpected database errors.
-func importCards(db *database.Database, httpClient *http.Client, imagesDir, imageBaseURL string, reader io.Reader) *importError {
-	csvCards, err := parseCardsCSV(reader)
+// syncOwnedCounts runs a sync import of the CSV read from reader through
+// service.
+func syncOwnedCounts(ctx context.Context, service *cardservice.ImportService, reader io.Reader, options importOptions) (*cardservice.SyncResult, *importError) {
+	result, err := service.Sync(ctx, reader, cardservice.SyncOptions{DryRun: options.dryRun, Confirmed: options.confirm})
 	if err != nil {
-		slog.Error("failed to parse CSV", "error", err)
-		return &importError{statusCode: http.StatusBadRequest, message: "invalid CSV: " + err.Error()}
-	}
-
-	if len(csvCards) == 0 {
-		slog.Warn("CSV parsed successfully but contains no card rows")
-		return &importError{statusCode: http.StatusBadRequest, message: "CSV contains no card rows"}
-	}
-
-	slog.Info("CSV parsed", "row_count", len(csvCards))
-
-	// Track names seen in this request to avoid duplicate inserts.
-	seen := make(map[string]bool, len(csvCards))
-
-	// Track how many images have been downloaded in this request so that
-	// the rate-limit sleep is applied correctly (only between downloads).
-	downloadCount := 0
-
-	insertedCount := 0
-	skippedDBCount := 0
-	skippedCSVCount := 0
-
-	for _, csvCard := range csvCards {
-		name := cardCSVToName(csvCard)
-
-		if seen[name] {
-			slog.Debug("skipping duplicate in CSV", "name", name)
-			skippedCSVCount++
-			continue
-		}
-		seen[name] = true
-
-		exists, err := db.CardExistsByName(name)
-		if err != nil {
-			slog.Error("database error checking card existence", "name", name, "error", err)
-			return &importError{statusCode: http.StatusInternalServerError, message: "database error"}
-		}
-
-		if exists {
-			slog.Debug("skipping card already in database", "name", name)
-			skippedDBCount++
-			continue
-		}
-
-		imagePath := ""
-
-		filePath, pathErr := buildImageFilePath(imagesDir, csvCard.Set, csvCard.CardNumber)
-		if pathErr == nil {
-			if _, statErr := os.Stat(filePath); os.IsNotExist(statErr) {
-				// Rate-limit: pause before every download after the first.
-				if downloadCount > 0 {
-					time.Sleep(imageDownloadInterval)
-				}
-
-				imageURL, urlErr := buildImageURL(imageBaseURL, csvCard.Set, csvCard.CardNumber)
-				if urlErr == nil {
-					slog.Info("downloading image", "name", name, "url", imageURL)
-					if dlErr := downloadCardImage(httpClient, imageURL, filePath); dlErr == nil {
-						slog.Info("image downloaded", "name", name, "path", filePath)
-						imagePath = filePath
-					} else {
-						slog.Warn("image download failed, inserting card without image", "name", name, "error", dlErr)
-					}
-				} else {
-					slog.Warn("could not build image URL", "name", name, "error", urlErr)
-				}
-
-				downloadCount++
-			} else if statErr == nil {
-				// Image already exists on disk; use its path directly.
-				slog.Debug("image already on disk", "name", name, "path", filePath)
-				imagePath = filePath
-			}
-		}
-
-		mainboard := cardCSVToMainboard(csvCard)
-
-		slog.Info("inserting card", "name", name, "image_path", imagePath, "mainboard", mainboard)
-		if err := db.InsertCard(name, imagePath, mainboard); err != nil {
-			slog.Error("database error inserting card", "name", name, "error", err)
-			return &importError{statusCode: http.StatusInternalServerError, message: "database error"}
-		}
-		insertedCount++
+		return nil, importErrorFrom(err)
 	}
-
-	slog.Info("import complete",
-		"inserted", insertedCount,
-		"skipped_already_in_db", skippedDBCount,
-		"skipped_duplicate_in_csv", skippedCSVCount,
-	)
-
-	return nil
+	return result, nil
 }
 
 // GetCardHandler returns an http.HandlerFunc that retrieves a single card by its
@@ -325,14 +138,14 @@ func GetCardHandler(db *database.Database) http.HandlerFunc {
 			return
 		}
 		if err != nil {
-			slog.Error("database error fetching card", "id", id, "error", err)
+			slog.ErrorContext(request.Context(), "database error fetching card", "id", id, "error", err)
 			http.Error(responseWriter, "database error", http.StatusInternalServerError)
 			return
 		}
 
 		responseWriter.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(responseWriter).Encode(card); err != nil {
-			slog.Error("failed to encode card response", "id", id, "error", err)
+			slog.ErrorContext(request.Context(), "failed to encode card response", "id", id, "error", err)
 			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
 			return
 		}
@@ -358,12 +171,8 @@ func IncrementCardOwnedHandler(db *database.Database) http.HandlerFunc {
 			return
 		}
 
-		if err := db.IncrementCardOwned(id); errors.Is(err, database.ErrCardNotFound) {
-			http.Error(responseWriter, "card not found", http.StatusNotFound)
-			return
-		} else if err != nil {
-			slog.Error("database error incrementing owned count", "id", id, "error", err)
-			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+		if _, err := cardservice.NewCollectionService(db).IncrementOwned(request.Context(), id); err != nil {
+			writeServiceError(responseWriter, err)
 			return
 		}
 
@@ -373,9 +182,12 @@ func IncrementCardOwnedHandler(db *database.Database) http.HandlerFunc {
 
 // DecrementCardOwnedHandler returns an http.HandlerFunc that decrements the
 // owned count by 1 for the card identified by the id path parameter, clamping
-// at 0 so it never goes negative. Returns 204 No Content on success, 400 Bad
-// Request for a missing or non-positive-integer id, 404 Not Found when no card
-// with that id exists, and 500 Internal Server Error for database errors.
+// at 0 so it never goes negative. A decrement below the decrement floor
+// setting needs the confirm=true query parameter. Returns 204 No Content on
+// success, 400 Bad Request for a missing or non-positive-integer id or an
+// invalid confirm, 404 Not Found when no card with that id exists, 409
+// Conflict when the decrement needs confirming, and 500 Internal Server Error
+// for database errors.
 func DecrementCardOwnedHandler(db *database.Database) http.HandlerFunc {
 	return func(responseWriter http.ResponseWriter, request *http.Request) {
 		rawID := request.PathValue("id")
@@ -390,12 +202,14 @@ func DecrementCardOwnedHandler(db *database.Database) http.HandlerFunc {
 			return
 		}
 
-		if err := db.DecrementCardOwned(id); errors.Is(err, database.ErrCardNotFound) {
-			http.Error(responseWriter, "card not found", http.StatusNotFound)
+		confirmed, ok := parseConfirm(request.URL.Query().Get("confirm"))
+		if !ok {
+			http.Error(responseWriter, "confirm must be a boolean", http.StatusBadRequest)
 			return
-		} else if err != nil {
-			slog.Error("database error decrementing owned count", "id", id, "error", err)
-			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+		}
+
+		if _, err := cardservice.NewCollectionService(db).DecrementOwned(request.Context(), id, confirmed); err != nil {
+			writeServiceError(responseWriter, err)
 			return
 		}
 
@@ -403,307 +217,1708 @@ func DecrementCardOwnedHandler(db *database.Database) http.HandlerFunc {
 	}
 }
 
+// parseConfirm parses the raw confirm parameter of a decrement. An empty
+// value means false.
+func parseConfirm(raw string) (bool, bool) {
+	if raw == "" {
+		return false, true
+	}
+	confirmed, err := strconv.ParseBool(raw)
+	return confirmed, err == nil
+}
+
+// parseCardSort validates the raw sort parameter. An empty value means no
+// particular order.
+func parseCardSort(raw string) (database.CardSort, bool) {
+	switch cardSort := database.CardSort(strings.ToLower(strings.TrimSpace(raw))); cardSort {
+	case "", database.SortByName, database.SortByOwned, database.SortByRecent, database.SortBySetNumber:
+		return cardSort, true
+	default:
+		return "", false
+	}
+}
+
 // SearchCardsHandler returns an http.HandlerFunc that handles GET /cards/search.
-// It reads the optional "q" query parameter and returns a JSON array of cards
-// whose names contain the query as a case-insensitive substring. If "q" is
-// absent or empty, all cards are returned. Always returns 200 OK with a JSON
-// array (empty array when there are no results), or 500 Internal Server Error
-// for database errors.
+// It reads the optional "q" query parameter, in the syntax of the search
+// package (plain words match card names and aliases case-insensitively), and
+// returns a JSON array of matching cards. If "q" is absent or empty, all cards
+// are returned. The optional "sort" parameter (name, owned, recent or
+// set_number) orders the results. Returns 200 OK with a JSON array (empty
+// array when there are no results), 400 Bad Request for a query that cannot
+// be parsed or an unknown sort, and 500 Internal Server Error for database
+// errors.
 func SearchCardsHandler(db *database.Database) http.HandlerFunc {
 	return func(responseWriter http.ResponseWriter, request *http.Request) {
 		query := request.URL.Query().Get("q")
+		if !validateSearchQuery(responseWriter, request, nil, query) {
+			return
+		}
+
+		cardSort, ok := parseCardSort(request.URL.Query().Get("sort"))
+		if !ok {
+			http.Error(responseWriter, "sort must be name, owned, recent or set_number", http.StatusBadRequest)
+			return
+		}
 
-		matchedCards, err := db.SearchCards(query)
+		var matchedCards []models.Card
+		var err error
+		if cardSort == "" {
+			matchedCards, err = db.SearchCards(query)
+		} else {
+			matchedCards, err = db.SearchCardsSorted(query, cardSort)
+		}
 		if err != nil {
-			slog.Error("database error searching cards", "query", query, "error", err)
+			slog.ErrorContext(request.Context(), "database error searching cards", "query", query, "error", err)
 			http.Error(responseWriter, "database error", http.StatusInternalServerError)
 			return
 		}
 
 		responseWriter.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(responseWriter).Encode(matchedCards); err != nil {
-			slog.Error("failed to encode search response", "query", query, "error", err)
+			slog.ErrorContext(request.Context(), "failed to encode search response", "query", query, "error", err)
 			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
 			return
 		}
 	}
 }
 
-// ImportCardsHandler returns an http.HandlerFunc that accepts a raw CSV body,
-// parses it, and inserts any cards that do not already exist in the database.
-// For each new card, the handler downloads its image from imageBaseURL and
-// saves it to imagesDir/{Set}{CardNumber}.png. Downloads are rate-limited to
-// 10 per second. If a download fails, the card is still inserted with an empty
-// Image field. If an image file already exists on disk, the download is
-// skipped. Cards that already exist (matched by name) are silently skipped.
-// Cards that appear more than once in the same CSV are only inserted once.
-// Returns 204 No Content on success, 400 Bad Request for invalid CSV, and
-// 500 Internal Server Error for unexpected database errors.
-func ImportCardsHandler(db *database.Database, httpClient *http.Client, imagesDir, imageBaseURL string) http.HandlerFunc {
-	return func(responseWriter http.ResponseWriter, request *http.Request) {
-		slog.Info("POST /cards/import received")
-
-		if impErr := importCards(db, httpClient, imagesDir, imageBaseURL, request.Body); impErr != nil {
-			slog.Error("import failed", "status", impErr.statusCode, "message", impErr.message)
-			http.Error(responseWriter, impErr.message, impErr.statusCode)
-			return
-		}
-
-		responseWriter.WriteHeader(http.StatusNoContent)
+// validateSearchQuery parses query in the syntax of the search package. It
+// responds with 400 Bad Request describing the problem, rendered by
+// templates.RenderError with tmpl (nil for the JSON handlers), and returns
+// false when the query cannot be parsed.
+func validateSearchQuery(responseWriter http.ResponseWriter, request *http.Request, tmpl templates.Renderer, query string) bool {
+	if _, err := search.Parse(query); err != nil {
+		templates.RenderError(responseWriter, request, tmpl, err.Error(), http.StatusBadRequest)
+		return false
 	}
+	return true
 }
 
-// IndexHandler returns an http.HandlerFunc that serves the full index page at
-// GET /. It loads all cards from the database and renders the index template.
-// Returns 500 Internal Server Error if the database query or template
-// rendering fails.
-func IndexHandler(db *database.Database, tmpl *template.Template) http.HandlerFunc {
+// bulkResult reports how many cards a bulk update changed.
+type bulkResult struct {
+	Updated int `json:"updated"`
+}
+
+// BulkUpdateCardsHandler returns an http.HandlerFunc that handles POST
+// /cards/bulk. It reads the search filter "q", as accepted by GET
+// /cards/search, and an "action" of "set_mainboard" (with "value" true or
+// false) or "archive" from the query string or a form body, and applies the
+// action to every matching card at once. Returns 200 OK with the number of
+// cards changed as JSON, 400 Bad Request for an unknown action or value or a
+// query that cannot be parsed, and 500 Internal Server Error for database
+// errors.
+func BulkUpdateCardsHandler(db *database.Database) http.HandlerFunc {
 	return func(responseWriter http.ResponseWriter, request *http.Request) {
-		slog.Info("GET / received")
+		query := request.FormValue("q")
+		action := database.BulkAction(request.FormValue("action"))
+
+		var mainboard bool
+		if action == database.BulkSetMainboard {
+			parsed, err := strconv.ParseBool(request.FormValue("value"))
+			if err != nil {
+				http.Error(responseWriter, "value must be true or false", http.StatusBadRequest)
+				return
+			}
+			mainboard = parsed
+		}
+
+		slog.InfoContext(request.Context(), "POST /cards/bulk received", "query", query, "action", action)
 
-		allCards, err := db.SearchCards("")
+		updated, err := cardservice.NewCollectionService(db).BulkUpdate(request.Context(), query, action, mainboard)
 		if err != nil {
-			slog.Error("database error loading cards for index", "error", err)
-			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			writeServiceError(responseWriter, err)
 			return
 		}
 
-		slog.Info("rendering index page", "card_count", len(allCards))
-
-		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := tmpl.ExecuteTemplate(responseWriter, "index", allCards); err != nil {
-			slog.Error("failed to render index template", "error", err)
-			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(bulkResult{Updated: updated}); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode bulk update response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
 			return
 		}
 	}
 }
 
-// SearchCardsHTMLHandler returns an http.HandlerFunc that handles
-// GET /cards/search/html. It reads the optional "q" query parameter and
-// renders the card grid partial template with matching cards. Used by htmx
-// for live search updates. Returns 200 OK with HTML on success and 500
-// Internal Server Error for database or template errors.
-func SearchCardsHTMLHandler(db *database.Database, tmpl *template.Template) http.HandlerFunc {
-	return func(responseWriter http.ResponseWriter, request *http.Request) {
-		query := request.URL.Query().Get("q")
-
-		matchedCards, err := db.SearchCards(query)
-		if err != nil {
-			slog.Error("database error searching cards for HTML response", "query", query, "error", err)
-			http.Error(responseWriter, "database error", http.StatusInternalServerError)
-			return
-		}
+// ownedSyncRequest is the JSON body accepted by ReplayOwnedHandler.
+type ownedSyncRequest struct {
+	Operations []models.OwnedOperation `json:"operations"`
+}
 
-		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := tmpl.ExecuteTemplate(responseWriter, "cards", matchedCards); err != nil {
-			slog.Error("failed to render cards template", "query", query, "error", err)
-			http.Error(responseWriter, "template error", http.StatusInternalServerError)
-			return
-		}
-	}
+// ownedSyncResponse is the JSON body served by ReplayOwnedHandler.
+type ownedSyncResponse struct {
+	Results []models.OwnedOperationResult `json:"results"`
 }
 
-// ImportCardsHTMLHandler returns an http.HandlerFunc that accepts a
-// multipart/form-data POST with a "file" field containing a CSV. It delegates
-// to the shared importCards helper and, on success, responds with 200 OK and
-// sets the HX-Trigger response header to "cardsImported" so htmx-listening
-// elements can react. On failure it returns a human-readable error string for
-// display in the UI.
-func ImportCardsHTMLHandler(db *database.Database, httpClient *http.Client, imagesDir, imageBaseURL string) http.HandlerFunc {
+// ReplayOwnedHandler returns an http.HandlerFunc that handles POST
+// /cards/owned/sync, which the pages call with the increments and decrements
+// queued while offline once the connection returns. The JSON body's
+// operations are applied by CollectionService.ReplayOwned, merging them onto
+// the current owned counts; those the owned count protection settings guard
+// are reported as rejected unless the "confirm" query parameter is true.
+// Returns 200 OK with each operation's status and the card's owned count, 400
+// Bad Request for an invalid body, confirm value or too many operations, and
+// 500 Internal Server Error for database errors.
+func ReplayOwnedHandler(db *database.Database) http.HandlerFunc {
 	return func(responseWriter http.ResponseWriter, request *http.Request) {
-		slog.Info("POST /cards/import/html received")
-
-		if err := request.ParseMultipartForm(10 << 20); err != nil {
-			slog.Error("failed to parse multipart form", "error", err)
-			http.Error(responseWriter, "invalid form data", http.StatusBadRequest)
+		confirmed, ok := parseConfirm(request.URL.Query().Get("confirm"))
+		if !ok {
+			http.Error(responseWriter, "confirm must be a boolean", http.StatusBadRequest)
 			return
 		}
 
-		file, fileHeader, err := request.FormFile("file")
-		if err != nil {
-			slog.Error("file field missing from import form", "error", err)
-			http.Error(responseWriter, "file field is required", http.StatusBadRequest)
+		var payload ownedSyncRequest
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
 			return
 		}
-		defer file.Close()
 
-		slog.Info("import file received", "filename", fileHeader.Filename, "size_bytes", fileHeader.Size)
+		slog.InfoContext(request.Context(), "POST /cards/owned/sync received", "operations", len(payload.Operations))
 
-		if impErr := importCards(db, httpClient, imagesDir, imageBaseURL, file); impErr != nil {
-			slog.Error("import failed", "status", impErr.statusCode, "message", impErr.message)
-			http.Error(responseWriter, impErr.message, impErr.statusCode)
+		results, err := cardservice.NewCollectionService(db).ReplayOwned(request.Context(), payload.Operations, confirmed)
+		if err != nil {
+			writeServiceError(responseWriter, err)
 			return
 		}
 
-		slog.Info("import succeeded, triggering cardsImported event")
-		responseWriter.Header().Set("HX-Trigger", "cardsImported")
-		responseWriter.WriteHeader(http.StatusOK)
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(ownedSyncResponse{Results: results}); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode owned sync response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
 	}
 }
 
-// IncrementCardOwnedHTMLHandler returns an http.HandlerFunc that increments
-// the owned count by 1 for the card identified by the id path parameter and
-// returns the updated owned-row fragment as HTML. Used by htmx for inline
-// owned count updates. Returns 400 Bad Request for invalid id, 404 Not Found
-// when no card exists, and 500 Internal Server Error for database or template
-// errors.
-func IncrementCardOwnedHTMLHandler(db *database.Database, tmpl *template.Template) http.HandlerFunc {
-	return func(responseWriter http.ResponseWriter, request *http.Request) {
-		rawID := request.PathValue("id")
-		if rawID == "" {
-			http.Error(responseWriter, "id path parameter is required", http.StatusBadRequest)
-			return
-		}
+// importMode selects how the import handlers treat the uploaded CSV.
+type importMode string
 
-		id, err := strconv.Atoi(rawID)
-		if err != nil || id <= 0 {
-			http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
-			return
-		}
+const (
+	// importModeInsert inserts cards that are not yet in the database and
+	// leaves existing cards' owned counts untouched.
+	importModeInsert importMode = "insert"
 
-		slog.Info("incrementing owned count", "card_id", id)
+	// importModeSync overwrites the owned counts of existing cards with the
+	// CSV's Owned Count column.
+	importModeSync importMode = "sync"
+)
 
-		if err := db.IncrementCardOwned(id); errors.Is(err, database.ErrCardNotFound) {
-			http.Error(responseWriter, "card not found", http.StatusNotFound)
-			return
-		} else if err != nil {
-			slog.Error("database error incrementing owned count", "card_id", id, "error", err)
-			http.Error(responseWriter, "database error", http.StatusInternalServerError)
-			return
-		}
+// importOptions are the options accepted by both import handlers.
+type importOptions struct {
+	mode   importMode
+	dryRun bool
+	// confirm applies sync changes that the owned count protection settings
+	// would otherwise refuse.
+	confirm bool
+	// lenient skips malformed rows and reports them in the import result
+	// instead of rejecting the whole file.
+	lenient bool
+	// useOwnedCount sets the owned count of newly inserted cards from the
+	// CSV's Owned Count instead of 0.
+	useOwnedCount bool
+}
 
-		card, err := db.GetCardByID(id)
+// parseImportOptions reads the import options using value, which returns the
+// raw value of a named query parameter or form field. An empty mode defaults
+// to importModeInsert and empty flags to their value in defaults; defaults
+// only applies in insert mode. Returns an error message suitable for a 400
+// response when a value is invalid, dry_run or confirm is requested outside
+// sync mode, or lenient or use_owned_count is requested outside insert mode.
+func parseImportOptions(value func(string) string, defaults importOptions) (importOptions, string) {
+	var options importOptions
+	switch value("mode") {
+	case "", string(importModeInsert):
+		options = importOptions{mode: importModeInsert, lenient: defaults.lenient, useOwnedCount: defaults.useOwnedCount}
+	case string(importModeSync):
+		options.mode = importModeSync
+	default:
+		return importOptions{}, "mode must be one of: insert, sync"
+	}
+
+	flags := []struct {
+		name   string
+		target *bool
+	}{
+		{name: "dry_run", target: &options.dryRun},
+		{name: "confirm", target: &options.confirm},
+		{name: "lenient", target: &options.lenient},
+		{name: "use_owned_count", target: &options.useOwnedCount},
+	}
+	for _, flag := range flags {
+		raw := value(flag.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(raw)
 		if err != nil {
-			slog.Error("database error fetching card after increment", "card_id", id, "error", err)
-			http.Error(responseWriter, "database error", http.StatusInternalServerError)
-			return
+			return importOptions{}, flag.name + " must be a boolean"
 		}
+		*flag.target = parsed
+	}
 
-		slog.Info("owned count incremented", "card_id", id, "owned", card.Owned)
+	if options.dryRun && options.mode != importModeSync {
+		return importOptions{}, "dry_run is only supported with mode=sync"
+	}
 
-		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := tmpl.ExecuteTemplate(responseWriter, "card-owned-fragment", card); err != nil {
-			slog.Error("failed to render card-owned-fragment template", "card_id", id, "error", err)
-			http.Error(responseWriter, "template error", http.StatusInternalServerError)
-			return
-		}
+	if options.confirm && options.mode != importModeSync {
+		return importOptions{}, "confirm is only supported with mode=sync"
 	}
-}
 
-// computeWishlistCards converts a slice of Card records into WishlistCard records
-// by computing the Deficit for each card. The deficit is the number of additional
-// copies needed to reach the minimum threshold: database.MainboardMinimumOwned for
-// mainboard cards and database.NonMainboardMinimumOwned for non-mainboard cards.
-func computeWishlistCards(cardSlice []models.Card) []models.WishlistCard {
-	wishlist := make([]models.WishlistCard, 0, len(cardSlice))
-	for _, card := range cardSlice {
-		minimum := database.NonMainboardMinimumOwned
-		if card.Mainboard {
-			minimum = database.MainboardMinimumOwned
-		}
-		wishlist = append(wishlist, models.WishlistCard{
-			Card:    card,
-			Deficit: minimum - card.Owned,
-		})
+	if options.lenient && options.mode != importModeInsert {
+		return importOptions{}, "lenient is only supported with mode=insert"
 	}
-	return wishlist
+
+	if options.useOwnedCount && options.mode != importModeInsert {
+		return importOptions{}, "use_owned_count is only supported with mode=insert"
+	}
+
+	return options, ""
 }
 
-// WishlistHandler returns an http.HandlerFunc that serves the wishlist page at
-// GET /wishlist. It loads all cards below their minimum owned threshold from the
-// database and renders the wishlist template. Returns 500 Internal Server Error
-// if the database query or template rendering fails.
-func WishlistHandler(db *database.Database, tmpl *template.Template) http.HandlerFunc {
+// ImportCardsHandler returns an http.HandlerFunc that accepts a raw CSV body,
+// parses it, and inserts any cards that do not already exist in the database.
+// For each new card, the handler downloads its image from imageBaseURLs and
+// saves it to imagesDir/{Set}{CardNumber}.png. Downloads are rate-limited to
+// 10 per second. If a download fails, the card is still inserted with an empty
+// Image field. If an image file already exists on disk, the download is
+// skipped. Cards that already exist (matched by name) are silently skipped.
+// Cards that appear more than once in the same CSV are only inserted once.
+// Returns 200 OK with a JSON summary of the cards inserted and skipped and the
+// names of cards whose image download failed, 400 Bad Request for invalid CSV, 409
+// Conflict when another import holds lock, and 500 Internal Server Error for
+// unexpected database errors.
+//
+// With the "mode=sync" query parameter, no cards are inserted; instead the
+// owned counts of existing cards are overwritten with the CSV's Owned Count in
+// a single transaction, and 200 OK is returned with a JSON list of the changes.
+// Adding "dry_run=true" previews the changes without writing them.
+//
+// With "lenient=true", malformed rows are skipped instead of failing the
+// import with 400 Bad Request, and are listed with their line numbers in the
+// summary's row_errors. With "use_owned_count=true", newly inserted cards get
+// the CSV's Owned Count instead of 0. When either parameter is omitted from an
+// insert, the import default from the settings applies.
+func ImportCardsHandler(db *database.Database, lock *ImportLock, httpClient *http.Client, imagesDir string, imageBaseURLs []string) http.HandlerFunc {
 	return func(responseWriter http.ResponseWriter, request *http.Request) {
-		slog.Info("GET /wishlist received")
+		slog.InfoContext(request.Context(), "POST /cards/import received")
 
-		wishlistCards, err := db.GetWishlistCards("")
-		if err != nil {
-			slog.Error("database error loading wishlist cards", "error", err)
-			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+		settings := db.Settings()
+		defaults := importOptions{lenient: settings.ImportLenient, useOwnedCount: settings.ImportUseOwnedCount}
+		options, message := parseImportOptions(request.URL.Query().Get, defaults)
+		if message != "" {
+			http.Error(responseWriter, message, http.StatusBadRequest)
 			return
 		}
 
-		slog.Info("rendering wishlist page", "card_count", len(wishlistCards))
-
-		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := tmpl.ExecuteTemplate(responseWriter, "wishlist", computeWishlistCards(wishlistCards)); err != nil {
-			slog.Error("failed to render wishlist template", "error", err)
-			http.Error(responseWriter, "template error", http.StatusInternalServerError)
-			return
-		}
+		serveJSONImport(responseWriter, request, db, lock, httpClient, imagesDir, imageBaseURLs, "api", "", request.Body, options, settings.ImportKeepFiles)
 	}
 }
 
-// SearchWishlistHTMLHandler returns an http.HandlerFunc that handles
-// GET /wishlist/search/html. It reads the optional "q" query parameter and
-// renders the wishlist card grid partial template with matching wishlist cards.
-// Used by htmx for live search updates. Returns 200 OK with HTML on success
-// and 500 Internal Server Error for database or template errors.
-func SearchWishlistHTMLHandler(db *database.Database, tmpl *template.Template) http.HandlerFunc {
-	return func(responseWriter http.ResponseWriter, request *http.Request) {
-		query := request.URL.Query().Get("q")
+// serveJSONImport runs an import of the CSV read from reader with options
+// under lock and responds with its JSON result, as described for
+// ImportCardsHandler. The import is recorded in the history with source and
+// fileName, together with the file itself when keep is true.
+func serveJSONImport(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, lock *ImportLock, httpClient *http.Client, imagesDir string, imageBaseURLs []string, source, fileName string, reader io.Reader, options importOptions, keep bool) {
+	if !lock.tryAcquire(source, options.mode, options.dryRun) {
+		slog.WarnContext(request.Context(), "import rejected, another import is in progress")
+		http.Error(responseWriter, errImportInProgress.message, errImportInProgress.statusCode)
+		return
+	}
+	var (
+		failure  *importError
+		kept     []byte
+		imported *cardservice.ImportResult
+		synced   *cardservice.SyncResult
+	)
+	defer func() { recordImport(request.Context(), db, lock.release(failure), fileName, kept, imported, synced) }()
 
-		wishlistCards, err := db.GetWishlistCards(query)
-		if err != nil {
-			slog.Error("database error searching wishlist cards for HTML response", "query", query, "error", err)
-			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+	reader, kept, failure = keepImportFile(reader, keep)
+	if failure != nil {
+		http.Error(responseWriter, failure.message, failure.statusCode)
+		return
+	}
+
+	service := cardservice.NewImportService(db, httpClient, imagesDir, imageBaseURLs)
+
+	if options.mode == importModeSync {
+		result, syncErr := syncOwnedCounts(request.Context(), service, reader, options)
+		if syncErr != nil {
+			failure = syncErr
+			slog.ErrorContext(request.Context(), "sync failed", "status", syncErr.statusCode, "message", syncErr.message)
+			http.Error(responseWriter, syncErr.message, syncErr.statusCode)
 			return
 		}
+		synced = result
 
-		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := tmpl.ExecuteTemplate(responseWriter, "wishlist-cards", computeWishlistCards(wishlistCards)); err != nil {
-			slog.Error("failed to render wishlist-cards template", "query", query, "error", err)
-			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(result); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode sync response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
 			return
 		}
+		return
+	}
+
+	result, impErr := importCards(request.Context(), service, reader, options)
+	if impErr != nil {
+		failure = impErr
+		slog.ErrorContext(request.Context(), "import failed", "status", impErr.statusCode, "message", impErr.message)
+		http.Error(responseWriter, impErr.message, impErr.statusCode)
+		return
+	}
+	imported = result
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(responseWriter).Encode(result); err != nil {
+		slog.ErrorContext(request.Context(), "failed to encode import response", "error", err)
+		http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+		return
 	}
 }
 
-// DecrementCardOwnedHTMLHandler returns an http.HandlerFunc that decrements
-// the owned count by 1 (clamped at 0) for the card identified by the id path
-// parameter and returns the updated owned-row fragment as HTML. Used by htmx
-// for inline owned count updates. Returns 400 Bad Request for invalid id,
-// 404 Not Found when no card exists, and 500 Internal Server Error for
-// database or template errors.
-func DecrementCardOwnedHTMLHandler(db *database.Database, tmpl *template.Template) http.HandlerFunc {
-	return func(responseWriter http.ResponseWriter, request *http.Request) {
-		rawID := request.PathValue("id")
-		if rawID == "" {
-			http.Error(responseWriter, "id path parameter is required", http.StatusBadRequest)
-			return
-		}
+// indexPage is the view model rendered by the index template. Settings
+// supplies the theme and the import options checked by default, and Lang the
+// language of the page's UI strings.
+type indexPage struct {
+	Grid          cardGrid
+	Recent        recentActivity
+	SavedSearches []models.SavedSearch
+	Goals         []models.GoalProgress
+	Settings      models.Settings
+	Query         string
+	Lang          string
+	Role          string
+	CSRFToken     string
+}
 
-		id, err := strconv.Atoi(rawID)
-		if err != nil || id <= 0 {
-			http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
-			return
-		}
+// cardGrid is the view model rendered by the cards template: one page of the
+// collection grid and, when more cards follow, the URL of the next page as a
+// fragment for htmx and as a full page for browsers without JavaScript.
+type cardGrid struct {
+	Cards        []gridCard
+	NextPage     string
+	NextPageLink string
+}
 
-		slog.Info("decrementing owned count", "card_id", id)
+// gridCard is a card in the collection grid. Snippet is the part of its rules
+// text matching the search, and is empty when the search did not match the
+// text.
+type gridCard struct {
+	models.Card
+	Snippet []search.SnippetPart
+}
 
-		if err := db.DecrementCardOwned(id); errors.Is(err, database.ErrCardNotFound) {
-			http.Error(responseWriter, "card not found", http.StatusNotFound)
-			return
-		} else if err != nil {
-			slog.Error("database error decrementing owned count", "card_id", id, "error", err)
-			http.Error(responseWriter, "database error", http.StatusInternalServerError)
-			return
+// newGridCards wraps cards for the grid, with snippets of their rules text
+// around the text terms of query.
+func newGridCards(cards []models.Card, query string) []gridCard {
+	var terms []string
+	if parsed, err := search.Parse(query); err == nil {
+		terms = parsed.TextTerms()
+	}
+
+	gridCards := make([]gridCard, len(cards))
+	for i, card := range cards {
+		gridCards[i] = gridCard{Card: card}
+		if len(terms) > 0 {
+			gridCards[i].Snippet = search.Snippet(card.Text, terms)
 		}
+	}
+	return gridCards
+}
 
-		card, err := db.GetCardByID(id)
+// loadCardGrid loads the given 1-based page of non-archived cards matching
+// query, in the default sort order and with the number of cards per page from
+// the settings. Every card is on the first page when ItemsPerPage is 0.
+func loadCardGrid(db *database.Database, query string, page int) (cardGrid, error) {
+	settings := db.Settings()
+
+	matchedCards, err := db.SearchCardsSorted(query, database.CardSort(settings.DefaultSort))
+	if err != nil {
+		return cardGrid{}, err
+	}
+
+	if settings.ItemsPerPage == 0 {
+		return cardGrid{Cards: newGridCards(matchedCards, query)}, nil
+	}
+
+	start := min((page-1)*settings.ItemsPerPage, len(matchedCards))
+	end := min(start+settings.ItemsPerPage, len(matchedCards))
+	grid := cardGrid{Cards: newGridCards(matchedCards[start:end], query)}
+
+	if end < len(matchedCards) {
+		values := url.Values{}
+		values.Set("q", query)
+		values.Set("page", strconv.Itoa(page+1))
+		grid.NextPage = "/cards/search/html?" + values.Encode()
+		grid.NextPageLink = "/?" + values.Encode()
+	}
+
+	return grid, nil
+}
+
+// parsePage reads the optional page query parameter, which defaults to 1.
+// Writes 400 Bad Request with templates.RenderError and returns false when it
+// is not a positive integer.
+func parsePage(responseWriter http.ResponseWriter, request *http.Request, tmpl templates.Renderer) (int, bool) {
+	rawPage := request.URL.Query().Get("page")
+	if rawPage == "" {
+		return 1, true
+	}
+
+	page, err := strconv.Atoi(rawPage)
+	if err != nil || page <= 0 {
+		templates.RenderError(responseWriter, request, tmpl, "page must be a positive integer", http.StatusBadRequest)
+		return 0, false
+	}
+
+	return page, true
+}
+
+// recentActivity is the view model rendered by the recent-activity template.
+type recentActivity struct {
+	Added   []models.Card
+	Changed []models.Card
+}
+
+// loadRecentActivity fetches the most recently added and most recently
+// changed cards for the index page's recent activity section.
+func loadRecentActivity(db *database.Database) (recentActivity, error) {
+	added, err := db.GetRecentCards(database.RecentAdded, recentActivityLimit)
+	if err != nil {
+		return recentActivity{}, err
+	}
+
+	changed, err := db.GetRecentCards(database.RecentChanged, recentActivityLimit)
+	if err != nil {
+		return recentActivity{}, err
+	}
+
+	return recentActivity{Added: added, Changed: changed}, nil
+}
+
+// parseRecentKind converts the "kind" query parameter into a RecentKind.
+// An empty value defaults to database.RecentAdded. Returns false for any
+// value other than "added" or "changed".
+func parseRecentKind(rawKind string) (database.RecentKind, bool) {
+	switch rawKind {
+	case "", string(database.RecentAdded):
+		return database.RecentAdded, true
+	case string(database.RecentChanged):
+		return database.RecentChanged, true
+	default:
+		return "", false
+	}
+}
+
+// RecentCardsHandler returns an http.HandlerFunc that handles GET /cards/recent.
+// It reads the optional "kind" query parameter ("added" or "changed",
+// defaulting to "added") and returns a JSON array of the most recently added
+// or changed cards, newest first. Returns 200 OK with a JSON array (empty
+// array when there are no results), 400 Bad Request for an unknown kind, and
+// 500 Internal Server Error for database errors.
+func RecentCardsHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		rawKind := request.URL.Query().Get("kind")
+
+		kind, ok := parseRecentKind(rawKind)
+		if !ok {
+			http.Error(responseWriter, "kind must be one of: added, changed", http.StatusBadRequest)
+			return
+		}
+
+		recentCards, err := db.GetRecentCards(kind, recentCardsLimit)
 		if err != nil {
-			slog.Error("database error fetching card after decrement", "card_id", id, "error", err)
+			slog.ErrorContext(request.Context(), "database error loading recent cards", "kind", kind, "error", err)
 			http.Error(responseWriter, "database error", http.StatusInternalServerError)
 			return
 		}
 
-		slog.Info("owned count decremented", "card_id", id, "owned", card.Owned)
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(recentCards); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode recent cards response", "kind", kind, "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// RecentCardsHTMLHandler returns an http.HandlerFunc that handles
+// GET /cards/recent/html. It renders the recent-activity partial template
+// listing the most recently added and changed cards. Used by htmx to refresh
+// the index page's recent activity section after an import. Returns 200 OK
+// with HTML on success and 500 Internal Server Error for database or template
+// errors.
+func RecentCardsHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		recent, err := loadRecentActivity(db)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading recent activity", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "recent-activity", recent); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render recent-activity template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// IndexHandler returns an http.HandlerFunc that serves the full index page at
+// GET /. It loads the cards matching the optional q and page parameters (so
+// that the search box and Load more work as plain links without JavaScript),
+// the recent activity lists and the saved searches from the database and
+// renders the index template. Returns 400 Bad Request for an invalid query or
+// page and 500 Internal Server Error if a database query or template
+// rendering fails.
+func IndexHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "GET / received")
+
+		query := request.URL.Query().Get("q")
+		if !validateSearchQuery(responseWriter, request, tmpl, query) {
+			return
+		}
+
+		pageNumber, ok := parsePage(responseWriter, request, tmpl)
+		if !ok {
+			return
+		}
+
+		grid, err := loadCardGrid(db, query, pageNumber)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading cards for index", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		recent, err := loadRecentActivity(db)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading recent activity for index", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		savedSearches, err := db.GetSavedSearches()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading saved searches for index", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		goals, err := db.GetGoalProgress()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading goal progress for index", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "rendering index page", "card_count", len(grid.Cards))
+
+		page := indexPage{
+			Grid:          grid,
+			Recent:        recent,
+			SavedSearches: savedSearches,
+			Goals:         goals,
+			Settings:      db.Settings(),
+			Query:         query,
+			Lang:          i18n.FromRequest(request),
+			Role:          roles.FromContext(request.Context()),
+			CSRFToken:     csrf.Token(request.Context()),
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		responseWriter.Header().Set("Content-Language", page.Lang)
+		responseWriter.Header().Add("Vary", "Accept-Language")
+		if err := tmpl.ExecuteTemplate(responseWriter, "index", page); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render index template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// SearchCardsHTMLHandler returns an http.HandlerFunc that handles
+// GET /cards/search/html. It reads the optional "q" and "page" query
+// parameters and renders the card grid partial template with that page of
+// matching cards, ending with a Load more button when more pages follow. Used
+// by htmx for live search updates. Returns 200 OK with HTML on success, 400
+// Bad Request for a page that is not a positive integer or a query that
+// cannot be parsed, and 500 Internal Server Error for database or template
+// errors.
+func SearchCardsHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query().Get("q")
+		if !validateSearchQuery(responseWriter, request, tmpl, query) {
+			return
+		}
+
+		page, ok := parsePage(responseWriter, request, tmpl)
+		if !ok {
+			return
+		}
+
+		grid, err := loadCardGrid(db, query, page)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error searching cards for HTML response", "query", query, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "cards", grid); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render cards template", "query", query, "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// maxUploadMemory is the maximum number of bytes of a multipart upload kept in
+// memory; larger uploads spill to temporary files, so the memory used by an
+// upload stays bounded however large the file is.
+const maxUploadMemory = 1 << 20
+
+// uploadedFile is a file from a parsed multipart form. Closing it also removes
+// any temporary files the form spilled to disk.
+type uploadedFile struct {
+	multipart.File
+	form *multipart.Form
+	// name is the file name the browser sent.
+	name string
+}
+
+// Close closes the file and removes the form's temporary files.
+func (file uploadedFile) Close() error {
+	closeErr := file.File.Close()
+	if err := file.form.RemoveAll(); err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// openUploadedCSV parses a multipart/form-data request and opens its "file"
+// field. On failure it writes a 400 Bad Request response and returns false;
+// callers must close the returned file on success.
+func openUploadedCSV(responseWriter http.ResponseWriter, request *http.Request) (uploadedFile, bool) {
+	if err := request.ParseMultipartForm(maxUploadMemory); err != nil {
+		slog.ErrorContext(request.Context(), "failed to parse multipart form", "error", err)
+		http.Error(responseWriter, "invalid form data", http.StatusBadRequest)
+		return uploadedFile{}, false
+	}
+
+	file, fileHeader, err := request.FormFile("file")
+	if err != nil {
+		slog.ErrorContext(request.Context(), "file field missing from upload form", "error", err)
+		request.MultipartForm.RemoveAll()
+		http.Error(responseWriter, "file field is required", http.StatusBadRequest)
+		return uploadedFile{}, false
+	}
+
+	slog.InfoContext(request.Context(), "upload file received", "filename", fileHeader.Filename, "size_bytes", fileHeader.Size)
+
+	return uploadedFile{File: file, form: request.MultipartForm, name: fileHeader.Filename}, true
+}
+
+// ImportCardsHTMLHandler returns an http.HandlerFunc that accepts a
+// multipart/form-data POST with a "file" field containing a CSV. It delegates
+// to the shared importCards helper and, on success, responds with 200 OK and
+// the import-result summary fragment, and sets the HX-Trigger response header
+// to "cardsImported" so htmx-listening elements can react. On failure it returns a human-readable error string for
+// display in the UI.
+//
+// When the "mode" form field is "sync", owned counts of existing cards are
+// overwritten from the CSV instead and the sync-result partial template is
+// rendered. With the "dry_run" form field set, the sync is only previewed and
+// no HX-Trigger header is sent. The "lenient" and "use_owned_count" form fields
+// behave like the query parameters of the same name on ImportCardsHandler. Like
+// ImportCardsHandler, it responds 409 Conflict while another import holds
+// lock.
+func ImportCardsHTMLHandler(db *database.Database, lock *ImportLock, httpClient *http.Client, imagesDir string, imageBaseURLs []string, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "POST /cards/import/html received")
+
+		file, ok := openUploadedCSV(responseWriter, request)
+		if !ok {
+			return
+		}
+		defer file.Close()
+
+		options, message := parseImportOptions(request.FormValue, importOptions{})
+		if message != "" {
+			templates.RenderError(responseWriter, request, tmpl, message, http.StatusBadRequest)
+			return
+		}
+
+		if !lock.tryAcquire("html", options.mode, options.dryRun) {
+			slog.WarnContext(request.Context(), "import rejected, another import is in progress")
+			templates.RenderError(responseWriter, request, tmpl, errImportInProgress.message, errImportInProgress.statusCode)
+			return
+		}
+		var (
+			failure  *importError
+			kept     []byte
+			imported *cardservice.ImportResult
+			synced   *cardservice.SyncResult
+		)
+		defer func() { recordImport(request.Context(), db, lock.release(failure), file.name, kept, imported, synced) }()
+
+		var reader io.Reader
+		reader, kept, failure = keepImportFile(file, db.Settings().ImportKeepFiles)
+		if failure != nil {
+			templates.RenderError(responseWriter, request, tmpl, failure.message, failure.statusCode)
+			return
+		}
+
+		service := cardservice.NewImportService(db, httpClient, imagesDir, imageBaseURLs)
+
+		if options.mode == importModeSync {
+			result, syncErr := syncOwnedCounts(request.Context(), service, reader, options)
+			if syncErr != nil {
+				failure = syncErr
+				slog.ErrorContext(request.Context(), "sync failed", "status", syncErr.statusCode, "message", syncErr.message)
+				templates.RenderError(responseWriter, request, tmpl, syncErr.message, syncErr.statusCode)
+				return
+			}
+			synced = result
+
+			if !options.dryRun {
+				slog.InfoContext(request.Context(), "sync succeeded, triggering cardsImported event")
+				responseWriter.Header().Set("HX-Trigger", "cardsImported")
+			}
+
+			responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := tmpl.ExecuteTemplate(responseWriter, "sync-result", result); err != nil {
+				slog.ErrorContext(request.Context(), "failed to render sync-result template", "error", err)
+				http.Error(responseWriter, "template error", http.StatusInternalServerError)
+				return
+			}
+			return
+		}
+
+		result, impErr := importCards(request.Context(), service, reader, options)
+		if impErr != nil {
+			failure = impErr
+			slog.ErrorContext(request.Context(), "import failed", "status", impErr.statusCode, "message", impErr.message)
+			templates.RenderError(responseWriter, request, tmpl, impErr.message, impErr.statusCode)
+			return
+		}
+		imported = result
+
+		slog.InfoContext(request.Context(), "import succeeded, triggering cardsImported event")
+		responseWriter.Header().Set("HX-Trigger", "cardsImported")
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "import-result", result); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render import-result template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ImportStatusHandler returns an http.HandlerFunc that handles
+// GET /cards/import/status. Returns 200 OK with the ImportStatus of the
+// running or most recent import as JSON.
+func ImportStatusHandler(lock *ImportLock) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(lock.Status()); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode import status", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// IncrementCardOwnedHTMLHandler returns an http.HandlerFunc that increments
+// the owned count by 1 for the card identified by the id path parameter and
+// returns the updated owned-row fragment as HTML. Used by htmx for inline
+// owned count updates. Returns 400 Bad Request for invalid id, 404 Not Found
+// when no card exists, and 500 Internal Server Error for database or template
+// errors.
+func IncrementCardOwnedHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		rawID := request.PathValue("id")
+		if rawID == "" {
+			templates.RenderError(responseWriter, request, tmpl, "id path parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(rawID)
+		if err != nil || id <= 0 {
+			templates.RenderError(responseWriter, request, tmpl, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "incrementing owned count", "card_id", id)
+
+		card, err := cardservice.NewCollectionService(db).IncrementOwned(request.Context(), id)
+		if err != nil {
+			writeServiceError(responseWriter, err)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "owned count incremented", "card_id", id, "owned", card.Owned)
 
 		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
 		if err := tmpl.ExecuteTemplate(responseWriter, "card-owned-fragment", card); err != nil {
-			slog.Error("failed to render card-owned-fragment template", "card_id", id, "error", err)
+			slog.ErrorContext(request.Context(), "failed to render card-owned-fragment template", "card_id", id, "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// WishlistCards converts a slice of Card records into WishlistCard records
+// by computing the Deficit for each card. The deficit is the number of additional
+// copies needed to reach the minimum threshold in settings for the card's type.
+// The result is ordered by priority, highest first, then by deficit, largest
+// first; cards that tie on both keep their original relative order.
+func WishlistCards(cardSlice []models.Card, settings models.Settings) []models.WishlistCard {
+	wishlist := make([]models.WishlistCard, 0, len(cardSlice))
+	for _, card := range cardSlice {
+		wishlist = append(wishlist, models.WishlistCard{
+			Card:    card,
+			Deficit: database.MinimumOwned(settings, card.Type) - card.Owned,
+		})
+	}
+	sort.SliceStable(wishlist, func(i, j int) bool {
+		if wishlist[i].Priority != wishlist[j].Priority {
+			return wishlist[i].Priority > wishlist[j].Priority
+		}
+		return wishlist[i].Deficit > wishlist[j].Deficit
+	})
+	return wishlist
+}
+
+// ExcessCards converts cards owned beyond their minimum threshold into
+// ExcessCards with the Surplus over the threshold in settings for the card's
+// type. The result is ordered by surplus, largest first; cards
+// with the same surplus keep their original relative order.
+func ExcessCards(cardSlice []models.Card, settings models.Settings) []models.ExcessCard {
+	excess := make([]models.ExcessCard, 0, len(cardSlice))
+	for _, card := range cardSlice {
+		excess = append(excess, models.ExcessCard{
+			Card:    card,
+			Surplus: card.Owned - database.MinimumOwned(settings, card.Type),
+		})
+	}
+	sort.SliceStable(excess, func(i, j int) bool {
+		return excess[i].Surplus > excess[j].Surplus
+	})
+	return excess
+}
+
+// ExcessCardsHandler returns an http.HandlerFunc that handles GET
+// /cards/excess, the inverse of the wishlist: every non-archived card owned
+// beyond its minimum owned threshold, with the surplus copies available to
+// trade, largest surplus first. Returns 200 OK with a JSON array (empty when
+// no card has spare copies) and 500 Internal Server Error for database
+// errors.
+func ExcessCardsHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		cardSlice, err := db.GetExcessCards()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading excess cards", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		excess := ExcessCards(cardSlice, db.Settings())
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(excess); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode excess cards response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// wishlistGroup is one collapsible section of a grouped wishlist grid.
+type wishlistGroup struct {
+	Label string
+	Cards []models.WishlistCard
+}
+
+// wishlistGrid is the view model rendered by the wishlist-grid template.
+// Groups is set instead of Cards when the wishlist is grouped.
+type wishlistGrid struct {
+	Cards  []models.WishlistCard
+	Groups []wishlistGroup
+}
+
+// wishlistPage is the view model rendered by the wishlist template.
+type wishlistPage struct {
+	Grid      wishlistGrid
+	Group     database.WishlistGrouping
+	Completed []models.WishlistCompletion
+	Theme     string
+	Role      string
+	CSRFToken string
+}
+
+// parseWishlistGrouping validates the raw group parameter. An empty value
+// means the wishlist is not grouped.
+func parseWishlistGrouping(raw string) (database.WishlistGrouping, bool) {
+	switch grouping := database.WishlistGrouping(strings.ToLower(strings.TrimSpace(raw))); grouping {
+	case "", database.GroupBySet, database.GroupByAspect, database.GroupByType:
+		return grouping, true
+	default:
+		return "", false
+	}
+}
+
+// loadWishlistGrid loads the wishlist cards matching query, grouped by
+// grouping unless it is empty. Cards without the grouped detail are labelled
+// "Unspecified".
+func loadWishlistGrid(db *database.Database, query string, grouping database.WishlistGrouping) (wishlistGrid, error) {
+	if grouping == "" {
+		wishlistCards, err := db.GetWishlistCards(query)
+		if err != nil {
+			return wishlistGrid{}, err
+		}
+		return wishlistGrid{Cards: WishlistCards(wishlistCards, db.Settings())}, nil
+	}
+
+	cardGroups, err := db.GetWishlistCardGroups(query, grouping)
+	if err != nil {
+		return wishlistGrid{}, err
+	}
+
+	settings := db.Settings()
+	groups := make([]wishlistGroup, len(cardGroups))
+	for i, cardGroup := range cardGroups {
+		label := cardGroup.Key
+		if label == "" {
+			label = "Unspecified"
+		}
+		groups[i] = wishlistGroup{Label: label, Cards: WishlistCards(cardGroup.Cards, settings)}
+	}
+	return wishlistGrid{Groups: groups}, nil
+}
+
+// WishlistHandler returns an http.HandlerFunc that serves the wishlist page at
+// GET /wishlist. It loads all cards below their minimum owned threshold and the
+// most recently completed wishlist cards from the database and renders the
+// wishlist template. The optional "group" query parameter (set, aspect or
+// type) renders the cards in collapsible sections. Returns 400 Bad Request for
+// an unknown group and 500 Internal Server Error if a database query or
+// template rendering fails.
+func WishlistHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "GET /wishlist received")
+
+		grouping, ok := parseWishlistGrouping(request.URL.Query().Get("group"))
+		if !ok {
+			templates.RenderError(responseWriter, request, tmpl, "group must be set, aspect or type", http.StatusBadRequest)
+			return
+		}
+
+		grid, err := loadWishlistGrid(db, "", grouping)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading wishlist cards", "group", grouping, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		completed, err := db.GetRecentWishlistCompletions(recentCompletionsLimit)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading wishlist completions", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "rendering wishlist page", "card_count", len(grid.Cards), "group_count", len(grid.Groups), "completed_count", len(completed))
+
+		page := wishlistPage{
+			Grid:      grid,
+			Group:     grouping,
+			Completed: completed,
+			Theme:     db.Settings().Theme,
+			Role:      roles.FromContext(request.Context()),
+			CSRFToken: csrf.Token(request.Context()),
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "wishlist", page); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render wishlist template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// SearchWishlistHTMLHandler returns an http.HandlerFunc that handles
+// GET /wishlist/search/html. It reads the optional "q" and "group" query
+// parameters and renders the wishlist grid partial template with matching
+// wishlist cards. Used by htmx for live search updates. Returns 200 OK with
+// HTML on success, 400 Bad Request for an unknown group or a query that cannot
+// be parsed, and 500 Internal Server Error for database or template errors.
+func SearchWishlistHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query().Get("q")
+		if !validateSearchQuery(responseWriter, request, tmpl, query) {
+			return
+		}
+
+		grouping, ok := parseWishlistGrouping(request.URL.Query().Get("group"))
+		if !ok {
+			templates.RenderError(responseWriter, request, tmpl, "group must be set, aspect or type", http.StatusBadRequest)
+			return
+		}
+
+		grid, err := loadWishlistGrid(db, query, grouping)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error searching wishlist cards for HTML response", "query", query, "group", grouping, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "wishlist-grid", grid); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render wishlist-grid template", "query", query, "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// WishlistSnippetHandler returns an http.HandlerFunc that handles GET
+// /wishlist/snippet: the wishlist, filtered by the optional "q" search query,
+// as a plain-text snippet of each card's missing copies in the
+// snippet.Format named by "format" (inline by default), in wishlist order.
+// Returns 400 Bad Request for an unknown format or a query that cannot be
+// parsed and 500 Internal Server Error for database errors.
+func WishlistSnippetHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		serveWishlistSnippet(responseWriter, request, db, nil)
+	}
+}
+
+// WishlistSnippetHTMLHandler returns an http.HandlerFunc that handles GET
+// /wishlist/snippet/html: the snippet of WishlistSnippetHandler in the
+// snippet fragment, with a format select and a copy button, for the wishlist
+// page. Returns the same errors as WishlistSnippetHandler.
+func WishlistSnippetHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		serveWishlistSnippet(responseWriter, request, db, tmpl)
+	}
+}
+
+// serveWishlistSnippet responds with the wishlist snippet, as text when tmpl
+// is nil and in the snippet fragment otherwise.
+func serveWishlistSnippet(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer) {
+	query := request.URL.Query().Get("q")
+	if !validateSearchQuery(responseWriter, request, tmpl, query) {
+		return
+	}
+
+	grid, err := loadWishlistGrid(db, query, "")
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error loading wishlist for snippet", "query", query, "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]snippet.Item, len(grid.Cards))
+	for i, card := range grid.Cards {
+		items[i] = snippet.Item{Count: card.Deficit, Name: card.Name}
+	}
+
+	snippet.Serve(responseWriter, request, tmpl, "/wishlist/snippet/html", items)
+}
+
+// DecrementCardOwnedHTMLHandler returns an http.HandlerFunc that decrements
+// the owned count by 1 (clamped at 0) for the card identified by the id path
+// parameter and returns the updated owned-row fragment as HTML. Used by htmx
+// for inline owned count updates. A decrement below the decrement floor
+// setting needs confirm=true: without it htmx requests get the
+// card-owned-confirm fragment, whose button repeats the decrement confirmed,
+// and other requests 409 Conflict. Returns 400 Bad Request for invalid id or
+// confirm, 404 Not Found when no card exists, and 500 Internal Server Error
+// for database or template errors.
+func DecrementCardOwnedHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		rawID := request.PathValue("id")
+		if rawID == "" {
+			templates.RenderError(responseWriter, request, tmpl, "id path parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(rawID)
+		if err != nil || id <= 0 {
+			templates.RenderError(responseWriter, request, tmpl, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		confirmed, ok := parseConfirm(request.FormValue("confirm"))
+		if !ok {
+			templates.RenderError(responseWriter, request, tmpl, "confirm must be a boolean", http.StatusBadRequest)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "decrementing owned count", "card_id", id, "confirmed", confirmed)
+
+		card, err := cardservice.NewCollectionService(db).DecrementOwned(request.Context(), id, confirmed)
+		if cardservice.KindOf(err) == cardservice.KindConfirm {
+			renderDecrementConfirm(responseWriter, request, db, tmpl, id, err)
+			return
+		}
+		if err != nil {
+			writeServiceError(responseWriter, err)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "owned count decremented", "card_id", id, "owned", card.Owned)
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "card-owned-fragment", card); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render card-owned-fragment template", "card_id", id, "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// renderDecrementConfirm responds to a decrement of the card with id that
+// needs confirming, err: htmx requests get the card-owned-confirm fragment and
+// other requests a 409 Conflict error page.
+func renderDecrementConfirm(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer, id int, err error) {
+	slog.InfoContext(request.Context(), "decrement needs confirming", "card_id", id)
+
+	if !fallback.IsHTMX(request) {
+		templates.RenderError(responseWriter, request, tmpl, err.Error(), http.StatusConflict)
+		return
+	}
+
+	card, err := db.GetCardByID(id)
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error loading card for decrement confirmation", "card_id", id, "error", err)
+		http.Error(responseWriter, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.ExecuteTemplate(responseWriter, "card-owned-confirm", card); err != nil {
+		slog.ErrorContext(request.Context(), "failed to render card-owned-confirm template", "card_id", id, "error", err)
+		http.Error(responseWriter, "template error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// setCardArchivedHandler returns an http.HandlerFunc that sets the archived
+// flag to archived for the card identified by the id path parameter. Returns
+// 204 No Content on success, 400 Bad Request for a missing or
+// non-positive-integer id, 404 Not Found when no card with that id exists,
+// and 500 Internal Server Error for database errors.
+func setCardArchivedHandler(db *database.Database, archived bool) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		rawID := request.PathValue("id")
+		if rawID == "" {
+			http.Error(responseWriter, "id path parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(rawID)
+		if err != nil || id <= 0 {
+			http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "setting card archived flag", "card_id", id, "archived", archived)
+
+		if err := cardservice.NewCollectionService(db).SetArchived(request.Context(), id, archived); err != nil {
+			writeServiceError(responseWriter, err)
+			return
+		}
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ArchiveCardHandler returns an http.HandlerFunc that handles
+// POST /cards/{id}/archive. The card is hidden from search and the wishlist
+// but keeps its owned count. Returns 204 No Content on success, 400 Bad
+// Request for an invalid id, 404 Not Found when no card exists, and 500
+// Internal Server Error for database errors.
+func ArchiveCardHandler(db *database.Database) http.HandlerFunc {
+	return setCardArchivedHandler(db, true)
+}
+
+// UnarchiveCardHandler returns an http.HandlerFunc that handles
+// POST /cards/{id}/unarchive, restoring an archived card to search and the
+// wishlist. Returns 204 No Content on success, 400 Bad Request for an invalid
+// id, 404 Not Found when no card exists, and 500 Internal Server Error for
+// database errors.
+func UnarchiveCardHandler(db *database.Database) http.HandlerFunc {
+	return setCardArchivedHandler(db, false)
+}
+
+// setCardArchivedHTMLHandler returns an http.HandlerFunc that sets the
+// archived flag to archived for the card identified by the id path parameter
+// and responds with 200 OK and an empty body, so an htmx outerHTML swap
+// removes the card tile from the current page. Returns 400 Bad Request for an
+// invalid id, 404 Not Found when no card exists, and 500 Internal Server
+// Error for database errors.
+func setCardArchivedHTMLHandler(db *database.Database, archived bool) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		rawID := request.PathValue("id")
+		if rawID == "" {
+			http.Error(responseWriter, "id path parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(rawID)
+		if err != nil || id <= 0 {
+			http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "setting card archived flag", "card_id", id, "archived", archived)
+
+		if err := cardservice.NewCollectionService(db).SetArchived(request.Context(), id, archived); err != nil {
+			writeServiceError(responseWriter, err)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		responseWriter.WriteHeader(http.StatusOK)
+	}
+}
+
+// ArchiveCardHTMLHandler returns an http.HandlerFunc that handles
+// POST /cards/{id}/archive/html. Used by the archive button on collection
+// card tiles; the empty response removes the tile from the grid.
+func ArchiveCardHTMLHandler(db *database.Database) http.HandlerFunc {
+	return setCardArchivedHTMLHandler(db, true)
+}
+
+// UnarchiveCardHTMLHandler returns an http.HandlerFunc that handles
+// POST /cards/{id}/unarchive/html. Used by the restore button on the archive
+// page; the empty response removes the tile from the archive grid.
+func UnarchiveCardHTMLHandler(db *database.Database) http.HandlerFunc {
+	return setCardArchivedHTMLHandler(db, false)
+}
+
+// archiveGrid is the view model rendered by the archive-cards template, the
+// grid of archived card tiles.
+type archiveGrid struct {
+	Cards []models.Card
+}
+
+// archivePage is the view model rendered by the archive template.
+type archivePage struct {
+	Grid      archiveGrid
+	Theme     string
+	Role      string
+	CSRFToken string
+}
+
+// ArchiveHandler returns an http.HandlerFunc that serves the archive page at
+// GET /archive. It loads all archived cards from the database and renders the
+// archive template. Returns 500 Internal Server Error if the database query or
+// template rendering fails.
+func ArchiveHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "GET /archive received")
+
+		archivedCards, err := db.GetArchivedCards("")
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading archived cards", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "rendering archive page", "card_count", len(archivedCards))
+
+		page := archivePage{Grid: archiveGrid{Cards: archivedCards}, Theme: db.Settings().Theme, Role: roles.FromContext(request.Context()), CSRFToken: csrf.Token(request.Context())}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "archive", page); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render archive template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// SearchArchiveHTMLHandler returns an http.HandlerFunc that handles
+// GET /archive/search/html. It reads the optional "q" query parameter and
+// renders the archive card grid partial template with matching archived
+// cards. Used by htmx for live search updates. Returns 200 OK with HTML on
+// success, 400 Bad Request for a query that cannot be parsed, and 500
+// Internal Server Error for database or template errors.
+func SearchArchiveHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query().Get("q")
+		if !validateSearchQuery(responseWriter, request, tmpl, query) {
+			return
+		}
+
+		archivedCards, err := db.GetArchivedCards(query)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error searching archived cards for HTML response", "query", query, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "archive-cards", archiveGrid{Cards: archivedCards}); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render archive-cards template", "query", query, "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// priorityNames maps the priority names accepted by the priority endpoints to
+// the values stored in the database.
+var priorityNames = map[string]int{
+	"low":    database.PriorityLow,
+	"normal": database.PriorityNormal,
+	"high":   database.PriorityHigh,
+}
+
+// priorityRequest is the JSON body accepted by SetCardPriorityHandler.
+type priorityRequest struct {
+	Priority string `json:"priority"`
+}
+
+// aliasRequest is the JSON body accepted by AddCardAliasHandler.
+type aliasRequest struct {
+	Alias string `json:"alias"`
+}
+
+// GetCardAliasesHandler returns an http.HandlerFunc that handles
+// GET /cards/{id}/aliases. Returns 200 OK with a JSON array of the card's
+// aliases (empty array when it has none), 400 Bad Request for a missing or
+// non-positive-integer id, 404 Not Found when no card with that id exists,
+// and 500 Internal Server Error for database errors.
+func GetCardAliasesHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		rawID := request.PathValue("id")
+		if rawID == "" {
+			http.Error(responseWriter, "id path parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(rawID)
+		if err != nil || id <= 0 {
+			http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		aliases, err := db.GetCardAliases(id)
+		if errors.Is(err, database.ErrCardNotFound) {
+			http.Error(responseWriter, "card not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error fetching card aliases", "card_id", id, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(aliases); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode aliases response", "card_id", id, "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// AddCardAliasHandler returns an http.HandlerFunc that handles
+// POST /cards/{id}/aliases. It accepts a JSON body of the form
+// {"alias": "..."} and records the alias so searches for it find the card.
+// Adding an alias the card already has is a no-op. Returns 204 No Content on
+// success, 400 Bad Request for an invalid id, malformed body, or blank alias,
+// 404 Not Found when no card with that id exists, and 500 Internal Server
+// Error for database errors.
+func AddCardAliasHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		rawID := request.PathValue("id")
+		if rawID == "" {
+			http.Error(responseWriter, "id path parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(rawID)
+		if err != nil || id <= 0 {
+			http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		var payload aliasRequest
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		alias := strings.TrimSpace(payload.Alias)
+		if alias == "" {
+			http.Error(responseWriter, "alias must not be empty", http.StatusBadRequest)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "adding card alias", "card_id", id, "alias", alias)
+
+		if err := db.AddCardAlias(id, alias); errors.Is(err, database.ErrCardNotFound) {
+			http.Error(responseWriter, "card not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.ErrorContext(request.Context(), "database error adding card alias", "card_id", id, "alias", alias, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SetCardPriorityHandler returns an http.HandlerFunc that handles
+// POST /cards/{id}/priority with a JSON body such as {"priority":"high"}.
+// Accepted priorities are "low", "normal" and "high". Returns 204 No Content
+// on success, 400 Bad Request for an invalid id, body or priority, 404 Not
+// Found when no card exists, and 500 Internal Server Error for database errors.
+func SetCardPriorityHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		rawID := request.PathValue("id")
+		if rawID == "" {
+			http.Error(responseWriter, "id path parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(rawID)
+		if err != nil || id <= 0 {
+			http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		var payload priorityRequest
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		priority, ok := priorityNames[strings.ToLower(strings.TrimSpace(payload.Priority))]
+		if !ok {
+			http.Error(responseWriter, "priority must be low, normal or high", http.StatusBadRequest)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "setting card priority", "card_id", id, "priority", payload.Priority)
+
+		if err := db.SetCardPriority(id, priority); errors.Is(err, database.ErrCardNotFound) {
+			http.Error(responseWriter, "card not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.ErrorContext(request.Context(), "database error setting card priority", "card_id", id, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SetCardPriorityHTMLHandler returns an http.HandlerFunc that handles
+// POST /cards/{id}/priority/html from the wishlist page's priority selector.
+// It reads the priority, the current search query q and grouping group from the
+// form, sets the priority, and re-renders the wishlist-grid fragment so the
+// tile moves to its new position. Returns 400 Bad Request for an invalid id,
+// priority or group, 404 Not
+// Found when no card exists, and 500 Internal Server Error for database or
+// template errors.
+func SetCardPriorityHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		rawID := request.PathValue("id")
+		if rawID == "" {
+			templates.RenderError(responseWriter, request, tmpl, "id path parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(rawID)
+		if err != nil || id <= 0 {
+			templates.RenderError(responseWriter, request, tmpl, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		rawPriority := request.FormValue("priority")
+		priority, ok := priorityNames[strings.ToLower(strings.TrimSpace(rawPriority))]
+		if !ok {
+			templates.RenderError(responseWriter, request, tmpl, "priority must be low, normal or high", http.StatusBadRequest)
+			return
+		}
+
+		grouping, ok := parseWishlistGrouping(request.FormValue("group"))
+		if !ok {
+			templates.RenderError(responseWriter, request, tmpl, "group must be set, aspect or type", http.StatusBadRequest)
+			return
+		}
+
+		if err := db.SetCardPriority(id, priority); errors.Is(err, database.ErrCardNotFound) {
+			templates.RenderError(responseWriter, request, tmpl, "card not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.ErrorContext(request.Context(), "database error setting card priority", "card_id", id, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		query := request.FormValue("q")
+		grid, err := loadWishlistGrid(db, query, grouping)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading wishlist cards after priority change", "query", query, "group", grouping, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "wishlist-grid", grid); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render wishlist-grid template", "card_id", id, "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// DeleteCardAliasHandler returns an http.HandlerFunc that handles
+// DELETE /cards/{id}/aliases/{alias}. Returns 204 No Content on success, 400
+// Bad Request for an invalid id or missing alias, 404 Not Found when the card
+// has no such alias, and 500 Internal Server Error for database errors.
+func DeleteCardAliasHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		rawID := request.PathValue("id")
+		if rawID == "" {
+			http.Error(responseWriter, "id path parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(rawID)
+		if err != nil || id <= 0 {
+			http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		alias := strings.TrimSpace(request.PathValue("alias"))
+		if alias == "" {
+			http.Error(responseWriter, "alias path parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "deleting card alias", "card_id", id, "alias", alias)
+
+		if err := db.DeleteCardAlias(id, alias); errors.Is(err, database.ErrAliasNotFound) {
+			http.Error(responseWriter, "alias not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.ErrorContext(request.Context(), "database error deleting card alias", "card_id", id, "alias", alias, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RandomCardHandler returns an http.HandlerFunc that handles GET /cards/random.
+// It returns a random non-archived card as JSON, optionally filtered by the
+// "set" and "rarity" query parameters and by "owned=true" to only pick cards
+// with at least one copy owned. Returns 200 OK with the card as JSON, 400 Bad
+// Request for a non-boolean owned value, 404 Not Found when no card matches
+// the filters, and 500 Internal Server Error for database errors.
+func RandomCardHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+
+		filter := database.RandomCardFilter{
+			Set:    query.Get("set"),
+			Rarity: query.Get("rarity"),
+		}
+
+		if rawOwned := query.Get("owned"); rawOwned != "" {
+			ownedOnly, err := strconv.ParseBool(rawOwned)
+			if err != nil {
+				http.Error(responseWriter, "owned must be a boolean", http.StatusBadRequest)
+				return
+			}
+			filter.OwnedOnly = ownedOnly
+		}
+
+		card, err := db.GetRandomCard(filter)
+		if errors.Is(err, database.ErrCardNotFound) {
+			http.Error(responseWriter, "no cards match the filters", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error picking random card", "filter", filter, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(card); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode random card response", "id", card.ID, "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// DiffCardsHandler returns an http.HandlerFunc that handles POST /cards/diff.
+// It accepts a raw SWUDB CSV body and returns a JSON comparison listing the
+// cards only in the CSV, the cards only in the database, and the cards whose
+// owned counts differ. Owned counts of variant rows sharing a card name are
+// summed. The database is not modified. Returns 200 OK with the diff as JSON,
+// 400 Bad Request for invalid CSV, and 500 Internal Server Error for database
+// errors.
+func DiffCardsHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "POST /cards/diff received")
+
+		diff, err := cardservice.NewCollectionService(db).Diff(request.Context(), request.Body)
+		if err != nil {
+			writeServiceError(responseWriter, err)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(diff); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode diff response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// DiffCardsHTMLHandler returns an http.HandlerFunc that handles
+// POST /cards/diff/html. It accepts a multipart/form-data POST with a "file"
+// field containing a SWUDB CSV and renders the cards-diff partial template
+// with the comparison. Used by the Compare dialog on the index page. Returns
+// 200 OK with HTML on success, 400 Bad Request for missing or invalid input,
+// and 500 Internal Server Error for database or template errors.
+func DiffCardsHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "POST /cards/diff/html received")
+
+		file, ok := openUploadedCSV(responseWriter, request)
+		if !ok {
+			return
+		}
+		defer file.Close()
+
+		diff, err := cardservice.NewCollectionService(db).Diff(request.Context(), file)
+		if err != nil {
+			writeServiceError(responseWriter, err)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "cards-diff", diff); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render cards-diff template", "error", err)
 			http.Error(responseWriter, "template error", http.StatusInternalServerError)
 			return
 		}
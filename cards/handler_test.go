@@ -2,46 +2,42 @@ package cards_test
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"image"
+	"image/png"
 	"io"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"swucol/apitokens"
+	"swucol/audit"
 	"swucol/cards"
 	"swucol/database"
+	"swucol/eventbus"
+	"swucol/images"
+	"swucol/internal/testdb"
+	"swucol/metrics"
 	"swucol/models"
+	"swucol/requestid"
+	"swucol/roles"
+	"swucol/templates"
 )
 
-// newTestDatabase creates a Database backed by a temporary file that is
-// cleaned up automatically when the test ends.
-func newTestDatabase(t *testing.T) *database.Database {
-	t.Helper()
-
-	filePath := filepath.Join(t.TempDir(), "test.db")
-
-	db, err := database.New(filePath)
-	require.NoError(t, err, "expected no error opening test database")
-
-	require.NoError(t, db.RunMigrations())
-
-	t.Cleanup(func() {
-		db.Shutdown()
-	})
-
-	return db
-}
-
 // postImport sends a POST request to the ImportCardsHandler with the given
 // HTTP client, images directory, image base URL, and CSV body.
 func postImport(t *testing.T, db *database.Database, httpClient *http.Client, imagesDir, imageBaseURL, body string) *http.Response {
@@ -50,7 +46,7 @@ func postImport(t *testing.T, db *database.Database, httpClient *http.Client, im
 	request := httptest.NewRequest(http.MethodPost, "/cards/import", strings.NewReader(body))
 	recorder := httptest.NewRecorder()
 
-	cards.ImportCardsHandler(db, httpClient, imagesDir, imageBaseURL)(recorder, request)
+	cards.ImportCardsHandler(db, cards.NewImportLock(), httpClient, imagesDir, []string{imageBaseURL})(recorder, request)
 
 	return recorder.Result()
 }
@@ -59,7 +55,7 @@ func postImport(t *testing.T, db *database.Database, httpClient *http.Client, im
 const validCSVHeader = "Set,Card Number,Card Name,Card Title,Card Type,Aspects,Variant Type,Rarity,Foil,Stamp,Artist,Owned Count,Group Owned Count"
 
 func TestImportCardsHandler_ValidCSV_InsertsNewCards(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -69,12 +65,12 @@ func TestImportCardsHandler_ValidCSV_InsertsNewCards(t *testing.T) {
 	defer imageServer.Close()
 
 	csv := validCSVHeader + "\n" +
-		"LAW,001,Chewbacca,Hero of Kessel,Character,Heroism,Normal,Rare,false,,Artist One,0,0\n" +
-		"LAW,002,Luke Skywalker,Jedi Knight,Character,Heroism,Normal,Rare,false,,Artist Two,0,0"
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0\n" +
+		"LAW,002,Luke Skywalker,Jedi Knight,Unit,Heroism,Normal,Rare,false,,Artist Two,0,0"
 
 	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
 
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
 	exists, err := db.CardExistsByName("Chewbacca, Hero of Kessel")
 	require.NoError(t, err)
@@ -85,8 +81,37 @@ func TestImportCardsHandler_ValidCSV_InsertsNewCards(t *testing.T) {
 	assert.True(t, exists, "expected Luke Skywalker, Jedi Knight to be inserted")
 }
 
+func TestImportCardsHandler_PublishesInsertedCardsAndCompletion(t *testing.T) {
+	db := testdb.New(t)
+
+	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("fake-png-data"))
+	}))
+	defer imageServer.Close()
+
+	var published []string
+	record := func(ctx context.Context, event eventbus.Event) {
+		published = append(published, fmt.Sprintf("%s %s %d", event.Type, event.CardName, event.Count))
+	}
+	t.Cleanup(eventbus.Subscribe(eventbus.CardInserted, record))
+	t.Cleanup(eventbus.Subscribe(eventbus.ImportCompleted, record))
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0\n" +
+		"LAW,002,Luke Skywalker,Jedi Knight,Unit,Heroism,Normal,Rare,false,,Artist Two,0,0"
+	response := postImport(t, db, imageServer.Client(), t.TempDir(), imageServer.URL, csv)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	assert.Equal(t, []string{
+		"card.inserted Chewbacca, Hero of Kessel 0",
+		"card.inserted Luke Skywalker, Jedi Knight 0",
+		"import.completed  2",
+	}, published)
+}
+
 func TestImportCardsHandler_InsertsCardsWithOwnedZero(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -96,11 +121,11 @@ func TestImportCardsHandler_InsertsCardsWithOwnedZero(t *testing.T) {
 	defer imageServer.Close()
 
 	csv := validCSVHeader + "\n" +
-		"LAW,001,Chewbacca,Hero of Kessel,Character,Heroism,Normal,Rare,false,,Artist One,5,10"
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,5,10"
 
 	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
 
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
 	row := db.Connection().QueryRow(
 		"SELECT owned FROM cards WHERE name = ?",
@@ -111,8 +136,57 @@ func TestImportCardsHandler_InsertsCardsWithOwnedZero(t *testing.T) {
 	assert.Equal(t, 0, owned, "expected owned to be 0 regardless of CSV Owned Count")
 }
 
+func TestImportCardsHandler_UseOwnedCount_SetsOwnedOnFirstInsertOnly(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Mainboard: true}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{"Luke Skywalker, Jedi Knight": 1}))
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,2,2\n" +
+		"LAW,002,Luke Skywalker,Jedi Knight,Unit,Heroism,Normal,Rare,false,,Artist Two,5,5\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Foil,Rare,true,,Artist One,1,1\n" +
+		"LAW,003,Han Solo,Scoundrel,Unit,Cunning,Normal,Rare,false,,Artist Three,,"
+
+	response := postSync(t, db, "use_owned_count=true", csv)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	assert.Equal(t, 3, getOwnedByName(t, db, "Chewbacca, Hero of Kessel"), "expected variant rows to be summed")
+	assert.Equal(t, 1, getOwnedByName(t, db, "Luke Skywalker, Jedi Knight"), "expected existing card count to be untouched")
+	assert.Equal(t, 0, getOwnedByName(t, db, "Han Solo, Scoundrel"), "expected blank count to be 0")
+}
+
+func TestImportCardsHandler_ImportDefaultsFromSettings_ApplyWhenOmitted(t *testing.T) {
+	db := testdb.New(t)
+	settings := database.DefaultSettings()
+	settings.ImportUseOwnedCount = true
+	require.NoError(t, db.SaveSettings(settings))
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,2,2\n" +
+		"LAW,002,Luke Skywalker,Jedi Knight,Unit,Heroism,Normal,Rare,false,,Artist Two,4,4"
+
+	response := postSync(t, db, "", csv)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, 2, getOwnedByName(t, db, "Chewbacca, Hero of Kessel"))
+
+	response = postSync(t, db, "use_owned_count=false", strings.Replace(csv, "Luke Skywalker", "Leia Organa", 1))
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, 0, getOwnedByName(t, db, "Leia Organa, Jedi Knight"), "expected an explicit parameter to override the default")
+}
+
+func TestImportCardsHandler_UseOwnedCount_InvalidCountReturns400(t *testing.T) {
+	db := testdb.New(t)
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,-2,0"
+
+	response := postSync(t, db, "use_owned_count=true", csv)
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
 func TestImportCardsHandler_DuplicateCards_SkipsExisting(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -122,14 +196,14 @@ func TestImportCardsHandler_DuplicateCards_SkipsExisting(t *testing.T) {
 	defer imageServer.Close()
 
 	csv := validCSVHeader + "\n" +
-		"LAW,001,Chewbacca,Hero of Kessel,Character,Heroism,Normal,Rare,false,,Artist One,0,0"
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
 
 	// Import the same CSV twice.
 	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
 	response = postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
 	// Confirm only one row exists for this card.
 	row := db.Connection().QueryRow(
@@ -142,7 +216,7 @@ func TestImportCardsHandler_DuplicateCards_SkipsExisting(t *testing.T) {
 }
 
 func TestImportCardsHandler_DuplicateRowsInSameCSV_InsertedOnce(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -152,12 +226,12 @@ func TestImportCardsHandler_DuplicateRowsInSameCSV_InsertedOnce(t *testing.T) {
 	defer imageServer.Close()
 
 	csv := validCSVHeader + "\n" +
-		"LAW,001,Chewbacca,Hero of Kessel,Character,Heroism,Normal,Rare,false,,Artist One,0,0\n" +
-		"LAW,001,Chewbacca,Hero of Kessel,Character,Heroism,Normal,Rare,false,,Artist One,0,0"
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
 
 	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
 
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
 	row := db.Connection().QueryRow(
 		"SELECT COUNT(*) FROM cards WHERE name = ?",
@@ -169,7 +243,7 @@ func TestImportCardsHandler_DuplicateRowsInSameCSV_InsertedOnce(t *testing.T) {
 }
 
 func TestImportCardsHandler_EmptyCardTitle_UsesCardNameOnly(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -179,11 +253,11 @@ func TestImportCardsHandler_EmptyCardTitle_UsesCardNameOnly(t *testing.T) {
 	defer imageServer.Close()
 
 	csv := validCSVHeader + "\n" +
-		"LAW,001,Chewbacca,,Character,Heroism,Normal,Rare,false,,Artist One,0,0"
+		"LAW,001,Chewbacca,,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
 
 	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
 
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
 	exists, err := db.CardExistsByName("Chewbacca")
 	require.NoError(t, err)
@@ -191,20 +265,22 @@ func TestImportCardsHandler_EmptyCardTitle_UsesCardNameOnly(t *testing.T) {
 }
 
 func TestImportCardsHandler_MalformedCSV_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
+	failures := metrics.ImportFailures.Value("invalid")
 
 	response := postImport(t, db, http.DefaultClient, imagesDir, "", "this is not a valid csv\x00\xff")
 
 	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, failures+1, metrics.ImportFailures.Value("invalid"), "expected the failed import to be counted")
 }
 
 func TestImportCardsHandler_WrongHeaderFormat_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	csv := "Wrong,Header,Format\n" +
-		"LAW,001,Chewbacca,Hero of Kessel,Character,Heroism,Normal,Rare,false,,Artist One,0,0"
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
 
 	response := postImport(t, db, http.DefaultClient, imagesDir, "", csv)
 
@@ -212,7 +288,7 @@ func TestImportCardsHandler_WrongHeaderFormat_Returns400(t *testing.T) {
 }
 
 func TestImportCardsHandler_UTF8BOMPrefix_ParsesSuccessfully(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -224,11 +300,11 @@ func TestImportCardsHandler_UTF8BOMPrefix_ParsesSuccessfully(t *testing.T) {
 	// Prepend a UTF-8 BOM as Excel and similar tools do when exporting CSVs.
 	bom := "\xEF\xBB\xBF"
 	csv := bom + validCSVHeader + "\n" +
-		"LAW,001,Chewbacca,Hero of Kessel,Character,Heroism,Normal,Rare,false,,Artist One,0,0"
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
 
 	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
 
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
 	exists, err := db.CardExistsByName("Chewbacca, Hero of Kessel")
 	require.NoError(t, err)
@@ -236,7 +312,7 @@ func TestImportCardsHandler_UTF8BOMPrefix_ParsesSuccessfully(t *testing.T) {
 }
 
 func TestImportCardsHandler_EmptyBody_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	response := postImport(t, db, http.DefaultClient, imagesDir, "", "")
@@ -245,7 +321,7 @@ func TestImportCardsHandler_EmptyBody_Returns400(t *testing.T) {
 }
 
 func TestImportCardsHandler_CSVWithNoDataRows_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	response := postImport(t, db, http.DefaultClient, imagesDir, "", validCSVHeader)
@@ -254,7 +330,7 @@ func TestImportCardsHandler_CSVWithNoDataRows_Returns400(t *testing.T) {
 }
 
 func TestImportCardsHandler_ValidCSV_DownloadsAndSavesImage(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -264,11 +340,11 @@ func TestImportCardsHandler_ValidCSV_DownloadsAndSavesImage(t *testing.T) {
 	defer imageServer.Close()
 
 	csv := validCSVHeader + "\n" +
-		"LAW,001,Chewbacca,Hero of Kessel,Character,Heroism,Normal,Rare,false,,Artist One,0,0"
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
 
 	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
 
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
 	expectedFilePath := filepath.Join(imagesDir, "LAW001.png")
 	_, err := os.Stat(expectedFilePath)
@@ -284,7 +360,7 @@ func TestImportCardsHandler_ValidCSV_DownloadsAndSavesImage(t *testing.T) {
 }
 
 func TestImportCardsHandler_ImageDownloadFails_InsertsCardWithoutImage(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	// Server always returns 404.
@@ -294,11 +370,11 @@ func TestImportCardsHandler_ImageDownloadFails_InsertsCardWithoutImage(t *testin
 	defer imageServer.Close()
 
 	csv := validCSVHeader + "\n" +
-		"LAW,001,Chewbacca,Hero of Kessel,Character,Heroism,Normal,Rare,false,,Artist One,0,0"
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
 
 	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
 
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
 	// Card must still be inserted despite the download failure.
 	exists, err := db.CardExistsByName("Chewbacca, Hero of Kessel")
@@ -315,8 +391,147 @@ func TestImportCardsHandler_ImageDownloadFails_InsertsCardWithoutImage(t *testin
 	assert.False(t, image.Valid, "expected NULL image field when download fails")
 }
 
+func TestImportCardsHandler_ImageHostDown_StopsDownloadingAndFlagsCards(t *testing.T) {
+	db := testdb.New(t)
+
+	// Retry once per card so the test does not wait for backoff.
+	defaultPolicy := images.DefaultRetryPolicy
+	images.DefaultRetryPolicy = images.RetryPolicy{Attempts: 1}
+	t.Cleanup(func() { images.DefaultRetryPolicy = defaultPolicy })
+
+	var requests atomic.Int32
+	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer imageServer.Close()
+
+	var body strings.Builder
+	body.WriteString(validCSVHeader)
+	for number := 1; number <= images.DefaultBreakerThreshold+3; number++ {
+		fmt.Fprintf(&body, "\nLAW,%03d,Card %d,,Unit,Heroism,Normal,Rare,false,,Artist,0,0", number, number)
+	}
+
+	response := postImport(t, db, imageServer.Client(), t.TempDir(), imageServer.URL, body.String())
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, int32(images.DefaultBreakerThreshold), requests.Load(), "expected downloads to stop once the breaker tripped")
+
+	failed, err := db.GetImageFailedCards()
+	require.NoError(t, err)
+	assert.Len(t, failed, images.DefaultBreakerThreshold+3, "expected every card to be left to the retry job")
+}
+
+func TestImportCardsHandler_FirstImageSourceFails_UsesNextAndRecordsIt(t *testing.T) {
+	db := testdb.New(t)
+	imagesDir := t.TempDir()
+
+	brokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer brokenServer.Close()
+
+	mirrorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fake-png-data"))
+	}))
+	defer mirrorServer.Close()
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/cards/import", strings.NewReader(csv))
+	cards.ImportCardsHandler(db, cards.NewImportLock(), http.DefaultClient, imagesDir, []string{brokenServer.URL, mirrorServer.URL})(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	card, err := db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(imagesDir, "LAW001.png"), card.Image)
+	assert.Equal(t, mirrorServer.URL, card.ImageSource)
+	assert.False(t, card.ImageFailed)
+}
+
+func TestImportCardsHandler_ImageSourceTemplate_UsesVariantAndFoil(t *testing.T) {
+	db := testdb.New(t)
+
+	var requested string
+	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		w.Write([]byte("fake-png-data"))
+	}))
+	defer imageServer.Close()
+
+	csv := validCSVHeader + "\n" +
+		"LAW,301,Chewbacca,Hero of Kessel,Unit,Heroism,Hyperspace,Rare,true,,Artist One,0,0"
+
+	response := postImport(t, db, imageServer.Client(), t.TempDir(), imageServer.URL+"/{set}/{variant}/{number}-{foil}.png", csv)
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "/LAW/hyperspace/301-foil.png", requested)
+}
+
+func TestImportCardsHandler_Leader_DownloadsBackImage(t *testing.T) {
+	db := testdb.New(t)
+	imagesDir := t.TempDir()
+
+	var requested []string
+	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		w.Write([]byte("fake-png-data"))
+	}))
+	defer imageServer.Close()
+
+	csv := validCSVHeader + "\n" +
+		"TWI,013,Mace Windu,Vaapad Form Master,Leader,Aggression|Heroism,Normal,Rare,false,,Artist One,0,0\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist Two,0,0"
+
+	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.ElementsMatch(t, []string{"/TWI/013.png", "/TWI/013-back.png", "/LAW/001.png"}, requested)
+
+	leader, err := db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(imagesDir, "TWI013-back.png"), leader.BackImage)
+
+	unit, err := db.GetCardByID(2)
+	require.NoError(t, err)
+	assert.Empty(t, unit.BackImage)
+}
+
+func TestSearchCardsHTMLHandler_DoubleSidedCard_HasFlipControl(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Mace Windu, Vaapad Form Master", Image: "images/TWI013.png", BackImage: "images/TWI013-back.png"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel", Image: "images/LAW001.png"}))
+
+	response := searchCardsHTML(t, db, newTestTemplates(t), "")
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "/images/TWI013-back.png")
+	assert.Equal(t, 1, strings.Count(string(body), "flip-btn"), "expected only the leader to have a flip control")
+}
+
+func TestSearchCardsHTMLHandler_RulesTextMatch_HighlightsSnippet(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCards([]models.NewCard{{Name: "Cantina Braggart"}, {Name: "Battlefield Marine"}}))
+	_, err := db.SetCardAttributes(map[string]models.CardAttributes{"Cantina Braggart": {Text: "Ambush"}})
+	require.NoError(t, err)
+
+	response := searchCardsHTML(t, db, newTestTemplates(t), "ambush")
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "Cantina Braggart")
+	assert.Contains(t, string(body), "<mark>Ambush</mark>")
+	assert.NotContains(t, string(body), "Battlefield Marine")
+}
+
 func TestImportCardsHandler_ImageAlreadyExists_SkipsDownload(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	// Pre-create the image file so it already exists on disk.
@@ -333,11 +548,11 @@ func TestImportCardsHandler_ImageAlreadyExists_SkipsDownload(t *testing.T) {
 	defer imageServer.Close()
 
 	csv := validCSVHeader + "\n" +
-		"LAW,001,Chewbacca,Hero of Kessel,Character,Heroism,Normal,Rare,false,,Artist One,0,0"
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
 
 	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
 
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 	assert.Equal(t, 0, requestCount, "expected no download requests when image file already exists")
 
 	// The existing file must not have been overwritten.
@@ -356,7 +571,7 @@ func TestImportCardsHandler_ImageAlreadyExists_SkipsDownload(t *testing.T) {
 }
 
 func TestImportCardsHandler_UnitCard_StoresMainboardTrue(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -370,7 +585,7 @@ func TestImportCardsHandler_UnitCard_StoresMainboardTrue(t *testing.T) {
 
 	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
 
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
 	row := db.Connection().QueryRow(
 		"SELECT mainboard FROM cards WHERE name = ?",
@@ -382,7 +597,7 @@ func TestImportCardsHandler_UnitCard_StoresMainboardTrue(t *testing.T) {
 }
 
 func TestImportCardsHandler_LeaderCard_StoresMainboardFalse(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -396,7 +611,7 @@ func TestImportCardsHandler_LeaderCard_StoresMainboardFalse(t *testing.T) {
 
 	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
 
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
 	row := db.Connection().QueryRow(
 		"SELECT mainboard FROM cards WHERE name = ?",
@@ -408,7 +623,7 @@ func TestImportCardsHandler_LeaderCard_StoresMainboardFalse(t *testing.T) {
 }
 
 func TestImportCardsHandler_BaseCard_StoresMainboardFalse(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -422,7 +637,7 @@ func TestImportCardsHandler_BaseCard_StoresMainboardFalse(t *testing.T) {
 
 	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
 
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
 	row := db.Connection().QueryRow(
 		"SELECT mainboard FROM cards WHERE name = ?",
@@ -434,7 +649,7 @@ func TestImportCardsHandler_BaseCard_StoresMainboardFalse(t *testing.T) {
 }
 
 func TestImportCardsHandler_LeaderCaseInsensitive_StoresMainboardFalse(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -449,7 +664,7 @@ func TestImportCardsHandler_LeaderCaseInsensitive_StoresMainboardFalse(t *testin
 
 	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
 
-	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
 	row := db.Connection().QueryRow(
 		"SELECT mainboard FROM cards WHERE name = ?",
@@ -475,7 +690,7 @@ func getCard(t *testing.T, db *database.Database, rawID string) *http.Response {
 }
 
 func TestGetCardHandler_ExistingCard_Returns200WithJSON(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	result, err := db.Connection().Exec(
 		"INSERT INTO cards (name, image, owned) VALUES (?, ?, ?)",
@@ -500,7 +715,7 @@ func TestGetCardHandler_ExistingCard_Returns200WithJSON(t *testing.T) {
 }
 
 func TestGetCardHandler_NullImage_Returns200WithEmptyImageField(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	result, err := db.Connection().Exec(
 		"INSERT INTO cards (name, owned) VALUES (?, ?)",
@@ -520,7 +735,7 @@ func TestGetCardHandler_NullImage_Returns200WithEmptyImageField(t *testing.T) {
 }
 
 func TestGetCardHandler_NonExistentID_Returns404(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	response := getCard(t, db, "99999")
 
@@ -528,7 +743,7 @@ func TestGetCardHandler_NonExistentID_Returns404(t *testing.T) {
 }
 
 func TestGetCardHandler_NonIntegerID_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	response := getCard(t, db, "abc")
 
@@ -536,7 +751,7 @@ func TestGetCardHandler_NonIntegerID_Returns400(t *testing.T) {
 }
 
 func TestGetCardHandler_ZeroID_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	response := getCard(t, db, "0")
 
@@ -544,7 +759,7 @@ func TestGetCardHandler_ZeroID_Returns400(t *testing.T) {
 }
 
 func TestGetCardHandler_NegativeID_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	response := getCard(t, db, "-1")
 
@@ -580,7 +795,7 @@ func decrementCardOwned(t *testing.T, db *database.Database, rawID string) *http
 }
 
 func TestIncrementCardOwnedHandler_ExistingCard_Returns204AndIncrementsOwned(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	result, err := db.Connection().Exec(
 		"INSERT INTO cards (name, owned) VALUES (?, ?)",
@@ -600,8 +815,37 @@ func TestIncrementCardOwnedHandler_ExistingCard_Returns204AndIncrementsOwned(t *
 	assert.Equal(t, 3, owned)
 }
 
+func TestIncrementCardOwnedHandler_RecordsAuditEventWithTokenUser(t *testing.T) {
+	db := testdb.New(t)
+	t.Cleanup(audit.Subscribe(db))
+
+	result, err := db.Connection().Exec("INSERT INTO cards (name, owned) VALUES (?, ?)", "Luke Skywalker, Jedi Knight", 2)
+	require.NoError(t, err)
+	insertedID, err := result.LastInsertId()
+	require.NoError(t, err)
+	_, secret, err := db.CreateAPIToken("phone", []string{models.ScopeWrite}, models.RoleEditor)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /cards/{id}/increment", cards.IncrementCardOwnedHandler(db))
+	request := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/cards/%d/increment", insertedID), nil)
+	request.Header.Set("Authorization", "Bearer "+secret)
+	recorder := httptest.NewRecorder()
+	apitokens.Middleware(db, roles.Middleware(db, models.RoleViewer, mux)).ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNoContent, recorder.Code, recorder.Body.String())
+
+	events, err := db.GetAuditEvents(database.AuditFilter{}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "phone", events[0].User)
+	assert.Equal(t, models.AuditIncrement, events[0].Action)
+	assert.Equal(t, int(insertedID), events[0].CardID)
+	assert.Equal(t, "Luke Skywalker, Jedi Knight", events[0].CardName)
+	assert.Equal(t, "owned 3", events[0].Detail)
+}
+
 func TestIncrementCardOwnedHandler_NonExistentID_Returns404(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	response := incrementCardOwned(t, db, "99999")
 
@@ -609,7 +853,7 @@ func TestIncrementCardOwnedHandler_NonExistentID_Returns404(t *testing.T) {
 }
 
 func TestIncrementCardOwnedHandler_NonIntegerID_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	response := incrementCardOwned(t, db, "abc")
 
@@ -617,7 +861,7 @@ func TestIncrementCardOwnedHandler_NonIntegerID_Returns400(t *testing.T) {
 }
 
 func TestIncrementCardOwnedHandler_ZeroID_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	response := incrementCardOwned(t, db, "0")
 
@@ -625,7 +869,7 @@ func TestIncrementCardOwnedHandler_ZeroID_Returns400(t *testing.T) {
 }
 
 func TestIncrementCardOwnedHandler_NegativeID_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	response := incrementCardOwned(t, db, "-1")
 
@@ -633,7 +877,7 @@ func TestIncrementCardOwnedHandler_NegativeID_Returns400(t *testing.T) {
 }
 
 func TestDecrementCardOwnedHandler_ExistingCardWithPositiveOwned_Returns204AndDecrementsOwned(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	result, err := db.Connection().Exec(
 		"INSERT INTO cards (name, owned) VALUES (?, ?)",
@@ -654,7 +898,7 @@ func TestDecrementCardOwnedHandler_ExistingCardWithPositiveOwned_Returns204AndDe
 }
 
 func TestDecrementCardOwnedHandler_ExistingCardWithZeroOwned_Returns204AndKeepsAtZero(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	result, err := db.Connection().Exec(
 		"INSERT INTO cards (name, owned) VALUES (?, ?)",
@@ -674,8 +918,31 @@ func TestDecrementCardOwnedHandler_ExistingCardWithZeroOwned_Returns204AndKeepsA
 	assert.Equal(t, 0, owned)
 }
 
+func TestDecrementCardOwnedHandler_BelowFloor_Returns409UntilConfirmed(t *testing.T) {
+	db := testdb.New(t)
+	settings := db.Settings()
+	settings.DecrementFloor = 3
+	require.NoError(t, db.SaveSettings(settings))
+
+	_, err := db.Connection().Exec("INSERT INTO cards (name, owned) VALUES (?, ?)", "Chewbacca, Hero of Kessel", 3)
+	require.NoError(t, err)
+
+	response := decrementCardOwned(t, db, "1")
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/1/decrement?confirm=true", nil)
+	request.SetPathValue("id", "1")
+	recorder := httptest.NewRecorder()
+	cards.DecrementCardOwnedHandler(db)(recorder, request)
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	card, err := db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, card.Owned)
+}
+
 func TestDecrementCardOwnedHandler_NonExistentID_Returns404(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	response := decrementCardOwned(t, db, "99999")
 
@@ -683,7 +950,7 @@ func TestDecrementCardOwnedHandler_NonExistentID_Returns404(t *testing.T) {
 }
 
 func TestDecrementCardOwnedHandler_NonIntegerID_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	response := decrementCardOwned(t, db, "abc")
 
@@ -691,7 +958,7 @@ func TestDecrementCardOwnedHandler_NonIntegerID_Returns400(t *testing.T) {
 }
 
 func TestDecrementCardOwnedHandler_ZeroID_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	response := decrementCardOwned(t, db, "0")
 
@@ -699,7 +966,7 @@ func TestDecrementCardOwnedHandler_ZeroID_Returns400(t *testing.T) {
 }
 
 func TestDecrementCardOwnedHandler_NegativeID_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	response := decrementCardOwned(t, db, "-1")
 
@@ -725,7 +992,7 @@ func searchCards(t *testing.T, db *database.Database, query string) *http.Respon
 }
 
 func TestSearchCardsHandler_EmptyDatabase_NoQuery_Returns200WithEmptyArray(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	response := searchCards(t, db, "")
 
@@ -738,7 +1005,7 @@ func TestSearchCardsHandler_EmptyDatabase_NoQuery_Returns200WithEmptyArray(t *te
 }
 
 func TestSearchCardsHandler_NoQuery_Returns200WithAllCards(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	_, err := db.Connection().Exec(
 		"INSERT INTO cards (name, owned) VALUES (?, ?), (?, ?)",
@@ -758,7 +1025,7 @@ func TestSearchCardsHandler_NoQuery_Returns200WithAllCards(t *testing.T) {
 }
 
 func TestSearchCardsHandler_PartialQuery_Returns200WithMatchingCards(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	_, err := db.Connection().Exec(
 		"INSERT INTO cards (name, owned) VALUES (?, ?), (?, ?), (?, ?)",
@@ -781,8 +1048,59 @@ func TestSearchCardsHandler_PartialQuery_Returns200WithMatchingCards(t *testing.
 	}
 }
 
+func TestSearchCardsHandler_QuerySyntax_FiltersByOwnedCount(t *testing.T) {
+	db := testdb.New(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?), (?, ?)",
+		"Luke Skywalker, Jedi Knight", 0,
+		"Luke Skywalker, Rebel Hero", 2,
+	)
+	require.NoError(t, err)
+
+	response := searchCards(t, db, url.QueryEscape("luke owned>0"))
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	var result []models.Card
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	require.Len(t, result, 1)
+	assert.Equal(t, "Luke Skywalker, Rebel Hero", result[0].Name)
+}
+
+func TestSearchCardsHandler_SortBySetNumber_OrdersNumerically(t *testing.T) {
+	db := testdb.New(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, set_code, card_number) VALUES (?, ?, ?), (?, ?, ?)",
+		"Darth Vader, Dark Lord of the Sith", "SOR", "10",
+		"Luke Skywalker, Faithful Friend", "SOR", "9",
+	)
+	require.NoError(t, err)
+
+	response := searchCards(t, db, "&sort=set_number")
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	var result []models.Card
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	require.Len(t, result, 2)
+	assert.Equal(t, "Luke Skywalker, Faithful Friend", result[0].Name)
+
+	assert.Equal(t, http.StatusBadRequest, searchCards(t, db, "&sort=price").StatusCode)
+}
+
+func TestSearchCardsHandler_InvalidQuery_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	response := searchCards(t, db, url.QueryEscape("colour:red"))
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `unknown field "colour"`)
+}
+
 func TestSearchCardsHandler_QueryWithNoMatch_Returns200WithEmptyArray(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	_, err := db.Connection().Exec(
 		"INSERT INTO cards (name, owned) VALUES (?, ?)",
@@ -802,10 +1120,10 @@ func TestSearchCardsHandler_QueryWithNoMatch_Returns200WithEmptyArray(t *testing
 
 // newTestTemplates loads the application HTML templates relative to this
 // test file's location in the cards/ package directory.
-func newTestTemplates(t *testing.T) *template.Template {
+func newTestTemplates(t testing.TB) *template.Template {
 	t.Helper()
 
-	tmpl, err := template.ParseGlob("../templates/*.html")
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
 	require.NoError(t, err, "expected no error loading test templates")
 
 	return tmpl
@@ -831,7 +1149,7 @@ func postImportHTML(t *testing.T, db *database.Database, httpClient *http.Client
 	request.Header.Set("Content-Type", writer.FormDataContentType())
 	recorder := httptest.NewRecorder()
 
-	cards.ImportCardsHTMLHandler(db, httpClient, imagesDir, imageBaseURL)(recorder, request)
+	cards.ImportCardsHTMLHandler(db, cards.NewImportLock(), httpClient, imagesDir, []string{imageBaseURL}, newTestTemplates(t))(recorder, request)
 
 	return recorder.Result()
 }
@@ -854,6 +1172,42 @@ func searchCardsHTML(t *testing.T, db *database.Database, tmpl *template.Templat
 	return recorder.Result()
 }
 
+func TestSearchCardsHTMLHandler_ItemsPerPage_PaginatesWithLoadMore(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	for _, name := range []string{"Chewbacca, Walking Carpet", "Anakin Skywalker, What It Takes", "Boba Fett, Daimyo"} {
+		require.NoError(t, db.InsertCard(models.NewCard{Name: name, Mainboard: true}))
+	}
+	settings := database.DefaultSettings()
+	settings.ItemsPerPage = 2
+	require.NoError(t, db.SaveSettings(settings))
+
+	response := searchCardsHTML(t, db, tmpl, "")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "Anakin Skywalker")
+	assert.Contains(t, string(body), "Boba Fett")
+	assert.NotContains(t, string(body), "Chewbacca")
+	assert.Contains(t, string(body), `hx-get="/cards/search/html?page=2&amp;q="`)
+
+	recorder := httptest.NewRecorder()
+	cards.SearchCardsHTMLHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/cards/search/html?page=2", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Chewbacca")
+	assert.NotContains(t, recorder.Body.String(), "Load more")
+}
+
+func TestSearchCardsHTMLHandler_InvalidPage_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := httptest.NewRecorder()
+	cards.SearchCardsHTMLHandler(db, newTestTemplates(t))(recorder, httptest.NewRequest(http.MethodGet, "/cards/search/html?page=0", nil))
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
 // incrementCardOwnedHTML sends a POST request to IncrementCardOwnedHTMLHandler
 // for the given raw id string.
 func incrementCardOwnedHTML(t *testing.T, db *database.Database, tmpl *template.Template, rawID string) *http.Response {
@@ -885,7 +1239,7 @@ func decrementCardOwnedHTML(t *testing.T, db *database.Database, tmpl *template.
 }
 
 func TestIndexHandler_Returns200WithHTMLPage(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	request := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -903,8 +1257,73 @@ func TestIndexHandler_Returns200WithHTMLPage(t *testing.T) {
 	assert.Contains(t, string(body), "SWU Collection")
 }
 
+func TestIndexHandler_Viewer_HidesEditingControls(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	recorder := httptest.NewRecorder()
+	roles.Middleware(db, models.RoleViewer, cards.IndexHandler(db, tmpl)).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), ".role-editor, .role-admin {")
+	assert.Contains(t, recorder.Body.String(), `class="import-btn role-admin"`)
+
+	adminRecorder := httptest.NewRecorder()
+	cards.IndexHandler(db, tmpl)(adminRecorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.NotContains(t, adminRecorder.Body.String(), ".role-editor, .role-admin {")
+}
+
+func TestIndexHandler_AcceptLanguage_RendersLocalizedUI(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Accept-Language", "de-DE,de;q=0.9,en;q=0.8")
+	recorder := httptest.NewRecorder()
+
+	cards.IndexHandler(db, tmpl)(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "de", recorder.Header().Get("Content-Language"))
+	assert.Contains(t, recorder.Body.String(), `<html lang="de">`)
+	assert.Contains(t, recorder.Body.String(), ">Wunschliste</a>")
+	assert.Contains(t, recorder.Body.String(), "Karten suchen...")
+}
+
+func TestIndexHandler_QueryAndHTMLOnly_RendersPlainPage(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?), (?, ?)",
+		"Luke Skywalker, Jedi Knight", 0,
+		"Chewbacca, Hero of Kessel", 0,
+	)
+	require.NoError(t, err)
+
+	settings := db.Settings()
+	settings.HTMLOnly = true
+	require.NoError(t, db.SaveSettings(settings))
+
+	recorder := httptest.NewRecorder()
+	cards.IndexHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/?q=luke", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, "Luke Skywalker, Jedi Knight")
+	assert.NotContains(t, body, "Chewbacca, Hero of Kessel")
+	assert.Contains(t, body, `value="luke"`)
+	assert.NotContains(t, body, "htmx.min.js")
+	assert.Contains(t, body, `formaction="/cards/`)
+
+	recorder = httptest.NewRecorder()
+	cards.IndexHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/?page=zero", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
 func TestIndexHandler_WithCards_RendersCardNames(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	_, err := db.Connection().Exec(
@@ -929,7 +1348,7 @@ func TestIndexHandler_WithCards_RendersCardNames(t *testing.T) {
 }
 
 func TestSearchCardsHTMLHandler_NoQuery_Returns200WithAllCards(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	_, err := db.Connection().Exec(
@@ -951,7 +1370,7 @@ func TestSearchCardsHTMLHandler_NoQuery_Returns200WithAllCards(t *testing.T) {
 }
 
 func TestSearchCardsHTMLHandler_WithQuery_ReturnsFilteredCards(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	_, err := db.Connection().Exec(
@@ -975,7 +1394,7 @@ func TestSearchCardsHTMLHandler_WithQuery_ReturnsFilteredCards(t *testing.T) {
 }
 
 func TestSearchCardsHTMLHandler_EmptyDatabase_ReturnsNoCardsMessage(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	response := searchCardsHTML(t, db, tmpl, "")
@@ -988,7 +1407,7 @@ func TestSearchCardsHTMLHandler_EmptyDatabase_ReturnsNoCardsMessage(t *testing.T
 }
 
 func TestImportCardsHTMLHandler_ValidCSV_Returns200WithHXTriggerHeader(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -998,7 +1417,7 @@ func TestImportCardsHTMLHandler_ValidCSV_Returns200WithHXTriggerHeader(t *testin
 	defer imageServer.Close()
 
 	csvContent := validCSVHeader + "\n" +
-		"LAW,001,Chewbacca,Hero of Kessel,Character,Heroism,Normal,Rare,false,,Artist One,0,0"
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
 
 	response := postImportHTML(t, db, imageServer.Client(), imagesDir, imageServer.URL, csvContent)
 
@@ -1008,10 +1427,14 @@ func TestImportCardsHTMLHandler_ValidCSV_Returns200WithHXTriggerHeader(t *testin
 	exists, err := db.CardExistsByName("Chewbacca, Hero of Kessel")
 	require.NoError(t, err)
 	assert.True(t, exists, "expected card to be inserted")
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "Import complete")
 }
 
 func TestImportCardsHTMLHandler_MalformedCSV_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	imagesDir := t.TempDir()
 
 	response := postImportHTML(t, db, http.DefaultClient, imagesDir, "", "this is not valid csv")
@@ -1020,14 +1443,14 @@ func TestImportCardsHTMLHandler_MalformedCSV_Returns400(t *testing.T) {
 }
 
 func TestImportCardsHTMLHandler_MissingFileField_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 
 	// Send a multipart form with a different field name (not "file").
 	var body bytes.Buffer
 	writer := multipart.NewWriter(&body)
 	part, err := writer.CreateFormFile("wrong-field", "cards.csv")
 	require.NoError(t, err)
-	_, err = io.WriteString(part, validCSVHeader+"\nLAW,001,Chewbacca,,Character,Heroism,Normal,Rare,false,,A,0,0")
+	_, err = io.WriteString(part, validCSVHeader+"\nLAW,001,Chewbacca,,Unit,Heroism,Normal,Rare,false,,A,0,0")
 	require.NoError(t, err)
 	require.NoError(t, writer.Close())
 
@@ -1035,13 +1458,13 @@ func TestImportCardsHTMLHandler_MissingFileField_Returns400(t *testing.T) {
 	request.Header.Set("Content-Type", writer.FormDataContentType())
 	recorder := httptest.NewRecorder()
 
-	cards.ImportCardsHTMLHandler(db, http.DefaultClient, t.TempDir(), "")(recorder, request)
+	cards.ImportCardsHTMLHandler(db, cards.NewImportLock(), http.DefaultClient, t.TempDir(), nil, newTestTemplates(t))(recorder, request)
 
 	assert.Equal(t, http.StatusBadRequest, recorder.Result().StatusCode)
 }
 
 func TestIncrementCardOwnedHTMLHandler_ExistingCard_Returns200WithUpdatedFragment(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	result, err := db.Connection().Exec(
@@ -1064,8 +1487,32 @@ func TestIncrementCardOwnedHTMLHandler_ExistingCard_Returns200WithUpdatedFragmen
 	assert.Contains(t, bodyStr, fmt.Sprintf("id=\"owned-%d\"", insertedID))
 }
 
+func TestIncrementCardOwnedHTMLHandler_ReachingMinimum_ShowsPlaysetBadge(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, card_type) VALUES (?, ?, ?)",
+		"Chewbacca, Walking Carpet", database.LeaderMinimumOwned-1, "Leader",
+	)
+	require.NoError(t, err)
+
+	response := incrementCardOwnedHTML(t, db, tmpl, "1")
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `class="playset-badge"`)
+
+	response = decrementCardOwnedHTML(t, db, tmpl, "1")
+
+	body, err = io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), `class="playset-badge"`)
+}
+
 func TestIncrementCardOwnedHTMLHandler_NonExistentID_Returns404(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	response := incrementCardOwnedHTML(t, db, tmpl, "99999")
@@ -1074,7 +1521,7 @@ func TestIncrementCardOwnedHTMLHandler_NonExistentID_Returns404(t *testing.T) {
 }
 
 func TestIncrementCardOwnedHTMLHandler_NonIntegerID_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	response := incrementCardOwnedHTML(t, db, tmpl, "abc")
@@ -1083,7 +1530,7 @@ func TestIncrementCardOwnedHTMLHandler_NonIntegerID_Returns400(t *testing.T) {
 }
 
 func TestIncrementCardOwnedHTMLHandler_ZeroID_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	response := incrementCardOwnedHTML(t, db, tmpl, "0")
@@ -1092,7 +1539,7 @@ func TestIncrementCardOwnedHTMLHandler_ZeroID_Returns400(t *testing.T) {
 }
 
 func TestDecrementCardOwnedHTMLHandler_PositiveOwned_Returns200WithDecrementedCount(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	result, err := db.Connection().Exec(
@@ -1114,7 +1561,7 @@ func TestDecrementCardOwnedHTMLHandler_PositiveOwned_Returns200WithDecrementedCo
 }
 
 func TestDecrementCardOwnedHTMLHandler_ZeroOwned_Returns200WithZeroCount(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	result, err := db.Connection().Exec(
@@ -1134,17 +1581,50 @@ func TestDecrementCardOwnedHTMLHandler_ZeroOwned_Returns200WithZeroCount(t *test
 	assert.Contains(t, string(body), "Owned: 0")
 }
 
-func TestDecrementCardOwnedHTMLHandler_NonExistentID_Returns404(t *testing.T) {
-	db := newTestDatabase(t)
+func TestDecrementCardOwnedHTMLHandler_BelowFloor_AsksForConfirmation(t *testing.T) {
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
+	settings := db.Settings()
+	settings.DecrementFloor = 2
+	require.NoError(t, db.SaveSettings(settings))
 
-	response := decrementCardOwnedHTML(t, db, tmpl, "99999")
+	_, err := db.Connection().Exec("INSERT INTO cards (name, owned) VALUES (?, ?)", "Chewbacca, Hero of Kessel", 2)
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/1/decrement/html", nil)
+	request.Header.Set("HX-Request", "true")
+	request.SetPathValue("id", "1")
+	recorder := httptest.NewRecorder()
+	cards.DecrementCardOwnedHTMLHandler(db, tmpl)(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Owned: 2")
+	assert.Contains(t, recorder.Body.String(), `hx-post="/cards/1/decrement/html?confirm=true"`)
+
+	assert.Equal(t, http.StatusConflict, decrementCardOwnedHTML(t, db, tmpl, "1").StatusCode, "expected requests without htmx to get an error page")
+
+	request = httptest.NewRequest(http.MethodPost, "/cards/1/decrement/html?confirm=true", nil)
+	request.Header.Set("HX-Request", "true")
+	request.SetPathValue("id", "1")
+	recorder = httptest.NewRecorder()
+	cards.DecrementCardOwnedHTMLHandler(db, tmpl)(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Owned: 1")
+	assert.NotContains(t, recorder.Body.String(), "confirm=true")
+}
+
+func TestDecrementCardOwnedHTMLHandler_NonExistentID_Returns404(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	response := decrementCardOwnedHTML(t, db, tmpl, "99999")
 
 	assert.Equal(t, http.StatusNotFound, response.StatusCode)
 }
 
 func TestDecrementCardOwnedHTMLHandler_NonIntegerID_Returns400(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	response := decrementCardOwnedHTML(t, db, tmpl, "abc")
@@ -1183,7 +1663,7 @@ func searchWishlistHTML(t *testing.T, db *database.Database, tmpl *template.Temp
 }
 
 func TestWishlistHandler_Returns200WithHTMLPage(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	response := getWishlist(t, db, tmpl)
@@ -1198,7 +1678,7 @@ func TestWishlistHandler_Returns200WithHTMLPage(t *testing.T) {
 }
 
 func TestWishlistHandler_WithCardBelowMinimum_RendersCardNameAndDeficit(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	// Mainboard card with owned=2 should appear with deficit of 4 (6-2=4).
@@ -1217,8 +1697,40 @@ func TestWishlistHandler_WithCardBelowMinimum_RendersCardNameAndDeficit(t *testi
 	assert.Contains(t, bodyStr, "Need: 4 more")
 }
 
+func TestWishlistSnippetHandlers_ListMissingCopies(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, card_type) VALUES (?, ?, ?), (?, ?, ?), (?, ?, ?)",
+		"Darth Vader, Commanding the First Legion", 0, "Event",
+		"Luke Skywalker, Faithful Friend", 4, "Unit",
+		"Han Solo, Reluctant Hero", 6, "Unit",
+	)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	cards.WishlistSnippetHandler(db)(recorder, httptest.NewRequest(http.MethodGet, "/wishlist/snippet", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "3 Darth Vader, Commanding the First Legion, 2 Luke Skywalker, Faithful Friend", recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	cards.WishlistSnippetHTMLHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/wishlist/snippet/html?format=markdown&q=luke", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), ">- 2x Luke Skywalker, Faithful Friend</textarea>")
+	assert.Contains(t, recorder.Body.String(), `<option value="markdown" selected>`)
+	assert.Contains(t, recorder.Body.String(), `name="q" value="luke"`)
+
+	recorder = httptest.NewRecorder()
+	cards.WishlistSnippetHandler(db)(recorder, httptest.NewRequest(http.MethodGet, "/wishlist/snippet?format=csv", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
 func TestWishlistHandler_ExcludesCardsAtMinimum(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	// Mainboard card with owned=6 is at minimum and should not appear.
@@ -1237,7 +1749,7 @@ func TestWishlistHandler_ExcludesCardsAtMinimum(t *testing.T) {
 }
 
 func TestWishlistHandler_EmptyWishlist_ShowsEmptyState(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	response := getWishlist(t, db, tmpl)
@@ -1247,14 +1759,16 @@ func TestWishlistHandler_EmptyWishlist_ShowsEmptyState(t *testing.T) {
 	assert.Contains(t, string(body), "No cards in your wishlist.")
 }
 
-func TestWishlistHandler_NonMainboardCardBelowMinimum_ComputesCorrectDeficit(t *testing.T) {
-	db := newTestDatabase(t)
+func TestWishlistHandler_EventBelowMinimum_ComputesCorrectDeficit(t *testing.T) {
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
-	// Non-mainboard card with owned=1 should have deficit of 2 (3-1=2).
+	// An event with owned=1 should have deficit of 2 (3-1=2), and a leader
+	// with owned=1 is at its minimum.
 	_, err := db.Connection().Exec(
-		"INSERT INTO cards (name, owned, mainboard) VALUES (?, ?, ?)",
-		"Darth Vader, Sith Lord", 1, 0,
+		"INSERT INTO cards (name, owned, mainboard, card_type) VALUES (?, ?, ?, ?), (?, ?, ?, ?)",
+		"Force Choke", 1, 1, "Event",
+		"Darth Vader, Sith Lord", 1, 0, "Leader",
 	)
 	require.NoError(t, err)
 
@@ -1263,12 +1777,13 @@ func TestWishlistHandler_NonMainboardCardBelowMinimum_ComputesCorrectDeficit(t *
 	body, err := io.ReadAll(response.Body)
 	require.NoError(t, err)
 	bodyStr := string(body)
-	assert.Contains(t, bodyStr, "Darth Vader, Sith Lord")
+	assert.Contains(t, bodyStr, "Force Choke")
 	assert.Contains(t, bodyStr, "Need: 2 more")
+	assert.NotContains(t, bodyStr, "Darth Vader, Sith Lord")
 }
 
 func TestSearchWishlistHTMLHandler_EmptyQuery_ReturnsAllWishlistCards(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	_, err := db.Connection().Exec(
@@ -1291,7 +1806,7 @@ func TestSearchWishlistHTMLHandler_EmptyQuery_ReturnsAllWishlistCards(t *testing
 }
 
 func TestSearchWishlistHTMLHandler_WithQuery_FiltersWishlistCards(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	_, err := db.Connection().Exec(
@@ -1313,7 +1828,7 @@ func TestSearchWishlistHTMLHandler_WithQuery_FiltersWishlistCards(t *testing.T)
 }
 
 func TestSearchWishlistHTMLHandler_ExcludesCardsAtMinimum(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.New(t)
 	tmpl := newTestTemplates(t)
 
 	// Card at minimum should never appear in the wishlist, even with no search filter.
@@ -1329,3 +1844,1485 @@ func TestSearchWishlistHTMLHandler_ExcludesCardsAtMinimum(t *testing.T) {
 	require.NoError(t, err)
 	assert.NotContains(t, string(body), "Luke Skywalker, Jedi Knight")
 }
+
+// getRecentCards sends a GET request to RecentCardsHandler with the given
+// kind. Pass an empty kind to omit the "kind" parameter entirely.
+func getRecentCards(t *testing.T, db *database.Database, kind string) *http.Response {
+	t.Helper()
+
+	target := "/cards/recent"
+	if kind != "" {
+		target = fmt.Sprintf("/cards/recent?kind=%s", kind)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	recorder := httptest.NewRecorder()
+
+	cards.RecentCardsHandler(db)(recorder, request)
+
+	return recorder.Result()
+}
+
+func TestRecentCardsHandler_NoKind_Returns200WithRecentlyAddedCards(t *testing.T) {
+	db := testdb.New(t)
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Mainboard: true}))
+
+	response := getRecentCards(t, db, "")
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+
+	var result []models.Card
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	require.Len(t, result, 1)
+	assert.Equal(t, "Luke Skywalker, Jedi Knight", result[0].Name)
+	assert.False(t, result[0].CreatedAt.IsZero(), "expected created_at in response")
+}
+
+func TestRecentCardsHandler_KindChanged_ReturnsOnlyChangedCards(t *testing.T) {
+	db := testdb.New(t)
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Mainboard: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel", Mainboard: true}))
+
+	_, err := db.Connection().Exec(
+		"UPDATE cards SET owned = 1, updated_at = ? WHERE name = ?",
+		"2999-01-01T00:00:00.000000000Z", "Chewbacca, Hero of Kessel",
+	)
+	require.NoError(t, err)
+
+	response := getRecentCards(t, db, "changed")
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	var result []models.Card
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	require.Len(t, result, 1)
+	assert.Equal(t, "Chewbacca, Hero of Kessel", result[0].Name)
+}
+
+func TestRecentCardsHandler_UnknownKind_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	response := getRecentCards(t, db, "deleted")
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestExcessCardsHandler_ReturnsSurplusLargestFirst(t *testing.T) {
+	db := testdb.New(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, card_type, archived) VALUES (?, 8, 'Unit', 0), (?, 7, 'Event', 0), (?, 6, 'Unit', 0), (?, 9, 'Leader', 1)",
+		"Luke Skywalker, Jedi Knight", "Sabine Wren, Explosives Artist", "Chewbacca, Hero of Kessel", "Darth Vader, Dark Lord of the Sith",
+	)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	cards.ExcessCardsHandler(db)(recorder, httptest.NewRequest(http.MethodGet, "/cards/excess", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var result []models.ExcessCard
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	require.Len(t, result, 2, "cards at their threshold and archived cards have no excess")
+	assert.Equal(t, "Sabine Wren, Explosives Artist", result[0].Name)
+	assert.Equal(t, 4, result[0].Surplus)
+	assert.Equal(t, "Luke Skywalker, Jedi Knight", result[1].Name)
+	assert.Equal(t, 2, result[1].Surplus)
+}
+
+func TestBulkUpdateCardsHandler_SetsMainboardOnMatchingCards(t *testing.T) {
+	db := testdb.New(t)
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Faithful Friend"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel"}))
+
+	form := url.Values{"q": {"luke"}, "action": {"set_mainboard"}, "value": {"true"}}
+	request := httptest.NewRequest(http.MethodPost, "/cards/bulk", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	cards.BulkUpdateCardsHandler(db)(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.JSONEq(t, `{"updated": 2}`, recorder.Body.String())
+
+	allCards, err := db.GetAllCards()
+	require.NoError(t, err)
+	for _, card := range allCards {
+		assert.Equal(t, strings.HasPrefix(card.Name, "Luke"), card.Mainboard, card.Name)
+	}
+}
+
+func TestBulkUpdateCardsHandler_InvalidActionOrValue_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	for _, target := range []string{"/cards/bulk?action=delete", "/cards/bulk?action=set_mainboard&value=maybe"} {
+		recorder := httptest.NewRecorder()
+		cards.BulkUpdateCardsHandler(db)(recorder, httptest.NewRequest(http.MethodPost, target, nil))
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, target)
+	}
+}
+
+func TestReplayOwnedHandler_AppliesQueuedOperations(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel"}))
+	allCards, err := db.GetAllCards()
+	require.NoError(t, err)
+	id := allCards[0].ID
+	require.NoError(t, db.IncrementCardOwned(id))
+	require.NoError(t, db.IncrementCardOwned(id))
+
+	body := fmt.Sprintf(`{"operations": [
+		{"id": "op-1", "card_id": %d, "action": "decrement", "base_owned": 2, "made_at": "2024-05-01T12:00:00Z"},
+		{"id": "op-2", "card_id": %d, "action": "decrement", "base_owned": 1, "made_at": "2024-05-01T12:00:05Z"}
+	]}`, id, id)
+	recorder := httptest.NewRecorder()
+	cards.ReplayOwnedHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/cards/owned/sync", strings.NewReader(body)))
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.JSONEq(t, fmt.Sprintf(`{"results": [
+		{"id": "op-1", "card_id": %d, "status": "applied", "owned": 1},
+		{"id": "op-2", "card_id": %d, "status": "applied", "owned": 0}
+	]}`, id, id), recorder.Body.String())
+}
+
+func TestReplayOwnedHandler_RejectsGuardedOperationsUntilConfirmed(t *testing.T) {
+	db := testdb.New(t)
+	settings := db.Settings()
+	settings.DecrementFloor = 2
+	require.NoError(t, db.SaveSettings(settings))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel"}))
+	allCards, err := db.GetAllCards()
+	require.NoError(t, err)
+	id := allCards[0].ID
+	require.NoError(t, db.IncrementCardOwned(id))
+	require.NoError(t, db.IncrementCardOwned(id))
+
+	body := fmt.Sprintf(`{"operations": [{"id": "op-1", "card_id": %d, "action": "decrement", "base_owned": 2}]}`, id)
+	recorder := httptest.NewRecorder()
+	cards.ReplayOwnedHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/cards/owned/sync", strings.NewReader(body)))
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.JSONEq(t, fmt.Sprintf(`{"results": [{"id": "op-1", "card_id": %d, "status": "rejected", "owned": 2}]}`, id), recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	cards.ReplayOwnedHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/cards/owned/sync?confirm=true", strings.NewReader(body)))
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.JSONEq(t, fmt.Sprintf(`{"results": [{"id": "op-1", "card_id": %d, "status": "applied", "owned": 1}]}`, id), recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	cards.ReplayOwnedHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/cards/owned/sync?confirm=maybe", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestReplayOwnedHandler_InvalidBody_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := httptest.NewRecorder()
+	cards.ReplayOwnedHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/cards/owned/sync", strings.NewReader("{")))
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestRecentCardsHTMLHandler_RendersRecentActivity(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Mainboard: true}))
+
+	request := httptest.NewRequest(http.MethodGet, "/cards/recent/html", nil)
+	recorder := httptest.NewRecorder()
+
+	cards.RecentCardsHTMLHandler(db, tmpl)(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Contains(t, response.Header.Get("Content-Type"), "text/html")
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "Recently added")
+	assert.Contains(t, string(body), "Luke Skywalker, Jedi Knight")
+	assert.Contains(t, string(body), "No changes yet.")
+}
+
+func TestIndexHandler_RendersRecentActivitySection(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Mainboard: true}))
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	recorder := httptest.NewRecorder()
+
+	cards.IndexHandler(db, tmpl)(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "Recent activity")
+	assert.Contains(t, string(body), "Recently added")
+}
+
+// postCardAction sends a POST request to handler with the given raw id set
+// as the "id" path value.
+func postCardAction(t *testing.T, handler http.HandlerFunc, target, rawID string) *http.Response {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, target, nil)
+	request.SetPathValue("id", rawID)
+	recorder := httptest.NewRecorder()
+
+	handler(recorder, request)
+
+	return recorder.Result()
+}
+
+func TestArchiveCardHandler_ExistingCard_Returns204AndHidesFromSearch(t *testing.T) {
+	db := testdb.New(t)
+
+	result, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?)",
+		"Luke Skywalker, Jedi Knight", 1,
+	)
+	require.NoError(t, err)
+	insertedID, err := result.LastInsertId()
+	require.NoError(t, err)
+
+	rawID := fmt.Sprintf("%d", insertedID)
+	response := postCardAction(t, cards.ArchiveCardHandler(db), "/cards/"+rawID+"/archive", rawID)
+
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+
+	matched, err := db.SearchCards("Luke")
+	require.NoError(t, err)
+	assert.Empty(t, matched, "expected archived card to be excluded from search")
+}
+
+func TestUnarchiveCardHandler_ArchivedCard_Returns204AndRestoresToSearch(t *testing.T) {
+	db := testdb.New(t)
+
+	result, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, archived) VALUES (?, ?, ?)",
+		"Luke Skywalker, Jedi Knight", 1, 1,
+	)
+	require.NoError(t, err)
+	insertedID, err := result.LastInsertId()
+	require.NoError(t, err)
+
+	rawID := fmt.Sprintf("%d", insertedID)
+	response := postCardAction(t, cards.UnarchiveCardHandler(db), "/cards/"+rawID+"/unarchive", rawID)
+
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+
+	matched, err := db.SearchCards("Luke")
+	require.NoError(t, err)
+	assert.Len(t, matched, 1)
+}
+
+func TestArchiveCardHandler_NonExistentID_Returns404(t *testing.T) {
+	db := testdb.New(t)
+
+	response := postCardAction(t, cards.ArchiveCardHandler(db), "/cards/99999/archive", "99999")
+
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestArchiveCardHandler_NonIntegerID_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	response := postCardAction(t, cards.ArchiveCardHandler(db), "/cards/abc/archive", "abc")
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestArchiveCardHTMLHandler_ExistingCard_Returns200WithEmptyBody(t *testing.T) {
+	db := testdb.New(t)
+
+	result, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?)",
+		"Luke Skywalker, Jedi Knight", 1,
+	)
+	require.NoError(t, err)
+	insertedID, err := result.LastInsertId()
+	require.NoError(t, err)
+
+	rawID := fmt.Sprintf("%d", insertedID)
+	response := postCardAction(t, cards.ArchiveCardHTMLHandler(db), "/cards/"+rawID+"/archive/html", rawID)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Empty(t, body)
+
+	card, err := db.GetCardByID(int(insertedID))
+	require.NoError(t, err)
+	assert.True(t, card.Archived)
+}
+
+func TestArchiveHandler_RendersOnlyArchivedCards(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, archived) VALUES (?, ?, ?), (?, ?, ?)",
+		"Luke Skywalker, Jedi Knight", 0, 0,
+		"Chewbacca, Hero of Kessel", 0, 1,
+	)
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodGet, "/archive", nil)
+	recorder := httptest.NewRecorder()
+
+	cards.ArchiveHandler(db, tmpl)(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Contains(t, response.Header.Get("Content-Type"), "text/html")
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	bodyStr := string(body)
+	assert.Contains(t, bodyStr, "<!DOCTYPE html>")
+	assert.Contains(t, bodyStr, "Chewbacca, Hero of Kessel")
+	assert.NotContains(t, bodyStr, "Luke Skywalker, Jedi Knight")
+}
+
+func TestSearchArchiveHTMLHandler_EmptyArchive_ShowsEmptyState(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	request := httptest.NewRequest(http.MethodGet, "/archive/search/html?q=luke", nil)
+	recorder := httptest.NewRecorder()
+
+	cards.SearchArchiveHTMLHandler(db, tmpl)(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "No archived cards.")
+}
+
+func TestWishlistHandler_ExcludesArchivedCards(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, mainboard, archived) VALUES (?, ?, ?, ?)",
+		"Luke Skywalker, Jedi Knight", 0, 1, 1,
+	)
+	require.NoError(t, err)
+
+	response := getWishlist(t, db, tmpl)
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "Luke Skywalker, Jedi Knight")
+}
+
+// addCardAlias sends a POST request to AddCardAliasHandler for the given raw
+// id string with body as the JSON payload.
+func addCardAlias(t *testing.T, db *database.Database, rawID, body string) *http.Response {
+	t.Helper()
+
+	target := fmt.Sprintf("/cards/%s/aliases", rawID)
+	request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	request.SetPathValue("id", rawID)
+	recorder := httptest.NewRecorder()
+
+	cards.AddCardAliasHandler(db)(recorder, request)
+
+	return recorder.Result()
+}
+
+// getCardAliases sends a GET request to GetCardAliasesHandler for the given
+// raw id string.
+func getCardAliases(t *testing.T, db *database.Database, rawID string) *http.Response {
+	t.Helper()
+
+	target := fmt.Sprintf("/cards/%s/aliases", rawID)
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	request.SetPathValue("id", rawID)
+	recorder := httptest.NewRecorder()
+
+	cards.GetCardAliasesHandler(db)(recorder, request)
+
+	return recorder.Result()
+}
+
+func TestAddCardAliasHandler_ValidAlias_Returns204AndCardIsSearchableByAlias(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+
+	response := addCardAlias(t, db, "1", `{"alias": "Chewie"}`)
+
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+
+	searchResponse := searchCards(t, db, "Chewie")
+	var result []models.Card
+	require.NoError(t, json.NewDecoder(searchResponse.Body).Decode(&result))
+	require.Len(t, result, 1)
+	assert.Equal(t, "Chewbacca, Walking Carpet", result[0].Name)
+}
+
+func TestAddCardAliasHandler_BlankAlias_Returns400(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+
+	response := addCardAlias(t, db, "1", `{"alias": "   "}`)
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestAddCardAliasHandler_MalformedBody_Returns400(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+
+	response := addCardAlias(t, db, "1", `not json`)
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestAddCardAliasHandler_NonExistentID_Returns404(t *testing.T) {
+	db := testdb.New(t)
+
+	response := addCardAlias(t, db, "99999", `{"alias": "Chewie"}`)
+
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestGetCardAliasesHandler_ExistingCard_Returns200WithAliases(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+	require.NoError(t, db.AddCardAlias(1, "Chewie"))
+
+	response := getCardAliases(t, db, "1")
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+
+	var aliases []string
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&aliases))
+	assert.Equal(t, []string{"Chewie"}, aliases)
+}
+
+func TestGetCardAliasesHandler_NonExistentID_Returns404(t *testing.T) {
+	db := testdb.New(t)
+
+	response := getCardAliases(t, db, "99999")
+
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestDeleteCardAliasHandler_ExistingAlias_Returns204(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+	require.NoError(t, db.AddCardAlias(1, "Chewie"))
+
+	request := httptest.NewRequest(http.MethodDelete, "/cards/1/aliases/Chewie", nil)
+	request.SetPathValue("id", "1")
+	request.SetPathValue("alias", "Chewie")
+	recorder := httptest.NewRecorder()
+
+	cards.DeleteCardAliasHandler(db)(recorder, request)
+
+	assert.Equal(t, http.StatusNoContent, recorder.Result().StatusCode)
+
+	aliases, err := db.GetCardAliases(1)
+	require.NoError(t, err)
+	assert.Empty(t, aliases)
+}
+
+func TestDeleteCardAliasHandler_UnknownAlias_Returns404(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+
+	request := httptest.NewRequest(http.MethodDelete, "/cards/1/aliases/Chewie", nil)
+	request.SetPathValue("id", "1")
+	request.SetPathValue("alias", "Chewie")
+	recorder := httptest.NewRecorder()
+
+	cards.DeleteCardAliasHandler(db)(recorder, request)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Result().StatusCode)
+}
+
+func TestImportCardsHandler_StoresCardDetailsFromCSV(t *testing.T) {
+	db := testdb.New(t)
+	imagesDir := t.TempDir()
+
+	csv := validCSVHeader + "\n" +
+		"SOR,149,Mace Windu,Party Crasher,Unit,Aggression|Heroism,Normal,Legendary,false,,Artist,0,0"
+
+	response := postImport(t, db, http.DefaultClient, imagesDir, "", csv)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	result, err := db.SearchCards("Mace Windu")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "SOR", result[0].Set)
+	assert.Equal(t, "149", result[0].Number)
+	assert.Equal(t, "Unit", result[0].Type)
+	assert.Equal(t, "Aggression|Heroism", result[0].Aspects)
+	assert.Equal(t, "Legendary", result[0].Rarity)
+}
+
+func TestImportCardsHandler_ExistingCardWithoutDetails_BackfillsDetails(t *testing.T) {
+	db := testdb.New(t)
+	imagesDir := t.TempDir()
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?)",
+		"Mace Windu, Party Crasher", 4,
+	)
+	require.NoError(t, err)
+
+	csv := validCSVHeader + "\n" +
+		"SOR,149,Mace Windu,Party Crasher,Unit,Aggression|Heroism,Normal,Legendary,false,,Artist,0,0"
+
+	response := postImport(t, db, http.DefaultClient, imagesDir, "", csv)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	result, err := db.SearchCards("Mace Windu")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "SOR", result[0].Set)
+	assert.Equal(t, "Legendary", result[0].Rarity)
+	assert.Equal(t, 4, result[0].Owned, "expected owned count to be untouched by the backfill")
+}
+
+// getRandomCard sends a GET request to RandomCardHandler with the given raw
+// query string.
+func getRandomCard(t *testing.T, db *database.Database, rawQuery string) *http.Response {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodGet, "/cards/random?"+rawQuery, nil)
+	recorder := httptest.NewRecorder()
+
+	cards.RandomCardHandler(db)(recorder, request)
+
+	return recorder.Result()
+}
+
+func TestRandomCardHandler_WithFilters_Returns200WithMatchingCard(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Law Rare", Set: "LAW", Rarity: "Rare"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Sor Rare", Set: "SOR", Rarity: "Rare"}))
+
+	response := getRandomCard(t, db, "set=LAW&rarity=Rare")
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+
+	var card models.Card
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&card))
+	assert.Equal(t, "Law Rare", card.Name)
+}
+
+func TestRandomCardHandler_NoMatch_Returns404(t *testing.T) {
+	db := testdb.New(t)
+
+	response := getRandomCard(t, db, "owned=true")
+
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestRandomCardHandler_InvalidOwned_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	response := getRandomCard(t, db, "owned=maybe")
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+// collectionDiffResponse mirrors the JSON body returned by DiffCardsHandler.
+type collectionDiffResponse struct {
+	OnlyInCSV       []string `json:"only_in_csv"`
+	OnlyInDB        []string `json:"only_in_db"`
+	OwnedMismatches []struct {
+		Name     string `json:"name"`
+		CSVOwned int    `json:"csv_owned"`
+		DBOwned  int    `json:"db_owned"`
+	} `json:"owned_mismatches"`
+}
+
+// postDiff sends a POST request to DiffCardsHandler with the given CSV body.
+func postDiff(t *testing.T, db *database.Database, body string) *http.Response {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/diff", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+
+	cards.DiffCardsHandler(db)(recorder, request)
+
+	return recorder.Result()
+}
+
+func TestDiffCardsHandler_ReportsAllDifferences(t *testing.T) {
+	db := testdb.New(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?), (?, ?), (?, ?)",
+		"Chewbacca, Hero of Kessel", 3,
+		"Luke Skywalker, Jedi Knight", 1,
+		"Darth Vader, Sith Lord", 2,
+	)
+	require.NoError(t, err)
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist,2,2\n" +
+		"LAW,201,Chewbacca,Hero of Kessel,Unit,Heroism,Hyperspace,Rare,false,,Artist,1,1\n" +
+		"LAW,002,Luke Skywalker,Jedi Knight,Unit,Heroism,Normal,Rare,false,,Artist,4,4\n" +
+		"LAW,003,Han Solo,Scoundrel,Unit,Heroism,Normal,Rare,false,,Artist,1,1"
+
+	response := postDiff(t, db, csv)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+
+	var diff collectionDiffResponse
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&diff))
+	assert.Equal(t, []string{"Han Solo, Scoundrel"}, diff.OnlyInCSV)
+	assert.Equal(t, []string{"Darth Vader, Sith Lord"}, diff.OnlyInDB)
+	require.Len(t, diff.OwnedMismatches, 1, "expected variant rows to be summed so Chewbacca matches")
+	assert.Equal(t, "Luke Skywalker, Jedi Knight", diff.OwnedMismatches[0].Name)
+	assert.Equal(t, 4, diff.OwnedMismatches[0].CSVOwned)
+	assert.Equal(t, 1, diff.OwnedMismatches[0].DBOwned)
+}
+
+func TestDiffCardsHandler_DoesNotModifyDatabase(t *testing.T) {
+	db := testdb.New(t)
+
+	csv := validCSVHeader + "\n" +
+		"LAW,003,Han Solo,Scoundrel,Unit,Heroism,Normal,Rare,false,,Artist,1,1"
+
+	response := postDiff(t, db, csv)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	exists, err := db.CardExistsByName("Han Solo, Scoundrel")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestDiffCardsHandler_InvalidOwnedCount_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	csv := validCSVHeader + "\n" +
+		"LAW,003,Han Solo,Scoundrel,Unit,Heroism,Normal,Rare,false,,Artist,lots,1"
+
+	response := postDiff(t, db, csv)
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestDiffCardsHandler_MalformedCSV_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	response := postDiff(t, db, "not,a,valid,header")
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestDiffCardsHTMLHandler_RendersComparison(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?)",
+		"Darth Vader, Sith Lord", 2,
+	)
+	require.NoError(t, err)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "cards.csv")
+	require.NoError(t, err)
+	_, err = io.WriteString(part, validCSVHeader+"\n"+"LAW,003,Han Solo,Scoundrel,Unit,Heroism,Normal,Rare,false,,Artist,1,1")
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/diff/html", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	recorder := httptest.NewRecorder()
+
+	cards.DiffCardsHTMLHandler(db, tmpl)(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Contains(t, response.Header.Get("Content-Type"), "text/html")
+
+	responseBody, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	bodyStr := string(responseBody)
+	assert.Contains(t, bodyStr, "Only in CSV (1)")
+	assert.Contains(t, bodyStr, "Han Solo, Scoundrel")
+	assert.Contains(t, bodyStr, "Only in collection (1)")
+	assert.Contains(t, bodyStr, "Darth Vader, Sith Lord")
+}
+
+// syncResultResponse mirrors the JSON shape returned by a sync-mode import.
+type syncResultResponse struct {
+	DryRun  bool `json:"dry_run"`
+	Changes []struct {
+		Name string `json:"name"`
+		From int    `json:"from"`
+		To   int    `json:"to"`
+	} `json:"changes"`
+	Unknown []string `json:"unknown"`
+}
+
+// postSync sends a raw CSV body to ImportCardsHandler with the given raw query
+// string, e.g. "mode=sync&dry_run=true".
+func postSync(t *testing.T, db *database.Database, rawQuery, body string) *http.Response {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/import?"+rawQuery, strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+
+	cards.ImportCardsHandler(db, cards.NewImportLock(), http.DefaultClient, t.TempDir(), nil)(recorder, request)
+
+	return recorder.Result()
+}
+
+// getOwnedByName returns the owned count of the card with the given name.
+func getOwnedByName(t *testing.T, db *database.Database, name string) int {
+	t.Helper()
+
+	var owned int
+	require.NoError(t, db.Connection().QueryRow("SELECT owned FROM cards WHERE name = ?", name).Scan(&owned))
+	return owned
+}
+
+func TestImportCardsHandler_SyncMode_UpdatesOwnedCounts(t *testing.T) {
+	db := testdb.New(t)
+	t.Cleanup(audit.Subscribe(db))
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?), (?, ?)",
+		"Chewbacca, Hero of Kessel", 1,
+		"Luke Skywalker, Jedi Knight", 4,
+	)
+	require.NoError(t, err)
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist,2,2\n" +
+		"LAW,201,Chewbacca,Hero of Kessel,Unit,Heroism,Hyperspace,Rare,false,,Artist,1,1\n" +
+		"LAW,002,Luke Skywalker,Jedi Knight,Unit,Heroism,Normal,Rare,false,,Artist,4,4\n" +
+		"LAW,003,Han Solo,Scoundrel,Unit,Heroism,Normal,Rare,false,,Artist,1,1"
+
+	response := postSync(t, db, "mode=sync", csv)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+
+	var result syncResultResponse
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	assert.False(t, result.DryRun)
+	require.Len(t, result.Changes, 1)
+	assert.Equal(t, "Chewbacca, Hero of Kessel", result.Changes[0].Name)
+	assert.Equal(t, 1, result.Changes[0].From)
+	assert.Equal(t, 3, result.Changes[0].To)
+	assert.Equal(t, []string{"Han Solo, Scoundrel"}, result.Unknown)
+
+	assert.Equal(t, 3, getOwnedByName(t, db, "Chewbacca, Hero of Kessel"))
+	assert.Equal(t, 4, getOwnedByName(t, db, "Luke Skywalker, Jedi Knight"))
+
+	exists, err := db.CardExistsByName("Han Solo, Scoundrel")
+	require.NoError(t, err)
+	assert.False(t, exists, "sync mode must not insert new cards")
+
+	events, err := db.GetAuditEvents(database.AuditFilter{Action: models.AuditSync}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "Chewbacca, Hero of Kessel", events[0].CardName)
+	assert.Equal(t, "owned 1 → 3", events[0].Detail)
+}
+
+func TestImportCardsHandler_SyncDryRun_DoesNotModifyDatabase(t *testing.T) {
+	db := testdb.New(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?)",
+		"Chewbacca, Hero of Kessel", 1,
+	)
+	require.NoError(t, err)
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist,5,5"
+
+	response := postSync(t, db, "mode=sync&dry_run=true", csv)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	var result syncResultResponse
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	assert.True(t, result.DryRun)
+	require.Len(t, result.Changes, 1)
+	assert.Equal(t, 5, result.Changes[0].To)
+
+	assert.Equal(t, 1, getOwnedByName(t, db, "Chewbacca, Hero of Kessel"))
+
+	count, err := db.CountAuditEvents(database.AuditFilter{})
+	require.NoError(t, err)
+	assert.Zero(t, count, "a dry run must not be audited")
+}
+
+func TestImportCardsHandler_SyncLargeChange_Returns409UntilConfirmed(t *testing.T) {
+	db := testdb.New(t)
+	settings := db.Settings()
+	settings.MaxOwnedChange = 3
+	require.NoError(t, db.SaveSettings(settings))
+
+	_, err := db.Connection().Exec("INSERT INTO cards (name, owned) VALUES (?, ?)", "Chewbacca, Hero of Kessel", 1)
+	require.NoError(t, err)
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist,5,5"
+
+	response := postSync(t, db, "mode=sync", csv)
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+	assert.Equal(t, 1, getOwnedByName(t, db, "Chewbacca, Hero of Kessel"))
+
+	response = postSync(t, db, "mode=sync&confirm=true", csv)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, 5, getOwnedByName(t, db, "Chewbacca, Hero of Kessel"))
+}
+
+func TestImportCardsHandler_InvalidImportOptions_Returns400(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawQuery string
+	}{
+		{name: "unknown mode", rawQuery: "mode=replace"},
+		{name: "invalid dry_run", rawQuery: "mode=sync&dry_run=maybe"},
+		{name: "dry_run without sync", rawQuery: "dry_run=true"},
+		{name: "confirm without sync", rawQuery: "confirm=true"},
+		{name: "invalid lenient", rawQuery: "lenient=sometimes"},
+		{name: "lenient with sync", rawQuery: "mode=sync&lenient=true"},
+		{name: "invalid use_owned_count", rawQuery: "use_owned_count=perhaps"},
+		{name: "use_owned_count with sync", rawQuery: "mode=sync&use_owned_count=true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testdb.New(t)
+
+			response := postSync(t, db, tt.rawQuery, validCSVHeader)
+
+			assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		})
+	}
+}
+
+func TestImportCardsHandler_Lenient_SkipsMalformedRowsAndReportsLines(t *testing.T) {
+	db := testdb.New(t)
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0\n" +
+		"LAW,002,Luke Skywalker\n" +
+		"LAW,003,Han \"Solo,Scoundrel,Unit,Cunning,Normal,Rare,false,,Artist Three,0,0\n" +
+		"LAW,004,Leia Organa,Rebel Leader,Unit,Command,Normal,Rare,false,,Artist Four,0,0"
+
+	response := postSync(t, db, "lenient=true", csv)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	var summary struct {
+		Inserted  int `json:"inserted"`
+		RowErrors []struct {
+			Line    int    `json:"line"`
+			Message string `json:"message"`
+		} `json:"row_errors"`
+	}
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&summary))
+	assert.Equal(t, 2, summary.Inserted)
+	require.Len(t, summary.RowErrors, 2)
+	assert.Equal(t, 3, summary.RowErrors[0].Line)
+	assert.Equal(t, 4, summary.RowErrors[1].Line)
+	assert.NotEmpty(t, summary.RowErrors[0].Message)
+
+	exists, err := db.CardExistsByName("Leia Organa, Rebel Leader")
+	require.NoError(t, err)
+	assert.True(t, exists, "expected rows after a malformed row to be imported")
+}
+
+func TestImportCardsHandler_NotLenient_MalformedRowReturns400(t *testing.T) {
+	db := testdb.New(t)
+
+	csv := validCSVHeader + "\n" +
+		"LAW,002,Luke Skywalker\n" +
+		"LAW,004,Leia Organa,Rebel Leader,Unit,Command,Normal,Rare,false,,Artist Four,0,0"
+
+	response := postSync(t, db, "", csv)
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestImportCardsHandler_InvalidFieldValue_Returns400WithLine(t *testing.T) {
+	tests := []struct {
+		name string
+		row  string
+	}{
+		{name: "invalid set code", row: "law!,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"},
+		{name: "non-numeric card number", row: "LAW,one,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"},
+		{name: "empty card name", row: "LAW,001,,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"},
+		{name: "unknown card type", row: "LAW,001,Chewbacca,Hero of Kessel,Starship,Heroism,Normal,Rare,false,,Artist One,0,0"},
+		{name: "unknown aspect", row: "LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism|Bravery,Normal,Rare,false,,Artist One,0,0"},
+		{name: "unknown rarity", row: "LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Mythic,false,,Artist One,0,0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testdb.New(t)
+
+			csv := validCSVHeader + "\n" +
+				"LAW,002,Luke Skywalker,Jedi Knight,Unit,Heroism,Normal,Rare,false,,Artist Two,0,0\n" +
+				tt.row
+
+			response := postSync(t, db, "", csv)
+
+			assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+			body, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(body), "line 3")
+
+			allCards, err := db.GetAllCards()
+			require.NoError(t, err)
+			assert.Empty(t, allCards, "expected no cards from a file with an invalid row")
+		})
+	}
+}
+
+func TestImportCardsHandler_Lenient_SkipsRowsWithInvalidValues(t *testing.T) {
+	db := testdb.New(t)
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Mythic,false,,Artist One,0,0\n" +
+		"law,002,Luke Skywalker,Jedi Knight,Unit,Heroism,Normal,Rare,false,,Artist Two,0,0\n" +
+		"LAW,003,Leia Organa,Rebel Leader,leader,heroism|VIGILANCE,Normal,rare,false,,Artist Three,0,0"
+
+	response := postSync(t, db, "lenient=true", csv)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	var summary struct {
+		Inserted  int `json:"inserted"`
+		RowErrors []struct {
+			Line    int    `json:"line"`
+			Message string `json:"message"`
+		} `json:"row_errors"`
+	}
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&summary))
+	assert.Equal(t, 1, summary.Inserted, "expected types, rarities and aspects to match case-insensitively")
+	require.Len(t, summary.RowErrors, 2)
+	assert.Equal(t, 2, summary.RowErrors[0].Line)
+	assert.Contains(t, summary.RowErrors[0].Message, "Mythic")
+	assert.Equal(t, 3, summary.RowErrors[1].Line)
+	assert.Contains(t, summary.RowErrors[1].Message, "set code")
+}
+
+func TestImportCardsHandler_SyncInvalidOwnedCount_Returns400AndLeavesCountsUnchanged(t *testing.T) {
+	db := testdb.New(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?)",
+		"Chewbacca, Hero of Kessel", 1,
+	)
+	require.NoError(t, err)
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist,5,5\n" +
+		"LAW,003,Han Solo,Scoundrel,Unit,Heroism,Normal,Rare,false,,Artist,lots,1"
+
+	response := postSync(t, db, "mode=sync", csv)
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, 1, getOwnedByName(t, db, "Chewbacca, Hero of Kessel"))
+}
+
+func TestImportCardsHTMLHandler_SyncDryRun_RendersPreviewWithoutTrigger(t *testing.T) {
+	db := testdb.New(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?)",
+		"Chewbacca, Hero of Kessel", 1,
+	)
+	require.NoError(t, err)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "cards.csv")
+	require.NoError(t, err)
+	_, err = io.WriteString(part, validCSVHeader+"\n"+"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist,5,5")
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteField("mode", "sync"))
+	require.NoError(t, writer.WriteField("dry_run", "true"))
+	require.NoError(t, writer.Close())
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/import/html", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	recorder := httptest.NewRecorder()
+
+	cards.ImportCardsHTMLHandler(db, cards.NewImportLock(), http.DefaultClient, t.TempDir(), nil, newTestTemplates(t))(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Empty(t, response.Header.Get("HX-Trigger"))
+
+	responseBody, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	html := string(responseBody)
+	assert.Contains(t, html, "Would update")
+	assert.Contains(t, html, "Chewbacca, Hero of Kessel")
+
+	assert.Equal(t, 1, getOwnedByName(t, db, "Chewbacca, Hero of Kessel"))
+}
+
+func TestImportCardsHandler_ImageDownloadFails_FlagsCardForRetry(t *testing.T) {
+	db := testdb.New(t)
+
+	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer imageServer.Close()
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
+
+	response := postImport(t, db, imageServer.Client(), t.TempDir(), imageServer.URL, csv)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	failed, err := db.GetImageFailedCards()
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Equal(t, "Chewbacca, Hero of Kessel", failed[0].Name)
+}
+
+func TestImportCardsHandler_ReturnsSummaryOfImport(t *testing.T) {
+	db := testdb.New(t)
+
+	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "002") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("fake-png-data"))
+	}))
+	defer imageServer.Close()
+
+	imagesDir := t.TempDir()
+	first := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
+	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, first)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	second := first + "\n" +
+		"LAW,002,Luke Skywalker,Jedi Knight,Unit,Heroism,Normal,Rare,false,,Artist Two,0,0\n" +
+		"LAW,002,Luke Skywalker,Jedi Knight,Unit,Heroism,Foil,Rare,true,,Artist Two,0,0"
+	response = postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, second)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+
+	var summary struct {
+		Inserted         int      `json:"inserted"`
+		SkippedExisting  int      `json:"skipped_existing"`
+		SkippedDuplicate int      `json:"skipped_duplicate"`
+		ImageFailures    []string `json:"image_failures"`
+	}
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&summary))
+	assert.Equal(t, 1, summary.Inserted)
+	assert.Equal(t, 1, summary.SkippedExisting)
+	assert.Equal(t, 1, summary.SkippedDuplicate)
+	assert.Equal(t, []string{"Luke Skywalker, Jedi Knight"}, summary.ImageFailures)
+}
+
+func TestImportCardsHandler_DownloadedImage_IsOptimizedWithThumbnailShownInGrid(t *testing.T) {
+	db := testdb.New(t)
+	imagesDir := t.TempDir()
+
+	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		png.Encode(w, image.NewRGBA(image.Rect(0, 0, 300, 420)))
+	}))
+	defer imageServer.Close()
+
+	csv := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
+
+	response := postImport(t, db, imageServer.Client(), imagesDir, imageServer.URL, csv)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	card, err := db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(imagesDir, "LAW001.jpg"), card.Image, "expected downloaded PNG to be optimized to JPEG")
+	assert.NoFileExists(t, filepath.Join(imagesDir, "LAW001.png"))
+	assert.Equal(t, filepath.Join(imagesDir, "thumbs", "LAW001.jpg"), card.Thumbnail)
+	assert.FileExists(t, card.Thumbnail)
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	recorder := httptest.NewRecorder()
+	cards.IndexHandler(db, newTestTemplates(t))(recorder, request)
+
+	body := recorder.Body.String()
+	assert.Contains(t, body, `src="/`+card.Thumbnail+`"`)
+	assert.Contains(t, body, `href="/`+card.Image+`"`)
+}
+
+// setCardPriority sends a POST request to SetCardPriorityHandler for the given
+// raw id string and JSON body.
+func setCardPriority(t *testing.T, db *database.Database, rawID, body string) *http.Response {
+	t.Helper()
+
+	target := fmt.Sprintf("/cards/%s/priority", rawID)
+	request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	request.SetPathValue("id", rawID)
+	recorder := httptest.NewRecorder()
+
+	cards.SetCardPriorityHandler(db)(recorder, request)
+
+	return recorder.Result()
+}
+
+func TestSetCardPriorityHandler_ValidPriority_Returns204AndStoresPriority(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Darth Vader, Dark Lord of the Sith", Mainboard: true}))
+
+	response := setCardPriority(t, db, "1", `{"priority": "high"}`)
+
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+
+	card, err := db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, database.PriorityHigh, card.Priority)
+}
+
+func TestSetCardPriorityHandler_UnknownPriority_Returns400(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Darth Vader, Dark Lord of the Sith", Mainboard: true}))
+
+	response := setCardPriority(t, db, "1", `{"priority": "urgent"}`)
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestSetCardPriorityHandler_NonExistentID_Returns404(t *testing.T) {
+	db := testdb.New(t)
+
+	response := setCardPriority(t, db, "99999", `{"priority": "low"}`)
+
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestWishlistHandler_OrdersByPriorityThenDeficit(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Alpha Low", Mainboard: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Bravo Normal Small", Mainboard: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Charlie Normal Large", Mainboard: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Delta High", Mainboard: true}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{"Bravo Normal Small": 2, "Delta High": 2}))
+	require.NoError(t, db.SetCardPriority(1, database.PriorityLow))
+	require.NoError(t, db.SetCardPriority(4, database.PriorityHigh))
+
+	response := getWishlist(t, db, tmpl)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	raw, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	body := string(raw)
+
+	high := strings.Index(body, "Delta High")
+	large := strings.Index(body, "Charlie Normal Large")
+	small := strings.Index(body, "Bravo Normal Small")
+	low := strings.Index(body, "Alpha Low")
+	assert.True(t, high < large && large < small && small < low, "unexpected wishlist order")
+}
+
+func TestSetCardPriorityHTMLHandler_ReturnsReorderedWishlistFragment(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Alpha", Mainboard: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Bravo", Mainboard: true}))
+
+	form := url.Values{"priority": {"high"}}
+	request := httptest.NewRequest(http.MethodPost, "/cards/2/priority/html", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetPathValue("id", "2")
+	recorder := httptest.NewRecorder()
+
+	cards.SetCardPriorityHTMLHandler(db, tmpl)(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Less(t, strings.Index(body, "Bravo"), strings.Index(body, "Alpha"))
+	assert.NotContains(t, body, "<!DOCTYPE html>")
+}
+
+func TestWishlistHandler_RendersRecentlyCompletedSection(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Han Solo, Reluctant Hero", Mainboard: true}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{"Han Solo, Reluctant Hero": database.UntypedMinimumOwned}))
+
+	response := getWishlist(t, db, tmpl)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	raw, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	body := string(raw)
+
+	assert.Contains(t, body, "Recently completed")
+	assert.Contains(t, body, "Han Solo, Reluctant Hero")
+}
+
+func TestWishlistHandler_GroupByAspect_RendersCollapsibleSections(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Faithful Friend", Mainboard: true, Aspects: "Vigilance, Heroism"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Darth Vader, Dark Lord of the Sith", Mainboard: true, Aspects: "Aggression, Villainy"}))
+
+	request := httptest.NewRequest(http.MethodGet, "/wishlist?group=aspect", nil)
+	recorder := httptest.NewRecorder()
+
+	cards.WishlistHandler(db, tmpl)(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Equal(t, 2, strings.Count(body, `<details class="wishlist-group"`))
+	assert.Less(t, strings.Index(body, "Aggression, Villainy"), strings.Index(body, "Vigilance, Heroism"))
+	assert.Less(t, strings.Index(body, "Darth Vader"), strings.Index(body, "Luke Skywalker"))
+}
+
+func TestWishlistHandler_UnknownGroup_Returns400(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	request := httptest.NewRequest(http.MethodGet, "/wishlist?group=rarity", nil)
+	recorder := httptest.NewRecorder()
+
+	cards.WishlistHandler(db, tmpl)(recorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestSearchWishlistHTMLHandler_WithGroup_RendersGroupedFragment(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Faithful Friend", Mainboard: true, Set: "SOR"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Boba Fett, Collecting the Bounty", Mainboard: true, Set: "SHD"}))
+
+	response := searchWishlistHTML(t, db, tmpl, "luke&group=set")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	raw, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	body := string(raw)
+
+	assert.Contains(t, body, "SOR")
+	assert.Contains(t, body, "Luke Skywalker")
+	assert.NotContains(t, body, "Boba Fett")
+	assert.NotContains(t, body, "<!DOCTYPE html>")
+}
+
+func TestImportCardsHandler_FileLargerThanOneBatch_InsertsEveryDistinctCard(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Card 1100, Already Owned", Mainboard: true}))
+
+	var body strings.Builder
+	body.WriteString(validCSVHeader + "\n")
+	for i := range 1200 {
+		title := "Generic"
+		if i == 1100 {
+			title = "Already Owned"
+		}
+		fmt.Fprintf(&body, "LAW,%04d,Card %d,%s,Unit,,Normal,Common,false,,,1,1\n", i, i, title)
+	}
+	// Repeat a card from the first batch after later batches have been committed.
+	body.WriteString("LAW,0003,Card 3,Generic,Unit,,Normal,Common,false,,,1,1\n")
+
+	response := postImport(t, db, http.DefaultClient, t.TempDir(), "", body.String())
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	allCards, err := db.GetAllCards()
+	require.NoError(t, err)
+	assert.Len(t, allCards, 1200)
+}
+
+func TestImportCardsHandler_WhileAnotherImportRuns_Returns409AndReportsStatus(t *testing.T) {
+	db := testdb.New(t)
+	lock := cards.NewImportLock()
+
+	downloadStarted := make(chan struct{})
+	releaseDownload := make(chan struct{})
+	imageServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		close(downloadStarted)
+		<-releaseDownload
+		responseWriter.WriteHeader(http.StatusNotFound)
+	}))
+	defer imageServer.Close()
+
+	csv := validCSVHeader + "\n" + "SOR,005,Luke Skywalker,Faithful Friend,Leader,Vigilance,Normal,Common,false,,,1,1\n"
+	importHandler := cards.ImportCardsHandler(db, lock, imageServer.Client(), t.TempDir(), []string{imageServer.URL})
+
+	firstDone := make(chan int)
+	go func() {
+		recorder := httptest.NewRecorder()
+		importHandler(recorder, httptest.NewRequest(http.MethodPost, "/cards/import", strings.NewReader(csv)))
+		firstDone <- recorder.Code
+	}()
+	<-downloadStarted
+
+	status := lock.Status()
+	assert.True(t, status.Running)
+	assert.Equal(t, "api", status.Source)
+
+	recorder := httptest.NewRecorder()
+	importHandler(recorder, httptest.NewRequest(http.MethodPost, "/cards/import", strings.NewReader(csv)))
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	close(releaseDownload)
+	assert.Equal(t, http.StatusOK, <-firstDone)
+
+	statusRecorder := httptest.NewRecorder()
+	cards.ImportStatusHandler(lock)(statusRecorder, httptest.NewRequest(http.MethodGet, "/cards/import/status", nil))
+	require.Equal(t, http.StatusOK, statusRecorder.Code)
+
+	var finished cards.ImportStatus
+	require.NoError(t, json.NewDecoder(statusRecorder.Body).Decode(&finished))
+	assert.False(t, finished.Running)
+	assert.False(t, finished.FinishedAt.IsZero())
+	assert.Empty(t, finished.Error)
+
+	allCards, err := db.GetAllCards()
+	require.NoError(t, err)
+	assert.Len(t, allCards, 1)
+}
+
+func TestImportCardsHandler_FailedImport_ReleasesLockAndRecordsError(t *testing.T) {
+	db := testdb.New(t)
+	lock := cards.NewImportLock()
+	importHandler := cards.ImportCardsHandler(db, lock, http.DefaultClient, t.TempDir(), nil)
+
+	recorder := httptest.NewRecorder()
+	importHandler(recorder, httptest.NewRequest(http.MethodPost, "/cards/import", strings.NewReader("not,a,valid,header\n")))
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	status := lock.Status()
+	assert.False(t, status.Running)
+	assert.NotEmpty(t, status.Error)
+
+	recorder = httptest.NewRecorder()
+	importHandler(recorder, httptest.NewRequest(http.MethodPost, "/cards/import", strings.NewReader(validCSVHeader+"\nLAW,002,Luke Skywalker,,Unit,,Normal,Common,false,,,1,1\n")))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestImportCardsHandler_LogsEveryLineWithRequestID(t *testing.T) {
+	db := testdb.New(t)
+
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(requestid.NewLogHandler(slog.NewTextHandler(&logs, nil))))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	body := validCSVHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0"
+	request := httptest.NewRequest(http.MethodPost, "/cards/import", strings.NewReader(body))
+	request.Header.Set(requestid.Header, "import-test")
+	recorder := httptest.NewRecorder()
+
+	handler := cards.ImportCardsHandler(db, cards.NewImportLock(), http.DefaultClient, t.TempDir(), nil)
+	requestid.Middleware(handler).ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.Greater(t, len(lines), 1)
+	for _, line := range lines {
+		assert.Contains(t, line, "request_id=import-test")
+	}
+}
+
+func TestQuickHandler_ExactNameMatchShownFirstWithOtherMatches(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?), (?, ?)",
+		"Luke Skywalker, Faithful Friend", 1,
+		"Luke Skywalker", 3,
+	)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	cards.QuickHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/quick?q=luke+skywalker", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, `<div class="quick-name">Luke Skywalker</div>`)
+	assert.Contains(t, body, "Owned: 3")
+	assert.Contains(t, body, `/cards/2/increment/html`)
+	assert.Contains(t, body, `hx-get="/quick/card/html?id=1"`)
+}
+
+func TestQuickCardHTMLHandler_ByID_RendersThatCard(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel"}))
+
+	recorder := httptest.NewRecorder()
+	cards.QuickCardHTMLHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/quick/card/html?id=1", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Chewbacca, Hero of Kessel")
+	assert.NotContains(t, recorder.Body.String(), "<!DOCTYPE html>")
+}
+
+func TestQuickCardHTMLHandler_NoMatch_ShowsHint(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	recorder := httptest.NewRecorder()
+	cards.QuickCardHTMLHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/quick/card/html?q=yoda", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "No card matches")
+}
+
+func TestQuickCardHTMLHandler_InvalidOrUnknownID_ReturnsError(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	invalid := httptest.NewRecorder()
+	cards.QuickCardHTMLHandler(db, tmpl)(invalid, httptest.NewRequest(http.MethodGet, "/quick/card/html?id=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+
+	unknown := httptest.NewRecorder()
+	cards.QuickCardHTMLHandler(db, tmpl)(unknown, httptest.NewRequest(http.MethodGet, "/quick/card/html?id=99", nil))
+	assert.Equal(t, http.StatusNotFound, unknown.Code)
+}
+
+func TestQuickHandler_Set_ShowsBoxView(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+	require.NoError(t, db.InsertCards([]models.NewCard{
+		{Name: "Luke Skywalker, Jedi Knight", Set: "SOR"},
+		{Name: "Chewbacca, Walking Carpet", Set: "SHD"},
+	}))
+
+	recorder := httptest.NewRecorder()
+	cards.QuickHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/quick?set=SOR", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, "Box SOR: pick a card to count.")
+	assert.Contains(t, body, "Luke Skywalker, Jedi Knight")
+	assert.NotContains(t, body, "Chewbacca, Walking Carpet")
+}
@@ -0,0 +1,147 @@
+package cards
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"swucol/cardservice"
+	"swucol/database"
+	"swucol/models"
+)
+
+// importHistoryLimit is the number of imports GET /imports returns.
+const importHistoryLimit = 50
+
+// recordImport adds the import whose final status is status to the import
+// history, with the counts from imported or synced, whichever the import
+// produced; both are nil when it failed. file is stored with the entry unless
+// it is nil. Dry runs change nothing and are not recorded. A failure to record
+// is logged rather than returned, since the import itself has already
+// finished.
+func recordImport(ctx context.Context, db *database.Database, status ImportStatus, fileName string, file []byte, imported *cardservice.ImportResult, synced *cardservice.SyncResult) {
+	if status.DryRun {
+		return
+	}
+
+	record := models.ImportRecord{
+		Source:     status.Source,
+		FileName:   fileName,
+		Mode:       string(status.Mode),
+		Error:      status.Error,
+		StartedAt:  status.StartedAt,
+		FinishedAt: status.FinishedAt,
+	}
+	if imported != nil {
+		record.Inserted = imported.Inserted
+		record.SkippedExisting = imported.SkippedExisting
+		record.SkippedDuplicate = imported.SkippedDuplicate
+		record.ImageFailures = len(imported.ImageFailures)
+		record.RowErrors = len(imported.RowErrors)
+	}
+	if synced != nil {
+		record.Changed = len(synced.Changes)
+	}
+
+	if _, err := db.CreateImportRecord(record, file); err != nil {
+		slog.ErrorContext(ctx, "database error recording import history", "source", record.Source, "error", err)
+	}
+}
+
+// keepImportFile reads all of reader when keep is true and returns a reader
+// over the same bytes together with the bytes, to be stored with the import's
+// history entry. When keep is false reader is returned unchanged with nil
+// bytes. Returns an *importError with status 400 if reader fails.
+func keepImportFile(reader io.Reader, keep bool) (io.Reader, []byte, *importError) {
+	if !keep {
+		return reader, nil, nil
+	}
+
+	file, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, &importError{statusCode: http.StatusBadRequest, message: "failed to read file: " + err.Error()}
+	}
+
+	return bytes.NewReader(file), file, nil
+}
+
+// ImportHistoryHandler returns an http.HandlerFunc that handles GET /imports.
+// Returns 200 OK with the most recent imports, newest first, as JSON, and 500
+// Internal Server Error for database errors.
+func ImportHistoryHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		records, err := db.GetImportRecords(importHistoryLimit)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading import history", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(records); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode import history", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// RerunImportHandler returns an http.HandlerFunc that handles POST
+// /imports/{id}/rerun. It imports the file kept with the import history entry
+// again, for example after fixing a card or to apply it in sync mode, without
+// uploading it again. The query parameters and responses are those of POST
+// /cards/import, and the re-run is recorded in the history with source
+// "rerun" and the original file name. Returns 400 Bad Request for an invalid
+// id or option, 404 Not Found when the entry does not exist or its file was
+// not kept, 409 Conflict when another import holds lock, and 500 Internal
+// Server Error for database errors.
+func RerunImportHandler(db *database.Database, lock *ImportLock, httpClient *http.Client, imagesDir string, imageBaseURLs []string) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "POST /imports/{id}/rerun received")
+
+		id, err := strconv.Atoi(request.PathValue("id"))
+		if err != nil || id <= 0 {
+			http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		settings := db.Settings()
+		defaults := importOptions{lenient: settings.ImportLenient, useOwnedCount: settings.ImportUseOwnedCount}
+		options, message := parseImportOptions(request.URL.Query().Get, defaults)
+		if message != "" {
+			http.Error(responseWriter, message, http.StatusBadRequest)
+			return
+		}
+
+		record, err := db.GetImportRecord(id)
+		if errors.Is(err, database.ErrImportNotFound) {
+			http.Error(responseWriter, "import not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading import", "id", id, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		file, err := db.GetImportFile(id)
+		if errors.Is(err, database.ErrImportNotFound) || errors.Is(err, database.ErrImportFileNotKept) {
+			http.Error(responseWriter, "import file was not kept", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading import file", "id", id, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "re-running import", "id", id, "file_name", record.FileName, "mode", options.mode)
+
+		serveJSONImport(responseWriter, request, db, lock, httpClient, imagesDir, imageBaseURLs, "rerun", record.FileName, bytes.NewReader(file), options, settings.ImportKeepFiles)
+	}
+}
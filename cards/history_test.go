@@ -0,0 +1,109 @@
+package cards_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/cards"
+	"swucol/database"
+	"swucol/internal/testdb"
+	"swucol/models"
+)
+
+// keepImportFiles turns on the import_keep_files setting.
+func keepImportFiles(t *testing.T, db *database.Database) {
+	t.Helper()
+
+	settings := db.Settings()
+	settings.ImportKeepFiles = true
+	require.NoError(t, db.SaveSettings(settings))
+}
+
+// postRerun sends POST /imports/{id}/rerun with rawQuery to
+// RerunImportHandler.
+func postRerun(t *testing.T, db *database.Database, id int, rawQuery string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rawID := strconv.Itoa(id)
+	request := httptest.NewRequest(http.MethodPost, "/imports/"+rawID+"/rerun?"+rawQuery, nil)
+	request.SetPathValue("id", rawID)
+	recorder := httptest.NewRecorder()
+
+	cards.RerunImportHandler(db, cards.NewImportLock(), http.DefaultClient, t.TempDir(), nil)(recorder, request)
+
+	return recorder
+}
+
+func TestImportHistoryHandler_ListsUploadedImports(t *testing.T) {
+	db := testdb.New(t)
+	postImport(t, db, http.DefaultClient, t.TempDir(), "", validCSVHeader+"\n"+
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0")
+
+	recorder := httptest.NewRecorder()
+	cards.ImportHistoryHandler(db)(recorder, httptest.NewRequest(http.MethodGet, "/imports", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var records []models.ImportRecord
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &records))
+	require.Len(t, records, 1)
+	assert.Equal(t, "api", records[0].Source)
+	assert.Equal(t, "insert", records[0].Mode)
+	assert.Equal(t, 1, records[0].Inserted)
+}
+
+func TestRerunImportHandler_SyncModeAppliesKeptFile(t *testing.T) {
+	db := testdb.New(t)
+	keepImportFiles(t, db)
+	postImport(t, db, http.DefaultClient, t.TempDir(), "", validCSVHeader+"\n"+
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,3,3")
+
+	records, err := db.GetImportRecords(10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.True(t, records[0].HasFile)
+
+	recorder := postRerun(t, db, records[0].ID, "mode=sync")
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Contains(t, recorder.Body.String(), `"to":3`)
+	counts, err := db.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, 3, counts["Chewbacca, Hero of Kessel"])
+
+	records, err = db.GetImportRecords(10)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "rerun", records[0].Source)
+	assert.Equal(t, "sync", records[0].Mode)
+	assert.Equal(t, 1, records[0].Changed)
+}
+
+func TestRerunImportHandler_FileNotKept_Returns404(t *testing.T) {
+	db := testdb.New(t)
+	postImport(t, db, http.DefaultClient, t.TempDir(), "", validCSVHeader+"\n"+
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0")
+
+	records, err := db.GetImportRecords(10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.False(t, records[0].HasFile)
+
+	recorder := postRerun(t, db, records[0].ID, "")
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "not kept")
+}
+
+func TestRerunImportHandler_UnknownImport_Returns404(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := postRerun(t, db, 42, "")
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
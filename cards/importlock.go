@@ -0,0 +1,90 @@
+package cards
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"swucol/metrics"
+)
+
+// ImportStatus describes the import currently running, or the most recent one
+// when Running is false. Fields are zero until the first import starts.
+type ImportStatus struct {
+	Running bool `json:"running"`
+	// Source is "api" for POST /cards/import, "html" for the upload form,
+	// "catalog" for POST /cards/import/set/{setcode}, "watch" for files
+	// picked up from the watch folder and "rerun" for POST
+	// /imports/{id}/rerun.
+	Source     string     `json:"source,omitempty"`
+	Mode       importMode `json:"mode,omitempty"`
+	DryRun     bool       `json:"dry_run,omitempty"`
+	StartedAt  time.Time  `json:"started_at,omitzero"`
+	FinishedAt time.Time  `json:"finished_at,omitzero"`
+	// Error is the failure message of the most recent import, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// ImportLock allows only one import to run at a time. Imports check which
+// cards already exist before inserting them, so two concurrent imports of
+// overlapping files would otherwise both insert the same cards. A busy import
+// is rejected rather than queued so that the caller finds out immediately.
+type ImportLock struct {
+	mutex  sync.Mutex
+	status ImportStatus
+}
+
+// NewImportLock returns an unlocked ImportLock.
+func NewImportLock() *ImportLock {
+	return &ImportLock{}
+}
+
+// tryAcquire marks an import as running and returns true, or returns false if
+// another import is already running.
+func (lock *ImportLock) tryAcquire(source string, mode importMode, dryRun bool) bool {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	if lock.status.Running {
+		return false
+	}
+
+	lock.status = ImportStatus{
+		Running:   true,
+		Source:    source,
+		Mode:      mode,
+		DryRun:    dryRun,
+		StartedAt: time.Now().UTC(),
+	}
+
+	return true
+}
+
+// release marks the running import as finished, recording impErr's message
+// and counting it in metrics.ImportFailures when it failed, and returns its
+// final status.
+func (lock *ImportLock) release(impErr *importError) ImportStatus {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	lock.status.Running = false
+	lock.status.FinishedAt = time.Now().UTC()
+	if impErr != nil {
+		lock.status.Error = impErr.message
+		metrics.ImportFailures.Inc(impErr.cause())
+	}
+
+	return lock.status
+}
+
+// Status returns a snapshot of the current or most recent import.
+func (lock *ImportLock) Status() ImportStatus {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	return lock.status
+}
+
+// errImportInProgress is returned when an import is attempted while another
+// is running.
+var errImportInProgress = &importError{statusCode: http.StatusConflict, message: "import already in progress"}
@@ -0,0 +1,174 @@
+package cards
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"swucol/csrf"
+	"swucol/database"
+	"swucol/models"
+	"swucol/roles"
+	"swucol/search"
+	"swucol/templates"
+)
+
+// quickMaxOthers is how many further matches the quick-count page lists
+// below the card being counted.
+const quickMaxOthers = 5
+
+// quickCard is the view model rendered by the quick-card template: the card
+// being counted, if any, and other cards matching the search.
+type quickCard struct {
+	Query  string
+	Set    string
+	Card   *models.Card
+	Others []models.Card
+}
+
+// quickPage is the view model rendered by the quick template.
+type quickPage struct {
+	Current   quickCard
+	Theme     string
+	Role      string
+	CSRFToken string
+}
+
+// errInvalidQuickID is returned by loadQuickCard for an id parameter that is
+// not a positive integer.
+var errInvalidQuickID = errors.New("id must be a positive integer")
+
+// loadQuickCard picks the card shown by the quick-count page from the "id",
+// "set" and "q" parameters. A positive id selects that card. A set shows the
+// box view: no card yet, and every card in the set to pick from. Otherwise
+// the first card matching q is shown, an exact name match taking precedence,
+// with a few other matches listed after it. With none of them no card is
+// selected.
+func loadQuickCard(db *database.Database, parameters url.Values) (quickCard, error) {
+	query := parameters.Get("q")
+	rawID := parameters.Get("id")
+	current := quickCard{Query: query, Set: parameters.Get("set")}
+
+	if rawID != "" {
+		id, err := strconv.Atoi(rawID)
+		if err != nil || id <= 0 {
+			return quickCard{}, errInvalidQuickID
+		}
+
+		card, err := db.GetCardByID(id)
+		if err != nil {
+			return quickCard{}, err
+		}
+		current.Card = card
+		return current, nil
+	}
+
+	if current.Set != "" {
+		setCards, err := db.GetCardsBySet(current.Set)
+		if err != nil {
+			return quickCard{}, err
+		}
+		slices.SortFunc(setCards, func(a, b models.Card) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+		current.Others = setCards
+		return current, nil
+	}
+
+	if strings.TrimSpace(query) == "" {
+		return current, nil
+	}
+
+	matches, err := db.SearchCards(query)
+	if err != nil {
+		return quickCard{}, err
+	}
+	if len(matches) == 0 {
+		return current, nil
+	}
+
+	first := 0
+	for i, card := range matches {
+		if strings.EqualFold(card.Name, strings.TrimSpace(query)) {
+			first = i
+			break
+		}
+	}
+	current.Card = &matches[first]
+
+	for i, card := range matches {
+		if i != first && len(current.Others) < quickMaxOthers {
+			current.Others = append(current.Others, card)
+		}
+	}
+
+	return current, nil
+}
+
+// writeQuickCardError responds to a loadQuickCard error, rendered by
+// templates.RenderError with tmpl, with 400 Bad Request for an invalid id or
+// search query, 404 Not Found for an unknown card, and 500 Internal Server
+// Error otherwise.
+func writeQuickCardError(responseWriter http.ResponseWriter, request *http.Request, tmpl templates.Renderer, err error) {
+	switch {
+	case errors.Is(err, errInvalidQuickID):
+		templates.RenderError(responseWriter, request, tmpl, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, database.ErrCardNotFound):
+		templates.RenderError(responseWriter, request, tmpl, "card not found", http.StatusNotFound)
+	case errors.Is(err, search.ErrInvalidQuery):
+		templates.RenderError(responseWriter, request, tmpl, "invalid search query", http.StatusBadRequest)
+	default:
+		slog.ErrorContext(request.Context(), "database error loading quick-count card", "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+	}
+}
+
+// QuickHandler returns an http.HandlerFunc that serves the phone-sized
+// quick-count page at GET /quick. The optional "q", "id" and "set" query
+// parameters preselect a card or box as described by loadQuickCard, so that
+// scanned labels open straight onto a card or box. Returns 400 Bad Request for an
+// invalid id, 404 Not Found for an unknown card, and 500 Internal Server
+// Error for database or template errors.
+func QuickHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		current, err := loadQuickCard(db, request.URL.Query())
+		if err != nil {
+			writeQuickCardError(responseWriter, request, tmpl, err)
+			return
+		}
+
+		page := quickPage{Current: current, Theme: db.Settings().Theme, Role: roles.FromContext(request.Context()), CSRFToken: csrf.Token(request.Context())}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "quick", page); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render quick template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// QuickCardHTMLHandler returns an http.HandlerFunc that handles GET
+// /quick/card/html, rendering the quick-card fragment for the "q", "id" or
+// "set" query parameter. Used by htmx as the quick-count search box is typed in and
+// when another match is picked. Errors are as for QuickHandler.
+func QuickCardHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		current, err := loadQuickCard(db, request.URL.Query())
+		if err != nil {
+			writeQuickCardError(responseWriter, request, tmpl, err)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "quick-card", current); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render quick-card template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package cards
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"swucol/cardservice"
+	"swucol/database"
+)
+
+// UnreleasedSetsHandler returns an http.HandlerFunc that handles GET
+// /sets/unreleased. Returns 200 OK with the sets imported as spoiler-season
+// previews that are not released yet, as JSON, and 500 Internal Server Error
+// for database errors.
+func UnreleasedSetsHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		sets, err := db.GetUnreleasedSets()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading unreleased sets", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(sets); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode unreleased sets", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ReleaseSetHandler returns an http.HandlerFunc that handles POST
+// /sets/{setcode}/release. It releases an unreleased set ahead of (or
+// without) its release date, so that its cards count in the owned
+// statistics like any others. Returns 204 No Content on success, 400 Bad
+// Request for an invalid set code, 404 Not Found when the set is not marked
+// unreleased, and 500 Internal Server Error for database errors.
+func ReleaseSetHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		setCode := strings.ToUpper(strings.TrimSpace(request.PathValue("setcode")))
+		if !cardservice.IsSetCode(setCode) {
+			http.Error(responseWriter, "setcode must be 2 to 5 letters or digits", http.StatusBadRequest)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "releasing set", "set", setCode)
+
+		if err := db.ReleaseSet(setCode); errors.Is(err, database.ErrSetNotUnreleased) {
+			http.Error(responseWriter, "set "+setCode+" is not unreleased", http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.ErrorContext(request.Context(), "database error releasing set", "set", setCode, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,44 @@
+package cards_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/cards"
+	"swucol/internal/testdb"
+)
+
+// postReleaseSet sends POST /sets/{setcode}/release to ReleaseSetHandler.
+func postReleaseSet(t *testing.T, handler http.HandlerFunc, setCode string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/sets/"+setCode+"/release", nil)
+	request.SetPathValue("setcode", setCode)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+
+	return recorder
+}
+
+func TestReleaseSetHandler_ReleasesUnreleasedSet(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.MarkSetUnreleased("LOF", ""))
+
+	recorder := httptest.NewRecorder()
+	cards.UnreleasedSetsHandler(db)(recorder, httptest.NewRequest(http.MethodGet, "/sets/unreleased", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `[{"set": "LOF", "release_date": ""}]`, recorder.Body.String())
+
+	handler := cards.ReleaseSetHandler(db)
+	assert.Equal(t, http.StatusNoContent, postReleaseSet(t, handler, "lof").Code)
+	assert.Equal(t, http.StatusNotFound, postReleaseSet(t, handler, "LOF").Code)
+	assert.Equal(t, http.StatusBadRequest, postReleaseSet(t, handler, "not-a-set").Code)
+
+	sets, err := db.GetUnreleasedSets()
+	require.NoError(t, err)
+	assert.Empty(t, sets)
+}
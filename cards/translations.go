@@ -0,0 +1,69 @@
+package cards
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"swucol/database"
+)
+
+// translationsRequest is the JSON body of POST /cards/translations: the
+// localized names in Language keyed by English card name.
+type translationsRequest struct {
+	Language string            `json:"language"`
+	Names    map[string]string `json:"names"`
+}
+
+// translationsResponse is the JSON response of POST /cards/translations.
+// Unknown lists the English names that matched no card.
+type translationsResponse struct {
+	Language string   `json:"language"`
+	Updated  int      `json:"updated"`
+	Unknown  []string `json:"unknown"`
+}
+
+// ImportTranslationsHandler returns an http.HandlerFunc that handles POST
+// /cards/translations with a JSON body such as
+// {"language":"de","names":{"Luke Skywalker, Faithful Friend":"Luke Skywalker, Treuer Freund"}}.
+// It stores the localized names so that searches in that language find the
+// cards; a blank name removes the card's translation. Names matching no card
+// are reported rather than rejected. Returns 200 OK with the number of cards
+// updated, 400 Bad Request for a malformed body or invalid language, and 500
+// Internal Server Error for database errors.
+func ImportTranslationsHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		var payload translationsRequest
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		language, err := database.NormalizeLanguage(payload.Language)
+		if err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if len(payload.Names) == 0 {
+			http.Error(responseWriter, "names must not be empty", http.StatusBadRequest)
+			return
+		}
+
+		updated, unknown, err := db.SetCardTranslations(language, payload.Names)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error storing card translations", "language", language, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "card translations imported", "language", language, "updated", updated, "unknown", len(unknown))
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(translationsResponse{Language: language, Updated: updated, Unknown: unknown}); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode translations response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
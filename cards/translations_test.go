@@ -0,0 +1,71 @@
+package cards_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/cards"
+	"swucol/internal/testdb"
+	"swucol/models"
+)
+
+// postTranslations sends body to ImportTranslationsHandler.
+func postTranslations(t *testing.T, handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/translations", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+
+	return recorder
+}
+
+func TestImportTranslationsHandler_StoresNamesForSearch(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Faithful Friend", Mainboard: false}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Battlefield Marine", Mainboard: true}))
+
+	handler := cards.ImportTranslationsHandler(db)
+	recorder := postTranslations(t, handler, `{"language": "DE", "names": {
+		"Luke Skywalker, Faithful Friend": "Luke Skywalker, Treuer Freund",
+		"Battlefield Marine": "Schlachtfeld-Marine",
+		"Unknown Card": "Unbekannte Karte"
+	}}`)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"language": "de", "updated": 2, "unknown": ["Unknown Card"]}`, recorder.Body.String())
+
+	found, err := db.SearchCards("treuer")
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "Luke Skywalker, Faithful Friend", found[0].Name)
+
+	translations, err := db.GetCardTranslations(found[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, []models.CardTranslation{{Language: "de", Name: "Luke Skywalker, Treuer Freund"}}, translations)
+
+	recorder = postTranslations(t, handler, `{"language": "de", "names": {"Luke Skywalker, Faithful Friend": ""}}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	found, err = db.SearchCards("treuer")
+	require.NoError(t, err)
+	assert.Empty(t, found, "expected a blank name to remove the translation")
+}
+
+func TestImportTranslationsHandler_InvalidBody_Returns400(t *testing.T) {
+	db := testdb.New(t)
+	handler := cards.ImportTranslationsHandler(db)
+
+	for _, body := range []string{
+		`not json`,
+		`{"language": "german!", "names": {"Battlefield Marine": "Schlachtfeld-Marine"}}`,
+		`{"language": "de", "names": {}}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, postTranslations(t, handler, body).Code, body)
+	}
+}
@@ -0,0 +1,154 @@
+package cards
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"swucol/cardservice"
+	"swucol/database"
+)
+
+// WatchArchiveDir is the subdirectory of the watch folder that imported files
+// are moved to.
+const WatchArchiveDir = "archive"
+
+// watchSettleTime is how long a file in the watch folder must go unmodified
+// before it is imported, so that a file still being written or synced is not
+// read half-way.
+const watchSettleTime = 10 * time.Second
+
+// pendingWatchFiles returns the paths of the CSV files directly inside dir
+// that have not been modified since watchSettleTime before now, sorted by
+// name.
+func pendingWatchFiles(dir string, now time.Time) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read watch folder: %w", err)
+	}
+
+	paths := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.EqualFold(filepath.Ext(entry.Name()), ".csv") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if now.Sub(info.ModTime()) < watchSettleTime {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// archiveWatchedFile moves path into the archive subdirectory of its folder,
+// prefixing its name with importedAt so that a later export with the same
+// name does not overwrite it.
+func archiveWatchedFile(path string, importedAt time.Time) error {
+	archiveDir := filepath.Join(filepath.Dir(path), WatchArchiveDir)
+	if err := os.MkdirAll(archiveDir, 0o755); err != nil {
+		return fmt.Errorf("create archive folder: %w", err)
+	}
+
+	target := filepath.Join(archiveDir, importedAt.UTC().Format("20060102-150405")+"-"+filepath.Base(path))
+	if err := os.Rename(path, target); err != nil {
+		return fmt.Errorf("archive %s: %w", filepath.Base(path), err)
+	}
+
+	return nil
+}
+
+// ImportWatchFolder imports every CSV file waiting in dir as an insert import
+// with the import defaults from the settings, records each in the import
+// history with source "watch", and moves it to the archive subdirectory. A
+// file rejected as invalid is archived too, with the reason in its history
+// entry; a file whose import failed on a database error is left in place to
+// be retried. Returns the number of files imported. When another import holds
+// lock, ImportWatchFolder stops and the remaining files wait for the next
+// call.
+func ImportWatchFolder(ctx context.Context, db *database.Database, lock *ImportLock, httpClient *http.Client, imagesDir string, imageBaseURLs []string, dir string) (int, error) {
+	paths, err := pendingWatchFiles(dir, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	for _, path := range paths {
+		settings := db.Settings()
+		options := importOptions{mode: importModeInsert, lenient: settings.ImportLenient, useOwnedCount: settings.ImportUseOwnedCount}
+
+		if !lock.tryAcquire("watch", options.mode, false) {
+			slog.InfoContext(ctx, "watch folder import deferred, another import is in progress", "file", filepath.Base(path))
+			return imported, nil
+		}
+
+		result, kept, impErr := importWatchedFile(ctx, db, httpClient, imagesDir, imageBaseURLs, path, options, settings.ImportKeepFiles)
+		status := lock.release(impErr)
+		recordImport(ctx, db, status, filepath.Base(path), kept, result, nil)
+
+		if impErr != nil && impErr.statusCode >= http.StatusInternalServerError {
+			return imported, fmt.Errorf("import %s: %s", filepath.Base(path), impErr.message)
+		}
+
+		if err := archiveWatchedFile(path, status.StartedAt); err != nil {
+			return imported, err
+		}
+
+		if impErr != nil {
+			slog.WarnContext(ctx, "watch folder file rejected", "file", filepath.Base(path), "message", impErr.message)
+			continue
+		}
+		imported++
+	}
+
+	return imported, nil
+}
+
+// importWatchedFile runs importCards on the file at path, also returning
+// the file's contents when keep is true.
+func importWatchedFile(ctx context.Context, db *database.Database, httpClient *http.Client, imagesDir string, imageBaseURLs []string, path string, options importOptions, keep bool) (*cardservice.ImportResult, []byte, *importError) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, &importError{statusCode: http.StatusInternalServerError, message: "open file: " + err.Error()}
+	}
+	defer file.Close()
+
+	slog.InfoContext(ctx, "importing file from watch folder", "file", filepath.Base(path))
+
+	reader, kept, impErr := keepImportFile(file, keep)
+	if impErr != nil {
+		return nil, nil, impErr
+	}
+
+	result, impErr := importCards(ctx, cardservice.NewImportService(db, httpClient, imagesDir, imageBaseURLs), reader, options)
+	return result, kept, impErr
+}
+
+// ScheduleWatchFolder calls ImportWatchFolder every interval until ctx is
+// cancelled. Failures are logged and retried at the next tick.
+func ScheduleWatchFolder(ctx context.Context, interval time.Duration, db *database.Database, lock *ImportLock, httpClient *http.Client, imagesDir string, imageBaseURLs []string, dir string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := ImportWatchFolder(ctx, db, lock, httpClient, imagesDir, imageBaseURLs, dir); err != nil {
+				slog.ErrorContext(ctx, "watch folder import failed", "error", err)
+			}
+		}
+	}
+}
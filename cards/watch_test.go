@@ -0,0 +1,88 @@
+package cards_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/cards"
+	"swucol/internal/testdb"
+)
+
+// writeWatchedFile writes content to name in dir and backdates it so that the
+// watch folder treats it as finished.
+func writeWatchedFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, os.Chtimes(path, past, past))
+
+	return path
+}
+
+func TestImportWatchFolder_ImportsAndArchivesFiles(t *testing.T) {
+	db := testdb.New(t)
+	dir := t.TempDir()
+	path := writeWatchedFile(t, dir, "export.csv", validCSVHeader+"\n"+
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0")
+
+	imported, err := cards.ImportWatchFolder(t.Context(), db, cards.NewImportLock(), http.DefaultClient, t.TempDir(), nil, dir)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, imported)
+	exists, err := db.CardExistsByName("Chewbacca, Hero of Kessel")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.NoFileExists(t, path)
+	archived, err := filepath.Glob(filepath.Join(dir, cards.WatchArchiveDir, "*-export.csv"))
+	require.NoError(t, err)
+	assert.Len(t, archived, 1)
+
+	records, err := db.GetImportRecords(10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "watch", records[0].Source)
+	assert.Equal(t, "export.csv", records[0].FileName)
+	assert.Equal(t, 1, records[0].Inserted)
+	assert.Empty(t, records[0].Error)
+}
+
+func TestImportWatchFolder_InvalidFile_ArchivedWithError(t *testing.T) {
+	db := testdb.New(t)
+	dir := t.TempDir()
+	path := writeWatchedFile(t, dir, "broken.csv", "not,a,card,export\n")
+
+	imported, err := cards.ImportWatchFolder(t.Context(), db, cards.NewImportLock(), http.DefaultClient, t.TempDir(), nil, dir)
+
+	require.NoError(t, err)
+	assert.Zero(t, imported)
+	assert.NoFileExists(t, path)
+
+	records, err := db.GetImportRecords(10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Contains(t, records[0].Error, "invalid CSV")
+}
+
+func TestImportWatchFolder_SkipsRecentAndNonCSVFiles(t *testing.T) {
+	db := testdb.New(t)
+	dir := t.TempDir()
+	recent := filepath.Join(dir, "still-writing.csv")
+	require.NoError(t, os.WriteFile(recent, []byte(validCSVHeader+"\n"), 0o644))
+	notes := writeWatchedFile(t, dir, "notes.txt", "hello")
+
+	imported, err := cards.ImportWatchFolder(t.Context(), db, cards.NewImportLock(), http.DefaultClient, t.TempDir(), nil, dir)
+
+	require.NoError(t, err)
+	assert.Zero(t, imported)
+	assert.FileExists(t, recent)
+	assert.FileExists(t, notes)
+}
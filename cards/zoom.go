@@ -0,0 +1,76 @@
+package cards
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"swucol/database"
+	"swucol/models"
+	"swucol/templates"
+)
+
+// cardZoom is the view model rendered by the card-zoom template.
+type cardZoom struct {
+	Card         *models.Card
+	Aspects      []string
+	Traits       []string
+	Translations []models.CardTranslation
+}
+
+// CardZoomHTMLHandler returns an http.HandlerFunc that handles GET
+// /cards/{id}/zoom. It renders the card-zoom fragment with the card's
+// full-size image (and back face, if any) and its details, including the
+// gameplay attributes synced from the catalog and any localized names, which
+// the collection page shows in a modal when a tile is clicked. Returns 400 Bad
+// Request for a non-positive-integer id, 404 Not Found when no card with that
+// id exists, and 500 Internal Server Error for database or template errors.
+func CardZoomHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, err := strconv.Atoi(request.PathValue("id"))
+		if err != nil || id <= 0 {
+			templates.RenderError(responseWriter, request, tmpl, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		card, err := db.GetCardByID(id)
+		if errors.Is(err, database.ErrCardNotFound) {
+			templates.RenderError(responseWriter, request, tmpl, "card not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error fetching card", "id", id, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		aspects, err := db.GetCardAspects(id)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error fetching card aspects", "id", id, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		traits, err := db.GetCardTraits(id)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error fetching card traits", "id", id, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		translations, err := db.GetCardTranslations(id)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error fetching card translations", "id", id, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "card-zoom", cardZoom{Card: card, Aspects: aspects, Traits: traits, Translations: translations}); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render card-zoom template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
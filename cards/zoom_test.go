@@ -0,0 +1,85 @@
+package cards_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/cards"
+	"swucol/internal/testdb"
+	"swucol/models"
+)
+
+// getCardZoom sends GET /cards/{id}/zoom to CardZoomHTMLHandler and returns
+// the recorder.
+func getCardZoom(t *testing.T, handler http.HandlerFunc, id string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodGet, "/cards/"+id+"/zoom", nil)
+	request.SetPathValue("id", id)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+
+	return recorder
+}
+
+func TestCardZoomHTMLHandler_RendersImageAndDetails(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{
+		Name: "Mace Windu, Vaapad Form Master", Image: "images/TWI013.jpg", BackImage: "images/TWI013-back.jpg",
+		Set: "TWI", Number: "013", Type: "Leader", Aspects: "Aggression|Heroism", Rarity: "Rare",
+	}))
+
+	recorder := getCardZoom(t, cards.CardZoomHTMLHandler(db, newTestTemplates(t)), "1")
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "text/html")
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	html := string(body)
+	assert.Contains(t, html, "Mace Windu, Vaapad Form Master")
+	assert.Contains(t, html, `src="/images/TWI013.jpg"`)
+	assert.Contains(t, html, `src="/images/TWI013-back.jpg"`)
+	assert.Contains(t, html, "TWI 013")
+	assert.Contains(t, html, `<span class="aspect-icon aspect-aggression" title="Aggression">▲</span> Aggression, <span class="aspect-icon aspect-heroism" title="Heroism">✦</span> Heroism`)
+	assert.Contains(t, html, "Rare")
+}
+
+func TestCardZoomHTMLHandler_RendersGameplayAttributes(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Battlefield Marine", Type: "Unit"}))
+	cost, power, hp := 2, 3, 3
+	_, err := db.SetCardAttributes(map[string]models.CardAttributes{
+		"Battlefield Marine": {Cost: &cost, Power: &power, HP: &hp, Traits: "Rebel|Trooper", Text: "A loyal soldier."},
+	})
+	require.NoError(t, err)
+
+	recorder := getCardZoom(t, cards.CardZoomHTMLHandler(db, newTestTemplates(t)), "1")
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	html := recorder.Body.String()
+	assert.Contains(t, html, "<dt>Cost</dt><dd>2</dd>")
+	assert.Contains(t, html, "<dt>HP</dt><dd>3</dd>")
+	assert.Contains(t, html, "REBEL, TROOPER")
+	assert.Contains(t, html, "A loyal soldier.")
+}
+
+func TestCardZoomHTMLHandler_UnknownCard_Returns404(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := getCardZoom(t, cards.CardZoomHTMLHandler(db, newTestTemplates(t)), "42")
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestCardZoomHTMLHandler_InvalidID_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := getCardZoom(t, cards.CardZoomHTMLHandler(db, newTestTemplates(t)), "abc")
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
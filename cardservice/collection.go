@@ -0,0 +1,424 @@
+package cardservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+
+	"swucol/database"
+	"swucol/eventbus"
+	"swucol/models"
+	"swucol/search"
+)
+
+// CollectionService changes the cards in the collection and compares the
+// collection with CSV exports.
+type CollectionService struct {
+	db *database.Database
+}
+
+// NewCollectionService returns a CollectionService for the cards in db.
+func NewCollectionService(db *database.Database) *CollectionService {
+	return &CollectionService{db: db}
+}
+
+// IncrementOwned adds 1 to the owned count of the card with id, publishes
+// the change as an eventbus.OwnedChanged event and returns the updated card.
+// Returns an *Error of KindNotFound when no card has that id, or KindInternal
+// for database errors.
+func (service *CollectionService) IncrementOwned(ctx context.Context, id int) (*models.Card, error) {
+	return service.changeOwned(ctx, id, models.AuditIncrement, service.db.IncrementCardOwned)
+}
+
+// DecrementOwned subtracts 1 from the owned count of the card with id,
+// clamping at 0 so it never goes negative, publishes the change as an
+// eventbus.OwnedChanged event and returns the updated card. Unless confirmed
+// is true, a decrement taking the count below the DecrementFloor setting is
+// refused with an *Error of KindConfirm and nothing changes. Returns an
+// *Error of KindNotFound when no card has that id, or KindInternal for
+// database errors.
+func (service *CollectionService) DecrementOwned(ctx context.Context, id int, confirmed bool) (*models.Card, error) {
+	if floor := service.db.Settings().DecrementFloor; floor > 0 && !confirmed {
+		card, err := service.db.GetCardByID(id)
+		if errors.Is(err, database.ErrCardNotFound) {
+			return nil, errCardNotFound
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "database error loading card before decrement", "card_id", id, "error", err)
+			return nil, errDatabase
+		}
+		if card.Owned > 0 && card.Owned-1 < floor {
+			return nil, &Error{Kind: KindConfirm, Message: fmt.Sprintf("decrementing %s below %d copies must be confirmed with confirm=true", card.Name, floor)}
+		}
+	}
+
+	return service.changeOwned(ctx, id, models.AuditDecrement, service.db.DecrementCardOwned)
+}
+
+// changeOwned applies change, an increment or decrement with the audit action
+// cause, to the card with id.
+func (service *CollectionService) changeOwned(ctx context.Context, id int, cause string, change func(id int) error) (*models.Card, error) {
+	if err := change(id); errors.Is(err, database.ErrCardNotFound) {
+		return nil, errCardNotFound
+	} else if err != nil {
+		slog.ErrorContext(ctx, "database error changing owned count", "card_id", id, "cause", cause, "error", err)
+		return nil, errDatabase
+	}
+
+	card, err := service.db.GetCardByID(id)
+	if err != nil {
+		slog.ErrorContext(ctx, "database error fetching card after owned count change", "card_id", id, "cause", cause, "error", err)
+		return nil, errDatabase
+	}
+
+	eventbus.Publish(ctx, eventbus.Event{Type: eventbus.OwnedChanged, CardID: card.ID, CardName: card.Name, Cause: cause, Owned: card.Owned})
+
+	return card, nil
+}
+
+// MaxOwnedOperations is the most operations ReplayOwned accepts at once.
+const MaxOwnedOperations = 1000
+
+// ReplayOwned applies operations, increments and decrements a device queued
+// while offline, in the order they were made, and returns the outcome of each
+// in that order. Operations are merged onto the current owned counts rather
+// than overwriting them, so changes made elsewhere in the meantime are kept:
+// an operation whose BaseOwned differs from the count it is applied to is
+// reported as models.OperationConflict, and a decrement of a count already at
+// 0 as models.OperationClamped. An operation whose ID was applied before is
+// skipped as models.OperationDuplicate, so a device can safely send its queue
+// again when it never saw the response. Operations without an ID, card or
+// known action are models.OperationInvalid, and those for unknown cards
+// models.OperationNotFound. Unless confirmed is true, an operation that would
+// take its card below the DecrementFloor setting, or change it by more than
+// MaxOwnedChange over the whole batch, is not applied and is reported as
+// models.OperationRejected, so that it can be sent again with confirmation;
+// the other operations are still applied. Each change is published as an
+// eventbus.OwnedChanged event. Returns an *Error of KindInvalid for more than
+// MaxOwnedOperations operations, or KindInternal for database errors, after
+// which the operations not yet applied can be sent again.
+func (service *CollectionService) ReplayOwned(ctx context.Context, operations []models.OwnedOperation, confirmed bool) ([]models.OwnedOperationResult, error) {
+	if len(operations) > MaxOwnedOperations {
+		return nil, invalid(fmt.Sprintf("at most %d operations can be sent at once", MaxOwnedOperations))
+	}
+
+	ordered := slices.Clone(operations)
+	slices.SortStableFunc(ordered, func(a, b models.OwnedOperation) int {
+		return a.MadeAt.Compare(b.MadeAt)
+	})
+
+	settings := service.db.Settings()
+	guarded := !confirmed && (settings.DecrementFloor > 0 || settings.MaxOwnedChange > 0)
+	// batchStart holds each card's owned count before the batch's first
+	// operation on it, which the guard measures the batch's changes from.
+	batchStart := make(map[int]int)
+
+	results := make([]models.OwnedOperationResult, 0, len(ordered))
+	for _, operation := range ordered {
+		result := models.OwnedOperationResult{ID: operation.ID, CardID: operation.CardID}
+
+		if operation.ID == "" || operation.CardID <= 0 || (operation.Action != models.AuditIncrement && operation.Action != models.AuditDecrement) {
+			result.Status = models.OperationInvalid
+			results = append(results, result)
+			continue
+		}
+		if operation.MadeAt.IsZero() {
+			operation.MadeAt = time.Now()
+		}
+
+		if guarded {
+			owned, rejected, err := service.rejectOwnedOperation(settings, operation, batchStart)
+			if err != nil {
+				slog.ErrorContext(ctx, "database error checking owned operation", "operation_id", operation.ID, "card_id", operation.CardID, "error", err)
+				return nil, errDatabase
+			}
+			if rejected {
+				result.Status = models.OperationRejected
+				result.Owned = owned
+				results = append(results, result)
+				continue
+			}
+		}
+
+		before, after, applied, err := service.db.ApplyOwnedOperation(operation)
+		if errors.Is(err, database.ErrCardNotFound) {
+			result.Status = models.OperationNotFound
+			results = append(results, result)
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "database error replaying owned operation", "operation_id", operation.ID, "card_id", operation.CardID, "error", err)
+			return nil, errDatabase
+		}
+
+		result.Owned = after
+		switch {
+		case !applied:
+			result.Status = models.OperationDuplicate
+		case operation.Action == models.AuditDecrement && before == 0:
+			result.Status = models.OperationClamped
+		case before != operation.BaseOwned:
+			result.Status = models.OperationConflict
+		default:
+			result.Status = models.OperationApplied
+		}
+		results = append(results, result)
+
+		if after == before {
+			continue
+		}
+
+		// The change has been made, so a failure to load the card for the
+		// event is only logged.
+		card, err := service.db.GetCardByID(operation.CardID)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to load card for event", "type", eventbus.OwnedChanged, "card_id", operation.CardID, "error", err)
+			continue
+		}
+		eventbus.Publish(ctx, eventbus.Event{Type: eventbus.OwnedChanged, CardID: card.ID, CardName: card.Name, Cause: operation.Action, Owned: card.Owned})
+	}
+
+	slog.InfoContext(ctx, "owned operations replayed", "operations", len(results))
+
+	return results, nil
+}
+
+// rejectOwnedOperation reports whether operation must be rejected under the
+// owned count protection settings, together with its card's current owned
+// count. The change is measured from the card's count in batchStart, which it
+// records on the card's first operation of the batch, to the count after
+// operation, so that many small operations cannot add up to a guarded change.
+// Operations applied before or for unknown cards are not rejected, leaving
+// ApplyOwnedOperation to report them.
+func (service *CollectionService) rejectOwnedOperation(settings models.Settings, operation models.OwnedOperation, batchStart map[int]int) (int, bool, error) {
+	applied, err := service.db.OwnedOperationApplied(operation.ID)
+	if err != nil || applied {
+		return 0, false, err
+	}
+
+	card, err := service.db.GetCardByID(operation.CardID)
+	if errors.Is(err, database.ErrCardNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	from, seen := batchStart[card.ID]
+	if !seen {
+		from = card.Owned
+		batchStart[card.ID] = from
+	}
+
+	to := card.Owned + 1
+	if operation.Action == models.AuditDecrement {
+		to = max(card.Owned-1, 0)
+	}
+
+	return card.Owned, to != card.Owned && NeedsConfirmation(settings, from, to), nil
+}
+
+// ApplyPeerCards merges cards received from another instance with
+// database.ApplyRemoteCards and returns the number of cards inserted or
+// updated. Each owned count it changes is published as an
+// eventbus.OwnedChanged event with the cause models.AuditSync. Unless
+// confirmed is true, cards changing an existing card's owned count in a way
+// the owned count protection settings guard (see NeedsConfirmation) are
+// refused with an *Error of KindConfirm and nothing changes. Returns an
+// *Error of KindInvalid for a card without a name or with a negative owned
+// count, or KindInternal for database errors.
+func (service *CollectionService) ApplyPeerCards(ctx context.Context, cards []models.Card, confirmed bool) (int, error) {
+	for _, card := range cards {
+		if card.Name == "" || card.Owned < 0 {
+			return 0, invalid("cards must have a name and a non-negative owned count")
+		}
+	}
+
+	if !confirmed {
+		guarded, err := service.guardedPeerCards(cards)
+		if err != nil {
+			slog.ErrorContext(ctx, "database error checking peer sync changes", "error", err)
+			return 0, errDatabase
+		}
+		if guarded > 0 {
+			return 0, &Error{Kind: KindConfirm, Message: fmt.Sprintf("sync would make %d owned count changes that must be confirmed with confirm=true", guarded)}
+		}
+	}
+
+	result, err := service.db.ApplyRemoteCards(cards)
+	if err != nil {
+		slog.ErrorContext(ctx, "database error applying peer sync changes", "error", err)
+		return 0, errDatabase
+	}
+
+	for _, change := range result.OwnedChanges {
+		eventbus.Publish(ctx, eventbus.Event{Type: eventbus.OwnedChanged, CardName: change.Name, Cause: models.AuditSync, Previous: change.From, Owned: change.To})
+	}
+
+	return result.Applied, nil
+}
+
+// guardedPeerCards returns the number of cards whose owned count differs from
+// the local card's in a way the owned count protection settings guard.
+func (service *CollectionService) guardedPeerCards(cards []models.Card) (int, error) {
+	settings := service.db.Settings()
+	if settings.DecrementFloor == 0 && settings.MaxOwnedChange == 0 {
+		return 0, nil
+	}
+
+	localCounts, err := service.db.GetOwnedCountsByName()
+	if err != nil {
+		return 0, err
+	}
+
+	guarded := 0
+	for _, card := range cards {
+		if localOwned, exists := localCounts[card.Name]; exists && NeedsConfirmation(settings, localOwned, card.Owned) {
+			guarded++
+		}
+	}
+	return guarded, nil
+}
+
+// SetArchived sets the archived flag of the card with id to archived and
+// publishes the change as an eventbus.CardArchived or CardUnarchived event.
+// Archived cards are hidden from search and the wishlist but keep their owned
+// count. Returns an *Error of KindNotFound when no card has that id, or
+// KindInternal for database errors.
+func (service *CollectionService) SetArchived(ctx context.Context, id int, archived bool) error {
+	if err := service.db.SetCardArchived(id, archived); errors.Is(err, database.ErrCardNotFound) {
+		return errCardNotFound
+	} else if err != nil {
+		slog.ErrorContext(ctx, "database error setting card archived flag", "card_id", id, "archived", archived, "error", err)
+		return errDatabase
+	}
+
+	eventType := eventbus.CardUnarchived
+	if archived {
+		eventType = eventbus.CardArchived
+	}
+
+	// The change has been made, so a failure to load the card for the event
+	// is only logged.
+	card, err := service.db.GetCardByID(id)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load card for event", "type", eventType, "card_id", id, "error", err)
+		return nil
+	}
+
+	eventbus.Publish(ctx, eventbus.Event{Type: eventType, CardID: card.ID, CardName: card.Name, Owned: card.Owned})
+
+	return nil
+}
+
+// BulkUpdate applies action to every card matching query, in the syntax of
+// the search package, at once; mainboard is the value database.BulkSetMainboard
+// sets. The update is published as an eventbus.CardsUpdated event. Returns the
+// number of cards changed, or an *Error of KindInvalid for a query that
+// cannot be parsed or an unknown action, or KindInternal for database errors.
+func (service *CollectionService) BulkUpdate(ctx context.Context, query string, action database.BulkAction, mainboard bool) (int, error) {
+	if _, err := search.Parse(query); err != nil {
+		return 0, invalid(err.Error())
+	}
+	if action != database.BulkSetMainboard && action != database.BulkArchive {
+		return 0, invalid("action must be one of: set_mainboard, archive")
+	}
+
+	updated, err := service.db.BulkUpdateCards(query, action, mainboard)
+	if err != nil {
+		slog.ErrorContext(ctx, "database error applying bulk update", "query", query, "action", action, "error", err)
+		return 0, errDatabase
+	}
+
+	slog.InfoContext(ctx, "bulk update applied", "query", query, "action", action, "updated", updated)
+
+	detail := fmt.Sprintf("%s on %d cards matching %q", action, updated, query)
+	if action == database.BulkSetMainboard {
+		detail = fmt.Sprintf("%s=%t on %d cards matching %q", action, mainboard, updated, query)
+	}
+	eventbus.Publish(ctx, eventbus.Event{Type: eventbus.CardsUpdated, Count: updated, Detail: detail})
+
+	return updated, nil
+}
+
+// OwnedMismatch describes a card whose owned count differs between an
+// uploaded CSV and the database.
+type OwnedMismatch struct {
+	Name     string `json:"name"`
+	CSVOwned int    `json:"csv_owned"`
+	DBOwned  int    `json:"db_owned"`
+}
+
+// CollectionDiff is the comparison between an uploaded CSV and the database
+// returned by Diff. All lists are sorted by card name.
+type CollectionDiff struct {
+	OnlyInCSV       []string        `json:"only_in_csv"`
+	OnlyInDB        []string        `json:"only_in_db"`
+	OwnedMismatches []OwnedMismatch `json:"owned_mismatches"`
+}
+
+// Diff parses a SWUDB CSV from reader and compares it against every card in
+// the database (including archived cards), without changing anything.
+// Returns an *Error of KindInvalid for invalid CSV input or KindInternal for
+// database errors.
+func (service *CollectionService) Diff(ctx context.Context, reader io.Reader) (*CollectionDiff, error) {
+	csvCards, err := parseCardsCSV(reader)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to parse CSV for diff", "error", err)
+		return nil, invalid("invalid CSV: " + err.Error())
+	}
+
+	csvCounts, err := csvOwnedCountsByName(csvCards)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid owned count in CSV for diff", "error", err)
+		return nil, invalid("invalid CSV: " + err.Error())
+	}
+
+	dbCounts, err := service.db.GetOwnedCountsByName()
+	if err != nil {
+		slog.ErrorContext(ctx, "database error loading owned counts for diff", "error", err)
+		return nil, errDatabase
+	}
+
+	diff := &CollectionDiff{
+		OnlyInCSV:       []string{},
+		OnlyInDB:        []string{},
+		OwnedMismatches: []OwnedMismatch{},
+	}
+
+	for name, csvOwned := range csvCounts {
+		dbOwned, exists := dbCounts[name]
+		if !exists {
+			diff.OnlyInCSV = append(diff.OnlyInCSV, name)
+			continue
+		}
+		if dbOwned != csvOwned {
+			diff.OwnedMismatches = append(diff.OwnedMismatches, OwnedMismatch{Name: name, CSVOwned: csvOwned, DBOwned: dbOwned})
+		}
+	}
+
+	for name := range dbCounts {
+		if _, exists := csvCounts[name]; !exists {
+			diff.OnlyInDB = append(diff.OnlyInDB, name)
+		}
+	}
+
+	sort.Strings(diff.OnlyInCSV)
+	sort.Strings(diff.OnlyInDB)
+	sort.Slice(diff.OwnedMismatches, func(i, j int) bool {
+		return diff.OwnedMismatches[i].Name < diff.OwnedMismatches[j].Name
+	})
+
+	slog.InfoContext(ctx, "collection diff computed",
+		"only_in_csv", len(diff.OnlyInCSV),
+		"only_in_db", len(diff.OnlyInDB),
+		"owned_mismatches", len(diff.OwnedMismatches),
+	)
+
+	return diff, nil
+}
@@ -0,0 +1,242 @@
+package cardservice_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/cardservice"
+	"swucol/database"
+	"swucol/eventbus"
+	"swucol/internal/testdb"
+	"swucol/models"
+)
+
+// insertCard inserts a mainboard card named name and returns its id.
+func insertCard(t *testing.T, db *database.Database, name string) int {
+	t.Helper()
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: name, Mainboard: true}))
+	cards, err := db.SearchCards(name)
+	require.NoError(t, err)
+	require.Len(t, cards, 1)
+
+	return cards[0].ID
+}
+
+func TestChangeOwned_UpdatesCountAndPublishesIt(t *testing.T) {
+	db := testdb.New(t)
+	id := insertCard(t, db, "Chewbacca")
+	published := record(t, eventbus.OwnedChanged)
+	service := cardservice.NewCollectionService(db)
+
+	card, err := service.IncrementOwned(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, card.Owned)
+
+	for range 2 {
+		card, err = service.DecrementOwned(context.Background(), id, false)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 0, card.Owned, "expected the owned count to stop at 0")
+
+	require.Len(t, *published, 3)
+	assert.Equal(t, eventbus.Event{Type: eventbus.OwnedChanged, CardID: id, CardName: "Chewbacca", Cause: models.AuditIncrement, Owned: 1}, (*published)[0])
+	assert.Equal(t, models.AuditDecrement, (*published)[2].Cause)
+
+	_, err = service.IncrementOwned(context.Background(), id+1)
+	assert.Equal(t, cardservice.KindNotFound, cardservice.KindOf(err))
+	assert.Len(t, *published, 3)
+}
+
+func TestDecrementOwned_BelowFloor_NeedsConfirming(t *testing.T) {
+	db := testdb.New(t)
+	id := insertCard(t, db, "Chewbacca")
+	settings := db.Settings()
+	settings.DecrementFloor = 2
+	require.NoError(t, db.SaveSettings(settings))
+	service := cardservice.NewCollectionService(db)
+
+	for range 3 {
+		_, err := service.IncrementOwned(context.Background(), id)
+		require.NoError(t, err)
+	}
+
+	card, err := service.DecrementOwned(context.Background(), id, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, card.Owned)
+
+	_, err = service.DecrementOwned(context.Background(), id, false)
+	assert.Equal(t, cardservice.KindConfirm, cardservice.KindOf(err))
+	unchanged, err := db.GetCardByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, 2, unchanged.Owned)
+
+	card, err = service.DecrementOwned(context.Background(), id, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, card.Owned)
+}
+
+func TestReplayOwned_MergesOperationsInTheOrderMade(t *testing.T) {
+	db := testdb.New(t)
+	id := insertCard(t, db, "Chewbacca")
+	published := record(t, eventbus.OwnedChanged)
+	service := cardservice.NewCollectionService(db)
+
+	// Another page added a copy while the device was offline.
+	_, err := service.IncrementOwned(context.Background(), id)
+	require.NoError(t, err)
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	operations := []models.OwnedOperation{
+		{ID: "b", CardID: id, Action: models.AuditIncrement, BaseOwned: 1, MadeAt: start.Add(time.Minute)},
+		{ID: "a", CardID: id, Action: models.AuditIncrement, BaseOwned: 0, MadeAt: start},
+		{ID: "c", CardID: id + 1, Action: models.AuditIncrement, MadeAt: start},
+		{ID: "", CardID: id, Action: models.AuditIncrement, MadeAt: start},
+	}
+
+	results, err := service.ReplayOwned(context.Background(), operations, false)
+	require.NoError(t, err)
+	assert.Equal(t, []models.OwnedOperationResult{
+		{ID: "a", CardID: id, Status: models.OperationConflict, Owned: 2},
+		{ID: "c", CardID: id + 1, Status: models.OperationNotFound},
+		{ID: "", CardID: id, Status: models.OperationInvalid},
+		{ID: "b", CardID: id, Status: models.OperationConflict, Owned: 3},
+	}, results)
+	require.Len(t, *published, 3)
+	assert.Equal(t, eventbus.Event{Type: eventbus.OwnedChanged, CardID: id, CardName: "Chewbacca", Cause: models.AuditIncrement, Owned: 3}, (*published)[2])
+
+	// The device never saw the response and sends its queue again.
+	results, err = service.ReplayOwned(context.Background(), operations[:2], false)
+	require.NoError(t, err)
+	assert.Equal(t, models.OperationDuplicate, results[0].Status)
+	assert.Equal(t, 3, results[1].Owned)
+	assert.Len(t, *published, 3)
+}
+
+func TestReplayOwned_ReportsAppliedAndClampedOperations(t *testing.T) {
+	db := testdb.New(t)
+	id := insertCard(t, db, "Chewbacca")
+	service := cardservice.NewCollectionService(db)
+
+	results, err := service.ReplayOwned(context.Background(), []models.OwnedOperation{
+		{ID: "a", CardID: id, Action: models.AuditIncrement, BaseOwned: 0},
+		{ID: "b", CardID: id, Action: models.AuditDecrement, BaseOwned: 1},
+		{ID: "c", CardID: id, Action: models.AuditDecrement, BaseOwned: 0},
+	}, false)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, models.OperationApplied, results[0].Status)
+	assert.Equal(t, models.OperationApplied, results[1].Status)
+	assert.Equal(t, models.OperationClamped, results[2].Status)
+	assert.Equal(t, 0, results[2].Owned)
+
+	_, err = service.ReplayOwned(context.Background(), make([]models.OwnedOperation, cardservice.MaxOwnedOperations+1), false)
+	assert.Equal(t, cardservice.KindInvalid, cardservice.KindOf(err))
+}
+
+func TestReplayOwned_GuardedOperations_AreRejectedUnlessConfirmed(t *testing.T) {
+	db := testdb.New(t)
+	chewbacca := insertCard(t, db, "Chewbacca")
+	han := insertCard(t, db, "Han Solo")
+	settings := db.Settings()
+	settings.DecrementFloor = 1
+	settings.MaxOwnedChange = 2
+	require.NoError(t, db.SaveSettings(settings))
+	service := cardservice.NewCollectionService(db)
+	_, err := service.IncrementOwned(context.Background(), chewbacca)
+	require.NoError(t, err)
+
+	// A script adding copies one operation at a time still hits the batch's
+	// limit, and the decrement below the floor is held back too.
+	operations := []models.OwnedOperation{
+		{ID: "a", CardID: han, Action: models.AuditIncrement},
+		{ID: "b", CardID: han, Action: models.AuditIncrement, BaseOwned: 1},
+		{ID: "c", CardID: han, Action: models.AuditIncrement, BaseOwned: 2},
+		{ID: "d", CardID: chewbacca, Action: models.AuditDecrement, BaseOwned: 1},
+	}
+
+	results, err := service.ReplayOwned(context.Background(), operations, false)
+	require.NoError(t, err)
+	assert.Equal(t, []models.OwnedOperationResult{
+		{ID: "a", CardID: han, Status: models.OperationApplied, Owned: 1},
+		{ID: "b", CardID: han, Status: models.OperationApplied, Owned: 2},
+		{ID: "c", CardID: han, Status: models.OperationRejected, Owned: 2},
+		{ID: "d", CardID: chewbacca, Status: models.OperationRejected, Owned: 1},
+	}, results)
+
+	// Rejected operations were not recorded, so they can be sent again.
+	results, err = service.ReplayOwned(context.Background(), operations, true)
+	require.NoError(t, err)
+	assert.Equal(t, models.OperationDuplicate, results[0].Status)
+	assert.Equal(t, models.OperationApplied, results[2].Status)
+	assert.Equal(t, 3, results[2].Owned)
+	assert.Equal(t, models.OperationApplied, results[3].Status)
+	assert.Equal(t, 0, results[3].Owned)
+}
+
+func TestSetArchived_PublishesArchiveAndRestore(t *testing.T) {
+	db := testdb.New(t)
+	id := insertCard(t, db, "Chewbacca")
+	published := record(t, eventbus.CardArchived, eventbus.CardUnarchived)
+	service := cardservice.NewCollectionService(db)
+
+	require.NoError(t, service.SetArchived(context.Background(), id, true))
+	archived, err := db.GetArchivedCards("")
+	require.NoError(t, err)
+	assert.Len(t, archived, 1)
+
+	require.NoError(t, service.SetArchived(context.Background(), id, false))
+	assert.Equal(t, cardservice.KindNotFound, cardservice.KindOf(service.SetArchived(context.Background(), id+1, true)))
+
+	require.Len(t, *published, 2)
+	assert.Equal(t, eventbus.CardArchived, (*published)[0].Type)
+	assert.Equal(t, eventbus.CardUnarchived, (*published)[1].Type)
+}
+
+func TestBulkUpdate(t *testing.T) {
+	db := testdb.New(t)
+	insertCard(t, db, "Chewbacca")
+	insertCard(t, db, "Han Solo")
+	published := record(t, eventbus.CardsUpdated)
+	service := cardservice.NewCollectionService(db)
+
+	updated, err := service.BulkUpdate(context.Background(), "chew", database.BulkSetMainboard, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, []eventbus.Event{{Type: eventbus.CardsUpdated, Count: 1, Detail: `set_mainboard=false on 1 cards matching "chew"`}}, *published)
+
+	_, err = service.BulkUpdate(context.Background(), "chew", "delete", false)
+	assert.Equal(t, cardservice.KindInvalid, cardservice.KindOf(err))
+
+	_, err = service.BulkUpdate(context.Background(), `name:"chew`, database.BulkArchive, false)
+	assert.Equal(t, cardservice.KindInvalid, cardservice.KindOf(err))
+	assert.Len(t, *published, 1)
+}
+
+func TestDiff_ComparesCSVWithCollection(t *testing.T) {
+	db := testdb.New(t)
+	id := insertCard(t, db, "Chewbacca, Hero of Kessel")
+	insertCard(t, db, "Luke Skywalker, Jedi Knight")
+	_, err := cardservice.NewCollectionService(db).IncrementOwned(context.Background(), id)
+	require.NoError(t, err)
+
+	csv := csvHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,2,0\n" +
+		"LAW,002,Han Solo,,Unit,Heroism,Normal,Rare,false,,Artist Two,1,0"
+
+	diff, err := cardservice.NewCollectionService(db).Diff(context.Background(), strings.NewReader(csv))
+	require.NoError(t, err)
+	assert.Equal(t, &cardservice.CollectionDiff{
+		OnlyInCSV:       []string{"Han Solo"},
+		OnlyInDB:        []string{"Luke Skywalker, Jedi Knight"},
+		OwnedMismatches: []cardservice.OwnedMismatch{{Name: "Chewbacca, Hero of Kessel", CSVOwned: 2, DBOwned: 1}},
+	}, diff)
+
+	_, err = cardservice.NewCollectionService(db).Diff(context.Background(), strings.NewReader(csvHeader+"\nLAW,001,Chewbacca,,Unit,,Normal,Rare,false,,,many,0"))
+	assert.Equal(t, cardservice.KindInvalid, cardservice.KindOf(err))
+}
@@ -0,0 +1,209 @@
+package cardservice
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"swucol/images"
+	"swucol/models"
+)
+
+// utf8BOM is the three-byte UTF-8 byte order mark prepended by some editors
+// and spreadsheet applications (e.g. Excel) when exporting CSV files.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// csvColumnCount is the expected number of columns in a valid card CSV.
+const csvColumnCount = 13
+
+// csvHeaderSet is the value expected in the first column of the header row.
+const csvHeaderSet = "Set"
+
+// cardCSVReader reads CardCSV records from a CSV stream one at a time, so
+// that arbitrarily large files can be processed without holding every row in
+// memory.
+type cardCSVReader struct {
+	csvReader *csv.Reader
+}
+
+// newCardCSVReader wraps reader and validates the header row. A UTF-8 BOM at
+// the start of the stream is silently stripped before parsing. Returns an
+// error if the CSV is empty or the header does not match the expected format.
+func newCardCSVReader(reader io.Reader) (*cardCSVReader, error) {
+	if reader == nil {
+		return nil, errors.New("reader must not be nil")
+	}
+
+	// Wrap in a buffered reader so we can peek ahead and strip any UTF-8 BOM
+	// that Excel and similar tools prepend to CSV exports.
+	buffered := bufio.NewReader(reader)
+	if peeked, err := buffered.Peek(len(utf8BOM)); err == nil && bytes.Equal(peeked, utf8BOM) {
+		buffered.Discard(len(utf8BOM))
+	}
+
+	csvReader := csv.NewReader(buffered)
+	csvReader.ReuseRecord = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+
+	if len(header) != csvColumnCount || header[0] != csvHeaderSet {
+		return nil, errors.New("CSV header does not match expected format")
+	}
+
+	return &cardCSVReader{csvReader: csvReader}, nil
+}
+
+// Read returns the next record. It returns io.EOF once every record has been
+// read and an error for a malformed record or one with an unexpected number of
+// columns.
+func (reader *cardCSVReader) Read() (models.CardCSV, error) {
+	record, err := reader.csvReader.Read()
+	if errors.Is(err, io.EOF) {
+		return models.CardCSV{}, io.EOF
+	}
+	if err != nil {
+		return models.CardCSV{}, fmt.Errorf("read CSV record: %w", err)
+	}
+
+	return models.CardCSV{
+		Set:             record[0],
+		CardNumber:      record[1],
+		CardName:        record[2],
+		CardTitle:       record[3],
+		CardType:        record[4],
+		Aspects:         record[5],
+		VariantType:     record[6],
+		Rarity:          record[7],
+		Foil:            record[8],
+		Stamp:           record[9],
+		Artist:          record[10],
+		OwnedCount:      record[11],
+		GroupOwnedCount: record[12],
+	}, nil
+}
+
+// Line returns the 1-based line of the file on which the record most recently
+// returned by Read starts.
+func (reader *cardCSVReader) Line() int {
+	line, _ := reader.csvReader.FieldPos(0)
+	return line
+}
+
+// parseCardsCSV reads a CSV from reader and returns a slice of CardCSV records.
+// The first row must be the header row. Returns an error if the CSV is empty,
+// malformed, or has an unexpected number of columns. A UTF-8 BOM at the start
+// of the stream is silently stripped before parsing.
+func parseCardsCSV(reader io.Reader) ([]models.CardCSV, error) {
+	cardReader, err := newCardCSVReader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []models.CardCSV
+	for {
+		card, err := cardReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		cards = append(cards, card)
+	}
+
+	return cards, nil
+}
+
+// cardCSVToName converts a CardCSV record to the card name used in the database.
+// The name is formed by combining CardName and CardTitle with a comma-space
+// separator. If CardTitle is empty, only CardName is returned.
+func cardCSVToName(card models.CardCSV) string {
+	if strings.TrimSpace(card.CardTitle) == "" {
+		return card.CardName
+	}
+	return card.CardName + ", " + card.CardTitle
+}
+
+// cardCSVToMainboard returns false if the card's type is "Leader" or "Base"
+// (case-insensitive), and true for all other card types. Leaders and Bases are
+// not part of the main deck in Star Wars: Unlimited.
+func cardCSVToMainboard(card models.CardCSV) bool {
+	cardType := strings.TrimSpace(card.CardType)
+	return !strings.EqualFold(cardType, "leader") && !strings.EqualFold(cardType, "base")
+}
+
+// isLeader reports whether card is a leader, whose card has a back face.
+func isLeader(card models.CardCSV) bool {
+	return strings.EqualFold(strings.TrimSpace(card.CardType), "leader")
+}
+
+// cardCSVToPrinting returns the printing of card whose image an import
+// downloads.
+func cardCSVToPrinting(card models.CardCSV) images.Printing {
+	return images.Printing{
+		Set:     card.Set,
+		Number:  card.CardNumber,
+		Variant: strings.TrimSpace(card.VariantType),
+		Foil:    strings.EqualFold(strings.TrimSpace(card.Foil), "true"),
+	}
+}
+
+// cardCSVToNewCard converts a CardCSV record into the NewCard inserted into
+// the database, using imagePath as the stored image path.
+func cardCSVToNewCard(card models.CardCSV, imagePath string) models.NewCard {
+	return models.NewCard{
+		Name:      cardCSVToName(card),
+		Image:     imagePath,
+		Mainboard: cardCSVToMainboard(card),
+		Set:       strings.TrimSpace(card.Set),
+		Number:    strings.TrimSpace(card.CardNumber),
+		Type:      strings.TrimSpace(card.CardType),
+		Aspects:   strings.TrimSpace(card.Aspects),
+		Rarity:    strings.TrimSpace(card.Rarity),
+	}
+}
+
+// csvOwnedCountsByName returns the total Owned Count per card name across all
+// rows in csvCards. Variants of the same card share a name, so their counts
+// are summed. A blank Owned Count is treated as 0. Returns an error naming the
+// first row (1-based, excluding the header) with a non-numeric or negative
+// count.
+func csvOwnedCountsByName(csvCards []models.CardCSV) (map[string]int, error) {
+	counts := make(map[string]int, len(csvCards))
+
+	for index, csvCard := range csvCards {
+		owned, err := parseOwnedCount(csvCard.OwnedCount)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", index+1, err)
+		}
+
+		counts[cardCSVToName(csvCard)] += owned
+	}
+
+	return counts, nil
+}
+
+// parseOwnedCount parses a CSV Owned Count. A blank count is treated as 0.
+// Returns an error for a non-numeric or negative count.
+func parseOwnedCount(rawOwned string) (int, error) {
+	trimmed := strings.TrimSpace(rawOwned)
+	if trimmed == "" {
+		return 0, nil
+	}
+
+	owned, err := strconv.Atoi(trimmed)
+	if err != nil || owned < 0 {
+		return 0, fmt.Errorf("owned count %q must be a non-negative integer", rawOwned)
+	}
+
+	return owned, nil
+}
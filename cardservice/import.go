@@ -0,0 +1,587 @@
+package cardservice
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"swucol/database"
+	"swucol/eventbus"
+	"swucol/images"
+	"swucol/models"
+)
+
+// ImportService imports cards into the collection from SWUDB CSV exports and
+// catalog listings, and syncs owned counts from CSV exports. Callers must not
+// run two imports at once, since each checks which cards exist before
+// inserting them.
+type ImportService struct {
+	db            *database.Database
+	httpClient    *http.Client
+	imagesDir     string
+	imageBaseURLs []string
+}
+
+// NewImportService returns an ImportService storing cards in db and the
+// images of new cards, downloaded with httpClient from imageBaseURLs in
+// order, in imagesDir.
+func NewImportService(db *database.Database, httpClient *http.Client, imagesDir string, imageBaseURLs []string) *ImportService {
+	return &ImportService{db: db, httpClient: httpClient, imagesDir: imagesDir, imageBaseURLs: imageBaseURLs}
+}
+
+// ImportOptions are the options of an insert import.
+type ImportOptions struct {
+	// Lenient skips malformed rows and reports them in the result's
+	// RowErrors instead of rejecting the whole file.
+	Lenient bool
+	// UseOwnedCount sets the owned count of newly inserted cards from the
+	// CSV's Owned Count instead of 0.
+	UseOwnedCount bool
+}
+
+// importBatchSize is the number of distinct cards Import reads from the
+// CSV before checking them against the database and inserting the new ones in
+// a single transaction.
+const importBatchSize = 500
+
+// ImportResult summarises what an insert import did.
+type ImportResult struct {
+	Inserted         int `json:"inserted"`
+	SkippedExisting  int `json:"skipped_existing"`
+	SkippedDuplicate int `json:"skipped_duplicate"`
+	// ImageFailures names the inserted cards whose image could not be
+	// downloaded.
+	ImageFailures []string `json:"image_failures"`
+	// RowErrors lists the rows skipped by a lenient import.
+	RowErrors []RowError `json:"row_errors"`
+}
+
+// RowError describes a CSV row that a lenient import skipped. Line is the
+// 1-based line of the file on which the row starts.
+type RowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// cardImporter holds the state of a single import across batches.
+type cardImporter struct {
+	*ImportService
+
+	// ctx carries the request the import runs for, for logging.
+	ctx context.Context
+
+	// breaker stops image downloads for the rest of the import once the
+	// image host keeps failing; the cards are flagged for images.RetryFailed.
+	breaker *images.Breaker
+
+	// ownedCounts sums the Owned Count of every valid row per card name, and
+	// insertedOwned holds the count each card this import inserted was
+	// inserted with. Both are only tracked when the import uses the CSV's
+	// owned counts.
+	ownedCounts   map[string]int
+	insertedOwned map[string]int
+
+	result ImportResult
+}
+
+// newCardImporter returns a cardImporter with an empty result.
+func (service *ImportService) newCardImporter(ctx context.Context) *cardImporter {
+	return &cardImporter{
+		ImportService: service,
+		ctx:           ctx,
+		breaker:       images.NewBreaker(images.DefaultBreakerThreshold),
+		result:        ImportResult{ImageFailures: make([]string, 0), RowErrors: make([]RowError, 0)},
+	}
+}
+
+// Import streams a SWUDB CSV from reader, and inserts any cards not already
+// in the database along with their set, number, type, aspects and rarity.
+// Cards that already exist have those details backfilled if they were
+// imported before the details were tracked. For each new card, it attempts to
+// download the image from the service's image sources, in order, and save it
+// to its images directory. Downloads are rate-limited to 10 per second. If a
+// download fails, the card is inserted with an empty image. If the image
+// already exists on disk, the download is skipped. Cards that already exist
+// in the database or appear more than once in the CSV are silently skipped.
+//
+// Rows are processed in batches of importBatchSize, so memory use does not
+// grow with the size of the file beyond the set of distinct names seen. Each
+// batch is committed before the next is read, so a malformed row part way
+// through leaves the cards from earlier batches imported. Rows whose values
+// fail validateCardCSV are treated as malformed. When options.Lenient is true,
+// malformed rows are skipped and recorded in the result's RowErrors instead.
+// When options.UseOwnedCount is true, each inserted card's owned count is set
+// to its Owned Count summed across variant rows, and a row with an invalid
+// Owned Count is malformed; cards already in the database keep their counts.
+// The count is inserted with the card, in its batch's transaction, so a
+// failure later in the file never leaves an imported card at 0 for
+// re-imports to skip; only variant rows after that batch are added once the
+// whole file has been read.
+// Each inserted card is published as an eventbus.CardInserted event, and a
+// successful import as eventbus.ImportCompleted. Returns a summary of the
+// import on success, or an *Error of KindInvalid for invalid CSV input or
+// KindInternal for database errors.
+func (service *ImportService) Import(ctx context.Context, reader io.Reader, options ImportOptions) (*ImportResult, error) {
+	cardReader, err := newCardCSVReader(reader)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to parse CSV", "error", err)
+		return nil, invalid("invalid CSV: " + err.Error())
+	}
+
+	importer := service.newCardImporter(ctx)
+	if options.UseOwnedCount {
+		importer.ownedCounts = make(map[string]int)
+		importer.insertedOwned = make(map[string]int)
+	}
+
+	// Track names seen in this request to avoid duplicate inserts.
+	seen := make(map[string]bool)
+
+	rowCount := 0
+	batch := make([]models.CardCSV, 0, importBatchSize)
+
+	for {
+		csvCard, err := cardReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if options.Lenient && errors.As(err, &parseErr) {
+			rowCount++
+			slog.WarnContext(ctx, "skipping malformed CSV row", "line", parseErr.StartLine, "error", parseErr.Err)
+			importer.result.RowErrors = append(importer.result.RowErrors, RowError{Line: parseErr.StartLine, Message: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to parse CSV", "row", rowCount+1, "error", err)
+			return nil, invalid("invalid CSV: " + err.Error())
+		}
+		rowCount++
+
+		owned := 0
+		err = validateCardCSV(csvCard)
+		if err == nil && options.UseOwnedCount {
+			owned, err = parseOwnedCount(csvCard.OwnedCount)
+		}
+		if err != nil {
+			line := cardReader.Line()
+			if !options.Lenient {
+				slog.ErrorContext(ctx, "invalid CSV row", "line", line, "error", err)
+				return nil, invalid(fmt.Sprintf("invalid CSV: line %d: %s", line, err))
+			}
+			slog.WarnContext(ctx, "skipping invalid CSV row", "line", line, "error", err)
+			importer.result.RowErrors = append(importer.result.RowErrors, RowError{Line: line, Message: err.Error()})
+			continue
+		}
+
+		name := cardCSVToName(csvCard)
+		if options.UseOwnedCount {
+			importer.ownedCounts[name] += owned
+		}
+		if seen[name] {
+			slog.DebugContext(ctx, "skipping duplicate in CSV", "name", name)
+			importer.result.SkippedDuplicate++
+			continue
+		}
+		seen[name] = true
+
+		batch = append(batch, csvCard)
+		if len(batch) == importBatchSize {
+			if err := importer.importBatch(batch); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if rowCount == 0 {
+		slog.WarnContext(ctx, "CSV parsed successfully but contains no card rows")
+		return nil, invalid("CSV contains no card rows")
+	}
+
+	if err := importer.importBatch(batch); err != nil {
+		return nil, err
+	}
+
+	// Variant rows of an inserted card may appear in later batches, after the
+	// card was inserted with the rows read so far.
+	counts := make(map[string]int)
+	for name, owned := range importer.insertedOwned {
+		if total := importer.ownedCounts[name]; total != owned {
+			counts[name] = total
+		}
+	}
+	if len(counts) > 0 {
+		if err := service.db.SetOwnedCountsByName(counts); err != nil {
+			slog.ErrorContext(ctx, "database error setting imported owned counts", "count", len(counts), "error", err)
+			return nil, errDatabase
+		}
+	}
+
+	eventbus.Publish(ctx, eventbus.Event{Type: eventbus.ImportCompleted, Count: importer.result.Inserted})
+
+	slog.InfoContext(ctx, "import complete",
+		"row_count", rowCount,
+		"inserted", importer.result.Inserted,
+		"skipped_already_in_db", importer.result.SkippedExisting,
+		"skipped_duplicate_in_csv", importer.result.SkippedDuplicate,
+		"image_failures", len(importer.result.ImageFailures),
+		"row_errors", len(importer.result.RowErrors),
+	)
+
+	return &importer.result, nil
+}
+
+// CatalogCard is a card of a set listing from the online catalog: the row an
+// export of the card would contain and its gameplay attributes.
+type CatalogCard struct {
+	CSV        models.CardCSV
+	Attributes models.CardAttributes
+}
+
+// isAlternateVariant reports whether card is a Hyperspace, Showcase or other
+// alternate printing rather than the normal one. Alternate printings share
+// the normal card's name and have their own numbers.
+func isAlternateVariant(card models.CardCSV) bool {
+	variant := strings.TrimSpace(card.VariantType)
+	return variant != "" && !strings.EqualFold(variant, "normal")
+}
+
+// ImportCatalog imports the cards of a set listing the way a lenient insert
+// import of a CSV would: every card not yet in the collection is inserted
+// with an owned count of 0 and its image, and existing cards have their
+// details backfilled. Alternate variants are counted as duplicates so that
+// each card keeps the number of its normal printing. Cards that fail
+// validateCardCSV are skipped and listed in the result's RowErrors, with Line
+// being the card's 1-based position in the listing. Once the cards are in,
+// the gameplay attributes of every imported or existing card are replaced
+// with the catalog's. Returns an *Error of KindInternal for database errors.
+func (service *ImportService) ImportCatalog(ctx context.Context, catalogCards []CatalogCard) (*ImportResult, error) {
+	importer := service.newCardImporter(ctx)
+
+	seen := make(map[string]bool)
+	batch := make([]models.CardCSV, 0, importBatchSize)
+	attributes := make(map[string]models.CardAttributes)
+
+	for i, catalogCard := range catalogCards {
+		csvCard := catalogCard.CSV
+		if err := validateCardCSV(csvCard); err != nil {
+			slog.WarnContext(ctx, "skipping invalid catalog card", "position", i+1, "error", err)
+			importer.result.RowErrors = append(importer.result.RowErrors, RowError{Line: i + 1, Message: err.Error()})
+			continue
+		}
+
+		name := cardCSVToName(csvCard)
+		if seen[name] || isAlternateVariant(csvCard) {
+			importer.result.SkippedDuplicate++
+			continue
+		}
+		seen[name] = true
+		attributes[name] = catalogCard.Attributes
+
+		batch = append(batch, csvCard)
+		if len(batch) == importBatchSize {
+			if err := importer.importBatch(batch); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if err := importer.importBatch(batch); err != nil {
+		return nil, err
+	}
+
+	updated, err := service.db.SetCardAttributes(attributes)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to store card attributes", "error", err)
+		return nil, errDatabase
+	}
+
+	slog.InfoContext(ctx, "catalog import complete",
+		"card_count", len(catalogCards),
+		"attributes_updated", updated,
+		"inserted", importer.result.Inserted,
+		"skipped_already_in_db", importer.result.SkippedExisting,
+		"skipped_duplicate", importer.result.SkippedDuplicate,
+		"image_failures", len(importer.result.ImageFailures),
+		"row_errors", len(importer.result.RowErrors),
+	)
+
+	return &importer.result, nil
+}
+
+// importBatch imports a batch of CSV rows with distinct names: cards already
+// in the database have their details backfilled and the rest are inserted,
+// with their images, in one transaction.
+func (importer *cardImporter) importBatch(batch []models.CardCSV) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(batch))
+	for i, csvCard := range batch {
+		names[i] = cardCSVToName(csvCard)
+	}
+
+	existing, err := importer.db.GetExistingCardNames(names)
+	if err != nil {
+		slog.ErrorContext(importer.ctx, "database error checking card existence", "batch_size", len(batch), "error", err)
+		return errDatabase
+	}
+
+	newCards := make([]models.NewCard, 0, len(batch))
+	for i, csvCard := range batch {
+		name := names[i]
+
+		if existing[name] {
+			slog.DebugContext(importer.ctx, "skipping card already in database", "name", name)
+			if err := importer.db.FillMissingCardDetails(cardCSVToNewCard(csvCard, "")); err != nil {
+				slog.ErrorContext(importer.ctx, "database error backfilling card details", "name", name, "error", err)
+				return errDatabase
+			}
+			importer.result.SkippedExisting++
+			continue
+		}
+
+		newCard := importer.prepareNewCard(csvCard)
+		newCard.Owned = importer.ownedCounts[name]
+		newCards = append(newCards, newCard)
+	}
+
+	if len(newCards) == 0 {
+		return nil
+	}
+
+	slog.InfoContext(importer.ctx, "inserting cards", "count", len(newCards))
+	if err := importer.db.InsertCards(newCards); err != nil {
+		slog.ErrorContext(importer.ctx, "database error inserting cards", "count", len(newCards), "error", err)
+		return errDatabase
+	}
+	importer.result.Inserted += len(newCards)
+	for _, newCard := range newCards {
+		if importer.insertedOwned != nil {
+			importer.insertedOwned[newCard.Name] = newCard.Owned
+		}
+		if newCard.ImageFailed {
+			importer.result.ImageFailures = append(importer.result.ImageFailures, newCard.Name)
+		}
+		eventbus.Publish(importer.ctx, eventbus.Event{Type: eventbus.CardInserted, CardName: newCard.Name})
+	}
+
+	return nil
+}
+
+// prepareNewCard converts csvCard into a NewCard, downloading its image and
+// generating its thumbnail when needed.
+func (importer *cardImporter) prepareNewCard(csvCard models.CardCSV) models.NewCard {
+	name := cardCSVToName(csvCard)
+	imagePath := ""
+	imageSource := ""
+	imageFailed := false
+
+	filePath, pathErr := images.FilePath(importer.imagesDir, csvCard.Set, csvCard.CardNumber)
+	if pathErr == nil {
+		if existingPath, exists := images.ExistingFilePath(importer.imagesDir, csvCard.Set, csvCard.CardNumber); !exists {
+			if !importer.breaker.Allow() {
+				slog.DebugContext(importer.ctx, "image host unavailable, leaving image to the retry job", "name", name)
+				imageFailed = true
+			} else {
+				slog.InfoContext(importer.ctx, "downloading image", "name", name)
+				source, dlErr := images.DownloadFromSources(importer.ctx, importer.httpClient, importer.imageBaseURLs, cardCSVToPrinting(csvCard), filePath, images.DefaultRetryPolicy)
+				if dlErr == nil {
+					slog.InfoContext(importer.ctx, "image downloaded", "name", name, "path", filePath, "source", source)
+					imagePath = images.OptimizeOrKeep(importer.ctx, filePath)
+					imageSource = source
+				} else {
+					slog.WarnContext(importer.ctx, "image download failed, inserting card without image", "name", name, "error", dlErr)
+					imageFailed = true
+				}
+				if importer.breaker.Record(dlErr) {
+					slog.WarnContext(importer.ctx, "image host keeps failing, skipping the remaining downloads of this import", "consecutive_failures", images.DefaultBreakerThreshold)
+				}
+			}
+		} else {
+			// Image already exists on disk; use its path directly.
+			slog.DebugContext(importer.ctx, "image already on disk", "name", name, "path", existingPath)
+			imagePath = existingPath
+		}
+	}
+
+	newCard := cardCSVToNewCard(csvCard, imagePath)
+	newCard.ImageSource = imageSource
+	newCard.ImageFailed = imageFailed
+	if pathErr == nil && isLeader(csvCard) {
+		newCard.BackImage = importer.prepareBackImage(csvCard, name)
+	}
+
+	if imagePath != "" {
+		if thumbnailPath, thumbErr := images.EnsureThumbnail(importer.imagesDir, csvCard.Set, csvCard.CardNumber, imagePath); thumbErr == nil {
+			newCard.Thumbnail = thumbnailPath
+		} else {
+			slog.WarnContext(importer.ctx, "thumbnail generation failed, inserting card without thumbnail", "name", name, "error", thumbErr)
+		}
+	}
+
+	slog.InfoContext(importer.ctx, "prepared card", "name", name, "image_path", imagePath, "mainboard", newCard.Mainboard)
+
+	return newCard
+}
+
+// prepareBackImage returns the path of the back face image of the leader
+// csvCard, downloading it when it is not on disk yet. A back face that cannot
+// be downloaded is logged and left empty without flagging the card, since the
+// front is still usable and not every image source has back faces.
+func (importer *cardImporter) prepareBackImage(csvCard models.CardCSV, name string) string {
+	if existingPath, exists := images.ExistingBackFilePath(importer.imagesDir, csvCard.Set, csvCard.CardNumber); exists {
+		slog.DebugContext(importer.ctx, "back image already on disk", "name", name, "path", existingPath)
+		return existingPath
+	}
+
+	backPath, err := images.BackFilePath(importer.imagesDir, csvCard.Set, csvCard.CardNumber)
+	if err != nil || !importer.breaker.Allow() {
+		return ""
+	}
+
+	printing := cardCSVToPrinting(csvCard)
+	printing.Back = true
+	if _, err := images.DownloadFromSources(importer.ctx, importer.httpClient, importer.imageBaseURLs, printing, backPath, images.DefaultRetryPolicy); err != nil {
+		slog.WarnContext(importer.ctx, "back image download failed, inserting leader without it", "name", name, "error", err)
+		return ""
+	}
+
+	return images.OptimizeOrKeep(importer.ctx, backPath)
+}
+
+// SyncOptions are the options of a sync import.
+type SyncOptions struct {
+	// DryRun previews the changes without writing or publishing them.
+	DryRun bool
+	// Confirmed applies changes that the owned count protection settings
+	// would otherwise refuse.
+	Confirmed bool
+}
+
+// OwnedChange describes an owned count update applied (or previewed) by a
+// sync import. NeedsConfirmation is true when the change is larger than the
+// MaxOwnedChange setting or takes the count below the DecrementFloor setting.
+type OwnedChange struct {
+	Name              string `json:"name"`
+	From              int    `json:"from"`
+	To                int    `json:"to"`
+	NeedsConfirmation bool   `json:"needs_confirmation,omitempty"`
+}
+
+// NeedsConfirmation reports whether changing an owned count from from to to
+// is guarded by settings' DecrementFloor or MaxOwnedChange.
+func NeedsConfirmation(settings models.Settings, from, to int) bool {
+	if settings.MaxOwnedChange > 0 && max(to-from, from-to) > settings.MaxOwnedChange {
+		return true
+	}
+	return settings.DecrementFloor > 0 && to < from && to < settings.DecrementFloor
+}
+
+// SyncResult is the outcome of a sync import. Unknown lists CSV card names
+// that are not in the database; sync mode never inserts cards.
+type SyncResult struct {
+	DryRun  bool          `json:"dry_run"`
+	Changes []OwnedChange `json:"changes"`
+	Unknown []string      `json:"unknown"`
+}
+
+// Sync parses a SWUDB CSV from reader and sets the owned count of every
+// existing card to the CSV's Owned Count (summed across variant rows). All
+// updates are applied in a single transaction and published as
+// eventbus.OwnedChanged events, followed by eventbus.ImportCompleted. When
+// options.DryRun is true nothing is written or published and the result only
+// previews the changes. Unless options.Confirmed is true, a sync with any
+// change needing confirmation (see OwnedChange) is refused with an *Error of
+// KindConfirm and nothing changes; a dry run still previews it. Returns an
+// *Error of KindInvalid for invalid CSV input or KindInternal for database
+// errors.
+func (service *ImportService) Sync(ctx context.Context, reader io.Reader, options SyncOptions) (*SyncResult, error) {
+	dryRun := options.DryRun
+
+	csvCards, err := parseCardsCSV(reader)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to parse CSV for sync", "error", err)
+		return nil, invalid("invalid CSV: " + err.Error())
+	}
+
+	if len(csvCards) == 0 {
+		slog.WarnContext(ctx, "CSV parsed successfully but contains no card rows")
+		return nil, invalid("CSV contains no card rows")
+	}
+
+	csvCounts, err := csvOwnedCountsByName(csvCards)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid owned count in CSV for sync", "error", err)
+		return nil, invalid("invalid CSV: " + err.Error())
+	}
+
+	dbCounts, err := service.db.GetOwnedCountsByName()
+	if err != nil {
+		slog.ErrorContext(ctx, "database error loading owned counts for sync", "error", err)
+		return nil, errDatabase
+	}
+
+	settings := service.db.Settings()
+	result := &SyncResult{DryRun: dryRun, Changes: []OwnedChange{}, Unknown: []string{}}
+	updates := make(map[string]int)
+	guarded := 0
+
+	for name, csvOwned := range csvCounts {
+		dbOwned, exists := dbCounts[name]
+		if !exists {
+			result.Unknown = append(result.Unknown, name)
+			continue
+		}
+		if dbOwned != csvOwned {
+			change := OwnedChange{Name: name, From: dbOwned, To: csvOwned, NeedsConfirmation: NeedsConfirmation(settings, dbOwned, csvOwned)}
+			if change.NeedsConfirmation {
+				guarded++
+			}
+			result.Changes = append(result.Changes, change)
+			updates[name] = csvOwned
+		}
+	}
+
+	sort.Slice(result.Changes, func(i, j int) bool {
+		return result.Changes[i].Name < result.Changes[j].Name
+	})
+	sort.Strings(result.Unknown)
+
+	if !dryRun && !options.Confirmed && guarded > 0 {
+		slog.WarnContext(ctx, "sync refused without confirmation", "guarded", guarded)
+		return nil, &Error{Kind: KindConfirm, Message: fmt.Sprintf("sync would make %d owned count changes that must be confirmed; preview them with dry_run=true and apply them with confirm=true", guarded)}
+	}
+
+	if !dryRun && len(updates) > 0 {
+		if err := service.db.SetOwnedCountsByName(updates); err != nil {
+			slog.ErrorContext(ctx, "database error applying synced owned counts", "error", err)
+			return nil, errDatabase
+		}
+
+		for _, change := range result.Changes {
+			eventbus.Publish(ctx, eventbus.Event{Type: eventbus.OwnedChanged, CardName: change.Name, Cause: models.AuditSync, Previous: change.From, Owned: change.To})
+		}
+	}
+
+	if !dryRun {
+		eventbus.Publish(ctx, eventbus.Event{Type: eventbus.ImportCompleted})
+	}
+
+	slog.InfoContext(ctx, "sync complete",
+		"dry_run", dryRun,
+		"changed", len(result.Changes),
+		"unknown", len(result.Unknown),
+	)
+
+	return result, nil
+}
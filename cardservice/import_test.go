@@ -0,0 +1,243 @@
+package cardservice_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/cardservice"
+	"swucol/database"
+	"swucol/eventbus"
+	"swucol/images"
+	"swucol/internal/testdb"
+	"swucol/models"
+)
+
+// csvHeader is the header row of a SWUDB CSV export.
+const csvHeader = "Set,Card Number,Card Name,Card Title,Card Type,Aspects,Variant Type,Rarity,Foil,Stamp,Artist,Owned Count,Group Owned Count"
+
+// newImportService returns an ImportService for db whose image host has no
+// images, so that every download fails at once.
+func newImportService(t *testing.T, db *database.Database) *cardservice.ImportService {
+	t.Helper()
+
+	imageServer := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(imageServer.Close)
+
+	return cardservice.NewImportService(db, imageServer.Client(), t.TempDir(), []string{imageServer.URL})
+}
+
+// record subscribes to eventTypes on the default bus for the rest of the test
+// and returns the events published to them.
+func record(t *testing.T, eventTypes ...string) *[]eventbus.Event {
+	t.Helper()
+
+	published := &[]eventbus.Event{}
+	for _, eventType := range eventTypes {
+		t.Cleanup(eventbus.Subscribe(eventType, func(ctx context.Context, event eventbus.Event) {
+			*published = append(*published, event)
+		}))
+	}
+
+	return published
+}
+
+func TestImport_InsertsNewCardsAndPublishesThem(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Mainboard: true}))
+	published := record(t, eventbus.CardInserted, eventbus.ImportCompleted)
+
+	csv := csvHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,2,0\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Hyperspace,Rare,false,,Artist One,1,0\n" +
+		"LAW,002,Luke Skywalker,Jedi Knight,Unit,Heroism,Normal,Rare,false,,Artist Two,3,0\n" +
+		"LAW,003,Sabine Wren,,Base,,Normal,Common,false,,Artist Three,0,0"
+
+	result, err := newImportService(t, db).Import(context.Background(), strings.NewReader(csv), cardservice.ImportOptions{UseOwnedCount: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, result.Inserted)
+	assert.Equal(t, 1, result.SkippedExisting)
+	assert.Equal(t, 1, result.SkippedDuplicate)
+	assert.ElementsMatch(t, []string{"Chewbacca, Hero of Kessel", "Sabine Wren"}, result.ImageFailures)
+
+	counts, err := db.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"Chewbacca, Hero of Kessel": 3, "Luke Skywalker, Jedi Knight": 0, "Sabine Wren": 0}, counts)
+
+	require.Len(t, *published, 3)
+	assert.Equal(t, eventbus.Event{Type: eventbus.CardInserted, CardName: "Chewbacca, Hero of Kessel"}, (*published)[0])
+	assert.Equal(t, eventbus.Event{Type: eventbus.ImportCompleted, Count: 2}, (*published)[2])
+}
+
+// committedBatchCSV returns a CSV of 500 distinct cards, enough for Import to
+// commit them as one batch before reading on, each owned once, followed by
+// extra.
+func committedBatchCSV(extra ...string) string {
+	var builder strings.Builder
+	builder.WriteString(csvHeader)
+	for number := 1; number <= 500; number++ {
+		fmt.Fprintf(&builder, "\nLAW,%03d,Trooper %03d,,Unit,Villainy,Normal,Common,false,,Artist,1,0", number, number)
+	}
+	for _, row := range extra {
+		builder.WriteString("\n" + row)
+	}
+	return builder.String()
+}
+
+// newCachedImportService returns an ImportService for db whose images
+// directory already holds the image of every card of committedBatchCSV, so
+// that importing it downloads nothing.
+func newCachedImportService(t *testing.T, db *database.Database) *cardservice.ImportService {
+	t.Helper()
+
+	imagesDir := t.TempDir()
+	for number := 1; number <= 501; number++ {
+		filePath, err := images.FilePath(imagesDir, "LAW", fmt.Sprintf("%03d", number))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filePath, []byte("image"), 0o644))
+	}
+
+	return cardservice.NewImportService(db, http.DefaultClient, imagesDir, nil)
+}
+
+func TestImport_UseOwnedCount_CountsCommitWithTheirBatch(t *testing.T) {
+	db := testdb.New(t)
+	service := newCachedImportService(t, db)
+	variant := "LAW,501,Trooper 001,,Unit,Villainy,Hyperspace,Common,false,,Artist,2,0"
+
+	_, err := service.Import(context.Background(), strings.NewReader(committedBatchCSV(variant, "LAW,abc,Han Solo,,Unit,Heroism,Normal,Rare,false,,Artist,1,0")), cardservice.ImportOptions{UseOwnedCount: true})
+	require.Error(t, err)
+
+	counts, err := db.GetOwnedCountsByName()
+	require.NoError(t, err)
+	require.Len(t, counts, 500, "expected the first batch to stay imported")
+	assert.Equal(t, 1, counts["Trooper 001"], "expected the count read before the batch to be kept")
+	assert.Equal(t, 1, counts["Trooper 500"])
+
+	db = testdb.New(t)
+	_, err = newCachedImportService(t, db).Import(context.Background(), strings.NewReader(committedBatchCSV(variant)), cardservice.ImportOptions{UseOwnedCount: true})
+	require.NoError(t, err)
+
+	counts, err = db.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, 3, counts["Trooper 001"], "expected a variant row after the batch to be added")
+	assert.Equal(t, 1, counts["Trooper 500"])
+}
+
+func TestImport_InvalidRows(t *testing.T) {
+	db := testdb.New(t)
+	service := newImportService(t, db)
+
+	csv := csvHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,0,0\n" +
+		"LAW,abc,Han Solo,,Unit,Heroism,Normal,Rare,false,,Artist Two,0,0"
+
+	_, err := service.Import(context.Background(), strings.NewReader(csv), cardservice.ImportOptions{})
+	require.Error(t, err)
+	assert.Equal(t, cardservice.KindInvalid, cardservice.KindOf(err))
+	assert.Contains(t, err.Error(), "line 3")
+
+	result, err := service.Import(context.Background(), strings.NewReader(csv), cardservice.ImportOptions{Lenient: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Inserted)
+	assert.Equal(t, []cardservice.RowError{{Line: 3, Message: `card number "abc" is not numeric`}}, result.RowErrors)
+
+	for _, body := range []string{"", "Name,Owned\nChewbacca,1", csvHeader} {
+		_, err := service.Import(context.Background(), strings.NewReader(body), cardservice.ImportOptions{})
+		assert.Equal(t, cardservice.KindInvalid, cardservice.KindOf(err), "body %q", body)
+	}
+}
+
+func TestImportCatalog_SkipsAlternateVariantsAndStoresAttributes(t *testing.T) {
+	db := testdb.New(t)
+	cost := 3
+
+	result, err := newImportService(t, db).ImportCatalog(context.Background(), []cardservice.CatalogCard{
+		{CSV: models.CardCSV{Set: "LAW", CardNumber: "001", CardName: "Chewbacca", CardType: "Unit", Rarity: "Rare", VariantType: "Normal"}, Attributes: models.CardAttributes{Cost: &cost}},
+		{CSV: models.CardCSV{Set: "LAW", CardNumber: "201", CardName: "Chewbacca", CardType: "Unit", Rarity: "Rare", VariantType: "Hyperspace"}},
+		{CSV: models.CardCSV{Set: "law!", CardNumber: "002", CardName: "Han Solo", CardType: "Unit", Rarity: "Rare"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, result.Inserted)
+	assert.Equal(t, 1, result.SkippedDuplicate)
+	require.Len(t, result.RowErrors, 1)
+	assert.Equal(t, 3, result.RowErrors[0].Line)
+
+	cards, err := db.SearchCards("Chewbacca")
+	require.NoError(t, err)
+	require.Len(t, cards, 1)
+	assert.Equal(t, "001", cards[0].Number)
+}
+
+func TestSync_UpdatesOwnedCountsUnlessDryRun(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel", Mainboard: true}))
+	published := record(t, eventbus.OwnedChanged, eventbus.ImportCompleted)
+	service := newImportService(t, db)
+
+	csv := csvHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,2,0\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Hyperspace,Rare,false,,Artist One,1,0\n" +
+		"LAW,002,Han Solo,,Unit,Heroism,Normal,Rare,false,,Artist Two,1,0"
+
+	preview, err := service.Sync(context.Background(), strings.NewReader(csv), cardservice.SyncOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, []cardservice.OwnedChange{{Name: "Chewbacca, Hero of Kessel", From: 0, To: 3}}, preview.Changes)
+	assert.Equal(t, []string{"Han Solo"}, preview.Unknown)
+	assert.Empty(t, *published, "expected a dry run to publish nothing")
+
+	_, err = service.Sync(context.Background(), strings.NewReader(csv), cardservice.SyncOptions{})
+	require.NoError(t, err)
+
+	counts, err := db.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, 3, counts["Chewbacca, Hero of Kessel"])
+	assert.Equal(t, []eventbus.Event{
+		{Type: eventbus.OwnedChanged, CardName: "Chewbacca, Hero of Kessel", Cause: models.AuditSync, Previous: 0, Owned: 3},
+		{Type: eventbus.ImportCompleted},
+	}, *published)
+}
+
+func TestSync_LargeOrBelowFloorChanges_NeedConfirming(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel", Mainboard: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Han Solo", Mainboard: true}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{"Chewbacca, Hero of Kessel": 1, "Han Solo": 3}))
+	settings := db.Settings()
+	settings.DecrementFloor = 2
+	settings.MaxOwnedChange = 4
+	require.NoError(t, db.SaveSettings(settings))
+	service := newImportService(t, db)
+
+	csv := csvHeader + "\n" +
+		"LAW,001,Chewbacca,Hero of Kessel,Unit,Heroism,Normal,Rare,false,,Artist One,9,0\n" +
+		"LAW,002,Han Solo,,Unit,Heroism,Normal,Rare,false,,Artist Two,1,0"
+
+	preview, err := service.Sync(context.Background(), strings.NewReader(csv), cardservice.SyncOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, []cardservice.OwnedChange{
+		{Name: "Chewbacca, Hero of Kessel", From: 1, To: 9, NeedsConfirmation: true},
+		{Name: "Han Solo", From: 3, To: 1, NeedsConfirmation: true},
+	}, preview.Changes)
+
+	_, err = service.Sync(context.Background(), strings.NewReader(csv), cardservice.SyncOptions{})
+	assert.Equal(t, cardservice.KindConfirm, cardservice.KindOf(err))
+	counts, err := db.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, 1, counts["Chewbacca, Hero of Kessel"])
+
+	_, err = service.Sync(context.Background(), strings.NewReader(csv), cardservice.SyncOptions{Confirmed: true})
+	require.NoError(t, err)
+	counts, err = db.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"Chewbacca, Hero of Kessel": 9, "Han Solo": 1}, counts)
+}
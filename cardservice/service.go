@@ -0,0 +1,68 @@
+// Package cardservice holds the collection's business rules apart from any
+// transport: ImportService imports SWUDB CSV exports and catalog listings,
+// and CollectionService changes owned counts, archives cards, applies bulk
+// updates and compares the collection with a CSV. Each change is made in the
+// database and published on the event bus (see package eventbus), so that
+// the HTTP handlers, and any CLI or other front end, share the same
+// behaviour and side effects.
+//
+// Failures are returned as *Error, whose Kind says what went wrong and whose
+// Message is safe to show to the user. Database failures are logged with the
+// caller's context and reported as KindInternal with the message
+// "database error".
+package cardservice
+
+import (
+	"errors"
+)
+
+// Kind classifies a failed service call.
+type Kind string
+
+const (
+	// KindInvalid means the input, such as a CSV or a search query, is
+	// invalid.
+	KindInvalid Kind = "invalid"
+	// KindNotFound means the card or set does not exist.
+	KindNotFound Kind = "not_found"
+	// KindConfirm means the change is guarded by the owned count protection
+	// settings and is only made when the caller confirms it.
+	KindConfirm Kind = "confirm"
+	// KindUpstream means an external service, such as the online catalog,
+	// failed.
+	KindUpstream Kind = "upstream"
+	// KindInternal means the database or another internal step failed.
+	KindInternal Kind = "internal"
+)
+
+// Error is a failed service call.
+type Error struct {
+	Kind    Kind
+	Message string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// errDatabase is returned for database failures, which are logged where they
+// happen.
+var errDatabase = &Error{Kind: KindInternal, Message: "database error"}
+
+// errCardNotFound is returned when no card has the requested id.
+var errCardNotFound = &Error{Kind: KindNotFound, Message: "card not found"}
+
+// invalid returns a KindInvalid error with message.
+func invalid(message string) *Error {
+	return &Error{Kind: KindInvalid, Message: message}
+}
+
+// KindOf returns the Kind of err, or KindInternal when err is not an *Error.
+func KindOf(err error) Kind {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Kind
+	}
+	return KindInternal
+}
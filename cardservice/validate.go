@@ -0,0 +1,74 @@
+package cardservice
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"swucol/database"
+	"swucol/models"
+)
+
+// setCodePattern matches a set code such as "SOR" or "LAW".
+var setCodePattern = regexp.MustCompile(`^[A-Z0-9]{2,5}$`)
+
+// cardNumberPattern matches a card number such as "001" or "149".
+var cardNumberPattern = regexp.MustCompile(`^[0-9]{1,4}$`)
+
+// IsSetCode reports whether code is a valid set code: 2 to 5 upper-case
+// letters or digits.
+func IsSetCode(code string) bool {
+	return setCodePattern.MatchString(code)
+}
+
+// validateCardCSV checks the values of a CSV row that are stored on insert:
+// the set code, card number, name, type, aspects and rarity. Types, rarities
+// and aspects must be one of the models constants, matched case-insensitively.
+// Aspects may be empty for neutral cards. Returns an error describing every
+// invalid value, or nil when the row is valid.
+func validateCardCSV(card models.CardCSV) error {
+	var problems []string
+
+	if set := strings.TrimSpace(card.Set); !setCodePattern.MatchString(set) {
+		problems = append(problems, fmt.Sprintf("invalid set code %q", set))
+	}
+
+	if number := strings.TrimSpace(card.CardNumber); !cardNumberPattern.MatchString(number) {
+		problems = append(problems, fmt.Sprintf("card number %q is not numeric", number))
+	}
+
+	if strings.TrimSpace(card.CardName) == "" {
+		problems = append(problems, "card name is empty")
+	}
+
+	if cardType := strings.TrimSpace(card.CardType); !containsFold(models.CardTypes, cardType) {
+		problems = append(problems, fmt.Sprintf("unknown card type %q", cardType))
+	}
+
+	for _, aspect := range database.SplitAspects(card.Aspects) {
+		if !containsFold(models.Aspects, aspect) {
+			problems = append(problems, fmt.Sprintf("unknown aspect %q", aspect))
+		}
+	}
+
+	if rarity := strings.TrimSpace(card.Rarity); !containsFold(models.Rarities, rarity) {
+		problems = append(problems, fmt.Sprintf("unknown rarity %q", rarity))
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// containsFold reports whether values contains value, ignoring case.
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
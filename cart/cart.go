@@ -0,0 +1,131 @@
+// Package cart keeps a per-browser shopping cart of wishlist cards and turns
+// it into the mass-entry text and links marketplaces accept.
+//
+// There are no user accounts, so a cart belongs to the browser session named
+// by the swucol_cart cookie, which is issued on first use.
+package cart
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"swucol/models"
+)
+
+// CookieName is the cookie holding the cart session.
+const CookieName = "swucol_cart"
+
+// validSession matches a session as issued by Session.
+var validSession = regexp.MustCompile(`^[A-Z2-7]{26}$`)
+
+// Session returns the request's cart session, issuing a new session cookie
+// when the request has none.
+func Session(responseWriter http.ResponseWriter, request *http.Request) string {
+	if cookie, err := request.Cookie(CookieName); err == nil && validSession.MatchString(cookie.Value) {
+		return cookie.Value
+	}
+
+	session := rand.Text()
+	http.SetCookie(responseWriter, &http.Cookie{
+		Name:     CookieName,
+		Value:    session,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   request.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return session
+}
+
+// Vendor is a marketplace the cart can be exported to.
+type Vendor string
+
+// Supported vendors.
+const (
+	VendorTCGplayer  Vendor = "tcgplayer"
+	VendorCardmarket Vendor = "cardmarket"
+)
+
+// Vendors lists the supported vendors in the order the cart page shows them.
+var Vendors = []Vendor{VendorTCGplayer, VendorCardmarket}
+
+// ErrUnknownVendor is returned by ParseVendor for an unsupported vendor.
+var ErrUnknownVendor = errors.New("vendor must be one of: tcgplayer, cardmarket")
+
+// tcgplayerMassEntryURL is TCGplayer's mass entry page. Its c parameter takes
+// the cart's lines separated by "||".
+const tcgplayerMassEntryURL = "https://www.tcgplayer.com/massentry"
+
+// tcgplayerProductLine is the product line TCGplayer lists the game under.
+const tcgplayerProductLine = "Star Wars Unlimited"
+
+// ParseVendor returns the vendor named by raw, compared case-insensitively.
+func ParseVendor(raw string) (Vendor, error) {
+	vendor := Vendor(strings.ToLower(strings.TrimSpace(raw)))
+	for _, supported := range Vendors {
+		if vendor == supported {
+			return vendor, nil
+		}
+	}
+	return "", ErrUnknownVendor
+}
+
+// Label returns the vendor's display name.
+func (vendor Vendor) Label() string {
+	switch vendor {
+	case VendorTCGplayer:
+		return "TCGplayer"
+	case VendorCardmarket:
+		return "Cardmarket"
+	}
+	return string(vendor)
+}
+
+// MassEntry formats items as the vendor's mass-entry text, one card per
+// line: "2 Name [SET]" for TCGplayer and "2x Name" for Cardmarket's wants
+// list import. The set is left out when it is unknown.
+func MassEntry(vendor Vendor, items []models.CartItem) string {
+	lines := make([]string, len(items))
+	for i, item := range items {
+		lines[i] = entryLine(vendor, item)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// entryLine formats one cart item for vendor.
+func entryLine(vendor Vendor, item models.CartItem) string {
+	if vendor == VendorCardmarket {
+		return fmt.Sprintf("%dx %s", item.Quantity, item.Name)
+	}
+
+	if item.Set == "" {
+		return fmt.Sprintf("%d %s", item.Quantity, item.Name)
+	}
+	return fmt.Sprintf("%d %s [%s]", item.Quantity, item.Name, item.Set)
+}
+
+// DeepLink returns a URL opening the vendor's mass entry with items filled
+// in, or "" when the vendor has no such link and the MassEntry text must be
+// pasted instead.
+func DeepLink(vendor Vendor, items []models.CartItem) string {
+	if vendor != VendorTCGplayer || len(items) == 0 {
+		return ""
+	}
+
+	lines := make([]string, len(items))
+	for i, item := range items {
+		lines[i] = entryLine(vendor, item)
+	}
+
+	values := url.Values{}
+	values.Set("productline", tcgplayerProductLine)
+	values.Set("c", strings.Join(lines, "||"))
+
+	return tcgplayerMassEntryURL + "?" + values.Encode()
+}
@@ -0,0 +1,68 @@
+package cart_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/cart"
+	"swucol/models"
+)
+
+func TestMassEntry_FormatsEachVendor(t *testing.T) {
+	items := []models.CartItem{
+		{Name: "Darth Vader, Dark Lord of the Sith", Set: "SOR", Quantity: 1},
+		{Name: "Battlefield Marine", Quantity: 3},
+	}
+
+	assert.Equal(t, "1 Darth Vader, Dark Lord of the Sith [SOR]\n3 Battlefield Marine", cart.MassEntry(cart.VendorTCGplayer, items))
+	assert.Equal(t, "1x Darth Vader, Dark Lord of the Sith\n3x Battlefield Marine", cart.MassEntry(cart.VendorCardmarket, items))
+}
+
+func TestDeepLink_TCGplayerPrefillsMassEntry(t *testing.T) {
+	items := []models.CartItem{
+		{Name: "Darth Vader, Dark Lord of the Sith", Set: "SOR", Quantity: 1},
+		{Name: "Battlefield Marine", Set: "SOR", Quantity: 3},
+	}
+
+	link, err := url.Parse(cart.DeepLink(cart.VendorTCGplayer, items))
+	require.NoError(t, err)
+	assert.Equal(t, "www.tcgplayer.com", link.Host)
+	assert.Equal(t, "Star Wars Unlimited", link.Query().Get("productline"))
+	assert.Equal(t, "1 Darth Vader, Dark Lord of the Sith [SOR]||3 Battlefield Marine [SOR]", link.Query().Get("c"))
+
+	assert.Empty(t, cart.DeepLink(cart.VendorCardmarket, items), "Cardmarket has no prefilled mass entry link")
+	assert.Empty(t, cart.DeepLink(cart.VendorTCGplayer, nil))
+}
+
+func TestParseVendor(t *testing.T) {
+	vendor, err := cart.ParseVendor(" Cardmarket ")
+	require.NoError(t, err)
+	assert.Equal(t, cart.VendorCardmarket, vendor)
+
+	_, err = cart.ParseVendor("ebay")
+	assert.ErrorIs(t, err, cart.ErrUnknownVendor)
+}
+
+func TestSession_ReusesValidCookieAndIssuesNewOtherwise(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	session := cart.Session(recorder, httptest.NewRequest(http.MethodGet, "/cart", nil))
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, cart.CookieName, cookies[0].Name)
+	assert.Equal(t, session, cookies[0].Value)
+
+	request := httptest.NewRequest(http.MethodGet, "/cart", nil)
+	request.AddCookie(cookies[0])
+	reuseRecorder := httptest.NewRecorder()
+	assert.Equal(t, session, cart.Session(reuseRecorder, request))
+	assert.Empty(t, reuseRecorder.Result().Cookies(), "a valid session is not reissued")
+
+	forged := httptest.NewRequest(http.MethodGet, "/cart", nil)
+	forged.AddCookie(&http.Cookie{Name: cart.CookieName, Value: "not-a-session"})
+	assert.NotEqual(t, "not-a-session", cart.Session(httptest.NewRecorder(), forged))
+}
@@ -0,0 +1,346 @@
+package cart
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"swucol/csrf"
+	"swucol/database"
+	"swucol/internal/httpjson"
+	"swucol/models"
+	"swucol/templates"
+)
+
+// itemRequest is the JSON body of POST /cart/items. Quantity defaults to 1.
+type itemRequest struct {
+	CardID   int `json:"card_id"`
+	Quantity int `json:"quantity"`
+}
+
+// Export is a cart formatted for one vendor: the mass-entry text to paste and,
+// when the vendor supports it, a link that opens the mass entry pre-filled.
+type Export struct {
+	Vendor    Vendor `json:"vendor"`
+	Label     string `json:"label"`
+	MassEntry string `json:"mass_entry"`
+	Link      string `json:"link,omitempty"`
+}
+
+// NewExport formats items for vendor.
+func NewExport(vendor Vendor, items []models.CartItem) Export {
+	return Export{
+		Vendor:    vendor,
+		Label:     vendor.Label(),
+		MassEntry: MassEntry(vendor, items),
+		Link:      DeepLink(vendor, items),
+	}
+}
+
+// cartPage is the view model rendered by the cart template and by its
+// cart-body fragment.
+type cartPage struct {
+	Items     []models.CartItem
+	Exports   []Export
+	Theme     string
+	CSRFToken string
+}
+
+// addedFragment is the view model rendered by the cart-added fragment that
+// replaces a wishlist tile's add to cart form.
+type addedFragment struct {
+	Quantity int
+}
+
+// ListHandler returns an http.HandlerFunc that handles GET /cart. Returns
+// 200 OK with a JSON array of the session's cart items ordered by name
+// (empty when the cart is empty), and 500 Internal Server Error for database
+// errors.
+func ListHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		items, ok := loadCart(responseWriter, request, db, Session(responseWriter, request))
+		if !ok {
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, items)
+	}
+}
+
+// AddHandler returns an http.HandlerFunc that handles POST /cart/items with
+// a JSON body such as {"card_id":12,"quantity":2}, putting the card in the
+// session's cart or replacing its quantity. Returns 204 No Content on
+// success, 400 Bad Request for a malformed body or invalid quantity, 404 Not
+// Found if no such card exists, and 500 Internal Server Error for database
+// errors.
+func AddHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		var payload itemRequest
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		if payload.Quantity == 0 {
+			payload.Quantity = 1
+		}
+
+		if !setQuantity(responseWriter, request, db, Session(responseWriter, request), payload.CardID, payload.Quantity) {
+			return
+		}
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RemoveHandler returns an http.HandlerFunc that handles DELETE
+// /cart/items/{id}, taking the card out of the session's cart. Returns 204
+// No Content on success, 400 Bad Request for an invalid id, 404 Not Found if
+// the card is not in the cart, and 500 Internal Server Error for database
+// errors.
+func RemoveHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, ok := parseCardID(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		if !removeItem(responseWriter, request, db, Session(responseWriter, request), id) {
+			return
+		}
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ClearHandler returns an http.HandlerFunc that handles DELETE /cart,
+// emptying the session's cart. Returns 200 OK with {"removed": n} and 500
+// Internal Server Error for database errors.
+func ClearHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		removed, ok := clearCart(responseWriter, request, db, Session(responseWriter, request))
+		if !ok {
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, map[string]int{"removed": removed})
+	}
+}
+
+// ExportHandler returns an http.HandlerFunc that handles GET
+// /cart/export/{vendor}. Returns 200 OK with the session's cart formatted
+// for the vendor as a JSON Export, 400 Bad Request for an unsupported
+// vendor, and 500 Internal Server Error for database errors.
+func ExportHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		vendor, err := ParseVendor(request.PathValue("vendor"))
+		if err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		items, ok := loadCart(responseWriter, request, db, Session(responseWriter, request))
+		if !ok {
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, NewExport(vendor, items))
+	}
+}
+
+// PageHandler returns an http.HandlerFunc that serves the cart page at GET
+// /cart/html with the session's cart and its mass entry for every vendor.
+// Returns 500 Internal Server Error if the database query or template
+// rendering fails.
+func PageHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		renderCart(responseWriter, request, db, tmpl, Session(responseWriter, request), "cart")
+	}
+}
+
+// AddHTMLHandler returns an http.HandlerFunc that handles POST
+// /cart/items/html with form values "card_id" and "quantity", as the
+// wishlist's add to cart buttons send. Responds with the cart-added fragment,
+// 400 Bad Request for invalid values, 404 Not Found if no such card exists,
+// and 500 Internal Server Error for database or template errors.
+func AddHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if err := request.ParseForm(); err != nil {
+			templates.RenderError(responseWriter, request, tmpl, "invalid form data", http.StatusBadRequest)
+			return
+		}
+
+		cardID, err := strconv.Atoi(request.PostForm.Get("card_id"))
+		if err != nil {
+			templates.RenderError(responseWriter, request, tmpl, "card_id must be an integer", http.StatusBadRequest)
+			return
+		}
+
+		quantity := 1
+		if rawQuantity := request.PostForm.Get("quantity"); rawQuantity != "" {
+			quantity, err = strconv.Atoi(rawQuantity)
+			if err != nil {
+				templates.RenderError(responseWriter, request, tmpl, "quantity must be an integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if !setQuantity(responseWriter, request, db, Session(responseWriter, request), cardID, quantity) {
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "cart-added", addedFragment{Quantity: quantity}); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render cart-added template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// RemoveHTMLHandler returns an http.HandlerFunc that handles POST
+// /cart/items/{id}/remove/html. Responds with the re-rendered cart-body
+// fragment, 400 Bad Request for an invalid id, 404 Not Found if the card is
+// not in the cart, and 500 Internal Server Error for database or template
+// errors.
+func RemoveHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, ok := parseCardID(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		session := Session(responseWriter, request)
+		if !removeItem(responseWriter, request, db, session, id) {
+			return
+		}
+
+		renderCart(responseWriter, request, db, tmpl, session, "cart-body")
+	}
+}
+
+// ClearHTMLHandler returns an http.HandlerFunc that handles POST
+// /cart/clear/html. Responds with the re-rendered cart-body fragment and 500
+// Internal Server Error for database or template errors.
+func ClearHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		session := Session(responseWriter, request)
+		if _, ok := clearCart(responseWriter, request, db, session); !ok {
+			return
+		}
+
+		renderCart(responseWriter, request, db, tmpl, session, "cart-body")
+	}
+}
+
+// parseCardID reads the {id} path value. It responds with 400 Bad Request
+// and returns false when the id is not a positive integer.
+func parseCardID(responseWriter http.ResponseWriter, request *http.Request) (int, bool) {
+	id, err := strconv.Atoi(request.PathValue("id"))
+	if err != nil || id <= 0 {
+		http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// setQuantity puts quantity copies of the card in the cart of session. It
+// responds with the error and returns false when the quantity is invalid,
+// the card does not exist or the write fails.
+func setQuantity(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, session string, cardID, quantity int) bool {
+	if quantity < 1 {
+		http.Error(responseWriter, "quantity must be at least 1", http.StatusBadRequest)
+		return false
+	}
+
+	err := db.SetCartQuantity(session, cardID, quantity)
+	if errors.Is(err, database.ErrCardNotFound) {
+		http.Error(responseWriter, "card not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error adding to cart", "card_id", cardID, "error", err)
+		http.Error(responseWriter, "database error", http.StatusInternalServerError)
+		return false
+	}
+
+	slog.InfoContext(request.Context(), "card added to cart", "card_id", cardID, "quantity", quantity)
+
+	return true
+}
+
+// removeItem takes the card out of the cart of session. It responds with the
+// error and returns false when the card is not in the cart or the delete
+// fails.
+func removeItem(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, session string, cardID int) bool {
+	err := db.RemoveFromCart(session, cardID)
+	if errors.Is(err, database.ErrCartItemNotFound) {
+		http.Error(responseWriter, "card not in cart", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error removing from cart", "card_id", cardID, "error", err)
+		http.Error(responseWriter, "database error", http.StatusInternalServerError)
+		return false
+	}
+
+	slog.InfoContext(request.Context(), "card removed from cart", "card_id", cardID)
+
+	return true
+}
+
+// clearCart empties the cart of session. It responds with the error and
+// returns false when the delete fails.
+func clearCart(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, session string) (int, bool) {
+	removed, err := db.ClearCart(session)
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error clearing cart", "error", err)
+		http.Error(responseWriter, "database error", http.StatusInternalServerError)
+		return 0, false
+	}
+
+	slog.InfoContext(request.Context(), "cart cleared", "removed", removed)
+
+	return removed, true
+}
+
+// loadCart returns the cart of session. It responds with 500 Internal Server
+// Error and returns false when the query fails.
+func loadCart(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, session string) ([]models.CartItem, bool) {
+	items, err := db.GetCart(session)
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error loading cart", "error", err)
+		http.Error(responseWriter, "database error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return items, true
+}
+
+// renderCart loads the cart of session and renders templateName, either the
+// full page or the cart-body fragment.
+func renderCart(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer, session, templateName string) {
+	items, ok := loadCart(responseWriter, request, db, session)
+	if !ok {
+		return
+	}
+
+	page := cartPage{
+		Items:     items,
+		Theme:     db.Settings().Theme,
+		CSRFToken: csrf.Token(request.Context()),
+	}
+	if len(items) > 0 {
+		for _, vendor := range Vendors {
+			page.Exports = append(page.Exports, NewExport(vendor, items))
+		}
+	}
+
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.ExecuteTemplate(responseWriter, templateName, page); err != nil {
+		slog.ErrorContext(request.Context(), "failed to render cart template", "template", templateName, "error", err)
+		http.Error(responseWriter, "template error", http.StatusInternalServerError)
+		return
+	}
+}
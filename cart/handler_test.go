@@ -0,0 +1,122 @@
+package cart_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/cart"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/templates"
+)
+
+// sessionCookie is a cart session cookie shared by the requests of one test.
+var sessionCookie = &http.Cookie{Name: cart.CookieName, Value: "ABCDEFGHIJKLMNOPQRSTUVWXYZ"}
+
+// newRequest returns a request carrying sessionCookie.
+func newRequest(method, target, body string) *http.Request {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.AddCookie(sessionCookie)
+	return request
+}
+
+func TestAddListAndExportHandlers(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCards([]models.NewCard{
+		{Name: "Darth Vader, Dark Lord of the Sith", Set: "SOR", Number: "010"},
+		{Name: "Battlefield Marine", Set: "SOR", Number: "095"},
+	}))
+
+	for _, body := range []string{`{"card_id": 2, "quantity": 3}`, `{"card_id": 1}`} {
+		recorder := httptest.NewRecorder()
+		cart.AddHandler(db)(recorder, newRequest(http.MethodPost, "/cart/items", body))
+		require.Equal(t, http.StatusNoContent, recorder.Code, recorder.Body.String())
+	}
+
+	listRecorder := httptest.NewRecorder()
+	cart.ListHandler(db)(listRecorder, newRequest(http.MethodGet, "/cart", ""))
+
+	require.Equal(t, http.StatusOK, listRecorder.Code)
+	var items []models.CartItem
+	require.NoError(t, json.Unmarshal(listRecorder.Body.Bytes(), &items))
+	assert.Equal(t, []models.CartItem{
+		{CardID: 2, Name: "Battlefield Marine", Set: "SOR", Number: "095", Quantity: 3},
+		{CardID: 1, Name: "Darth Vader, Dark Lord of the Sith", Set: "SOR", Number: "010", Quantity: 1},
+	}, items)
+
+	exportRequest := newRequest(http.MethodGet, "/cart/export/cardmarket", "")
+	exportRequest.SetPathValue("vendor", "cardmarket")
+	exportRecorder := httptest.NewRecorder()
+	cart.ExportHandler(db)(exportRecorder, exportRequest)
+
+	require.Equal(t, http.StatusOK, exportRecorder.Code)
+	var export cart.Export
+	require.NoError(t, json.Unmarshal(exportRecorder.Body.Bytes(), &export))
+	assert.Equal(t, "3x Battlefield Marine\n1x Darth Vader, Dark Lord of the Sith", export.MassEntry)
+	assert.Empty(t, export.Link)
+
+	otherRecorder := httptest.NewRecorder()
+	cart.ListHandler(db)(otherRecorder, httptest.NewRequest(http.MethodGet, "/cart", nil))
+	assert.JSONEq(t, `[]`, otherRecorder.Body.String(), "another session has its own cart")
+}
+
+func TestAddHandler_InvalidItems(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Battlefield Marine"}))
+
+	for body, status := range map[string]int{
+		`{"card_id": 99}`:                http.StatusNotFound,
+		`{"card_id": 1, "quantity": -2}`: http.StatusBadRequest,
+		`not json`:                       http.StatusBadRequest,
+	} {
+		recorder := httptest.NewRecorder()
+		cart.AddHandler(db)(recorder, newRequest(http.MethodPost, "/cart/items", body))
+		assert.Equal(t, status, recorder.Code, body)
+	}
+
+	exportRequest := newRequest(http.MethodGet, "/cart/export/ebay", "")
+	exportRequest.SetPathValue("vendor", "ebay")
+	exportRecorder := httptest.NewRecorder()
+	cart.ExportHandler(db)(exportRecorder, exportRequest)
+	assert.Equal(t, http.StatusBadRequest, exportRecorder.Code)
+}
+
+func TestHTMLHandlers_AddAndRemoveCard(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Battlefield Marine", Set: "SOR"}))
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	form := url.Values{"card_id": {"1"}, "quantity": {"2"}}
+	addRequest := newRequest(http.MethodPost, "/cart/items/html", form.Encode())
+	addRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	addRecorder := httptest.NewRecorder()
+	cart.AddHTMLHandler(db, tmpl)(addRecorder, addRequest)
+
+	require.Equal(t, http.StatusOK, addRecorder.Code, addRecorder.Body.String())
+	assert.Contains(t, addRecorder.Body.String(), "In cart: 2")
+
+	pageRecorder := httptest.NewRecorder()
+	cart.PageHandler(db, tmpl)(pageRecorder, newRequest(http.MethodGet, "/cart/html", ""))
+
+	require.Equal(t, http.StatusOK, pageRecorder.Code)
+	page := pageRecorder.Body.String()
+	assert.Contains(t, page, "2 Battlefield Marine [SOR]")
+	assert.Contains(t, page, "2x Battlefield Marine")
+	assert.Contains(t, page, "Open in TCGplayer")
+
+	removeRequest := newRequest(http.MethodPost, "/cart/items/1/remove/html", "")
+	removeRequest.SetPathValue("id", "1")
+	removeRecorder := httptest.NewRecorder()
+	cart.RemoveHTMLHandler(db, tmpl)(removeRecorder, removeRequest)
+
+	require.Equal(t, http.StatusOK, removeRecorder.Code)
+	assert.Contains(t, removeRecorder.Body.String(), "The cart is empty.")
+}
@@ -0,0 +1,111 @@
+// Package csrf protects the routes the HTML pages post to from cross-site
+// request forgery with a double-submit token: Middleware gives each browser a
+// random token in a cookie, the pages repeat it in a header (htmx requests)
+// or a form field (plain forms), and Protect rejects requests where the two
+// do not match. Another site can make the browser send the cookie, but cannot
+// read it to repeat it.
+package csrf
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	// CookieName is the cookie the token is stored in.
+	CookieName = "swucol_csrf"
+
+	// HeaderName is the request header htmx sends the token in.
+	HeaderName = "X-CSRF-Token"
+
+	// FormField is the form field plain HTML forms send the token in.
+	FormField = "csrf_token"
+
+	// maxMultipartMemory is the part of a multipart form Protect keeps in
+	// memory while looking for FormField; larger files spill to temporary
+	// files, as the upload handlers' own parsing does.
+	maxMultipartMemory = 1 << 20
+)
+
+// validToken matches tokens issued by Middleware; other cookie values are
+// replaced.
+var validToken = regexp.MustCompile(`^[A-Z2-7]{26}$`)
+
+// contextKey is the context key type for the token.
+type contextKey struct{}
+
+// Token returns the request's CSRF token for rendering into pages, or "" when
+// Middleware is not in use.
+func Token(ctx context.Context) string {
+	token, _ := ctx.Value(contextKey{}).(string)
+	return token
+}
+
+// Middleware makes the browser's token available to handlers through Token,
+// issuing a new token cookie when the request has none.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		token := cookieToken(request)
+		if token == "" {
+			token = rand.Text()
+			http.SetCookie(responseWriter, &http.Cookie{
+				Name:     CookieName,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   request.TLS != nil,
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+
+		next.ServeHTTP(responseWriter, request.WithContext(context.WithValue(request.Context(), contextKey{}, token)))
+	})
+}
+
+// cookieToken returns the token cookie's value, or "" if it is missing or
+// malformed.
+func cookieToken(request *http.Request) string {
+	cookie, err := request.Cookie(CookieName)
+	if err != nil || !validToken.MatchString(cookie.Value) {
+		return ""
+	}
+	return cookie.Value
+}
+
+// Protect returns a handler that serves next only when the request repeats
+// its token cookie in the X-CSRF-Token header or, for forms posted without
+// htmx, the csrf_token field. Returns 403 Forbidden otherwise, or 400 Bad
+// Request for a multipart form that cannot be parsed. Multipart forms are
+// parsed here, keeping at most maxMultipartMemory bytes in memory, and next
+// reads the already parsed form.
+func Protect(next http.HandlerFunc) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		expected := cookieToken(request)
+
+		submitted := request.Header.Get(HeaderName)
+		contentType := request.Header.Get("Content-Type")
+		if submitted == "" && strings.HasPrefix(contentType, "multipart/form-data") {
+			if err := request.ParseMultipartForm(maxMultipartMemory); err != nil {
+				slog.WarnContext(request.Context(), "failed to parse multipart form for CSRF token", "error", err)
+				http.Error(responseWriter, "invalid form data", http.StatusBadRequest)
+				return
+			}
+		}
+		if submitted == "" && (strings.HasPrefix(contentType, "application/x-www-form-urlencoded") || strings.HasPrefix(contentType, "multipart/form-data")) {
+			submitted = request.PostFormValue(FormField)
+		}
+
+		if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(submitted)) != 1 {
+			slog.WarnContext(request.Context(), "rejected request with missing or invalid CSRF token", "method", request.Method, "path", request.URL.Path)
+			http.Error(responseWriter, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+
+		next(responseWriter, request)
+	}
+}
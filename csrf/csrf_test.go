@@ -0,0 +1,171 @@
+package csrf_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/csrf"
+)
+
+// validToken is a well-formed token as issued by Middleware.
+const validToken = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// okHandler responds 200 OK so tests can tell whether Protect let a request
+// through.
+func okHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter.WriteHeader(http.StatusOK)
+}
+
+func TestMiddleware_IssuesTokenCookie(t *testing.T) {
+	var seen string
+	handler := csrf.Middleware(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		seen = csrf.Token(request.Context())
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, csrf.CookieName, cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+	assert.Equal(t, cookies[0].Value, seen)
+}
+
+func TestMiddleware_ReusesExistingToken(t *testing.T) {
+	var seen string
+	handler := csrf.Middleware(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		seen = csrf.Token(request.Context())
+	}))
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.AddCookie(&http.Cookie{Name: csrf.CookieName, Value: validToken})
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	assert.Empty(t, recorder.Result().Cookies())
+	assert.Equal(t, validToken, seen)
+}
+
+func TestToken_WithoutMiddleware_IsEmpty(t *testing.T) {
+	assert.Empty(t, csrf.Token(httptest.NewRequest(http.MethodGet, "/", nil).Context()))
+}
+
+func TestProtect_MatchingHeader_ServesRequest(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "/cards/1/increment/html", nil)
+	request.AddCookie(&http.Cookie{Name: csrf.CookieName, Value: validToken})
+	request.Header.Set(csrf.HeaderName, validToken)
+
+	recorder := httptest.NewRecorder()
+	csrf.Protect(okHandler)(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestProtect_MatchingFormField_ServesRequest(t *testing.T) {
+	form := url.Values{csrf.FormField: {validToken}, "theme": {"light"}}
+	request := httptest.NewRequest(http.MethodPost, "/settings/html", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.AddCookie(&http.Cookie{Name: csrf.CookieName, Value: validToken})
+
+	recorder := httptest.NewRecorder()
+	csrf.Protect(func(responseWriter http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "light", request.FormValue("theme"))
+		responseWriter.WriteHeader(http.StatusOK)
+	})(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestProtect_MatchingMultipartField_LeavesFileForHandler(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField(csrf.FormField, validToken))
+	part, err := writer.CreateFormFile("file", "cards.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("Name\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/import/html", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	request.AddCookie(&http.Cookie{Name: csrf.CookieName, Value: validToken})
+
+	recorder := httptest.NewRecorder()
+	csrf.Protect(func(responseWriter http.ResponseWriter, request *http.Request) {
+		file, header, err := request.FormFile("file")
+		require.NoError(t, err)
+		file.Close()
+		assert.Equal(t, "cards.csv", header.Filename)
+		responseWriter.WriteHeader(http.StatusOK)
+	})(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestProtect_LargeMultipartFile_SpillsToDisk(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField(csrf.FormField, validToken))
+	part, err := writer.CreateFormFile("file", "cards.csv")
+	require.NoError(t, err)
+	_, err = part.Write(bytes.Repeat([]byte("Name\n"), 1<<19))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/import/html", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	request.AddCookie(&http.Cookie{Name: csrf.CookieName, Value: validToken})
+
+	recorder := httptest.NewRecorder()
+	csrf.Protect(func(responseWriter http.ResponseWriter, request *http.Request) {
+		defer request.MultipartForm.RemoveAll()
+		file, _, err := request.FormFile("file")
+		require.NoError(t, err)
+		defer file.Close()
+		assert.IsType(t, &os.File{}, file, "expected the 2 MB upload to be kept on disk rather than in memory")
+		responseWriter.WriteHeader(http.StatusOK)
+	})(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestProtect_MissingOrMismatchedToken_ReturnsForbidden(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie string
+		header string
+	}{
+		{name: "no cookie or header"},
+		{name: "header without cookie", header: validToken},
+		{name: "cookie without header", cookie: validToken},
+		{name: "mismatched header", cookie: validToken, header: "ZYXWVUTSRQPONMLKJIHGFEDCBA"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/admin/db/vacuum", nil)
+			if test.cookie != "" {
+				request.AddCookie(&http.Cookie{Name: csrf.CookieName, Value: test.cookie})
+			}
+			if test.header != "" {
+				request.Header.Set(csrf.HeaderName, test.header)
+			}
+
+			recorder := httptest.NewRecorder()
+			csrf.Protect(okHandler)(recorder, request)
+
+			assert.Equal(t, http.StatusForbidden, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Package cubes builds draft cubes from owned cards: it checks a cube's
+// balance across aspects and rarities, formats the cube list for export, and
+// provides the HTTP handlers for the /cubes routes.
+package cubes
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"swucol/models"
+)
+
+// aspectTolerance is how far, as a fraction of the mean, an aspect's card
+// count may stray from the mean of all aspects before it is reported.
+const aspectTolerance = 0.25
+
+// rarityTolerance is how far, in percentage points, a rarity's share of the
+// cube may stray from its share of a booster pack before it is reported.
+const rarityTolerance = 10
+
+// rarityTarget is a rarity group and its share of the main slots of a booster
+// pack: nine commons, three uncommons and one rare or legendary.
+type rarityTarget struct {
+	name     string
+	rarities []string
+	percent  float64
+}
+
+// rarityTargets are the rarity groups CheckBalance compares against.
+var rarityTargets = []rarityTarget{
+	{name: models.RarityCommon, rarities: []string{models.RarityCommon}, percent: 900.0 / 13},
+	{name: models.RarityUncommon, rarities: []string{models.RarityUncommon}, percent: 300.0 / 13},
+	{name: "Rare or Legendary", rarities: []string{models.RarityRare, models.RarityLegendary}, percent: 100.0 / 13},
+}
+
+// Share is the number of cards in a cube with an aspect or rarity, and
+// their percentage of the cube's cards.
+type Share struct {
+	Name    string  `json:"name"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// Balance describes how a cube's cards are spread across aspects and
+// rarities. Warnings is empty when the cube is balanced.
+type Balance struct {
+	Total    int      `json:"total"`
+	Aspects  []Share  `json:"aspects"`
+	Rarities []Share  `json:"rarities"`
+	Warnings []string `json:"warnings"`
+}
+
+// CheckBalance counts the copies in cards per aspect and per rarity group.
+// Cards with two aspects count towards both. An aspect is reported when its
+// count strays from the mean of the six aspects by more than aspectTolerance,
+// and a rarity group when its share strays from its booster share by more
+// than rarityTolerance points.
+func CheckBalance(cards []models.CubeCard) Balance {
+	balance := Balance{
+		Aspects:  make([]Share, len(models.Aspects)),
+		Rarities: make([]Share, len(rarityTargets)),
+		Warnings: make([]string, 0),
+	}
+
+	for i, aspect := range models.Aspects {
+		balance.Aspects[i].Name = aspect
+	}
+	for i, target := range rarityTargets {
+		balance.Rarities[i].Name = target.name
+	}
+
+	for _, card := range cards {
+		balance.Total += card.Count
+
+		for aspect := range strings.SplitSeq(card.Aspects, "|") {
+			for i := range balance.Aspects {
+				if strings.EqualFold(strings.TrimSpace(aspect), balance.Aspects[i].Name) {
+					balance.Aspects[i].Count += card.Count
+				}
+			}
+		}
+
+		for i, target := range rarityTargets {
+			for _, rarity := range target.rarities {
+				if strings.EqualFold(card.Rarity, rarity) {
+					balance.Rarities[i].Count += card.Count
+				}
+			}
+		}
+	}
+
+	if balance.Total == 0 {
+		return balance
+	}
+
+	aspectTotal := 0
+	for _, share := range balance.Aspects {
+		aspectTotal += share.Count
+	}
+	mean := float64(aspectTotal) / float64(len(balance.Aspects))
+
+	for i := range balance.Aspects {
+		share := &balance.Aspects[i]
+		share.Percent = percentOf(share.Count, balance.Total)
+		if math.Abs(float64(share.Count)-mean) > mean*aspectTolerance {
+			balance.Warnings = append(balance.Warnings, fmt.Sprintf(
+				"%s has %d cards; the aspect average is %.1f", share.Name, share.Count, mean,
+			))
+		}
+	}
+
+	for i, target := range rarityTargets {
+		share := &balance.Rarities[i]
+		share.Percent = percentOf(share.Count, balance.Total)
+		if math.Abs(share.Percent-target.percent) > rarityTolerance {
+			balance.Warnings = append(balance.Warnings, fmt.Sprintf(
+				"%s is %.0f%% of the cube; booster packs have %.0f%%", share.Name, share.Percent, target.percent,
+			))
+		}
+	}
+
+	return balance
+}
+
+// percentOf returns count as a percentage of total, rounded to one decimal.
+func percentOf(count, total int) float64 {
+	return math.Round(float64(count)*1000/float64(total)) / 10
+}
+
+// WriteList writes the cube list in the plain text deck list form, one
+// "count name" line per card, after a comment line naming the cube.
+func WriteList(writer io.Writer, cube models.Cube, cards []models.CubeCard) error {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# %s (%d cards)\n", cube.Name, cube.CardCount)
+	for _, card := range cards {
+		fmt.Fprintf(&builder, "%d %s\n", card.Count, card.Name)
+	}
+
+	if _, err := io.WriteString(writer, builder.String()); err != nil {
+		return fmt.Errorf("write cube list: %w", err)
+	}
+
+	return nil
+}
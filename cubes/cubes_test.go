@@ -0,0 +1,68 @@
+package cubes_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/cubes"
+	"swucol/models"
+)
+
+// cubeCard returns a CubeCard with the given details.
+func cubeCard(name, aspects, rarity string, count int) models.CubeCard {
+	return models.CubeCard{Card: models.Card{Name: name, Aspects: aspects, Rarity: rarity}, Count: count}
+}
+
+func TestCheckBalance_EvenCubeHasNoWarnings(t *testing.T) {
+	var cards []models.CubeCard
+	for _, aspect := range models.Aspects {
+		cards = append(cards,
+			cubeCard(aspect+" common", aspect, models.RarityCommon, 9),
+			cubeCard(aspect+" uncommon", aspect, models.RarityUncommon, 3),
+			cubeCard(aspect+" rare", aspect, models.RarityRare, 1),
+		)
+	}
+
+	balance := cubes.CheckBalance(cards)
+
+	assert.Equal(t, 78, balance.Total)
+	assert.Empty(t, balance.Warnings)
+	assert.Equal(t, 13, balance.Aspects[0].Count)
+	assert.Equal(t, 69.2, balance.Rarities[0].Percent)
+}
+
+func TestCheckBalance_ReportsSkewedAspectsAndRarities(t *testing.T) {
+	cards := []models.CubeCard{
+		cubeCard("Luke Skywalker, Jedi Knight", "Vigilance|Heroism", models.RarityRare, 4),
+		cubeCard("Darth Vader, Dark Lord of the Sith", "Aggression|Villainy", models.RarityLegendary, 4),
+	}
+
+	balance := cubes.CheckBalance(cards)
+
+	assert.Equal(t, 8, balance.Total)
+	assert.Contains(t, balance.Warnings, "Command has 0 cards; the aspect average is 2.7")
+	assert.Contains(t, balance.Warnings, "Rare or Legendary is 100% of the cube; booster packs have 8%")
+}
+
+func TestCheckBalance_EmptyCube(t *testing.T) {
+	balance := cubes.CheckBalance(nil)
+
+	assert.Zero(t, balance.Total)
+	assert.Empty(t, balance.Warnings)
+	assert.Len(t, balance.Aspects, len(models.Aspects))
+}
+
+func TestWriteList_WritesCountAndNameLines(t *testing.T) {
+	var builder strings.Builder
+
+	err := cubes.WriteList(&builder, models.Cube{Name: "Rebels", CardCount: 3}, []models.CubeCard{
+		cubeCard("Luke Skywalker, Jedi Knight", "", "", 2),
+		cubeCard("Leia Organa, Defiant Princess", "", "", 1),
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "# Rebels (3 cards)\n2 Luke Skywalker, Jedi Knight\n1 Leia Organa, Defiant Princess\n", builder.String())
+}
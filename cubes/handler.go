@@ -0,0 +1,482 @@
+package cubes
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"swucol/csrf"
+	"swucol/database"
+	"swucol/internal/httpjson"
+	"swucol/models"
+	"swucol/roles"
+	"swucol/snippet"
+	"swucol/templates"
+)
+
+// cubeRequest is the JSON body accepted by CreateHandler.
+type cubeRequest struct {
+	Name string `json:"name"`
+}
+
+// countRequest is the JSON body accepted by SetCardCountHandler.
+type countRequest struct {
+	Count int `json:"count"`
+}
+
+// cubeResponse is a cube with its cards and balance, as returned by
+// GetHandler.
+type cubeResponse struct {
+	models.Cube
+	Cards   []models.CubeCard `json:"cards"`
+	Balance Balance           `json:"balance"`
+}
+
+// cubesPage is the view model rendered by the cubes template and its
+// cubes-list fragment.
+type cubesPage struct {
+	Cubes     []models.Cube
+	Uncubed   []models.Card
+	Theme     string
+	Role      string
+	CSRFToken string
+}
+
+// cubePage is the view model rendered by the cube template and its
+// cube-detail fragment.
+type cubePage struct {
+	Cube      models.Cube
+	Cards     []models.CubeCard
+	Balance   Balance
+	Uncubed   []models.Card
+	Theme     string
+	Role      string
+	CSRFToken string
+}
+
+// ListHandler returns an http.HandlerFunc that handles GET /cubes. Returns
+// 200 OK with a JSON array of every cube and its card count (empty when there
+// are none), and 500 Internal Server Error for database errors.
+func ListHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		cubes, err := db.GetCubes()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading cubes", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, cubes)
+	}
+}
+
+// CreateHandler returns an http.HandlerFunc that handles POST /cubes with a
+// JSON body of the form {"name": "..."}. Returns 201 Created with the new
+// cube as JSON, 400 Bad Request for an invalid body or blank name, and 500
+// Internal Server Error for database errors.
+func CreateHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		var payload cubeRequest
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		cube, ok := createCube(responseWriter, request, db, nil, payload.Name)
+		if !ok {
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusCreated, cube)
+	}
+}
+
+// GetHandler returns an http.HandlerFunc that handles GET /cubes/{id}.
+// Returns 200 OK with the cube, its cards and its balance as JSON, 400 Bad
+// Request for an invalid id, 404 Not Found if no such cube exists, and 500
+// Internal Server Error for database errors.
+func GetHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		cube, cards, ok := loadCube(responseWriter, request, db, nil)
+		if !ok {
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, cubeResponse{Cube: cube, Cards: cards, Balance: CheckBalance(cards)})
+	}
+}
+
+// DeleteHandler returns an http.HandlerFunc that handles DELETE /cubes/{id}.
+// The cards stay in the collection. Returns 204 No Content once the cube is
+// deleted, 400 Bad Request for an invalid id, 404 Not Found if no such cube
+// exists, and 500 Internal Server Error for database errors.
+func DeleteHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, ok := parsePathID(responseWriter, request, nil, "id")
+		if !ok {
+			return
+		}
+
+		err := db.DeleteCube(id)
+		if errors.Is(err, database.ErrCubeNotFound) {
+			http.Error(responseWriter, "cube not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error deleting cube", "cube_id", id, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "cube deleted", "cube_id", id)
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SetCardCountHandler returns an http.HandlerFunc that handles PUT
+// /cubes/{id}/cards/{cardID} with a JSON body of the form {"count": 2}. A
+// count of zero removes the card from the cube. Returns 204 No Content on
+// success, 400 Bad Request for invalid ids or body, 404 Not Found for an
+// unknown cube or card, 409 Conflict when the card's copies across all cubes
+// would exceed the number owned, and 500 Internal Server Error for database
+// errors.
+func SetCardCountHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		var payload countRequest
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		if _, ok := setCardCount(responseWriter, request, db, nil, payload.Count); !ok {
+			return
+		}
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ExportHandler returns an http.HandlerFunc that handles GET
+// /cubes/{id}/export. Responds with the cube list as a plain text attachment
+// in the form written by WriteList, 400 Bad Request for an invalid id, 404
+// Not Found if no such cube exists, and 500 Internal Server Error for
+// database errors.
+func ExportHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		cube, cards, ok := loadCube(responseWriter, request, db, nil)
+		if !ok {
+			return
+		}
+
+		fileName := "cube-" + strconv.Itoa(cube.ID) + ".txt"
+		responseWriter.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		responseWriter.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+		if err := WriteList(responseWriter, cube, cards); err != nil {
+			slog.ErrorContext(request.Context(), "failed to write cube export", "cube_id", cube.ID, "error", err)
+			return
+		}
+	}
+}
+
+// SnippetHandler returns an http.HandlerFunc that handles GET
+// /cubes/{id}/snippet. Responds with the cube's cards and counts as a
+// plain-text snippet in the snippet.Format named by "format" (inline by
+// default), 400 Bad Request for an invalid id or unknown format, 404 Not
+// Found if no such cube exists, and 500 Internal Server Error for database
+// errors.
+func SnippetHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		serveSnippet(responseWriter, request, db, nil)
+	}
+}
+
+// UncubedHandler returns an http.HandlerFunc that handles GET
+// /cubes/uncubed. Returns 200 OK with a JSON array of the owned cards that
+// are in no cube, and 500 Internal Server Error for database errors.
+func UncubedHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		cards, err := db.GetUncubedCards()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading uncubed cards", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, cards)
+	}
+}
+
+// IndexHandler returns an http.HandlerFunc that serves the cube list page at
+// GET /cubes/html, with the owned cards that are in no cube. Returns 500
+// Internal Server Error if a database query or template rendering fails.
+func IndexHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		renderCubes(responseWriter, request, db, tmpl, "cubes")
+	}
+}
+
+// CreateHTMLHandler returns an http.HandlerFunc that handles POST
+// /cubes/html with the form value "name". Responds with the re-rendered cube
+// list, 400 Bad Request for a blank name, and 500 Internal Server Error for
+// database or template errors.
+func CreateHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if err := request.ParseForm(); err != nil {
+			templates.RenderError(responseWriter, request, tmpl, "invalid form data", http.StatusBadRequest)
+			return
+		}
+
+		if _, ok := createCube(responseWriter, request, db, tmpl, request.PostForm.Get("name")); !ok {
+			return
+		}
+
+		renderCubes(responseWriter, request, db, tmpl, "cubes-list")
+	}
+}
+
+// CubeHandler returns an http.HandlerFunc that serves a cube's page at GET
+// /cubes/{id}/html: its cards and counts, its balance, and the owned cards in
+// no cube to add. Returns 400 Bad Request for an invalid id, 404 Not Found if
+// no such cube exists, and 500 Internal Server Error if a database query or
+// template rendering fails.
+func CubeHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		cube, cards, ok := loadCube(responseWriter, request, db, tmpl)
+		if !ok {
+			return
+		}
+
+		renderCube(responseWriter, request, db, tmpl, "cube", cube, cards)
+	}
+}
+
+// SetCardCountHTMLHandler returns an http.HandlerFunc that handles POST
+// /cubes/{id}/cards/{cardID}/html with the form value "count". Responds with
+// the re-rendered cube detail, or the errors described by SetCardCountHandler.
+func SetCardCountHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if err := request.ParseForm(); err != nil {
+			templates.RenderError(responseWriter, request, tmpl, "invalid form data", http.StatusBadRequest)
+			return
+		}
+
+		count, err := strconv.Atoi(strings.TrimSpace(request.PostForm.Get("count")))
+		if err != nil {
+			templates.RenderError(responseWriter, request, tmpl, "count must be an integer", http.StatusBadRequest)
+			return
+		}
+
+		cubeID, ok := setCardCount(responseWriter, request, db, tmpl, count)
+		if !ok {
+			return
+		}
+
+		cube, err := db.GetCube(cubeID)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading cube", "cube_id", cubeID, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		cards, err := db.GetCubeCards(cubeID)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading cube cards", "cube_id", cubeID, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		renderCube(responseWriter, request, db, tmpl, "cube-detail", cube, cards)
+	}
+}
+
+// SnippetHTMLHandler returns an http.HandlerFunc that handles GET
+// /cubes/{id}/snippet/html: the snippet of SnippetHandler in the snippet
+// fragment, with a format select and a copy button, for the cube page.
+// Returns the same errors as SnippetHandler.
+func SnippetHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		serveSnippet(responseWriter, request, db, tmpl)
+	}
+}
+
+// parsePathID reads the named path value. It responds with 400 Bad Request
+// and returns false when the value is not a positive integer.
+//
+// Like the other helpers below, it reports errors with templates.RenderError,
+// so the HTML handlers pass their tmpl and the JSON handlers nil, which
+// writes errors as plain text.
+func parsePathID(responseWriter http.ResponseWriter, request *http.Request, tmpl templates.Renderer, name string) (int, bool) {
+	id, err := strconv.Atoi(request.PathValue(name))
+	if err != nil || id <= 0 {
+		templates.RenderError(responseWriter, request, tmpl, name+" must be a positive integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// createCube creates a cube named name. It responds with the error and
+// returns false when the name is blank or the insert fails.
+func createCube(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer, name string) (models.Cube, bool) {
+	if strings.TrimSpace(name) == "" {
+		templates.RenderError(responseWriter, request, tmpl, "name is required", http.StatusBadRequest)
+		return models.Cube{}, false
+	}
+
+	cube, err := db.CreateCube(name)
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error creating cube", "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+		return models.Cube{}, false
+	}
+
+	slog.InfoContext(request.Context(), "cube created", "cube_id", cube.ID, "name", cube.Name)
+
+	return cube, true
+}
+
+// loadCube loads the cube named by the {id} path value and its cards. It
+// responds with the error and returns false when the id is invalid, the cube
+// does not exist, or a query fails.
+func loadCube(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer) (models.Cube, []models.CubeCard, bool) {
+	id, ok := parsePathID(responseWriter, request, tmpl, "id")
+	if !ok {
+		return models.Cube{}, nil, false
+	}
+
+	cube, err := db.GetCube(id)
+	if err == nil {
+		var cards []models.CubeCard
+		if cards, err = db.GetCubeCards(id); err == nil {
+			return cube, cards, true
+		}
+	}
+
+	if errors.Is(err, database.ErrCubeNotFound) {
+		templates.RenderError(responseWriter, request, tmpl, "cube not found", http.StatusNotFound)
+		return models.Cube{}, nil, false
+	}
+	slog.ErrorContext(request.Context(), "database error loading cube", "cube_id", id, "error", err)
+	templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+	return models.Cube{}, nil, false
+}
+
+// serveSnippet responds with the snippet of the cube named by the {id} path
+// value, as text when tmpl is nil and in the snippet fragment otherwise.
+func serveSnippet(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer) {
+	cube, cards, ok := loadCube(responseWriter, request, db, tmpl)
+	if !ok {
+		return
+	}
+
+	items := make([]snippet.Item, len(cards))
+	for i, card := range cards {
+		items[i] = snippet.Item{Count: card.Count, Name: card.Name}
+	}
+
+	snippet.Serve(responseWriter, request, tmpl, "/cubes/"+strconv.Itoa(cube.ID)+"/snippet/html", items)
+}
+
+// setCardCount sets the count of the card named by the {cardID} path value in
+// the cube named by {id}, and returns the cube id. It responds with the error
+// and returns false when an id is invalid or the count cannot be set.
+func setCardCount(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer, count int) (int, bool) {
+	cubeID, ok := parsePathID(responseWriter, request, tmpl, "id")
+	if !ok {
+		return 0, false
+	}
+	cardID, ok := parsePathID(responseWriter, request, tmpl, "cardID")
+	if !ok {
+		return 0, false
+	}
+
+	if count < 0 {
+		templates.RenderError(responseWriter, request, tmpl, "count must not be negative", http.StatusBadRequest)
+		return 0, false
+	}
+
+	err := db.SetCubeCardCount(cubeID, cardID, count)
+	switch {
+	case errors.Is(err, database.ErrCubeNotFound):
+		templates.RenderError(responseWriter, request, tmpl, "cube not found", http.StatusNotFound)
+		return 0, false
+	case errors.Is(err, database.ErrCardNotFound):
+		templates.RenderError(responseWriter, request, tmpl, "card not found", http.StatusNotFound)
+		return 0, false
+	case errors.Is(err, database.ErrCubeCountExceedsOwned):
+		templates.RenderError(responseWriter, request, tmpl, "not enough owned copies outside other cubes", http.StatusConflict)
+		return 0, false
+	case err != nil:
+		slog.ErrorContext(request.Context(), "database error setting cube card count", "cube_id", cubeID, "card_id", cardID, "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+		return 0, false
+	}
+
+	slog.InfoContext(request.Context(), "cube card count set", "cube_id", cubeID, "card_id", cardID, "count", count)
+
+	return cubeID, true
+}
+
+// renderCubes renders templateName, either the cube list page or its
+// cubes-list fragment.
+func renderCubes(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer, templateName string) {
+	cubes, err := db.GetCubes()
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error loading cubes", "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	uncubed, err := db.GetUncubedCards()
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error loading uncubed cards", "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	page := cubesPage{
+		Cubes:     cubes,
+		Uncubed:   uncubed,
+		Theme:     db.Settings().Theme,
+		Role:      roles.FromContext(request.Context()),
+		CSRFToken: csrf.Token(request.Context()),
+	}
+
+	render(responseWriter, request, tmpl, templateName, page)
+}
+
+// renderCube renders templateName, either a cube's page or its cube-detail
+// fragment, for cube and its cards.
+func renderCube(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer, templateName string, cube models.Cube, cards []models.CubeCard) {
+	uncubed, err := db.GetUncubedCards()
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error loading uncubed cards", "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	page := cubePage{
+		Cube:      cube,
+		Cards:     cards,
+		Balance:   CheckBalance(cards),
+		Uncubed:   uncubed,
+		Theme:     db.Settings().Theme,
+		Role:      roles.FromContext(request.Context()),
+		CSRFToken: csrf.Token(request.Context()),
+	}
+
+	render(responseWriter, request, tmpl, templateName, page)
+}
+
+// render executes templateName with page as HTML.
+func render(responseWriter http.ResponseWriter, request *http.Request, tmpl templates.Renderer, templateName string, page any) {
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.ExecuteTemplate(responseWriter, templateName, page); err != nil {
+		slog.ErrorContext(request.Context(), "failed to render cubes template", "template", templateName, "error", err)
+		http.Error(responseWriter, "template error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,140 @@
+package cubes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/cubes"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/roles"
+	"swucol/templates"
+)
+
+// newCubeRequest builds a request to target with the {id} and, when not
+// empty, {cardID} path values set.
+func newCubeRequest(method, target, body, id, cardID string) *http.Request {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.SetPathValue("id", id)
+	if cardID != "" {
+		request.SetPathValue("cardID", cardID)
+	}
+	return request
+}
+
+func TestSetCardCountHandler_AssignsCardsAndExportsList(t *testing.T) {
+	db := testdb.New(t)
+	_, err := db.Connection().Exec("INSERT INTO cards (name, owned, aspects, rarity) VALUES ('Luke Skywalker, Jedi Knight', 2, 'Vigilance|Heroism', 'Rare')")
+	require.NoError(t, err)
+
+	createRecorder := httptest.NewRecorder()
+	cubes.CreateHandler(db)(createRecorder, httptest.NewRequest(http.MethodPost, "/cubes", strings.NewReader(`{"name": "Rebels"}`)))
+	require.Equal(t, http.StatusCreated, createRecorder.Code, createRecorder.Body.String())
+
+	setRecorder := httptest.NewRecorder()
+	cubes.SetCardCountHandler(db)(setRecorder, newCubeRequest(http.MethodPut, "/cubes/1/cards/1", `{"count": 2}`, "1", "1"))
+	require.Equal(t, http.StatusNoContent, setRecorder.Code, setRecorder.Body.String())
+
+	getRecorder := httptest.NewRecorder()
+	cubes.GetHandler(db)(getRecorder, newCubeRequest(http.MethodGet, "/cubes/1", "", "1", ""))
+	require.Equal(t, http.StatusOK, getRecorder.Code)
+	var cube struct {
+		CardCount int `json:"card_count"`
+		Balance   cubes.Balance
+	}
+	require.NoError(t, json.Unmarshal(getRecorder.Body.Bytes(), &cube))
+	assert.Equal(t, 2, cube.CardCount)
+	assert.Equal(t, 2, cube.Balance.Total)
+
+	exportRecorder := httptest.NewRecorder()
+	cubes.ExportHandler(db)(exportRecorder, newCubeRequest(http.MethodGet, "/cubes/1/export", "", "1", ""))
+	require.Equal(t, http.StatusOK, exportRecorder.Code)
+	assert.Contains(t, exportRecorder.Header().Get("Content-Disposition"), "attachment")
+	assert.Equal(t, "# Rebels (2 cards)\n2 Luke Skywalker, Jedi Knight\n", exportRecorder.Body.String())
+
+	snippetRecorder := httptest.NewRecorder()
+	cubes.SnippetHandler(db)(snippetRecorder, newCubeRequest(http.MethodGet, "/cubes/1/snippet?format=lines", "", "1", ""))
+	require.Equal(t, http.StatusOK, snippetRecorder.Code)
+	assert.Equal(t, "2x Luke Skywalker, Jedi Knight", snippetRecorder.Body.String())
+}
+
+func TestSetCardCountHandler_MoreThanOwned_ReturnsConflict(t *testing.T) {
+	db := testdb.New(t)
+	_, err := db.Connection().Exec("INSERT INTO cards (name, owned) VALUES ('Luke Skywalker, Jedi Knight', 1)")
+	require.NoError(t, err)
+	_, err = db.CreateCube("Rebels")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	cubes.SetCardCountHandler(db)(recorder, newCubeRequest(http.MethodPut, "/cubes/1/cards/1", `{"count": 2}`, "1", "1"))
+
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+}
+
+func TestGetHandler_UnknownCube_ReturnsNotFound(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := httptest.NewRecorder()
+	cubes.GetHandler(db)(recorder, newCubeRequest(http.MethodGet, "/cubes/42", "", "42", ""))
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestHTMLHandlers_ListUncubedCardsAndAddThem(t *testing.T) {
+	db := testdb.New(t)
+	_, err := db.Connection().Exec("INSERT INTO cards (name, owned) VALUES ('Luke Skywalker, Jedi Knight', 1), ('Chewbacca, Walking Carpet', 0)")
+	require.NoError(t, err)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	createRequest := httptest.NewRequest(http.MethodPost, "/cubes/html", strings.NewReader(url.Values{"name": {"Rebels"}}.Encode()))
+	createRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	createRecorder := httptest.NewRecorder()
+	cubes.CreateHTMLHandler(db, tmpl)(createRecorder, createRequest)
+
+	require.Equal(t, http.StatusOK, createRecorder.Code, createRecorder.Body.String())
+	assert.Contains(t, createRecorder.Body.String(), `href="/cubes/1/html">Rebels</a>`)
+	assert.Contains(t, createRecorder.Body.String(), "Owned cards in no cube (1)")
+	assert.NotContains(t, createRecorder.Body.String(), "Chewbacca")
+
+	addRequest := newCubeRequest(http.MethodPost, "/cubes/1/cards/1/html", "count=1", "1", "1")
+	addRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	addRecorder := httptest.NewRecorder()
+	cubes.SetCardCountHTMLHandler(db, tmpl)(addRecorder, addRequest)
+
+	require.Equal(t, http.StatusOK, addRecorder.Code, addRecorder.Body.String())
+	assert.Contains(t, addRecorder.Body.String(), "Balance (1 card)")
+	assert.Contains(t, addRecorder.Body.String(), "Every owned card is in a cube.")
+
+	pageRecorder := httptest.NewRecorder()
+	cubes.CubeHandler(db, tmpl)(pageRecorder, newCubeRequest(http.MethodGet, "/cubes/1/html", "", "1", ""))
+
+	require.Equal(t, http.StatusOK, pageRecorder.Code)
+	assert.Contains(t, pageRecorder.Body.String(), "<title>Rebels — SWU Collection Manager</title>")
+	assert.Contains(t, pageRecorder.Body.String(), `href="/cubes/1/export"`)
+	assert.Contains(t, pageRecorder.Body.String(), `class="cubes-count"`)
+	assert.NotContains(t, pageRecorder.Body.String(), ".role-admin {")
+
+	viewerRecorder := httptest.NewRecorder()
+	roles.Middleware(db, models.RoleViewer, cubes.CubeHandler(db, tmpl)).ServeHTTP(viewerRecorder, newCubeRequest(http.MethodGet, "/cubes/1/html", "", "1", ""))
+
+	require.Equal(t, http.StatusOK, viewerRecorder.Code)
+	assert.Contains(t, viewerRecorder.Body.String(), ".role-editor, .role-admin {")
+	assert.Contains(t, viewerRecorder.Body.String(), `class="cubes-btn cubes-btn-danger role-admin"`)
+	assert.NotContains(t, viewerRecorder.Body.String(), `class="cubes-count"`, "expected viewers to see counts without inputs")
+
+	editorRecorder := httptest.NewRecorder()
+	roles.Middleware(db, models.RoleEditor, cubes.IndexHandler(db, tmpl)).ServeHTTP(editorRecorder, httptest.NewRequest(http.MethodGet, "/cubes/html", nil))
+
+	require.Equal(t, http.StatusOK, editorRecorder.Code)
+	assert.Contains(t, editorRecorder.Body.String(), ".role-admin {")
+	assert.NotContains(t, editorRecorder.Body.String(), ".role-editor, .role-admin {")
+	assert.Contains(t, editorRecorder.Body.String(), `class="cubes-form role-editor"`)
+}
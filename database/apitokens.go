@@ -0,0 +1,213 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"swucol/models"
+)
+
+// ErrAPITokenNotFound is returned when no token matches an ID, or when a
+// secret does not belong to an active token.
+var ErrAPITokenNotFound = errors.New("API token not found")
+
+// apiTokenPrefix starts every token secret, so that leaked tokens are easy
+// to recognize.
+const apiTokenPrefix = "swucol_"
+
+// apiTokenScopes are the scopes a token may be given.
+var apiTokenScopes = []string{models.ScopeRead, models.ScopeWrite, models.ScopeImport}
+
+// ValidateAPIToken returns an error describing why a token named name with
+// scopes and role cannot be created, or nil when it can.
+func ValidateAPIToken(name string, scopes []string, role string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("name is required")
+	}
+
+	if len(scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(apiTokenScopes, scope) {
+			return fmt.Errorf("scopes must be some of: %s", strings.Join(apiTokenScopes, ", "))
+		}
+	}
+
+	if !slices.Contains(models.Roles, role) {
+		return fmt.Errorf("role must be one of: %s", strings.Join(models.Roles, ", "))
+	}
+
+	return nil
+}
+
+// hashAPIToken returns the stored form of a token secret.
+func hashAPIToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken stores a new token and returns it with its secret, which is
+// not recoverable later. Returns an error if ValidateAPIToken rejects name,
+// scopes or role.
+func (database *Database) CreateAPIToken(name string, scopes []string, role string) (models.APIToken, string, error) {
+	if err := ValidateAPIToken(name, scopes, role); err != nil {
+		return models.APIToken{}, "", fmt.Errorf("create API token: %w", err)
+	}
+
+	scopes = slices.Compact(slices.Sorted(slices.Values(scopes)))
+	secret := apiTokenPrefix + rand.Text()
+
+	result, err := database.connection.Exec(
+		"INSERT INTO api_tokens (name, token_hash, scopes, role, created_at) VALUES (?, ?, ?, ?, ?)",
+		strings.TrimSpace(name), hashAPIToken(secret), strings.Join(scopes, ","), role, currentTimestamp(),
+	)
+	if err != nil {
+		return models.APIToken{}, "", fmt.Errorf("create API token: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return models.APIToken{}, "", fmt.Errorf("create API token: last insert id: %w", err)
+	}
+
+	token, err := scanAPIToken(database.connection.QueryRow("SELECT "+apiTokenColumns+" FROM api_tokens WHERE id = ?", id))
+	if err != nil {
+		return models.APIToken{}, "", fmt.Errorf("create API token: read back: %w", err)
+	}
+
+	return token, secret, nil
+}
+
+// apiTokenColumns is the column list read by scanAPIToken.
+const apiTokenColumns = "id, name, scopes, role, created_at, last_used_at, revoked_at"
+
+// scanAPIToken reads a row selecting apiTokenColumns.
+func scanAPIToken(row rowScanner) (models.APIToken, error) {
+	var (
+		token                          models.APIToken
+		scopes                         string
+		createdAt, lastUsed, revokedAt sql.NullString
+	)
+	if err := row.Scan(&token.ID, &token.Name, &scopes, &token.Role, &createdAt, &lastUsed, &revokedAt); err != nil {
+		return models.APIToken{}, err
+	}
+
+	token.Scopes = strings.Split(scopes, ",")
+
+	var err error
+	if token.CreatedAt, err = parseTimestamp(createdAt); err != nil {
+		return models.APIToken{}, err
+	}
+	if token.LastUsedAt, err = parseTimestamp(lastUsed); err != nil {
+		return models.APIToken{}, err
+	}
+	if token.RevokedAt, err = parseTimestamp(revokedAt); err != nil {
+		return models.APIToken{}, err
+	}
+
+	return token, nil
+}
+
+// GetAPITokens returns every token, including revoked ones, oldest first.
+// Returns an empty slice (never nil) when none exist.
+func (database *Database) GetAPITokens() ([]models.APIToken, error) {
+	rows, err := database.connection.Query("SELECT " + apiTokenColumns + " FROM api_tokens ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("get API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := make([]models.APIToken, 0)
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get API tokens: scan: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get API tokens: rows: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// HasActiveAPITokens reports whether any token has been created and not
+// revoked.
+func (database *Database) HasActiveAPITokens() (bool, error) {
+	var active bool
+	if err := database.connection.QueryRow("SELECT EXISTS (SELECT 1 FROM api_tokens WHERE revoked_at IS NULL)").Scan(&active); err != nil {
+		return false, fmt.Errorf("has active API tokens: %w", err)
+	}
+	return active, nil
+}
+
+// RevokeAPIToken revokes the token with the given id. Revoking a token that
+// is already revoked keeps its original revocation time. Returns
+// ErrAPITokenNotFound if no token with that id exists.
+func (database *Database) RevokeAPIToken(id int) error {
+	result, err := database.connection.Exec(
+		"UPDATE api_tokens SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?",
+		currentTimestamp(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("revoke API token: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("revoke API token: rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrAPITokenNotFound
+	}
+
+	return nil
+}
+
+// apiTokenUsageInterval is how stale a token's last use may get before
+// AuthenticateAPIToken records it again.
+const apiTokenUsageInterval = time.Minute
+
+// AuthenticateAPIToken returns the active token whose secret is secret. The
+// lookup runs on the read pool, so that authenticating does not wait for
+// writes such as a running import; the time the token was used is written
+// only when the recorded one is older than apiTokenUsageInterval. Returns
+// ErrAPITokenNotFound for unknown or revoked secrets.
+func (database *Database) AuthenticateAPIToken(secret string) (models.APIToken, error) {
+	token, err := scanAPIToken(database.connection.QueryRow(
+		"SELECT "+apiTokenColumns+" FROM api_tokens WHERE token_hash = ? AND revoked_at IS NULL",
+		hashAPIToken(secret),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIToken{}, ErrAPITokenNotFound
+	}
+	if err != nil {
+		return models.APIToken{}, fmt.Errorf("authenticate API token: %w", err)
+	}
+
+	usedAt := time.Now()
+	if usedAt.Sub(token.LastUsedAt) < apiTokenUsageInterval {
+		return token, nil
+	}
+
+	stored := formatTimestamp(usedAt)
+	if _, err := database.connection.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", stored, token.ID); err != nil {
+		return models.APIToken{}, fmt.Errorf("authenticate API token: record use: %w", err)
+	}
+	if token.LastUsedAt, err = parseTimestamp(stored); err != nil {
+		return models.APIToken{}, fmt.Errorf("authenticate API token: %w", err)
+	}
+
+	return token, nil
+}
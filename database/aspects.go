@@ -0,0 +1,124 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"swucol/models"
+)
+
+// SplitAspects splits a card's aspects string into the aspect names in it.
+// CSV exports join aspects with "|", but commas and spaces are accepted as
+// separators too.
+func SplitAspects(aspects string) []string {
+	return strings.FieldsFunc(aspects, func(character rune) bool {
+		return character == '|' || character == ',' || unicode.IsSpace(character)
+	})
+}
+
+// normalizeAspects returns the known aspects named in aspects, spelled as in
+// models.Aspects and without duplicates. Unknown names are left out.
+func normalizeAspects(aspects string) []string {
+	var normalized []string
+	for _, name := range SplitAspects(aspects) {
+		for _, aspect := range models.Aspects {
+			if strings.EqualFold(name, aspect) && !slices.Contains(normalized, aspect) {
+				normalized = append(normalized, aspect)
+			}
+		}
+	}
+	return normalized
+}
+
+// queryExecer is satisfied by *sql.Tx and by the instrumented connection and
+// transaction.
+type queryExecer interface {
+	execer
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// syncCardAspects replaces the card_aspects rows of every card matching
+// condition, a WHERE clause over the cards table, with the aspects parsed
+// from its aspects column. Every statement that writes that column calls it
+// for the cards it wrote.
+func syncCardAspects(executor queryExecer, condition string, args ...any) error {
+	rows, err := executor.Query("SELECT id, aspects FROM cards WHERE "+condition, args...)
+	if err != nil {
+		return fmt.Errorf("sync card aspects: %w", err)
+	}
+
+	type cardAspects struct {
+		id      int
+		aspects string
+	}
+	var cards []cardAspects
+	for rows.Next() {
+		var card cardAspects
+		if err := rows.Scan(&card.id, &card.aspects); err != nil {
+			rows.Close()
+			return fmt.Errorf("sync card aspects: scan: %w", err)
+		}
+		cards = append(cards, card)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("sync card aspects: rows: %w", err)
+	}
+
+	for _, card := range cards {
+		if _, err := executor.Exec("DELETE FROM card_aspects WHERE card_id = ?", card.id); err != nil {
+			return fmt.Errorf("sync card aspects: clear card %d: %w", card.id, err)
+		}
+		for _, aspect := range normalizeAspects(card.aspects) {
+			if _, err := executor.Exec("INSERT INTO card_aspects (card_id, aspect) VALUES (?, ?)", card.id, aspect); err != nil {
+				return fmt.Errorf("sync card aspects: insert %s for card %d: %w", aspect, card.id, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// rebuildCardAspects recreates the whole card_aspects table from the cards
+// table.
+func rebuildCardAspects(executor queryExecer) error {
+	if _, err := executor.Exec("DELETE FROM card_aspects"); err != nil {
+		return fmt.Errorf("rebuild card aspects: %w", err)
+	}
+	return syncCardAspects(executor, "aspects <> ''")
+}
+
+// GetCardAspects returns the aspects of the card with the given id from the
+// card_aspects table, in the order of models.Aspects. Returns an empty slice
+// (never nil) for a neutral or unknown card.
+func (database *Database) GetCardAspects(id int) ([]string, error) {
+	rows, err := database.connection.Query("SELECT aspect FROM card_aspects WHERE card_id = ?", id)
+	if err != nil {
+		return nil, fmt.Errorf("get card aspects: %w", err)
+	}
+	defer rows.Close()
+
+	var stored []string
+	for rows.Next() {
+		var aspect string
+		if err := rows.Scan(&aspect); err != nil {
+			return nil, fmt.Errorf("get card aspects: scan: %w", err)
+		}
+		stored = append(stored, aspect)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get card aspects: rows: %w", err)
+	}
+
+	aspects := make([]string, 0, len(stored))
+	for _, aspect := range models.Aspects {
+		if slices.Contains(stored, aspect) {
+			aspects = append(aspects, aspect)
+		}
+	}
+
+	return aspects, nil
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"swucol/models"
+)
+
+// AuditFilter selects audit events. Empty fields match every event. Card
+// matches card names case-insensitively by substring, and From and To bound
+// the time the event occurred at, From inclusive and To exclusive.
+type AuditFilter struct {
+	Card   string
+	User   string
+	Action string
+	From   time.Time
+	To     time.Time
+}
+
+// where returns the WHERE clause, including the keyword, and its arguments
+// for filter, or "" when it matches every event.
+func (filter AuditFilter) where() (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	if filter.Card != "" {
+		conditions = append(conditions, "instr(lower(card_name), lower(?)) > 0")
+		args = append(args, filter.Card)
+	}
+	if filter.User != "" {
+		conditions = append(conditions, "user_name = ?")
+		args = append(args, filter.User)
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "occurred_at >= ?")
+		args = append(args, formatTimestamp(filter.From))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "occurred_at < ?")
+		args = append(args, formatTimestamp(filter.To))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// RecordAuditEvent stores event. OccurredAt defaults to the current time
+// when zero, and a zero CardID is stored as NULL.
+func (database *Database) RecordAuditEvent(event models.AuditEvent) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	cardID := sql.NullInt64{Int64: int64(event.CardID), Valid: event.CardID > 0}
+
+	_, err := database.connection.Exec(
+		"INSERT INTO audit_events (occurred_at, user_name, action, card_id, card_name, detail) VALUES (?, ?, ?, ?, ?, ?)",
+		formatTimestamp(event.OccurredAt), event.User, event.Action, cardID, event.CardName, event.Detail,
+	)
+	if err != nil {
+		return fmt.Errorf("record audit event: %w", err)
+	}
+
+	return nil
+}
+
+// GetAuditEvents returns the events matching filter, newest first, skipping
+// the first offset and returning at most limit; a limit of 0 returns every
+// event after offset. Returns an empty slice (never nil) when none match.
+func (database *Database) GetAuditEvents(filter AuditFilter, limit, offset int) ([]models.AuditEvent, error) {
+	where, args := filter.where()
+
+	query := "SELECT id, occurred_at, user_name, action, card_id, card_name, detail FROM audit_events" + where + " ORDER BY occurred_at DESC, id DESC"
+	if limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, offset)
+	} else if offset > 0 {
+		query += " LIMIT -1 OFFSET ?"
+		args = append(args, offset)
+	}
+
+	rows, err := database.connection.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("get audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var (
+			event      models.AuditEvent
+			occurredAt sql.NullString
+			cardID     sql.NullInt64
+		)
+		if err := rows.Scan(&event.ID, &occurredAt, &event.User, &event.Action, &cardID, &event.CardName, &event.Detail); err != nil {
+			return nil, fmt.Errorf("get audit events: scan: %w", err)
+		}
+		if event.OccurredAt, err = parseTimestamp(occurredAt); err != nil {
+			return nil, fmt.Errorf("get audit events: parse occurred_at: %w", err)
+		}
+		event.CardID = int(cardID.Int64)
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get audit events: rows: %w", err)
+	}
+
+	return events, nil
+}
+
+// CountAuditEvents returns the number of events matching filter.
+func (database *Database) CountAuditEvents(filter AuditFilter) (int, error) {
+	where, args := filter.where()
+
+	var count int
+	if err := database.connection.QueryRow("SELECT COUNT(*) FROM audit_events"+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count audit events: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetAuditUsers returns the distinct users of the audit events, sorted, for
+// the audit page's user filter. The anonymous user "" is left out. Returns an
+// empty slice (never nil) when there are none.
+func (database *Database) GetAuditUsers() ([]string, error) {
+	rows, err := database.connection.Query("SELECT DISTINCT user_name FROM audit_events WHERE user_name != '' ORDER BY user_name")
+	if err != nil {
+		return nil, fmt.Errorf("get audit users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []string{}
+	for rows.Next() {
+		var user string
+		if err := rows.Scan(&user); err != nil {
+			return nil, fmt.Errorf("get audit users: scan: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get audit users: rows: %w", err)
+	}
+
+	return users, nil
+}
+
+// GetAuditDailyCounts returns the number of audit events on each UTC day in
+// [from, to), keyed by date (2006-01-02). Days without events are left out.
+func (database *Database) GetAuditDailyCounts(from, to time.Time) (map[string]int, error) {
+	// Timestamps are stored in UTC with a fixed-width layout, so their first
+	// ten characters are the UTC date.
+	rows, err := database.connection.Query(
+		"SELECT substr(occurred_at, 1, 10) AS day, COUNT(*) FROM audit_events WHERE occurred_at >= ? AND occurred_at < ? GROUP BY day",
+		formatTimestamp(from), formatTimestamp(to),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get audit daily counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var (
+			day   string
+			count int
+		)
+		if err := rows.Scan(&day, &count); err != nil {
+			return nil, fmt.Errorf("get audit daily counts: scan: %w", err)
+		}
+		counts[day] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get audit daily counts: rows: %w", err)
+	}
+
+	return counts, nil
+}
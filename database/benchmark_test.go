@@ -0,0 +1,62 @@
+package database_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"swucol/database"
+	"swucol/loadtest"
+)
+
+// benchmarkCards is the number of cards the benchmarks seed.
+const benchmarkCards = 5000
+
+// newSeededDatabase creates a migrated temporary Database holding
+// benchmarkCards synthetic cards.
+func newSeededDatabase(b *testing.B) *database.Database {
+	b.Helper()
+
+	db, err := database.New(filepath.Join(b.TempDir(), "bench.db"))
+	require.NoError(b, err)
+	b.Cleanup(func() { db.Shutdown() })
+	require.NoError(b, db.RunMigrations())
+	require.NoError(b, loadtest.Seed(db, benchmarkCards))
+
+	return db
+}
+
+func BenchmarkSearchCards(b *testing.B) {
+	db := newSeededDatabase(b)
+
+	for _, query := range []string{"trooper 01", "aspect:heroism owned<2", "type:event -aspect:cunning"} {
+		b.Run(query, func(b *testing.B) {
+			for b.Loop() {
+				if _, err := db.SearchCards(query); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkInsertCards(b *testing.B) {
+	cards, _ := loadtest.Cards(1000)
+
+	for b.Loop() {
+		b.StopTimer()
+		db, err := database.New(filepath.Join(b.TempDir(), "bench.db"))
+		require.NoError(b, err)
+		require.NoError(b, db.RunMigrations())
+		b.StartTimer()
+
+		if err := db.InsertCards(cards); err != nil {
+			b.Fatal(err)
+		}
+
+		b.StopTimer()
+		db.Shutdown()
+		b.StartTimer()
+	}
+}
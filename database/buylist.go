@@ -0,0 +1,93 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"swucol/models"
+)
+
+// ReplaceBuylistPrices replaces the cached buylist of vendor with prices and
+// records fetchedAt as its refresh time, in one transaction.
+func (database *Database) ReplaceBuylistPrices(vendor string, prices []models.BuylistPrice, fetchedAt time.Time) error {
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return fmt.Errorf("replace buylist prices: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	if _, err := transaction.Exec("DELETE FROM buylist_prices WHERE vendor = ?", vendor); err != nil {
+		return fmt.Errorf("replace buylist prices: delete: %w", err)
+	}
+
+	for _, price := range prices {
+		_, err := transaction.Exec(
+			"INSERT INTO buylist_prices (vendor, name, set_code, card_number, price_cents) VALUES (?, ?, ?, ?, ?)",
+			vendor, price.Name, price.Set, price.Number, price.PriceCents,
+		)
+		if err != nil {
+			return fmt.Errorf("replace buylist prices: insert %q: %w", price.Name, err)
+		}
+	}
+
+	_, err = transaction.Exec(
+		`INSERT INTO buylist_refreshes (vendor, fetched_at) VALUES (?, ?)
+		ON CONFLICT (vendor) DO UPDATE SET fetched_at = excluded.fetched_at`,
+		vendor, fetchedAt.UTC().Format(timestampLayout),
+	)
+	if err != nil {
+		return fmt.Errorf("replace buylist prices: record refresh: %w", err)
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("replace buylist prices: commit: %w", err)
+	}
+
+	return nil
+}
+
+// GetBuylistPrices returns every cached buylist price ordered by vendor and
+// name. Returns an empty slice (never nil) when nothing is cached.
+func (database *Database) GetBuylistPrices() ([]models.BuylistPrice, error) {
+	rows, err := database.connection.Query(
+		"SELECT vendor, name, set_code, card_number, price_cents FROM buylist_prices ORDER BY vendor, name COLLATE NOCASE, set_code, card_number",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get buylist prices: %w", err)
+	}
+	defer rows.Close()
+
+	prices := []models.BuylistPrice{}
+	for rows.Next() {
+		var price models.BuylistPrice
+		if err := rows.Scan(&price.Vendor, &price.Name, &price.Set, &price.Number, &price.PriceCents); err != nil {
+			return nil, fmt.Errorf("get buylist prices: scan: %w", err)
+		}
+		prices = append(prices, price)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get buylist prices: rows: %w", err)
+	}
+
+	return prices, nil
+}
+
+// GetBuylistRefreshTime returns when the buylist of vendor was last fetched,
+// or the zero time when it never has been.
+func (database *Database) GetBuylistRefreshTime(vendor string) (time.Time, error) {
+	var fetchedAt sql.NullString
+	err := database.connection.QueryRow("SELECT fetched_at FROM buylist_refreshes WHERE vendor = ?", vendor).Scan(&fetchedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, fmt.Errorf("get buylist refresh time: %w", err)
+	}
+
+	fetched, err := parseTimestamp(fetchedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get buylist refresh time: parse: %w", err)
+	}
+
+	return fetched, nil
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"swucol/models"
+)
+
+// ErrCartItemNotFound is returned when a card is not in the cart.
+var ErrCartItemNotFound = errors.New("cart item not found")
+
+// SetCartQuantity puts quantity copies of the card with the given id in the
+// cart of session, replacing the quantity if the card is already there.
+// Returns ErrCardNotFound if no card with that id exists.
+func (database *Database) SetCartQuantity(session string, cardID, quantity int) error {
+	if session == "" {
+		return errors.New("session must not be empty")
+	}
+	if quantity < 1 {
+		return errors.New("quantity must be at least 1")
+	}
+
+	exists, err := database.cardExistsByID(cardID)
+	if err != nil {
+		return fmt.Errorf("set cart quantity: check card exists: %w", err)
+	}
+	if !exists {
+		return ErrCardNotFound
+	}
+
+	_, err = database.connection.Exec(
+		`INSERT INTO cart_items (session, card_id, quantity, added_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (session, card_id) DO UPDATE SET quantity = excluded.quantity`,
+		session, cardID, quantity, currentTimestamp(),
+	)
+	if err != nil {
+		return fmt.Errorf("set cart quantity: %w", err)
+	}
+
+	return nil
+}
+
+// GetCart returns the cards in the cart of session ordered by name. Returns
+// an empty slice (never nil) when the cart is empty.
+func (database *Database) GetCart(session string) ([]models.CartItem, error) {
+	rows, err := database.connection.Query(
+		`SELECT cards.id, cards.name, cards.set_code, cards.card_number, cart_items.quantity
+		FROM cart_items JOIN cards ON cards.id = cart_items.card_id
+		WHERE cart_items.session = ? ORDER BY cards.name COLLATE NOCASE, cards.id`,
+		session,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get cart: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.CartItem{}
+	for rows.Next() {
+		var item models.CartItem
+		if err := rows.Scan(&item.CardID, &item.Name, &item.Set, &item.Number, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("get cart: scan: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get cart: rows: %w", err)
+	}
+
+	return items, nil
+}
+
+// RemoveFromCart takes the card with the given id out of the cart of
+// session. Returns ErrCartItemNotFound if it is not in the cart.
+func (database *Database) RemoveFromCart(session string, cardID int) error {
+	result, err := database.connection.Exec("DELETE FROM cart_items WHERE session = ? AND card_id = ?", session, cardID)
+	if err != nil {
+		return fmt.Errorf("remove from cart: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("remove from cart rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrCartItemNotFound
+	}
+
+	return nil
+}
+
+// ClearCart empties the cart of session and returns the number of cards
+// removed.
+func (database *Database) ClearCart(session string) (int, error) {
+	result, err := database.connection.Exec("DELETE FROM cart_items WHERE session = ?", session)
+	if err != nil {
+		return 0, fmt.Errorf("clear cart: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("clear cart rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
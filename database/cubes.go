@@ -0,0 +1,245 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"swucol/models"
+)
+
+// ErrCubeNotFound is returned when no cube has the requested ID.
+var ErrCubeNotFound = errors.New("cube not found")
+
+// ErrCubeCountExceedsOwned is returned by SetCubeCardCount when the copies
+// of a card across every cube would exceed the number owned.
+var ErrCubeCountExceedsOwned = errors.New("cube count exceeds owned copies")
+
+// cubeColumns selects the columns read by scanCube from cubes.
+const cubeColumns = "id, name, (SELECT COALESCE(SUM(count), 0) FROM cube_cards WHERE cube_id = cubes.id), created_at"
+
+// scanCube reads a row selecting cubeColumns.
+func scanCube(row rowScanner) (models.Cube, error) {
+	var (
+		cube      models.Cube
+		createdAt sql.NullString
+	)
+	if err := row.Scan(&cube.ID, &cube.Name, &cube.CardCount, &createdAt); err != nil {
+		return models.Cube{}, err
+	}
+
+	var err error
+	if cube.CreatedAt, err = parseTimestamp(createdAt); err != nil {
+		return models.Cube{}, err
+	}
+
+	return cube, nil
+}
+
+// CreateCube stores an empty cube named name and returns it. Returns an error
+// if name is blank.
+func (database *Database) CreateCube(name string) (models.Cube, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.Cube{}, errors.New("create cube: name is required")
+	}
+
+	cube, err := scanCube(database.connection.QueryRow(
+		"INSERT INTO cubes (name, created_at) VALUES (?, ?) RETURNING id, name, 0, created_at",
+		name, currentTimestamp(),
+	))
+	if err != nil {
+		return models.Cube{}, fmt.Errorf("create cube: %w", err)
+	}
+
+	return cube, nil
+}
+
+// GetCubes returns every cube ordered by name. Returns an empty slice (never
+// nil) when there are none.
+func (database *Database) GetCubes() ([]models.Cube, error) {
+	rows, err := database.connection.Query("SELECT " + cubeColumns + " FROM cubes ORDER BY name COLLATE NOCASE, id")
+	if err != nil {
+		return nil, fmt.Errorf("get cubes: %w", err)
+	}
+	defer rows.Close()
+
+	cubes := make([]models.Cube, 0)
+	for rows.Next() {
+		cube, err := scanCube(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get cubes: scan: %w", err)
+		}
+		cubes = append(cubes, cube)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get cubes: rows: %w", err)
+	}
+
+	return cubes, nil
+}
+
+// GetCube returns the cube with the given id. Returns ErrCubeNotFound if no
+// cube with that id exists.
+func (database *Database) GetCube(id int) (models.Cube, error) {
+	cube, err := scanCube(database.connection.QueryRow("SELECT "+cubeColumns+" FROM cubes WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Cube{}, ErrCubeNotFound
+	}
+	if err != nil {
+		return models.Cube{}, fmt.Errorf("get cube: %w", err)
+	}
+
+	return cube, nil
+}
+
+// DeleteCube removes the cube with the given id and its card assignments.
+// Returns ErrCubeNotFound if no cube with that id exists.
+func (database *Database) DeleteCube(id int) error {
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return fmt.Errorf("delete cube: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	if _, err := transaction.Exec("DELETE FROM cube_cards WHERE cube_id = ?", id); err != nil {
+		return fmt.Errorf("delete cube: cards: %w", err)
+	}
+
+	result, err := transaction.Exec("DELETE FROM cubes WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete cube: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete cube: rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrCubeNotFound
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("delete cube: commit: %w", err)
+	}
+
+	return nil
+}
+
+// SetCubeCardCount sets the number of copies of a card in a cube; a count of
+// zero removes the card from the cube. The copies of the card across every
+// cube may not exceed the number owned, since a physical card can only sit in
+// one cube. Returns ErrCubeNotFound or ErrCardNotFound for unknown ids, and
+// ErrCubeCountExceedsOwned when there are not enough spare copies.
+func (database *Database) SetCubeCardCount(cubeID, cardID, count int) error {
+	if count < 0 {
+		return errors.New("set cube card count: count must not be negative")
+	}
+
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return fmt.Errorf("set cube card count: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	var exists int
+	err = transaction.QueryRow("SELECT 1 FROM cubes WHERE id = ?", cubeID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCubeNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("set cube card count: cube: %w", err)
+	}
+
+	var owned, inOtherCubes int
+	err = transaction.QueryRow(
+		"SELECT owned, (SELECT COALESCE(SUM(count), 0) FROM cube_cards WHERE card_id = cards.id AND cube_id <> ?) FROM cards WHERE id = ?",
+		cubeID, cardID,
+	).Scan(&owned, &inOtherCubes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCardNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("set cube card count: card: %w", err)
+	}
+
+	if count+inOtherCubes > owned {
+		return ErrCubeCountExceedsOwned
+	}
+
+	if count == 0 {
+		_, err = transaction.Exec("DELETE FROM cube_cards WHERE cube_id = ? AND card_id = ?", cubeID, cardID)
+	} else {
+		_, err = transaction.Exec(
+			"INSERT INTO cube_cards (cube_id, card_id, count) VALUES (?, ?, ?) ON CONFLICT (cube_id, card_id) DO UPDATE SET count = excluded.count",
+			cubeID, cardID, count,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("set cube card count: %w", err)
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("set cube card count: commit: %w", err)
+	}
+
+	return nil
+}
+
+// GetCubeCards returns the cards in the cube with the given id with their
+// counts, ordered by name. Returns ErrCubeNotFound if no cube with that id
+// exists, and an empty slice (never nil) for an empty cube.
+func (database *Database) GetCubeCards(cubeID int) ([]models.CubeCard, error) {
+	if _, err := database.GetCube(cubeID); err != nil {
+		return nil, err
+	}
+
+	rows, err := database.connection.Query("SELECT card_id, count FROM cube_cards WHERE cube_id = ?", cubeID)
+	if err != nil {
+		return nil, fmt.Errorf("get cube cards: counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var cardID, count int
+		if err := rows.Scan(&cardID, &count); err != nil {
+			return nil, fmt.Errorf("get cube cards: scan count: %w", err)
+		}
+		counts[cardID] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get cube cards: counts rows: %w", err)
+	}
+
+	cards, err := database.queryCards(
+		"SELECT "+cardColumns+" FROM cards WHERE id IN (SELECT card_id FROM cube_cards WHERE cube_id = ?) ORDER BY name COLLATE NOCASE, id",
+		cubeID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get cube cards: %w", err)
+	}
+
+	cubeCards := make([]models.CubeCard, len(cards))
+	for i, card := range cards {
+		cubeCards[i] = models.CubeCard{Card: card, Count: counts[card.ID]}
+	}
+
+	return cubeCards, nil
+}
+
+// GetUncubedCards returns the owned, non-archived cards that are not in any
+// cube, ordered by name. Returns an empty slice (never nil) when every owned
+// card is in a cube.
+func (database *Database) GetUncubedCards() ([]models.Card, error) {
+	cards, err := database.queryCards(
+		"SELECT " + cardColumns + " FROM cards WHERE owned > 0 AND archived = 0 AND id NOT IN (SELECT card_id FROM cube_cards) ORDER BY name COLLATE NOCASE, id",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get uncubed cards: %w", err)
+	}
+
+	return cards, nil
+}
@@ -2,47 +2,154 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	_ "modernc.org/sqlite" // Register the SQLite driver.
 
 	"swucol/models"
+	"swucol/search"
 )
 
 // ErrCardNotFound is returned by GetCardByID when no card with the given ID exists.
 var ErrCardNotFound = errors.New("card not found")
 
-// MainboardMinimumOwned is the minimum number of copies required for mainboard cards.
-const MainboardMinimumOwned = 6
+// ErrAliasNotFound is returned by DeleteCardAlias when the card has no such alias.
+var ErrAliasNotFound = errors.New("alias not found")
+
+// ErrSnapshotNotFound is returned when no collection snapshot with the given ID exists.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// Default minimum numbers of copies required for each card type, and for
+// cards whose type is unknown. A deck holds one leader and one base, up to
+// three copies of any event, and units are wanted for two decks. The
+// minimums in use are the ones in Settings.
+const (
+	LeaderMinimumOwned  = 1
+	BaseMinimumOwned    = 1
+	UnitMinimumOwned    = 6
+	EventMinimumOwned   = 3
+	UpgradeMinimumOwned = 3
+	TokenMinimumOwned   = 1
+	UntypedMinimumOwned = 6
+)
+
+// Wishlist priority levels stored in the priority column. Normal is zero so
+// that cards without an explicit priority sort between the other two.
+const (
+	PriorityLow    = -1
+	PriorityNormal = 0
+	PriorityHigh   = 1
+)
+
+// timestampLayout is the fixed-width UTC layout used for the created_at and
+// updated_at columns. A fixed width keeps lexical ordering in SQL consistent
+// with chronological ordering.
+const timestampLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// cardColumns is the column list selected by every query that returns full
+// card records. It must stay in sync with scanCard. The last column is
+// computed from unreleased_sets rather than stored.
+const cardColumns = "id, name, image, thumbnail, image_source, back_image, image_failed, owned, mainboard, archived, priority, set_code, card_number, card_type, aspects, rarity, cost, power, hp, traits, rules_text, created_at, updated_at, " + unreleasedClause
+
+// searchClause parses query in the syntax of the search package and returns
+// the condition on the cards table it describes and that condition's
+// arguments. The condition is empty for an empty query. Returns an error
+// wrapping search.ErrInvalidQuery when query cannot be parsed.
+func (database *Database) searchClause(query string) (string, []any, error) {
+	parsed, err := search.Parse(query)
+	if err != nil {
+		return "", nil, err
+	}
+
+	condition, args := parsed.SQL(search.Threshold{Expression: minimumOwnedExpression, Args: database.minimumOwnedArgs()})
+	return condition, args, nil
+}
 
-// NonMainboardMinimumOwned is the minimum number of copies required for non-mainboard cards.
-const NonMainboardMinimumOwned = 3
+// RecentKind selects which timestamp GetRecentCards orders by.
+type RecentKind string
+
+const (
+	// RecentAdded lists cards by the time they were inserted.
+	RecentAdded RecentKind = "added"
+
+	// RecentChanged lists cards by the time they were last modified after
+	// insert.
+	RecentChanged RecentKind = "changed"
+)
 
 // Database wraps a sql.DB connection and provides schema management.
 type Database struct {
-	connection *sql.DB
+	connection *instrumentedDB
+
+	// settings caches the stored settings so that queries depending on them
+	// do not read the settings table each time.
+	settingsMutex sync.RWMutex
+	settings      models.Settings
 }
 
+// readConnections is the size of the read connection pool.
+const readConnections = 4
+
+// busyTimeoutMillis is how long a connection waits for a lock held by another
+// connection before failing with SQLITE_BUSY.
+const busyTimeoutMillis = 5000
+
 // New opens (or creates) a SQLite database file at the given filePath and
-// returns a Database instance. Returns an error if the path is empty or the
+// returns a Database instance. The file is switched to WAL journaling and
+// opened twice: one write connection, so that writes are serialized in the
+// process rather than contending for the file lock, and a pool of
+// readConnections query-only connections, so that searches keep running
+// while an import writes. Returns an error if the path is empty or either
 // connection cannot be established.
 func New(filePath string) (*Database, error) {
 	if filePath == "" {
 		return nil, errors.New("database file path must not be empty")
 	}
 
-	connection, err := sql.Open("sqlite", filePath)
+	writer, err := open(filePath, "_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	writer.SetMaxOpenConns(1)
+
+	reader, err := open(filePath, "_pragma=query_only(1)")
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+	reader.SetMaxOpenConns(readConnections)
+
+	return &Database{
+		connection: &instrumentedDB{DB: writer, reader: reader, instruments: newQueryInstruments()},
+		settings:   DefaultSettings(),
+	}, nil
+}
+
+// open opens and pings a connection pool to the SQLite file at filePath with
+// the busy timeout, foreign key enforcement, so that the ON DELETE CASCADE
+// clauses of the schema apply, and the given extra DSN parameters.
+func open(filePath, parameters string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("%s?_pragma=busy_timeout(%d)&_pragma=foreign_keys(1)&%s", filePath, busyTimeoutMillis, parameters)
+
+	connection, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("open sqlite database: %w", err)
 	}
 
 	if err := connection.Ping(); err != nil {
+		connection.Close()
 		return nil, fmt.Errorf("ping sqlite database: %w", err)
 	}
 
-	return &Database{connection: connection}, nil
+	return connection, nil
 }
 
 // RunMigrations creates all required tables if they do not already exist and
@@ -62,10 +169,513 @@ func (database *Database) RunMigrations() error {
 		return fmt.Errorf("create cards table: %w", err)
 	}
 
+	createCardAliasesTable := `
+		CREATE TABLE IF NOT EXISTS card_aliases (
+			id      INTEGER PRIMARY KEY AUTOINCREMENT,
+			card_id INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+			alias   TEXT    NOT NULL,
+			UNIQUE (card_id, alias COLLATE NOCASE)
+		);
+	`
+
+	if _, err := database.connection.Exec(createCardAliasesTable); err != nil {
+		return fmt.Errorf("create card_aliases table: %w", err)
+	}
+
+	createCardTranslationsTable := `
+		CREATE TABLE IF NOT EXISTS card_translations (
+			card_id  INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+			language TEXT    NOT NULL,
+			name     TEXT    NOT NULL,
+			PRIMARY KEY (card_id, language)
+		);
+		CREATE INDEX IF NOT EXISTS card_translations_name ON card_translations (name COLLATE NOCASE);
+	`
+
+	if _, err := database.connection.Exec(createCardTranslationsTable); err != nil {
+		return fmt.Errorf("create card_translations table: %w", err)
+	}
+
 	if err := database.addColumnIfNotExists("cards", "mainboard", "INTEGER NOT NULL DEFAULT 1"); err != nil {
 		return fmt.Errorf("add mainboard column: %w", err)
 	}
 
+	if err := database.addColumnIfNotExists("cards", "archived", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("add archived column: %w", err)
+	}
+
+	for _, column := range []string{"set_code", "card_number", "card_type", "aspects", "rarity"} {
+		if err := database.addColumnIfNotExists("cards", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return fmt.Errorf("add %s column: %w", column, err)
+		}
+	}
+
+	if err := database.addColumnIfNotExists("cards", "created_at", "TEXT"); err != nil {
+		return fmt.Errorf("add created_at column: %w", err)
+	}
+
+	if err := database.addColumnIfNotExists("cards", "updated_at", "TEXT"); err != nil {
+		return fmt.Errorf("add updated_at column: %w", err)
+	}
+
+	if err := database.addColumnIfNotExists("cards", "image_failed", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("add image_failed column: %w", err)
+	}
+
+	if err := database.addColumnIfNotExists("cards", "thumbnail", "TEXT"); err != nil {
+		return fmt.Errorf("add thumbnail column: %w", err)
+	}
+
+	if err := database.addColumnIfNotExists("cards", "image_source", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("add image_source column: %w", err)
+	}
+
+	if err := database.addColumnIfNotExists("cards", "back_image", "TEXT"); err != nil {
+		return fmt.Errorf("add back_image column: %w", err)
+	}
+
+	// cost, power and hp are NULL when the catalog lists none for the card or
+	// its attributes have not been synced yet; see SetCardAttributes.
+	for _, column := range []string{"cost", "power", "hp"} {
+		if err := database.addColumnIfNotExists("cards", column, "INTEGER"); err != nil {
+			return fmt.Errorf("add %s column: %w", column, err)
+		}
+	}
+
+	if err := database.addColumnIfNotExists("cards", "traits", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("add traits column: %w", err)
+	}
+
+	if err := database.addColumnIfNotExists("cards", "rules_text", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("add rules_text column: %w", err)
+	}
+
+	if err := database.addColumnIfNotExists("cards", "priority", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("add priority column: %w", err)
+	}
+
+	createSyncPeersTable := `
+		CREATE TABLE IF NOT EXISTS sync_peers (
+			peer          TEXT PRIMARY KEY,
+			remote_cursor TEXT,
+			local_cursor  TEXT
+		);
+	`
+
+	if _, err := database.connection.Exec(createSyncPeersTable); err != nil {
+		return fmt.Errorf("create sync_peers table: %w", err)
+	}
+
+	createWishlistCompletionsTable := `
+		CREATE TABLE IF NOT EXISTS wishlist_completions (
+			id           INTEGER PRIMARY KEY AUTOINCREMENT,
+			card_id      INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+			completed_at TEXT    NOT NULL,
+			notified_at  TEXT
+		);
+	`
+
+	if _, err := database.connection.Exec(createWishlistCompletionsTable); err != nil {
+		return fmt.Errorf("create wishlist_completions table: %w", err)
+	}
+
+	createCollectionSnapshotsTables := `
+		CREATE TABLE IF NOT EXISTS collection_snapshots (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			taken_at    TEXT    NOT NULL,
+			total_owned INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS collection_snapshot_counts (
+			snapshot_id INTEGER NOT NULL REFERENCES collection_snapshots(id) ON DELETE CASCADE,
+			card_name   TEXT    NOT NULL,
+			owned       INTEGER NOT NULL,
+			PRIMARY KEY (snapshot_id, card_name)
+		);
+	`
+
+	if _, err := database.connection.Exec(createCollectionSnapshotsTables); err != nil {
+		return fmt.Errorf("create collection snapshot tables: %w", err)
+	}
+
+	createSettingsTable := `
+		CREATE TABLE IF NOT EXISTS settings (
+			key   TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);
+	`
+
+	if _, err := database.connection.Exec(createSettingsTable); err != nil {
+		return fmt.Errorf("create settings table: %w", err)
+	}
+
+	// The mainboard and non-mainboard minimums were replaced by one minimum
+	// per card type.
+	if _, err := database.connection.Exec(
+		"DELETE FROM settings WHERE key IN ('mainboard_minimum_owned', 'non_mainboard_minimum_owned')",
+	); err != nil {
+		return fmt.Errorf("drop mainboard minimum settings: %w", err)
+	}
+
+	createAPITokensTable := `
+		CREATE TABLE IF NOT EXISTS api_tokens (
+			id           INTEGER PRIMARY KEY AUTOINCREMENT,
+			name         TEXT    NOT NULL,
+			token_hash   TEXT    NOT NULL UNIQUE,
+			scopes       TEXT    NOT NULL,
+			created_at   TEXT    NOT NULL,
+			last_used_at TEXT,
+			revoked_at   TEXT
+		);
+	`
+
+	if _, err := database.connection.Exec(createAPITokensTable); err != nil {
+		return fmt.Errorf("create api_tokens table: %w", err)
+	}
+
+	// Tokens created before roles existed keep the access they had.
+	if err := database.addColumnIfNotExists("api_tokens", "role", "TEXT NOT NULL DEFAULT 'admin'"); err != nil {
+		return fmt.Errorf("add role column to api_tokens: %w", err)
+	}
+
+	createInventoryItemsTable := `
+		CREATE TABLE IF NOT EXISTS inventory_items (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			name       TEXT    NOT NULL,
+			kind       TEXT    NOT NULL,
+			quantity   INTEGER NOT NULL DEFAULT 0,
+			notes      TEXT    NOT NULL DEFAULT '',
+			created_at TEXT    NOT NULL,
+			updated_at TEXT    NOT NULL
+		);
+	`
+
+	if _, err := database.connection.Exec(createInventoryItemsTable); err != nil {
+		return fmt.Errorf("create inventory_items table: %w", err)
+	}
+
+	createEventsTable := `
+		CREATE TABLE IF NOT EXISTS events (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			name       TEXT    NOT NULL,
+			played_on  TEXT    NOT NULL,
+			deck       TEXT    NOT NULL,
+			wins       INTEGER NOT NULL DEFAULT 0,
+			losses     INTEGER NOT NULL DEFAULT 0,
+			draws      INTEGER NOT NULL DEFAULT 0,
+			notes      TEXT    NOT NULL DEFAULT '',
+			created_at TEXT    NOT NULL
+		);
+	`
+
+	if _, err := database.connection.Exec(createEventsTable); err != nil {
+		return fmt.Errorf("create events table: %w", err)
+	}
+
+	createCubesTable := `
+		CREATE TABLE IF NOT EXISTS cubes (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			name       TEXT    NOT NULL,
+			created_at TEXT    NOT NULL
+		);
+	`
+
+	if _, err := database.connection.Exec(createCubesTable); err != nil {
+		return fmt.Errorf("create cubes table: %w", err)
+	}
+
+	createCubeCardsTable := `
+		CREATE TABLE IF NOT EXISTS cube_cards (
+			cube_id INTEGER NOT NULL REFERENCES cubes(id) ON DELETE CASCADE,
+			card_id INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+			count   INTEGER NOT NULL,
+			PRIMARY KEY (cube_id, card_id)
+		);
+	`
+
+	if _, err := database.connection.Exec(createCubeCardsTable); err != nil {
+		return fmt.Errorf("create cube_cards table: %w", err)
+	}
+
+	createSavedSearchesTable := `
+		CREATE TABLE IF NOT EXISTS saved_searches (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			name       TEXT    NOT NULL,
+			query      TEXT    NOT NULL DEFAULT '',
+			pinned     INTEGER NOT NULL DEFAULT 0,
+			created_at TEXT    NOT NULL
+		);
+	`
+
+	if _, err := database.connection.Exec(createSavedSearchesTable); err != nil {
+		return fmt.Errorf("create saved_searches table: %w", err)
+	}
+
+	// cart_items holds each browser session's shopping cart. Carts belong to
+	// a browser rather than the collection, so restoring a backup leaves them
+	// alone.
+	createCartItemsTable := `
+		CREATE TABLE IF NOT EXISTS cart_items (
+			session  TEXT    NOT NULL,
+			card_id  INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+			quantity INTEGER NOT NULL,
+			added_at TEXT    NOT NULL,
+			PRIMARY KEY (session, card_id)
+		);
+	`
+
+	if _, err := database.connection.Exec(createCartItemsTable); err != nil {
+		return fmt.Errorf("create cart_items table: %w", err)
+	}
+
+	// valuations holds one collection valuation per month.
+	createValuationsTable := `
+		CREATE TABLE IF NOT EXISTS valuations (
+			id             INTEGER PRIMARY KEY AUTOINCREMENT,
+			month          TEXT    NOT NULL UNIQUE,
+			valued_at      TEXT    NOT NULL,
+			total_cents    INTEGER NOT NULL,
+			priced_cards   INTEGER NOT NULL,
+			unpriced_cards INTEGER NOT NULL
+		);
+	`
+
+	if _, err := database.connection.Exec(createValuationsTable); err != nil {
+		return fmt.Errorf("create valuations table: %w", err)
+	}
+
+	// buylist_prices caches the prices vendors pay for cards, and
+	// buylist_refreshes when each vendor's buylist was last fetched. They are
+	// refetched on demand, so restoring a backup leaves them alone.
+	createBuylistTables := `
+		CREATE TABLE IF NOT EXISTS buylist_prices (
+			vendor      TEXT    NOT NULL,
+			name        TEXT    NOT NULL,
+			set_code    TEXT    NOT NULL DEFAULT '',
+			card_number TEXT    NOT NULL DEFAULT '',
+			price_cents INTEGER NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS buylist_prices_vendor ON buylist_prices (vendor);
+		CREATE TABLE IF NOT EXISTS buylist_refreshes (
+			vendor     TEXT PRIMARY KEY,
+			fetched_at TEXT NOT NULL
+		);
+	`
+
+	if _, err := database.connection.Exec(createBuylistTables); err != nil {
+		return fmt.Errorf("create buylist tables: %w", err)
+	}
+
+	// digests records when each collection digest was sent. Like imports it
+	// is an operational log, so restoring a backup leaves it alone.
+	createDigestsTable := `
+		CREATE TABLE IF NOT EXISTS digests (
+			id      INTEGER PRIMARY KEY AUTOINCREMENT,
+			channel TEXT    NOT NULL,
+			sent_at TEXT    NOT NULL
+		);
+	`
+
+	if _, err := database.connection.Exec(createDigestsTable); err != nil {
+		return fmt.Errorf("create digests table: %w", err)
+	}
+
+	createGoalsTable := `
+		CREATE TABLE IF NOT EXISTS goals (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			name       TEXT    NOT NULL,
+			query      TEXT    NOT NULL DEFAULT '',
+			target     INTEGER NOT NULL,
+			created_at TEXT    NOT NULL
+		);
+	`
+
+	if _, err := database.connection.Exec(createGoalsTable); err != nil {
+		return fmt.Errorf("create goals table: %w", err)
+	}
+
+	// imports is the import history. It is an operational log rather than
+	// part of the collection, so restoring a backup leaves it alone.
+	createImportsTable := `
+		CREATE TABLE IF NOT EXISTS imports (
+			id                INTEGER PRIMARY KEY AUTOINCREMENT,
+			source            TEXT    NOT NULL,
+			file_name         TEXT    NOT NULL DEFAULT '',
+			mode              TEXT    NOT NULL,
+			inserted          INTEGER NOT NULL DEFAULT 0,
+			skipped_existing  INTEGER NOT NULL DEFAULT 0,
+			skipped_duplicate INTEGER NOT NULL DEFAULT 0,
+			image_failures    INTEGER NOT NULL DEFAULT 0,
+			row_errors        INTEGER NOT NULL DEFAULT 0,
+			changed           INTEGER NOT NULL DEFAULT 0,
+			error             TEXT    NOT NULL DEFAULT '',
+			started_at        TEXT    NOT NULL,
+			finished_at       TEXT    NOT NULL
+		);
+	`
+
+	if _, err := database.connection.Exec(createImportsTable); err != nil {
+		return fmt.Errorf("create imports table: %w", err)
+	}
+
+	// file holds the imported CSV when the import_keep_files setting is on,
+	// and is NULL otherwise.
+	if err := database.addColumnIfNotExists("imports", "file", "BLOB"); err != nil {
+		return fmt.Errorf("add file column: %w", err)
+	}
+
+	// audit_events is the log of changes to the collection. Like imports it
+	// is an operational log, so restoring a backup leaves it alone, and it
+	// keeps card names so that events outlive the cards they name.
+	createAuditEventsTable := `
+		CREATE TABLE IF NOT EXISTS audit_events (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			occurred_at TEXT    NOT NULL,
+			user_name   TEXT    NOT NULL DEFAULT '',
+			action      TEXT    NOT NULL,
+			card_id     INTEGER,
+			card_name   TEXT    NOT NULL DEFAULT '',
+			detail      TEXT    NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS audit_events_occurred_at ON audit_events (occurred_at);
+	`
+
+	if _, err := database.connection.Exec(createAuditEventsTable); err != nil {
+		return fmt.Errorf("create audit_events table: %w", err)
+	}
+
+	// card_aspects holds each card's aspects column split into one row per
+	// aspect, kept in step by syncCardAspects, so that aspect filters and
+	// counts use an indexed lookup instead of matching the joined string.
+	createCardAspectsTable := `
+		CREATE TABLE IF NOT EXISTS card_aspects (
+			card_id INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+			aspect  TEXT    NOT NULL,
+			PRIMARY KEY (card_id, aspect)
+		);
+		CREATE INDEX IF NOT EXISTS card_aspects_aspect ON card_aspects (aspect COLLATE NOCASE);
+	`
+
+	if _, err := database.connection.Exec(createCardAspectsTable); err != nil {
+		return fmt.Errorf("create card_aspects table: %w", err)
+	}
+
+	// card_traits does for the traits column what card_aspects does for
+	// aspects, and is kept in step by syncCardTraits.
+	createCardTraitsTable := `
+		CREATE TABLE IF NOT EXISTS card_traits (
+			card_id INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+			trait   TEXT    NOT NULL,
+			PRIMARY KEY (card_id, trait)
+		);
+		CREATE INDEX IF NOT EXISTS card_traits_trait ON card_traits (trait COLLATE NOCASE);
+	`
+
+	if _, err := database.connection.Exec(createCardTraitsTable); err != nil {
+		return fmt.Errorf("create card_traits table: %w", err)
+	}
+
+	// unreleased_sets flags the sets whose cards are a spoiler-season preview;
+	// see MarkSetUnreleased.
+	createUnreleasedSetsTable := `
+		CREATE TABLE IF NOT EXISTS unreleased_sets (
+			set_code     TEXT NOT NULL PRIMARY KEY COLLATE NOCASE,
+			release_date TEXT NOT NULL DEFAULT ''
+		);
+	`
+
+	if _, err := database.connection.Exec(createUnreleasedSetsTable); err != nil {
+		return fmt.Errorf("create unreleased_sets table: %w", err)
+	}
+
+	// jobs holds each scheduled job's schedule and next run time, so that a
+	// run due while the server was down happens when it starts again, and
+	// job_runs the history of their runs.
+	createJobsTables := `
+		CREATE TABLE IF NOT EXISTS jobs (
+			name        TEXT NOT NULL PRIMARY KEY,
+			schedule    TEXT NOT NULL,
+			next_run_at TEXT
+		);
+		CREATE TABLE IF NOT EXISTS job_runs (
+			id           INTEGER PRIMARY KEY AUTOINCREMENT,
+			job_name     TEXT NOT NULL,
+			triggered_by TEXT NOT NULL,
+			started_at   TEXT NOT NULL,
+			finished_at  TEXT,
+			error        TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS job_runs_job_name ON job_runs (job_name, id);
+	`
+
+	if _, err := database.connection.Exec(createJobsTables); err != nil {
+		return fmt.Errorf("create jobs tables: %w", err)
+	}
+
+	// owned_operations records the owned count changes replayed from devices
+	// that made them offline, so that an operation sent twice is applied once.
+	createOwnedOperationsTable := `
+		CREATE TABLE IF NOT EXISTS owned_operations (
+			id         TEXT    NOT NULL PRIMARY KEY,
+			card_id    INTEGER NOT NULL,
+			action     TEXT    NOT NULL,
+			made_at    TEXT    NOT NULL,
+			applied_at TEXT    NOT NULL
+		);
+	`
+
+	if _, err := database.connection.Exec(createOwnedOperationsTable); err != nil {
+		return fmt.Errorf("create owned_operations table: %w", err)
+	}
+
+	if err := deleteOrphans(database.connection); err != nil {
+		return err
+	}
+
+	var hasCardAspects bool
+	if err := database.connection.QueryRow("SELECT EXISTS (SELECT 1 FROM card_aspects)").Scan(&hasCardAspects); err != nil {
+		return fmt.Errorf("check card_aspects table: %w", err)
+	}
+	if !hasCardAspects {
+		if err := rebuildCardAspects(database.connection); err != nil {
+			return fmt.Errorf("backfill card_aspects table: %w", err)
+		}
+	}
+
+	settings, err := database.GetSettings()
+	if err != nil {
+		return fmt.Errorf("load settings: %w", err)
+	}
+	database.cacheSettings(settings)
+
+	return nil
+}
+
+// cascadingReferences are the columns declared REFERENCES ... ON DELETE
+// CASCADE. Foreign keys were not enforced before, so deleteOrphans removes
+// the rows whose parent is already gone, as the cascade would have.
+var cascadingReferences = []struct{ table, column, parent string }{
+	{"card_aliases", "card_id", "cards"},
+	{"card_translations", "card_id", "cards"},
+	{"wishlist_completions", "card_id", "cards"},
+	{"collection_snapshot_counts", "snapshot_id", "collection_snapshots"},
+	{"cube_cards", "cube_id", "cubes"},
+	{"cube_cards", "card_id", "cards"},
+	{"cart_items", "card_id", "cards"},
+	{"card_aspects", "card_id", "cards"},
+	{"card_traits", "card_id", "cards"},
+}
+
+// deleteOrphans deletes the rows of cascadingReferences whose parent row does
+// not exist.
+func deleteOrphans(executor execer) error {
+	for _, reference := range cascadingReferences {
+		if _, err := executor.Exec(fmt.Sprintf(
+			"DELETE FROM %s WHERE %s NOT IN (SELECT id FROM %s)", reference.table, reference.column, reference.parent,
+		)); err != nil {
+			return fmt.Errorf("delete orphaned %s rows: %w", reference.table, err)
+		}
+	}
+
 	return nil
 }
 
@@ -131,10 +741,106 @@ func (database *Database) addColumnIfNotExists(tableName, columnName, columnDefi
 	return nil
 }
 
-// Connection returns the underlying *sql.DB so that other packages can
+// currentTimestamp returns the current UTC time formatted with timestampLayout.
+func currentTimestamp() string {
+	return time.Now().UTC().Format(timestampLayout)
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanCard scans a single row selected with cardColumns into a Card. NULL
+// images and timestamps are returned as their zero values, and NULL gameplay
+// attributes as nil. PlaysetComplete is computed from the settings in use.
+func (database *Database) scanCard(scanner rowScanner) (models.Card, error) {
+	var card models.Card
+	var image, thumbnail, backImage, createdAt, updatedAt sql.NullString
+	var imageFailedInt, mainboardInt, archivedInt, unreleasedInt int
+	var cost, power, hp sql.NullInt64
+
+	if err := scanner.Scan(
+		&card.ID, &card.Name, &image, &thumbnail, &card.ImageSource, &backImage, &imageFailedInt, &card.Owned, &mainboardInt, &archivedInt, &card.Priority,
+		&card.Set, &card.Number, &card.Type, &card.Aspects, &card.Rarity,
+		&cost, &power, &hp, &card.Traits, &card.Text,
+		&createdAt, &updatedAt, &unreleasedInt,
+	); err != nil {
+		return models.Card{}, err
+	}
+
+	if image.Valid {
+		card.Image = image.String
+	}
+
+	if thumbnail.Valid {
+		card.Thumbnail = thumbnail.String
+	}
+
+	if backImage.Valid {
+		card.BackImage = backImage.String
+	}
+
+	card.Cost = optionalInt(cost)
+	card.Power = optionalInt(power)
+	card.HP = optionalInt(hp)
+
+	card.ImageFailed = imageFailedInt != 0
+	card.Mainboard = mainboardInt != 0
+	card.Archived = archivedInt != 0
+	card.Unreleased = unreleasedInt != 0
+	card.PlaysetComplete = card.Owned >= MinimumOwned(database.Settings(), card.Type)
+
+	if createdAt.Valid {
+		parsed, err := time.Parse(timestampLayout, createdAt.String)
+		if err != nil {
+			return models.Card{}, fmt.Errorf("parse created_at: %w", err)
+		}
+		card.CreatedAt = parsed
+	}
+
+	if updatedAt.Valid {
+		parsed, err := time.Parse(timestampLayout, updatedAt.String)
+		if err != nil {
+			return models.Card{}, fmt.Errorf("parse updated_at: %w", err)
+		}
+		card.UpdatedAt = parsed
+	}
+
+	return card, nil
+}
+
+// queryCards runs query with args and scans every returned row into a Card.
+// The query must select cardColumns. Returns an empty slice (never nil) when
+// no rows match.
+func (database *Database) queryCards(query string, args ...any) ([]models.Card, error) {
+	rows, err := database.connection.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.Card{}
+
+	for rows.Next() {
+		card, err := database.scanCard(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		result = append(result, card)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// Connection returns the underlying write *sql.DB so that other packages can
 // execute queries against the database.
 func (database *Database) Connection() *sql.DB {
-	return database.connection
+	return database.connection.DB
 }
 
 // CardExistsByName returns true if a card with the given name already exists
@@ -156,52 +862,228 @@ func (database *Database) CardExistsByName(name string) (bool, error) {
 	return count > 0, nil
 }
 
-// InsertCard inserts a new card with the given name, optional image path, and
-// mainboard flag into the cards table. The owned field is always set to 0 on
-// insert. If imagePath is empty, the image column is set to NULL. Returns an
-// error if the name is empty or the insert fails.
-func (database *Database) InsertCard(name, imagePath string, mainboard bool) error {
-	if name == "" {
-		return errors.New("card name must not be empty")
+// GetExistingCardNames reports which of names already belong to a card. The
+// returned map only contains the names that exist. Names are matched
+// exactly, as in CardExistsByName.
+func (database *Database) GetExistingCardNames(names []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(names) == 0 {
+		return existing, nil
 	}
 
-	var image sql.NullString
-	if imagePath != "" {
-		image = sql.NullString{String: imagePath, Valid: true}
+	placeholders := strings.Repeat("?, ", len(names)-1) + "?"
+	args := make([]any, len(names))
+	for i, name := range names {
+		args[i] = name
 	}
 
-	mainboardInt := 0
-	if mainboard {
-		mainboardInt = 1
+	rows, err := database.connection.Query("SELECT name FROM cards WHERE name IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("get existing card names: %w", err)
 	}
+	defer rows.Close()
 
-	_, err := database.connection.Exec(
-		"INSERT INTO cards (name, image, owned, mainboard) VALUES (?, ?, 0, ?)",
-		name, image, mainboardInt,
-	)
-	if err != nil {
-		return fmt.Errorf("insert card: %w", err)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("get existing card names: scan: %w", err)
+		}
+		existing[name] = true
 	}
 
-	return nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get existing card names: rows: %w", err)
+	}
+
+	return existing, nil
 }
 
-// GetCardByID retrieves the card with the given id from the cards table.
-// Returns ErrCardNotFound if no card with that id exists.
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// InsertCard inserts card into the cards table with card.Owned copies and
+// both timestamps set to the current time. If card.Image or card.Thumbnail is
+// empty, that column is set to NULL. Returns an error if the name is empty,
+// the owned count is negative or the insert fails.
+func (database *Database) InsertCard(card models.NewCard) error {
+	if err := insertCard(database.connection, card); err != nil {
+		return fmt.Errorf("insert card: %w", err)
+	}
+
+	return nil
+}
+
+// maxInsertParameters bounds the host parameters of one multi-row INSERT,
+// keeping under the 999 that older SQLite builds allow.
+const maxInsertParameters = 999
+
+// insertCardColumns are the columns a card insert sets, and
+// insertCardPlaceholders their values: a parameter for each.
+const (
+	insertCardColumns      = "name, image, thumbnail, image_source, back_image, image_failed, owned, mainboard, set_code, card_number, card_type, aspects, rarity, created_at, updated_at"
+	insertCardPlaceholders = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	insertCardParameters   = 15
+)
+
+// InsertCards inserts every card as InsertCard does, in a single transaction:
+// either all cards are inserted or none are. Cards are inserted with one
+// multi-row INSERT per chunk of as many cards as maxInsertParameters allows.
+func (database *Database) InsertCards(cards []models.NewCard) error {
+	for _, card := range cards {
+		if card.Name == "" {
+			return errors.New("insert cards: card name must not be empty")
+		}
+		if card.Owned < 0 {
+			return fmt.Errorf("insert cards: owned count for %q must not be negative", card.Name)
+		}
+	}
+
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return fmt.Errorf("insert cards: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	now := currentTimestamp()
+	chunkSize := maxInsertParameters / insertCardParameters
+	for chunk := range slices.Chunk(cards, chunkSize) {
+		placeholders := make([]string, len(chunk))
+		args := make([]any, 0, len(chunk)*insertCardParameters)
+		for i, card := range chunk {
+			placeholders[i] = insertCardPlaceholders
+			args = append(args, newCardValues(card, now)...)
+		}
+
+		result, err := transaction.Exec("INSERT INTO cards ("+insertCardColumns+") VALUES "+strings.Join(placeholders, ", "), args...)
+		if err != nil {
+			return fmt.Errorf("insert cards: %w", err)
+		}
+
+		// The rows of one INSERT get consecutive ids ending at the last
+		// insert id, since the transaction holds the write lock.
+		lastID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("insert cards: last insert id: %w", err)
+		}
+
+		if err := syncCardAspects(transaction, "id BETWEEN ? AND ?", lastID-int64(len(chunk))+1, lastID); err != nil {
+			return fmt.Errorf("insert cards: %w", err)
+		}
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("insert cards: commit: %w", err)
+	}
+
+	return nil
+}
+
+// insertCard implements InsertCard against executor.
+func insertCard(executor queryExecer, card models.NewCard) error {
+	if card.Name == "" {
+		return errors.New("card name must not be empty")
+	}
+	if card.Owned < 0 {
+		return errors.New("owned count must not be negative")
+	}
+
+	result, err := executor.Exec(
+		"INSERT INTO cards ("+insertCardColumns+") VALUES "+insertCardPlaceholders,
+		newCardValues(card, currentTimestamp())...,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	return syncCardAspects(executor, "id = ?", id)
+}
+
+// newCardValues returns the insertCardParameters values inserting card
+// binds, with now as its created and updated time.
+func newCardValues(card models.NewCard, now string) []any {
+	var image sql.NullString
+	if card.Image != "" {
+		image = sql.NullString{String: card.Image, Valid: true}
+	}
+
+	mainboardInt := 0
+	if card.Mainboard {
+		mainboardInt = 1
+	}
+
+	var thumbnail sql.NullString
+	if card.Thumbnail != "" {
+		thumbnail = sql.NullString{String: card.Thumbnail, Valid: true}
+	}
+
+	var backImage sql.NullString
+	if card.BackImage != "" {
+		backImage = sql.NullString{String: card.BackImage, Valid: true}
+	}
+
+	imageFailedInt := 0
+	if card.ImageFailed {
+		imageFailedInt = 1
+	}
+
+	return []any{card.Name, image, thumbnail, card.ImageSource, backImage, imageFailedInt, card.Owned, mainboardInt, card.Set, card.Number, card.Type, card.Aspects, card.Rarity, now, now}
+}
+
+// FillMissingCardDetails copies the set, number, type, aspects and rarity
+// from card onto the existing card with the same name, but only when that
+// card has no set recorded yet. This backfills metadata for cards imported
+// before these columns existed without overwriting anything already stored.
+// Returns an error if the name is empty or the update fails.
+func (database *Database) FillMissingCardDetails(card models.NewCard) error {
+	if card.Name == "" {
+		return errors.New("card name must not be empty")
+	}
+
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return fmt.Errorf("fill missing card details: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	_, err = transaction.Exec(
+		`UPDATE cards SET set_code = ?, card_number = ?, card_type = ?, aspects = ?, rarity = ?
+		 WHERE name = ? AND set_code = ''`,
+		card.Set, card.Number, card.Type, card.Aspects, card.Rarity, card.Name,
+	)
+	if err != nil {
+		return fmt.Errorf("fill missing card details: %w", err)
+	}
+
+	if err := syncCardAspects(transaction, "name = ?", card.Name); err != nil {
+		return fmt.Errorf("fill missing card details: %w", err)
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("fill missing card details: commit: %w", err)
+	}
+
+	return nil
+}
+
+// GetCardByID retrieves the card with the given id from the cards table.
+// Returns ErrCardNotFound if no card with that id exists.
 // Returns an error if id is not a positive integer or the query fails.
 func (database *Database) GetCardByID(id int) (*models.Card, error) {
 	if id <= 0 {
 		return nil, errors.New("card id must be a positive integer")
 	}
 
-	var card models.Card
-	var image sql.NullString
-	var mainboardInt int
-
-	err := database.connection.QueryRow(
-		"SELECT id, name, image, owned, mainboard FROM cards WHERE id = ?",
+	card, err := database.scanCard(database.connection.QueryRow(
+		"SELECT "+cardColumns+" FROM cards WHERE id = ?",
 		id,
-	).Scan(&card.ID, &card.Name, &image, &card.Owned, &mainboardInt)
+	))
 
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrCardNotFound
@@ -210,26 +1092,35 @@ func (database *Database) GetCardByID(id int) (*models.Card, error) {
 		return nil, fmt.Errorf("get card by id: %w", err)
 	}
 
-	if image.Valid {
-		card.Image = image.String
-	}
-
-	card.Mainboard = mainboardInt != 0
-
 	return &card, nil
 }
 
+// minimumOwnedExpression evaluates to a card's minimum owned threshold, the
+// one for its type. It takes the minimums returned by minimumOwnedArgs as
+// arguments.
+const minimumOwnedExpression = "CASE LOWER(card_type) WHEN 'leader' THEN ? WHEN 'base' THEN ? WHEN 'unit' THEN ? " +
+	"WHEN 'event' THEN ? WHEN 'upgrade' THEN ? WHEN 'token' THEN ? ELSE ? END"
+
 // IncrementCardOwned increments the owned count by 1 for the card with the
-// given id. Returns ErrCardNotFound if no card with that id exists.
+// given id and records the change in updated_at. If the increment brings a
+// non-archived card up to its minimum owned threshold, a wishlist completion
+// is recorded. Returns ErrCardNotFound if no card with that id exists.
 // Returns an error if id is not a positive integer or the update fails.
 func (database *Database) IncrementCardOwned(id int) error {
 	if id <= 0 {
 		return errors.New("card id must be a positive integer")
 	}
 
-	result, err := database.connection.Exec(
-		"UPDATE cards SET owned = owned + 1 WHERE id = ?",
-		id,
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return fmt.Errorf("increment card owned: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	now := currentTimestamp()
+	result, err := transaction.Exec(
+		"UPDATE cards SET owned = owned + 1, updated_at = ? WHERE id = ?",
+		now, id,
 	)
 	if err != nil {
 		return fmt.Errorf("increment card owned: %w", err)
@@ -244,11 +1135,34 @@ func (database *Database) IncrementCardOwned(id int) error {
 		return ErrCardNotFound
 	}
 
+	if err := database.recordCompletion(transaction, id, now); err != nil {
+		return fmt.Errorf("increment card owned: %w", err)
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("increment card owned: commit: %w", err)
+	}
+
+	return nil
+}
+
+// recordCompletion records a wishlist completion for the card with id, at
+// now, if it is not archived and has just reached its minimum owned
+// threshold with an increment made in transaction.
+func (database *Database) recordCompletion(transaction *instrumentedTx, id int, now string) error {
+	if _, err := transaction.Exec(
+		"INSERT INTO wishlist_completions (card_id, completed_at) SELECT id, ? FROM cards WHERE id = ? AND archived = 0 AND owned = "+minimumOwnedExpression,
+		append([]any{now, id}, database.minimumOwnedArgs()...)...,
+	); err != nil {
+		return fmt.Errorf("record completion: %w", err)
+	}
+
 	return nil
 }
 
 // DecrementCardOwned decrements the owned count by 1 for the card with the
-// given id, clamping at 0 so it never goes negative. Returns ErrCardNotFound
+// given id, clamping at 0 so it never goes negative. updated_at is only
+// touched when the count actually changes. Returns ErrCardNotFound
 // if no card with that id exists. Returns an error if id is not a positive
 // integer or the update fails.
 func (database *Database) DecrementCardOwned(id int) error {
@@ -257,8 +1171,8 @@ func (database *Database) DecrementCardOwned(id int) error {
 	}
 
 	result, err := database.connection.Exec(
-		"UPDATE cards SET owned = MAX(owned - 1, 0) WHERE id = ?",
-		id,
+		"UPDATE cards SET updated_at = CASE WHEN owned > 0 THEN ? ELSE updated_at END, owned = MAX(owned - 1, 0) WHERE id = ?",
+		currentTimestamp(), id,
 	)
 	if err != nil {
 		return fmt.Errorf("decrement card owned: %w", err)
@@ -276,119 +1190,1971 @@ func (database *Database) DecrementCardOwned(id int) error {
 	return nil
 }
 
-// SearchCards returns all cards whose name contains query as a substring,
-// matched case-insensitively. If query is empty, all cards are returned.
-// Returns an empty slice (never nil) when no cards match.
+// SearchCards returns all non-archived cards matching query, in the syntax of
+// the search package; a plain word matches cards whose name, any alias, any
+// localized name or rules text contains it, case-insensitively. If query is
+// empty, all non-archived cards are returned. Returns an empty slice (never
+// nil) when no cards match, and an error wrapping search.ErrInvalidQuery when
+// query cannot be parsed.
 func (database *Database) SearchCards(query string) ([]models.Card, error) {
-	var (
-		rows *sql.Rows
-		err  error
-	)
+	result, err := database.searchCards(query, "")
+	if err != nil {
+		return nil, fmt.Errorf("search cards: %w", err)
+	}
 
-	if query == "" {
-		rows, err = database.connection.Query(
-			"SELECT id, name, image, owned, mainboard FROM cards",
-		)
-	} else {
-		rows, err = database.connection.Query(
-			"SELECT id, name, image, owned, mainboard FROM cards WHERE name LIKE ? COLLATE NOCASE",
-			"%"+query+"%",
-		)
+	return result, nil
+}
+
+// CardSort selects the order SearchCardsSorted returns cards in.
+type CardSort string
+
+const (
+	// SortByName orders cards alphabetically by name.
+	SortByName CardSort = "name"
+
+	// SortByOwned orders cards by owned count, most owned first.
+	SortByOwned CardSort = "owned"
+
+	// SortByRecent orders cards by when they were last changed, most recent
+	// first.
+	SortByRecent CardSort = "recent"
+
+	// SortBySetNumber orders cards by set code, then collector number, the
+	// order of a physical binder. Plain integer numbers sort numerically
+	// before other numbers; cards without a set come last.
+	SortBySetNumber CardSort = "set_number"
+)
+
+// cardSortOrders maps each CardSort to its ORDER BY clause.
+var cardSortOrders = map[CardSort]string{
+	SortByName:      "name COLLATE NOCASE, id",
+	SortByOwned:     "owned DESC, name COLLATE NOCASE, id",
+	SortByRecent:    "COALESCE(updated_at, created_at, '') DESC, id DESC",
+	SortBySetNumber: "set_code = '', set_code COLLATE NOCASE, card_number = '' OR card_number GLOB '*[^0-9]*', CAST(card_number AS INTEGER), card_number, name COLLATE NOCASE, id",
+}
+
+// SearchCardsSorted returns the cards SearchCards would return for query,
+// ordered by sort. Returns an error for an unknown sort.
+func (database *Database) SearchCardsSorted(query string, sort CardSort) ([]models.Card, error) {
+	orderBy, ok := cardSortOrders[sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown card sort %q", sort)
 	}
 
+	result, err := database.searchCards(query, orderBy)
 	if err != nil {
-		return nil, fmt.Errorf("search cards: %w", err)
+		return nil, fmt.Errorf("search cards sorted: %w", err)
 	}
-	defer rows.Close()
 
-	result := []models.Card{}
+	return result, nil
+}
 
-	for rows.Next() {
-		var card models.Card
-		var image sql.NullString
-		var mainboardInt int
+// searchCards runs the non-archived card search, filtered by query when it is
+// not empty and followed by orderBy when that is not empty.
+func (database *Database) searchCards(query, orderBy string) ([]models.Card, error) {
+	condition, args, err := database.searchClause(query)
+	if err != nil {
+		return nil, err
+	}
 
-		if err := rows.Scan(&card.ID, &card.Name, &image, &card.Owned, &mainboardInt); err != nil {
-			return nil, fmt.Errorf("search cards: scan: %w", err)
-		}
+	statement := "SELECT " + cardColumns + " FROM cards WHERE archived = 0"
+	if condition != "" {
+		statement += " AND " + condition
+	}
+
+	if orderBy != "" {
+		statement += " ORDER BY " + orderBy
+	}
+
+	return database.queryCards(statement, args...)
+}
+
+// wishlistClause restricts a cards query to non-archived cards below their
+// minimum owned threshold. It takes the minimums returned by minimumOwnedArgs
+// as arguments.
+const wishlistClause = "archived = 0 AND owned < " + minimumOwnedExpression
+
+// GetWishlistCards returns all non-archived cards where the owned count is
+// below the minimum threshold set in Settings for their card type. An optional query, in the syntax of the search package, filters the
+// results. Returns an empty slice (never nil) when no cards are below their
+// threshold or when the query matches none, and an error wrapping
+// search.ErrInvalidQuery when query cannot be parsed.
+func (database *Database) GetWishlistCards(query string) ([]models.Card, error) {
+	result, err := database.queryWishlistCards(query, "")
+	if err != nil {
+		return nil, fmt.Errorf("get wishlist cards: %w", err)
+	}
+
+	return result, nil
+}
+
+// queryWishlistCards runs the wishlist query, filtered by query when it is not
+// empty and followed by orderBy when that is not empty.
+func (database *Database) queryWishlistCards(query, orderBy string) ([]models.Card, error) {
+	condition, conditionArgs, err := database.searchClause(query)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := "SELECT " + cardColumns + " FROM cards WHERE " + wishlistClause
+	args := database.minimumOwnedArgs()
+	if condition != "" {
+		statement += " AND " + condition
+		args = append(args, conditionArgs...)
+	}
+
+	if orderBy != "" {
+		statement += " ORDER BY " + orderBy
+	}
+
+	return database.queryCards(statement, args...)
+}
+
+// excessClause matches non-archived cards owned beyond their minimum owned
+// threshold. It takes the minimums returned by minimumOwnedArgs.
+const excessClause = "archived = 0 AND owned > " + minimumOwnedExpression
+
+// GetExcessCards returns all non-archived cards whose owned count is above
+// the minimum threshold set in Settings for their card type, ordered by name. Returns an empty slice (never nil) when no card has spare
+// copies.
+func (database *Database) GetExcessCards() ([]models.Card, error) {
+	result, err := database.queryCards(
+		"SELECT "+cardColumns+" FROM cards WHERE "+excessClause+" ORDER BY name COLLATE NOCASE, id",
+		database.minimumOwnedArgs()...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get excess cards: %w", err)
+	}
+
+	return result, nil
+}
+
+// WishlistGrouping selects the card detail GetWishlistCardGroups groups by.
+type WishlistGrouping string
+
+const (
+	// GroupBySet groups cards by set code.
+	GroupBySet WishlistGrouping = "set"
+
+	// GroupByAspect groups cards by their aspects. Cards with more than one
+	// aspect form their own group rather than appearing in several.
+	GroupByAspect WishlistGrouping = "aspect"
+
+	// GroupByType groups cards by card type.
+	GroupByType WishlistGrouping = "type"
+)
+
+// wishlistGroupings maps each WishlistGrouping to the column it groups by and
+// the Card field holding that column's value.
+var wishlistGroupings = map[WishlistGrouping]struct {
+	column string
+	key    func(models.Card) string
+}{
+	GroupBySet:    {"set_code", func(card models.Card) string { return card.Set }},
+	GroupByAspect: {"aspects", func(card models.Card) string { return card.Aspects }},
+	GroupByType:   {"card_type", func(card models.Card) string { return card.Type }},
+}
+
+// CardGroup is a set of cards sharing the same value of the grouped detail.
+// Key is empty for cards where that detail is unknown.
+type CardGroup struct {
+	Key   string
+	Cards []models.Card
+}
+
+// GetWishlistCardGroups returns the cards GetWishlistCards would return for
+// query, grouped by the detail selected by grouping. Groups are ordered by key
+// case-insensitively, with the group of cards missing the detail last. Returns
+// an error for an unknown grouping and an empty slice (never nil) when no
+// cards match.
+func (database *Database) GetWishlistCardGroups(query string, grouping WishlistGrouping) ([]CardGroup, error) {
+	selected, ok := wishlistGroupings[grouping]
+	if !ok {
+		return nil, fmt.Errorf("unknown wishlist grouping %q", grouping)
+	}
+
+	cards, err := database.queryWishlistCards(query, selected.column+" = '', "+selected.column+" COLLATE NOCASE, "+selected.column+", id")
+	if err != nil {
+		return nil, fmt.Errorf("get wishlist card groups: %w", err)
+	}
 
-		if image.Valid {
-			card.Image = image.String
+	groups := make([]CardGroup, 0)
+	for _, card := range cards {
+		key := selected.key(card)
+		if len(groups) == 0 || groups[len(groups)-1].Key != key {
+			groups = append(groups, CardGroup{Key: key})
 		}
+		last := &groups[len(groups)-1]
+		last.Cards = append(last.Cards, card)
+	}
 
-		card.Mainboard = mainboardInt != 0
+	return groups, nil
+}
 
-		result = append(result, card)
+// GetArchivedCards returns all archived cards matching query, in the syntax
+// of the search package. If query is empty, all archived cards are returned.
+// Returns an empty slice (never nil) when no cards match, and an error
+// wrapping search.ErrInvalidQuery when query cannot be parsed.
+func (database *Database) GetArchivedCards(query string) ([]models.Card, error) {
+	condition, args, err := database.searchClause(query)
+	if err != nil {
+		return nil, fmt.Errorf("get archived cards: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("search cards: rows: %w", err)
+	statement := "SELECT " + cardColumns + " FROM cards WHERE archived = 1"
+	if condition != "" {
+		statement += " AND " + condition
+	}
+
+	result, err := database.queryCards(statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("get archived cards: %w", err)
 	}
 
 	return result, nil
 }
 
-// GetWishlistCards returns all cards where the owned count is below the minimum
-// threshold: MainboardMinimumOwned for mainboard cards and NonMainboardMinimumOwned
-// for non-mainboard cards. An optional name query filters results by a
-// case-insensitive substring match. Returns an empty slice (never nil) when no
-// cards are below their threshold or when the query matches none.
-func (database *Database) GetWishlistCards(query string) ([]models.Card, error) {
-	var (
-		rows *sql.Rows
-		err  error
+// SetCardArchived sets the archived flag for the card with the given id and
+// records the change in updated_at. Archived cards are hidden from search and
+// the wishlist but keep their owned count so they can be restored later.
+// Returns ErrCardNotFound if no card with that id exists. Returns an error if
+// id is not a positive integer or the update fails.
+func (database *Database) SetCardArchived(id int, archived bool) error {
+	if id <= 0 {
+		return errors.New("card id must be a positive integer")
+	}
+
+	archivedInt := 0
+	if archived {
+		archivedInt = 1
+	}
+
+	result, err := database.connection.Exec(
+		"UPDATE cards SET archived = ?, updated_at = ? WHERE id = ?",
+		archivedInt, currentTimestamp(), id,
 	)
+	if err != nil {
+		return fmt.Errorf("set card archived: %w", err)
+	}
 
-	if query == "" {
-		rows, err = database.connection.Query(
-			"SELECT id, name, image, owned, mainboard FROM cards WHERE (mainboard = 1 AND owned < ?) OR (mainboard = 0 AND owned < ?)",
-			MainboardMinimumOwned,
-			NonMainboardMinimumOwned,
-		)
-	} else {
-		rows, err = database.connection.Query(
-			"SELECT id, name, image, owned, mainboard FROM cards WHERE ((mainboard = 1 AND owned < ?) OR (mainboard = 0 AND owned < ?)) AND name LIKE ? COLLATE NOCASE",
-			MainboardMinimumOwned,
-			NonMainboardMinimumOwned,
-			"%"+query+"%",
-		)
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("set card archived rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrCardNotFound
+	}
+
+	return nil
+}
+
+// SetCardPriority sets the wishlist priority of the card with the given id to
+// one of PriorityLow, PriorityNormal or PriorityHigh and updates updated_at.
+// Returns ErrCardNotFound if no card with that id exists and an error for an
+// unknown priority.
+func (database *Database) SetCardPriority(id, priority int) error {
+	if id <= 0 {
+		return errors.New("card id must be a positive integer")
+	}
+	if priority < PriorityLow || priority > PriorityHigh {
+		return fmt.Errorf("unknown priority %d", priority)
 	}
 
+	result, err := database.connection.Exec(
+		"UPDATE cards SET priority = ?, updated_at = ? WHERE id = ?",
+		priority, currentTimestamp(), id,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("get wishlist cards: %w", err)
+		return fmt.Errorf("set card priority: %w", err)
 	}
-	defer rows.Close()
 
-	result := []models.Card{}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("set card priority: rows affected: %w", err)
+	}
 
-	for rows.Next() {
-		var card models.Card
-		var image sql.NullString
-		var mainboardInt int
+	if affected == 0 {
+		return ErrCardNotFound
+	}
 
-		if err := rows.Scan(&card.ID, &card.Name, &image, &card.Owned, &mainboardInt); err != nil {
-			return nil, fmt.Errorf("get wishlist cards: scan: %w", err)
-		}
+	return nil
+}
+
+// BulkAction is a change BulkUpdateCards applies to every matching card.
+type BulkAction string
 
-		if image.Valid {
-			card.Image = image.String
+const (
+	// BulkSetMainboard sets the mainboard flag to the given value.
+	BulkSetMainboard BulkAction = "set_mainboard"
+
+	// BulkArchive archives the cards.
+	BulkArchive BulkAction = "archive"
+)
+
+// BulkUpdateCards applies action to every card SearchCards(query) would
+// return, recording the change in updated_at. mainboard is the flag set by
+// BulkSetMainboard and is ignored by other actions. The cards are changed by
+// a single statement, so either all of them change or none do. Returns the
+// number of cards changed, an error for an unknown action, or an error
+// wrapping search.ErrInvalidQuery when query cannot be parsed.
+func (database *Database) BulkUpdateCards(query string, action BulkAction, mainboard bool) (int, error) {
+	var assignment string
+	var args []any
+	switch action {
+	case BulkSetMainboard:
+		mainboardInt := 0
+		if mainboard {
+			mainboardInt = 1
 		}
+		assignment = "mainboard = ?"
+		args = append(args, mainboardInt)
+	case BulkArchive:
+		assignment = "archived = 1"
+	default:
+		return 0, fmt.Errorf("bulk update cards: unknown action %q", action)
+	}
 
-		card.Mainboard = mainboardInt != 0
+	condition, conditionArgs, err := database.searchClause(query)
+	if err != nil {
+		return 0, fmt.Errorf("bulk update cards: %w", err)
+	}
 
-		result = append(result, card)
+	statement := "UPDATE cards SET " + assignment + ", updated_at = ? WHERE archived = 0"
+	args = append(args, currentTimestamp())
+	if condition != "" {
+		statement += " AND " + condition
+		args = append(args, conditionArgs...)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("get wishlist cards: rows: %w", err)
+	result, err := database.connection.Exec(statement, args...)
+	if err != nil {
+		return 0, fmt.Errorf("bulk update cards: %w", err)
 	}
 
-	return result, nil
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("bulk update cards: rows affected: %w", err)
+	}
+
+	return int(affected), nil
 }
 
-// Shutdown closes the database connection. It should be called when the
-// application is shutting down to release resources cleanly.
-func (database *Database) Shutdown() error {
+// cardExistsByID returns true if a card with the given id exists.
+func (database *Database) cardExistsByID(id int) (bool, error) {
+	var count int
+	err := database.connection.QueryRow(
+		"SELECT COUNT(*) FROM cards WHERE id = ?",
+		id,
+	).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// AddCardAlias records alias as an alternate name for the card with the given
+// id so that searches for the alias find the card. Adding an alias the card
+// already has (compared case-insensitively) is a no-op. Returns
+// ErrCardNotFound if no card with that id exists. Returns an error if id is
+// not a positive integer, the alias is empty, or the insert fails.
+func (database *Database) AddCardAlias(cardID int, alias string) error {
+	if cardID <= 0 {
+		return errors.New("card id must be a positive integer")
+	}
+	if alias == "" {
+		return errors.New("alias must not be empty")
+	}
+
+	exists, err := database.cardExistsByID(cardID)
+	if err != nil {
+		return fmt.Errorf("add card alias: check card exists: %w", err)
+	}
+	if !exists {
+		return ErrCardNotFound
+	}
+
+	_, err = database.connection.Exec(
+		"INSERT OR IGNORE INTO card_aliases (card_id, alias) VALUES (?, ?)",
+		cardID, alias,
+	)
+	if err != nil {
+		return fmt.Errorf("add card alias: %w", err)
+	}
+
+	return nil
+}
+
+// GetCardAliases returns the aliases of the card with the given id in
+// alphabetical order. Returns ErrCardNotFound if no card with that id exists.
+// Returns an empty slice (never nil) when the card has no aliases.
+func (database *Database) GetCardAliases(cardID int) ([]string, error) {
+	if cardID <= 0 {
+		return nil, errors.New("card id must be a positive integer")
+	}
+
+	exists, err := database.cardExistsByID(cardID)
+	if err != nil {
+		return nil, fmt.Errorf("get card aliases: check card exists: %w", err)
+	}
+	if !exists {
+		return nil, ErrCardNotFound
+	}
+
+	rows, err := database.connection.Query(
+		"SELECT alias FROM card_aliases WHERE card_id = ? ORDER BY alias COLLATE NOCASE",
+		cardID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get card aliases: %w", err)
+	}
+	defer rows.Close()
+
+	aliases := []string{}
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, fmt.Errorf("get card aliases: scan: %w", err)
+		}
+		aliases = append(aliases, alias)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get card aliases: rows: %w", err)
+	}
+
+	return aliases, nil
+}
+
+// DeleteCardAlias removes alias (compared case-insensitively) from the card
+// with the given id. Returns ErrAliasNotFound if the card has no such alias.
+// Returns an error if id is not a positive integer, the alias is empty, or the
+// delete fails.
+func (database *Database) DeleteCardAlias(cardID int, alias string) error {
+	if cardID <= 0 {
+		return errors.New("card id must be a positive integer")
+	}
+	if alias == "" {
+		return errors.New("alias must not be empty")
+	}
+
+	result, err := database.connection.Exec(
+		"DELETE FROM card_aliases WHERE card_id = ? AND alias = ? COLLATE NOCASE",
+		cardID, alias,
+	)
+	if err != nil {
+		return fmt.Errorf("delete card alias: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete card alias rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrAliasNotFound
+	}
+
+	return nil
+}
+
+// RandomCardFilter restricts the cards GetRandomCard chooses from. Empty Set
+// and Rarity match any value; both are compared case-insensitively.
+type RandomCardFilter struct {
+	Set       string
+	Rarity    string
+	OwnedOnly bool
+}
+
+// GetRandomCard returns a uniformly random non-archived card matching filter.
+// Returns ErrCardNotFound when no card matches.
+func (database *Database) GetRandomCard(filter RandomCardFilter) (*models.Card, error) {
+	query := "SELECT " + cardColumns + " FROM cards WHERE archived = 0"
+	var args []any
+
+	if filter.Set != "" {
+		query += " AND set_code = ? COLLATE NOCASE"
+		args = append(args, filter.Set)
+	}
+	if filter.Rarity != "" {
+		query += " AND rarity = ? COLLATE NOCASE"
+		args = append(args, filter.Rarity)
+	}
+	if filter.OwnedOnly {
+		query += " AND owned > 0"
+	}
+
+	query += " ORDER BY RANDOM() LIMIT 1"
+
+	card, err := database.scanCard(database.connection.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCardNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get random card: %w", err)
+	}
+
+	return &card, nil
+}
+
+// GetCardsBySet returns all non-archived cards in the given set, compared
+// case-insensitively. If set is empty, all non-archived cards are returned.
+// Returns an empty slice (never nil) when no cards match.
+func (database *Database) GetCardsBySet(set string) ([]models.Card, error) {
+	var (
+		result []models.Card
+		err    error
+	)
+
+	if set == "" {
+		result, err = database.queryCards(
+			"SELECT " + cardColumns + " FROM cards WHERE archived = 0",
+		)
+	} else {
+		result, err = database.queryCards(
+			"SELECT "+cardColumns+" FROM cards WHERE archived = 0 AND set_code = ? COLLATE NOCASE",
+			set,
+		)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get cards by set: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetSetCodes returns the distinct set codes of non-archived cards in
+// alphabetical order, leaving out cards with no set. Returns an empty slice
+// (never nil) when there are none.
+func (database *Database) GetSetCodes() ([]string, error) {
+	rows, err := database.connection.Query("SELECT DISTINCT set_code FROM cards WHERE archived = 0 AND set_code != '' ORDER BY set_code")
+	if err != nil {
+		return nil, fmt.Errorf("get set codes: %w", err)
+	}
+	defer rows.Close()
+
+	sets := make([]string, 0)
+	for rows.Next() {
+		var set string
+		if err := rows.Scan(&set); err != nil {
+			return nil, fmt.Errorf("get set codes: scan: %w", err)
+		}
+		sets = append(sets, set)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get set codes: rows: %w", err)
+	}
+
+	return sets, nil
+}
+
+// GetAllCards returns every card, archived or not, ordered by name. Returns
+// an empty slice (never nil) when the table is empty.
+func (database *Database) GetAllCards() ([]models.Card, error) {
+	result, err := database.queryCards("SELECT " + cardColumns + " FROM cards ORDER BY name COLLATE NOCASE")
+	if err != nil {
+		return nil, fmt.Errorf("get all cards: %w", err)
+	}
+
+	return result, nil
+}
+
+// SetCardImage sets the image path of the card with the given id and clears
+// its image_failed flag. An empty image clears the path to NULL. The change is
+// not recorded in updated_at because image files are local to each instance.
+// Returns ErrCardNotFound if no card with that id exists.
+func (database *Database) SetCardImage(id int, image string) error {
+	if id <= 0 {
+		return errors.New("card id must be a positive integer")
+	}
+
+	var imageValue sql.NullString
+	if image != "" {
+		imageValue = sql.NullString{String: image, Valid: true}
+	}
+
+	result, err := database.connection.Exec("UPDATE cards SET image = ?, image_failed = 0 WHERE id = ?", imageValue, id)
+	if err != nil {
+		return fmt.Errorf("set card image: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("set card image: rows affected: %w", err)
+	}
+
+	if affected == 0 {
+		return ErrCardNotFound
+	}
+
+	return nil
+}
+
+// SetCardThumbnail sets the thumbnail path of the card with the given id. An
+// empty thumbnail clears it to NULL. Like SetCardImage, the change is not
+// recorded in updated_at. Returns ErrCardNotFound if no card with that id
+// exists.
+func (database *Database) SetCardThumbnail(id int, thumbnail string) error {
+	if id <= 0 {
+		return errors.New("card id must be a positive integer")
+	}
+
+	var thumbnailValue sql.NullString
+	if thumbnail != "" {
+		thumbnailValue = sql.NullString{String: thumbnail, Valid: true}
+	}
+
+	result, err := database.connection.Exec("UPDATE cards SET thumbnail = ? WHERE id = ?", thumbnailValue, id)
+	if err != nil {
+		return fmt.Errorf("set card thumbnail: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("set card thumbnail: rows affected: %w", err)
+	}
+
+	if affected == 0 {
+		return ErrCardNotFound
+	}
+
+	return nil
+}
+
+// SetCardBackImage sets the back face image path of the card with the given
+// id. An empty backImage clears it to NULL. Like SetCardImage, the change is
+// not recorded in updated_at. Returns ErrCardNotFound if no card with that id
+// exists.
+func (database *Database) SetCardBackImage(id int, backImage string) error {
+	if id <= 0 {
+		return errors.New("card id must be a positive integer")
+	}
+
+	var backImageValue sql.NullString
+	if backImage != "" {
+		backImageValue = sql.NullString{String: backImage, Valid: true}
+	}
+
+	result, err := database.connection.Exec("UPDATE cards SET back_image = ? WHERE id = ?", backImageValue, id)
+	if err != nil {
+		return fmt.Errorf("set card back image: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("set card back image: rows affected: %w", err)
+	}
+
+	if affected == 0 {
+		return ErrCardNotFound
+	}
+
+	return nil
+}
+
+// SetCardImageSource records source as the image source the image of the card
+// with the given id was downloaded from. Like SetCardImage, the change is not
+// recorded in updated_at. Returns ErrCardNotFound if no card with that id
+// exists.
+func (database *Database) SetCardImageSource(id int, source string) error {
+	if id <= 0 {
+		return errors.New("card id must be a positive integer")
+	}
+
+	result, err := database.connection.Exec("UPDATE cards SET image_source = ? WHERE id = ?", source, id)
+	if err != nil {
+		return fmt.Errorf("set card image source: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("set card image source: rows affected: %w", err)
+	}
+
+	if affected == 0 {
+		return ErrCardNotFound
+	}
+
+	return nil
+}
+
+// MarkCardImageFailed flags the card with the given id as having an image
+// that could not be downloaded, so that a later retry picks it up. Returns
+// ErrCardNotFound if no card with that id exists.
+func (database *Database) MarkCardImageFailed(id int) error {
+	if id <= 0 {
+		return errors.New("card id must be a positive integer")
+	}
+
+	result, err := database.connection.Exec("UPDATE cards SET image_failed = 1 WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("mark card image failed: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("mark card image failed: rows affected: %w", err)
+	}
+
+	if affected == 0 {
+		return ErrCardNotFound
+	}
+
+	return nil
+}
+
+// GetImageFailedCards returns every card flagged with image_failed, ordered
+// by id. Returns an empty slice (never nil) when no downloads are pending a
+// retry.
+func (database *Database) GetImageFailedCards() ([]models.Card, error) {
+	result, err := database.queryCards("SELECT " + cardColumns + " FROM cards WHERE image_failed = 1 ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("get image failed cards: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetOwnedCountsByName returns the owned count of every card, archived or
+// not, keyed by card name.
+func (database *Database) GetOwnedCountsByName() (map[string]int, error) {
+	rows, err := database.connection.Query("SELECT name, owned FROM cards")
+	if err != nil {
+		return nil, fmt.Errorf("get owned counts by name: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var owned int
+		if err := rows.Scan(&name, &owned); err != nil {
+			return nil, fmt.Errorf("get owned counts by name: scan: %w", err)
+		}
+		counts[name] = owned
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get owned counts by name: rows: %w", err)
+	}
+
+	return counts, nil
+}
+
+// CreateCollectionSnapshot records the current owned count of every card with
+// at least one copy, archived or not, along with the collection's total owned
+// count. Cards of unreleased sets are left out. Counts are keyed by card name
+// so snapshots stay comparable across re-imports and synced instances.
+func (database *Database) CreateCollectionSnapshot() (models.CollectionSnapshot, error) {
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return models.CollectionSnapshot{}, fmt.Errorf("create collection snapshot: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	takenAt := currentTimestamp()
+	result, err := transaction.Exec(
+		"INSERT INTO collection_snapshots (taken_at, total_owned) SELECT ?, COALESCE(SUM(owned), 0) FROM cards WHERE NOT "+unreleasedClause,
+		takenAt,
+	)
+	if err != nil {
+		return models.CollectionSnapshot{}, fmt.Errorf("create collection snapshot: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return models.CollectionSnapshot{}, fmt.Errorf("create collection snapshot: last insert id: %w", err)
+	}
+
+	if _, err := transaction.Exec(
+		"INSERT INTO collection_snapshot_counts (snapshot_id, card_name, owned) SELECT ?, name, owned FROM cards WHERE owned > 0 AND NOT "+unreleasedClause,
+		id,
+	); err != nil {
+		return models.CollectionSnapshot{}, fmt.Errorf("create collection snapshot: counts: %w", err)
+	}
+
+	snapshot, err := scanCollectionSnapshot(transaction.QueryRow(
+		"SELECT "+collectionSnapshotColumns+" FROM collection_snapshots WHERE id = ?", id,
+	))
+	if err != nil {
+		return models.CollectionSnapshot{}, fmt.Errorf("create collection snapshot: read back: %w", err)
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return models.CollectionSnapshot{}, fmt.Errorf("create collection snapshot: commit: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// collectionSnapshotColumns is the column list read by scanCollectionSnapshot.
+const collectionSnapshotColumns = "id, taken_at, total_owned"
+
+// scanCollectionSnapshot reads a row selecting collectionSnapshotColumns.
+func scanCollectionSnapshot(row rowScanner) (models.CollectionSnapshot, error) {
+	var (
+		snapshot models.CollectionSnapshot
+		takenAt  sql.NullString
+	)
+	if err := row.Scan(&snapshot.ID, &takenAt, &snapshot.TotalOwned); err != nil {
+		return models.CollectionSnapshot{}, err
+	}
+
+	parsed, err := parseTimestamp(takenAt)
+	if err != nil {
+		return models.CollectionSnapshot{}, err
+	}
+	snapshot.TakenAt = parsed
+
+	return snapshot, nil
+}
+
+// GetCollectionSnapshots returns every collection snapshot, oldest first.
+// Returns an empty slice (never nil) when none have been taken.
+func (database *Database) GetCollectionSnapshots() ([]models.CollectionSnapshot, error) {
+	rows, err := database.connection.Query("SELECT " + collectionSnapshotColumns + " FROM collection_snapshots ORDER BY taken_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("get collection snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := make([]models.CollectionSnapshot, 0)
+	for rows.Next() {
+		snapshot, err := scanCollectionSnapshot(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get collection snapshots: scan: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get collection snapshots: rows: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// GetCollectionSnapshot returns the snapshot with the given id and its owned
+// counts keyed by card name. Cards with no copies at the time are absent from
+// the counts. Returns ErrSnapshotNotFound if no snapshot with that id exists.
+func (database *Database) GetCollectionSnapshot(id int) (models.CollectionSnapshot, map[string]int, error) {
+	snapshot, err := scanCollectionSnapshot(database.connection.QueryRow(
+		"SELECT "+collectionSnapshotColumns+" FROM collection_snapshots WHERE id = ?", id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.CollectionSnapshot{}, nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return models.CollectionSnapshot{}, nil, fmt.Errorf("get collection snapshot: %w", err)
+	}
+
+	rows, err := database.connection.Query("SELECT card_name, owned FROM collection_snapshot_counts WHERE snapshot_id = ?", id)
+	if err != nil {
+		return models.CollectionSnapshot{}, nil, fmt.Errorf("get collection snapshot counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var owned int
+		if err := rows.Scan(&name, &owned); err != nil {
+			return models.CollectionSnapshot{}, nil, fmt.Errorf("get collection snapshot counts: scan: %w", err)
+		}
+		counts[name] = owned
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.CollectionSnapshot{}, nil, fmt.Errorf("get collection snapshot counts: rows: %w", err)
+	}
+
+	return snapshot, counts, nil
+}
+
+// completionByNameQuery records a wishlist completion for the non-archived
+// card with a name when a new owned count brings it up to its minimum owned
+// threshold. Its arguments are the completion time, the name, the minimums
+// of minimumOwnedArgs, the new count and the minimums again.
+const completionByNameQuery = "INSERT INTO wishlist_completions (card_id, completed_at) SELECT id, ? FROM cards WHERE name = ? AND archived = 0 AND owned < " +
+	minimumOwnedExpression + " AND ? >= " + minimumOwnedExpression
+
+// SetOwnedCountsByName sets the owned count of each card named in counts to
+// the mapped value, recording the change in updated_at. All updates run in a
+// single transaction, so either every count is applied or none are. Names
+// with no matching card are ignored. A wishlist completion is recorded for
+// every non-archived card the new count brings up to its minimum threshold.
+func (database *Database) SetOwnedCountsByName(counts map[string]int) error {
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return fmt.Errorf("set owned counts: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	statement, err := transaction.Prepare("UPDATE cards SET owned = ?, updated_at = ? WHERE name = ?")
+	if err != nil {
+		return fmt.Errorf("set owned counts: prepare: %w", err)
+	}
+	defer statement.Close()
+
+	completionStatement, err := transaction.Prepare(completionByNameQuery)
+	if err != nil {
+		return fmt.Errorf("set owned counts: prepare completion: %w", err)
+	}
+	defer completionStatement.Close()
+
+	now := currentTimestamp()
+	minimums := database.minimumOwnedArgs()
+	for name, owned := range counts {
+		if owned < 0 {
+			return fmt.Errorf("set owned counts: owned count for %q must not be negative", name)
+		}
+		args := append([]any{now, name}, minimums...)
+		args = append(append(args, owned), minimums...)
+		if _, err := completionStatement.Exec(args...); err != nil {
+			return fmt.Errorf("set owned counts: record completion for %q: %w", name, err)
+		}
+		if _, err := statement.Exec(owned, now, name); err != nil {
+			return fmt.Errorf("set owned counts: update %q: %w", name, err)
+		}
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("set owned counts: commit: %w", err)
+	}
+
+	return nil
+}
+
+// wishlistCompletionColumns is the column list read by scanWishlistCompletions.
+const wishlistCompletionColumns = "wishlist_completions.id, wishlist_completions.card_id, cards.name, wishlist_completions.completed_at"
+
+// scanWishlistCompletions reads every row of rows, which must select
+// wishlistCompletionColumns. Returns an empty slice (never nil) for no rows.
+func scanWishlistCompletions(rows *sql.Rows) ([]models.WishlistCompletion, error) {
+	defer rows.Close()
+
+	completions := make([]models.WishlistCompletion, 0)
+	for rows.Next() {
+		var (
+			completion  models.WishlistCompletion
+			completedAt sql.NullString
+		)
+		if err := rows.Scan(&completion.ID, &completion.CardID, &completion.Name, &completedAt); err != nil {
+			return nil, err
+		}
+		parsed, err := parseTimestamp(completedAt)
+		if err != nil {
+			return nil, err
+		}
+		completion.CompletedAt = parsed
+		completions = append(completions, completion)
+	}
+
+	return completions, rows.Err()
+}
+
+// GetRecentWishlistCompletions returns up to limit wishlist completions, most
+// recent first. Returns an error for a non-positive limit and an empty slice
+// (never nil) when nothing has been completed.
+func (database *Database) GetRecentWishlistCompletions(limit int) ([]models.WishlistCompletion, error) {
+	if limit <= 0 {
+		return nil, errors.New("limit must be a positive integer")
+	}
+
+	rows, err := database.connection.Query(
+		"SELECT "+wishlistCompletionColumns+" FROM wishlist_completions JOIN cards ON cards.id = wishlist_completions.card_id ORDER BY wishlist_completions.completed_at DESC, wishlist_completions.id DESC LIMIT ?",
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get recent wishlist completions: %w", err)
+	}
+
+	completions, err := scanWishlistCompletions(rows)
+	if err != nil {
+		return nil, fmt.Errorf("get recent wishlist completions: %w", err)
+	}
+
+	return completions, nil
+}
+
+// GetUnnotifiedWishlistCompletions returns the wishlist completions that have
+// not yet been marked notified, oldest first. Returns an empty slice (never
+// nil) when there are none.
+func (database *Database) GetUnnotifiedWishlistCompletions() ([]models.WishlistCompletion, error) {
+	rows, err := database.connection.Query(
+		"SELECT " + wishlistCompletionColumns + " FROM wishlist_completions JOIN cards ON cards.id = wishlist_completions.card_id WHERE wishlist_completions.notified_at IS NULL ORDER BY wishlist_completions.id",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get unnotified wishlist completions: %w", err)
+	}
+
+	completions, err := scanWishlistCompletions(rows)
+	if err != nil {
+		return nil, fmt.Errorf("get unnotified wishlist completions: %w", err)
+	}
+
+	return completions, nil
+}
+
+// MarkWishlistCompletionsNotified records that the wishlist completions with
+// the given ids have been delivered. Unknown ids are ignored.
+func (database *Database) MarkWishlistCompletionsNotified(ids []int) error {
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return fmt.Errorf("mark wishlist completions notified: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	now := currentTimestamp()
+	for _, id := range ids {
+		if _, err := transaction.Exec("UPDATE wishlist_completions SET notified_at = ? WHERE id = ?", now, id); err != nil {
+			return fmt.Errorf("mark wishlist completions notified: %w", err)
+		}
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("mark wishlist completions notified: commit: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecentCards returns up to limit cards ordered most recent first. With
+// RecentAdded, cards are ordered by created_at. With RecentChanged, only cards
+// modified after insert are returned, ordered by updated_at. Cards without
+// timestamps (inserted before timestamps were tracked) are never returned.
+// Returns an error for an unknown kind or a non-positive limit. Returns an
+// empty slice (never nil) when no cards qualify.
+func (database *Database) GetRecentCards(kind RecentKind, limit int) ([]models.Card, error) {
+	if limit <= 0 {
+		return nil, errors.New("limit must be a positive integer")
+	}
+
+	var query string
+	switch kind {
+	case RecentAdded:
+		query = "SELECT " + cardColumns + " FROM cards WHERE created_at IS NOT NULL ORDER BY created_at DESC, id DESC LIMIT ?"
+	case RecentChanged:
+		query = "SELECT " + cardColumns + " FROM cards WHERE updated_at IS NOT NULL AND (created_at IS NULL OR updated_at > created_at) ORDER BY updated_at DESC, id DESC LIMIT ?"
+	default:
+		return nil, fmt.Errorf("unknown recent kind %q", kind)
+	}
+
+	result, err := database.queryCards(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get recent cards: %w", err)
+	}
+
+	return result, nil
+}
+
+// changeStampExpression is the SQL expression for the time a card last
+// changed: updated_at, falling back to created_at for rows that were never
+// modified after insert.
+const changeStampExpression = "COALESCE(updated_at, created_at)"
+
+// SyncCursors records how far two instances have synchronised. Remote is the
+// change stamp of the newest card pulled from the peer and Local is the change
+// stamp of the newest local card pushed to it. Zero values mean nothing has
+// been exchanged yet.
+type SyncCursors struct {
+	Remote time.Time
+	Local  time.Time
+}
+
+// formatTimestamp formats t with timestampLayout, returning NULL for the zero
+// time.
+func formatTimestamp(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(timestampLayout), Valid: true}
+}
+
+// parseTimestamp parses a nullable timestampLayout column, returning the zero
+// time for NULL.
+func parseTimestamp(value sql.NullString) (time.Time, error) {
+	if !value.Valid {
+		return time.Time{}, nil
+	}
+	return time.Parse(timestampLayout, value.String)
+}
+
+// GetCardsChangedSince returns every card, archived or not, whose change stamp
+// (updated_at, or created_at when never updated) is after since, oldest change
+// first. A zero since returns all cards, including those inserted before
+// timestamps were tracked. Returns an empty slice (never nil) when nothing
+// changed.
+func (database *Database) GetCardsChangedSince(since time.Time) ([]models.Card, error) {
+	var result []models.Card
+	var err error
+
+	if since.IsZero() {
+		result, err = database.queryCards(
+			"SELECT " + cardColumns + " FROM cards ORDER BY " + changeStampExpression + ", id",
+		)
+	} else {
+		result, err = database.queryCards(
+			"SELECT "+cardColumns+" FROM cards WHERE "+changeStampExpression+" > ? ORDER BY "+changeStampExpression+", id",
+			formatTimestamp(since),
+		)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get cards changed since: %w", err)
+	}
+
+	return result, nil
+}
+
+// RemoteCardsResult is the outcome of ApplyRemoteCards. Applied is the number
+// of cards inserted or updated, and OwnedChanges lists every card whose owned
+// count they changed, a card inserted with copies counting as a change from
+// 0, in the order the cards were given.
+type RemoteCardsResult struct {
+	Applied      int
+	OwnedChanges []RemoteOwnedChange
+}
+
+// RemoteOwnedChange is a card's owned count before and after ApplyRemoteCards.
+type RemoteOwnedChange struct {
+	Name string
+	From int
+	To   int
+}
+
+// ApplyRemoteCards merges cards received from another instance using
+// last-write-wins per card. Cards are matched by name because IDs are local
+// to each database. A card that does not exist locally is inserted with its
+// remote timestamps and no image. An existing card is overwritten only when
+// the remote change stamp is strictly newer than the local one, and it takes
+// the remote updated_at so both instances converge on the same stamp. Both
+// copy the card's details and gameplay attributes and refresh its aspects and
+// traits, and an update bringing a card up to its minimum owned threshold
+// records a wishlist completion, as SetOwnedCountsByName does. Images and
+// aliases are not synchronised. All changes run in a single transaction.
+func (database *Database) ApplyRemoteCards(cards []models.Card) (RemoteCardsResult, error) {
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return RemoteCardsResult{}, fmt.Errorf("apply remote cards: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	now := currentTimestamp()
+	minimums := database.minimumOwnedArgs()
+	result := RemoteCardsResult{OwnedChanges: []RemoteOwnedChange{}}
+	for _, card := range cards {
+		if card.Name == "" {
+			return RemoteCardsResult{}, errors.New("apply remote cards: card name must not be empty")
+		}
+		if card.Owned < 0 {
+			return RemoteCardsResult{}, fmt.Errorf("apply remote cards: owned count for %q must not be negative", card.Name)
+		}
+
+		remoteStamp := card.UpdatedAt
+		if remoteStamp.IsZero() {
+			remoteStamp = card.CreatedAt
+		}
+
+		mainboardInt := 0
+		if card.Mainboard {
+			mainboardInt = 1
+		}
+		archivedInt := 0
+		if card.Archived {
+			archivedInt = 1
+		}
+		traits := strings.Join(splitTraits(card.Traits), "|")
+
+		var localStamp sql.NullString
+		var localOwned int
+		err := transaction.QueryRow(
+			"SELECT "+changeStampExpression+", owned FROM cards WHERE name = ?",
+			card.Name,
+		).Scan(&localStamp, &localOwned)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			_, err = transaction.Exec(
+				`INSERT INTO cards (name, owned, mainboard, archived, priority, set_code, card_number, card_type, aspects, rarity, cost, power, hp, traits, rules_text, created_at, updated_at)
+				 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				card.Name, card.Owned, mainboardInt, archivedInt, card.Priority,
+				card.Set, card.Number, card.Type, card.Aspects, card.Rarity,
+				nullableInt(card.Cost), nullableInt(card.Power), nullableInt(card.HP), traits, card.Text,
+				formatTimestamp(card.CreatedAt), formatTimestamp(card.UpdatedAt),
+			)
+			if err != nil {
+				return RemoteCardsResult{}, fmt.Errorf("apply remote cards: insert %q: %w", card.Name, err)
+			}
+			if err := syncRemoteCard(transaction, card.Name); err != nil {
+				return RemoteCardsResult{}, err
+			}
+			result.Applied++
+			if card.Owned > 0 {
+				result.OwnedChanges = append(result.OwnedChanges, RemoteOwnedChange{Name: card.Name, From: 0, To: card.Owned})
+			}
+			continue
+		}
+
+		if err != nil {
+			return RemoteCardsResult{}, fmt.Errorf("apply remote cards: lookup %q: %w", card.Name, err)
+		}
+
+		local, err := parseTimestamp(localStamp)
+		if err != nil {
+			return RemoteCardsResult{}, fmt.Errorf("apply remote cards: parse local stamp for %q: %w", card.Name, err)
+		}
+
+		if !remoteStamp.After(local) {
+			continue
+		}
+
+		args := append([]any{now, card.Name}, minimums...)
+		args = append(append(args, card.Owned), minimums...)
+		if _, err := transaction.Exec(completionByNameQuery, args...); err != nil {
+			return RemoteCardsResult{}, fmt.Errorf("apply remote cards: record completion for %q: %w", card.Name, err)
+		}
+
+		_, err = transaction.Exec(
+			`UPDATE cards SET owned = ?, mainboard = ?, archived = ?, priority = ?, set_code = ?, card_number = ?, card_type = ?, aspects = ?, rarity = ?,
+			 cost = ?, power = ?, hp = ?, traits = ?, rules_text = ?, updated_at = ?
+			 WHERE name = ?`,
+			card.Owned, mainboardInt, archivedInt, card.Priority,
+			card.Set, card.Number, card.Type, card.Aspects, card.Rarity,
+			nullableInt(card.Cost), nullableInt(card.Power), nullableInt(card.HP), traits, card.Text,
+			formatTimestamp(remoteStamp), card.Name,
+		)
+		if err != nil {
+			return RemoteCardsResult{}, fmt.Errorf("apply remote cards: update %q: %w", card.Name, err)
+		}
+		if err := syncRemoteCard(transaction, card.Name); err != nil {
+			return RemoteCardsResult{}, err
+		}
+		result.Applied++
+		if card.Owned != localOwned {
+			result.OwnedChanges = append(result.OwnedChanges, RemoteOwnedChange{Name: card.Name, From: localOwned, To: card.Owned})
+		}
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return RemoteCardsResult{}, fmt.Errorf("apply remote cards: commit: %w", err)
+	}
+
+	return result, nil
+}
+
+// syncRemoteCard refreshes the aspects and traits of the card named name
+// after ApplyRemoteCards wrote it.
+func syncRemoteCard(executor queryExecer, name string) error {
+	if err := syncCardAspects(executor, "name = ?", name); err != nil {
+		return fmt.Errorf("apply remote cards: %q: %w", name, err)
+	}
+	if err := syncCardTraits(executor, "name = ?", name); err != nil {
+		return fmt.Errorf("apply remote cards: %q: %w", name, err)
+	}
+	return nil
+}
+
+// GetSyncCursors returns the stored cursors for peer. A peer that has never
+// been synchronised returns zero cursors.
+func (database *Database) GetSyncCursors(peer string) (SyncCursors, error) {
+	if peer == "" {
+		return SyncCursors{}, errors.New("peer must not be empty")
+	}
+
+	var remote, local sql.NullString
+	err := database.connection.QueryRow(
+		"SELECT remote_cursor, local_cursor FROM sync_peers WHERE peer = ?",
+		peer,
+	).Scan(&remote, &local)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return SyncCursors{}, nil
+	}
+
+	if err != nil {
+		return SyncCursors{}, fmt.Errorf("get sync cursors: %w", err)
+	}
+
+	var cursors SyncCursors
+	if cursors.Remote, err = parseTimestamp(remote); err != nil {
+		return SyncCursors{}, fmt.Errorf("get sync cursors: parse remote cursor: %w", err)
+	}
+	if cursors.Local, err = parseTimestamp(local); err != nil {
+		return SyncCursors{}, fmt.Errorf("get sync cursors: parse local cursor: %w", err)
+	}
+
+	return cursors, nil
+}
+
+// SetSyncCursors stores cursors for peer, replacing any previous values.
+func (database *Database) SetSyncCursors(peer string, cursors SyncCursors) error {
+	if peer == "" {
+		return errors.New("peer must not be empty")
+	}
+
+	_, err := database.connection.Exec(
+		`INSERT INTO sync_peers (peer, remote_cursor, local_cursor) VALUES (?, ?, ?)
+		 ON CONFLICT(peer) DO UPDATE SET remote_cursor = excluded.remote_cursor, local_cursor = excluded.local_cursor`,
+		peer, formatTimestamp(cursors.Remote), formatTimestamp(cursors.Local),
+	)
+	if err != nil {
+		return fmt.Errorf("set sync cursors: %w", err)
+	}
+
+	return nil
+}
+
+// SnapshotTo writes a consistent copy of the database to filePath using
+// VACUUM INTO, which is safe while the database is in use. Returns an error if
+// filePath is empty or already exists.
+func (database *Database) SnapshotTo(filePath string) error {
+	if filePath == "" {
+		return errors.New("snapshot file path must not be empty")
+	}
+
+	if _, err := database.connection.Exec("VACUUM INTO ?", filePath); err != nil {
+		return fmt.Errorf("snapshot database: %w", err)
+	}
+
+	return nil
+}
+
+// DatabaseSize returns the size in bytes of the database file, as the number
+// of pages times the page size.
+func (database *Database) DatabaseSize() (int64, error) {
+	var pageCount, pageSize int64
+	if err := database.connection.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, fmt.Errorf("database size: page count: %w", err)
+	}
+	if err := database.connection.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("database size: page size: %w", err)
+	}
+
+	return pageCount * pageSize, nil
+}
+
+// Vacuum rebuilds the database file, reclaiming the space left by deleted
+// rows.
+func (database *Database) Vacuum() error {
+	if _, err := database.connection.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+
+	return nil
+}
+
+// Reindex rebuilds every index in the database.
+func (database *Database) Reindex() error {
+	if _, err := database.connection.Exec("REINDEX"); err != nil {
+		return fmt.Errorf("reindex: %w", err)
+	}
+
+	return nil
+}
+
+// IntegrityCheck runs SQLite's integrity check and returns the problems it
+// reports. An empty slice means the database is intact.
+func (database *Database) IntegrityCheck() ([]string, error) {
+	rows, err := database.connection.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("integrity check: %w", err)
+	}
+	defer rows.Close()
+
+	problems := []string{}
+	for rows.Next() {
+		var message string
+		if err := rows.Scan(&message); err != nil {
+			return nil, fmt.Errorf("integrity check: scan: %w", err)
+		}
+		if message != "ok" {
+			problems = append(problems, message)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("integrity check: rows: %w", err)
+	}
+
+	return problems, nil
+}
+
+// DuplicateCards is a card name shared by more than one card row. IDs are in
+// ascending order; MergeDuplicateCards keeps the first.
+type DuplicateCards struct {
+	Name string `json:"name"`
+	IDs  []int  `json:"ids"`
+}
+
+// GetDuplicateCards returns every card name used by more than one card, in
+// name order. Names are matched exactly, as in CardExistsByName.
+func (database *Database) GetDuplicateCards() ([]DuplicateCards, error) {
+	rows, err := database.connection.Query(`
+		SELECT id, name FROM cards
+		WHERE name IN (SELECT name FROM cards GROUP BY name HAVING COUNT(*) > 1)
+		ORDER BY name, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("get duplicate cards: %w", err)
+	}
+	defer rows.Close()
+
+	duplicates := []DuplicateCards{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("get duplicate cards: scan: %w", err)
+		}
+
+		if len(duplicates) == 0 || duplicates[len(duplicates)-1].Name != name {
+			duplicates = append(duplicates, DuplicateCards{Name: name})
+		}
+		duplicates[len(duplicates)-1].IDs = append(duplicates[len(duplicates)-1].IDs, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get duplicate cards: rows: %w", err)
+	}
+
+	return duplicates, nil
+}
+
+// mergedDetailColumns are the text columns MergeDuplicateCards copies from a
+// duplicate when the kept card has no value.
+var mergedDetailColumns = []string{"image", "thumbnail", "back_image", "set_code", "card_number", "card_type", "aspects", "rarity", "traits", "rules_text"}
+
+// MergeDuplicateCards folds every group of cards sharing a name into the card
+// with the lowest ID and deletes the others. The kept card's owned count
+// becomes the sum of the group's, its priority the highest in the group, and
+// it stays archived only if every card in the group was archived. Empty
+// details are filled from the duplicates, and aliases, wishlist completions
+// and cube assignments are moved to the kept card; where the kept card is
+// already in a cube, the duplicate's assignment to it is dropped. Returns the
+// number of cards deleted.
+func (database *Database) MergeDuplicateCards() (int, error) {
+	duplicates, err := database.GetDuplicateCards()
+	if err != nil {
+		return 0, fmt.Errorf("merge duplicate cards: %w", err)
+	}
+
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("merge duplicate cards: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	now := currentTimestamp()
+	removed := 0
+	for _, group := range duplicates {
+		keepID := group.IDs[0]
+		dropIDs := group.IDs[1:]
+
+		placeholders := strings.Repeat("?, ", len(dropIDs)-1) + "?"
+		dropArgs := make([]any, len(dropIDs))
+		for i, id := range dropIDs {
+			dropArgs[i] = id
+		}
+
+		assignments := []string{
+			"owned = (SELECT SUM(owned) FROM cards WHERE name = ?)",
+			"priority = (SELECT MAX(priority) FROM cards WHERE name = ?)",
+			"archived = (SELECT MIN(archived) FROM cards WHERE name = ?)",
+		}
+		args := []any{group.Name, group.Name, group.Name}
+		for _, column := range mergedDetailColumns {
+			assignments = append(assignments, fmt.Sprintf(
+				"%[1]s = COALESCE(NULLIF(%[1]s, ''), (SELECT %[1]s FROM cards WHERE name = ? AND %[1]s <> '' ORDER BY id LIMIT 1), %[1]s)",
+				column,
+			))
+			args = append(args, group.Name)
+		}
+		assignments = append(assignments, "updated_at = ?")
+		args = append(args, now, keepID)
+
+		if _, err := transaction.Exec("UPDATE cards SET "+strings.Join(assignments, ", ")+" WHERE id = ?", args...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: update %q: %w", group.Name, err)
+		}
+
+		moveArgs := append([]any{keepID}, dropArgs...)
+		if _, err := transaction.Exec("UPDATE OR IGNORE card_aliases SET card_id = ? WHERE card_id IN ("+placeholders+")", moveArgs...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: move aliases of %q: %w", group.Name, err)
+		}
+		if _, err := transaction.Exec("DELETE FROM card_aliases WHERE card_id IN ("+placeholders+")", dropArgs...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: delete aliases of %q: %w", group.Name, err)
+		}
+		if _, err := transaction.Exec("UPDATE OR IGNORE card_translations SET card_id = ? WHERE card_id IN ("+placeholders+")", moveArgs...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: move translations of %q: %w", group.Name, err)
+		}
+		if _, err := transaction.Exec("DELETE FROM card_translations WHERE card_id IN ("+placeholders+")", dropArgs...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: delete translations of %q: %w", group.Name, err)
+		}
+		if _, err := transaction.Exec("UPDATE wishlist_completions SET card_id = ? WHERE card_id IN ("+placeholders+")", moveArgs...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: move wishlist completions of %q: %w", group.Name, err)
+		}
+		if _, err := transaction.Exec("UPDATE OR IGNORE cube_cards SET card_id = ? WHERE card_id IN ("+placeholders+")", moveArgs...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: move cube cards of %q: %w", group.Name, err)
+		}
+		if _, err := transaction.Exec("DELETE FROM cube_cards WHERE card_id IN ("+placeholders+")", dropArgs...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: delete cube cards of %q: %w", group.Name, err)
+		}
+		if _, err := transaction.Exec("UPDATE OR IGNORE cart_items SET card_id = ? WHERE card_id IN ("+placeholders+")", moveArgs...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: move cart items of %q: %w", group.Name, err)
+		}
+		if _, err := transaction.Exec("DELETE FROM cart_items WHERE card_id IN ("+placeholders+")", dropArgs...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: delete cart items of %q: %w", group.Name, err)
+		}
+		if _, err := transaction.Exec("UPDATE audit_events SET card_id = ? WHERE card_id IN ("+placeholders+")", moveArgs...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: move audit events of %q: %w", group.Name, err)
+		}
+		if _, err := transaction.Exec("DELETE FROM card_aspects WHERE card_id IN ("+placeholders+")", dropArgs...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: delete aspects of %q: %w", group.Name, err)
+		}
+		if _, err := transaction.Exec("DELETE FROM card_traits WHERE card_id IN ("+placeholders+")", dropArgs...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: delete traits of %q: %w", group.Name, err)
+		}
+		if _, err := transaction.Exec("DELETE FROM cards WHERE id IN ("+placeholders+")", dropArgs...); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: delete %q: %w", group.Name, err)
+		}
+		if err := syncCardAspects(transaction, "id = ?", keepID); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: %q: %w", group.Name, err)
+		}
+		if err := syncCardTraits(transaction, "id = ?", keepID); err != nil {
+			return 0, fmt.Errorf("merge duplicate cards: %q: %w", group.Name, err)
+		}
+
+		removed += len(dropIDs)
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return 0, fmt.Errorf("merge duplicate cards: commit: %w", err)
+	}
+
+	return removed, nil
+}
+
+// ErrInvalidRestoreSource is returned by RestoreFrom when the file is not a
+// SQLite database with a cards table.
+var ErrInvalidRestoreSource = errors.New("restore source is not a collection database")
+
+// restoredTables are the tables RestoreFrom replaces, parents before the
+// tables that reference them. api_tokens is left alone, so that restoring a
+// backup neither revives revoked tokens nor revokes current ones, as is the
+// imports history, and card_aspects and card_traits are rebuilt from the
+// restored cards instead.
+var restoredTables = []string{
+	"cards",
+	"unreleased_sets",
+	"card_aliases",
+	"card_translations",
+	"sync_peers",
+	"wishlist_completions",
+	"collection_snapshots",
+	"collection_snapshot_counts",
+	"valuations",
+	"inventory_items",
+	"events",
+	"cubes",
+	"cube_cards",
+	"saved_searches",
+	"goals",
+	"settings",
+}
+
+// contextQueryer is satisfied by both *sql.Conn and *sql.Tx.
+type contextQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// tableColumns returns the column names of table in the given schema, or an
+// empty slice if the table does not exist.
+func tableColumns(queryer contextQueryer, schema, table string) ([]string, error) {
+	rows, err := queryer.QueryContext(context.Background(), fmt.Sprintf("PRAGMA %s.table_info(%s)", schema, table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []string{}
+	for rows.Next() {
+		var (
+			cid          int
+			name         string
+			columnType   string
+			notNull      int
+			defaultValue sql.NullString
+			primaryKey   int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+
+	return columns, rows.Err()
+}
+
+// RestoreFrom replaces the contents of every table with those of the SQLite
+// database file at filePath, such as one written by SnapshotTo, in a single
+// transaction. Columns the file lacks, because it predates a migration, take
+// their default values, and tables it lacks are left empty. The restored
+// settings become the settings in use. Returns ErrInvalidRestoreSource if the
+// file is not a collection database.
+func (database *Database) RestoreFrom(filePath string) error {
+	if filePath == "" {
+		return errors.New("restore file path must not be empty")
+	}
+
+	ctx := context.Background()
+
+	// ATTACH only applies to one connection, so the whole restore runs on a
+	// dedicated one.
+	conn, err := database.connection.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("restore: connection: %w", err)
+	}
+	defer conn.Close()
+
+	// Clearing cards would otherwise cascade into tables already restored,
+	// and backups may hold rows orphaned before foreign keys were enforced,
+	// which are deleted once everything is copied. The pragma cannot change
+	// inside a transaction, so it is set around it.
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return fmt.Errorf("restore: disable foreign keys: %w", err)
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS restore_source", filePath); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRestoreSource, err)
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE restore_source")
+
+	sourceCardColumns, err := tableColumns(conn, "restore_source", "cards")
+	if err != nil || len(sourceCardColumns) == 0 {
+		return ErrInvalidRestoreSource
+	}
+
+	transaction, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("restore: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	for _, table := range restoredTables {
+		columns, err := tableColumns(transaction, "main", table)
+		if err != nil {
+			return fmt.Errorf("restore: %s columns: %w", table, err)
+		}
+		sourceColumns, err := tableColumns(transaction, "restore_source", table)
+		if err != nil {
+			return fmt.Errorf("restore: source %s columns: %w", table, err)
+		}
+
+		if _, err := transaction.ExecContext(ctx, "DELETE FROM main."+table); err != nil {
+			return fmt.Errorf("restore: clear %s: %w", table, err)
+		}
+
+		inSource := make(map[string]bool, len(sourceColumns))
+		for _, column := range sourceColumns {
+			inSource[column] = true
+		}
+		shared := []string{}
+		for _, column := range columns {
+			if inSource[column] {
+				shared = append(shared, column)
+			}
+		}
+		if len(shared) == 0 {
+			continue
+		}
+
+		columnList := strings.Join(shared, ", ")
+		if _, err := transaction.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO main.%[1]s (%[2]s) SELECT %[2]s FROM restore_source.%[1]s", table, columnList,
+		)); err != nil {
+			return fmt.Errorf("restore: copy %s: %w", table, err)
+		}
+	}
+
+	if err := deleteOrphans(transaction); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	if err := rebuildCardAspects(transaction); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	if err := rebuildCardTraits(transaction); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("restore: commit: %w", err)
+	}
+
+	settings, err := database.GetSettings()
+	if err != nil {
+		return fmt.Errorf("restore: load settings: %w", err)
+	}
+	database.cacheSettings(settings)
+
+	return nil
+}
+
+// maxItemsPerPage is the largest ItemsPerPage ValidateSettings accepts.
+const maxItemsPerPage = 1000
+
+// DefaultSettings returns the settings used until they are first saved.
+func DefaultSettings() models.Settings {
+	return models.Settings{
+		LeaderMinimumOwned:  LeaderMinimumOwned,
+		BaseMinimumOwned:    BaseMinimumOwned,
+		UnitMinimumOwned:    UnitMinimumOwned,
+		EventMinimumOwned:   EventMinimumOwned,
+		UpgradeMinimumOwned: UpgradeMinimumOwned,
+		TokenMinimumOwned:   TokenMinimumOwned,
+		UntypedMinimumOwned: UntypedMinimumOwned,
+		DefaultSort:         string(SortByName),
+		Theme:               models.ThemeDark,
+		DigestIntervalDays:  defaultDigestIntervalDays,
+	}
+}
+
+// ValidateSettings returns an error describing the first invalid value in
+// settings, or nil when every value is valid.
+func ValidateSettings(settings models.Settings) error {
+	for _, minimum := range minimumOwnedByType(settings) {
+		if minimum < 1 {
+			return errors.New("minimum owned counts must be at least 1")
+		}
+	}
+
+	if _, ok := cardSortOrders[CardSort(settings.DefaultSort)]; !ok {
+		return errors.New("default_sort must be one of: name, owned, recent, set_number")
+	}
+
+	if settings.Theme != models.ThemeDark && settings.Theme != models.ThemeLight {
+		return errors.New("theme must be one of: dark, light")
+	}
+
+	if settings.ItemsPerPage < 0 || settings.ItemsPerPage > maxItemsPerPage {
+		return fmt.Errorf("items_per_page must be between 0 and %d", maxItemsPerPage)
+	}
+
+	if settings.DigestIntervalDays < 1 || settings.DigestIntervalDays > maxDigestIntervalDays {
+		return fmt.Errorf("digest_interval_days must be between 1 and %d", maxDigestIntervalDays)
+	}
+
+	if settings.DecrementFloor < 0 {
+		return errors.New("decrement_floor must not be negative")
+	}
+
+	if settings.MaxOwnedChange < 0 {
+		return errors.New("max_owned_change must not be negative")
+	}
+
+	return validateDigestTarget(settings.DigestChannel, settings.DigestTarget)
+}
+
+// settingsValues returns settings as the key/value pairs stored in the
+// settings table. The keys match the JSON field names of models.Settings.
+func settingsValues(settings models.Settings) map[string]string {
+	return map[string]string{
+		"leader_minimum_owned":   strconv.Itoa(settings.LeaderMinimumOwned),
+		"base_minimum_owned":     strconv.Itoa(settings.BaseMinimumOwned),
+		"unit_minimum_owned":     strconv.Itoa(settings.UnitMinimumOwned),
+		"event_minimum_owned":    strconv.Itoa(settings.EventMinimumOwned),
+		"upgrade_minimum_owned":  strconv.Itoa(settings.UpgradeMinimumOwned),
+		"token_minimum_owned":    strconv.Itoa(settings.TokenMinimumOwned),
+		"untyped_minimum_owned":  strconv.Itoa(settings.UntypedMinimumOwned),
+		"default_sort":           settings.DefaultSort,
+		"theme":                  settings.Theme,
+		"items_per_page":         strconv.Itoa(settings.ItemsPerPage),
+		"import_lenient":         strconv.FormatBool(settings.ImportLenient),
+		"import_use_owned_count": strconv.FormatBool(settings.ImportUseOwnedCount),
+		"import_keep_files":      strconv.FormatBool(settings.ImportKeepFiles),
+		"html_only":              strconv.FormatBool(settings.HTMLOnly),
+		"digest_channel":         settings.DigestChannel,
+		"digest_target":          settings.DigestTarget,
+		"digest_interval_days":   strconv.Itoa(settings.DigestIntervalDays),
+		"decrement_floor":        strconv.Itoa(settings.DecrementFloor),
+		"max_owned_change":       strconv.Itoa(settings.MaxOwnedChange),
+	}
+}
+
+// GetSettings reads the stored settings. Settings that have never been saved
+// take their DefaultSettings value.
+func (database *Database) GetSettings() (models.Settings, error) {
+	rows, err := database.connection.Query("SELECT key, value FROM settings")
+	if err != nil {
+		return models.Settings{}, fmt.Errorf("get settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := DefaultSettings()
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return models.Settings{}, fmt.Errorf("get settings: scan: %w", err)
+		}
+
+		var parseErr error
+		switch key {
+		case "leader_minimum_owned":
+			settings.LeaderMinimumOwned, parseErr = strconv.Atoi(value)
+		case "base_minimum_owned":
+			settings.BaseMinimumOwned, parseErr = strconv.Atoi(value)
+		case "unit_minimum_owned":
+			settings.UnitMinimumOwned, parseErr = strconv.Atoi(value)
+		case "event_minimum_owned":
+			settings.EventMinimumOwned, parseErr = strconv.Atoi(value)
+		case "upgrade_minimum_owned":
+			settings.UpgradeMinimumOwned, parseErr = strconv.Atoi(value)
+		case "token_minimum_owned":
+			settings.TokenMinimumOwned, parseErr = strconv.Atoi(value)
+		case "untyped_minimum_owned":
+			settings.UntypedMinimumOwned, parseErr = strconv.Atoi(value)
+		case "default_sort":
+			settings.DefaultSort = value
+		case "theme":
+			settings.Theme = value
+		case "items_per_page":
+			settings.ItemsPerPage, parseErr = strconv.Atoi(value)
+		case "import_lenient":
+			settings.ImportLenient, parseErr = strconv.ParseBool(value)
+		case "import_use_owned_count":
+			settings.ImportUseOwnedCount, parseErr = strconv.ParseBool(value)
+		case "import_keep_files":
+			settings.ImportKeepFiles, parseErr = strconv.ParseBool(value)
+		case "html_only":
+			settings.HTMLOnly, parseErr = strconv.ParseBool(value)
+		case "digest_channel":
+			settings.DigestChannel = value
+		case "digest_target":
+			settings.DigestTarget = value
+		case "digest_interval_days":
+			settings.DigestIntervalDays, parseErr = strconv.Atoi(value)
+		case "decrement_floor":
+			settings.DecrementFloor, parseErr = strconv.Atoi(value)
+		case "max_owned_change":
+			settings.MaxOwnedChange, parseErr = strconv.Atoi(value)
+		}
+		if parseErr != nil {
+			return models.Settings{}, fmt.Errorf("get settings: parse %s: %w", key, parseErr)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Settings{}, fmt.Errorf("get settings: iterate: %w", err)
+	}
+
+	return settings, nil
+}
+
+// SaveSettings validates and stores settings, replacing every stored value,
+// and makes them the settings returned by Settings.
+func (database *Database) SaveSettings(settings models.Settings) error {
+	if err := ValidateSettings(settings); err != nil {
+		return fmt.Errorf("save settings: %w", err)
+	}
+
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return fmt.Errorf("save settings: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	for key, value := range settingsValues(settings) {
+		if _, err := transaction.Exec(
+			"INSERT INTO settings (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value",
+			key, value,
+		); err != nil {
+			return fmt.Errorf("save settings: %s: %w", key, err)
+		}
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("save settings: commit: %w", err)
+	}
+
+	database.cacheSettings(settings)
+
+	return nil
+}
+
+// Settings returns the settings in use: the stored settings once migrations
+// have run, otherwise DefaultSettings.
+func (database *Database) Settings() models.Settings {
+	database.settingsMutex.RLock()
+	defer database.settingsMutex.RUnlock()
+
+	return database.settings
+}
+
+// cacheSettings replaces the settings returned by Settings.
+func (database *Database) cacheSettings(settings models.Settings) {
+	database.settingsMutex.Lock()
+	defer database.settingsMutex.Unlock()
+
+	database.settings = settings
+}
+
+// minimumOwnedByType returns the minimum owned counts in settings in the
+// order minimumOwnedExpression takes them: leader, base, unit, event,
+// upgrade, token, and cards of any other type.
+func minimumOwnedByType(settings models.Settings) []int {
+	return []int{
+		settings.LeaderMinimumOwned,
+		settings.BaseMinimumOwned,
+		settings.UnitMinimumOwned,
+		settings.EventMinimumOwned,
+		settings.UpgradeMinimumOwned,
+		settings.TokenMinimumOwned,
+		settings.UntypedMinimumOwned,
+	}
+}
+
+// minimumOwnedArgs returns the minimum owned counts in use, as the query
+// arguments of minimumOwnedExpression.
+func (database *Database) minimumOwnedArgs() []any {
+	var args []any
+	for _, minimum := range minimumOwnedByType(database.Settings()) {
+		args = append(args, minimum)
+	}
+	return args
+}
+
+// MinimumOwned returns the minimum owned threshold in settings for a card of
+// cardType, matched case-insensitively; cards of an unknown type use
+// UntypedMinimumOwned. It agrees with the threshold the wishlist and excess
+// queries apply.
+func MinimumOwned(settings models.Settings, cardType string) int {
+	switch strings.ToLower(cardType) {
+	case "leader":
+		return settings.LeaderMinimumOwned
+	case "base":
+		return settings.BaseMinimumOwned
+	case "unit":
+		return settings.UnitMinimumOwned
+	case "event":
+		return settings.EventMinimumOwned
+	case "upgrade":
+		return settings.UpgradeMinimumOwned
+	case "token":
+		return settings.TokenMinimumOwned
+	default:
+		return settings.UntypedMinimumOwned
+	}
+}
+
+// Shutdown closes the read and write connections. It should be called when
+// the application is shutting down to release resources cleanly.
+func (database *Database) Shutdown() error {
+	if err := database.connection.reader.Close(); err != nil {
+		return fmt.Errorf("close sqlite read connections: %w", err)
+	}
+
 	if err := database.connection.Close(); err != nil {
 		return fmt.Errorf("close sqlite database: %w", err)
 	}
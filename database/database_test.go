@@ -1,32 +1,24 @@
 package database_test
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"swucol/database"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/search"
 )
 
-// newTestDatabase creates a Database backed by a temporary file that is
-// cleaned up automatically when the test ends.
-func newTestDatabase(t *testing.T) *database.Database {
-	t.Helper()
-
-	filePath := filepath.Join(t.TempDir(), "test.db")
-
-	db, err := database.New(filePath)
-	require.NoError(t, err, "expected no error opening test database")
-
-	t.Cleanup(func() {
-		db.Shutdown()
-	})
-
-	return db
-}
-
 func TestNew_EmptyFilePath_ReturnsError(t *testing.T) {
 	db, err := database.New("")
 
@@ -35,13 +27,49 @@ func TestNew_EmptyFilePath_ReturnsError(t *testing.T) {
 }
 
 func TestNew_ValidFilePath_OpensSuccessfully(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 
 	assert.NotNil(t, db)
 }
 
+func TestNew_UsesWriteAheadLog(t *testing.T) {
+	db := testdb.Open(t)
+
+	var mode string
+	require.NoError(t, db.Connection().QueryRow("PRAGMA journal_mode").Scan(&mode))
+
+	assert.Equal(t, "wal", mode)
+}
+
+func TestSearchCards_RunsWhileAWriteTransactionIsOpen(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight"}))
+
+	// The transaction holds the only write connection until it ends.
+	transaction, err := db.Connection().Begin()
+	require.NoError(t, err)
+	defer transaction.Rollback()
+	_, err = transaction.Exec("INSERT INTO cards (name) VALUES ('Luke Skywalker, Faithful Friend')")
+	require.NoError(t, err)
+
+	done := make(chan []models.Card, 1)
+	go func() {
+		cards, err := db.SearchCards("luke")
+		assert.NoError(t, err)
+		done <- cards
+	}()
+
+	select {
+	case cards := <-done:
+		assert.Len(t, cards, 1, "expected the uncommitted insert to be invisible to readers")
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the search not to wait for the write transaction")
+	}
+}
+
 func TestRunMigrations_CreatesCardsTable(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 
 	err := db.RunMigrations()
 	require.NoError(t, err, "expected migrations to run without error")
@@ -58,7 +86,7 @@ func TestRunMigrations_CreatesCardsTable(t *testing.T) {
 }
 
 func TestRunMigrations_CardsTableHasCorrectColumns(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	rows, err := db.Connection().Query("PRAGMA table_info(cards)")
@@ -103,14 +131,14 @@ func TestRunMigrations_CardsTableHasCorrectColumns(t *testing.T) {
 }
 
 func TestRunMigrations_IsIdempotent(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 
 	require.NoError(t, db.RunMigrations())
 	require.NoError(t, db.RunMigrations(), "running migrations a second time should not error")
 }
 
 func TestCardsTable_InsertAndQuery(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	// Insert a card.
@@ -134,7 +162,7 @@ func TestCardsTable_InsertAndQuery(t *testing.T) {
 }
 
 func TestCardsTable_NameIsRequired(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
@@ -146,7 +174,7 @@ func TestCardsTable_NameIsRequired(t *testing.T) {
 }
 
 func TestCardExistsByName_CardDoesNotExist_ReturnsFalse(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	exists, err := db.CardExistsByName("Nonexistent Card")
@@ -156,7 +184,7 @@ func TestCardExistsByName_CardDoesNotExist_ReturnsFalse(t *testing.T) {
 }
 
 func TestCardExistsByName_CardExists_ReturnsTrue(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
@@ -172,7 +200,7 @@ func TestCardExistsByName_CardExists_ReturnsTrue(t *testing.T) {
 }
 
 func TestCardExistsByName_EmptyName_ReturnsError(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	exists, err := db.CardExistsByName("")
@@ -182,10 +210,10 @@ func TestCardExistsByName_EmptyName_ReturnsError(t *testing.T) {
 }
 
 func TestInsertCard_ValidNameWithImage_InsertsWithOwnedZeroAndImage(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
-	err := db.InsertCard("Chewbacca, Hero of Kessel", "images/LAW001.png", true)
+	err := db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel", Image: "images/LAW001.png", Mainboard: true})
 	require.NoError(t, err)
 
 	row := db.Connection().QueryRow(
@@ -203,10 +231,10 @@ func TestInsertCard_ValidNameWithImage_InsertsWithOwnedZeroAndImage(t *testing.T
 }
 
 func TestInsertCard_MainboardTrue_StoresOne(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
-	err := db.InsertCard("Luke Skywalker, Jedi Knight", "", true)
+	err := db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Mainboard: true})
 	require.NoError(t, err)
 
 	row := db.Connection().QueryRow(
@@ -220,10 +248,10 @@ func TestInsertCard_MainboardTrue_StoresOne(t *testing.T) {
 }
 
 func TestInsertCard_MainboardFalse_StoresZero(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
-	err := db.InsertCard("Mace Windu, Party Crasher", "", false)
+	err := db.InsertCard(models.NewCard{Name: "Mace Windu, Party Crasher"})
 	require.NoError(t, err)
 
 	row := db.Connection().QueryRow(
@@ -237,10 +265,10 @@ func TestInsertCard_MainboardFalse_StoresZero(t *testing.T) {
 }
 
 func TestInsertCard_ValidNameWithEmptyImage_InsertsWithNullImage(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
-	err := db.InsertCard("Chewbacca, Hero of Kessel", "", true)
+	err := db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel", Mainboard: true})
 	require.NoError(t, err)
 
 	row := db.Connection().QueryRow(
@@ -256,16 +284,16 @@ func TestInsertCard_ValidNameWithEmptyImage_InsertsWithNullImage(t *testing.T) {
 }
 
 func TestInsertCard_EmptyName_ReturnsError(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
-	err := db.InsertCard("", "images/LAW001.png", true)
+	err := db.InsertCard(models.NewCard{Name: "", Image: "images/LAW001.png", Mainboard: true})
 
 	assert.ErrorContains(t, err, "must not be empty")
 }
 
 func TestGetCardByID_ExistingCard_ReturnsCard(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	result, err := db.Connection().Exec(
@@ -287,7 +315,7 @@ func TestGetCardByID_ExistingCard_ReturnsCard(t *testing.T) {
 }
 
 func TestGetCardByID_MainboardFalse_ReturnsMainboardFalse(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	result, err := db.Connection().Exec(
@@ -305,7 +333,7 @@ func TestGetCardByID_MainboardFalse_ReturnsMainboardFalse(t *testing.T) {
 }
 
 func TestGetCardByID_NullImage_ReturnsEmptyString(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	result, err := db.Connection().Exec(
@@ -323,7 +351,7 @@ func TestGetCardByID_NullImage_ReturnsEmptyString(t *testing.T) {
 }
 
 func TestGetCardByID_NonExistentID_ReturnsErrCardNotFound(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	card, err := db.GetCardByID(99999)
@@ -333,7 +361,7 @@ func TestGetCardByID_NonExistentID_ReturnsErrCardNotFound(t *testing.T) {
 }
 
 func TestGetCardByID_ZeroID_ReturnsError(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	card, err := db.GetCardByID(0)
@@ -343,7 +371,7 @@ func TestGetCardByID_ZeroID_ReturnsError(t *testing.T) {
 }
 
 func TestGetCardByID_NegativeID_ReturnsError(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	card, err := db.GetCardByID(-1)
@@ -353,7 +381,7 @@ func TestGetCardByID_NegativeID_ReturnsError(t *testing.T) {
 }
 
 func TestIncrementCardOwned_ExistingCard_IncrementsOwned(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	result, err := db.Connection().Exec(
@@ -375,7 +403,7 @@ func TestIncrementCardOwned_ExistingCard_IncrementsOwned(t *testing.T) {
 }
 
 func TestIncrementCardOwned_NonExistentID_ReturnsErrCardNotFound(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	err := db.IncrementCardOwned(99999)
@@ -384,7 +412,7 @@ func TestIncrementCardOwned_NonExistentID_ReturnsErrCardNotFound(t *testing.T) {
 }
 
 func TestIncrementCardOwned_ZeroID_ReturnsError(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	err := db.IncrementCardOwned(0)
@@ -393,7 +421,7 @@ func TestIncrementCardOwned_ZeroID_ReturnsError(t *testing.T) {
 }
 
 func TestIncrementCardOwned_NegativeID_ReturnsError(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	err := db.IncrementCardOwned(-1)
@@ -402,7 +430,7 @@ func TestIncrementCardOwned_NegativeID_ReturnsError(t *testing.T) {
 }
 
 func TestDecrementCardOwned_ExistingCardWithPositiveOwned_DecrementsOwned(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	result, err := db.Connection().Exec(
@@ -424,7 +452,7 @@ func TestDecrementCardOwned_ExistingCardWithPositiveOwned_DecrementsOwned(t *tes
 }
 
 func TestDecrementCardOwned_ExistingCardWithZeroOwned_StaysAtZero(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	result, err := db.Connection().Exec(
@@ -446,7 +474,7 @@ func TestDecrementCardOwned_ExistingCardWithZeroOwned_StaysAtZero(t *testing.T)
 }
 
 func TestDecrementCardOwned_NonExistentID_ReturnsErrCardNotFound(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	err := db.DecrementCardOwned(99999)
@@ -455,7 +483,7 @@ func TestDecrementCardOwned_NonExistentID_ReturnsErrCardNotFound(t *testing.T) {
 }
 
 func TestDecrementCardOwned_ZeroID_ReturnsError(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	err := db.DecrementCardOwned(0)
@@ -464,7 +492,7 @@ func TestDecrementCardOwned_ZeroID_ReturnsError(t *testing.T) {
 }
 
 func TestDecrementCardOwned_NegativeID_ReturnsError(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	err := db.DecrementCardOwned(-1)
@@ -473,7 +501,7 @@ func TestDecrementCardOwned_NegativeID_ReturnsError(t *testing.T) {
 }
 
 func TestSearchCards_EmptyDatabase_EmptyQuery_ReturnsEmptySlice(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	result, err := db.SearchCards("")
@@ -483,7 +511,7 @@ func TestSearchCards_EmptyDatabase_EmptyQuery_ReturnsEmptySlice(t *testing.T) {
 }
 
 func TestSearchCards_EmptyQuery_ReturnsAllCards(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
@@ -500,7 +528,7 @@ func TestSearchCards_EmptyQuery_ReturnsAllCards(t *testing.T) {
 }
 
 func TestSearchCards_ExactNameMatch_ReturnsMatchingCard(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
@@ -517,7 +545,7 @@ func TestSearchCards_ExactNameMatch_ReturnsMatchingCard(t *testing.T) {
 }
 
 func TestSearchCards_PartialNameMatch_ReturnsMatchingCards(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
@@ -535,7 +563,7 @@ func TestSearchCards_PartialNameMatch_ReturnsMatchingCards(t *testing.T) {
 }
 
 func TestSearchCards_CaseInsensitiveMatch_ReturnsMatchingCards(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
@@ -552,7 +580,7 @@ func TestSearchCards_CaseInsensitiveMatch_ReturnsMatchingCards(t *testing.T) {
 }
 
 func TestSearchCards_NoMatch_ReturnsEmptySlice(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
@@ -567,8 +595,118 @@ func TestSearchCards_NoMatch_ReturnsEmptySlice(t *testing.T) {
 	assert.Empty(t, result)
 }
 
+func TestSearchCards_QuerySyntax_FiltersByCardDetails(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	require.NoError(t, db.InsertCards([]models.NewCard{
+		{Name: "Luke Skywalker, Faithful Friend", Set: "SOR", Type: "Leader", Aspects: "Vigilance|Heroism", Rarity: "Rare"},
+		{Name: "Rebel Assault", Set: "SOR", Type: "Event", Aspects: "Aggression | Heroism", Rarity: "Common"},
+		{Name: "Echo Base", Set: "SOR", Type: "Base", Aspects: "Command", Rarity: "Common"},
+		{Name: "Darth Vader, Dark Lord of the Sith", Set: "SOR", Type: "Leader", Aspects: "Aggression|Villainy", Rarity: "Rare"},
+		{Name: "Han Solo, Audacious Smuggler", Set: "SHD", Type: "Leader", Aspects: "Command|Heroism", Rarity: "Rare"},
+	}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{
+		"Luke Skywalker, Faithful Friend":    3,
+		"Rebel Assault":                      4,
+		"Echo Base":                          5,
+		"Darth Vader, Dark Lord of the Sith": 3,
+		"Han Solo, Audacious Smuggler":       1,
+	}))
+
+	result, err := db.SearchCards("set:sor aspect:heroism owned>=3 -type:base")
+
+	require.NoError(t, err)
+	names := make([]string, len(result))
+	for i, card := range result {
+		names[i] = card.Name
+	}
+	assert.ElementsMatch(t, []string{"Luke Skywalker, Faithful Friend", "Rebel Assault"}, names)
+
+	_, err = db.SearchCards("colour:red")
+	assert.ErrorIs(t, err, search.ErrInvalidQuery)
+}
+
+func TestSetCardAttributes_StoresAttributesAndTraits(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	require.NoError(t, db.InsertCards([]models.NewCard{
+		{Name: "Rebel Assault", Type: "Event"},
+		{Name: "Battlefield Marine", Type: "Unit"},
+		{Name: "Death Trooper", Type: "Unit"},
+	}))
+	cost, power, hp, eventCost := 2, 3, 3, 1
+
+	updated, err := db.SetCardAttributes(map[string]models.CardAttributes{
+		"Rebel Assault":      {Cost: &eventCost, Traits: "Tactic", Text: "Attack with 2 Rebel units."},
+		"Battlefield Marine": {Cost: &cost, Power: &power, HP: &hp, Traits: "rebel|Trooper|REBEL"},
+		"Not In Collection":  {Cost: &cost},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated)
+
+	cards, err := db.SearchCards("Battlefield Marine")
+	require.NoError(t, err)
+	require.Len(t, cards, 1)
+	marine := cards[0]
+	require.NotNil(t, marine.Cost)
+	assert.Equal(t, 2, *marine.Cost)
+	require.NotNil(t, marine.HP)
+	assert.Equal(t, 3, *marine.HP)
+	assert.Equal(t, "REBEL|TROOPER", marine.Traits)
+	traits, err := db.GetCardTraits(marine.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"REBEL", "TROOPER"}, traits)
+
+	cards, err = db.SearchCards("Rebel Assault")
+	require.NoError(t, err)
+	require.Len(t, cards, 1)
+	assert.Nil(t, cards[0].Power)
+	assert.Equal(t, "Attack with 2 Rebel units.", cards[0].Text)
+
+	result, err := db.SearchCards("cost<=2 trait:rebel")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "Battlefield Marine", result[0].Name)
+
+	result, err = db.SearchCards("Death Trooper")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Nil(t, result[0].Cost, "cards the catalog did not list keep no attributes")
+}
+
+func TestCardAspects_ParsedOnInsertAndBackfilledByMigration(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Rebel Assault", Aspects: "heroism, Aggression Heroism"}))
+
+	cards, err := db.SearchCards("Rebel Assault")
+	require.NoError(t, err)
+	require.Len(t, cards, 1)
+	aspects, err := db.GetCardAspects(cards[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Aggression", "Heroism"}, aspects)
+
+	_, err = db.Connection().Exec("INSERT INTO cards (name, aspects) VALUES ('Darth Vader, Dark Lord of the Sith', 'Aggression|Villainy')")
+	require.NoError(t, err)
+	_, err = db.Connection().Exec("DELETE FROM card_aspects")
+	require.NoError(t, err)
+	require.NoError(t, db.RunMigrations())
+
+	villains, err := db.SearchCards("aspect:villainy")
+	require.NoError(t, err)
+	require.Len(t, villains, 1)
+	assert.Equal(t, "Darth Vader, Dark Lord of the Sith", villains[0].Name)
+	aggressive, err := db.SearchCards("aspect:aggression")
+	require.NoError(t, err)
+	assert.Len(t, aggressive, 2)
+}
+
 func TestSearchCards_NullImage_ReturnsEmptyStringForImage(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
@@ -585,7 +723,7 @@ func TestSearchCards_NullImage_ReturnsEmptyStringForImage(t *testing.T) {
 }
 
 func TestSearchCards_DefaultInsert_ReturnsMainboardTrue(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
@@ -602,7 +740,7 @@ func TestSearchCards_DefaultInsert_ReturnsMainboardTrue(t *testing.T) {
 }
 
 func TestSearchCards_MainboardFalse_ReturnsMainboardFalse(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
@@ -619,7 +757,7 @@ func TestSearchCards_MainboardFalse_ReturnsMainboardFalse(t *testing.T) {
 }
 
 func TestGetWishlistCards_EmptyDatabase_ReturnsEmptySlice(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	result, err := db.GetWishlistCards("")
@@ -628,13 +766,13 @@ func TestGetWishlistCards_EmptyDatabase_ReturnsEmptySlice(t *testing.T) {
 	assert.Empty(t, result)
 }
 
-func TestGetWishlistCards_MainboardCardBelowMinimum_IsIncluded(t *testing.T) {
-	db := newTestDatabase(t)
+func TestGetWishlistCards_UntypedCardBelowMinimum_IsIncluded(t *testing.T) {
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
 		"INSERT INTO cards (name, owned, mainboard) VALUES (?, ?, ?)",
-		"Luke Skywalker, Jedi Knight", database.MainboardMinimumOwned-1, 1,
+		"Luke Skywalker, Jedi Knight", database.UntypedMinimumOwned-1, 1,
 	)
 	require.NoError(t, err)
 
@@ -645,13 +783,13 @@ func TestGetWishlistCards_MainboardCardBelowMinimum_IsIncluded(t *testing.T) {
 	assert.Equal(t, "Luke Skywalker, Jedi Knight", result[0].Name)
 }
 
-func TestGetWishlistCards_MainboardCardAtMinimum_IsExcluded(t *testing.T) {
-	db := newTestDatabase(t)
+func TestGetWishlistCards_UntypedCardAtMinimum_IsExcluded(t *testing.T) {
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
 		"INSERT INTO cards (name, owned, mainboard) VALUES (?, ?, ?)",
-		"Luke Skywalker, Jedi Knight", database.MainboardMinimumOwned, 1,
+		"Luke Skywalker, Jedi Knight", database.UntypedMinimumOwned, 1,
 	)
 	require.NoError(t, err)
 
@@ -661,13 +799,13 @@ func TestGetWishlistCards_MainboardCardAtMinimum_IsExcluded(t *testing.T) {
 	assert.Empty(t, result)
 }
 
-func TestGetWishlistCards_NonMainboardCardBelowMinimum_IsIncluded(t *testing.T) {
-	db := newTestDatabase(t)
+func TestGetWishlistCards_EventBelowMinimum_IsIncluded(t *testing.T) {
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
-		"INSERT INTO cards (name, owned, mainboard) VALUES (?, ?, ?)",
-		"Darth Vader, Sith Lord", database.NonMainboardMinimumOwned-1, 0,
+		"INSERT INTO cards (name, owned, mainboard, card_type) VALUES (?, ?, ?, ?)",
+		"Force Choke", database.EventMinimumOwned-1, 1, "Event",
 	)
 	require.NoError(t, err)
 
@@ -675,16 +813,16 @@ func TestGetWishlistCards_NonMainboardCardBelowMinimum_IsIncluded(t *testing.T)
 
 	require.NoError(t, err)
 	require.Len(t, result, 1)
-	assert.Equal(t, "Darth Vader, Sith Lord", result[0].Name)
+	assert.Equal(t, "Force Choke", result[0].Name)
 }
 
-func TestGetWishlistCards_NonMainboardCardAtMinimum_IsExcluded(t *testing.T) {
-	db := newTestDatabase(t)
+func TestGetWishlistCards_LeaderAtMinimum_IsExcluded(t *testing.T) {
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
-		"INSERT INTO cards (name, owned, mainboard) VALUES (?, ?, ?)",
-		"Darth Vader, Sith Lord", database.NonMainboardMinimumOwned, 0,
+		"INSERT INTO cards (name, owned, mainboard, card_type) VALUES (?, ?, ?, ?)",
+		"Darth Vader, Dark Lord of the Sith", database.LeaderMinimumOwned, 0, "leader",
 	)
 	require.NoError(t, err)
 
@@ -695,7 +833,7 @@ func TestGetWishlistCards_NonMainboardCardAtMinimum_IsExcluded(t *testing.T) {
 }
 
 func TestGetWishlistCards_WithQueryFilter_ReturnsMatchingWishlistCards(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
@@ -713,7 +851,7 @@ func TestGetWishlistCards_WithQueryFilter_ReturnsMatchingWishlistCards(t *testin
 }
 
 func TestGetWishlistCards_WithQueryFilter_NoMatch_ReturnsEmptySlice(t *testing.T) {
-	db := newTestDatabase(t)
+	db := testdb.Open(t)
 	require.NoError(t, db.RunMigrations())
 
 	_, err := db.Connection().Exec(
@@ -727,3 +865,1852 @@ func TestGetWishlistCards_WithQueryFilter_NoMatch_ReturnsEmptySlice(t *testing.T
 	require.NoError(t, err)
 	assert.Empty(t, result)
 }
+
+func TestRunMigrations_AddsTimestampColumns(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	rows, err := db.Connection().Query("PRAGMA table_info(cards)")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	columns := map[string]bool{}
+	for rows.Next() {
+		var (
+			cid          int
+			name         string
+			dataType     string
+			notNull      int
+			defaultValue interface{}
+			primaryKey   int
+		)
+		require.NoError(t, rows.Scan(&cid, &name, &dataType, &notNull, &defaultValue, &primaryKey))
+		columns[name] = true
+	}
+	require.NoError(t, rows.Err())
+
+	assert.True(t, columns["created_at"], "expected created_at column")
+	assert.True(t, columns["updated_at"], "expected updated_at column")
+}
+
+func TestInsertCard_SetsCreatedAndUpdatedTimestamps(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel", Mainboard: true}))
+
+	result, err := db.SearchCards("Chewbacca")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.False(t, result[0].CreatedAt.IsZero(), "expected created_at to be set")
+	assert.Equal(t, result[0].CreatedAt, result[0].UpdatedAt)
+}
+
+func TestIncrementCardOwned_UpdatesUpdatedAt(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, created_at, updated_at) VALUES (?, ?, ?, ?)",
+		"Luke Skywalker, Jedi Knight", 0, "2024-01-01T00:00:00.000000000Z", "2024-01-01T00:00:00.000000000Z",
+	)
+	require.NoError(t, err)
+
+	result, err := db.SearchCards("Luke")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+
+	require.NoError(t, db.IncrementCardOwned(result[0].ID))
+
+	card, err := db.GetCardByID(result[0].ID)
+	require.NoError(t, err)
+	assert.True(t, card.UpdatedAt.After(card.CreatedAt), "expected updated_at to advance past created_at")
+}
+
+func TestDecrementCardOwned_AtZero_DoesNotUpdateUpdatedAt(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, created_at, updated_at) VALUES (?, ?, ?, ?)",
+		"Luke Skywalker, Jedi Knight", 0, "2024-01-01T00:00:00.000000000Z", "2024-01-01T00:00:00.000000000Z",
+	)
+	require.NoError(t, err)
+
+	result, err := db.SearchCards("Luke")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+
+	require.NoError(t, db.DecrementCardOwned(result[0].ID))
+
+	card, err := db.GetCardByID(result[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, card.CreatedAt, card.UpdatedAt, "expected updated_at to be unchanged when owned stays at 0")
+}
+
+func TestApplyOwnedOperation_AppliesEachOperationOnce(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	result, err := db.Connection().Exec("INSERT INTO cards (name, owned) VALUES (?, ?)", "Chewbacca, Hero of Kessel", 1)
+	require.NoError(t, err)
+	insertedID, err := result.LastInsertId()
+	require.NoError(t, err)
+	id := int(insertedID)
+	madeAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	before, after, applied, err := db.ApplyOwnedOperation(models.OwnedOperation{ID: "op-1", CardID: id, Action: models.AuditIncrement, MadeAt: madeAt})
+	require.NoError(t, err)
+	assert.True(t, applied)
+	assert.Equal(t, []int{1, 2}, []int{before, after})
+
+	before, after, applied, err = db.ApplyOwnedOperation(models.OwnedOperation{ID: "op-1", CardID: id, Action: models.AuditIncrement, MadeAt: madeAt})
+	require.NoError(t, err)
+	assert.False(t, applied, "expected a replayed operation to be skipped")
+	assert.Equal(t, []int{2, 2}, []int{before, after})
+
+	seen, err := db.OwnedOperationApplied("op-1")
+	require.NoError(t, err)
+	assert.True(t, seen)
+	seen, err = db.OwnedOperationApplied("op-2")
+	require.NoError(t, err)
+	assert.False(t, seen)
+
+	for _, operationID := range []string{"op-2", "op-3", "op-4"} {
+		_, after, applied, err = db.ApplyOwnedOperation(models.OwnedOperation{ID: operationID, CardID: id, Action: models.AuditDecrement, MadeAt: madeAt})
+		require.NoError(t, err)
+		assert.True(t, applied)
+	}
+	assert.Equal(t, 0, after, "expected the owned count to stop at 0")
+
+	_, _, _, err = db.ApplyOwnedOperation(models.OwnedOperation{ID: "op-5", CardID: id + 1, Action: models.AuditIncrement, MadeAt: madeAt})
+	assert.ErrorIs(t, err, database.ErrCardNotFound)
+
+	_, _, _, err = db.ApplyOwnedOperation(models.OwnedOperation{ID: "op-6", CardID: id, Action: "reset", MadeAt: madeAt})
+	assert.Error(t, err)
+}
+
+func TestGetRecentCards_Added_ReturnsNewestFirst(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, created_at, updated_at) VALUES (?, 0, ?, ?), (?, 0, ?, ?), (?, 0, NULL, NULL)",
+		"Older Card", "2024-01-01T00:00:00.000000000Z", "2024-01-01T00:00:00.000000000Z",
+		"Newer Card", "2024-02-01T00:00:00.000000000Z", "2024-02-01T00:00:00.000000000Z",
+		"Legacy Card",
+	)
+	require.NoError(t, err)
+
+	result, err := db.GetRecentCards(database.RecentAdded, 10)
+
+	require.NoError(t, err)
+	require.Len(t, result, 2, "expected cards without timestamps to be excluded")
+	assert.Equal(t, "Newer Card", result[0].Name)
+	assert.Equal(t, "Older Card", result[1].Name)
+}
+
+func TestGetRecentCards_Changed_ExcludesUnchangedCards(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, created_at, updated_at) VALUES (?, 0, ?, ?), (?, 1, ?, ?)",
+		"Untouched Card", "2024-01-01T00:00:00.000000000Z", "2024-01-01T00:00:00.000000000Z",
+		"Counted Card", "2024-01-01T00:00:00.000000000Z", "2024-03-01T00:00:00.000000000Z",
+	)
+	require.NoError(t, err)
+
+	result, err := db.GetRecentCards(database.RecentChanged, 10)
+
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "Counted Card", result[0].Name)
+}
+
+func TestGetRecentCards_RespectsLimit(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	for _, name := range []string{"Card A", "Card B", "Card C"} {
+		require.NoError(t, db.InsertCard(models.NewCard{Name: name, Mainboard: true}))
+	}
+
+	result, err := db.GetRecentCards(database.RecentAdded, 2)
+
+	require.NoError(t, err)
+	assert.Len(t, result, 2)
+}
+
+func TestGetRecentCards_UnknownKind_ReturnsError(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	result, err := db.GetRecentCards("removed", 10)
+
+	assert.Nil(t, result)
+	assert.ErrorContains(t, err, "unknown recent kind")
+}
+
+func TestGetRecentCards_ZeroLimit_ReturnsError(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	result, err := db.GetRecentCards(database.RecentAdded, 0)
+
+	assert.Nil(t, result)
+	assert.ErrorContains(t, err, "must be a positive integer")
+}
+
+func TestSetCardArchived_ArchivesAndRestoresCard(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	result, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?)",
+		"Luke Skywalker, Jedi Knight", 2,
+	)
+	require.NoError(t, err)
+	insertedID, err := result.LastInsertId()
+	require.NoError(t, err)
+
+	require.NoError(t, db.SetCardArchived(int(insertedID), true))
+
+	card, err := db.GetCardByID(int(insertedID))
+	require.NoError(t, err)
+	assert.True(t, card.Archived)
+	assert.Equal(t, 2, card.Owned, "expected owned count to be preserved when archiving")
+
+	require.NoError(t, db.SetCardArchived(int(insertedID), false))
+
+	card, err = db.GetCardByID(int(insertedID))
+	require.NoError(t, err)
+	assert.False(t, card.Archived)
+}
+
+func TestSetCardArchived_NonExistentID_ReturnsErrCardNotFound(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	err := db.SetCardArchived(99999, true)
+
+	assert.ErrorIs(t, err, database.ErrCardNotFound)
+}
+
+func TestSetCardArchived_ZeroID_ReturnsError(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	err := db.SetCardArchived(0, true)
+
+	assert.ErrorContains(t, err, "must be a positive integer")
+}
+
+func TestSearchCards_ExcludesArchivedCards(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, archived) VALUES (?, ?, ?), (?, ?, ?)",
+		"Luke Skywalker, Jedi Knight", 0, 0,
+		"Luke Skywalker, Rebel Hero", 0, 1,
+	)
+	require.NoError(t, err)
+
+	result, err := db.SearchCards("Luke")
+
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "Luke Skywalker, Jedi Knight", result[0].Name)
+}
+
+func TestGetWishlistCards_ExcludesArchivedCards(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, mainboard, archived) VALUES (?, ?, ?, ?)",
+		"Luke Skywalker, Jedi Knight", 0, 1, 1,
+	)
+	require.NoError(t, err)
+
+	result, err := db.GetWishlistCards("")
+
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestGetArchivedCards_ReturnsOnlyArchivedCards(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, archived) VALUES (?, ?, ?), (?, ?, ?), (?, ?, ?)",
+		"Luke Skywalker, Jedi Knight", 0, 0,
+		"Luke Skywalker, Rebel Hero", 0, 1,
+		"Chewbacca, Hero of Kessel", 0, 1,
+	)
+	require.NoError(t, err)
+
+	all, err := db.GetArchivedCards("")
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	filtered, err := db.GetArchivedCards("luke")
+	require.NoError(t, err)
+	require.Len(t, filtered, 1)
+	assert.Equal(t, "Luke Skywalker, Rebel Hero", filtered[0].Name)
+}
+
+func TestRunMigrations_CreatesCardAliasesTable(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	row := db.Connection().QueryRow(
+		"SELECT name FROM sqlite_master WHERE type='table' AND name='card_aliases'",
+	)
+
+	var tableName string
+	require.NoError(t, row.Scan(&tableName), "expected card_aliases table to exist in database")
+	assert.Equal(t, "card_aliases", tableName)
+}
+
+func TestDeleteCard_CascadesToItsAliases(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet"}))
+	cards, err := db.GetAllCards()
+	require.NoError(t, err)
+	require.NoError(t, db.AddCardAlias(cards[0].ID, "Chewie"))
+
+	_, err = db.Connection().Exec("DELETE FROM cards WHERE id = ?", cards[0].ID)
+	require.NoError(t, err)
+
+	var aliases int
+	require.NoError(t, db.Connection().QueryRow("SELECT COUNT(*) FROM card_aliases").Scan(&aliases))
+	assert.Zero(t, aliases, "expected foreign keys to be enforced")
+}
+
+func TestRunMigrations_DeletesOrphanedRows(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	// Rows like this were left behind while foreign keys were not enforced.
+	ctx := context.Background()
+	conn, err := db.Connection().Conn(ctx)
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF")
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, "INSERT INTO card_aliases (card_id, alias) VALUES (42, 'Chewie')")
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	require.NoError(t, db.RunMigrations())
+
+	var aliases int
+	require.NoError(t, db.Connection().QueryRow("SELECT COUNT(*) FROM card_aliases").Scan(&aliases))
+	assert.Zero(t, aliases)
+}
+
+func TestAddCardAlias_AliasIsReturnedByGetCardAliases(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+
+	result, err := db.SearchCards("Chewbacca")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+
+	require.NoError(t, db.AddCardAlias(result[0].ID, "Chewie"))
+	require.NoError(t, db.AddCardAlias(result[0].ID, "chewie"), "expected duplicate alias to be ignored")
+	require.NoError(t, db.AddCardAlias(result[0].ID, "Big Walking Carpet"))
+
+	aliases, err := db.GetCardAliases(result[0].ID)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Big Walking Carpet", "Chewie"}, aliases)
+}
+
+func TestAddCardAlias_NonExistentCard_ReturnsErrCardNotFound(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	err := db.AddCardAlias(99999, "Chewie")
+
+	assert.ErrorIs(t, err, database.ErrCardNotFound)
+}
+
+func TestAddCardAlias_EmptyAlias_ReturnsError(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	err := db.AddCardAlias(1, "")
+
+	assert.ErrorContains(t, err, "must not be empty")
+}
+
+func TestGetCardAliases_NoAliases_ReturnsEmptySlice(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+
+	result, err := db.SearchCards("Chewbacca")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+
+	aliases, err := db.GetCardAliases(result[0].ID)
+
+	require.NoError(t, err)
+	assert.NotNil(t, aliases)
+	assert.Empty(t, aliases)
+}
+
+func TestDeleteCardAlias_RemovesAlias(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+
+	result, err := db.SearchCards("Chewbacca")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	require.NoError(t, db.AddCardAlias(result[0].ID, "Chewie"))
+
+	require.NoError(t, db.DeleteCardAlias(result[0].ID, "CHEWIE"))
+
+	aliases, err := db.GetCardAliases(result[0].ID)
+	require.NoError(t, err)
+	assert.Empty(t, aliases)
+}
+
+func TestDeleteCardAlias_UnknownAlias_ReturnsErrAliasNotFound(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+
+	err := db.DeleteCardAlias(1, "Chewie")
+
+	assert.ErrorIs(t, err, database.ErrAliasNotFound)
+}
+
+func TestSearchCards_MatchesAlias(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Mainboard: true}))
+
+	result, err := db.SearchCards("Chewbacca")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	require.NoError(t, db.AddCardAlias(result[0].ID, "Chewie"))
+
+	result, err = db.SearchCards("chew")
+
+	require.NoError(t, err)
+	require.Len(t, result, 1, "expected a card matching by name and alias to be returned once")
+	assert.Equal(t, "Chewbacca, Walking Carpet", result[0].Name)
+
+	result, err = db.SearchCards("ewie")
+
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "Chewbacca, Walking Carpet", result[0].Name)
+}
+
+func TestGetWishlistCards_MatchesAlias(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+
+	result, err := db.SearchCards("Chewbacca")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	require.NoError(t, db.AddCardAlias(result[0].ID, "Chewie"))
+
+	wishlist, err := db.GetWishlistCards("Chewie")
+
+	require.NoError(t, err)
+	require.Len(t, wishlist, 1)
+	assert.Equal(t, "Chewbacca, Walking Carpet", wishlist[0].Name)
+}
+
+func TestInsertCard_StoresCardDetails(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	require.NoError(t, db.InsertCard(models.NewCard{
+		Name:      "Mace Windu, Party Crasher",
+		Mainboard: true,
+		Set:       "SOR",
+		Number:    "149",
+		Type:      "Unit",
+		Aspects:   "Aggression|Heroism",
+		Rarity:    "Legendary",
+	}))
+
+	result, err := db.SearchCards("Mace Windu")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "SOR", result[0].Set)
+	assert.Equal(t, "149", result[0].Number)
+	assert.Equal(t, "Unit", result[0].Type)
+	assert.Equal(t, "Aggression|Heroism", result[0].Aspects)
+	assert.Equal(t, "Legendary", result[0].Rarity)
+}
+
+func TestFillMissingCardDetails_CardWithoutSet_BackfillsDetails(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Mace Windu, Party Crasher", Mainboard: true}))
+
+	require.NoError(t, db.FillMissingCardDetails(models.NewCard{Name: "Mace Windu, Party Crasher", Set: "SOR", Number: "149", Rarity: "Legendary"}))
+
+	result, err := db.SearchCards("Mace Windu")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "SOR", result[0].Set)
+	assert.Equal(t, "149", result[0].Number)
+	assert.Equal(t, "Legendary", result[0].Rarity)
+}
+
+func TestFillMissingCardDetails_CardWithSet_IsNotOverwritten(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Mace Windu, Party Crasher", Mainboard: true, Set: "SOR", Number: "149"}))
+
+	require.NoError(t, db.FillMissingCardDetails(models.NewCard{Name: "Mace Windu, Party Crasher", Set: "LAW", Number: "001"}))
+
+	result, err := db.SearchCards("Mace Windu")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "SOR", result[0].Set)
+	assert.Equal(t, "149", result[0].Number)
+}
+
+func TestGetRandomCard_AppliesFilters(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Law Rare", Set: "LAW", Rarity: "Rare"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Law Common", Set: "LAW", Rarity: "Common"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Sor Rare", Set: "SOR", Rarity: "Rare"}))
+
+	for range 10 {
+		card, err := db.GetRandomCard(database.RandomCardFilter{Set: "law", Rarity: "rare"})
+		require.NoError(t, err)
+		assert.Equal(t, "Law Rare", card.Name)
+	}
+}
+
+func TestGetRandomCard_OwnedOnly_ExcludesUnownedCards(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned) VALUES (?, ?), (?, ?)",
+		"Owned Card", 2,
+		"Unowned Card", 0,
+	)
+	require.NoError(t, err)
+
+	for range 10 {
+		card, err := db.GetRandomCard(database.RandomCardFilter{OwnedOnly: true})
+		require.NoError(t, err)
+		assert.Equal(t, "Owned Card", card.Name)
+	}
+}
+
+func TestGetRandomCard_NoMatch_ReturnsErrCardNotFound(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	card, err := db.GetRandomCard(database.RandomCardFilter{Set: "LAW"})
+
+	assert.Nil(t, card)
+	assert.ErrorIs(t, err, database.ErrCardNotFound)
+}
+
+func TestGetCardsBySet_ReturnsOnlyCardsInSet(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Law Card", Set: "LAW"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Sor Card", Set: "SOR"}))
+
+	result, err := db.GetCardsBySet("law")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "Law Card", result[0].Name)
+
+	all, err := db.GetCardsBySet("")
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
+
+func TestGetOwnedCountsByName_IncludesArchivedCards(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, archived) VALUES (?, ?, ?), (?, ?, ?)",
+		"Luke Skywalker, Jedi Knight", 2, 0,
+		"Chewbacca, Hero of Kessel", 5, 1,
+	)
+	require.NoError(t, err)
+
+	counts, err := db.GetOwnedCountsByName()
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"Luke Skywalker, Jedi Knight": 2,
+		"Chewbacca, Hero of Kessel":   5,
+	}, counts)
+}
+
+func TestSetOwnedCountsByName_UpdatesMatchingCardsAndTimestamps(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel"}))
+
+	err := db.SetOwnedCountsByName(map[string]int{
+		"Luke Skywalker, Jedi Knight": 3,
+		"Unknown Card":                9,
+	})
+
+	require.NoError(t, err)
+	counts, err := db.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"Luke Skywalker, Jedi Knight": 3,
+		"Chewbacca, Hero of Kessel":   0,
+	}, counts)
+
+	changed, err := db.GetRecentCards(database.RecentChanged, 10)
+	require.NoError(t, err)
+	require.Len(t, changed, 1)
+	assert.Equal(t, "Luke Skywalker, Jedi Knight", changed[0].Name)
+}
+
+func TestSetOwnedCountsByName_NegativeCount_RollsBackAllUpdates(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel"}))
+
+	err := db.SetOwnedCountsByName(map[string]int{
+		"Luke Skywalker, Jedi Knight": 3,
+		"Chewbacca, Hero of Kessel":   -1,
+	})
+
+	require.Error(t, err)
+	counts, err := db.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, 0, counts["Luke Skywalker, Jedi Knight"])
+	assert.Equal(t, 0, counts["Chewbacca, Hero of Kessel"])
+}
+
+func TestGetCardsChangedSince_ZeroSince_ReturnsAllCardsIncludingArchived(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, archived) VALUES (?, ?), (?, ?)",
+		"Luke Skywalker, Jedi Knight", 0,
+		"Chewbacca, Hero of Kessel", 1,
+	)
+	require.NoError(t, err)
+
+	cards, err := db.GetCardsChangedSince(time.Time{})
+
+	require.NoError(t, err)
+	assert.Len(t, cards, 2)
+}
+
+func TestGetCardsChangedSince_ReturnsOnlyNewerChanges(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, created_at, updated_at) VALUES (?, ?, ?), (?, ?, ?)",
+		"Luke Skywalker, Jedi Knight", "2026-01-01T00:00:00.000000000Z", "2026-01-01T00:00:00.000000000Z",
+		"Chewbacca, Hero of Kessel", "2026-01-01T00:00:00.000000000Z", "2026-03-01T00:00:00.000000000Z",
+	)
+	require.NoError(t, err)
+
+	cards, err := db.GetCardsChangedSince(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+
+	require.NoError(t, err)
+	require.Len(t, cards, 1)
+	assert.Equal(t, "Chewbacca, Hero of Kessel", cards[0].Name)
+}
+
+func TestApplyRemoteCards_LastWriteWins(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, created_at, updated_at) VALUES (?, ?, ?, ?), (?, ?, ?, ?)",
+		"Luke Skywalker, Jedi Knight", 1, "2026-01-01T00:00:00.000000000Z", "2026-02-01T00:00:00.000000000Z",
+		"Chewbacca, Hero of Kessel", 1, "2026-01-01T00:00:00.000000000Z", "2026-02-01T00:00:00.000000000Z",
+	)
+	require.NoError(t, err)
+
+	result, err := db.ApplyRemoteCards([]models.Card{
+		{Name: "Luke Skywalker, Jedi Knight", Owned: 6, UpdatedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "Chewbacca, Hero of Kessel", Owned: 9, UpdatedAt: time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{Name: "Han Solo, Scoundrel", Owned: 2, Set: "SOR", CreatedAt: time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Applied)
+	assert.Equal(t, []database.RemoteOwnedChange{
+		{Name: "Luke Skywalker, Jedi Knight", From: 1, To: 6},
+		{Name: "Han Solo, Scoundrel", From: 0, To: 2},
+	}, result.OwnedChanges)
+
+	completions, err := db.GetRecentWishlistCompletions(10)
+	require.NoError(t, err)
+	require.Len(t, completions, 1, "expected Luke reaching the minimum to leave the wishlist")
+	assert.Equal(t, "Luke Skywalker, Jedi Knight", completions[0].Name)
+
+	counts, err := db.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"Luke Skywalker, Jedi Knight": 6,
+		"Chewbacca, Hero of Kessel":   1,
+		"Han Solo, Scoundrel":         2,
+	}, counts)
+
+	changed, err := db.GetCardsChangedSince(time.Date(2026, 2, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, changed, 1)
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), changed[0].UpdatedAt, "expected remote stamp to be kept")
+}
+
+func TestApplyRemoteCards_CopiesAttributesForSearch(t *testing.T) {
+	db := testdb.New(t)
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, created_at, updated_at) VALUES (?, 0, ?, ?)",
+		"Chewbacca, Hero of Kessel", "2026-01-01T00:00:00.000000000Z", "2026-01-01T00:00:00.000000000Z",
+	)
+	require.NoError(t, err)
+	cost, power, hp := 5, 4, 7
+
+	_, err = db.ApplyRemoteCards([]models.Card{
+		{Name: "Chewbacca, Hero of Kessel", Type: "Unit", Cost: &cost, Power: &power, HP: &hp, Traits: "Rebel|Wookiee", Text: "Ambush. When played, heal 2 damage.", UpdatedAt: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "Han Solo, Scoundrel", Type: "Unit", Cost: &cost, Traits: "Underworld", Text: "Raid 2.", CreatedAt: time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)},
+	})
+	require.NoError(t, err)
+
+	for query, want := range map[string][]string{
+		"cost:5":           {"Chewbacca, Hero of Kessel", "Han Solo, Scoundrel"},
+		"power:4":          {"Chewbacca, Hero of Kessel"},
+		"hp>=7":            {"Chewbacca, Hero of Kessel"},
+		"trait:wookiee":    {"Chewbacca, Hero of Kessel"},
+		"trait:underworld": {"Han Solo, Scoundrel"},
+		"text:ambush":      {"Chewbacca, Hero of Kessel"},
+		`text:"raid 2"`:    {"Han Solo, Scoundrel"},
+	} {
+		cards, err := db.SearchCards(query)
+		require.NoError(t, err, query)
+		names := make([]string, len(cards))
+		for i, card := range cards {
+			names[i] = card.Name
+		}
+		assert.ElementsMatch(t, want, names, query)
+	}
+}
+
+func TestApplyRemoteCards_InvalidCard_RollsBackAllChanges(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.ApplyRemoteCards([]models.Card{
+		{Name: "Han Solo, Scoundrel", Owned: 2},
+		{Name: "", Owned: 1},
+	})
+
+	require.Error(t, err)
+	exists, err := db.CardExistsByName("Han Solo, Scoundrel")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestSyncCursors_RoundTrip(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	cursors, err := db.GetSyncCursors("http://laptop:8080")
+	require.NoError(t, err)
+	assert.True(t, cursors.Remote.IsZero())
+	assert.True(t, cursors.Local.IsZero())
+
+	want := database.SyncCursors{
+		Remote: time.Date(2026, 3, 1, 0, 0, 0, 5, time.UTC),
+		Local:  time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, db.SetSyncCursors("http://laptop:8080", want))
+	require.NoError(t, db.SetSyncCursors("http://laptop:8080", want))
+
+	cursors, err = db.GetSyncCursors("http://laptop:8080")
+	require.NoError(t, err)
+	assert.Equal(t, want, cursors)
+}
+
+func TestSnapshotTo_WritesReadableCopy(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight"}))
+
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.db")
+	require.NoError(t, db.SnapshotTo(snapshotPath))
+
+	snapshot, err := database.New(snapshotPath)
+	require.NoError(t, err)
+	defer snapshot.Shutdown()
+
+	exists, err := snapshot.CardExistsByName("Luke Skywalker, Jedi Knight")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestSnapshotTo_EmptyPath_ReturnsError(t *testing.T) {
+	db := testdb.Open(t)
+
+	assert.Error(t, db.SnapshotTo(""))
+}
+
+func TestGetAllCards_IncludesArchivedCardsSortedByName(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, archived) VALUES (?, ?), (?, ?)",
+		"Luke Skywalker, Jedi Knight", 0,
+		"Chewbacca, Hero of Kessel", 1,
+	)
+	require.NoError(t, err)
+
+	cards, err := db.GetAllCards()
+
+	require.NoError(t, err)
+	require.Len(t, cards, 2)
+	assert.Equal(t, "Chewbacca, Hero of Kessel", cards[0].Name)
+	assert.Equal(t, "Luke Skywalker, Jedi Knight", cards[1].Name)
+}
+
+func TestSetCardImage_UpdatesImageWithoutTouchingUpdatedAt(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight"}))
+
+	before, err := db.GetCardByID(1)
+	require.NoError(t, err)
+
+	require.NoError(t, db.SetCardImage(1, "images/SOR005.png"))
+
+	after, err := db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "images/SOR005.png", after.Image)
+	assert.Equal(t, before.UpdatedAt, after.UpdatedAt)
+}
+
+func TestSetCardImage_NonExistentID_ReturnsErrCardNotFound(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	assert.ErrorIs(t, db.SetCardImage(99, "images/SOR005.png"), database.ErrCardNotFound)
+}
+
+func TestMarkCardImageFailed_FlagsCardUntilImageIsSet(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight"}))
+
+	require.NoError(t, db.MarkCardImageFailed(1))
+
+	failed, err := db.GetImageFailedCards()
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.True(t, failed[0].ImageFailed)
+
+	require.NoError(t, db.SetCardImage(1, "images/SOR005.png"))
+
+	failed, err = db.GetImageFailedCards()
+	require.NoError(t, err)
+	assert.Empty(t, failed)
+}
+
+func TestMarkCardImageFailed_NonExistentID_ReturnsErrCardNotFound(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	assert.ErrorIs(t, db.MarkCardImageFailed(99), database.ErrCardNotFound)
+}
+
+func TestSetCardThumbnail_StoresAndClearsThumbnail(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Thumbnail: "images/thumbs/SOR005.jpg"}))
+
+	card, err := db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "images/thumbs/SOR005.jpg", card.Thumbnail)
+
+	require.NoError(t, db.SetCardThumbnail(1, ""))
+
+	card, err = db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Empty(t, card.Thumbnail)
+
+	assert.ErrorIs(t, db.SetCardThumbnail(99, "x.jpg"), database.ErrCardNotFound)
+}
+
+func TestSetCardImageSource_StoresSource(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", ImageSource: "https://cdn.example.com/cards"}))
+
+	card, err := db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/cards", card.ImageSource)
+
+	require.NoError(t, db.SetCardImageSource(1, "https://mirror.example.com/cards"))
+
+	card, err = db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "https://mirror.example.com/cards", card.ImageSource)
+
+	assert.ErrorIs(t, db.SetCardImageSource(99, "x"), database.ErrCardNotFound)
+}
+
+func TestSetCardPriority_StoresPriorityAndTouchesUpdatedAt(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Darth Vader, Dark Lord of the Sith"}))
+
+	before, err := db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, database.PriorityNormal, before.Priority)
+
+	require.NoError(t, db.SetCardPriority(1, database.PriorityHigh))
+
+	after, err := db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, database.PriorityHigh, after.Priority)
+	assert.False(t, after.UpdatedAt.IsZero())
+}
+
+func TestSetCardPriority_UnknownPriority_ReturnsError(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Darth Vader, Dark Lord of the Sith"}))
+
+	assert.Error(t, db.SetCardPriority(1, 5))
+}
+
+func TestSetCardPriority_NonExistentID_ReturnsErrCardNotFound(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	assert.ErrorIs(t, db.SetCardPriority(99, database.PriorityLow), database.ErrCardNotFound)
+}
+
+func TestIncrementCardOwned_ReachingMinimum_RecordsWishlistCompletion(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Han Solo, Reluctant Hero", Type: models.CardTypeEvent}))
+
+	for range database.EventMinimumOwned - 1 {
+		require.NoError(t, db.IncrementCardOwned(1))
+	}
+
+	completions, err := db.GetRecentWishlistCompletions(10)
+	require.NoError(t, err)
+	assert.Empty(t, completions)
+
+	require.NoError(t, db.IncrementCardOwned(1))
+	require.NoError(t, db.IncrementCardOwned(1))
+
+	completions, err = db.GetRecentWishlistCompletions(10)
+	require.NoError(t, err)
+	require.Len(t, completions, 1, "expected only the increment that reached the minimum to count")
+	assert.Equal(t, 1, completions[0].CardID)
+	assert.Equal(t, "Han Solo, Reluctant Hero", completions[0].Name)
+	assert.False(t, completions[0].CompletedAt.IsZero())
+}
+
+func TestSetOwnedCountsByName_CrossingMinimum_RecordsWishlistCompletion(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Han Solo, Reluctant Hero", Mainboard: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{
+		"Han Solo, Reluctant Hero":  database.UntypedMinimumOwned + 2,
+		"Chewbacca, Walking Carpet": database.UntypedMinimumOwned - 1,
+	}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{
+		"Han Solo, Reluctant Hero": database.UntypedMinimumOwned,
+	}))
+
+	completions, err := db.GetUnnotifiedWishlistCompletions()
+	require.NoError(t, err)
+	require.Len(t, completions, 1)
+	assert.Equal(t, "Han Solo, Reluctant Hero", completions[0].Name)
+
+	require.NoError(t, db.MarkWishlistCompletionsNotified([]int{completions[0].ID}))
+
+	completions, err = db.GetUnnotifiedWishlistCompletions()
+	require.NoError(t, err)
+	assert.Empty(t, completions)
+}
+
+func TestGetWishlistCardGroups_BySet_GroupsCardsWithUnknownSetLast(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Faithful Friend", Mainboard: true, Set: "SOR"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Mystery Card", Mainboard: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Boba Fett, Collecting the Bounty", Mainboard: true, Set: "SHD"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Darth Vader, Commanding the First Legion", Mainboard: true, Set: "SOR"}))
+
+	groups, err := db.GetWishlistCardGroups("", database.GroupBySet)
+	require.NoError(t, err)
+	require.Len(t, groups, 3)
+
+	assert.Equal(t, "SHD", groups[0].Key)
+	assert.Len(t, groups[0].Cards, 1)
+	assert.Equal(t, "SOR", groups[1].Key)
+	assert.Len(t, groups[1].Cards, 2)
+	assert.Equal(t, "", groups[2].Key)
+	assert.Equal(t, "Mystery Card", groups[2].Cards[0].Name)
+}
+
+func TestGetWishlistCardGroups_WithQuery_FiltersBeforeGrouping(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Faithful Friend", Mainboard: true, Type: "Leader"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Darth Vader, Dark Lord of the Sith", Mainboard: true, Type: "Leader"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Vader's Lightsaber", Mainboard: true, Type: "Upgrade"}))
+
+	groups, err := db.GetWishlistCardGroups("vader", database.GroupByType)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, "Leader", groups[0].Key)
+	assert.Len(t, groups[0].Cards, 1)
+	assert.Equal(t, "Upgrade", groups[1].Key)
+}
+
+func TestGetWishlistCardGroups_UnknownGrouping_ReturnsError(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.GetWishlistCardGroups("", database.WishlistGrouping("rarity"))
+	assert.Error(t, err)
+}
+
+func TestGetExistingCardNames_ReturnsOnlyNamesInDatabase(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Faithful Friend"}))
+
+	existing, err := db.GetExistingCardNames([]string{"Luke Skywalker, Faithful Friend", "Darth Vader, Dark Lord of the Sith"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"Luke Skywalker, Faithful Friend": true}, existing)
+
+	existing, err = db.GetExistingCardNames(nil)
+	require.NoError(t, err)
+	assert.Empty(t, existing)
+}
+
+func TestInsertCards_ManyChunks_InsertsEveryCardWithAspects(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Echo Base", Aspects: "Command"}))
+
+	cards := make([]models.NewCard, 250)
+	for i := range cards {
+		cards[i] = models.NewCard{Name: fmt.Sprintf("Battlefield Marine %03d", i), Set: "SOR", Aspects: "Command|Heroism"}
+	}
+	cards[len(cards)-1].Aspects = "Vigilance"
+
+	require.NoError(t, db.InsertCards(cards))
+
+	allCards, err := db.GetAllCards()
+	require.NoError(t, err)
+	assert.Len(t, allCards, len(cards)+1)
+
+	heroic, err := db.SearchCards("aspect:heroism")
+	require.NoError(t, err)
+	assert.Len(t, heroic, len(cards)-1)
+
+	vigilant, err := db.SearchCards("aspect:vigilance")
+	require.NoError(t, err)
+	require.Len(t, vigilant, 1)
+	assert.Equal(t, "Battlefield Marine 249", vigilant[0].Name)
+}
+
+func TestInsertCards_InvalidCard_InsertsNothing(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	err := db.InsertCards([]models.NewCard{{Name: "Luke Skywalker, Faithful Friend"}, {Name: ""}})
+	assert.Error(t, err)
+
+	allCards, err := db.GetAllCards()
+	require.NoError(t, err)
+	assert.Empty(t, allCards)
+}
+
+func TestGetSettings_NothingSaved_ReturnsDefaults(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	settings, err := db.GetSettings()
+
+	require.NoError(t, err)
+	assert.Equal(t, database.DefaultSettings(), settings)
+	assert.Equal(t, database.DefaultSettings(), db.Settings())
+}
+
+func TestSaveSettings_PersistsAcrossConnections(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := database.New(filePath)
+	require.NoError(t, err)
+	require.NoError(t, db.RunMigrations())
+
+	saved := models.Settings{
+		LeaderMinimumOwned:  2,
+		BaseMinimumOwned:    1,
+		UnitMinimumOwned:    4,
+		EventMinimumOwned:   2,
+		UpgradeMinimumOwned: 2,
+		TokenMinimumOwned:   1,
+		UntypedMinimumOwned: 3,
+		DefaultSort:         string(database.SortByOwned),
+		Theme:               models.ThemeLight,
+		ItemsPerPage:        50,
+		ImportLenient:       true,
+		ImportUseOwnedCount: true,
+		DigestChannel:       models.DigestChannelEmail,
+		DigestTarget:        "collector@example.com",
+		DigestIntervalDays:  7,
+		DecrementFloor:      2,
+		MaxOwnedChange:      5,
+	}
+	require.NoError(t, db.SaveSettings(saved))
+	assert.Equal(t, saved, db.Settings())
+	require.NoError(t, db.Shutdown())
+
+	reopened, err := database.New(filePath)
+	require.NoError(t, err)
+	t.Cleanup(func() { reopened.Shutdown() })
+	require.NoError(t, reopened.RunMigrations())
+
+	assert.Equal(t, saved, reopened.Settings())
+}
+
+func TestSaveSettings_InvalidValues_ReturnsErrorAndKeepsSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*models.Settings)
+	}{
+		{name: "zero unit minimum", modify: func(s *models.Settings) { s.UnitMinimumOwned = 0 }},
+		{name: "negative leader minimum", modify: func(s *models.Settings) { s.LeaderMinimumOwned = -1 }},
+		{name: "zero untyped minimum", modify: func(s *models.Settings) { s.UntypedMinimumOwned = 0 }},
+		{name: "unknown sort", modify: func(s *models.Settings) { s.DefaultSort = "price" }},
+		{name: "unknown theme", modify: func(s *models.Settings) { s.Theme = "neon" }},
+		{name: "negative items per page", modify: func(s *models.Settings) { s.ItemsPerPage = -5 }},
+		{name: "too many items per page", modify: func(s *models.Settings) { s.ItemsPerPage = 100000 }},
+		{name: "zero digest interval", modify: func(s *models.Settings) { s.DigestIntervalDays = 0 }},
+		{name: "unknown digest channel", modify: func(s *models.Settings) { s.DigestChannel = "pager" }},
+		{name: "ntfy digest without URL", modify: func(s *models.Settings) { s.DigestChannel = models.DigestChannelNtfy; s.DigestTarget = "my-topic" }},
+		{name: "email digest without address", modify: func(s *models.Settings) { s.DigestChannel = models.DigestChannelEmail }},
+		{name: "negative decrement floor", modify: func(s *models.Settings) { s.DecrementFloor = -1 }},
+		{name: "negative max owned change", modify: func(s *models.Settings) { s.MaxOwnedChange = -1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testdb.Open(t)
+			require.NoError(t, db.RunMigrations())
+
+			settings := database.DefaultSettings()
+			tt.modify(&settings)
+
+			assert.Error(t, db.SaveSettings(settings))
+			assert.Equal(t, database.DefaultSettings(), db.Settings())
+		})
+	}
+}
+
+func TestMinimumOwned_UsesThresholdForCardType(t *testing.T) {
+	settings := database.DefaultSettings()
+
+	assert.Equal(t, database.LeaderMinimumOwned, database.MinimumOwned(settings, "Leader"))
+	assert.Equal(t, database.BaseMinimumOwned, database.MinimumOwned(settings, "base"))
+	assert.Equal(t, database.UnitMinimumOwned, database.MinimumOwned(settings, "UNIT"))
+	assert.Equal(t, database.EventMinimumOwned, database.MinimumOwned(settings, "Event"))
+	assert.Equal(t, database.UntypedMinimumOwned, database.MinimumOwned(settings, ""))
+	assert.Equal(t, database.UntypedMinimumOwned, database.MinimumOwned(settings, "Battlefield"))
+}
+
+func TestSearchCards_PlaysetCompleteFlagAndFilter(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, card_type) VALUES (?, ?, ?), (?, ?, ?), (?, ?, ?)",
+		"Luke Skywalker, Faithful Friend", 1, "Leader",
+		"Battlefield Marine", 5, "Unit",
+		"Force Choke", 3, "Event",
+	)
+	require.NoError(t, err)
+
+	all, err := db.SearchCards("")
+	require.NoError(t, err)
+	complete := map[string]bool{}
+	for _, card := range all {
+		complete[card.Name] = card.PlaysetComplete
+	}
+	assert.Equal(t, map[string]bool{"Luke Skywalker, Faithful Friend": true, "Battlefield Marine": false, "Force Choke": true}, complete)
+
+	incomplete, err := db.SearchCards("complete:false")
+	require.NoError(t, err)
+	require.Len(t, incomplete, 1)
+	assert.Equal(t, "Battlefield Marine", incomplete[0].Name)
+
+	settings := db.Settings()
+	settings.UnitMinimumOwned = 5
+	require.NoError(t, db.SaveSettings(settings))
+
+	completed, err := db.SearchCards("complete:true")
+	require.NoError(t, err)
+	assert.Len(t, completed, 3)
+}
+
+func TestGetWishlistCards_UsesMinimumsFromSettings(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, mainboard, card_type) VALUES (?, ?, ?, ?), (?, ?, ?, ?)",
+		"Luke Skywalker, Jedi Knight", 2, 1, "",
+		"Darth Vader, Sith Lord", 1, 0, "Leader",
+	)
+	require.NoError(t, err)
+
+	settings := database.DefaultSettings()
+	settings.UntypedMinimumOwned = 2
+	settings.LeaderMinimumOwned = 2
+	require.NoError(t, db.SaveSettings(settings))
+
+	result, err := db.GetWishlistCards("")
+
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "Darth Vader, Sith Lord", result[0].Name)
+}
+
+func TestSearchCardsSorted_OrdersBySort(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Walking Carpet", Mainboard: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "anakin Skywalker, What It Takes", Mainboard: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Boba Fett, Daimyo", Mainboard: true}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{"Boba Fett, Daimyo": 3, "Chewbacca, Walking Carpet": 1}))
+
+	names := func(sort database.CardSort) []string {
+		cards, err := db.SearchCardsSorted("", sort)
+		require.NoError(t, err)
+		result := make([]string, len(cards))
+		for i, card := range cards {
+			result[i] = card.Name
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"anakin Skywalker, What It Takes", "Boba Fett, Daimyo", "Chewbacca, Walking Carpet"}, names(database.SortByName))
+	assert.Equal(t, []string{"Boba Fett, Daimyo", "Chewbacca, Walking Carpet", "anakin Skywalker, What It Takes"}, names(database.SortByOwned))
+
+	_, err := db.SearchCardsSorted("", "price")
+	assert.Error(t, err)
+}
+
+func TestSearchCardsSorted_SetNumber_OrdersLikeABinder(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	for _, card := range []models.NewCard{
+		{Name: "Token", Mainboard: true},
+		{Name: "Han Solo, Audacious Smuggler", Set: "SHD", Number: "10", Mainboard: true},
+		{Name: "Darth Vader, Dark Lord of the Sith", Set: "SOR", Number: "10", Mainboard: true},
+		{Name: "Experience", Set: "SOR", Number: "T01", Mainboard: true},
+		{Name: "Luke Skywalker, Faithful Friend", Set: "SOR", Number: "9", Mainboard: true},
+		{Name: "Bounty Hunter Crew", Set: "SHD", Number: "2", Mainboard: true},
+	} {
+		require.NoError(t, db.InsertCard(card))
+	}
+
+	cards, err := db.SearchCardsSorted("", database.SortBySetNumber)
+
+	require.NoError(t, err)
+	names := make([]string, len(cards))
+	for i, card := range cards {
+		names[i] = card.Name
+	}
+	assert.Equal(t, []string{
+		"Bounty Hunter Crew",
+		"Han Solo, Audacious Smuggler",
+		"Luke Skywalker, Faithful Friend",
+		"Darth Vader, Dark Lord of the Sith",
+		"Experience",
+		"Token",
+	}, names)
+}
+
+func TestIntegrityCheck_HealthyDatabase_ReturnsNoProblems(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	problems, err := db.IntegrityCheck()
+
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+}
+
+func TestVacuumAndReindex_Succeed(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight"}))
+
+	assert.NoError(t, db.Vacuum())
+	assert.NoError(t, db.Reindex())
+
+	size, err := db.DatabaseSize()
+	require.NoError(t, err)
+	assert.Positive(t, size)
+}
+
+func TestMergeDuplicateCards_FoldsDuplicatesIntoLowestID(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(`
+		INSERT INTO cards (id, name, owned, priority, archived, image, set_code) VALUES
+			(1, 'Luke Skywalker, Jedi Knight', 2, 0, 1, NULL, ''),
+			(2, 'Luke Skywalker, Jedi Knight', 3, 1, 0, 'images/LAW001.png', 'LAW'),
+			(3, 'Chewbacca, Hero of Kessel', 1, 0, 0, NULL, '')
+	`)
+	require.NoError(t, err)
+	require.NoError(t, db.AddCardAlias(2, "Luke"))
+	require.NoError(t, db.RecordAuditEvent(models.AuditEvent{Action: models.AuditIncrement, CardID: 2, CardName: "Luke Skywalker, Jedi Knight"}))
+
+	duplicates, err := db.GetDuplicateCards()
+	require.NoError(t, err)
+	assert.Equal(t, []database.DuplicateCards{{Name: "Luke Skywalker, Jedi Knight", IDs: []int{1, 2}}}, duplicates)
+
+	removed, err := db.MergeDuplicateCards()
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	card, err := db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, 5, card.Owned)
+	assert.Equal(t, database.PriorityHigh, card.Priority)
+	assert.False(t, card.Archived)
+	assert.Equal(t, "images/LAW001.png", card.Image)
+	assert.Equal(t, "LAW", card.Set)
+
+	_, err = db.GetCardByID(2)
+	assert.ErrorIs(t, err, database.ErrCardNotFound)
+
+	aliases, err := db.GetCardAliases(1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Luke"}, aliases)
+
+	events, err := db.GetAuditEvents(database.AuditFilter{}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, 1, events[0].CardID, "expected audit events to follow the merged card")
+
+	duplicates, err = db.GetDuplicateCards()
+	require.NoError(t, err)
+	assert.Empty(t, duplicates)
+}
+
+func TestGetAuditEvents_FiltersByCardUserAndTime(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	march := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	for _, event := range []models.AuditEvent{
+		{OccurredAt: march, User: "phone", Action: models.AuditIncrement, CardID: 1, CardName: "Luke Skywalker, Jedi Knight"},
+		{OccurredAt: march.Add(time.Hour), User: "phone", Action: models.AuditDecrement, CardID: 2, CardName: "Chewbacca, Hero of Kessel"},
+		{OccurredAt: march.AddDate(0, 1, 0), Action: models.AuditImport, Detail: "2 cards added"},
+	} {
+		require.NoError(t, db.RecordAuditEvent(event))
+	}
+
+	events, err := db.GetAuditEvents(database.AuditFilter{Card: "SKYWALKER"}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "phone", events[0].User)
+	assert.True(t, events[0].OccurredAt.Equal(march))
+
+	events, err = db.GetAuditEvents(database.AuditFilter{User: "phone"}, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.AuditDecrement, events[0].Action, "expected newest first")
+
+	count, err := db.CountAuditEvents(database.AuditFilter{From: march.Add(time.Minute), To: march.AddDate(0, 1, 1)})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	users, err := db.GetAuditUsers()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"phone"}, users)
+}
+
+func TestGetAuditDailyCounts_GroupsByUTCDay(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	for _, occurredAt := range []time.Time{
+		day.Add(time.Minute),
+		day.Add(23 * time.Hour),
+		day.Add(25 * time.Hour),
+		day.AddDate(0, 0, 5),
+	} {
+		require.NoError(t, db.RecordAuditEvent(models.AuditEvent{OccurredAt: occurredAt, Action: models.AuditIncrement}))
+	}
+
+	counts, err := db.GetAuditDailyCounts(day, day.AddDate(0, 0, 5))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"2024-03-10": 2, "2024-03-11": 1}, counts)
+}
+
+func TestRestoreFrom_ReplacesContentsWithSnapshot(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight"}))
+	settings := database.DefaultSettings()
+	settings.Theme = models.ThemeLight
+	require.NoError(t, db.SaveSettings(settings))
+
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.db")
+	require.NoError(t, db.SnapshotTo(snapshotPath))
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Chewbacca, Hero of Kessel"}))
+	require.NoError(t, db.SaveSettings(database.DefaultSettings()))
+
+	require.NoError(t, db.RestoreFrom(snapshotPath))
+
+	cards, err := db.GetAllCards()
+	require.NoError(t, err)
+	require.Len(t, cards, 1)
+	assert.Equal(t, "Luke Skywalker, Jedi Knight", cards[0].Name)
+	assert.Equal(t, models.ThemeLight, db.Settings().Theme)
+}
+
+func TestRestoreFrom_NotACollectionDatabase_ReturnsErrInvalidRestoreSource(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight"}))
+
+	sourcePath := filepath.Join(t.TempDir(), "source.db")
+	require.NoError(t, os.WriteFile(sourcePath, []byte("not a database"), 0o644))
+
+	err := db.RestoreFrom(sourcePath)
+
+	assert.ErrorIs(t, err, database.ErrInvalidRestoreSource)
+	exists, err := db.CardExistsByName("Luke Skywalker, Jedi Knight")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestSetSlowQueryThreshold_LogsSlowQueriesWithRedactedArgs(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	db.SetSlowQueryThreshold(time.Nanosecond)
+	_, err := db.CardExistsByName("Luke Skywalker, Jedi Knight")
+	require.NoError(t, err)
+
+	assert.Contains(t, logs.String(), "slow database query")
+	assert.Contains(t, logs.String(), "method=CardExistsByName")
+	assert.Contains(t, logs.String(), "string(27)")
+	assert.NotContains(t, logs.String(), "Luke Skywalker")
+
+	logs.Reset()
+	db.SetSlowQueryThreshold(0)
+	_, err = db.CardExistsByName("Luke Skywalker, Jedi Knight")
+	require.NoError(t, err)
+
+	assert.Empty(t, logs.String())
+}
+
+func TestCreateAPIToken_AuthenticatesUntilRevoked(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	token, secret, err := db.CreateAPIToken(" sync script ", []string{models.ScopeWrite, models.ScopeRead, models.ScopeRead}, models.RoleEditor)
+	require.NoError(t, err)
+	assert.Equal(t, "sync script", token.Name)
+	assert.Equal(t, []string{models.ScopeRead, models.ScopeWrite}, token.Scopes)
+	assert.Equal(t, models.RoleEditor, token.Role)
+	assert.Contains(t, secret, "swucol_")
+	assert.True(t, token.LastUsedAt.IsZero())
+
+	authenticated, err := db.AuthenticateAPIToken(secret)
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, authenticated.ID)
+	assert.False(t, authenticated.LastUsedAt.IsZero(), "expected last use to be recorded")
+
+	require.NoError(t, db.RevokeAPIToken(token.ID))
+
+	_, err = db.AuthenticateAPIToken(secret)
+	assert.ErrorIs(t, err, database.ErrAPITokenNotFound)
+
+	tokens, err := db.GetAPITokens()
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.False(t, tokens[0].RevokedAt.IsZero())
+}
+
+func TestAuthenticateAPIToken_RecordsUseOnlyWhenStale(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	token, secret, err := db.CreateAPIToken("sync script", []string{models.ScopeRead}, models.RoleViewer)
+	require.NoError(t, err)
+
+	_, err = db.Connection().Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", "2020-01-02T03:04:05.000000000Z", token.ID)
+	require.NoError(t, err)
+
+	first, err := db.AuthenticateAPIToken(secret)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), first.LastUsedAt, time.Minute, "expected a stale last use to be recorded again")
+
+	_, err = db.Connection().Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", first.LastUsedAt.Add(-time.Second).UTC().Format("2006-01-02T15:04:05.000000000Z07:00"), token.ID)
+	require.NoError(t, err)
+
+	second, err := db.AuthenticateAPIToken(secret)
+	require.NoError(t, err)
+	assert.Equal(t, first.LastUsedAt.Add(-time.Second), second.LastUsedAt, "expected a recent last use to be kept")
+}
+
+func TestHasActiveAPITokens(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	active, err := db.HasActiveAPITokens()
+	require.NoError(t, err)
+	assert.False(t, active)
+
+	token, _, err := db.CreateAPIToken("sync script", []string{models.ScopeRead}, models.RoleViewer)
+	require.NoError(t, err)
+	active, err = db.HasActiveAPITokens()
+	require.NoError(t, err)
+	assert.True(t, active)
+
+	require.NoError(t, db.RevokeAPIToken(token.ID))
+	active, err = db.HasActiveAPITokens()
+	require.NoError(t, err)
+	assert.False(t, active, "expected revoked tokens not to count")
+}
+
+func TestAuthenticateAPIToken_UnknownSecret_ReturnsNotFound(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.AuthenticateAPIToken("swucol_UNKNOWN")
+
+	assert.ErrorIs(t, err, database.ErrAPITokenNotFound)
+}
+
+func TestRevokeAPIToken_UnknownID_ReturnsNotFound(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	assert.ErrorIs(t, db.RevokeAPIToken(42), database.ErrAPITokenNotFound)
+}
+
+func TestValidateAPIToken_RejectsMissingNameAndUnknownScopesOrRole(t *testing.T) {
+	assert.NoError(t, database.ValidateAPIToken("script", []string{models.ScopeImport}, models.RoleAdmin))
+	assert.Error(t, database.ValidateAPIToken(" ", []string{models.ScopeRead}, models.RoleViewer))
+	assert.Error(t, database.ValidateAPIToken("script", nil, models.RoleViewer))
+	assert.Error(t, database.ValidateAPIToken("script", []string{"admin"}, models.RoleAdmin))
+	assert.Error(t, database.ValidateAPIToken("script", []string{models.ScopeRead}, "owner"))
+	assert.Error(t, database.ValidateAPIToken("script", []string{models.ScopeRead}, ""))
+}
+
+func TestGetSetCodes_ReturnsDistinctSetsOfActiveCards(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, set_code, archived) VALUES ('A', 'SOR', 0), ('B', 'SHD', 0), ('C', 'SOR', 0), ('D', 'TWI', 1), ('E', '', 0)",
+	)
+	require.NoError(t, err)
+
+	sets, err := db.GetSetCodes()
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SHD", "SOR"}, sets)
+}
+
+func TestInventoryItems_CreateAdjustUpdateDelete(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	created, err := db.CreateInventoryItem(models.InventoryItem{Name: " Dragon Shield Matte Black ", Kind: models.ItemKindSleeves, Quantity: 2})
+	require.NoError(t, err)
+	assert.Positive(t, created.ID)
+	assert.Equal(t, "Dragon Shield Matte Black", created.Name)
+	assert.False(t, created.CreatedAt.IsZero())
+
+	adjusted, err := db.AdjustInventoryItemQuantity(created.ID, -5)
+	require.NoError(t, err)
+	assert.Equal(t, 0, adjusted.Quantity)
+
+	created.Name = "Dragon Shield Matte Red"
+	created.Quantity = 3
+	updated, err := db.UpdateInventoryItem(created)
+	require.NoError(t, err)
+	assert.Equal(t, "Dragon Shield Matte Red", updated.Name)
+	assert.Equal(t, 3, updated.Quantity)
+
+	items, err := db.GetInventoryItems()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, updated.Name, items[0].Name)
+
+	require.NoError(t, db.DeleteInventoryItem(created.ID))
+	assert.ErrorIs(t, db.DeleteInventoryItem(created.ID), database.ErrInventoryItemNotFound)
+
+	items, err = db.GetInventoryItems()
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestUpdateInventoryItem_UnknownID_ReturnsNotFound(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.UpdateInventoryItem(models.InventoryItem{ID: 42, Name: "Playmat", Kind: models.ItemKindPlaymat})
+
+	assert.ErrorIs(t, err, database.ErrInventoryItemNotFound)
+}
+
+func TestValidateInventoryItem_RejectsMissingNameUnknownKindAndNegativeQuantity(t *testing.T) {
+	assert.NoError(t, database.ValidateInventoryItem(models.InventoryItem{Name: "Deck box", Kind: models.ItemKindDeckBox}))
+	assert.Error(t, database.ValidateInventoryItem(models.InventoryItem{Name: " ", Kind: models.ItemKindDeckBox}))
+	assert.Error(t, database.ValidateInventoryItem(models.InventoryItem{Name: "Binder", Kind: "binder"}))
+	assert.Error(t, database.ValidateInventoryItem(models.InventoryItem{Name: "Deck box", Kind: models.ItemKindDeckBox, Quantity: -1}))
+}
+
+func TestGetDeckStats_TotalsEventsPerDeckBestFirst(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	for _, event := range []models.Event{
+		{Name: "Store Showdown", PlayedOn: "2026-03-01", Deck: "Sabine Aggro", Wins: 2, Losses: 2},
+		{Name: "Planetary Qualifier", PlayedOn: "2026-04-12", Deck: "sabine aggro", Wins: 4, Losses: 1, Draws: 1},
+		{Name: "Store Showdown", PlayedOn: "2026-05-03", Deck: "Han Control", Wins: 3},
+	} {
+		_, err := db.CreateEvent(event)
+		require.NoError(t, err)
+	}
+
+	stats, err := db.GetDeckStats()
+
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	assert.Equal(t, "Han Control", stats[0].Deck)
+	assert.Equal(t, 1.0, stats[0].WinRate)
+	assert.Equal(t, 2, stats[1].Events)
+	assert.Equal(t, 6, stats[1].Wins)
+	assert.Equal(t, 3, stats[1].Losses)
+	assert.InDelta(t, 0.6, stats[1].WinRate, 0.0001)
+
+	events, err := db.GetEvents()
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, "2026-05-03", events[0].PlayedOn)
+}
+
+func TestValidateEvent_RejectsMissingFieldsBadDateAndNegativeRecord(t *testing.T) {
+	valid := models.Event{Name: "Store Showdown", PlayedOn: "2026-03-01", Deck: "Sabine Aggro", Wins: 1}
+	assert.NoError(t, database.ValidateEvent(valid))
+
+	for name, mutate := range map[string]func(*models.Event){
+		"name":   func(event *models.Event) { event.Name = " " },
+		"deck":   func(event *models.Event) { event.Deck = "" },
+		"date":   func(event *models.Event) { event.PlayedOn = "01/03/2026" },
+		"record": func(event *models.Event) { event.Losses = -1 },
+	} {
+		event := valid
+		mutate(&event)
+		assert.Error(t, database.ValidateEvent(event), name)
+	}
+}
+
+func TestSetCubeCardCount_BoundsCopiesAcrossCubesByOwned(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec("INSERT INTO cards (name, owned) VALUES ('Luke Skywalker, Jedi Knight', 3), ('Darth Vader, Dark Lord of the Sith', 1)")
+	require.NoError(t, err)
+
+	first, err := db.CreateCube("Rebels")
+	require.NoError(t, err)
+	second, err := db.CreateCube("Mixed")
+	require.NoError(t, err)
+
+	require.NoError(t, db.SetCubeCardCount(first.ID, 1, 2))
+	assert.ErrorIs(t, db.SetCubeCardCount(second.ID, 1, 2), database.ErrCubeCountExceedsOwned)
+	require.NoError(t, db.SetCubeCardCount(second.ID, 1, 1))
+	require.NoError(t, db.SetCubeCardCount(first.ID, 1, 2), "setting the same count again must not count the cube's own copies twice")
+
+	assert.ErrorIs(t, db.SetCubeCardCount(42, 1, 1), database.ErrCubeNotFound)
+	assert.ErrorIs(t, db.SetCubeCardCount(first.ID, 42, 1), database.ErrCardNotFound)
+
+	cards, err := db.GetCubeCards(first.ID)
+	require.NoError(t, err)
+	require.Len(t, cards, 1)
+	assert.Equal(t, 2, cards[0].Count)
+
+	cube, err := db.GetCube(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cube.CardCount)
+
+	uncubed, err := db.GetUncubedCards()
+	require.NoError(t, err)
+	require.Len(t, uncubed, 1)
+	assert.Equal(t, "Darth Vader, Dark Lord of the Sith", uncubed[0].Name)
+
+	require.NoError(t, db.SetCubeCardCount(second.ID, 1, 0))
+	require.NoError(t, db.DeleteCube(first.ID))
+	assert.ErrorIs(t, db.DeleteCube(first.ID), database.ErrCubeNotFound)
+
+	uncubed, err = db.GetUncubedCards()
+	require.NoError(t, err)
+	assert.Len(t, uncubed, 2)
+}
+
+func TestGetExcessCards_UsesMinimumOwnedSettings(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec("INSERT INTO cards (name, owned, mainboard, card_type) VALUES ('A', 4, 1, 'Unit'), ('B', 4, 1, 'Event')")
+	require.NoError(t, err)
+
+	excess, err := db.GetExcessCards()
+	require.NoError(t, err)
+	require.Len(t, excess, 1)
+	assert.Equal(t, "B", excess[0].Name)
+
+	settings := db.Settings()
+	settings.UnitMinimumOwned = 2
+	require.NoError(t, db.SaveSettings(settings))
+
+	excess, err = db.GetExcessCards()
+	require.NoError(t, err)
+	assert.Len(t, excess, 2)
+}
+
+func TestBulkUpdateCards_ArchivesOnlyMatchingActiveCards(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	_, err := db.Connection().Exec("INSERT INTO cards (name, archived) VALUES ('Luke Skywalker, Jedi Knight', 0), ('Luke Skywalker, Faithful Friend', 1), ('Chewbacca, Hero of Kessel', 0)")
+	require.NoError(t, err)
+
+	updated, err := db.BulkUpdateCards("luke", database.BulkArchive, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, updated)
+
+	remaining, err := db.SearchCards("")
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "Chewbacca, Hero of Kessel", remaining[0].Name)
+
+	_, err = db.BulkUpdateCards("", database.BulkAction("delete"), false)
+	assert.Error(t, err)
+}
+
+func TestMarkSetUnreleased_FlagsCardsAndLeavesThemOutOfSnapshots(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	require.NoError(t, db.InsertCards([]models.NewCard{
+		{Name: "Preview Card", Set: "NEW"},
+		{Name: "Released Card", Set: "SOR"},
+		{Name: "Old Preview Card", Set: "OLD"},
+	}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{"Preview Card": 2, "Released Card": 3, "Old Preview Card": 1}))
+	require.NoError(t, db.MarkSetUnreleased("new", "2999-01-01"))
+	require.NoError(t, db.MarkSetUnreleased("OLD", "2000-01-01"))
+
+	result, err := db.SearchCards("unreleased:yes")
+	require.NoError(t, err)
+	require.Len(t, result, 1, "a set whose release date has passed counts as released")
+	assert.Equal(t, "Preview Card", result[0].Name)
+	assert.True(t, result[0].Unreleased)
+
+	sets, err := db.GetUnreleasedSets()
+	require.NoError(t, err)
+	assert.Equal(t, []models.UnreleasedSet{{Set: "NEW", ReleaseDate: "2999-01-01"}}, sets)
+
+	snapshot, err := db.CreateCollectionSnapshot()
+	require.NoError(t, err)
+	assert.Equal(t, 4, snapshot.TotalOwned)
+
+	require.NoError(t, db.ReleaseSet("NEW"))
+	result, err = db.SearchCards("Preview Card -unreleased:yes")
+	require.NoError(t, err)
+	assert.Len(t, result, 2)
+	assert.ErrorIs(t, db.ReleaseSet("NEW"), database.ErrSetNotUnreleased)
+
+	assert.Error(t, db.MarkSetUnreleased("NEW", "next week"))
+}
+
+func TestCart_SetRemoveAndClear(t *testing.T) {
+	db := testdb.Open(t)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.InsertCards([]models.NewCard{{Name: "Battlefield Marine", Set: "SOR"}, {Name: "Admiral Ackbar", Set: "SOR"}}))
+
+	require.NoError(t, db.SetCartQuantity("session-a", 1, 2))
+	require.NoError(t, db.SetCartQuantity("session-a", 1, 4))
+	require.NoError(t, db.SetCartQuantity("session-a", 2, 1))
+	require.NoError(t, db.SetCartQuantity("session-b", 1, 1))
+	assert.ErrorIs(t, db.SetCartQuantity("session-a", 99, 1), database.ErrCardNotFound)
+
+	items, err := db.GetCart("session-a")
+	require.NoError(t, err)
+	assert.Equal(t, []models.CartItem{
+		{CardID: 2, Name: "Admiral Ackbar", Set: "SOR", Quantity: 1},
+		{CardID: 1, Name: "Battlefield Marine", Set: "SOR", Quantity: 4},
+	}, items)
+
+	require.NoError(t, db.RemoveFromCart("session-a", 2))
+	assert.ErrorIs(t, db.RemoveFromCart("session-a", 2), database.ErrCartItemNotFound)
+
+	removed, err := db.ClearCart("session-a")
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	items, err = db.GetCart("session-b")
+	require.NoError(t, err)
+	assert.Len(t, items, 1, "clearing one session's cart leaves others alone")
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"swucol/models"
+)
+
+// defaultDigestIntervalDays is the DigestIntervalDays used until settings are
+// first saved: one digest a week.
+const defaultDigestIntervalDays = 7
+
+// maxDigestIntervalDays is the largest DigestIntervalDays ValidateSettings
+// accepts.
+const maxDigestIntervalDays = 90
+
+// validateDigestTarget checks that target suits channel: an http(s) topic URL
+// for ntfy and a single address for email. Any target is accepted while
+// digests are off so that it survives switching them off and on again.
+func validateDigestTarget(channel, target string) error {
+	switch channel {
+	case "":
+		return nil
+	case models.DigestChannelNtfy:
+		parsed, err := url.Parse(target)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return errors.New("digest_target must be an http or https ntfy topic URL")
+		}
+		return nil
+	case models.DigestChannelEmail:
+		if _, err := mail.ParseAddress(target); err != nil {
+			return errors.New("digest_target must be an email address")
+		}
+		return nil
+	default:
+		return errors.New("digest_channel must be one of: ntfy, email")
+	}
+}
+
+// GetDigest summarises the collection changes made after since and up to
+// until: cards added, cards whose counts or flags changed, and wishlist
+// completions, along with the number of cards still on the wishlist. Each
+// name list is cut to limit entries, newest first; the counts are not.
+// Archived cards are left out of the added and changed lists.
+func (database *Database) GetDigest(since, until time.Time, limit int) (models.Digest, error) {
+	from := since.UTC().Format(timestampLayout)
+	to := until.UTC().Format(timestampLayout)
+
+	digest := models.Digest{Since: since, Until: until}
+
+	lists := []struct {
+		statement string
+		args      []any
+		count     *int
+		names     *[]string
+	}{
+		{
+			statement: "SELECT name FROM cards WHERE archived = 0 AND created_at > ? AND created_at <= ? ORDER BY created_at DESC, name",
+			args:      []any{from, to},
+			count:     &digest.AddedCount,
+			names:     &digest.Added,
+		},
+		{
+			statement: "SELECT name FROM cards WHERE archived = 0 AND updated_at > ? AND updated_at <= ? AND created_at <= ? ORDER BY updated_at DESC, name",
+			args:      []any{from, to, from},
+			count:     &digest.ChangedCount,
+			names:     &digest.Changed,
+		},
+		{
+			statement: "SELECT cards.name FROM wishlist_completions JOIN cards ON cards.id = wishlist_completions.card_id WHERE wishlist_completions.completed_at > ? AND wishlist_completions.completed_at <= ? ORDER BY wishlist_completions.completed_at DESC, cards.name",
+			args:      []any{from, to},
+			count:     &digest.CompletedCount,
+			names:     &digest.Completed,
+		},
+	}
+
+	for _, list := range lists {
+		names, err := database.queryNames(list.statement, list.args...)
+		if err != nil {
+			return models.Digest{}, fmt.Errorf("get digest: %w", err)
+		}
+
+		*list.count = len(names)
+		if len(names) > limit {
+			names = names[:limit]
+		}
+		*list.names = names
+	}
+
+	err := database.connection.QueryRow(
+		"SELECT COUNT(*) FROM cards WHERE "+wishlistClause+" AND NOT "+unreleasedClause,
+		database.minimumOwnedArgs()...,
+	).Scan(&digest.WishlistRemaining)
+	if err != nil {
+		return models.Digest{}, fmt.Errorf("get digest: count wishlist: %w", err)
+	}
+
+	return digest, nil
+}
+
+// queryNames runs query, which must select a single text column, and returns
+// the values in order. Returns an empty slice (never nil) when no rows match.
+func (database *Database) queryNames(query string, args ...any) ([]string, error) {
+	rows, err := database.connection.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate: %w", err)
+	}
+
+	return names, nil
+}
+
+// GetLastDigestTime returns when the most recent digest was sent, or the zero
+// time when none has been.
+func (database *Database) GetLastDigestTime() (time.Time, error) {
+	var sentAt sql.NullString
+	if err := database.connection.QueryRow("SELECT MAX(sent_at) FROM digests").Scan(&sentAt); err != nil {
+		return time.Time{}, fmt.Errorf("get last digest time: %w", err)
+	}
+
+	sent, err := parseTimestamp(sentAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get last digest time: parse: %w", err)
+	}
+
+	return sent, nil
+}
+
+// RecordDigest notes that a digest was sent through channel at sentAt. The
+// next digest covers changes made after sentAt.
+func (database *Database) RecordDigest(channel string, sentAt time.Time) error {
+	_, err := database.connection.Exec(
+		"INSERT INTO digests (channel, sent_at) VALUES (?, ?)",
+		channel, sentAt.UTC().Format(timestampLayout),
+	)
+	if err != nil {
+		return fmt.Errorf("record digest: %w", err)
+	}
+
+	return nil
+}
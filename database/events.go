@@ -0,0 +1,163 @@
+package database
+
+import (
+	"cmp"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"swucol/models"
+)
+
+// ErrEventNotFound is returned when no event has the requested ID.
+var ErrEventNotFound = errors.New("event not found")
+
+// EventDateLayout is the form of Event.PlayedOn.
+const EventDateLayout = "2006-01-02"
+
+// ValidateEvent returns an error describing why event cannot be stored, or
+// nil when it can. The ID and creation time are ignored.
+func ValidateEvent(event models.Event) error {
+	if strings.TrimSpace(event.Name) == "" {
+		return errors.New("name is required")
+	}
+
+	if strings.TrimSpace(event.Deck) == "" {
+		return errors.New("deck is required")
+	}
+
+	if _, err := time.Parse(EventDateLayout, event.PlayedOn); err != nil {
+		return errors.New("played_on must be a date in YYYY-MM-DD form")
+	}
+
+	if event.Wins < 0 || event.Losses < 0 || event.Draws < 0 {
+		return errors.New("wins, losses and draws must not be negative")
+	}
+
+	return nil
+}
+
+// eventColumns is the column list read by scanEvent.
+const eventColumns = "id, name, played_on, deck, wins, losses, draws, notes, created_at"
+
+// scanEvent reads a row selecting eventColumns.
+func scanEvent(row rowScanner) (models.Event, error) {
+	var (
+		event     models.Event
+		createdAt sql.NullString
+	)
+	if err := row.Scan(&event.ID, &event.Name, &event.PlayedOn, &event.Deck, &event.Wins, &event.Losses, &event.Draws, &event.Notes, &createdAt); err != nil {
+		return models.Event{}, err
+	}
+
+	var err error
+	if event.CreatedAt, err = parseTimestamp(createdAt); err != nil {
+		return models.Event{}, err
+	}
+
+	return event, nil
+}
+
+// CreateEvent stores event and returns it with its ID and creation time set.
+// Returns an error if ValidateEvent rejects it.
+func (database *Database) CreateEvent(event models.Event) (models.Event, error) {
+	if err := ValidateEvent(event); err != nil {
+		return models.Event{}, fmt.Errorf("create event: %w", err)
+	}
+
+	created, err := scanEvent(database.connection.QueryRow(
+		"INSERT INTO events (name, played_on, deck, wins, losses, draws, notes, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING "+eventColumns,
+		strings.TrimSpace(event.Name), event.PlayedOn, strings.TrimSpace(event.Deck),
+		event.Wins, event.Losses, event.Draws, strings.TrimSpace(event.Notes), currentTimestamp(),
+	))
+	if err != nil {
+		return models.Event{}, fmt.Errorf("create event: %w", err)
+	}
+
+	return created, nil
+}
+
+// GetEvents returns every event, most recently played first. Returns an
+// empty slice (never nil) when there are none.
+func (database *Database) GetEvents() ([]models.Event, error) {
+	rows, err := database.connection.Query("SELECT " + eventColumns + " FROM events ORDER BY played_on DESC, id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("get events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]models.Event, 0)
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get events: scan: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get events: rows: %w", err)
+	}
+
+	return events, nil
+}
+
+// DeleteEvent removes the event with the given id. Returns ErrEventNotFound
+// if no event with that id exists.
+func (database *Database) DeleteEvent(id int) error {
+	result, err := database.connection.Exec("DELETE FROM events WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete event: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete event: rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrEventNotFound
+	}
+
+	return nil
+}
+
+// GetDeckStats totals the recorded events per deck, ordered by win rate and
+// then by deck name. Deck names are compared case-insensitively. Returns an
+// empty slice (never nil) when no events are recorded.
+func (database *Database) GetDeckStats() ([]models.DeckStats, error) {
+	rows, err := database.connection.Query(`
+		SELECT MIN(deck), COUNT(*), SUM(wins), SUM(losses), SUM(draws)
+		FROM events
+		GROUP BY deck COLLATE NOCASE
+		ORDER BY deck COLLATE NOCASE
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("get deck stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := make([]models.DeckStats, 0)
+	for rows.Next() {
+		var deck models.DeckStats
+		if err := rows.Scan(&deck.Deck, &deck.Events, &deck.Wins, &deck.Losses, &deck.Draws); err != nil {
+			return nil, fmt.Errorf("get deck stats: scan: %w", err)
+		}
+		if matches := deck.Wins + deck.Losses + deck.Draws; matches > 0 {
+			deck.WinRate = float64(deck.Wins) / float64(matches)
+		}
+		stats = append(stats, deck)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get deck stats: rows: %w", err)
+	}
+
+	slices.SortStableFunc(stats, func(a, b models.DeckStats) int {
+		return cmp.Compare(b.WinRate, a.WinRate)
+	})
+
+	return stats, nil
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"swucol/models"
+	"swucol/search"
+)
+
+// ErrGoalNotFound is returned when no goal has the requested ID.
+var ErrGoalNotFound = errors.New("goal not found")
+
+// ValidateGoal returns an error describing why goal cannot be stored, or nil
+// when it can. An empty query is allowed and covers every card; any other
+// query must parse.
+func ValidateGoal(goal models.Goal) error {
+	if strings.TrimSpace(goal.Name) == "" {
+		return errors.New("name is required")
+	}
+
+	if goal.Target < 1 {
+		return errors.New("target must be at least 1")
+	}
+
+	if _, err := search.Parse(goal.Query); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// goalColumns is the column list read by scanGoal.
+const goalColumns = "id, name, query, target, created_at"
+
+// scanGoal reads a row selecting goalColumns.
+func scanGoal(row rowScanner) (models.Goal, error) {
+	var (
+		goal      models.Goal
+		createdAt sql.NullString
+	)
+	if err := row.Scan(&goal.ID, &goal.Name, &goal.Query, &goal.Target, &createdAt); err != nil {
+		return models.Goal{}, err
+	}
+
+	var err error
+	if goal.CreatedAt, err = parseTimestamp(createdAt); err != nil {
+		return models.Goal{}, err
+	}
+
+	return goal, nil
+}
+
+// CreateGoal stores goal and returns it with its ID and creation time set.
+// Returns an error if ValidateGoal rejects it.
+func (database *Database) CreateGoal(goal models.Goal) (models.Goal, error) {
+	if err := ValidateGoal(goal); err != nil {
+		return models.Goal{}, fmt.Errorf("create goal: %w", err)
+	}
+
+	created, err := scanGoal(database.connection.QueryRow(
+		"INSERT INTO goals (name, query, target, created_at) VALUES (?, ?, ?, ?) RETURNING "+goalColumns,
+		strings.TrimSpace(goal.Name), strings.TrimSpace(goal.Query), goal.Target, currentTimestamp(),
+	))
+	if err != nil {
+		return models.Goal{}, fmt.Errorf("create goal: %w", err)
+	}
+
+	return created, nil
+}
+
+// GetGoals returns every goal in the order they were created. Returns an
+// empty slice (never nil) when there are none.
+func (database *Database) GetGoals() ([]models.Goal, error) {
+	rows, err := database.connection.Query("SELECT " + goalColumns + " FROM goals ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("get goals: %w", err)
+	}
+	defer rows.Close()
+
+	goals := make([]models.Goal, 0)
+	for rows.Next() {
+		goal, err := scanGoal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get goals: scan: %w", err)
+		}
+		goals = append(goals, goal)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get goals: rows: %w", err)
+	}
+
+	return goals, nil
+}
+
+// DeleteGoal removes the goal with the given id. Returns ErrGoalNotFound if
+// no goal with that id exists.
+func (database *Database) DeleteGoal(id int) error {
+	result, err := database.connection.Exec("DELETE FROM goals WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete goal: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete goal: rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrGoalNotFound
+	}
+
+	return nil
+}
+
+// GetGoalProgress returns the progress of every goal, in the order of
+// GetGoals. Like the other owned statistics, progress counts non-archived
+// cards outside unreleased sets only. Returns an empty slice (never nil) when
+// there are no goals.
+func (database *Database) GetGoalProgress() ([]models.GoalProgress, error) {
+	goals, err := database.GetGoals()
+	if err != nil {
+		return nil, fmt.Errorf("get goal progress: %w", err)
+	}
+
+	progress := make([]models.GoalProgress, 0, len(goals))
+	for _, goal := range goals {
+		condition, args, err := database.searchClause(goal.Query)
+		if err != nil {
+			return nil, fmt.Errorf("get goal progress: goal %d: %w", goal.ID, err)
+		}
+
+		statement := "SELECT COUNT(*), COALESCE(SUM(owned >= ?), 0), COALESCE(SUM(MIN(owned, ?)), 0) FROM cards WHERE archived = 0 AND NOT " + unreleasedClause
+		if condition != "" {
+			statement += " AND " + condition
+		}
+
+		entry := models.GoalProgress{Goal: goal}
+		if err := database.connection.QueryRow(statement, append([]any{goal.Target, goal.Target}, args...)...).Scan(
+			&entry.Cards, &entry.Complete, &entry.Copies,
+		); err != nil {
+			return nil, fmt.Errorf("get goal progress: goal %d: %w", goal.ID, err)
+		}
+
+		entry.Needed = entry.Cards * goal.Target
+		entry.Percent = 100
+		if entry.Needed > 0 {
+			entry.Percent = entry.Copies * 100 / entry.Needed
+		}
+
+		progress = append(progress, entry)
+	}
+
+	return progress, nil
+}
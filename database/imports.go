@@ -0,0 +1,129 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"swucol/models"
+)
+
+// ErrImportNotFound is returned when no import history entry has the
+// requested ID.
+var ErrImportNotFound = errors.New("import not found")
+
+// ErrImportFileNotKept is returned by GetImportFile when the import's file was
+// not stored.
+var ErrImportFileNotKept = errors.New("import file was not kept")
+
+// importRecordColumns is the column list read by scanImportRecord.
+const importRecordColumns = "id, source, file_name, mode, inserted, skipped_existing, skipped_duplicate, image_failures, row_errors, changed, error, file IS NOT NULL, started_at, finished_at"
+
+// scanImportRecord reads a row selecting importRecordColumns.
+func scanImportRecord(row rowScanner) (models.ImportRecord, error) {
+	var (
+		record     models.ImportRecord
+		startedAt  sql.NullString
+		finishedAt sql.NullString
+	)
+	if err := row.Scan(
+		&record.ID, &record.Source, &record.FileName, &record.Mode,
+		&record.Inserted, &record.SkippedExisting, &record.SkippedDuplicate, &record.ImageFailures, &record.RowErrors, &record.Changed,
+		&record.Error, &record.HasFile, &startedAt, &finishedAt,
+	); err != nil {
+		return models.ImportRecord{}, err
+	}
+
+	var err error
+	if record.StartedAt, err = parseTimestamp(startedAt); err != nil {
+		return models.ImportRecord{}, err
+	}
+	if record.FinishedAt, err = parseTimestamp(finishedAt); err != nil {
+		return models.ImportRecord{}, err
+	}
+
+	return record, nil
+}
+
+// CreateImportRecord adds record to the import history, storing file with it
+// unless file is nil, and returns it with its ID and HasFile set. The
+// record's ID and HasFile are ignored.
+func (database *Database) CreateImportRecord(record models.ImportRecord, file []byte) (models.ImportRecord, error) {
+	var storedFile any
+	if file != nil {
+		storedFile = file
+	}
+
+	created, err := scanImportRecord(database.connection.QueryRow(
+		`INSERT INTO imports (source, file_name, mode, inserted, skipped_existing, skipped_duplicate, image_failures, row_errors, changed, error, file, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING `+importRecordColumns,
+		record.Source, record.FileName, record.Mode,
+		record.Inserted, record.SkippedExisting, record.SkippedDuplicate, record.ImageFailures, record.RowErrors, record.Changed,
+		record.Error, storedFile, record.StartedAt.UTC().Format(timestampLayout), record.FinishedAt.UTC().Format(timestampLayout),
+	))
+	if err != nil {
+		return models.ImportRecord{}, fmt.Errorf("create import record: %w", err)
+	}
+
+	return created, nil
+}
+
+// GetImportRecords returns the limit most recent entries of the import
+// history, newest first. Returns an empty slice (never nil) when there are
+// none.
+func (database *Database) GetImportRecords(limit int) ([]models.ImportRecord, error) {
+	rows, err := database.connection.Query("SELECT "+importRecordColumns+" FROM imports ORDER BY started_at DESC, id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, fmt.Errorf("get import records: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]models.ImportRecord, 0)
+	for rows.Next() {
+		record, err := scanImportRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get import records: scan: %w", err)
+		}
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get import records: rows: %w", err)
+	}
+
+	return records, nil
+}
+
+// GetImportRecord returns the import history entry with the given ID, or
+// ErrImportNotFound if there is none.
+func (database *Database) GetImportRecord(id int) (models.ImportRecord, error) {
+	record, err := scanImportRecord(database.connection.QueryRow("SELECT "+importRecordColumns+" FROM imports WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ImportRecord{}, ErrImportNotFound
+	}
+	if err != nil {
+		return models.ImportRecord{}, fmt.Errorf("get import record: %w", err)
+	}
+
+	return record, nil
+}
+
+// GetImportFile returns the file stored with the import history entry with
+// the given ID. Returns ErrImportNotFound if there is no such entry, and
+// ErrImportFileNotKept if its file was not stored.
+func (database *Database) GetImportFile(id int) ([]byte, error) {
+	var file []byte
+	err := database.connection.QueryRow("SELECT file FROM imports WHERE id = ?", id).Scan(&file)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrImportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get import file: %w", err)
+	}
+
+	if file == nil {
+		return nil, ErrImportFileNotKept
+	}
+
+	return file, nil
+}
@@ -0,0 +1,295 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// DefaultSlowQueryThreshold is the query duration above which queries are
+// logged until SetSlowQueryThreshold is called.
+const DefaultSlowQueryThreshold = 100 * time.Millisecond
+
+// queryDurationBuckets are the upper bounds, in seconds, of the query
+// duration histogram buckets.
+var queryDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// methodPrefix is the function name prefix of Database methods in stack
+// frames, used to attribute queries to the method that ran them.
+var methodPrefix = reflect.TypeFor[Database]().PkgPath() + ".(*Database)."
+
+// queryHistogram is a Prometheus-style cumulative histogram of query
+// durations. counts[i] is the number of queries no longer than
+// queryDurationBuckets[i].
+type queryHistogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// queryError identifies a count of failed queries: the Database method that
+// ran them and the cause errorCause gave their error.
+type queryError struct {
+	method, cause string
+}
+
+// queryInstruments times every query run through an instrumentedDB or
+// instrumentedTx, logs slow ones, and keeps a duration histogram per Database
+// method and a count of failed queries per method and cause.
+type queryInstruments struct {
+	// slowThreshold is in nanoseconds; zero disables slow-query logging.
+	slowThreshold atomic.Int64
+
+	mutex      sync.Mutex
+	histograms map[string]*queryHistogram
+	errors     map[queryError]uint64
+}
+
+// newQueryInstruments returns instruments using DefaultSlowQueryThreshold.
+func newQueryInstruments() *queryInstruments {
+	instruments := &queryInstruments{histograms: make(map[string]*queryHistogram), errors: make(map[queryError]uint64)}
+	instruments.slowThreshold.Store(int64(DefaultSlowQueryThreshold))
+	return instruments
+}
+
+// observe records a query that started at start and logs it if it took
+// longer than the slow-query threshold. queryErr points to the error the
+// query returned, which is counted when set; it is nil for QueryRow, whose
+// errors only surface when the row is scanned.
+func (instruments *queryInstruments) observe(query string, args []any, start time.Time, queryErr *error) {
+	duration := time.Since(start)
+	method := callingMethod()
+
+	instruments.mutex.Lock()
+	histogram, ok := instruments.histograms[method]
+	if !ok {
+		histogram = &queryHistogram{counts: make([]uint64, len(queryDurationBuckets))}
+		instruments.histograms[method] = histogram
+	}
+	seconds := duration.Seconds()
+	for i, bound := range queryDurationBuckets {
+		if seconds <= bound {
+			histogram.counts[i]++
+		}
+	}
+	histogram.count++
+	histogram.sum += seconds
+	if queryErr != nil && *queryErr != nil {
+		instruments.errors[queryError{method: method, cause: errorCause(*queryErr)}]++
+	}
+	instruments.mutex.Unlock()
+
+	threshold := time.Duration(instruments.slowThreshold.Load())
+	if threshold > 0 && duration > threshold {
+		slog.Warn("slow database query",
+			"method", method,
+			"duration", duration,
+			"sql", strings.Join(strings.Fields(query), " "),
+			"args", redactArgs(args),
+		)
+	}
+}
+
+// errorCause classifies a query error by its SQLite result code: "busy",
+// "locked", "constraint", "full", "readonly", "io" or "corrupt", and "other"
+// for any other error, including those that did not come from SQLite.
+func errorCause(err error) string {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return "other"
+	}
+
+	// Extended result codes keep the primary code in their low byte.
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY:
+		return "busy"
+	case sqlite3.SQLITE_LOCKED:
+		return "locked"
+	case sqlite3.SQLITE_CONSTRAINT:
+		return "constraint"
+	case sqlite3.SQLITE_FULL:
+		return "full"
+	case sqlite3.SQLITE_READONLY:
+		return "readonly"
+	case sqlite3.SQLITE_IOERR:
+		return "io"
+	case sqlite3.SQLITE_CORRUPT:
+		return "corrupt"
+	}
+
+	return "other"
+}
+
+// callingMethod returns the name of the outermost exported Database method on
+// the stack nearest to the query, or "unknown" when the query did not run
+// inside one.
+func callingMethod() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if name, ok := strings.CutPrefix(frame.Function, methodPrefix); ok {
+			name, _, _ = strings.Cut(name, ".")
+			if name != "" && unicode.IsUpper(rune(name[0])) {
+				return name
+			}
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// redactArgs describes query arguments by type and size only, so that slow
+// query logs do not contain card names, URLs, or other stored values.
+func redactArgs(args []any) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		switch value := arg.(type) {
+		case nil:
+			redacted[i] = "nil"
+		case string:
+			redacted[i] = fmt.Sprintf("string(%d)", len(value))
+		case []byte:
+			redacted[i] = fmt.Sprintf("bytes(%d)", len(value))
+		default:
+			redacted[i] = fmt.Sprintf("%T", arg)
+		}
+	}
+	return redacted
+}
+
+// instrumentedDB is the write *sql.DB together with the read pool, whose
+// Exec, Query, QueryRow and Begin are timed. Query and QueryRow run SELECT
+// statements on the read pool and everything else, such as INSERT ...
+// RETURNING, on the write connection, as do Exec and Begin.
+type instrumentedDB struct {
+	*sql.DB
+	reader      *sql.DB
+	instruments *queryInstruments
+}
+
+// route returns the pool query should run on.
+func (db *instrumentedDB) route(query string) *sql.DB {
+	query = strings.TrimSpace(query)
+	if len(query) >= len("SELECT") && strings.EqualFold(query[:len("SELECT")], "SELECT") {
+		return db.reader
+	}
+	return db.DB
+}
+
+func (db *instrumentedDB) Exec(query string, args ...any) (result sql.Result, err error) {
+	defer db.instruments.observe(query, args, time.Now(), &err)
+	return db.DB.Exec(query, args...)
+}
+
+func (db *instrumentedDB) Query(query string, args ...any) (rows *sql.Rows, err error) {
+	defer db.instruments.observe(query, args, time.Now(), &err)
+	return db.route(query).Query(query, args...)
+}
+
+func (db *instrumentedDB) QueryRow(query string, args ...any) *sql.Row {
+	defer db.instruments.observe(query, args, time.Now(), nil)
+	return db.route(query).QueryRow(query, args...)
+}
+
+// Begin starts a transaction whose statements are timed as well.
+func (db *instrumentedDB) Begin() (*instrumentedTx, error) {
+	transaction, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedTx{Tx: transaction, instruments: db.instruments}, nil
+}
+
+// instrumentedTx is a *sql.Tx whose Exec, Query and QueryRow are timed.
+type instrumentedTx struct {
+	*sql.Tx
+	instruments *queryInstruments
+}
+
+func (transaction *instrumentedTx) Exec(query string, args ...any) (result sql.Result, err error) {
+	defer transaction.instruments.observe(query, args, time.Now(), &err)
+	return transaction.Tx.Exec(query, args...)
+}
+
+func (transaction *instrumentedTx) Query(query string, args ...any) (rows *sql.Rows, err error) {
+	defer transaction.instruments.observe(query, args, time.Now(), &err)
+	return transaction.Tx.Query(query, args...)
+}
+
+func (transaction *instrumentedTx) QueryRow(query string, args ...any) *sql.Row {
+	defer transaction.instruments.observe(query, args, time.Now(), nil)
+	return transaction.Tx.QueryRow(query, args...)
+}
+
+// SetSlowQueryThreshold sets the duration above which queries are logged
+// with their SQL and redacted arguments. A threshold of zero disables
+// slow-query logging.
+func (database *Database) SetSlowQueryThreshold(threshold time.Duration) {
+	database.connection.instruments.slowThreshold.Store(int64(threshold))
+}
+
+// WriteQueryMetrics writes the query duration histograms, one per Database
+// method, and the counts of failed queries by method and cause (see
+// errorCause) in the Prometheus text exposition format.
+func (database *Database) WriteQueryMetrics(writer io.Writer) error {
+	instruments := database.connection.instruments
+	instruments.mutex.Lock()
+	defer instruments.mutex.Unlock()
+
+	methods := make([]string, 0, len(instruments.histograms))
+	for method := range instruments.histograms {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	var builder strings.Builder
+	builder.WriteString("# HELP swucol_db_query_duration_seconds Duration of database queries by Database method.\n")
+	builder.WriteString("# TYPE swucol_db_query_duration_seconds histogram\n")
+	for _, method := range methods {
+		histogram := instruments.histograms[method]
+		for i, bound := range queryDurationBuckets {
+			fmt.Fprintf(&builder, "swucol_db_query_duration_seconds_bucket{method=%q,le=\"%g\"} %d\n", method, bound, histogram.counts[i])
+		}
+		fmt.Fprintf(&builder, "swucol_db_query_duration_seconds_bucket{method=%q,le=\"+Inf\"} %d\n", method, histogram.count)
+		fmt.Fprintf(&builder, "swucol_db_query_duration_seconds_sum{method=%q} %g\n", method, histogram.sum)
+		fmt.Fprintf(&builder, "swucol_db_query_duration_seconds_count{method=%q} %d\n", method, histogram.count)
+	}
+
+	failures := make([]queryError, 0, len(instruments.errors))
+	for failure := range instruments.errors {
+		failures = append(failures, failure)
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		if failures[i].method != failures[j].method {
+			return failures[i].method < failures[j].method
+		}
+		return failures[i].cause < failures[j].cause
+	})
+
+	builder.WriteString("# HELP swucol_db_errors_total Failed database queries by Database method and cause.\n")
+	builder.WriteString("# TYPE swucol_db_errors_total counter\n")
+	for _, failure := range failures {
+		fmt.Fprintf(&builder, "swucol_db_errors_total{method=%q,cause=%q} %d\n", failure.method, failure.cause, instruments.errors[failure])
+	}
+
+	if _, err := io.WriteString(writer, builder.String()); err != nil {
+		return fmt.Errorf("write query metrics: %w", err)
+	}
+
+	return nil
+}
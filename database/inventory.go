@@ -0,0 +1,170 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"swucol/models"
+)
+
+// ErrInventoryItemNotFound is returned when no inventory item has the
+// requested ID.
+var ErrInventoryItemNotFound = errors.New("inventory item not found")
+
+// InventoryItemKinds are the kinds an inventory item may have.
+var InventoryItemKinds = []string{
+	models.ItemKindSleeves,
+	models.ItemKindDeckBox,
+	models.ItemKindPlaymat,
+	models.ItemKindOther,
+}
+
+// ValidateInventoryItem returns an error describing why item cannot be
+// stored, or nil when it can. The ID and timestamps are ignored.
+func ValidateInventoryItem(item models.InventoryItem) error {
+	if strings.TrimSpace(item.Name) == "" {
+		return errors.New("name is required")
+	}
+
+	if !slices.Contains(InventoryItemKinds, item.Kind) {
+		return fmt.Errorf("kind must be one of: %s", strings.Join(InventoryItemKinds, ", "))
+	}
+
+	if item.Quantity < 0 {
+		return errors.New("quantity must not be negative")
+	}
+
+	return nil
+}
+
+// inventoryItemColumns is the column list read by scanInventoryItem.
+const inventoryItemColumns = "id, name, kind, quantity, notes, created_at, updated_at"
+
+// scanInventoryItem reads a row selecting inventoryItemColumns.
+func scanInventoryItem(row rowScanner) (models.InventoryItem, error) {
+	var (
+		item                 models.InventoryItem
+		createdAt, updatedAt sql.NullString
+	)
+	if err := row.Scan(&item.ID, &item.Name, &item.Kind, &item.Quantity, &item.Notes, &createdAt, &updatedAt); err != nil {
+		return models.InventoryItem{}, err
+	}
+
+	var err error
+	if item.CreatedAt, err = parseTimestamp(createdAt); err != nil {
+		return models.InventoryItem{}, err
+	}
+	if item.UpdatedAt, err = parseTimestamp(updatedAt); err != nil {
+		return models.InventoryItem{}, err
+	}
+
+	return item, nil
+}
+
+// CreateInventoryItem stores item and returns it with its ID and timestamps
+// set. Returns an error if ValidateInventoryItem rejects it.
+func (database *Database) CreateInventoryItem(item models.InventoryItem) (models.InventoryItem, error) {
+	if err := ValidateInventoryItem(item); err != nil {
+		return models.InventoryItem{}, fmt.Errorf("create inventory item: %w", err)
+	}
+
+	now := currentTimestamp()
+	created, err := scanInventoryItem(database.connection.QueryRow(
+		"INSERT INTO inventory_items (name, kind, quantity, notes, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING "+inventoryItemColumns,
+		strings.TrimSpace(item.Name), item.Kind, item.Quantity, strings.TrimSpace(item.Notes), now, now,
+	))
+	if err != nil {
+		return models.InventoryItem{}, fmt.Errorf("create inventory item: %w", err)
+	}
+
+	return created, nil
+}
+
+// GetInventoryItems returns every inventory item ordered by kind and name.
+// Returns an empty slice (never nil) when there are none.
+func (database *Database) GetInventoryItems() ([]models.InventoryItem, error) {
+	rows, err := database.connection.Query("SELECT " + inventoryItemColumns + " FROM inventory_items ORDER BY kind, name COLLATE NOCASE, id")
+	if err != nil {
+		return nil, fmt.Errorf("get inventory items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]models.InventoryItem, 0)
+	for rows.Next() {
+		item, err := scanInventoryItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get inventory items: scan: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get inventory items: rows: %w", err)
+	}
+
+	return items, nil
+}
+
+// UpdateInventoryItem replaces the name, kind, quantity and notes of the item
+// with item.ID and returns the stored item. Returns an error if
+// ValidateInventoryItem rejects it, and ErrInventoryItemNotFound if no item
+// with that ID exists.
+func (database *Database) UpdateInventoryItem(item models.InventoryItem) (models.InventoryItem, error) {
+	if err := ValidateInventoryItem(item); err != nil {
+		return models.InventoryItem{}, fmt.Errorf("update inventory item: %w", err)
+	}
+
+	updated, err := scanInventoryItem(database.connection.QueryRow(
+		"UPDATE inventory_items SET name = ?, kind = ?, quantity = ?, notes = ?, updated_at = ? WHERE id = ? RETURNING "+inventoryItemColumns,
+		strings.TrimSpace(item.Name), item.Kind, item.Quantity, strings.TrimSpace(item.Notes), currentTimestamp(), item.ID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.InventoryItem{}, ErrInventoryItemNotFound
+	}
+	if err != nil {
+		return models.InventoryItem{}, fmt.Errorf("update inventory item: %w", err)
+	}
+
+	return updated, nil
+}
+
+// AdjustInventoryItemQuantity adds delta, which may be negative, to the
+// quantity of the item with the given id and returns the stored item. The
+// quantity never drops below zero. Returns ErrInventoryItemNotFound if no item
+// with that id exists.
+func (database *Database) AdjustInventoryItemQuantity(id int, delta int) (models.InventoryItem, error) {
+	item, err := scanInventoryItem(database.connection.QueryRow(
+		"UPDATE inventory_items SET quantity = MAX(quantity + ?, 0), updated_at = ? WHERE id = ? RETURNING "+inventoryItemColumns,
+		delta, currentTimestamp(), id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.InventoryItem{}, ErrInventoryItemNotFound
+	}
+	if err != nil {
+		return models.InventoryItem{}, fmt.Errorf("adjust inventory item quantity: %w", err)
+	}
+
+	return item, nil
+}
+
+// DeleteInventoryItem removes the item with the given id. Returns
+// ErrInventoryItemNotFound if no item with that id exists.
+func (database *Database) DeleteInventoryItem(id int) error {
+	result, err := database.connection.Exec("DELETE FROM inventory_items WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete inventory item: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete inventory item: rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrInventoryItemNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"swucol/models"
+)
+
+// GetJobNextRun returns the next run time stored for the job name, or the
+// zero time when none is stored or it was stored for a schedule other than
+// schedule.
+func (database *Database) GetJobNextRun(name, schedule string) (time.Time, error) {
+	var nextRun sql.NullString
+	err := database.connection.QueryRow("SELECT next_run_at FROM jobs WHERE name = ? AND schedule = ?", name, schedule).Scan(&nextRun)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get job next run: %w", err)
+	}
+
+	next, err := parseTimestamp(nextRun)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get job next run: parse next_run_at: %w", err)
+	}
+
+	return next, nil
+}
+
+// SaveJob stores the job name's schedule and next run time, replacing those
+// stored before.
+func (database *Database) SaveJob(name, schedule string, nextRun time.Time) error {
+	_, err := database.connection.Exec(
+		"INSERT INTO jobs (name, schedule, next_run_at) VALUES (?, ?, ?) ON CONFLICT (name) DO UPDATE SET schedule = excluded.schedule, next_run_at = excluded.next_run_at",
+		name, schedule, formatTimestamp(nextRun),
+	)
+	if err != nil {
+		return fmt.Errorf("save job: %w", err)
+	}
+
+	return nil
+}
+
+// StartJobRun records that the job name started at startedAt, triggered as
+// trigger, and returns the run's ID for FinishJobRun.
+func (database *Database) StartJobRun(name, trigger string, startedAt time.Time) (int, error) {
+	var id int
+	err := database.connection.QueryRow(
+		"INSERT INTO job_runs (job_name, triggered_by, started_at) VALUES (?, ?, ?) RETURNING id",
+		name, trigger, formatTimestamp(startedAt),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("start job run: %w", err)
+	}
+
+	return id, nil
+}
+
+// FinishJobRun records that the run id finished at finishedAt, with runErr
+// as its error ("" when it succeeded).
+func (database *Database) FinishJobRun(id int, finishedAt time.Time, runErr string) error {
+	if _, err := database.connection.Exec("UPDATE job_runs SET finished_at = ?, error = ? WHERE id = ?", formatTimestamp(finishedAt), runErr, id); err != nil {
+		return fmt.Errorf("finish job run: %w", err)
+	}
+
+	return nil
+}
+
+// AbandonJobRuns finishes every run that never finished, such as those cut
+// short by a restart, at finishedAt with the error "interrupted". Returns the
+// number of runs finished.
+func (database *Database) AbandonJobRuns(finishedAt time.Time) (int, error) {
+	result, err := database.connection.Exec("UPDATE job_runs SET finished_at = ?, error = 'interrupted' WHERE finished_at IS NULL", formatTimestamp(finishedAt))
+	if err != nil {
+		return 0, fmt.Errorf("abandon job runs: %w", err)
+	}
+
+	abandoned, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("abandon job runs: rows affected: %w", err)
+	}
+
+	return int(abandoned), nil
+}
+
+// jobRunColumns is the column list read by scanJobRun.
+const jobRunColumns = "id, job_name, triggered_by, started_at, finished_at, error"
+
+// scanJobRun reads a row selecting jobRunColumns.
+func scanJobRun(row rowScanner) (models.JobRun, error) {
+	var (
+		run                   models.JobRun
+		startedAt, finishedAt sql.NullString
+	)
+	if err := row.Scan(&run.ID, &run.Job, &run.Trigger, &startedAt, &finishedAt, &run.Error); err != nil {
+		return models.JobRun{}, err
+	}
+
+	var err error
+	if run.StartedAt, err = parseTimestamp(startedAt); err != nil {
+		return models.JobRun{}, err
+	}
+	if finishedAt.Valid {
+		finished, err := parseTimestamp(finishedAt)
+		if err != nil {
+			return models.JobRun{}, err
+		}
+		run.FinishedAt = &finished
+	}
+
+	return run, nil
+}
+
+// GetJobRuns returns the most recent runs of the job name, newest first, at
+// most limit of them. Returns an empty slice (never nil) when it has none.
+func (database *Database) GetJobRuns(name string, limit int) ([]models.JobRun, error) {
+	rows, err := database.connection.Query("SELECT "+jobRunColumns+" FROM job_runs WHERE job_name = ? ORDER BY id DESC LIMIT ?", name, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get job runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []models.JobRun{}
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get job runs: scan: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get job runs: rows: %w", err)
+	}
+
+	return runs, nil
+}
+
+// GetLastJobRuns returns the most recent run of each job that has run, keyed
+// by job name.
+func (database *Database) GetLastJobRuns() (map[string]models.JobRun, error) {
+	rows, err := database.connection.Query("SELECT " + jobRunColumns + " FROM job_runs WHERE id IN (SELECT MAX(id) FROM job_runs GROUP BY job_name)")
+	if err != nil {
+		return nil, fmt.Errorf("get last job runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := map[string]models.JobRun{}
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get last job runs: scan: %w", err)
+		}
+		runs[run.Job] = run
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get last job runs: rows: %w", err)
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"swucol/models"
+)
+
+// ApplyOwnedOperation applies operation, an increment or decrement replayed
+// from a device, to its card's owned count unless an operation with the same
+// ID was applied before, in which case applied is false and nothing changes.
+// A decrement clamps at 0, and an increment reaching the card's minimum owned
+// threshold records a wishlist completion, as IncrementCardOwned does. Returns
+// the owned count before and after the change, ErrCardNotFound if no card
+// with the operation's CardID exists (the operation is not recorded), or an
+// error if the action is neither increment nor decrement or a query fails.
+func (database *Database) ApplyOwnedOperation(operation models.OwnedOperation) (before, after int, applied bool, err error) {
+	delta := 0
+	switch operation.Action {
+	case models.AuditIncrement:
+		delta = 1
+	case models.AuditDecrement:
+		delta = -1
+	default:
+		return 0, 0, false, fmt.Errorf("apply owned operation: unknown action %q", operation.Action)
+	}
+
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("apply owned operation: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	err = transaction.QueryRow("SELECT owned FROM cards WHERE id = ?", operation.CardID).Scan(&before)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, false, ErrCardNotFound
+	}
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("apply owned operation: get owned: %w", err)
+	}
+
+	now := currentTimestamp()
+	result, err := transaction.Exec(
+		"INSERT INTO owned_operations (id, card_id, action, made_at, applied_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING",
+		operation.ID, operation.CardID, operation.Action, formatTimestamp(operation.MadeAt), now,
+	)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("apply owned operation: record: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("apply owned operation: record rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return before, before, false, nil
+	}
+
+	after = max(before+delta, 0)
+	if after != before {
+		if _, err := transaction.Exec("UPDATE cards SET owned = ?, updated_at = ? WHERE id = ?", after, now, operation.CardID); err != nil {
+			return 0, 0, false, fmt.Errorf("apply owned operation: update owned: %w", err)
+		}
+	}
+
+	if delta > 0 {
+		if err := database.recordCompletion(transaction, operation.CardID, now); err != nil {
+			return 0, 0, false, fmt.Errorf("apply owned operation: %w", err)
+		}
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return 0, 0, false, fmt.Errorf("apply owned operation: commit: %w", err)
+	}
+
+	return before, after, true, nil
+}
+
+// OwnedOperationApplied reports whether an owned operation with id was
+// applied before, so that ApplyOwnedOperation would skip it as a duplicate.
+func (database *Database) OwnedOperationApplied(id string) (bool, error) {
+	var applied bool
+	if err := database.connection.QueryRow("SELECT EXISTS (SELECT 1 FROM owned_operations WHERE id = ?)", id).Scan(&applied); err != nil {
+		return false, fmt.Errorf("owned operation applied: %w", err)
+	}
+	return applied, nil
+}
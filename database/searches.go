@@ -0,0 +1,162 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"swucol/models"
+)
+
+// ErrSavedSearchNotFound is returned when no saved search has the requested
+// ID.
+var ErrSavedSearchNotFound = errors.New("saved search not found")
+
+// ValidateSavedSearch returns an error describing why search cannot be
+// stored, or nil when it can. An empty query is allowed and matches every
+// card.
+func ValidateSavedSearch(search models.SavedSearch) error {
+	if strings.TrimSpace(search.Name) == "" {
+		return errors.New("name is required")
+	}
+
+	return nil
+}
+
+// savedSearchColumns is the column list read by scanSavedSearch.
+const savedSearchColumns = "id, name, query, pinned, created_at"
+
+// scanSavedSearch reads a row selecting savedSearchColumns.
+func scanSavedSearch(row rowScanner) (models.SavedSearch, error) {
+	var (
+		search    models.SavedSearch
+		pinned    int
+		createdAt sql.NullString
+	)
+	if err := row.Scan(&search.ID, &search.Name, &search.Query, &pinned, &createdAt); err != nil {
+		return models.SavedSearch{}, err
+	}
+
+	search.Pinned = pinned != 0
+
+	var err error
+	if search.CreatedAt, err = parseTimestamp(createdAt); err != nil {
+		return models.SavedSearch{}, err
+	}
+
+	return search, nil
+}
+
+// boolToInt returns 1 for true and 0 for false, the form SQLite stores flags
+// in.
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// CreateSavedSearch stores search and returns it with its ID and creation
+// time set. Returns an error if ValidateSavedSearch rejects it.
+func (database *Database) CreateSavedSearch(search models.SavedSearch) (models.SavedSearch, error) {
+	if err := ValidateSavedSearch(search); err != nil {
+		return models.SavedSearch{}, fmt.Errorf("create saved search: %w", err)
+	}
+
+	created, err := scanSavedSearch(database.connection.QueryRow(
+		"INSERT INTO saved_searches (name, query, pinned, created_at) VALUES (?, ?, ?, ?) RETURNING "+savedSearchColumns,
+		strings.TrimSpace(search.Name), strings.TrimSpace(search.Query), boolToInt(search.Pinned), currentTimestamp(),
+	))
+	if err != nil {
+		return models.SavedSearch{}, fmt.Errorf("create saved search: %w", err)
+	}
+
+	return created, nil
+}
+
+// GetSavedSearches returns every saved search, pinned ones first, then by
+// name. Returns an empty slice (never nil) when there are none.
+func (database *Database) GetSavedSearches() ([]models.SavedSearch, error) {
+	rows, err := database.connection.Query("SELECT " + savedSearchColumns + " FROM saved_searches ORDER BY pinned DESC, name COLLATE NOCASE, id")
+	if err != nil {
+		return nil, fmt.Errorf("get saved searches: %w", err)
+	}
+	defer rows.Close()
+
+	searches := make([]models.SavedSearch, 0)
+	for rows.Next() {
+		search, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get saved searches: scan: %w", err)
+		}
+		searches = append(searches, search)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get saved searches: rows: %w", err)
+	}
+
+	return searches, nil
+}
+
+// UpdateSavedSearch replaces the name, query and pinned flag of the saved
+// search with search.ID and returns the stored search. Returns an error if
+// ValidateSavedSearch rejects it, and ErrSavedSearchNotFound if no saved
+// search with that ID exists.
+func (database *Database) UpdateSavedSearch(search models.SavedSearch) (models.SavedSearch, error) {
+	if err := ValidateSavedSearch(search); err != nil {
+		return models.SavedSearch{}, fmt.Errorf("update saved search: %w", err)
+	}
+
+	updated, err := scanSavedSearch(database.connection.QueryRow(
+		"UPDATE saved_searches SET name = ?, query = ?, pinned = ? WHERE id = ? RETURNING "+savedSearchColumns,
+		strings.TrimSpace(search.Name), strings.TrimSpace(search.Query), boolToInt(search.Pinned), search.ID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.SavedSearch{}, ErrSavedSearchNotFound
+	}
+	if err != nil {
+		return models.SavedSearch{}, fmt.Errorf("update saved search: %w", err)
+	}
+
+	return updated, nil
+}
+
+// SetSavedSearchPinned pins or unpins the saved search with the given id.
+// Returns ErrSavedSearchNotFound if no saved search with that id exists.
+func (database *Database) SetSavedSearchPinned(id int, pinned bool) error {
+	result, err := database.connection.Exec("UPDATE saved_searches SET pinned = ? WHERE id = ?", boolToInt(pinned), id)
+	if err != nil {
+		return fmt.Errorf("set saved search pinned: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("set saved search pinned: rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrSavedSearchNotFound
+	}
+
+	return nil
+}
+
+// DeleteSavedSearch removes the saved search with the given id. Returns
+// ErrSavedSearchNotFound if no saved search with that id exists.
+func (database *Database) DeleteSavedSearch(id int) error {
+	result, err := database.connection.Exec("DELETE FROM saved_searches WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete saved search: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete saved search: rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrSavedSearchNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"swucol/models"
+)
+
+// ErrSetNotUnreleased is returned by ReleaseSet when the set is not marked
+// unreleased.
+var ErrSetNotUnreleased = errors.New("set is not unreleased")
+
+// ReleaseDateLayout is the layout of the release dates of unreleased sets.
+const ReleaseDateLayout = "2006-01-02"
+
+// unreleasedClause is a condition on the cards table matching cards of a set
+// that is still unreleased: marked by MarkSetUnreleased and either without a
+// release date or with one after today (UTC). Once the date passes the cards
+// count as released without any further change.
+const unreleasedClause = "(set_code <> '' AND set_code IN (SELECT set_code FROM unreleased_sets WHERE release_date = '' OR release_date > date('now')))"
+
+// MarkSetUnreleased flags setCode as an unreleased set whose cards are a
+// preview: they can be searched and wishlisted, but are left out of the owned
+// statistics. releaseDate, in YYYY-MM-DD form, is the day the set becomes
+// released on its own, and may be empty to keep it unreleased until
+// ReleaseSet is called. Marking a set again replaces its release date.
+func (database *Database) MarkSetUnreleased(setCode, releaseDate string) error {
+	setCode = strings.ToUpper(strings.TrimSpace(setCode))
+	if setCode == "" {
+		return errors.New("mark set unreleased: set code must not be empty")
+	}
+	if releaseDate != "" {
+		if _, err := time.Parse(ReleaseDateLayout, releaseDate); err != nil {
+			return fmt.Errorf("mark set unreleased: release date must be YYYY-MM-DD: %w", err)
+		}
+	}
+
+	if _, err := database.connection.Exec(
+		"INSERT INTO unreleased_sets (set_code, release_date) VALUES (?, ?) ON CONFLICT (set_code) DO UPDATE SET release_date = excluded.release_date",
+		setCode, releaseDate,
+	); err != nil {
+		return fmt.Errorf("mark set unreleased: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseSet clears the unreleased flag of setCode, so that its cards count
+// like any others. Returns ErrSetNotUnreleased when the set is not marked.
+func (database *Database) ReleaseSet(setCode string) error {
+	result, err := database.connection.Exec("DELETE FROM unreleased_sets WHERE set_code = ?", strings.TrimSpace(setCode))
+	if err != nil {
+		return fmt.Errorf("release set: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("release set: rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrSetNotUnreleased
+	}
+
+	return nil
+}
+
+// GetUnreleasedSets returns the sets that are still unreleased, ordered by
+// set code. Sets whose release date has passed are left out. Returns an empty
+// slice (never nil) when there are none.
+func (database *Database) GetUnreleasedSets() ([]models.UnreleasedSet, error) {
+	rows, err := database.connection.Query(
+		"SELECT set_code, release_date FROM unreleased_sets WHERE release_date = '' OR release_date > date('now') ORDER BY set_code",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get unreleased sets: %w", err)
+	}
+	defer rows.Close()
+
+	sets := []models.UnreleasedSet{}
+	for rows.Next() {
+		var set models.UnreleasedSet
+		if err := rows.Scan(&set.Set, &set.ReleaseDate); err != nil {
+			return nil, fmt.Errorf("get unreleased sets: scan: %w", err)
+		}
+		sets = append(sets, set)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get unreleased sets: rows: %w", err)
+	}
+
+	return sets, nil
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"swucol/models"
+)
+
+// splitTraits splits a card's traits column into its trait names, in upper
+// case as the catalog prints them and without duplicates.
+func splitTraits(traits string) []string {
+	var split []string
+	for _, trait := range strings.Split(traits, "|") {
+		trait = strings.ToUpper(strings.TrimSpace(trait))
+		if trait != "" && !slices.Contains(split, trait) {
+			split = append(split, trait)
+		}
+	}
+	return split
+}
+
+// syncCardTraits replaces the card_traits rows of every card matching
+// condition, a WHERE clause over the cards table, with the traits parsed from
+// its traits column, as syncCardAspects does for aspects.
+func syncCardTraits(executor queryExecer, condition string, args ...any) error {
+	rows, err := executor.Query("SELECT id, traits FROM cards WHERE "+condition, args...)
+	if err != nil {
+		return fmt.Errorf("sync card traits: %w", err)
+	}
+
+	type cardTraits struct {
+		id     int
+		traits string
+	}
+	var cards []cardTraits
+	for rows.Next() {
+		var card cardTraits
+		if err := rows.Scan(&card.id, &card.traits); err != nil {
+			rows.Close()
+			return fmt.Errorf("sync card traits: scan: %w", err)
+		}
+		cards = append(cards, card)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("sync card traits: rows: %w", err)
+	}
+
+	for _, card := range cards {
+		if _, err := executor.Exec("DELETE FROM card_traits WHERE card_id = ?", card.id); err != nil {
+			return fmt.Errorf("sync card traits: clear card %d: %w", card.id, err)
+		}
+		for _, trait := range splitTraits(card.traits) {
+			if _, err := executor.Exec("INSERT INTO card_traits (card_id, trait) VALUES (?, ?)", card.id, trait); err != nil {
+				return fmt.Errorf("sync card traits: insert %s for card %d: %w", trait, card.id, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// rebuildCardTraits recreates the whole card_traits table from the cards
+// table.
+func rebuildCardTraits(executor queryExecer) error {
+	if _, err := executor.Exec("DELETE FROM card_traits"); err != nil {
+		return fmt.Errorf("rebuild card traits: %w", err)
+	}
+	return syncCardTraits(executor, "traits <> ''")
+}
+
+// GetCardTraits returns the traits of the card with the given id from the
+// card_traits table, in alphabetical order. Returns an empty slice (never nil)
+// for a card without traits or an unknown card.
+func (database *Database) GetCardTraits(id int) ([]string, error) {
+	rows, err := database.connection.Query("SELECT trait FROM card_traits WHERE card_id = ? ORDER BY trait", id)
+	if err != nil {
+		return nil, fmt.Errorf("get card traits: %w", err)
+	}
+	defer rows.Close()
+
+	traits := []string{}
+	for rows.Next() {
+		var trait string
+		if err := rows.Scan(&trait); err != nil {
+			return nil, fmt.Errorf("get card traits: scan: %w", err)
+		}
+		traits = append(traits, trait)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get card traits: rows: %w", err)
+	}
+
+	return traits, nil
+}
+
+// TraitCount is a trait with the number of non-archived cards that have it
+// and how many of those are owned.
+type TraitCount struct {
+	Trait string
+	Cards int
+	Owned int
+}
+
+// GetTraitCounts returns every trait of a non-archived card with its card
+// counts, in alphabetical order. Cards of unreleased sets are not counted. Returns an empty slice (never nil) when no
+// card has traits.
+func (database *Database) GetTraitCounts() ([]TraitCount, error) {
+	rows, err := database.connection.Query(`
+		SELECT card_traits.trait, COUNT(*), SUM(CASE WHEN cards.owned > 0 THEN 1 ELSE 0 END)
+		FROM card_traits JOIN cards ON cards.id = card_traits.card_id
+		WHERE cards.archived = 0 AND NOT ` + unreleasedClause + `
+		GROUP BY card_traits.trait
+		ORDER BY card_traits.trait
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("get trait counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []TraitCount{}
+	for rows.Next() {
+		var count TraitCount
+		if err := rows.Scan(&count.Trait, &count.Cards, &count.Owned); err != nil {
+			return nil, fmt.Errorf("get trait counts: scan: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get trait counts: rows: %w", err)
+	}
+
+	return counts, nil
+}
+
+// GetCardsByTrait returns the non-archived cards with the given trait,
+// ignoring case, ordered by name. Returns an empty slice (never nil) when no
+// card has it.
+func (database *Database) GetCardsByTrait(trait string) ([]models.Card, error) {
+	result, err := database.queryCards(
+		"SELECT "+cardColumns+" FROM cards WHERE archived = 0 AND id IN (SELECT card_id FROM card_traits WHERE trait = ? COLLATE NOCASE) ORDER BY name COLLATE NOCASE, id",
+		strings.TrimSpace(trait),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get cards by trait: %w", err)
+	}
+
+	return result, nil
+}
+
+// nullableInt converts an optional attribute to a column value, NULL when
+// value is nil.
+func nullableInt(value *int) sql.NullInt64 {
+	if value == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*value), Valid: true}
+}
+
+// SetCardAttributes stores the gameplay attributes in attributes, keyed by
+// card name, on the cards with those names, in a single transaction, and
+// returns the number of cards updated. Names with no card are ignored. Like
+// the image columns, the attributes are reference data rather than
+// collection changes, so updated_at is not touched.
+func (database *Database) SetCardAttributes(attributes map[string]models.CardAttributes) (int, error) {
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("set card attributes: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	updated := 0
+	for name, attribute := range attributes {
+		if name == "" {
+			return 0, errors.New("set card attributes: card name must not be empty")
+		}
+
+		result, err := transaction.Exec(
+			"UPDATE cards SET cost = ?, power = ?, hp = ?, traits = ?, rules_text = ? WHERE name = ?",
+			nullableInt(attribute.Cost), nullableInt(attribute.Power), nullableInt(attribute.HP),
+			strings.Join(splitTraits(attribute.Traits), "|"), attribute.Text, name,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("set card attributes: %q: %w", name, err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("set card attributes: rows affected: %w", err)
+		}
+		if affected == 0 {
+			continue
+		}
+		updated += int(affected)
+
+		if err := syncCardTraits(transaction, "name = ?", name); err != nil {
+			return 0, fmt.Errorf("set card attributes: %w", err)
+		}
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return 0, fmt.Errorf("set card attributes: commit: %w", err)
+	}
+
+	return updated, nil
+}
+
+// optionalInt converts a nullable column value to an optional attribute, nil
+// when the column is NULL.
+func optionalInt(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	converted := int(value.Int64)
+	return &converted
+}
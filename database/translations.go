@@ -0,0 +1,117 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"swucol/models"
+)
+
+// ErrInvalidLanguage is returned when a translation's language is not a
+// language tag.
+var ErrInvalidLanguage = errors.New("language must be a language tag such as de or pt-br")
+
+// languageTagPattern matches a lower-cased language tag: a two or three
+// letter language followed by optional subtags such as a region.
+var languageTagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// NormalizeLanguage trims and lower-cases a language tag, so that "pt-BR"
+// and "pt-br" are stored alike. Returns ErrInvalidLanguage when the result is
+// not a language tag.
+func NormalizeLanguage(language string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(language))
+	if !languageTagPattern.MatchString(normalized) {
+		return "", ErrInvalidLanguage
+	}
+
+	return normalized, nil
+}
+
+// SetCardTranslations stores the localized names in language of the cards
+// named by the keys of names, replacing any they had, in one transaction. A
+// blank localized name removes the card's translation. Every card sharing an
+// English name gets the translation. Returns the number of cards updated and
+// the English names matching no card, in the order of names' sorted keys.
+func (database *Database) SetCardTranslations(language string, names map[string]string) (int, []string, error) {
+	language, err := NormalizeLanguage(language)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	transaction, err := database.connection.Begin()
+	if err != nil {
+		return 0, nil, fmt.Errorf("set card translations: begin: %w", err)
+	}
+	defer transaction.Rollback()
+
+	updated, unknown := 0, []string{}
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		translated := strings.TrimSpace(names[name])
+
+		var count int
+		if err := transaction.QueryRow("SELECT COUNT(*) FROM cards WHERE name = ?", name).Scan(&count); err != nil {
+			return 0, nil, fmt.Errorf("set card translations: count %q: %w", name, err)
+		}
+		if count == 0 {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		if translated == "" {
+			_, err = transaction.Exec(
+				"DELETE FROM card_translations WHERE language = ? AND card_id IN (SELECT id FROM cards WHERE name = ?)",
+				language, name,
+			)
+		} else {
+			_, err = transaction.Exec(
+				`INSERT INTO card_translations (card_id, language, name) SELECT id, ?, ? FROM cards WHERE name = ?
+				ON CONFLICT (card_id, language) DO UPDATE SET name = excluded.name`,
+				language, translated, name,
+			)
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("set card translations: %q: %w", name, err)
+		}
+
+		updated += count
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("set card translations: commit: %w", err)
+	}
+
+	return updated, unknown, nil
+}
+
+// GetCardTranslations returns the localized names of the card with the given
+// id ordered by language. Returns an empty slice (never nil) when the card has
+// none.
+func (database *Database) GetCardTranslations(cardID int) ([]models.CardTranslation, error) {
+	rows, err := database.connection.Query(
+		"SELECT language, name FROM card_translations WHERE card_id = ? ORDER BY language",
+		cardID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get card translations: %w", err)
+	}
+	defer rows.Close()
+
+	translations := []models.CardTranslation{}
+	for rows.Next() {
+		var translation models.CardTranslation
+		if err := rows.Scan(&translation.Language, &translation.Name); err != nil {
+			return nil, fmt.Errorf("get card translations: scan: %w", err)
+		}
+		translations = append(translations, translation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get card translations: rows: %w", err)
+	}
+
+	return translations, nil
+}
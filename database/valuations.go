@@ -0,0 +1,91 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"swucol/models"
+)
+
+// valuationColumns is the column list read by scanValuation.
+const valuationColumns = "id, month, valued_at, total_cents, priced_cards, unpriced_cards"
+
+// scanValuation reads a row selecting valuationColumns.
+func scanValuation(row rowScanner) (models.Valuation, error) {
+	var (
+		valuation models.Valuation
+		valuedAt  sql.NullString
+	)
+	if err := row.Scan(&valuation.ID, &valuation.Month, &valuedAt, &valuation.TotalCents, &valuation.PricedCards, &valuation.UnpricedCards); err != nil {
+		return models.Valuation{}, err
+	}
+
+	parsed, err := parseTimestamp(valuedAt)
+	if err != nil {
+		return models.Valuation{}, fmt.Errorf("parse valued_at: %w", err)
+	}
+	valuation.ValuedAt = parsed
+
+	return valuation, nil
+}
+
+// SaveValuation stores valuation as the valuation of its month, replacing
+// any the month already has, and returns it as stored.
+func (database *Database) SaveValuation(valuation models.Valuation) (models.Valuation, error) {
+	_, err := database.connection.Exec(
+		`INSERT INTO valuations (month, valued_at, total_cents, priced_cards, unpriced_cards) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (month) DO UPDATE SET valued_at = excluded.valued_at, total_cents = excluded.total_cents,
+			priced_cards = excluded.priced_cards, unpriced_cards = excluded.unpriced_cards`,
+		valuation.Month, valuation.ValuedAt.UTC().Format(timestampLayout), valuation.TotalCents, valuation.PricedCards, valuation.UnpricedCards,
+	)
+	if err != nil {
+		return models.Valuation{}, fmt.Errorf("save valuation: %w", err)
+	}
+
+	saved, err := scanValuation(database.connection.QueryRow("SELECT "+valuationColumns+" FROM valuations WHERE month = ?", valuation.Month))
+	if err != nil {
+		return models.Valuation{}, fmt.Errorf("save valuation: read back: %w", err)
+	}
+
+	return saved, nil
+}
+
+// HasValuation reports whether month, formatted as 2006-01, has a valuation.
+func (database *Database) HasValuation(month string) (bool, error) {
+	var id int
+	err := database.connection.QueryRow("SELECT id FROM valuations WHERE month = ?", month).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("has valuation: %w", err)
+	}
+
+	return true, nil
+}
+
+// GetValuations returns every valuation, oldest month first. Returns an empty
+// slice (never nil) when none has been recorded.
+func (database *Database) GetValuations() ([]models.Valuation, error) {
+	rows, err := database.connection.Query("SELECT " + valuationColumns + " FROM valuations ORDER BY month")
+	if err != nil {
+		return nil, fmt.Errorf("get valuations: %w", err)
+	}
+	defer rows.Close()
+
+	valuations := []models.Valuation{}
+	for rows.Next() {
+		valuation, err := scanValuation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get valuations: scan: %w", err)
+		}
+		valuations = append(valuations, valuation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get valuations: rows: %w", err)
+	}
+
+	return valuations, nil
+}
@@ -0,0 +1,66 @@
+// Package datadir describes where an instance keeps its files. The database,
+// card images and backup staging all live under one root directory, so that
+// a container needs a single volume.
+package datadir
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Names of the entries under the root directory.
+const (
+	DatabaseFile = "swucol.db"
+	ImagesDir    = "images"
+	BackupsDir   = "backups"
+)
+
+// Layout holds the absolute paths of an instance's files.
+type Layout struct {
+	Root     string `json:"root"`
+	Database string `json:"database"`
+	Images   string `json:"images"`
+	Backups  string `json:"backups"`
+}
+
+// New returns the layout rooted at root, which is made absolute.
+func New(root string) (Layout, error) {
+	if root == "" {
+		return Layout{}, errors.New("data directory must not be empty")
+	}
+
+	absoluteRoot, err := filepath.Abs(root)
+	if err != nil {
+		return Layout{}, fmt.Errorf("resolve data directory: %w", err)
+	}
+
+	return Layout{
+		Root:     absoluteRoot,
+		Database: filepath.Join(absoluteRoot, DatabaseFile),
+		Images:   filepath.Join(absoluteRoot, ImagesDir),
+		Backups:  filepath.Join(absoluteRoot, BackupsDir),
+	}, nil
+}
+
+// Init creates the root, images and backups directories if they are missing.
+// It reports whether this is the first run, meaning no database file exists
+// yet; the database itself is created when it is first opened.
+func (layout Layout) Init() (bool, error) {
+	for _, dir := range []string{layout.Root, layout.Images, layout.Backups} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return false, fmt.Errorf("create %s: %w", dir, err)
+		}
+	}
+
+	_, err := os.Stat(layout.Database)
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("check database file: %w", err)
+	}
+
+	return false, nil
+}
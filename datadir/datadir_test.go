@@ -0,0 +1,49 @@
+package datadir_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/datadir"
+)
+
+func TestNew_PlacesEverythingUnderRoot(t *testing.T) {
+	root := t.TempDir()
+
+	layout, err := datadir.New(root)
+
+	require.NoError(t, err)
+	assert.Equal(t, datadir.Layout{
+		Root:     root,
+		Database: filepath.Join(root, "swucol.db"),
+		Images:   filepath.Join(root, "images"),
+		Backups:  filepath.Join(root, "backups"),
+	}, layout)
+}
+
+func TestNew_EmptyRoot_ReturnsError(t *testing.T) {
+	_, err := datadir.New("")
+
+	assert.Error(t, err)
+}
+
+func TestInit_CreatesLayoutAndDetectsFirstRun(t *testing.T) {
+	layout, err := datadir.New(filepath.Join(t.TempDir(), "data"))
+	require.NoError(t, err)
+
+	firstRun, err := layout.Init()
+	require.NoError(t, err)
+	assert.True(t, firstRun)
+	assert.DirExists(t, layout.Images)
+	assert.DirExists(t, layout.Backups)
+
+	require.NoError(t, os.WriteFile(layout.Database, nil, 0o644))
+
+	firstRun, err = layout.Init()
+	require.NoError(t, err)
+	assert.False(t, firstRun)
+}
@@ -0,0 +1,120 @@
+// Package eventbus decouples the handlers that change the collection from the
+// side effects of those changes. Handlers Publish an Event after a change
+// succeeds, and the audit log and webhook notifier Subscribe to the types they
+// act on, so that a new side effect, such as broadcasting live updates, is one
+// more subscriber rather than a call added to every handler.
+//
+// Subscribers run synchronously, in the order they subscribed, on the
+// publishing goroutine and with its context, so that they see the request's
+// user and request ID, and an audit entry is stored before the response is
+// written. A subscriber with slow work must hand it off, as the webhook
+// notifier does.
+package eventbus
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Event types.
+const (
+	// CardInserted is published for each card an import adds, with its name.
+	CardInserted = "card.inserted"
+	// OwnedChanged is published when a card's owned count is changed, with
+	// the card, the cause (models.AuditIncrement, AuditDecrement or AuditSync)
+	// and its owned count after the change; sync changes also carry the count
+	// before it.
+	OwnedChanged = "owned.changed"
+	// CardArchived and CardUnarchived are published when a card is archived or
+	// restored, with the card.
+	CardArchived   = "card.archived"
+	CardUnarchived = "card.unarchived"
+	// CardsUpdated is published after a bulk update, with the number of cards
+	// it changed and a description.
+	CardsUpdated = "cards.updated"
+	// ImportCompleted is published when a CSV import finishes without error,
+	// with the number of cards it added (0 for a sync import).
+	ImportCompleted = "import.completed"
+)
+
+// Event is a change to the collection. Fields that do not apply to its Type
+// are zero.
+type Event struct {
+	Type string
+	// CardID is 0 for a card matched by name.
+	CardID   int
+	CardName string
+	Cause    string
+	Owned    int
+	Previous int
+	Count    int
+	Detail   string
+}
+
+// Handler acts on a published event.
+type Handler func(ctx context.Context, event Event)
+
+// subscription is one Subscribe call, compared by pointer so that it can be
+// removed again.
+type subscription struct {
+	handler Handler
+}
+
+// Bus delivers published events to the handlers subscribed to their type. It
+// is safe for concurrent use.
+type Bus struct {
+	mutex       sync.RWMutex
+	subscribers map[string][]*subscription
+}
+
+// New returns a bus without subscribers.
+func New() *Bus {
+	return &Bus{subscribers: make(map[string][]*subscription)}
+}
+
+// Default is the bus the server's handlers publish to and its side effects
+// subscribe to.
+var Default = New()
+
+// Subscribe calls handler for every event of eventType published from now on,
+// and returns a function that stops doing so.
+func (bus *Bus) Subscribe(eventType string, handler Handler) func() {
+	added := &subscription{handler: handler}
+
+	bus.mutex.Lock()
+	bus.subscribers[eventType] = append(bus.subscribers[eventType], added)
+	bus.mutex.Unlock()
+
+	return func() {
+		bus.mutex.Lock()
+		defer bus.mutex.Unlock()
+
+		bus.subscribers[eventType] = slices.DeleteFunc(bus.subscribers[eventType], func(existing *subscription) bool {
+			return existing == added
+		})
+	}
+}
+
+// Publish calls the handlers subscribed to event's type, in the order they
+// subscribed, and returns once they have all returned. Handlers may publish
+// events themselves.
+func (bus *Bus) Publish(ctx context.Context, event Event) {
+	bus.mutex.RLock()
+	subscribers := slices.Clone(bus.subscribers[event.Type])
+	bus.mutex.RUnlock()
+
+	for _, subscriber := range subscribers {
+		subscriber.handler(ctx, event)
+	}
+}
+
+// Subscribe subscribes handler to eventType on Default.
+func Subscribe(eventType string, handler Handler) func() {
+	return Default.Subscribe(eventType, handler)
+}
+
+// Publish publishes event on Default.
+func Publish(ctx context.Context, event Event) {
+	Default.Publish(ctx, event)
+}
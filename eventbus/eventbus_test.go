@@ -0,0 +1,60 @@
+package eventbus_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"swucol/eventbus"
+)
+
+func TestPublish_CallsSubscribersOfTheTypeInOrder(t *testing.T) {
+	bus := eventbus.New()
+
+	var calls []string
+	bus.Subscribe(eventbus.OwnedChanged, func(ctx context.Context, event eventbus.Event) {
+		calls = append(calls, "first "+event.CardName)
+	})
+	bus.Subscribe(eventbus.OwnedChanged, func(ctx context.Context, event eventbus.Event) {
+		calls = append(calls, "second "+event.CardName)
+	})
+	bus.Subscribe(eventbus.ImportCompleted, func(ctx context.Context, event eventbus.Event) {
+		calls = append(calls, "import")
+	})
+
+	bus.Publish(context.Background(), eventbus.Event{Type: eventbus.OwnedChanged, CardName: "Luke"})
+
+	assert.Equal(t, []string{"first Luke", "second Luke"}, calls)
+}
+
+func TestSubscribe_UnsubscribeStopsDelivery(t *testing.T) {
+	bus := eventbus.New()
+
+	var kept, removed int
+	bus.Subscribe(eventbus.CardInserted, func(context.Context, eventbus.Event) { kept++ })
+	unsubscribe := bus.Subscribe(eventbus.CardInserted, func(context.Context, eventbus.Event) { removed++ })
+
+	bus.Publish(context.Background(), eventbus.Event{Type: eventbus.CardInserted})
+	unsubscribe()
+	bus.Publish(context.Background(), eventbus.Event{Type: eventbus.CardInserted})
+
+	assert.Equal(t, 2, kept)
+	assert.Equal(t, 1, removed)
+}
+
+func TestPublish_SubscribersMayPublish(t *testing.T) {
+	bus := eventbus.New()
+
+	var completed bool
+	bus.Subscribe(eventbus.CardInserted, func(ctx context.Context, event eventbus.Event) {
+		bus.Publish(ctx, eventbus.Event{Type: eventbus.ImportCompleted, Count: 1})
+	})
+	bus.Subscribe(eventbus.ImportCompleted, func(ctx context.Context, event eventbus.Event) {
+		completed = event.Count == 1
+	})
+
+	bus.Publish(context.Background(), eventbus.Event{Type: eventbus.CardInserted})
+
+	assert.True(t, completed)
+}
@@ -0,0 +1,188 @@
+// Package events provides the HTTP handlers for the event log: tournaments
+// and other events played, the deck used and the match record, with win-rate
+// totals per deck. Decks are identified by name.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"swucol/csrf"
+	"swucol/database"
+	"swucol/models"
+	"swucol/roles"
+	"swucol/templates"
+)
+
+// deckStatsRow is a deck's totals with its win rate formatted for display.
+type deckStatsRow struct {
+	models.DeckStats
+	WinPercent string
+}
+
+// eventsPage is the view model rendered by the events template and by its
+// events-log fragment.
+type eventsPage struct {
+	Events    []models.Event
+	Stats     []deckStatsRow
+	Today     string
+	Theme     string
+	Role      string
+	CSRFToken string
+}
+
+// StatsHandler returns an http.HandlerFunc that handles GET /events/stats.
+// Returns 200 OK with a JSON array of per-deck totals, best win rate first
+// (empty when no events are recorded), and 500 Internal Server Error for
+// database errors.
+func StatsHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		stats, err := db.GetDeckStats()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading deck stats", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(stats); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode deck stats response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// PageHandler returns an http.HandlerFunc that serves the event log at GET
+// /events. Returns 500 Internal Server Error if a database query or template
+// rendering fails.
+func PageHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		renderEvents(responseWriter, request, db, tmpl, "events")
+	}
+}
+
+// CreateHTMLHandler returns an http.HandlerFunc that handles POST
+// /events/html with form values "name", "played_on", "deck", "wins",
+// "losses", "draws" and "notes". Responds with the re-rendered stats and
+// event list, 400 Bad Request for invalid values, and 500 Internal Server
+// Error for database or template errors.
+func CreateHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if err := request.ParseForm(); err != nil {
+			templates.RenderError(responseWriter, request, tmpl, "invalid form data", http.StatusBadRequest)
+			return
+		}
+
+		event := models.Event{
+			Name:     request.PostForm.Get("name"),
+			PlayedOn: request.PostForm.Get("played_on"),
+			Deck:     request.PostForm.Get("deck"),
+			Notes:    request.PostForm.Get("notes"),
+		}
+
+		for field, target := range map[string]*int{"wins": &event.Wins, "losses": &event.Losses, "draws": &event.Draws} {
+			raw := strings.TrimSpace(request.PostForm.Get(field))
+			if raw == "" {
+				continue
+			}
+			value, err := strconv.Atoi(raw)
+			if err != nil {
+				templates.RenderError(responseWriter, request, tmpl, field+" must be an integer", http.StatusBadRequest)
+				return
+			}
+			*target = value
+		}
+
+		if err := database.ValidateEvent(event); err != nil {
+			templates.RenderError(responseWriter, request, tmpl, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		created, err := db.CreateEvent(event)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error creating event", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "event recorded", "event_id", created.ID, "deck", created.Deck,
+			"wins", created.Wins, "losses", created.Losses, "draws", created.Draws)
+
+		renderEvents(responseWriter, request, db, tmpl, "events-log")
+	}
+}
+
+// DeleteHTMLHandler returns an http.HandlerFunc that handles DELETE
+// /events/{id}/html. Responds with the re-rendered stats and event list, 400
+// Bad Request for an invalid id, 404 Not Found if no such event exists, and
+// 500 Internal Server Error for database or template errors.
+func DeleteHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, err := strconv.Atoi(request.PathValue("id"))
+		if err != nil || id <= 0 {
+			templates.RenderError(responseWriter, request, tmpl, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		err = db.DeleteEvent(id)
+		if errors.Is(err, database.ErrEventNotFound) {
+			templates.RenderError(responseWriter, request, tmpl, "event not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error deleting event", "event_id", id, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "event deleted", "event_id", id)
+
+		renderEvents(responseWriter, request, db, tmpl, "events-log")
+	}
+}
+
+// renderEvents loads the events and deck totals and renders templateName,
+// either the full page or the events-log fragment.
+func renderEvents(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer, templateName string) {
+	events, err := db.GetEvents()
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error loading events", "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	stats, err := db.GetDeckStats()
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error loading deck stats", "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	rows := make([]deckStatsRow, len(stats))
+	for i, deck := range stats {
+		rows[i] = deckStatsRow{DeckStats: deck, WinPercent: fmt.Sprintf("%.0f%%", deck.WinRate*100)}
+	}
+
+	page := eventsPage{
+		Events:    events,
+		Stats:     rows,
+		Today:     time.Now().Format(database.EventDateLayout),
+		Theme:     db.Settings().Theme,
+		Role:      roles.FromContext(request.Context()),
+		CSRFToken: csrf.Token(request.Context()),
+	}
+
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.ExecuteTemplate(responseWriter, templateName, page); err != nil {
+		slog.ErrorContext(request.Context(), "failed to render events template", "template", templateName, "error", err)
+		http.Error(responseWriter, "template error", http.StatusInternalServerError)
+		return
+	}
+}
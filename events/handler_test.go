@@ -0,0 +1,110 @@
+package events_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/events"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/roles"
+	"swucol/templates"
+)
+
+// newFormRequest builds a urlencoded POST to target carrying form.
+func newFormRequest(target string, form url.Values) *http.Request {
+	request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return request
+}
+
+func TestCreateHTMLHandler_RecordsEventAndShowsWinRate(t *testing.T) {
+	db := testdb.New(t)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	events.CreateHTMLHandler(db, tmpl)(recorder, newFormRequest("/events/html", url.Values{
+		"name": {"Store Showdown"}, "played_on": {"2026-03-01"}, "deck": {"Sabine Aggro"},
+		"wins": {"3"}, "losses": {"1"},
+	}))
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	body := recorder.Body.String()
+	assert.Contains(t, body, "Store Showdown")
+	assert.Contains(t, body, "3-1-0")
+	assert.Contains(t, body, "75%")
+	assert.Contains(t, body, `hx-delete="/events/1/html"`)
+}
+
+func TestCreateHTMLHandler_InvalidDate_ReturnsBadRequest(t *testing.T) {
+	db := testdb.New(t)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	events.CreateHTMLHandler(db, tmpl)(recorder, newFormRequest("/events/html", url.Values{
+		"name": {"Store Showdown"}, "played_on": {"March 1st"}, "deck": {"Sabine Aggro"},
+	}))
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "played_on")
+}
+
+func TestStatsHandler_ReturnsDeckTotals(t *testing.T) {
+	db := testdb.New(t)
+	_, err := db.CreateEvent(models.Event{Name: "Store Showdown", PlayedOn: "2026-03-01", Deck: "Han Control", Wins: 1, Losses: 1})
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	events.StatsHandler(db)(recorder, httptest.NewRequest(http.MethodGet, "/events/stats", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var stats []models.DeckStats
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &stats))
+	require.Len(t, stats, 1)
+	assert.Equal(t, 0.5, stats[0].WinRate)
+}
+
+func TestPageHandler_RendersEmptyState(t *testing.T) {
+	db := testdb.New(t)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	events.PageHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "No events recorded yet.")
+}
+
+func TestPageHandler_HidesControlsTheRoleCannotUse(t *testing.T) {
+	db := testdb.New(t)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	viewer := httptest.NewRecorder()
+	roles.Middleware(db, models.RoleViewer, events.PageHandler(db, tmpl)).ServeHTTP(viewer, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	require.Equal(t, http.StatusOK, viewer.Code)
+	assert.Contains(t, viewer.Body.String(), ".role-editor, .role-admin {")
+	assert.Contains(t, viewer.Body.String(), `class="events-form role-editor"`)
+
+	editor := httptest.NewRecorder()
+	roles.Middleware(db, models.RoleEditor, events.PageHandler(db, tmpl)).ServeHTTP(editor, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	assert.NotContains(t, editor.Body.String(), ".role-editor, .role-admin {")
+	assert.Contains(t, editor.Body.String(), ".role-admin {")
+
+	admin := httptest.NewRecorder()
+	events.PageHandler(db, tmpl)(admin, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	assert.NotContains(t, admin.Body.String(), ".role-admin {")
+}
@@ -0,0 +1,144 @@
+// Package fallback lets the htmx routes serve browsers without JavaScript,
+// or with the html_only setting on, where their forms are posted as regular
+// page loads.
+//
+// htmx marks its requests with the HX-Request header. Other requests to a
+// wrapped route are answered with a full page instead of a fragment: either a
+// redirect back to the page the form was posted from (POST-redirect-GET), or
+// the fragment wrapped in the fallback-page template.
+package fallback
+
+import (
+	"bytes"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"swucol/templates"
+)
+
+// Fallback wraps HTML handlers with their non-JavaScript responses.
+type Fallback struct {
+	basePath string
+	tmpl     templates.Renderer
+}
+
+// New returns a Fallback that redirects to basePath when a request has no
+// usable Referer and renders result pages with tmpl.
+func New(basePath string, tmpl templates.Renderer) *Fallback {
+	return &Fallback{basePath: basePath, tmpl: tmpl}
+}
+
+// IsHTMX reports whether request was sent by htmx.
+func IsHTMX(request *http.Request) bool {
+	return request.Header.Get("HX-Request") == "true"
+}
+
+// fallbackPage is the view model rendered by the fallback-page template.
+type fallbackPage struct {
+	Content template.HTML
+	Back    string
+}
+
+// bufferedResponse holds a handler's response so it can be replaced before
+// anything reaches the client.
+type bufferedResponse struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (response *bufferedResponse) Header() http.Header {
+	return response.header
+}
+
+func (response *bufferedResponse) Write(data []byte) (int, error) {
+	return response.body.Write(data)
+}
+
+func (response *bufferedResponse) WriteHeader(statusCode int) {
+	if response.statusCode == 0 {
+		response.statusCode = statusCode
+	}
+}
+
+// serve runs next with a buffered response and returns it. When next fails
+// the error is copied to responseWriter as is and ok is false.
+func serve(responseWriter http.ResponseWriter, request *http.Request, next http.HandlerFunc) (*bufferedResponse, bool) {
+	response := &bufferedResponse{header: http.Header{}}
+	next(response, request)
+
+	if response.statusCode == 0 {
+		response.statusCode = http.StatusOK
+	}
+
+	if response.statusCode >= http.StatusBadRequest {
+		for key, values := range response.header {
+			responseWriter.Header()[key] = values
+		}
+		responseWriter.WriteHeader(response.statusCode)
+		responseWriter.Write(response.body.Bytes())
+		return response, false
+	}
+
+	return response, true
+}
+
+// Redirect serves htmx requests with next unchanged. Other requests have
+// next's fragment discarded and are redirected with 303 See Other to the page
+// they were posted from, so that reloading it does not repeat the post.
+// Errors from next are passed through.
+func (fallback *Fallback) Redirect(next http.HandlerFunc) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if IsHTMX(request) {
+			next(responseWriter, request)
+			return
+		}
+
+		if _, ok := serve(responseWriter, request, next); !ok {
+			return
+		}
+
+		http.Redirect(responseWriter, request, fallback.back(request), http.StatusSeeOther)
+	}
+}
+
+// Page serves htmx requests with next unchanged. Other requests get next's
+// fragment inside the fallback-page template, with a link back to the page
+// they were posted from. It suits results worth reading, such as an import
+// summary. Errors from next are passed through.
+func (fallback *Fallback) Page(next http.HandlerFunc) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if IsHTMX(request) {
+			next(responseWriter, request)
+			return
+		}
+
+		response, ok := serve(responseWriter, request, next)
+		if !ok {
+			return
+		}
+
+		page := fallbackPage{Content: template.HTML(response.body.String()), Back: fallback.back(request)}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := fallback.tmpl.ExecuteTemplate(responseWriter, "fallback-page", page); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render fallback-page template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// back returns the path of the page request was posted from, taken from its
+// Referer when that names this host, and the app root otherwise.
+func (fallback *Fallback) back(request *http.Request) string {
+	referer, err := url.Parse(request.Referer())
+	if err == nil && referer.Host == request.Host && strings.HasPrefix(referer.Path, "/") {
+		return referer.RequestURI()
+	}
+
+	return fallback.basePath + "/"
+}
@@ -0,0 +1,99 @@
+package fallback_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/fallback"
+	"swucol/templates"
+)
+
+// fragmentHandler responds with an HTML fragment and an htmx trigger, as the
+// HTML routes do.
+func fragmentHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter.Header().Set("HX-Trigger", "cardsChanged")
+	responseWriter.Write([]byte(`<p class="result">Imported 3 cards.</p>`))
+}
+
+// failingHandler responds with a plain-text error.
+func failingHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	http.Error(responseWriter, "card not found", http.StatusNotFound)
+}
+
+// newTestFallback returns a Fallback under base path /swucol rendering with
+// the application's templates.
+func newTestFallback(t *testing.T) *fallback.Fallback {
+	t.Helper()
+
+	tmpl, err := templates.ParseGlob("../templates/*.html", "/swucol")
+	require.NoError(t, err)
+
+	return fallback.New("/swucol", tmpl)
+}
+
+// post sends a form post to handler, from htmx when htmx is true, with the
+// given Referer.
+func post(handler http.HandlerFunc, htmx bool, referer string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "http://example.com/swucol/cards/1/increment/html", nil)
+	if htmx {
+		request.Header.Set("HX-Request", "true")
+	}
+	if referer != "" {
+		request.Header.Set("Referer", referer)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+
+	return recorder
+}
+
+func TestRedirect_HTMXRequest_ServesFragment(t *testing.T) {
+	recorder := post(newTestFallback(t).Redirect(fragmentHandler), true, "")
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "cardsChanged", recorder.Header().Get("HX-Trigger"))
+	assert.Contains(t, recorder.Body.String(), "Imported 3 cards.")
+}
+
+func TestRedirect_PlainFormPost_RedirectsToReferer(t *testing.T) {
+	handler := newTestFallback(t).Redirect(fragmentHandler)
+
+	recorder := post(handler, false, "http://example.com/swucol/?q=luke&page=2")
+	assert.Equal(t, http.StatusSeeOther, recorder.Code)
+	assert.Equal(t, "/swucol/?q=luke&page=2", recorder.Header().Get("Location"))
+	assert.Empty(t, recorder.Header().Get("HX-Trigger"))
+
+	for _, referer := range []string{"", "http://evil.example/phish"} {
+		recorder := post(handler, false, referer)
+		assert.Equal(t, http.StatusSeeOther, recorder.Code)
+		assert.Equal(t, "/swucol/", recorder.Header().Get("Location"), referer)
+	}
+}
+
+func TestRedirect_PlainFormPostError_PassesErrorThrough(t *testing.T) {
+	recorder := post(newTestFallback(t).Redirect(failingHandler), false, "http://example.com/swucol/")
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "card not found")
+}
+
+func TestPage_PlainFormPost_WrapsFragmentInPage(t *testing.T) {
+	recorder := post(newTestFallback(t).Page(fragmentHandler), false, "http://example.com/swucol/")
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, "<!DOCTYPE html>")
+	assert.Contains(t, body, `<p class="result">Imported 3 cards.</p>`)
+	assert.Contains(t, body, `<a href="/swucol/">Back</a>`)
+}
+
+func TestPage_HTMXRequest_ServesFragment(t *testing.T) {
+	recorder := post(newTestFallback(t).Page(fragmentHandler), true, "")
+
+	assert.Equal(t, `<p class="result">Imported 3 cards.</p>`, recorder.Body.String())
+}
@@ -0,0 +1,206 @@
+// Package goals provides the HTTP handlers for collection goals: saved
+// searches with a target number of copies per card, such as "own a playset
+// of every LAW rare". The JSON endpoints under /goals are for scripts; the
+// HTML fragment endpoints drive the goals widget on the index page, which
+// shows a progress bar for each goal.
+package goals
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"swucol/database"
+	"swucol/internal/httpjson"
+	"swucol/models"
+	"swucol/templates"
+)
+
+// goalRequest is the JSON body accepted by CreateHandler.
+type goalRequest struct {
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	Target int    `json:"target"`
+}
+
+// ListHandler returns an http.HandlerFunc that handles GET /goals. Returns
+// 200 OK with a JSON array of every goal with its progress (empty when there
+// are none), and 500 Internal Server Error for database errors.
+func ListHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		progress, err := db.GetGoalProgress()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading goal progress", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, progress)
+	}
+}
+
+// CreateHandler returns an http.HandlerFunc that handles POST /goals with a
+// JSON body of the form {"name": "...", "query": "...", "target": 3}.
+// Returns 201 Created with the stored goal as JSON, 400 Bad Request for an
+// invalid body, target or query, and 500 Internal Server Error for database
+// errors.
+func CreateHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		var payload goalRequest
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		created, ok := createGoal(responseWriter, request, db, models.Goal{Name: payload.Name, Query: payload.Query, Target: payload.Target})
+		if !ok {
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusCreated, created)
+	}
+}
+
+// DeleteHandler returns an http.HandlerFunc that handles DELETE /goals/{id}.
+// Returns 204 No Content once the goal is deleted, 400 Bad Request for an
+// invalid id, 404 Not Found if no such goal exists, and 500 Internal Server
+// Error for database errors.
+func DeleteHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, ok := parseGoalID(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		if !deleteGoal(responseWriter, request, db, id) {
+			return
+		}
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// WidgetHTMLHandler returns an http.HandlerFunc that handles GET /goals/html.
+// Responds with the goals widget of the index page, which reloads it when
+// owned counts change, and 500 Internal Server Error for database or template
+// errors.
+func WidgetHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		renderGoals(responseWriter, request, db, tmpl)
+	}
+}
+
+// CreateHTMLHandler returns an http.HandlerFunc that handles POST /goals/html
+// with form values "name", "q" (the search box contents) and "target".
+// Responds with the re-rendered goals widget, 400 Bad Request for invalid
+// values, and 500 Internal Server Error for database or template errors.
+func CreateHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if err := request.ParseForm(); err != nil {
+			templates.RenderError(responseWriter, request, tmpl, "invalid form data", http.StatusBadRequest)
+			return
+		}
+
+		target, err := strconv.Atoi(request.PostForm.Get("target"))
+		if err != nil {
+			templates.RenderError(responseWriter, request, tmpl, "target must be a whole number", http.StatusBadRequest)
+			return
+		}
+
+		goal := models.Goal{Name: request.PostForm.Get("name"), Query: request.PostForm.Get("q"), Target: target}
+		if _, ok := createGoal(responseWriter, request, db, goal); !ok {
+			return
+		}
+
+		renderGoals(responseWriter, request, db, tmpl)
+	}
+}
+
+// DeleteHTMLHandler returns an http.HandlerFunc that handles DELETE
+// /goals/{id}/html. Responds with the re-rendered goals widget, 400 Bad
+// Request for an invalid id, 404 Not Found if no such goal exists, and 500
+// Internal Server Error for database or template errors.
+func DeleteHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, ok := parseGoalID(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		if !deleteGoal(responseWriter, request, db, id) {
+			return
+		}
+
+		renderGoals(responseWriter, request, db, tmpl)
+	}
+}
+
+// createGoal validates and stores goal. It responds with the error and
+// returns false when the goal is invalid or cannot be stored.
+func createGoal(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, goal models.Goal) (models.Goal, bool) {
+	if err := database.ValidateGoal(goal); err != nil {
+		http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+		return models.Goal{}, false
+	}
+
+	created, err := db.CreateGoal(goal)
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error creating goal", "error", err)
+		http.Error(responseWriter, "database error", http.StatusInternalServerError)
+		return models.Goal{}, false
+	}
+
+	slog.InfoContext(request.Context(), "goal created", "goal_id", created.ID)
+
+	return created, true
+}
+
+// parseGoalID reads the {id} path value. It responds with 400 Bad Request and
+// returns false when the id is not a positive integer.
+func parseGoalID(responseWriter http.ResponseWriter, request *http.Request) (int, bool) {
+	id, err := strconv.Atoi(request.PathValue("id"))
+	if err != nil || id <= 0 {
+		http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// deleteGoal deletes the goal with the given id. It responds with the error
+// and returns false when the goal does not exist or the delete fails.
+func deleteGoal(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, id int) bool {
+	err := db.DeleteGoal(id)
+	if errors.Is(err, database.ErrGoalNotFound) {
+		http.Error(responseWriter, "goal not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error deleting goal", "goal_id", id, "error", err)
+		http.Error(responseWriter, "database error", http.StatusInternalServerError)
+		return false
+	}
+
+	slog.InfoContext(request.Context(), "goal deleted", "goal_id", id)
+
+	return true
+}
+
+// renderGoals loads the progress of every goal and renders the goals
+// fragment of the index page.
+func renderGoals(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer) {
+	progress, err := db.GetGoalProgress()
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error loading goal progress", "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.ExecuteTemplate(responseWriter, "goals", progress); err != nil {
+		slog.ErrorContext(request.Context(), "failed to render goals template", "error", err)
+		http.Error(responseWriter, "template error", http.StatusInternalServerError)
+		return
+	}
+}
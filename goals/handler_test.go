@@ -0,0 +1,118 @@
+package goals_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/goals"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/templates"
+)
+
+func TestCreateAndListHandlers_ReportProgress(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCards([]models.NewCard{
+		{Name: "Bounty Hunter Crew", Set: "LAW", Rarity: "Rare"},
+		{Name: "Jabba the Hutt", Set: "LAW", Rarity: "Rare"},
+		{Name: "Battlefield Marine", Set: "LAW", Rarity: "Common"},
+	}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{"Bounty Hunter Crew": 5, "Jabba the Hutt": 1}))
+
+	createRecorder := httptest.NewRecorder()
+	goals.CreateHandler(db)(createRecorder, httptest.NewRequest(http.MethodPost, "/goals",
+		strings.NewReader(`{"name": "LAW rare playsets", "query": "set:LAW rarity:rare", "target": 3}`)))
+	require.Equal(t, http.StatusCreated, createRecorder.Code, createRecorder.Body.String())
+
+	listRecorder := httptest.NewRecorder()
+	goals.ListHandler(db)(listRecorder, httptest.NewRequest(http.MethodGet, "/goals", nil))
+
+	require.Equal(t, http.StatusOK, listRecorder.Code)
+	var progress []models.GoalProgress
+	require.NoError(t, json.Unmarshal(listRecorder.Body.Bytes(), &progress))
+	require.Len(t, progress, 1)
+	assert.Equal(t, "LAW rare playsets", progress[0].Name)
+	assert.Equal(t, 2, progress[0].Cards)
+	assert.Equal(t, 1, progress[0].Complete)
+	assert.Equal(t, 4, progress[0].Copies, "copies beyond the target do not count")
+	assert.Equal(t, 6, progress[0].Needed)
+	assert.Equal(t, 66, progress[0].Percent)
+}
+
+func TestCreateHandler_InvalidGoals_ReturnBadRequest(t *testing.T) {
+	db := testdb.New(t)
+
+	for _, body := range []string{
+		`{"name": " ", "query": "set:LAW", "target": 3}`,
+		`{"name": "Playsets", "query": "set:LAW", "target": 0}`,
+		`{"name": "Playsets", "query": "colour:red", "target": 3}`,
+	} {
+		recorder := httptest.NewRecorder()
+		goals.CreateHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/goals", strings.NewReader(body)))
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, body)
+	}
+}
+
+func TestDeleteHandler_UnknownGoal_ReturnsNotFound(t *testing.T) {
+	db := testdb.New(t)
+
+	request := httptest.NewRequest(http.MethodDelete, "/goals/42", nil)
+	request.SetPathValue("id", "42")
+	recorder := httptest.NewRecorder()
+	goals.DeleteHandler(db)(recorder, request)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestHTMLHandlers_AddAndDeleteGoal(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Faithful Friend", Type: "Leader", Aspects: "Vigilance|Heroism"}))
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	form := url.Values{"name": {"Heroism leaders"}, "q": {"aspect:heroism type:leader"}, "target": {"1"}}
+	createRequest := httptest.NewRequest(http.MethodPost, "/goals/html", strings.NewReader(form.Encode()))
+	createRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	createRecorder := httptest.NewRecorder()
+	goals.CreateHTMLHandler(db, tmpl)(createRecorder, createRequest)
+
+	require.Equal(t, http.StatusOK, createRecorder.Code, createRecorder.Body.String())
+	body := createRecorder.Body.String()
+	assert.Contains(t, body, "Heroism leaders")
+	assert.Contains(t, body, `<progress class="goal-bar" value="0" max="1">0%</progress>`)
+	assert.Contains(t, body, "0 of 1 cards complete")
+
+	deleteRequest := httptest.NewRequest(http.MethodDelete, "/goals/1/html", nil)
+	deleteRequest.SetPathValue("id", "1")
+	deleteRecorder := httptest.NewRecorder()
+	goals.DeleteHTMLHandler(db, tmpl)(deleteRecorder, deleteRequest)
+
+	require.Equal(t, http.StatusOK, deleteRecorder.Code)
+	assert.Contains(t, deleteRecorder.Body.String(), "No goals yet.")
+}
+
+func TestWidgetHTMLHandler_MarksControlsForTheRolesAllowedThem(t *testing.T) {
+	db := testdb.New(t)
+	_, err := db.CreateGoal(models.Goal{Name: "Playsets", Target: 3})
+	require.NoError(t, err)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	goals.WidgetHTMLHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/goals/html", nil))
+
+	// The index page's role partial hides these from the roles that
+	// roles.Required would refuse.
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, `<form class="saved-form role-editor"`)
+	assert.Contains(t, body, `<button class="bulk-btn role-admin" hx-delete="/goals/1/html"`)
+}
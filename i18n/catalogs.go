@@ -0,0 +1,95 @@
+package i18n
+
+// catalogs maps each supported language to its UI messages by key. The
+// DefaultLanguage catalog must hold every key used by the templates; other
+// catalogs may omit keys, which then fall back to it.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"search.placeholder": "Search cards... (e.g. set:SOR aspect:heroism owned>=3 -type:base)",
+		"nav.import":         "Import",
+		"nav.compare":        "Compare",
+		"nav.wishlist":       "Wishlist",
+		"nav.quick":          "Quick",
+		"nav.binder":         "Binder",
+		"nav.traits":         "Traits",
+		"nav.cubes":          "Cubes",
+		"nav.inventory":      "Inventory",
+		"nav.events":         "Events",
+		"nav.archive":        "Archive",
+		"nav.history":        "History",
+		"nav.buylist":        "Buylist",
+		"nav.settings":       "Settings",
+		"nav.sign_in":        "Sign in",
+		"bulk.label":         "All cards matching the search:",
+		"bulk.mainboard":     "Mark mainboard",
+		"bulk.not_mainboard": "Mark not mainboard",
+		"bulk.archive":       "Archive",
+		"recent.title":       "Recent activity",
+	},
+	"de": {
+		"search.placeholder": "Karten suchen... (z. B. set:SOR aspect:heroism owned>=3 -type:base)",
+		"nav.import":         "Importieren",
+		"nav.compare":        "Vergleichen",
+		"nav.wishlist":       "Wunschliste",
+		"nav.quick":          "Schnell",
+		"nav.binder":         "Sammelordner",
+		"nav.traits":         "Merkmale",
+		"nav.cubes":          "Cubes",
+		"nav.inventory":      "Inventar",
+		"nav.events":         "Turniere",
+		"nav.archive":        "Archiv",
+		"nav.history":        "Verlauf",
+		"nav.buylist":        "Ankaufspreise",
+		"nav.settings":       "Einstellungen",
+		"nav.sign_in":        "Anmelden",
+		"bulk.label":         "Alle Karten der Suche:",
+		"bulk.mainboard":     "Als Hauptdeck markieren",
+		"bulk.not_mainboard": "Nicht als Hauptdeck markieren",
+		"bulk.archive":       "Archivieren",
+		"recent.title":       "Letzte Aktivität",
+	},
+	"es": {
+		"search.placeholder": "Buscar cartas... (p. ej. set:SOR aspect:heroism owned>=3 -type:base)",
+		"nav.import":         "Importar",
+		"nav.compare":        "Comparar",
+		"nav.wishlist":       "Lista de deseos",
+		"nav.quick":          "Rápido",
+		"nav.binder":         "Carpeta",
+		"nav.traits":         "Rasgos",
+		"nav.cubes":          "Cubos",
+		"nav.inventory":      "Inventario",
+		"nav.events":         "Eventos",
+		"nav.archive":        "Archivo",
+		"nav.history":        "Historial",
+		"nav.buylist":        "Precios de compra",
+		"nav.settings":       "Ajustes",
+		"nav.sign_in":        "Iniciar sesión",
+		"bulk.label":         "Todas las cartas de la búsqueda:",
+		"bulk.mainboard":     "Marcar mazo principal",
+		"bulk.not_mainboard": "Marcar fuera del mazo principal",
+		"bulk.archive":       "Archivar",
+		"recent.title":       "Actividad reciente",
+	},
+	"fr": {
+		"search.placeholder": "Rechercher des cartes... (ex. set:SOR aspect:heroism owned>=3 -type:base)",
+		"nav.import":         "Importer",
+		"nav.compare":        "Comparer",
+		"nav.wishlist":       "Liste de souhaits",
+		"nav.quick":          "Rapide",
+		"nav.binder":         "Classeur",
+		"nav.traits":         "Traits",
+		"nav.cubes":          "Cubes",
+		"nav.inventory":      "Inventaire",
+		"nav.events":         "Événements",
+		"nav.archive":        "Archives",
+		"nav.history":        "Historique",
+		"nav.buylist":        "Prix de rachat",
+		"nav.settings":       "Paramètres",
+		"nav.sign_in":        "Se connecter",
+		"bulk.label":         "Toutes les cartes de la recherche :",
+		"bulk.mainboard":     "Marquer deck principal",
+		"bulk.not_mainboard": "Marquer hors deck principal",
+		"bulk.archive":       "Archiver",
+		"recent.title":       "Activité récente",
+	},
+}
@@ -0,0 +1,80 @@
+// Package i18n selects the language pages are rendered in and looks up their
+// UI strings in the message catalogs.
+//
+// Only the UI is localized here. Localized card names are stored in the
+// database's card_translations table and matched by the search package.
+package i18n
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is used when a request accepts none of the catalog
+// languages. Its catalog holds every key.
+const DefaultLanguage = "en"
+
+// Languages returns the languages with a message catalog, in alphabetical
+// order.
+func Languages() []string {
+	languages := make([]string, 0, len(catalogs))
+	for language := range catalogs {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	return languages
+}
+
+// Negotiate returns the catalog language best matching an Accept-Language
+// header value, such as "de-CH,de;q=0.9,en;q=0.5". Region subtags are
+// ignored, so "de-CH" selects "de". Returns DefaultLanguage when the header is
+// empty or names no catalog language.
+func Negotiate(acceptLanguage string) string {
+	best, bestQuality := DefaultLanguage, 0.0
+	for _, entry := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		language, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if _, ok := catalogs[language]; !ok {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+
+		if quality > bestQuality {
+			best, bestQuality = language, quality
+		}
+	}
+
+	return best
+}
+
+// FromRequest returns the catalog language negotiated from the request's
+// Accept-Language header.
+func FromRequest(request *http.Request) string {
+	return Negotiate(request.Header.Get("Accept-Language"))
+}
+
+// Translate returns the message for key in language, falling back to
+// DefaultLanguage when the language or its catalog lacks the key, and to the
+// key itself when no catalog has it.
+func Translate(language, key string) string {
+	if message, ok := catalogs[language][key]; ok {
+		return message
+	}
+
+	if message, ok := catalogs[DefaultLanguage][key]; ok {
+		return message
+	}
+
+	return key
+}
@@ -0,0 +1,38 @@
+package i18n_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"swucol/i18n"
+)
+
+func TestNegotiate_PicksHighestQualityCatalogLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: i18n.DefaultLanguage},
+		{header: "de", want: "de"},
+		{header: "fr-CA,fr;q=0.9", want: "fr"},
+		{header: "ja,es;q=0.8,de;q=0.5", want: "es"},
+		{header: "de;q=0.3, fr;q=0.7", want: "fr"},
+		{header: "ja,zh;q=0.9", want: i18n.DefaultLanguage},
+		{header: "*", want: i18n.DefaultLanguage},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, i18n.Negotiate(tt.header), tt.header)
+	}
+}
+
+func TestTranslate_FallsBackToDefaultLanguageThenKey(t *testing.T) {
+	assert.Equal(t, "Wunschliste", i18n.Translate("de", "nav.wishlist"))
+	assert.Equal(t, "Wishlist", i18n.Translate("ja", "nav.wishlist"))
+	assert.Equal(t, "no.such.key", i18n.Translate("de", "no.such.key"))
+}
+
+func TestLanguages_ListsCatalogsAlphabetically(t *testing.T) {
+	assert.Equal(t, []string{"de", "en", "es", "fr"}, i18n.Languages())
+}
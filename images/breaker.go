@@ -0,0 +1,49 @@
+package images
+
+// DefaultBreakerThreshold is the number of consecutive failed downloads after
+// which a Breaker made by import trips.
+const DefaultBreakerThreshold = 5
+
+// Breaker is a circuit breaker for a run of image downloads, such as one
+// import. After threshold downloads in a row fail in a way that suggests the
+// image host is down, it trips and Allow reports false for the rest of the
+// run, so that the remaining cards are not each retried against a dead host
+// and can be left to RetryFailed instead. Failures that are specific to one
+// image, such as 404 Not Found, do not count. A Breaker is not safe for
+// concurrent use.
+type Breaker struct {
+	threshold int
+	failures  int
+	tripped   bool
+}
+
+// NewBreaker returns a closed Breaker that trips after threshold consecutive
+// host failures. A threshold below 1 is treated as 1.
+func NewBreaker(threshold int) *Breaker {
+	return &Breaker{threshold: max(threshold, 1)}
+}
+
+// Allow reports whether the next download should be attempted.
+func (breaker *Breaker) Allow() bool {
+	return !breaker.tripped
+}
+
+// Record counts the outcome of a download. An error that DownloadWithRetry
+// would retry extends the run of failures, tripping the breaker once it
+// reaches the threshold; success or a failure specific to the image shows the
+// host is answering and ends the run. Record reports whether this call
+// tripped the breaker.
+func (breaker *Breaker) Record(err error) bool {
+	if err == nil || !retryable(err) {
+		breaker.failures = 0
+		return false
+	}
+
+	breaker.failures++
+	if breaker.tripped || breaker.failures < breaker.threshold {
+		return false
+	}
+
+	breaker.tripped = true
+	return true
+}
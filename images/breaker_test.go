@@ -0,0 +1,37 @@
+package images_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"swucol/images"
+)
+
+func TestBreaker_TripsAfterConsecutiveHostFailures(t *testing.T) {
+	breaker := images.NewBreaker(3)
+	unavailable := &images.StatusError{StatusCode: http.StatusServiceUnavailable}
+
+	assert.False(t, breaker.Record(unavailable))
+	assert.False(t, breaker.Record(errors.New("connection refused")))
+	assert.True(t, breaker.Allow())
+
+	assert.True(t, breaker.Record(unavailable), "expected the third failure to trip the breaker")
+	assert.False(t, breaker.Allow())
+	assert.False(t, breaker.Record(unavailable), "expected only the first trip to be reported")
+}
+
+func TestBreaker_SuccessOrMissingImageEndsTheRun(t *testing.T) {
+	breaker := images.NewBreaker(2)
+	unavailable := &images.StatusError{StatusCode: http.StatusBadGateway}
+
+	breaker.Record(unavailable)
+	breaker.Record(nil)
+	breaker.Record(unavailable)
+	breaker.Record(&images.StatusError{StatusCode: http.StatusNotFound})
+	breaker.Record(unavailable)
+
+	assert.True(t, breaker.Allow())
+}
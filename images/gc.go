@@ -0,0 +1,95 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"swucol/models"
+)
+
+// GCResult reports the outcome of CollectGarbage. Orphans lists the names of
+// image files no card references; they were deleted unless DryRun is true.
+type GCResult struct {
+	DryRun     bool     `json:"dry_run"`
+	Orphans    []string `json:"orphans"`
+	Deleted    int      `json:"deleted"`
+	OrphanSize int64    `json:"orphan_bytes"`
+}
+
+// referencedFileNames returns the set of image file names in use by cards.
+// A card references both the files in its image and back image columns and
+// the files (original and optimized, front and back) its set and number map
+// to, so that an image downloaded after the card was inserted is not treated
+// as an orphan. Archived cards still count as references.
+func referencedFileNames(cards []models.Card) map[string]bool {
+	referenced := make(map[string]bool, len(cards)*4)
+	for _, card := range cards {
+		if card.Image != "" {
+			referenced[filepath.Base(card.Image)] = true
+		}
+		if card.BackImage != "" {
+			referenced[filepath.Base(card.BackImage)] = true
+		}
+		if card.Set != "" && card.Number != "" {
+			referenced[FileName(card.Set, card.Number)] = true
+			referenced[OptimizedFileName(card.Set, card.Number)] = true
+			referenced[BackFileName(card.Set, card.Number)] = true
+			referenced[OptimizedBackFileName(card.Set, card.Number)] = true
+		}
+	}
+	return referenced
+}
+
+// CollectGarbage finds regular files directly inside imagesDir that none of
+// cards references and, unless dryRun is true, deletes them. Subdirectories
+// are left alone. A missing imagesDir is treated as empty. Orphans are
+// returned sorted by name.
+func CollectGarbage(imagesDir string, cards []models.Card, dryRun bool) (*GCResult, error) {
+	if imagesDir == "" {
+		return nil, errors.New("images directory must not be empty")
+	}
+
+	result := &GCResult{DryRun: dryRun, Orphans: []string{}}
+
+	entries, err := os.ReadDir(imagesDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read images directory: %w", err)
+	}
+
+	referenced := referencedFileNames(cards)
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || referenced[entry.Name()] {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("stat %q: %w", entry.Name(), err)
+		}
+
+		result.Orphans = append(result.Orphans, entry.Name())
+		result.OrphanSize += info.Size()
+	}
+
+	sort.Strings(result.Orphans)
+
+	if dryRun {
+		return result, nil
+	}
+
+	for _, name := range result.Orphans {
+		if err := os.Remove(filepath.Join(imagesDir, name)); err != nil {
+			return result, fmt.Errorf("delete %q: %w", name, err)
+		}
+		result.Deleted++
+	}
+
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package images_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/images"
+	"swucol/models"
+)
+
+// writeImageFiles creates each named file in dir with placeholder content.
+func writeImageFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("png"), 0o644))
+	}
+}
+
+func TestCollectGarbage_DryRun_ReportsOrphansWithoutDeleting(t *testing.T) {
+	dir := t.TempDir()
+	writeImageFiles(t, dir, "SOR005.png", "SOR010.png", "OLD001.png")
+
+	cards := []models.Card{
+		{Name: "Luke Skywalker, Jedi Knight", Image: filepath.Join("images", "SOR005.png")},
+		{Name: "Darth Vader, Dark Lord", Set: "SOR", Number: "010", Archived: true},
+	}
+
+	result, err := images.CollectGarbage(dir, cards, true)
+
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []string{"OLD001.png"}, result.Orphans)
+	assert.Equal(t, int64(3), result.OrphanSize)
+	assert.Equal(t, 0, result.Deleted)
+	assert.FileExists(t, filepath.Join(dir, "OLD001.png"))
+}
+
+func TestCollectGarbage_DeletesOrphansAndKeepsSubdirectories(t *testing.T) {
+	dir := t.TempDir()
+	writeImageFiles(t, dir, "SOR005.png", "OLD001.png")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "cache"), 0o755))
+
+	cards := []models.Card{{Name: "Luke Skywalker, Jedi Knight", Set: "SOR", Number: "005"}}
+
+	result, err := images.CollectGarbage(dir, cards, false)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"OLD001.png"}, result.Orphans)
+	assert.Equal(t, 1, result.Deleted)
+	assert.NoFileExists(t, filepath.Join(dir, "OLD001.png"))
+	assert.FileExists(t, filepath.Join(dir, "SOR005.png"))
+	assert.DirExists(t, filepath.Join(dir, "cache"))
+}
+
+func TestCollectGarbage_KeepsBackFaceImages(t *testing.T) {
+	dir := t.TempDir()
+	writeImageFiles(t, dir, "SOR005-back.jpg", "TWI013-back.png", "OLD001-back.png")
+
+	cards := []models.Card{
+		{Name: "Luke Skywalker, Faithful Friend", Set: "SOR", Number: "005"},
+		{Name: "Mace Windu, Vaapad Form Master", BackImage: filepath.Join("images", "TWI013-back.png")},
+	}
+
+	result, err := images.CollectGarbage(dir, cards, true)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"OLD001-back.png"}, result.Orphans)
+}
+
+func TestCollectGarbage_MissingDirectory_ReturnsEmptyResult(t *testing.T) {
+	result, err := images.CollectGarbage(filepath.Join(t.TempDir(), "missing"), nil, false)
+
+	require.NoError(t, err)
+	assert.Empty(t, result.Orphans)
+}
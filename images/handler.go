@@ -0,0 +1,162 @@
+package images
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"swucol/database"
+)
+
+// GarbageCollectHandler returns an http.HandlerFunc that handles
+// POST /admin/images/gc. It cross-references the files in imagesDir against
+// the cards table and reports image files no card uses. The "dry_run" query
+// parameter defaults to true, so orphans are only deleted when the caller
+// explicitly passes dry_run=false. Returns 200 OK with the GCResult as JSON,
+// 400 Bad Request for an invalid dry_run value, and 500 Internal Server Error
+// for database or file system errors.
+func GarbageCollectHandler(db *database.Database, imagesDir string) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "POST /admin/images/gc received")
+
+		dryRun := true
+		if rawDryRun := request.URL.Query().Get("dry_run"); rawDryRun != "" {
+			parsed, err := strconv.ParseBool(rawDryRun)
+			if err != nil {
+				http.Error(responseWriter, "dry_run must be a boolean", http.StatusBadRequest)
+				return
+			}
+			dryRun = parsed
+		}
+
+		cards, err := db.GetAllCards()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading cards for image gc", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		result, err := CollectGarbage(imagesDir, cards, dryRun)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "image gc failed", "images_dir", imagesDir, "error", err)
+			http.Error(responseWriter, "image garbage collection failed", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "image gc complete",
+			"dry_run", result.DryRun,
+			"orphans", len(result.Orphans),
+			"deleted", result.Deleted,
+			"orphan_bytes", result.OrphanSize,
+		)
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(result); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode image gc response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// writePrefetchStatus writes status as a JSON response with the given status
+// code.
+func writePrefetchStatus(responseWriter http.ResponseWriter, request *http.Request, statusCode int, status PrefetchStatus) {
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(statusCode)
+	if err := json.NewEncoder(responseWriter).Encode(status); err != nil {
+		slog.ErrorContext(request.Context(), "failed to encode prefetch status", "error", err)
+	}
+}
+
+// PrefetchStatusHandler returns an http.HandlerFunc that handles
+// GET /admin/images/prefetch. Returns 200 OK with the prefetch progress.
+func PrefetchStatusHandler(prefetcher *Prefetcher) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		writePrefetchStatus(responseWriter, request, http.StatusOK, prefetcher.Status())
+	}
+}
+
+// StartPrefetchHandler returns an http.HandlerFunc that handles
+// POST /admin/images/prefetch. It starts downloading every missing card image
+// in the background. Returns 202 Accepted with the initial progress, or 409
+// Conflict if a prefetch is already running or paused.
+func StartPrefetchHandler(prefetcher *Prefetcher) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "POST /admin/images/prefetch received")
+
+		if err := prefetcher.Start(); err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusConflict)
+			return
+		}
+
+		writePrefetchStatus(responseWriter, request, http.StatusAccepted, prefetcher.Status())
+	}
+}
+
+// PausePrefetchHandler returns an http.HandlerFunc that handles
+// POST /admin/images/prefetch/pause. Returns 200 OK with the progress, or 409
+// Conflict if no prefetch is running.
+func PausePrefetchHandler(prefetcher *Prefetcher) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if err := prefetcher.Pause(); err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusConflict)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "image prefetch paused")
+		writePrefetchStatus(responseWriter, request, http.StatusOK, prefetcher.Status())
+	}
+}
+
+// ResumePrefetchHandler returns an http.HandlerFunc that handles
+// POST /admin/images/prefetch/resume. Returns 200 OK with the progress, or 409
+// Conflict if no prefetch is paused.
+func ResumePrefetchHandler(prefetcher *Prefetcher) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if err := prefetcher.Resume(); err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusConflict)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "image prefetch resumed")
+		writePrefetchStatus(responseWriter, request, http.StatusOK, prefetcher.Status())
+	}
+}
+
+// OptimizeHandler returns an http.HandlerFunc that handles
+// POST /admin/images/optimize. It re-encodes every card's PNG image as a JPEG
+// using the optional "quality" query parameter (1-100, default
+// DefaultQuality). Returns 200 OK with an OptimizeResult as JSON, 400 Bad
+// Request for an invalid quality, and 500 Internal Server Error for database
+// errors.
+func OptimizeHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "POST /admin/images/optimize received")
+
+		quality := DefaultQuality
+		if rawQuality := request.URL.Query().Get("quality"); rawQuality != "" {
+			parsed, err := strconv.Atoi(rawQuality)
+			if err != nil || parsed < 1 || parsed > 100 {
+				http.Error(responseWriter, "quality must be an integer between 1 and 100", http.StatusBadRequest)
+				return
+			}
+			quality = parsed
+		}
+
+		result, err := OptimizeAll(db, quality)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "image optimization failed", "error", err)
+			http.Error(responseWriter, "image optimization failed", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(result); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode optimize response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package images_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/database"
+	"swucol/images"
+	"swucol/internal/testdb"
+	"swucol/models"
+)
+
+// postGarbageCollect sends a POST request to GarbageCollectHandler with the
+// given raw query string.
+func postGarbageCollect(t *testing.T, db *database.Database, imagesDir, rawQuery string) *http.Response {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/admin/images/gc?"+rawQuery, nil)
+	recorder := httptest.NewRecorder()
+
+	images.GarbageCollectHandler(db, imagesDir)(recorder, request)
+
+	return recorder.Result()
+}
+
+func TestGarbageCollectHandler_DefaultsToDryRun(t *testing.T) {
+	db := testdb.New(t)
+	dir := t.TempDir()
+	writeImageFiles(t, dir, "SOR005.png", "OLD001.png")
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Image: filepath.Join(dir, "SOR005.png")}))
+
+	response := postGarbageCollect(t, db, dir, "")
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+
+	var result images.GCResult
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []string{"OLD001.png"}, result.Orphans)
+	assert.FileExists(t, filepath.Join(dir, "OLD001.png"))
+}
+
+func TestGarbageCollectHandler_DryRunFalse_DeletesOrphans(t *testing.T) {
+	db := testdb.New(t)
+	dir := t.TempDir()
+	writeImageFiles(t, dir, "OLD001.png")
+
+	response := postGarbageCollect(t, db, dir, "dry_run=false")
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	var result images.GCResult
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	assert.Equal(t, 1, result.Deleted)
+	assert.NoFileExists(t, filepath.Join(dir, "OLD001.png"))
+}
+
+func TestGarbageCollectHandler_InvalidDryRun_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	response := postGarbageCollect(t, db, t.TempDir(), "dry_run=perhaps")
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
@@ -0,0 +1,246 @@
+package images
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hashLength is the number of hex digits of an image's SHA-256 content hash
+// used in its hashed name.
+const hashLength = 16
+
+// hashedNamePattern matches hashed image names, such as
+// "3fa94c0e5d21b7a8.jpg".
+var hashedNamePattern = regexp.MustCompile(`^[0-9a-f]{16}\.[a-z]+$`)
+
+// hashedEntry is the hashed name of an image file as of its size and
+// modification time, which were last compared with the file's at checked.
+type hashedEntry struct {
+	size    int64
+	modTime time.Time
+	name    string
+	checked time.Time
+}
+
+// Hashes names the image files in a directory after their content, so that
+// they can be served under URLs that change whenever the file does and be
+// cached by browsers for good. A hashed name is the first 16 hex digits of
+// the file's SHA-256 followed by its extension: PNG and JPEG files keep
+// their format. Hashes are computed on first use and again only when a
+// file's size or modification time changes. A Hashes is safe for concurrent
+// use.
+type Hashes struct {
+	imagesDir string
+
+	mutex sync.Mutex
+	// entries maps stored image paths, such as "images/SOR005.png", to their
+	// hashed names, and paths maps hashed names back to stored paths.
+	entries map[string]hashedEntry
+	paths   map[string]string
+	// scanned is when scan last hashed every file.
+	scanned time.Time
+}
+
+// recheckInterval is how long HashedPath trusts a file's hashed name before
+// comparing its size and modification time again, so that rendering a page
+// of images does not stat every file. Handler always compares them before
+// serving a hashed name.
+const recheckInterval = 10 * time.Second
+
+// scanInterval is the least time between two scans for unknown hashed
+// names, so that requests for made-up names cannot keep the server hashing.
+const scanInterval = time.Minute
+
+// NewHashes returns a Hashes for the image files in imagesDir.
+func NewHashes(imagesDir string) *Hashes {
+	return &Hashes{imagesDir: imagesDir, entries: map[string]hashedEntry{}, paths: map[string]string{}}
+}
+
+// HashedPath returns the path Handler serves storedPath, an image path stored
+// on a card such as "images/SOR005.png" or "images/thumbs/SOR005.jpg", at:
+// its hashed name under images/, such as "images/3fa94c0e5d21b7a8.png".
+// storedPath is returned unchanged when it is outside imagesDir or cannot be
+// read, so that a missing image keeps its old URL. A name checked within
+// recheckInterval is returned without touching the file.
+func (hashes *Hashes) HashedPath(storedPath string) string {
+	if !hashes.inImagesDir(storedPath) {
+		return storedPath
+	}
+
+	hashes.mutex.Lock()
+	entry, ok := hashes.entries[storedPath]
+	hashes.mutex.Unlock()
+	if ok && time.Since(entry.checked) < recheckInterval {
+		return "images/" + entry.name
+	}
+
+	name, err := hashes.hashedName(storedPath)
+	if err != nil {
+		return storedPath
+	}
+
+	return "images/" + name
+}
+
+// inImagesDir reports whether storedPath names a file inside imagesDir.
+func (hashes *Hashes) inImagesDir(storedPath string) bool {
+	relative, err := filepath.Rel(hashes.imagesDir, filepath.FromSlash(storedPath))
+	return err == nil && filepath.IsLocal(relative)
+}
+
+// hashedName returns the hashed name of the file at storedPath, hashing it
+// when it is new or has changed.
+func (hashes *Hashes) hashedName(storedPath string) (string, error) {
+	info, err := os.Stat(storedPath)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", storedPath)
+	}
+
+	hashes.mutex.Lock()
+	entry, ok := hashes.entries[storedPath]
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		entry.checked = time.Now()
+		hashes.entries[storedPath] = entry
+		hashes.mutex.Unlock()
+		return entry.name, nil
+	}
+	hashes.mutex.Unlock()
+
+	sum, err := hashFile(storedPath)
+	if err != nil {
+		return "", err
+	}
+	name := sum[:hashLength] + strings.ToLower(filepath.Ext(storedPath))
+
+	hashes.mutex.Lock()
+	defer hashes.mutex.Unlock()
+	hashes.entries[storedPath] = hashedEntry{size: info.Size(), modTime: info.ModTime(), name: name, checked: time.Now()}
+	hashes.paths[name] = storedPath
+
+	return name, nil
+}
+
+// hashFile returns the hex SHA-256 of the file at filePath.
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("open image: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("hash image: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// lookup returns the stored path of the file with the hashed name, and
+// whether it still has that content.
+func (hashes *Hashes) lookup(name string) (string, bool) {
+	hashes.mutex.Lock()
+	storedPath, ok := hashes.paths[name]
+	hashes.mutex.Unlock()
+	if !ok {
+		return "", false
+	}
+
+	current, err := hashes.hashedName(storedPath)
+	return storedPath, err == nil && current == name
+}
+
+// scan hashes every file in imagesDir and its subdirectories, so that hashed
+// names handed out before a restart can be found again. It does nothing when
+// the last scan was less than scanInterval ago.
+func (hashes *Hashes) scan() error {
+	hashes.mutex.Lock()
+	if time.Since(hashes.scanned) < scanInterval {
+		hashes.mutex.Unlock()
+		return nil
+	}
+	hashes.scanned = time.Now()
+	hashes.mutex.Unlock()
+
+	return filepath.WalkDir(hashes.imagesDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if _, err := hashes.hashedName(filePath); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// Handler returns an http.HandlerFunc that handles GET /images/{path...}.
+// Hashed names are served with Cache-Control: public, max-age=31536000,
+// immutable, since their content never changes; a hashed name not seen since
+// the server started is found by hashing every image. Any other path is a
+// legacy image URL, answered with 302 Found and the image's hashed URL under
+// basePath, and Cache-Control: no-cache so that the redirect follows the
+// file's changes, as is a hashed name whose file has since changed. Returns
+// 404 Not Found for an unknown image.
+func (hashes *Hashes) Handler(basePath string) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		name := request.PathValue("path")
+
+		if hashedNamePattern.MatchString(name) {
+			storedPath, ok := hashes.lookup(name)
+			if !ok {
+				if err := hashes.scan(); err != nil {
+					slog.WarnContext(request.Context(), "failed to hash images", "error", err)
+				}
+				storedPath, ok = hashes.lookup(name)
+			}
+			if !ok && storedPath != "" {
+				// The file changed after a page linked to it, for instance
+				// within recheckInterval of HashedPath's last check.
+				hashes.redirect(responseWriter, request, basePath, storedPath)
+				return
+			}
+			if !ok {
+				http.NotFound(responseWriter, request)
+				return
+			}
+
+			responseWriter.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			http.ServeFile(responseWriter, request, storedPath)
+			return
+		}
+
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			http.NotFound(responseWriter, request)
+			return
+		}
+
+		hashes.redirect(responseWriter, request, basePath, filepath.Join(hashes.imagesDir, filepath.FromSlash(name)))
+	}
+}
+
+// redirect answers with 302 Found and the current hashed URL of storedPath
+// under basePath, or 404 Not Found when it cannot be read.
+func (hashes *Hashes) redirect(responseWriter http.ResponseWriter, request *http.Request, basePath, storedPath string) {
+	hashedName, err := hashes.hashedName(storedPath)
+	if err != nil {
+		http.NotFound(responseWriter, request)
+		return
+	}
+
+	responseWriter.Header().Set("Cache-Control", "no-cache")
+	http.Redirect(responseWriter, request, basePath+"/images/"+hashedName, http.StatusFound)
+}
@@ -0,0 +1,88 @@
+package images_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/images"
+)
+
+// serveImage requests target from hashes' handler under the base path /swucol.
+func serveImage(hashes *images.Hashes, target string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /images/{path...}", hashes.Handler("/swucol"))
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder
+}
+
+func TestHashedPath_ChangesWithContent(t *testing.T) {
+	dir := t.TempDir()
+	storedPath := filepath.Join(dir, "SOR005.png")
+	require.NoError(t, os.WriteFile(storedPath, []byte("front"), 0o644))
+	hashes := images.NewHashes(dir)
+
+	hashed := hashes.HashedPath(storedPath)
+	assert.Regexp(t, `^images/[0-9a-f]{16}\.png$`, hashed)
+	assert.Equal(t, hashed, hashes.HashedPath(storedPath))
+
+	require.NoError(t, os.WriteFile(storedPath, []byte("new front"), 0o644))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(storedPath, later, later))
+
+	// The name just checked is reused without looking at the file, but is
+	// never served for the changed content: it redirects to the new name.
+	assert.Equal(t, hashed, hashes.HashedPath(storedPath))
+	recorder := serveImage(hashes, "/"+hashed)
+	require.Equal(t, http.StatusFound, recorder.Code)
+	assert.NotEqual(t, hashed, hashes.HashedPath(storedPath), "expected the handler's check to update the name")
+	assert.Equal(t, "/swucol/"+hashes.HashedPath(storedPath), recorder.Header().Get("Location"))
+
+	missing := filepath.Join(dir, "SOR006.png")
+	assert.Equal(t, missing, hashes.HashedPath(missing))
+	assert.Equal(t, "elsewhere/SOR005.png", hashes.HashedPath("elsewhere/SOR005.png"))
+}
+
+func TestHandler_ServesHashedImagesForGood(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "thumbs"), 0o755))
+	storedPath := filepath.Join(dir, "thumbs", "SOR005.jpg")
+	require.NoError(t, os.WriteFile(storedPath, []byte("thumbnail"), 0o644))
+
+	hashed := images.NewHashes(dir).HashedPath(storedPath)
+
+	// A restarted server finds names it handed out before by hashing every
+	// image.
+	recorder := serveImage(images.NewHashes(dir), "/"+hashed)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "public, max-age=31536000, immutable", recorder.Header().Get("Cache-Control"))
+	assert.Equal(t, "thumbnail", recorder.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, serveImage(images.NewHashes(dir), "/images/0123456789abcdef.jpg").Code)
+}
+
+func TestHandler_RedirectsLegacyPaths(t *testing.T) {
+	dir := t.TempDir()
+	storedPath := filepath.Join(dir, "SOR005.png")
+	require.NoError(t, os.WriteFile(storedPath, []byte("front"), 0o644))
+	hashes := images.NewHashes(dir)
+
+	recorder := serveImage(hashes, "/images/SOR005.png")
+
+	require.Equal(t, http.StatusFound, recorder.Code)
+	assert.Equal(t, "/swucol/"+hashes.HashedPath(storedPath), recorder.Header().Get("Location"))
+	assert.Equal(t, "no-cache", recorder.Header().Get("Cache-Control"))
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Location"), "/swucol/images/"))
+
+	assert.Equal(t, http.StatusNotFound, serveImage(hashes, "/images/SOR006.png").Code)
+}
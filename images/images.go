@@ -0,0 +1,187 @@
+// Package images manages the local card image files: building their remote
+// URLs and local paths, downloading them, and cleaning up files no card uses.
+package images
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DownloadInterval is the minimum duration between image downloads to stay
+// within the rate limit of 10 images per second. Download enforces it across
+// every caller in the process.
+const DownloadInterval = 100 * time.Millisecond
+
+// StatusError is returned by Download when the image server responds with a
+// status other than 200 OK.
+type StatusError struct {
+	StatusCode int
+}
+
+func (statusError *StatusError) Error() string {
+	return fmt.Sprintf("image download returned status %d", statusError.StatusCode)
+}
+
+// DefaultURLLayout is appended to an image source that contains none of the
+// URL placeholders, giving {source}/{set}/{number}.png for front faces and
+// {source}/{set}/{number}-back.png for back faces.
+const DefaultURLLayout = "/{set}/{number}{side}.png"
+
+// backSideSuffix is what the {side} placeholder expands to for back faces.
+const backSideSuffix = "-back"
+
+// urlPlaceholders are the placeholders URL replaces in an image source.
+var urlPlaceholders = []string{"{set}", "{number}", "{variant}", "{foil}", "{side}"}
+
+// Printing identifies the printing of a card whose image is downloaded: its
+// set and card number, and optionally its variant type as exported (such as
+// "Hyperspace") and whether it is foil. An empty Variant is the normal
+// printing. Back selects the back face of a double-sided card such as a
+// leader.
+type Printing struct {
+	Set     string
+	Number  string
+	Variant string
+	Foil    bool
+	Back    bool
+}
+
+// URL constructs the remote image URL of printing from the URL template
+// imageBaseURL. The template's {set}, {number}, {variant}, {foil} and {side}
+// placeholders are replaced by the set, the card number, the variant type in
+// lower case with spaces as hyphens ("normal" when empty), "foil" or
+// "nonfoil", and "-back" for back faces or nothing for front faces. A template
+// without placeholders is a base URL that DefaultURLLayout is appended to.
+// Returns an error if imageBaseURL, the set or the card number is empty, or
+// if printing is a back face and the template has no {side} placeholder to
+// tell it apart from the front.
+func URL(imageBaseURL string, printing Printing) (string, error) {
+	if imageBaseURL == "" {
+		return "", errors.New("image base URL must not be empty")
+	}
+	if printing.Set == "" {
+		return "", errors.New("set must not be empty")
+	}
+	if printing.Number == "" {
+		return "", errors.New("card number must not be empty")
+	}
+
+	template := imageBaseURL
+	if !slices.ContainsFunc(urlPlaceholders, func(placeholder string) bool { return strings.Contains(imageBaseURL, placeholder) }) {
+		template = imageBaseURL + DefaultURLLayout
+	}
+	if printing.Back && !strings.Contains(template, "{side}") {
+		return "", errors.New("image source has no {side} placeholder for back faces")
+	}
+
+	variant := strings.ToLower(strings.Join(strings.Fields(printing.Variant), "-"))
+	if variant == "" {
+		variant = "normal"
+	}
+	foil := "nonfoil"
+	if printing.Foil {
+		foil = "foil"
+	}
+	side := ""
+	if printing.Back {
+		side = backSideSuffix
+	}
+
+	return strings.NewReplacer(
+		"{set}", printing.Set,
+		"{number}", printing.Number,
+		"{variant}", url.PathEscape(variant),
+		"{foil}", foil,
+		"{side}", side,
+	).Replace(template), nil
+}
+
+// FilePath constructs the local file path where a card image is
+// saved, using the provided images directory, set, and card number.
+// Returns an error if any argument is empty.
+func FilePath(imagesDir, set, cardNumber string) (string, error) {
+	if imagesDir == "" {
+		return "", errors.New("images directory must not be empty")
+	}
+	if set == "" {
+		return "", errors.New("set must not be empty")
+	}
+	if cardNumber == "" {
+		return "", errors.New("card number must not be empty")
+	}
+	return filepath.Join(imagesDir, FileName(set, cardNumber)), nil
+}
+
+// FileName returns the name of the image file for the card with the given set
+// and card number, e.g. "SOR005.png".
+func FileName(set, cardNumber string) string {
+	return set + cardNumber + ".png"
+}
+
+// BackFilePath is FilePath for the back face image of a double-sided card.
+func BackFilePath(imagesDir, set, cardNumber string) (string, error) {
+	if _, err := FilePath(imagesDir, set, cardNumber); err != nil {
+		return "", err
+	}
+	return filepath.Join(imagesDir, BackFileName(set, cardNumber)), nil
+}
+
+// BackFileName returns the name of the back face image file for the card with
+// the given set and card number, e.g. "SOR005-back.png".
+func BackFileName(set, cardNumber string) string {
+	return set + cardNumber + backSideSuffix + ".png"
+}
+
+// Download downloads the image at imageURL and writes it to destPath, waiting
+// first until DownloadInterval has passed since the process's previous image
+// request. The parent directory of destPath is created if it does not already
+// exist.
+// Returns an error if the HTTP request fails, the server returns a non-200
+// status, or the file cannot be written.
+func Download(httpClient *http.Client, imageURL, destPath string) error {
+	if httpClient == nil {
+		return errors.New("http client must not be nil")
+	}
+	if imageURL == "" {
+		return errors.New("image URL must not be empty")
+	}
+	if destPath == "" {
+		return errors.New("destination path must not be empty")
+	}
+
+	downloadLimiter.wait()
+
+	resp, err := httpClient.Get(imageURL)
+	if err != nil {
+		return fmt.Errorf("download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("create image directory: %w", err)
+	}
+
+	file, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("create image file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		return fmt.Errorf("write image file: %w", err)
+	}
+
+	return nil
+}
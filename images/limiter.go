@@ -0,0 +1,34 @@
+package images
+
+import (
+	"sync"
+	"time"
+)
+
+// downloadLimiter paces every image request made by Download in this
+// process, so that imports, the prefetcher and the retry job running at the
+// same time stay within the image host's rate limit together.
+var downloadLimiter = &limiter{interval: DownloadInterval}
+
+// limiter hands out slots at least interval apart to any number of
+// goroutines.
+type limiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until the caller's slot, which it reserves before sleeping so
+// that concurrent callers queue up behind each other.
+func (limiter *limiter) wait() {
+	limiter.mu.Lock()
+	slot := time.Now()
+	if slot.Before(limiter.next) {
+		slot = limiter.next
+	}
+	limiter.next = slot.Add(limiter.interval)
+	limiter.mu.Unlock()
+
+	time.Sleep(time.Until(slot))
+}
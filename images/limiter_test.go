@@ -0,0 +1,48 @@
+package images_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/images"
+)
+
+func TestDownload_ConcurrentCallersShareTheRateLimit(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []time.Time
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, time.Now())
+		mu.Unlock()
+		w.Write([]byte("fake-png-data"))
+	}))
+	t.Cleanup(server.Close)
+
+	// Three callers, like an import, a prefetch and the retry job, each
+	// downloading at once.
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for i := range 3 {
+		wg.Go(func() {
+			assert.NoError(t, images.Download(server.Client(), server.URL, filepath.Join(dir, fmt.Sprintf("SOR%03d.png", i))))
+		})
+	}
+	wg.Wait()
+
+	require.Len(t, received, 3)
+	slices.SortFunc(received, func(a, b time.Time) int { return a.Compare(b) })
+	for i := 1; i < len(received); i++ {
+		assert.GreaterOrEqual(t, received[i].Sub(received[i-1]), images.DownloadInterval-5*time.Millisecond, "expected requests %d and %d to be spaced", i-1, i)
+	}
+}
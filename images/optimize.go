@@ -0,0 +1,211 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"swucol/database"
+)
+
+// DefaultQuality is the JPEG quality downloaded images are re-encoded with.
+// At 85 card art is visually unchanged while files shrink to well under half
+// the size of the original PNGs.
+const DefaultQuality = 85
+
+// OptimizedFileName returns the name of the optimized image file for the card
+// with the given set and card number, e.g. "SOR005.jpg".
+func OptimizedFileName(set, cardNumber string) string {
+	return set + cardNumber + ".jpg"
+}
+
+// OptimizedBackFileName is OptimizedFileName for the back face image, e.g.
+// "SOR005-back.jpg".
+func OptimizedBackFileName(set, cardNumber string) string {
+	return set + cardNumber + backSideSuffix + ".jpg"
+}
+
+// ExistingFilePath returns the path of the card's image in imagesDir,
+// preferring the optimized JPEG over the original PNG. Returns false when
+// neither exists or the set or card number is empty.
+func ExistingFilePath(imagesDir, set, cardNumber string) (string, bool) {
+	return existingFilePath(imagesDir, set, cardNumber, OptimizedFileName, FileName)
+}
+
+// ExistingBackFilePath is ExistingFilePath for the back face image of a
+// double-sided card.
+func ExistingBackFilePath(imagesDir, set, cardNumber string) (string, bool) {
+	return existingFilePath(imagesDir, set, cardNumber, OptimizedBackFileName, BackFileName)
+}
+
+// existingFilePath returns the first of the files named by fileNames for the
+// card that exists in imagesDir.
+func existingFilePath(imagesDir, set, cardNumber string, fileNames ...func(set, cardNumber string) string) (string, bool) {
+	if imagesDir == "" || set == "" || cardNumber == "" {
+		return "", false
+	}
+
+	for _, fileName := range fileNames {
+		path := filepath.Join(imagesDir, fileName(set, cardNumber))
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+// Optimize re-encodes the PNG at pngPath as a JPEG with the given quality
+// (1-100), written next to it with a .jpg extension. Transparent pixels are
+// flattened onto the grid background. The PNG is removed once the JPEG is
+// written. Returns the JPEG path.
+func Optimize(pngPath string, quality int) (string, error) {
+	if quality < 1 || quality > 100 {
+		return "", errors.New("quality must be between 1 and 100")
+	}
+	if !strings.HasSuffix(pngPath, ".png") {
+		return "", fmt.Errorf("%q is not a PNG file", pngPath)
+	}
+
+	srcFile, err := os.Open(pngPath)
+	if err != nil {
+		return "", fmt.Errorf("open image: %w", err)
+	}
+
+	src, _, err := image.Decode(srcFile)
+	srcFile.Close()
+	if err != nil {
+		return "", fmt.Errorf("decode image: %w", err)
+	}
+
+	jpegPath := strings.TrimSuffix(pngPath, ".png") + ".jpg"
+
+	destFile, err := os.Create(jpegPath)
+	if err != nil {
+		return "", fmt.Errorf("create optimized file: %w", err)
+	}
+
+	if err := jpeg.Encode(destFile, scaleToHeight(src, src.Bounds().Dy()), &jpeg.Options{Quality: quality}); err != nil {
+		destFile.Close()
+		os.Remove(jpegPath)
+		return "", fmt.Errorf("encode optimized image: %w", err)
+	}
+
+	if err := destFile.Close(); err != nil {
+		os.Remove(jpegPath)
+		return "", fmt.Errorf("close optimized file: %w", err)
+	}
+
+	if err := os.Remove(pngPath); err != nil {
+		return "", fmt.Errorf("remove original image: %w", err)
+	}
+
+	return jpegPath, nil
+}
+
+// OptimizeOrKeep optimizes a freshly downloaded image with DefaultQuality and
+// returns the optimized path. If optimization fails the original is kept and
+// its path returned, since an unoptimized image is still usable. The failure
+// is logged with ctx.
+func OptimizeOrKeep(ctx context.Context, pngPath string) string {
+	jpegPath, err := Optimize(pngPath, DefaultQuality)
+	if err != nil {
+		slog.WarnContext(ctx, "image optimization failed, keeping original", "path", pngPath, "error", err)
+		return pngPath
+	}
+	return jpegPath
+}
+
+// OptimizeResult reports the outcome of OptimizeAll. BytesBefore and
+// BytesAfter cover only the converted images.
+type OptimizeResult struct {
+	Quality     int   `json:"quality"`
+	Converted   int   `json:"converted"`
+	Failed      int   `json:"failed"`
+	BytesBefore int64 `json:"bytes_before"`
+	BytesAfter  int64 `json:"bytes_after"`
+}
+
+// optimizeCardFile re-encodes the image at path for OptimizeAll when it is a
+// PNG that exists, points the card at the JPEG with link, and adds the
+// outcome to result. Only errors from link or from reading the new file are
+// returned; a failed conversion is counted in result.
+func optimizeCardFile(result *OptimizeResult, name, path string, quality int, link func(jpegPath string) error) error {
+	if !strings.HasSuffix(path, ".png") {
+		return nil
+	}
+
+	before, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	jpegPath, err := Optimize(path, quality)
+	if err != nil {
+		slog.Warn("image optimization failed", "name", name, "error", err)
+		result.Failed++
+		return nil
+	}
+
+	if err := link(jpegPath); err != nil {
+		return err
+	}
+
+	after, err := os.Stat(jpegPath)
+	if err != nil {
+		return fmt.Errorf("stat optimized image: %w", err)
+	}
+
+	result.Converted++
+	result.BytesBefore += before.Size()
+	result.BytesAfter += after.Size()
+
+	return nil
+}
+
+// OptimizeAll re-encodes the PNG image and back face image of every card
+// (archived or not) as a JPEG with the given quality and points the card at
+// the new file. Images that cannot be converted are counted as failed and
+// left in place.
+func OptimizeAll(db *database.Database, quality int) (*OptimizeResult, error) {
+	if quality < 1 || quality > 100 {
+		return nil, errors.New("quality must be between 1 and 100")
+	}
+
+	cards, err := db.GetAllCards()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &OptimizeResult{Quality: quality}
+
+	for _, card := range cards {
+		if err := optimizeCardFile(result, card.Name, card.Image, quality, func(jpegPath string) error {
+			return db.SetCardImage(card.ID, jpegPath)
+		}); err != nil {
+			return result, err
+		}
+
+		if err := optimizeCardFile(result, card.Name, card.BackImage, quality, func(jpegPath string) error {
+			return db.SetCardBackImage(card.ID, jpegPath)
+		}); err != nil {
+			return result, err
+		}
+	}
+
+	slog.Info("image optimization complete",
+		"quality", quality,
+		"converted", result.Converted,
+		"failed", result.Failed,
+		"bytes_before", result.BytesBefore,
+		"bytes_after", result.BytesAfter,
+	)
+
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package images_test
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/images"
+	"swucol/internal/testdb"
+	"swucol/models"
+)
+
+// writeDetailedPNG writes a 300x420 PNG with photographic-style detail (a
+// gradient with fine texture) so compression ratios resemble real card art.
+func writeDetailedPNG(t *testing.T, path string) {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 300, 420))
+	for y := range 420 {
+		for x := range 300 {
+			noise := uint8((x*7919 + y*104729) % 23)
+			img.Set(x, y, color.RGBA{R: uint8(x*255/300) ^ noise, G: uint8(y*255/420) + noise, B: uint8((x+y)%256) ^ noise, A: 255})
+		}
+	}
+
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	require.NoError(t, png.Encode(file, img))
+}
+
+// fileSize returns the size of the file at path.
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	return info.Size()
+}
+
+func TestOptimize_ReplacesPNGWithSmallerJPEG(t *testing.T) {
+	dir := t.TempDir()
+	pngPath := filepath.Join(dir, "SOR005.png")
+	writeDetailedPNG(t, pngPath)
+	pngSize := fileSize(t, pngPath)
+
+	jpegPath, err := images.Optimize(pngPath, images.DefaultQuality)
+
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "SOR005.jpg"), jpegPath)
+	assert.NoFileExists(t, pngPath)
+	assert.Less(t, fileSize(t, jpegPath), pngSize/2, "expected optimized image to be under half the original size")
+
+	decoded := decodeJPEG(t, jpegPath)
+	assert.Equal(t, image.Rect(0, 0, 300, 420), decoded.Bounds())
+}
+
+func TestOptimize_InvalidInput_ReturnsErrorAndKeepsOriginal(t *testing.T) {
+	dir := t.TempDir()
+	pngPath := filepath.Join(dir, "SOR005.png")
+	require.NoError(t, os.WriteFile(pngPath, []byte("not an image"), 0o644))
+
+	_, err := images.Optimize(pngPath, 0)
+	assert.Error(t, err, "expected out-of-range quality to be rejected")
+
+	_, err = images.Optimize(pngPath, images.DefaultQuality)
+	assert.Error(t, err)
+	assert.FileExists(t, pngPath)
+
+	assert.Equal(t, pngPath, images.OptimizeOrKeep(context.Background(), pngPath))
+}
+
+func TestExistingFilePath_PrefersOptimizedImage(t *testing.T) {
+	dir := t.TempDir()
+
+	_, exists := images.ExistingFilePath(dir, "SOR", "005")
+	assert.False(t, exists)
+
+	writeImageFiles(t, dir, "SOR005.png")
+	path, exists := images.ExistingFilePath(dir, "SOR", "005")
+	assert.True(t, exists)
+	assert.Equal(t, filepath.Join(dir, "SOR005.png"), path)
+
+	writeImageFiles(t, dir, "SOR005.jpg")
+	path, exists = images.ExistingFilePath(dir, "SOR", "005")
+	assert.True(t, exists)
+	assert.Equal(t, filepath.Join(dir, "SOR005.jpg"), path)
+}
+
+func TestOptimizeHandler_ConvertsCardImagesAndReportsSavings(t *testing.T) {
+	db := testdb.New(t)
+	dir := t.TempDir()
+	pngPath := filepath.Join(dir, "SOR005.png")
+	writeDetailedPNG(t, pngPath)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Image: pngPath}))
+
+	recorder := httptest.NewRecorder()
+	images.OptimizeHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/admin/images/optimize?quality=70", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Result().StatusCode)
+	assert.Contains(t, recorder.Body.String(), `"quality":70`)
+	assert.Contains(t, recorder.Body.String(), `"converted":1`)
+
+	card, err := db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "SOR005.jpg"), card.Image)
+}
+
+func TestOptimizeHandler_InvalidQuality_Returns400(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	images.OptimizeHandler(testdb.New(t))(recorder, httptest.NewRequest(http.MethodPost, "/admin/images/optimize?quality=101", nil))
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Result().StatusCode)
+}
@@ -0,0 +1,138 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"swucol/database"
+	"swucol/models"
+)
+
+// aspectColors are the colours of the aspect bands on a placeholder, the
+// same as the index page's aspect icons.
+var aspectColors = map[string]string{
+	models.AspectVigilance:  "#3b82f6",
+	models.AspectCommand:    "#22c55e",
+	models.AspectAggression: "#ef4444",
+	models.AspectCunning:    "#eab308",
+	models.AspectHeroism:    "#e5e7eb",
+	models.AspectVillainy:   "#6b7280",
+}
+
+// neutralColor is the band colour of a card without known aspects.
+const neutralColor = "#9ca3af"
+
+// placeholderLineLength is the number of characters after which a card's
+// name is wrapped on a placeholder.
+const placeholderLineLength = 16
+
+// placeholderMaxLines is the most lines of a card's name a placeholder shows;
+// the last one is cut short with an ellipsis.
+const placeholderMaxLines = 4
+
+// Placeholder renders an SVG standing in for the image of a card that has
+// none: a card-shaped frame with a band in each of its aspects' colours (grey
+// without any), its set code and number, and its name wrapped over a few
+// lines.
+func Placeholder(card models.Card) []byte {
+	var aspects []string
+	for aspect := range strings.SplitSeq(card.Aspects, "|") {
+		if color, ok := aspectColors[strings.TrimSpace(aspect)]; ok {
+			aspects = append(aspects, color)
+		}
+	}
+	if len(aspects) == 0 {
+		aspects = []string{neutralColor}
+	}
+
+	var svg strings.Builder
+	svg.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 250 350" width="250" height="350">`)
+	svg.WriteString(`<rect width="250" height="350" rx="12" fill="#f3f4f6" stroke="#9ca3af" stroke-width="2"/>`)
+
+	bandWidth := 250.0 / float64(len(aspects))
+	for i, color := range aspects {
+		fmt.Fprintf(&svg, `<rect x="%.2f" y="0" width="%.2f" height="40" fill="%s"/>`, float64(i)*bandWidth, bandWidth, color)
+	}
+
+	if card.Set != "" {
+		fmt.Fprintf(&svg, `<text x="125" y="90" text-anchor="middle" font-family="sans-serif" font-size="22" fill="#4b5563">%s %s</text>`,
+			html.EscapeString(card.Set), html.EscapeString(card.Number))
+	}
+
+	for i, line := range wrapName(card.Name) {
+		fmt.Fprintf(&svg, `<text x="125" y="%d" text-anchor="middle" font-family="sans-serif" font-size="20" font-weight="bold" fill="#111827">%s</text>`,
+			160+i*26, html.EscapeString(line))
+	}
+
+	svg.WriteString(`</svg>`)
+	return []byte(svg.String())
+}
+
+// wrapName splits name into lines of at most placeholderLineLength
+// characters, breaking between words where it can, and keeps the first
+// placeholderMaxLines of them.
+func wrapName(name string) []string {
+	var lines []string
+	var line []rune
+	for word := range strings.FieldsSeq(name) {
+		runes := []rune(word)
+		if len(line) > 0 && len(line)+1+len(runes) > placeholderLineLength {
+			lines = append(lines, string(line))
+			line = nil
+		}
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, runes...)
+		for len(line) > placeholderLineLength {
+			lines = append(lines, string(line[:placeholderLineLength]))
+			line = line[placeholderLineLength:]
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+
+	if len(lines) > placeholderMaxLines {
+		last := []rune(lines[placeholderMaxLines-1])
+		lines = append(lines[:placeholderMaxLines-1], string(last[:min(len(last), placeholderLineLength-1)])+"…")
+	}
+	return lines
+}
+
+// PlaceholderHandler returns an http.HandlerFunc that handles
+// GET /images/placeholder/{id}, rendering the Placeholder of the card with
+// that id as image/svg+xml. The pages link to it for cards without an image.
+// Returns 400 Bad Request for an invalid id, 404 Not Found for an unknown
+// card, and 500 Internal Server Error for database errors.
+func PlaceholderHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, err := strconv.Atoi(request.PathValue("id"))
+		if err != nil || id <= 0 {
+			http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		card, err := db.GetCardByID(id)
+		if errors.Is(err, database.ErrCardNotFound) {
+			http.Error(responseWriter, "card not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading card for placeholder", "id", id, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "image/svg+xml")
+		responseWriter.Header().Set("Cache-Control", "no-cache")
+		if _, err := responseWriter.Write(Placeholder(*card)); err != nil {
+			slog.WarnContext(request.Context(), "failed to write placeholder", "id", id, "error", err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package images_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/database"
+	"swucol/images"
+	"swucol/internal/testdb"
+	"swucol/models"
+)
+
+// getPlaceholder requests /images/placeholder/{id} from PlaceholderHandler.
+func getPlaceholder(db *database.Database, id string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /images/placeholder/{id}", images.PlaceholderHandler(db))
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/images/placeholder/"+id, nil))
+	return recorder
+}
+
+func TestPlaceholder_ShowsSetNumberNameAndAspects(t *testing.T) {
+	svg := string(images.Placeholder(models.Card{
+		Name:    "Luke Skywalker, Faithful Friend",
+		Set:     "SOR",
+		Number:  "005",
+		Aspects: "Vigilance|Heroism",
+	}))
+
+	assert.True(t, strings.HasPrefix(svg, "<svg "))
+	assert.Contains(t, svg, ">SOR 005<")
+	assert.Contains(t, svg, ">Luke Skywalker,<")
+	assert.Contains(t, svg, ">Faithful Friend<")
+	assert.Contains(t, svg, `fill="#3b82f6"`)
+	assert.Contains(t, svg, `fill="#e5e7eb"`)
+}
+
+func TestPlaceholder_EscapesNameAndShortensLongOnes(t *testing.T) {
+	svg := string(images.Placeholder(models.Card{Name: "<Boba> & " + strings.Repeat("Fett ", 20)}))
+
+	assert.Contains(t, svg, "&lt;Boba&gt; &amp;")
+	assert.NotContains(t, svg, "<Boba>")
+	assert.Equal(t, 4, strings.Count(svg, `font-weight="bold"`))
+	assert.Contains(t, svg, "…<")
+	assert.Contains(t, svg, `fill="#9ca3af"`)
+}
+
+func TestPlaceholderHandler(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Darth Vader, Commanding the First Legion", Set: "SOR", Number: "010", Aspects: "Command|Villainy"}))
+	cards, err := db.GetAllCards()
+	require.NoError(t, err)
+	require.Len(t, cards, 1)
+
+	recorder := getPlaceholder(db, strconv.Itoa(cards[0].ID))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "image/svg+xml", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), ">SOR 010<")
+
+	assert.Equal(t, http.StatusNotFound, getPlaceholder(db, "999").Code)
+	assert.Equal(t, http.StatusBadRequest, getPlaceholder(db, "abc").Code)
+}
@@ -0,0 +1,233 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"swucol/database"
+)
+
+// PrefetchState describes what a Prefetcher is currently doing.
+type PrefetchState string
+
+const (
+	// PrefetchIdle means no prefetch has been started yet.
+	PrefetchIdle PrefetchState = "idle"
+
+	// PrefetchRunning means a prefetch is downloading images.
+	PrefetchRunning PrefetchState = "running"
+
+	// PrefetchPaused means a prefetch is waiting to be resumed.
+	PrefetchPaused PrefetchState = "paused"
+
+	// PrefetchDone means the last prefetch finished.
+	PrefetchDone PrefetchState = "done"
+)
+
+// ErrPrefetchRunning is returned by Start when a prefetch is already running
+// or paused.
+var ErrPrefetchRunning = errors.New("prefetch already in progress")
+
+// ErrPrefetchNotRunning is returned by Pause and Resume when there is no
+// prefetch in a state that can be paused or resumed.
+var ErrPrefetchNotRunning = errors.New("no prefetch to pause or resume")
+
+// PrefetchStatus is a snapshot of a Prefetcher's progress. Processed counts
+// cards examined so far out of Total; Downloaded, Linked, Skipped and Failed
+// break Processed down by outcome.
+type PrefetchStatus struct {
+	State      PrefetchState `json:"state"`
+	Total      int           `json:"total"`
+	Processed  int           `json:"processed"`
+	Downloaded int           `json:"downloaded"`
+	Linked     int           `json:"linked"`
+	Skipped    int           `json:"skipped"`
+	Failed     int           `json:"failed"`
+	Error      string        `json:"error,omitempty"`
+	StartedAt  time.Time     `json:"started_at,omitzero"`
+	FinishedAt time.Time     `json:"finished_at,omitzero"`
+}
+
+// Prefetcher walks every card and downloads any image missing from disk so
+// that browsing works entirely offline. Its downloads share the rate limit
+// of every other Download in the process. A prefetch runs in the background and can be
+// paused and resumed; only one runs at a time.
+type Prefetcher struct {
+	db            *database.Database
+	httpClient    *http.Client
+	imagesDir     string
+	imageBaseURLs []string
+
+	mu      sync.Mutex
+	resumed *sync.Cond
+	status  PrefetchStatus
+}
+
+// NewPrefetcher returns an idle Prefetcher that saves images to imagesDir,
+// downloading them from imageBaseURLs in order.
+func NewPrefetcher(db *database.Database, httpClient *http.Client, imagesDir string, imageBaseURLs []string) *Prefetcher {
+	prefetcher := &Prefetcher{
+		db:            db,
+		httpClient:    httpClient,
+		imagesDir:     imagesDir,
+		imageBaseURLs: imageBaseURLs,
+		status:        PrefetchStatus{State: PrefetchIdle},
+	}
+	prefetcher.resumed = sync.NewCond(&prefetcher.mu)
+	return prefetcher
+}
+
+// Status returns a snapshot of the current progress.
+func (prefetcher *Prefetcher) Status() PrefetchStatus {
+	prefetcher.mu.Lock()
+	defer prefetcher.mu.Unlock()
+
+	return prefetcher.status
+}
+
+// Start begins a prefetch in the background and returns immediately. Returns
+// ErrPrefetchRunning if one is already running or paused.
+func (prefetcher *Prefetcher) Start() error {
+	prefetcher.mu.Lock()
+	defer prefetcher.mu.Unlock()
+
+	if prefetcher.status.State == PrefetchRunning || prefetcher.status.State == PrefetchPaused {
+		return ErrPrefetchRunning
+	}
+
+	prefetcher.status = PrefetchStatus{State: PrefetchRunning, StartedAt: time.Now().UTC()}
+
+	go prefetcher.run()
+
+	return nil
+}
+
+// Pause stops the running prefetch before its next card. Returns
+// ErrPrefetchNotRunning if no prefetch is running.
+func (prefetcher *Prefetcher) Pause() error {
+	prefetcher.mu.Lock()
+	defer prefetcher.mu.Unlock()
+
+	if prefetcher.status.State != PrefetchRunning {
+		return ErrPrefetchNotRunning
+	}
+
+	prefetcher.status.State = PrefetchPaused
+	return nil
+}
+
+// Resume continues a paused prefetch. Returns ErrPrefetchNotRunning if no
+// prefetch is paused.
+func (prefetcher *Prefetcher) Resume() error {
+	prefetcher.mu.Lock()
+	defer prefetcher.mu.Unlock()
+
+	if prefetcher.status.State != PrefetchPaused {
+		return ErrPrefetchNotRunning
+	}
+
+	prefetcher.status.State = PrefetchRunning
+	prefetcher.resumed.Broadcast()
+	return nil
+}
+
+// waitWhilePaused blocks until the prefetch is no longer paused.
+func (prefetcher *Prefetcher) waitWhilePaused() {
+	prefetcher.mu.Lock()
+	defer prefetcher.mu.Unlock()
+
+	for prefetcher.status.State == PrefetchPaused {
+		prefetcher.resumed.Wait()
+	}
+}
+
+// record applies update to the status under the lock.
+func (prefetcher *Prefetcher) record(update func(status *PrefetchStatus)) {
+	prefetcher.mu.Lock()
+	defer prefetcher.mu.Unlock()
+
+	update(&prefetcher.status)
+}
+
+// finish marks the prefetch done, recording errorMessage if it stopped early.
+func (prefetcher *Prefetcher) finish(errorMessage string) {
+	prefetcher.record(func(status *PrefetchStatus) {
+		status.State = PrefetchDone
+		status.Error = errorMessage
+		status.FinishedAt = time.Now().UTC()
+	})
+
+	status := prefetcher.Status()
+	slog.Info("image prefetch finished",
+		"total", status.Total,
+		"downloaded", status.Downloaded,
+		"linked", status.Linked,
+		"skipped", status.Skipped,
+		"failed", status.Failed,
+		"error", status.Error,
+	)
+}
+
+// run walks every card, including archived ones. Cards without a set and
+// number are skipped. When the image file is already on disk the card is
+// only linked to it (and given a thumbnail if it lacks one); otherwise the
+// image is downloaded first. A failed
+// download is counted and the walk continues.
+func (prefetcher *Prefetcher) run() {
+	cards, err := prefetcher.db.GetAllCards()
+	if err != nil {
+		slog.Error("database error loading cards for image prefetch", "error", err)
+		prefetcher.finish("database error")
+		return
+	}
+
+	prefetcher.record(func(status *PrefetchStatus) { status.Total = len(cards) })
+	slog.Info("image prefetch started", "total", len(cards))
+
+	for _, card := range cards {
+		prefetcher.waitWhilePaused()
+
+		filePath, pathErr := FilePath(prefetcher.imagesDir, card.Set, card.Number)
+		if pathErr != nil {
+			prefetcher.record(func(status *PrefetchStatus) { status.Processed++; status.Skipped++ })
+			continue
+		}
+
+		if existingPath, exists := ExistingFilePath(prefetcher.imagesDir, card.Set, card.Number); exists {
+			if card.Image == existingPath && card.Thumbnail != "" {
+				prefetcher.record(func(status *PrefetchStatus) { status.Processed++; status.Skipped++ })
+				continue
+			}
+			if err := linkImage(prefetcher.db, prefetcher.imagesDir, card, existingPath, ""); err != nil {
+				slog.Error("database error linking prefetched image", "name", card.Name, "error", err)
+				prefetcher.finish("database error")
+				return
+			}
+			prefetcher.record(func(status *PrefetchStatus) { status.Processed++; status.Linked++ })
+			continue
+		}
+
+		source, downloadErr := DownloadFromSources(context.Background(), prefetcher.httpClient, prefetcher.imageBaseURLs, Printing{Set: card.Set, Number: card.Number}, filePath, DefaultRetryPolicy)
+		if downloadErr != nil {
+			slog.Warn("image prefetch download failed", "name", card.Name, "error", downloadErr)
+			if err := prefetcher.db.MarkCardImageFailed(card.ID); err != nil {
+				slog.Error("database error flagging failed image", "name", card.Name, "error", err)
+			}
+			prefetcher.record(func(status *PrefetchStatus) { status.Processed++; status.Failed++ })
+			continue
+		}
+
+		if err := linkImage(prefetcher.db, prefetcher.imagesDir, card, OptimizeOrKeep(context.Background(), filePath), source); err != nil {
+			slog.Error("database error saving prefetched image", "name", card.Name, "error", err)
+			prefetcher.finish("database error")
+			return
+		}
+		prefetcher.record(func(status *PrefetchStatus) { status.Processed++; status.Downloaded++ })
+	}
+
+	prefetcher.finish("")
+}
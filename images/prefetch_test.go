@@ -0,0 +1,173 @@
+package images_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/images"
+	"swucol/internal/testdb"
+	"swucol/models"
+)
+
+// waitForPrefetchState polls until prefetcher reaches state or the test times
+// out.
+func waitForPrefetchState(t *testing.T, prefetcher *images.Prefetcher, state images.PrefetchState) images.PrefetchStatus {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		return prefetcher.Status().State == state
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return prefetcher.Status()
+}
+
+func TestPrefetcher_DownloadsMissingImagesAndLinksExistingFiles(t *testing.T) {
+	db := testdb.New(t)
+	dir := t.TempDir()
+
+	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fake-png-data"))
+	}))
+	defer imageServer.Close()
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Set: "SOR", Number: "005"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Darth Vader, Dark Lord", Set: "SOR", Number: "010"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Mystery Card"}))
+	writeImageFiles(t, dir, "SOR010.png")
+
+	prefetcher := images.NewPrefetcher(db, imageServer.Client(), dir, []string{imageServer.URL})
+	require.NoError(t, prefetcher.Start())
+
+	status := waitForPrefetchState(t, prefetcher, images.PrefetchDone)
+
+	assert.Equal(t, 3, status.Total)
+	assert.Equal(t, 3, status.Processed)
+	assert.Equal(t, 1, status.Downloaded)
+	assert.Equal(t, 1, status.Linked)
+	assert.Equal(t, 1, status.Skipped)
+	assert.Equal(t, 0, status.Failed)
+	assert.FileExists(t, filepath.Join(dir, "SOR005.png"))
+
+	cards, err := db.GetAllCards()
+	require.NoError(t, err)
+	for _, card := range cards {
+		if card.Set != "" {
+			assert.Equal(t, filepath.Join(dir, images.FileName(card.Set, card.Number)), card.Image)
+		}
+	}
+}
+
+func TestPrefetcher_FailedDownload_IsCountedAndWalkContinues(t *testing.T) {
+	db := testdb.New(t)
+	dir := t.TempDir()
+
+	imageServer := httptest.NewServer(http.NotFoundHandler())
+	defer imageServer.Close()
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Set: "SOR", Number: "005"}))
+
+	prefetcher := images.NewPrefetcher(db, imageServer.Client(), dir, []string{imageServer.URL})
+	require.NoError(t, prefetcher.Start())
+
+	status := waitForPrefetchState(t, prefetcher, images.PrefetchDone)
+
+	assert.Equal(t, 1, status.Failed)
+	_, err := os.Stat(filepath.Join(dir, "SOR005.png"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPrefetcher_PauseAndResume(t *testing.T) {
+	db := testdb.New(t)
+	dir := t.TempDir()
+
+	requested := make(chan struct{}, 2)
+	release := make(chan struct{})
+	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		<-release
+		w.Write([]byte("fake-png-data"))
+	}))
+	defer imageServer.Close()
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Set: "SOR", Number: "005"}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Darth Vader, Dark Lord", Set: "SOR", Number: "010"}))
+
+	prefetcher := images.NewPrefetcher(db, imageServer.Client(), dir, []string{imageServer.URL})
+	require.NoError(t, prefetcher.Start())
+
+	<-requested
+	require.NoError(t, prefetcher.Pause())
+	release <- struct{}{}
+
+	require.Eventually(t, func() bool {
+		return prefetcher.Status().Processed == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, images.PrefetchPaused, prefetcher.Status().State)
+	assert.Equal(t, 1, prefetcher.Status().Processed, "expected no progress while paused")
+
+	require.NoError(t, prefetcher.Resume())
+	<-requested
+	release <- struct{}{}
+
+	status := waitForPrefetchState(t, prefetcher, images.PrefetchDone)
+	assert.Equal(t, 2, status.Downloaded)
+}
+
+func TestPrefetcher_StartWhileRunning_ReturnsError(t *testing.T) {
+	db := testdb.New(t)
+
+	release := make(chan struct{})
+	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer imageServer.Close()
+	defer close(release)
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Set: "SOR", Number: "005"}))
+
+	prefetcher := images.NewPrefetcher(db, imageServer.Client(), t.TempDir(), []string{imageServer.URL})
+	require.NoError(t, prefetcher.Start())
+
+	assert.ErrorIs(t, prefetcher.Start(), images.ErrPrefetchRunning)
+}
+
+func TestPrefetcher_PauseOrResumeWhenIdle_ReturnsError(t *testing.T) {
+	prefetcher := images.NewPrefetcher(testdb.New(t), http.DefaultClient, t.TempDir(), nil)
+
+	assert.ErrorIs(t, prefetcher.Pause(), images.ErrPrefetchNotRunning)
+	assert.ErrorIs(t, prefetcher.Resume(), images.ErrPrefetchNotRunning)
+	assert.Equal(t, images.PrefetchIdle, prefetcher.Status().State)
+}
+
+func TestStartPrefetchHandler_Returns202ThenStatusReportsProgress(t *testing.T) {
+	db := testdb.New(t)
+	prefetcher := images.NewPrefetcher(db, http.DefaultClient, t.TempDir(), nil)
+
+	recorder := httptest.NewRecorder()
+	images.StartPrefetchHandler(prefetcher)(recorder, httptest.NewRequest(http.MethodPost, "/admin/images/prefetch", nil))
+	assert.Equal(t, http.StatusAccepted, recorder.Result().StatusCode)
+
+	waitForPrefetchState(t, prefetcher, images.PrefetchDone)
+
+	recorder = httptest.NewRecorder()
+	images.PrefetchStatusHandler(prefetcher)(recorder, httptest.NewRequest(http.MethodGet, "/admin/images/prefetch", nil))
+	assert.Equal(t, http.StatusOK, recorder.Result().StatusCode)
+	assert.Contains(t, recorder.Body.String(), `"state":"done"`)
+}
+
+func TestPausePrefetchHandler_NotRunning_Returns409(t *testing.T) {
+	prefetcher := images.NewPrefetcher(testdb.New(t), http.DefaultClient, t.TempDir(), nil)
+
+	recorder := httptest.NewRecorder()
+	images.PausePrefetchHandler(prefetcher)(recorder, httptest.NewRequest(http.MethodPost, "/admin/images/prefetch/pause", nil))
+
+	assert.Equal(t, http.StatusConflict, recorder.Result().StatusCode)
+}
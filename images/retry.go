@@ -0,0 +1,131 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"swucol/database"
+	"swucol/metrics"
+)
+
+// RetryPolicy controls how DownloadWithRetry retries a failed download. The
+// delay before retry n (starting at 1) is BaseDelay * 2^(n-1).
+type RetryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+}
+
+// DefaultRetryPolicy makes up to three attempts, waiting 500ms and then 1s
+// between them.
+var DefaultRetryPolicy = RetryPolicy{Attempts: 3, BaseDelay: 500 * time.Millisecond}
+
+// retryable reports whether a download that failed with err may succeed if
+// tried again. Client errors other than 408 Request Timeout and 429 Too Many
+// Requests are permanent (e.g. the image does not exist), so they are not
+// retried.
+func retryable(err error) bool {
+	var statusError *StatusError
+	if !errors.As(err, &statusError) {
+		return true
+	}
+
+	switch statusError.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+
+	return statusError.StatusCode < 400 || statusError.StatusCode >= 500
+}
+
+// failureCause classifies a failed download for
+// metrics.ImageDownloadFailures: "status_<code>" for an unexpected status,
+// "network" when no response arrived, "file" when the image could not be
+// written, and "other" otherwise.
+func failureCause(err error) string {
+	var (
+		statusError *StatusError
+		urlError    *url.Error
+		netError    net.Error
+		pathError   *fs.PathError
+	)
+	switch {
+	case errors.As(err, &statusError):
+		return "status_" + strconv.Itoa(statusError.StatusCode)
+	case errors.As(err, &urlError), errors.As(err, &netError):
+		return "network"
+	case errors.As(err, &pathError):
+		return "file"
+	}
+	return "other"
+}
+
+// DownloadWithRetry calls Download, retrying transient failures with
+// exponential backoff according to policy. Returns the last error when every
+// attempt fails or the failure is not retryable, after counting it in
+// metrics.ImageDownloadFailures. Retries are logged with ctx.
+func DownloadWithRetry(ctx context.Context, httpClient *http.Client, imageURL, destPath string, policy RetryPolicy) error {
+	attempts := max(policy.Attempts, 1)
+	delay := policy.BaseDelay
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = Download(httpClient, imageURL, destPath)
+		if err == nil {
+			return nil
+		}
+		if !retryable(err) || attempt == attempts {
+			metrics.ImageDownloadFailures.Inc(failureCause(err))
+			return err
+		}
+
+		slog.DebugContext(ctx, "image download failed, retrying", "url", imageURL, "attempt", attempt, "delay", delay, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+
+	return err
+}
+
+// RetryFailed tries again to download the image of every card flagged with
+// image_failed. Cards whose download succeeds are linked to the file and the
+// flag is cleared; cards that still fail stay flagged for the next run.
+// Each image is tried from imageBaseURLs in order as DownloadFromSources does,
+// as the normal non-foil printing since cards do not record which printing
+// they were imported from. Returns the number of images recovered.
+func RetryFailed(db *database.Database, httpClient *http.Client, imagesDir string, imageBaseURLs []string, policy RetryPolicy) (int, error) {
+	cards, err := db.GetImageFailedCards()
+	if err != nil {
+		return 0, err
+	}
+
+	recovered := 0
+	for _, card := range cards {
+		filePath, err := FilePath(imagesDir, card.Set, card.Number)
+		if err != nil {
+			slog.Warn("cannot retry image for card without set or number", "name", card.Name)
+			continue
+		}
+
+		source, err := DownloadFromSources(context.Background(), httpClient, imageBaseURLs, Printing{Set: card.Set, Number: card.Number}, filePath, policy)
+		if err != nil {
+			slog.Warn("image retry failed", "name", card.Name, "error", err)
+			continue
+		}
+
+		if err := linkImage(db, imagesDir, card, OptimizeOrKeep(context.Background(), filePath), source); err != nil {
+			return recovered, err
+		}
+		recovered++
+	}
+
+	slog.Info("image retry complete", "pending", len(cards), "recovered", recovered)
+
+	return recovered, nil
+}
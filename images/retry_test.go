@@ -0,0 +1,114 @@
+package images_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/images"
+	"swucol/internal/testdb"
+	"swucol/metrics"
+	"swucol/models"
+)
+
+// testRetryPolicy retries quickly so tests stay fast.
+var testRetryPolicy = images.RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond}
+
+// newFlakyImageServer returns a server that responds with failStatus to the
+// first failures requests and with image data afterwards, plus a counter of
+// requests received.
+func newFlakyImageServer(t *testing.T, failures int32, failStatus int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(failStatus)
+			return
+		}
+		w.Write([]byte("fake-png-data"))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func TestDownloadWithRetry_TransientFailure_SucceedsOnRetry(t *testing.T) {
+	server, requests := newFlakyImageServer(t, 2, http.StatusServiceUnavailable)
+	destPath := filepath.Join(t.TempDir(), "SOR005.png")
+
+	err := images.DownloadWithRetry(context.Background(), server.Client(), server.URL, destPath, testRetryPolicy)
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), requests.Load())
+	assert.FileExists(t, destPath)
+}
+
+func TestDownloadWithRetry_GivesUpAfterAttempts(t *testing.T) {
+	server, requests := newFlakyImageServer(t, 10, http.StatusBadGateway)
+
+	err := images.DownloadWithRetry(context.Background(), server.Client(), server.URL, filepath.Join(t.TempDir(), "SOR005.png"), testRetryPolicy)
+
+	var statusError *images.StatusError
+	require.ErrorAs(t, err, &statusError)
+	assert.Equal(t, http.StatusBadGateway, statusError.StatusCode)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestDownloadWithRetry_NotFound_DoesNotRetry(t *testing.T) {
+	server, requests := newFlakyImageServer(t, 10, http.StatusNotFound)
+	failures := metrics.ImageDownloadFailures.Value("status_404")
+
+	err := images.DownloadWithRetry(context.Background(), server.Client(), server.URL, filepath.Join(t.TempDir(), "SOR005.png"), testRetryPolicy)
+
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+	assert.Equal(t, failures+1, metrics.ImageDownloadFailures.Value("status_404"), "expected the failure to be counted once")
+}
+
+func TestRetryFailed_RecoversImagesAndClearsFlag(t *testing.T) {
+	db := testdb.New(t)
+	dir := t.TempDir()
+	server, _ := newFlakyImageServer(t, 0, http.StatusOK)
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Set: "SOR", Number: "005", ImageFailed: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Darth Vader, Dark Lord", Set: "SOR", Number: "010"}))
+
+	recovered, err := images.RetryFailed(db, server.Client(), dir, []string{server.URL}, testRetryPolicy)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, recovered)
+	assert.FileExists(t, filepath.Join(dir, "SOR005.png"))
+	assert.NoFileExists(t, filepath.Join(dir, "SOR010.png"), "expected only flagged cards to be retried")
+
+	card, err := db.GetCardByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL, card.ImageSource)
+
+	failed, err := db.GetImageFailedCards()
+	require.NoError(t, err)
+	assert.Empty(t, failed)
+}
+
+func TestRetryFailed_StillFailing_StaysFlagged(t *testing.T) {
+	db := testdb.New(t)
+	server, _ := newFlakyImageServer(t, 10, http.StatusNotFound)
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Set: "SOR", Number: "005", ImageFailed: true}))
+
+	recovered, err := images.RetryFailed(db, server.Client(), t.TempDir(), []string{server.URL}, testRetryPolicy)
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, recovered)
+
+	failed, err := db.GetImageFailedCards()
+	require.NoError(t, err)
+	assert.Len(t, failed, 1)
+}
@@ -0,0 +1,69 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ParseSources parses a comma-separated list of image URL templates, in the
+// order they should be tried, as accepted by URL. Surrounding spaces and trailing
+// slashes are removed. Returns an error if the list is empty or an entry is not
+// an absolute http or https URL.
+func ParseSources(list string) ([]string, error) {
+	sources := make([]string, 0)
+	for entry := range strings.SplitSeq(list, ",") {
+		source := strings.TrimRight(strings.TrimSpace(entry), "/")
+		if source == "" {
+			continue
+		}
+
+		parsed, err := url.Parse(source)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("image source %q is not an http or https URL", source)
+		}
+		sources = append(sources, source)
+	}
+
+	if len(sources) == 0 {
+		return nil, errors.New("no image sources given")
+	}
+
+	return sources, nil
+}
+
+// DownloadFromSources downloads the image of printing to destPath, trying
+// each of imageBaseURLs in order with DownloadWithRetry until one succeeds, so
+// that one source going away or changing its layout does not leave cards
+// without images. Sources URL cannot expand for printing, such as those
+// without back faces, are skipped. Returns the base URL the image was
+// downloaded from. When every source fails, the errors of all of them are
+// returned joined.
+func DownloadFromSources(ctx context.Context, httpClient *http.Client, imageBaseURLs []string, printing Printing, destPath string, policy RetryPolicy) (string, error) {
+	if len(imageBaseURLs) == 0 {
+		return "", errors.New("no image sources configured")
+	}
+
+	failures := make([]error, 0, len(imageBaseURLs))
+	for _, imageBaseURL := range imageBaseURLs {
+		imageURL, err := URL(imageBaseURL, printing)
+		if err != nil {
+			failures = append(failures, err)
+			continue
+		}
+
+		err = DownloadWithRetry(ctx, httpClient, imageURL, destPath, policy)
+		if err == nil {
+			return imageBaseURL, nil
+		}
+
+		slog.DebugContext(ctx, "image source failed, trying the next", "url", imageURL, "error", err)
+		failures = append(failures, err)
+	}
+
+	return "", errors.Join(failures...)
+}
@@ -0,0 +1,121 @@
+package images_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/images"
+)
+
+func TestParseSources_TrimsAndKeepsOrder(t *testing.T) {
+	sources, err := images.ParseSources(" https://cdn.example.com/cards/ , http://mirror.local:8081/{set}-{number}.png")
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://cdn.example.com/cards", "http://mirror.local:8081/{set}-{number}.png"}, sources)
+}
+
+func TestParseSources_InvalidOrEmpty_ReturnsError(t *testing.T) {
+	for _, list := range []string{"", " , ", "ftp://example.com/cards", "/srv/images", "https://cdn.example.com,not a url"} {
+		_, err := images.ParseSources(list)
+		assert.Error(t, err, "list %q", list)
+	}
+}
+
+func TestURL_Placeholders_ReplaceDefaultLayout(t *testing.T) {
+	imageURL, err := images.URL("https://assets.example.com/{set}/cards/{number}_front.png", images.Printing{Set: "SOR", Number: "005"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://assets.example.com/SOR/cards/005_front.png", imageURL)
+}
+
+func TestURL_NoPlaceholders_AppendsDefaultLayout(t *testing.T) {
+	imageURL, err := images.URL("https://cdn.example.com/cards", images.Printing{Set: "SOR", Number: "005", Variant: "Hyperspace"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/cards/SOR/005.png", imageURL)
+}
+
+func TestURL_VariantAndFoilPlaceholders(t *testing.T) {
+	template := "https://cdn.example.com/{set}/{variant}/{number}-{foil}.png"
+
+	tests := []struct {
+		printing images.Printing
+		expected string
+	}{
+		{images.Printing{Set: "SOR", Number: "005"}, "https://cdn.example.com/SOR/normal/005-nonfoil.png"},
+		{images.Printing{Set: "SOR", Number: "274", Variant: "Hyperspace", Foil: true}, "https://cdn.example.com/SOR/hyperspace/274-foil.png"},
+		{images.Printing{Set: "TWI", Number: "262", Variant: "Showcase Prestige"}, "https://cdn.example.com/TWI/showcase-prestige/262-nonfoil.png"},
+	}
+	for _, test := range tests {
+		imageURL, err := images.URL(template, test.printing)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, imageURL)
+	}
+}
+
+func TestURL_BackFace_UsesSidePlaceholder(t *testing.T) {
+	imageURL, err := images.URL("https://cdn.example.com/cards", images.Printing{Set: "SOR", Number: "005", Back: true})
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/cards/SOR/005-back.png", imageURL)
+
+	imageURL, err = images.URL("https://cdn.example.com/cards", images.Printing{Set: "SOR", Number: "005"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/cards/SOR/005.png", imageURL)
+
+	_, err = images.URL("https://cdn.example.com/{set}/{number}.png", images.Printing{Set: "SOR", Number: "005", Back: true})
+	assert.Error(t, err, "expected a template without {side} to be unusable for back faces")
+}
+
+func TestURL_MissingSetOrNumber_ReturnsError(t *testing.T) {
+	_, err := images.URL("https://cdn.example.com/cards", images.Printing{Number: "005"})
+	assert.Error(t, err)
+
+	_, err = images.URL("https://cdn.example.com/cards", images.Printing{Set: "SOR"})
+	assert.Error(t, err)
+}
+
+func TestDownloadFromSources_FallsBackToNextSource(t *testing.T) {
+	broken, brokenRequests := newFlakyImageServer(t, 10, http.StatusNotFound)
+	var requested string
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		w.Write([]byte("fake-png-data"))
+	}))
+	t.Cleanup(working.Close)
+	destPath := filepath.Join(t.TempDir(), "SOR005.png")
+
+	source, err := images.DownloadFromSources(context.Background(), http.DefaultClient, []string{broken.URL, working.URL + "/mirror/{set}-{number}.png"}, images.Printing{Set: "SOR", Number: "005"}, destPath, testRetryPolicy)
+
+	require.NoError(t, err)
+	assert.Equal(t, working.URL+"/mirror/{set}-{number}.png", source)
+	assert.Equal(t, "/mirror/SOR-005.png", requested)
+	assert.Equal(t, int32(1), brokenRequests.Load())
+	data, err := os.ReadFile(destPath)
+	require.NoError(t, err)
+	assert.Equal(t, "fake-png-data", string(data))
+}
+
+func TestDownloadFromSources_AllFail_ReturnsError(t *testing.T) {
+	first, firstRequests := newFlakyImageServer(t, 10, http.StatusNotFound)
+	second, secondRequests := newFlakyImageServer(t, 10, http.StatusNotFound)
+
+	source, err := images.DownloadFromSources(context.Background(), http.DefaultClient, []string{first.URL, second.URL}, images.Printing{Set: "SOR", Number: "005"}, filepath.Join(t.TempDir(), "SOR005.png"), testRetryPolicy)
+
+	assert.Error(t, err)
+	assert.Empty(t, source)
+	assert.Equal(t, int32(1), firstRequests.Load())
+	assert.Equal(t, int32(1), secondRequests.Load())
+}
+
+func TestDownloadFromSources_NoSources_ReturnsError(t *testing.T) {
+	_, err := images.DownloadFromSources(context.Background(), http.DefaultClient, nil, images.Printing{Set: "SOR", Number: "005"}, filepath.Join(t.TempDir(), "SOR005.png"), testRetryPolicy)
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // Register the PNG decoder for card images.
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"swucol/database"
+	"swucol/models"
+)
+
+// ThumbnailHeight is the pixel height thumbnails are scaled to. It matches
+// the height card images are displayed at in the grid.
+const ThumbnailHeight = 180
+
+// thumbnailDir is the subdirectory of the images directory thumbnails are
+// stored in.
+const thumbnailDir = "thumbs"
+
+// thumbnailQuality is the JPEG quality thumbnails are encoded with.
+const thumbnailQuality = 80
+
+// thumbnailBackground is the colour transparent pixels are flattened onto,
+// matching the image background in the grid, since JPEG has no alpha channel.
+var thumbnailBackground = color.RGBA{R: 0xee, G: 0xee, B: 0xee, A: 0xff}
+
+// ThumbnailPath constructs the local file path of the thumbnail for the card
+// with the given set and card number. Returns an error if any argument is
+// empty.
+func ThumbnailPath(imagesDir, set, cardNumber string) (string, error) {
+	if imagesDir == "" {
+		return "", errors.New("images directory must not be empty")
+	}
+	if set == "" {
+		return "", errors.New("set must not be empty")
+	}
+	if cardNumber == "" {
+		return "", errors.New("card number must not be empty")
+	}
+	return filepath.Join(imagesDir, thumbnailDir, set+cardNumber+".jpg"), nil
+}
+
+// EnsureThumbnail returns the thumbnail path for the card with the given set
+// and card number, generating it from the full image at imagePath when it
+// does not exist yet.
+func EnsureThumbnail(imagesDir, set, cardNumber, imagePath string) (string, error) {
+	thumbnailPath, err := ThumbnailPath(imagesDir, set, cardNumber)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(thumbnailPath); err == nil {
+		return thumbnailPath, nil
+	}
+
+	if err := GenerateThumbnail(imagePath, thumbnailPath, ThumbnailHeight); err != nil {
+		return "", err
+	}
+
+	return thumbnailPath, nil
+}
+
+// linkImage records imagePath as card's image, downloaded from source, and
+// makes sure it has a thumbnail. An empty source leaves the recorded source
+// unchanged. A thumbnail that cannot be generated is logged and left empty
+// rather than failing, since the full image is still usable.
+func linkImage(db *database.Database, imagesDir string, card models.Card, imagePath, source string) error {
+	if err := db.SetCardImage(card.ID, imagePath); err != nil {
+		return err
+	}
+
+	if source != "" {
+		if err := db.SetCardImageSource(card.ID, source); err != nil {
+			return err
+		}
+	}
+
+	thumbnailPath, err := EnsureThumbnail(imagesDir, card.Set, card.Number, imagePath)
+	if err != nil {
+		slog.Warn("thumbnail generation failed", "name", card.Name, "error", err)
+		return nil
+	}
+
+	return db.SetCardThumbnail(card.ID, thumbnailPath)
+}
+
+// GenerateThumbnail decodes the PNG or JPEG image at srcPath, scales it to the
+// given height preserving its aspect ratio (images already that small are not
+// enlarged), and writes it to destPath as a JPEG. The parent directory of
+// destPath is created if needed.
+func GenerateThumbnail(srcPath, destPath string, height int) error {
+	if height <= 0 {
+		return errors.New("thumbnail height must be positive")
+	}
+
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("open image: %w", err)
+	}
+	defer srcFile.Close()
+
+	src, _, err := image.Decode(srcFile)
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+
+	thumbnail := scaleToHeight(src, height)
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("create thumbnail directory: %w", err)
+	}
+
+	destFile, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("create thumbnail file: %w", err)
+	}
+
+	if err := jpeg.Encode(destFile, thumbnail, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		destFile.Close()
+		os.Remove(destPath)
+		return fmt.Errorf("encode thumbnail: %w", err)
+	}
+
+	if err := destFile.Close(); err != nil {
+		return fmt.Errorf("close thumbnail file: %w", err)
+	}
+
+	return nil
+}
+
+// scaleToHeight returns src scaled down to height with a box filter, each
+// destination pixel averaging the source pixels it covers, and flattened onto
+// thumbnailBackground. Passing the source height copies src unscaled, which
+// Optimize uses to flatten full-size images.
+func scaleToHeight(src image.Image, height int) *image.RGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	if srcHeight < height {
+		height = srcHeight
+	}
+	width := max(srcWidth*height/srcHeight, 1)
+
+	dest := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := range height {
+		y0 := bounds.Min.Y + y*srcHeight/height
+		y1 := max(bounds.Min.Y+(y+1)*srcHeight/height, y0+1)
+
+		for x := range width {
+			x0 := bounds.Min.X + x*srcWidth/width
+			x1 := max(bounds.Min.X+(x+1)*srcWidth/width, x0+1)
+
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+					count++
+				}
+			}
+
+			// Premultiplied averages composited over the opaque background.
+			r, g, b, a = r/count, g/count, b/count, a/count
+			inverse := 0xffff - a
+			dest.SetRGBA(x, y, color.RGBA{
+				R: uint8((r + uint64(thumbnailBackground.R)*0x101*inverse/0xffff) >> 8),
+				G: uint8((g + uint64(thumbnailBackground.G)*0x101*inverse/0xffff) >> 8),
+				B: uint8((b + uint64(thumbnailBackground.B)*0x101*inverse/0xffff) >> 8),
+				A: 0xff,
+			})
+		}
+	}
+
+	return dest
+}
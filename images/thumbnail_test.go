@@ -0,0 +1,104 @@
+package images_test
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/images"
+)
+
+// writeTestPNG writes a width x height PNG filled with fill to path.
+func writeTestPNG(t *testing.T, path string, width, height int, fill color.Color) {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, fill)
+		}
+	}
+
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	require.NoError(t, png.Encode(file, img))
+}
+
+// decodeJPEG decodes the JPEG at path.
+func decodeJPEG(t *testing.T, path string) image.Image {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	img, err := jpeg.Decode(file)
+	require.NoError(t, err)
+	return img
+}
+
+func TestGenerateThumbnail_ScalesToHeightPreservingAspectRatio(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "SOR005.png")
+	destPath := filepath.Join(dir, "thumbs", "SOR005.jpg")
+	writeTestPNG(t, srcPath, 300, 420, color.RGBA{R: 200, A: 255})
+
+	require.NoError(t, images.GenerateThumbnail(srcPath, destPath, images.ThumbnailHeight))
+
+	thumbnail := decodeJPEG(t, destPath)
+	assert.Equal(t, 128, thumbnail.Bounds().Dx())
+	assert.Equal(t, 180, thumbnail.Bounds().Dy())
+
+	r, g, b, _ := thumbnail.At(64, 90).RGBA()
+	assert.InDelta(t, 200, r>>8, 8)
+	assert.InDelta(t, 0, g>>8, 8)
+	assert.InDelta(t, 0, b>>8, 8)
+}
+
+func TestGenerateThumbnail_SmallImage_IsNotEnlargedAndTransparencyIsFlattened(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "SOR005.png")
+	destPath := filepath.Join(dir, "SOR005.jpg")
+	writeTestPNG(t, srcPath, 40, 60, color.RGBA{})
+
+	require.NoError(t, images.GenerateThumbnail(srcPath, destPath, images.ThumbnailHeight))
+
+	thumbnail := decodeJPEG(t, destPath)
+	assert.Equal(t, image.Rect(0, 0, 40, 60), thumbnail.Bounds())
+
+	r, _, _, _ := thumbnail.At(20, 30).RGBA()
+	assert.InDelta(t, 0xee, r>>8, 4, "expected transparent pixels to be flattened onto the grid background")
+}
+
+func TestGenerateThumbnail_UndecodableImage_ReturnsErrorAndWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "SOR005.png")
+	destPath := filepath.Join(dir, "SOR005.jpg")
+	require.NoError(t, os.WriteFile(srcPath, []byte("not an image"), 0o644))
+
+	err := images.GenerateThumbnail(srcPath, destPath, images.ThumbnailHeight)
+
+	assert.Error(t, err)
+	assert.NoFileExists(t, destPath)
+}
+
+func TestEnsureThumbnail_ExistingThumbnail_IsReused(t *testing.T) {
+	dir := t.TempDir()
+	thumbnailPath, err := images.ThumbnailPath(dir, "SOR", "005")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(thumbnailPath), 0o755))
+	require.NoError(t, os.WriteFile(thumbnailPath, []byte("cached"), 0o644))
+
+	path, err := images.EnsureThumbnail(dir, "SOR", "005", filepath.Join(dir, "missing.png"))
+
+	require.NoError(t, err)
+	assert.Equal(t, thumbnailPath, path)
+}
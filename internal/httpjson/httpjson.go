@@ -0,0 +1,18 @@
+// Package httpjson writes the JSON responses of the API handlers.
+package httpjson
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// Write responds with statusCode and value as JSON. The status is sent before
+// encoding, so an encoding error can only be logged.
+func Write(responseWriter http.ResponseWriter, request *http.Request, statusCode int, value any) {
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(statusCode)
+	if err := json.NewEncoder(responseWriter).Encode(value); err != nil {
+		slog.ErrorContext(request.Context(), "failed to encode JSON response", "path", request.URL.Path, "error", err)
+	}
+}
@@ -0,0 +1,37 @@
+// Package testdb opens databases for tests, backed by temporary files that
+// are cleaned up when the test ends.
+package testdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"swucol/database"
+)
+
+// Open returns a Database backed by a file in a temporary directory, without
+// running migrations, and shuts it down when t ends.
+func Open(t testing.TB) *database.Database {
+	t.Helper()
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err, "expected no error opening test database")
+
+	t.Cleanup(func() {
+		db.Shutdown()
+	})
+
+	return db
+}
+
+// New returns a migrated Database as Open does.
+func New(t testing.TB) *database.Database {
+	t.Helper()
+
+	db := Open(t)
+	require.NoError(t, db.RunMigrations())
+
+	return db
+}
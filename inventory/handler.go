@@ -0,0 +1,318 @@
+// Package inventory provides the HTTP handlers for the accessory inventory:
+// sleeves, deck boxes, playmats and other non-card items, each with a
+// quantity. The JSON endpoints under /inventory/items are for scripts; the
+// /inventory page and its HTML fragment endpoints are driven by htmx.
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"swucol/csrf"
+	"swucol/database"
+	"swucol/internal/httpjson"
+	"swucol/models"
+	"swucol/roles"
+	"swucol/templates"
+)
+
+// kindOption is a kind choice offered by the inventory page.
+type kindOption struct {
+	Value string
+	Label string
+}
+
+// kindOptions are the item kinds in the order the page offers them.
+var kindOptions = []kindOption{
+	{Value: models.ItemKindSleeves, Label: "Sleeves"},
+	{Value: models.ItemKindDeckBox, Label: "Deck box"},
+	{Value: models.ItemKindPlaymat, Label: "Playmat"},
+	{Value: models.ItemKindOther, Label: "Other"},
+}
+
+// inventoryPage is the view model rendered by the inventory template and by
+// its inventory-items fragment.
+type inventoryPage struct {
+	Items     []models.InventoryItem
+	Kinds     []kindOption
+	Theme     string
+	Role      string
+	CSRFToken string
+}
+
+// itemRequest is the JSON body accepted by CreateHandler and UpdateHandler.
+type itemRequest struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Quantity int    `json:"quantity"`
+	Notes    string `json:"notes"`
+}
+
+// item returns the inventory item described by the request.
+func (payload itemRequest) item() models.InventoryItem {
+	return models.InventoryItem{Name: payload.Name, Kind: payload.Kind, Quantity: payload.Quantity, Notes: payload.Notes}
+}
+
+// ListHandler returns an http.HandlerFunc that handles GET /inventory/items.
+// Returns 200 OK with a JSON array of every item (empty when there are none),
+// and 500 Internal Server Error for database errors.
+func ListHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		items, err := db.GetInventoryItems()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading inventory items", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, items)
+	}
+}
+
+// CreateHandler returns an http.HandlerFunc that handles POST
+// /inventory/items with a JSON body of the form {"name": "...", "kind":
+// "sleeves", "quantity": 2, "notes": "..."}. Returns 201 Created with the
+// stored item as JSON, 400 Bad Request for an invalid body, and 500 Internal
+// Server Error for database errors.
+func CreateHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		var payload itemRequest
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		item := payload.item()
+		if err := database.ValidateInventoryItem(item); err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		created, err := db.CreateInventoryItem(item)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error creating inventory item", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "inventory item created", "item_id", created.ID, "kind", created.Kind)
+
+		httpjson.Write(responseWriter, request, http.StatusCreated, created)
+	}
+}
+
+// UpdateHandler returns an http.HandlerFunc that handles PUT
+// /inventory/items/{id} with the same JSON body as CreateHandler, replacing
+// every field of the item. Returns 200 OK with the stored item as JSON, 400
+// Bad Request for an invalid id or body, 404 Not Found if no such item
+// exists, and 500 Internal Server Error for database errors.
+func UpdateHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, ok := parseItemID(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		var payload itemRequest
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		item := payload.item()
+		item.ID = id
+		if err := database.ValidateInventoryItem(item); err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		updated, err := db.UpdateInventoryItem(item)
+		if errors.Is(err, database.ErrInventoryItemNotFound) {
+			http.Error(responseWriter, "inventory item not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error updating inventory item", "item_id", id, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, updated)
+	}
+}
+
+// DeleteHandler returns an http.HandlerFunc that handles DELETE
+// /inventory/items/{id}. Returns 204 No Content once the item is deleted, 400
+// Bad Request for an invalid id, 404 Not Found if no such item exists, and
+// 500 Internal Server Error for database errors.
+func DeleteHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, ok := parseItemID(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		if !deleteItem(responseWriter, request, db, id) {
+			return
+		}
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// PageHandler returns an http.HandlerFunc that serves the inventory page at
+// GET /inventory. Returns 500 Internal Server Error if the database query or
+// template rendering fails.
+func PageHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		renderItems(responseWriter, request, db, tmpl, "inventory")
+	}
+}
+
+// CreateHTMLHandler returns an http.HandlerFunc that handles POST
+// /inventory/html with form values "name", "kind", "quantity" and "notes".
+// Responds with the re-rendered item list, 400 Bad Request for invalid
+// values, and 500 Internal Server Error for database or template errors.
+func CreateHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if err := request.ParseForm(); err != nil {
+			templates.RenderError(responseWriter, request, tmpl, "invalid form data", http.StatusBadRequest)
+			return
+		}
+
+		item := models.InventoryItem{
+			Name:  request.PostForm.Get("name"),
+			Kind:  request.PostForm.Get("kind"),
+			Notes: request.PostForm.Get("notes"),
+		}
+		if rawQuantity := request.PostForm.Get("quantity"); rawQuantity != "" {
+			quantity, err := strconv.Atoi(rawQuantity)
+			if err != nil {
+				templates.RenderError(responseWriter, request, tmpl, "quantity must be an integer", http.StatusBadRequest)
+				return
+			}
+			item.Quantity = quantity
+		}
+
+		if err := database.ValidateInventoryItem(item); err != nil {
+			templates.RenderError(responseWriter, request, tmpl, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		created, err := db.CreateInventoryItem(item)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error creating inventory item", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "inventory item created", "item_id", created.ID, "kind", created.Kind)
+
+		renderItems(responseWriter, request, db, tmpl, "inventory-items")
+	}
+}
+
+// AdjustQuantityHTMLHandler returns an http.HandlerFunc that adds delta to
+// the quantity of the item at /inventory/items/{id}, as the page's + and −
+// buttons do, never going below zero. Responds with the re-rendered item
+// list, 400 Bad Request for an invalid id, 404 Not Found if no such item
+// exists, and 500 Internal Server Error for database or template errors.
+func AdjustQuantityHTMLHandler(db *database.Database, tmpl templates.Renderer, delta int) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, ok := parseItemID(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		_, err := db.AdjustInventoryItemQuantity(id, delta)
+		if errors.Is(err, database.ErrInventoryItemNotFound) {
+			templates.RenderError(responseWriter, request, tmpl, "inventory item not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error adjusting inventory quantity", "item_id", id, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		renderItems(responseWriter, request, db, tmpl, "inventory-items")
+	}
+}
+
+// DeleteHTMLHandler returns an http.HandlerFunc that handles DELETE
+// /inventory/items/{id}/html. Responds with the re-rendered item list, 400
+// Bad Request for an invalid id, 404 Not Found if no such item exists, and
+// 500 Internal Server Error for database or template errors.
+func DeleteHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, ok := parseItemID(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		if !deleteItem(responseWriter, request, db, id) {
+			return
+		}
+
+		renderItems(responseWriter, request, db, tmpl, "inventory-items")
+	}
+}
+
+// parseItemID reads the {id} path value. It responds with 400 Bad Request
+// and returns false when the id is not a positive integer.
+func parseItemID(responseWriter http.ResponseWriter, request *http.Request) (int, bool) {
+	id, err := strconv.Atoi(request.PathValue("id"))
+	if err != nil || id <= 0 {
+		http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// deleteItem deletes the item with the given id. It responds with the error
+// and returns false when the item does not exist or the delete fails.
+func deleteItem(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, id int) bool {
+	err := db.DeleteInventoryItem(id)
+	if errors.Is(err, database.ErrInventoryItemNotFound) {
+		http.Error(responseWriter, "inventory item not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error deleting inventory item", "item_id", id, "error", err)
+		http.Error(responseWriter, "database error", http.StatusInternalServerError)
+		return false
+	}
+
+	slog.InfoContext(request.Context(), "inventory item deleted", "item_id", id)
+
+	return true
+}
+
+// renderItems loads every item and renders templateName, either the full
+// page or the inventory-items fragment.
+func renderItems(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer, templateName string) {
+	items, err := db.GetInventoryItems()
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error loading inventory items", "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	page := inventoryPage{
+		Items:     items,
+		Kinds:     kindOptions,
+		Theme:     db.Settings().Theme,
+		Role:      roles.FromContext(request.Context()),
+		CSRFToken: csrf.Token(request.Context()),
+	}
+
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.ExecuteTemplate(responseWriter, templateName, page); err != nil {
+		slog.ErrorContext(request.Context(), "failed to render inventory template", "template", templateName, "error", err)
+		http.Error(responseWriter, "template error", http.StatusInternalServerError)
+		return
+	}
+}
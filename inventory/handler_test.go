@@ -0,0 +1,124 @@
+package inventory_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/internal/testdb"
+	"swucol/inventory"
+	"swucol/models"
+	"swucol/roles"
+	"swucol/templates"
+)
+
+func TestCreateAndUpdateHandlers_StoreItem(t *testing.T) {
+	db := testdb.New(t)
+
+	createRecorder := httptest.NewRecorder()
+	inventory.CreateHandler(db)(createRecorder, httptest.NewRequest(http.MethodPost, "/inventory/items",
+		strings.NewReader(`{"name": "Dragon Shield Matte Black", "kind": "sleeves", "quantity": 2}`)))
+
+	require.Equal(t, http.StatusCreated, createRecorder.Code, createRecorder.Body.String())
+	var created models.InventoryItem
+	require.NoError(t, json.Unmarshal(createRecorder.Body.Bytes(), &created))
+	assert.Equal(t, 2, created.Quantity)
+
+	updateRequest := httptest.NewRequest(http.MethodPut, "/inventory/items/1",
+		strings.NewReader(`{"name": "Dragon Shield Matte Black", "kind": "sleeves", "quantity": 5, "notes": "100 count"}`))
+	updateRequest.SetPathValue("id", "1")
+	updateRecorder := httptest.NewRecorder()
+	inventory.UpdateHandler(db)(updateRecorder, updateRequest)
+
+	require.Equal(t, http.StatusOK, updateRecorder.Code, updateRecorder.Body.String())
+
+	listRecorder := httptest.NewRecorder()
+	inventory.ListHandler(db)(listRecorder, httptest.NewRequest(http.MethodGet, "/inventory/items", nil))
+	var items []models.InventoryItem
+	require.NoError(t, json.Unmarshal(listRecorder.Body.Bytes(), &items))
+	require.Len(t, items, 1)
+	assert.Equal(t, 5, items[0].Quantity)
+	assert.Equal(t, "100 count", items[0].Notes)
+}
+
+func TestCreateHandler_UnknownKind_ReturnsBadRequest(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := httptest.NewRecorder()
+	inventory.CreateHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/inventory/items",
+		strings.NewReader(`{"name": "Binder", "kind": "binder"}`)))
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "kind must be one of")
+}
+
+func TestDeleteHandler_UnknownItem_ReturnsNotFound(t *testing.T) {
+	db := testdb.New(t)
+
+	request := httptest.NewRequest(http.MethodDelete, "/inventory/items/42", nil)
+	request.SetPathValue("id", "42")
+	recorder := httptest.NewRecorder()
+	inventory.DeleteHandler(db)(recorder, request)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestHTMLHandlers_AddAndAdjustItems(t *testing.T) {
+	db := testdb.New(t)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	form := url.Values{"name": {"Galactic Republic playmat"}, "kind": {models.ItemKindPlaymat}, "quantity": {"1"}}
+	createRequest := httptest.NewRequest(http.MethodPost, "/inventory/html", strings.NewReader(form.Encode()))
+	createRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	createRecorder := httptest.NewRecorder()
+	inventory.CreateHTMLHandler(db, tmpl)(createRecorder, createRequest)
+
+	require.Equal(t, http.StatusOK, createRecorder.Code, createRecorder.Body.String())
+	assert.Contains(t, createRecorder.Body.String(), "Galactic Republic playmat")
+	assert.Contains(t, createRecorder.Body.String(), "Playmat")
+
+	incrementRequest := httptest.NewRequest(http.MethodPost, "/inventory/items/1/increment/html", nil)
+	incrementRequest.SetPathValue("id", "1")
+	inventory.AdjustQuantityHTMLHandler(db, tmpl, 1)(httptest.NewRecorder(), incrementRequest)
+
+	pageRecorder := httptest.NewRecorder()
+	inventory.PageHandler(db, tmpl)(pageRecorder, httptest.NewRequest(http.MethodGet, "/inventory", nil))
+
+	require.Equal(t, http.StatusOK, pageRecorder.Code)
+	assert.Contains(t, pageRecorder.Body.String(), "<span>2</span>")
+	assert.Contains(t, pageRecorder.Body.String(), `hx-delete="/inventory/items/1/html"`)
+}
+
+func TestPageHandler_HidesControlsTheRoleCannotUse(t *testing.T) {
+	db := testdb.New(t)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+	_, err = db.CreateInventoryItem(models.InventoryItem{Name: "Galactic Republic playmat", Kind: models.ItemKindPlaymat, Quantity: 1})
+	require.NoError(t, err)
+
+	viewer := httptest.NewRecorder()
+	roles.Middleware(db, models.RoleViewer, inventory.PageHandler(db, tmpl)).ServeHTTP(viewer, httptest.NewRequest(http.MethodGet, "/inventory", nil))
+
+	require.Equal(t, http.StatusOK, viewer.Code)
+	assert.Contains(t, viewer.Body.String(), ".role-editor, .role-admin {")
+	assert.Contains(t, viewer.Body.String(), `class="inventory-btn inventory-btn-small role-editor"`)
+	assert.Contains(t, viewer.Body.String(), `class="inventory-btn inventory-btn-small inventory-btn-danger role-admin"`)
+
+	editor := httptest.NewRecorder()
+	roles.Middleware(db, models.RoleEditor, inventory.PageHandler(db, tmpl)).ServeHTTP(editor, httptest.NewRequest(http.MethodGet, "/inventory", nil))
+
+	assert.NotContains(t, editor.Body.String(), ".role-editor, .role-admin {")
+	assert.Contains(t, editor.Body.String(), ".role-admin {")
+
+	admin := httptest.NewRecorder()
+	inventory.PageHandler(db, tmpl)(admin, httptest.NewRequest(http.MethodGet, "/inventory", nil))
+
+	assert.NotContains(t, admin.Body.String(), ".role-admin {")
+}
@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"swucol/internal/httpjson"
+)
+
+// defaultRunsLimit is the number of runs GET /jobs/{name}/runs returns
+// without a "limit" parameter.
+const defaultRunsLimit = 50
+
+// ListHandler returns an http.HandlerFunc that handles GET /jobs. Returns 200
+// OK with every job's schedule, next run, whether it is running and its last
+// run as JSON, and 500 Internal Server Error for database errors.
+func ListHandler(scheduler *Scheduler) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		jobs, err := scheduler.Jobs()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "failed to get jobs", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, jobs)
+	}
+}
+
+// RunsHandler returns an http.HandlerFunc that handles GET /jobs/{name}/runs.
+// The optional "limit" parameter (default 50) caps the number of runs.
+// Returns 200 OK with the job's runs as JSON, newest first, 400 Bad Request
+// for an invalid limit, 404 Not Found for an unknown job, and 500 Internal
+// Server Error for database errors.
+func RunsHandler(scheduler *Scheduler) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		limit := defaultRunsLimit
+		if rawLimit := request.URL.Query().Get("limit"); rawLimit != "" {
+			parsed, err := strconv.Atoi(rawLimit)
+			if err != nil || parsed <= 0 {
+				http.Error(responseWriter, "limit must be a positive number", http.StatusBadRequest)
+				return
+			}
+			limit = parsed
+		}
+
+		runs, err := scheduler.Runs(request.PathValue("name"), limit)
+		if errors.Is(err, ErrUnknownJob) {
+			http.Error(responseWriter, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "failed to get job runs", "job", request.PathValue("name"), "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, runs)
+	}
+}
+
+// TriggerHandler returns an http.HandlerFunc that handles
+// POST /jobs/{name}/run. It starts the job in the background without
+// changing its schedule. Returns 202 Accepted, 404 Not Found for an unknown
+// job, and 409 Conflict if the job is already running.
+func TriggerHandler(scheduler *Scheduler) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		name := request.PathValue("name")
+		slog.InfoContext(request.Context(), "POST /jobs/{name}/run received", "job", name)
+
+		// The run outlives the request, but keeps its values, such as the
+		// request ID its log lines carry.
+		err := scheduler.Trigger(context.WithoutCancel(request.Context()), name)
+		switch {
+		case errors.Is(err, ErrUnknownJob):
+			http.Error(responseWriter, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrJobRunning):
+			http.Error(responseWriter, err.Error(), http.StatusConflict)
+		default:
+			responseWriter.WriteHeader(http.StatusAccepted)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+// Package jobs runs the server's recurring background work, such as backups,
+// image retries and valuations, on cron-like schedules (see ParseSchedule).
+// Each job's next run time is kept in the jobs table, so that a run that fell
+// due while the server was down happens once it is back, and every run, by
+// schedule or started by hand through POST /jobs/{name}/run, is recorded in
+// the job_runs table.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"swucol/database"
+	"swucol/models"
+)
+
+// ErrUnknownJob is returned when no job has the requested name.
+var ErrUnknownJob = errors.New("unknown job")
+
+// ErrJobRunning is returned when a job is started while it is still running.
+var ErrJobRunning = errors.New("job is already running")
+
+// RunFunc does a job's work. ctx is cancelled when the scheduler stops.
+type RunFunc func(ctx context.Context) error
+
+// job is a registered job and its state.
+type job struct {
+	name     string
+	spec     string
+	schedule Schedule
+	run      RunFunc
+	next     time.Time
+	running  bool
+}
+
+// Scheduler runs registered jobs on their schedules. It is safe for
+// concurrent use.
+type Scheduler struct {
+	db        *database.Database
+	overrides map[string]string
+
+	mutex sync.Mutex
+	jobs  []*job
+	// wake interrupts Run's wait when a job is registered after Run started.
+	wake chan struct{}
+	runs sync.WaitGroup
+}
+
+// New returns a scheduler storing its jobs and runs in db. overrides, keyed by
+// job name as ParseSchedules returns them, replace the default schedules jobs
+// are registered with.
+func New(db *database.Database, overrides map[string]string) *Scheduler {
+	return &Scheduler{db: db, overrides: overrides, wake: make(chan struct{}, 1)}
+}
+
+// Register adds the job name, which runs on spec unless the scheduler's
+// overrides give it another schedule. Returns an error if the name is taken
+// or the schedule does not parse.
+func (scheduler *Scheduler) Register(name, spec string, run RunFunc) error {
+	if override, ok := scheduler.overrides[name]; ok {
+		spec = override
+	}
+
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("register job %q: %w", name, err)
+	}
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if scheduler.find(name) != nil {
+		return fmt.Errorf("register job %q: name is taken", name)
+	}
+
+	scheduler.jobs = append(scheduler.jobs, &job{name: name, spec: spec, schedule: schedule, run: run})
+
+	select {
+	case scheduler.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// find returns the job name, or nil when there is none. The caller must hold
+// the mutex.
+func (scheduler *Scheduler) find(name string) *job {
+	index := slices.IndexFunc(scheduler.jobs, func(job *job) bool { return job.name == name })
+	if index < 0 {
+		return nil
+	}
+	return scheduler.jobs[index]
+}
+
+// Run runs the registered jobs when they fall due until ctx is cancelled,
+// then waits for the runs in progress to finish. It first records runs left
+// unfinished by a previous server as interrupted. A job still running when
+// it falls due again skips that run. Overrides for unregistered jobs are
+// logged and ignored.
+func (scheduler *Scheduler) Run(ctx context.Context) {
+	defer scheduler.runs.Wait()
+
+	if abandoned, err := scheduler.db.AbandonJobRuns(time.Now()); err != nil {
+		slog.Error("failed to record interrupted job runs", "error", err)
+	} else if abandoned > 0 {
+		slog.Warn("recorded interrupted job runs", "count", abandoned)
+	}
+
+	scheduler.mutex.Lock()
+	for name := range scheduler.overrides {
+		if scheduler.find(name) == nil {
+			slog.Warn("schedule set for unknown job", "job", name)
+		}
+	}
+	scheduler.mutex.Unlock()
+
+	for {
+		next := scheduler.startDue(ctx, time.Now())
+
+		var (
+			timer   *time.Timer
+			timeout <-chan time.Time
+		)
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			timeout = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-timeout:
+		case <-scheduler.wake:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// startDue starts the jobs due at now, works out when jobs first registered
+// since the last call run next, and returns the earliest next run time, or
+// the zero time when no job will run again.
+func (scheduler *Scheduler) startDue(ctx context.Context, now time.Time) time.Time {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	var earliest time.Time
+	for _, job := range scheduler.jobs {
+		if job.next.IsZero() {
+			scheduler.plan(job, now)
+		} else if !job.next.After(now) {
+			if job.running {
+				slog.Warn("skipped job run while the previous one runs", "job", job.name)
+			} else {
+				scheduler.start(ctx, job, models.JobTriggerSchedule)
+			}
+			job.next = job.schedule.Next(now)
+			scheduler.save(job)
+		}
+
+		if !job.next.IsZero() && (earliest.IsZero() || job.next.Before(earliest)) {
+			earliest = job.next
+		}
+	}
+
+	return earliest
+}
+
+// plan sets a newly registered job's next run time to the one stored for its
+// schedule, which may have passed while the server was down, or else to its
+// schedule's next run after now. The caller must hold the mutex.
+func (scheduler *Scheduler) plan(job *job, now time.Time) {
+	stored, err := scheduler.db.GetJobNextRun(job.name, job.spec)
+	if err != nil {
+		slog.Error("failed to load job next run", "job", job.name, "error", err)
+	}
+
+	job.next = stored
+	if job.next.IsZero() {
+		job.next = job.schedule.Next(now)
+		scheduler.save(job)
+	}
+}
+
+// save stores job's schedule and next run time. The caller must hold the
+// mutex.
+func (scheduler *Scheduler) save(job *job) {
+	if err := scheduler.db.SaveJob(job.name, job.spec, job.next); err != nil {
+		slog.Error("failed to save job", "job", job.name, "error", err)
+	}
+}
+
+// start runs job in the background, recording the run as triggered by
+// trigger. The caller must hold the mutex and have checked that job is not
+// running.
+func (scheduler *Scheduler) start(ctx context.Context, job *job, trigger string) {
+	job.running = true
+	scheduler.runs.Go(func() {
+		scheduler.execute(ctx, job, trigger)
+
+		scheduler.mutex.Lock()
+		job.running = false
+		scheduler.mutex.Unlock()
+	})
+}
+
+// execute runs job and records the run.
+func (scheduler *Scheduler) execute(ctx context.Context, job *job, trigger string) {
+	start := time.Now()
+	id, err := scheduler.db.StartJobRun(job.name, trigger, start)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to record job run", "job", job.name, "error", err)
+	}
+
+	slog.InfoContext(ctx, "job started", "job", job.name, "trigger", trigger)
+
+	var message string
+	if runErr := job.run(ctx); runErr != nil {
+		message = runErr.Error()
+		slog.ErrorContext(ctx, "job failed", "job", job.name, "trigger", trigger, "duration", time.Since(start), "error", runErr)
+	} else {
+		slog.InfoContext(ctx, "job finished", "job", job.name, "trigger", trigger, "duration", time.Since(start))
+	}
+
+	if id > 0 {
+		if err := scheduler.db.FinishJobRun(id, time.Now(), message); err != nil {
+			slog.ErrorContext(ctx, "failed to record job run", "job", job.name, "error", err)
+		}
+	}
+}
+
+// Trigger starts the job name now, without changing when its schedule next
+// runs it. It runs in the background with ctx. Returns ErrUnknownJob or
+// ErrJobRunning when it cannot be started.
+func (scheduler *Scheduler) Trigger(ctx context.Context, name string) error {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	job := scheduler.find(name)
+	if job == nil {
+		return ErrUnknownJob
+	}
+	if job.running {
+		return ErrJobRunning
+	}
+
+	scheduler.start(ctx, job, models.JobTriggerManual)
+
+	return nil
+}
+
+// Wait blocks until every run started so far has finished.
+func (scheduler *Scheduler) Wait() {
+	scheduler.runs.Wait()
+}
+
+// Jobs returns the status of every registered job, in the order they were
+// registered. NextRun is zero until Run has planned the job.
+func (scheduler *Scheduler) Jobs() ([]models.Job, error) {
+	lastRuns, err := scheduler.db.GetLastJobRuns()
+	if err != nil {
+		return nil, err
+	}
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	jobs := make([]models.Job, len(scheduler.jobs))
+	for i, job := range scheduler.jobs {
+		jobs[i] = models.Job{Name: job.name, Schedule: job.spec, NextRun: job.next, Running: job.running}
+		if run, ok := lastRuns[job.name]; ok {
+			jobs[i].LastRun = &run
+		}
+	}
+
+	return jobs, nil
+}
+
+// Runs returns the most recent runs of the job name, newest first, at most
+// limit of them. Returns ErrUnknownJob when there is no such job.
+func (scheduler *Scheduler) Runs(name string, limit int) ([]models.JobRun, error) {
+	scheduler.mutex.Lock()
+	known := scheduler.find(name) != nil
+	scheduler.mutex.Unlock()
+
+	if !known {
+		return nil, ErrUnknownJob
+	}
+
+	return scheduler.db.GetJobRuns(name, limit)
+}
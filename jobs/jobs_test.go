@@ -0,0 +1,200 @@
+package jobs_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/internal/testdb"
+	"swucol/jobs"
+	"swucol/models"
+)
+
+func TestParseSchedule_Next(t *testing.T) {
+	// 2024-06-15 was a Saturday.
+	after := time.Date(2024, 6, 15, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{spec: "30 3 * * *", expected: time.Date(2024, 6, 16, 3, 30, 0, 0, time.UTC)},
+		{spec: "*/15 * * * *", expected: time.Date(2024, 6, 15, 10, 15, 0, 0, time.UTC)},
+		{spec: "0 9 * * 1-5", expected: time.Date(2024, 6, 17, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 0 * * 7", expected: time.Date(2024, 6, 16, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 13 * 5", expected: time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 8,20 1 1,7 *", expected: time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)},
+		{spec: "@monthly", expected: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "@hourly", expected: time.Date(2024, 6, 15, 11, 0, 0, 0, time.UTC)},
+		{spec: "@every 90m", expected: after.Add(90 * time.Minute)},
+		{spec: "0 0 31 2 *", expected: time.Time{}},
+	}
+
+	for _, test := range tests {
+		schedule, err := jobs.ParseSchedule(test.spec)
+		require.NoError(t, err, test.spec)
+		assert.Equal(t, test.expected, schedule.Next(after), test.spec)
+	}
+}
+
+func TestParseSchedule_RejectsInvalidSchedules(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *", "@yearly", "@every 0s", "@every soon"} {
+		_, err := jobs.ParseSchedule(spec)
+		assert.Error(t, err, "expected %q to be rejected", spec)
+	}
+}
+
+func TestParseSchedules(t *testing.T) {
+	schedules, err := jobs.ParseSchedules(" backup = 0 3 * * * ; image-retry=@every 2h; ")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"backup": "0 3 * * *", "image-retry": "@every 2h"}, schedules)
+
+	for _, list := range []string{"0 3 * * *", "=@daily", "backup=@yearly", "backup=@daily;backup=@hourly"} {
+		_, err := jobs.ParseSchedules(list)
+		assert.Error(t, err, "expected %q to be rejected", list)
+	}
+}
+
+func TestRegister_UsesOverridesAndRejectsTakenNames(t *testing.T) {
+	scheduler := jobs.New(testdb.New(t), map[string]string{"backup": "@daily"})
+	noop := func(context.Context) error { return nil }
+
+	require.NoError(t, scheduler.Register("backup", "@every 24h", noop))
+	assert.Error(t, scheduler.Register("backup", "@hourly", noop))
+	assert.Error(t, scheduler.Register("digest", "@sometimes", noop))
+
+	statuses, err := scheduler.Jobs()
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "@daily", statuses[0].Schedule)
+}
+
+func TestRun_RunsOverdueJobsAndRecordsTheirRuns(t *testing.T) {
+	db := testdb.New(t)
+
+	// A run cut short by a restart, and next runs that fell due while the
+	// server was down.
+	interrupted, err := db.StartJobRun("backup", models.JobTriggerSchedule, time.Now().Add(-2*time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, db.SaveJob("backup", "@every 1h", time.Now().Add(-time.Minute)))
+	require.NoError(t, db.SaveJob("digest", "@every 1h", time.Now().Add(-time.Minute)))
+
+	scheduler := jobs.New(db, nil)
+	ran := make(chan string, 2)
+	require.NoError(t, scheduler.Register("backup", "@every 1h", func(context.Context) error {
+		ran <- "backup"
+		return nil
+	}))
+	require.NoError(t, scheduler.Register("digest", "@every 1h", func(context.Context) error {
+		ran <- "digest"
+		return errors.New("ntfy unreachable")
+	}))
+	require.NoError(t, scheduler.Register("valuation", "@every 1h", func(context.Context) error {
+		ran <- "valuation"
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+
+	assert.ElementsMatch(t, []string{"backup", "digest"}, []string{<-ran, <-ran})
+	cancel()
+	<-done
+	assert.Empty(t, ran, "expected the job without an overdue run to wait for its schedule")
+
+	statuses, err := scheduler.Jobs()
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+
+	backup := statuses[0]
+	require.NotNil(t, backup.LastRun)
+	assert.Equal(t, models.JobTriggerSchedule, backup.LastRun.Trigger)
+	assert.NotNil(t, backup.LastRun.FinishedAt)
+	assert.Empty(t, backup.LastRun.Error)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), backup.NextRun, time.Minute)
+	assert.False(t, backup.Running)
+
+	require.NotNil(t, statuses[1].LastRun)
+	assert.Equal(t, "ntfy unreachable", statuses[1].LastRun.Error)
+
+	assert.Nil(t, statuses[2].LastRun)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), statuses[2].NextRun, time.Minute)
+
+	runs, err := db.GetJobRuns("backup", 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, interrupted, runs[1].ID)
+	assert.Equal(t, "interrupted", runs[1].Error)
+	assert.NotNil(t, runs[1].FinishedAt)
+
+	stored, err := db.GetJobNextRun("backup", "@every 1h")
+	require.NoError(t, err)
+	assert.Equal(t, backup.NextRun.UTC(), stored.UTC())
+
+	stored, err = db.GetJobNextRun("backup", "@daily")
+	require.NoError(t, err)
+	assert.True(t, stored.IsZero(), "expected no next run for a changed schedule")
+}
+
+func TestHandlers_TriggerAJobAndListItsRuns(t *testing.T) {
+	scheduler := jobs.New(testdb.New(t), nil)
+	release := make(chan struct{})
+	require.NoError(t, scheduler.Register("image-retry", "@every 1h", func(context.Context) error {
+		<-release
+		return nil
+	}))
+
+	trigger := func(name string) int {
+		request := httptest.NewRequest(http.MethodPost, "/jobs/"+name+"/run", nil)
+		request.SetPathValue("name", name)
+		recorder := httptest.NewRecorder()
+		jobs.TriggerHandler(scheduler)(recorder, request)
+		return recorder.Code
+	}
+
+	assert.Equal(t, http.StatusAccepted, trigger("image-retry"))
+	assert.Equal(t, http.StatusConflict, trigger("image-retry"))
+	assert.Equal(t, http.StatusNotFound, trigger("backup"))
+
+	recorder := httptest.NewRecorder()
+	jobs.ListHandler(scheduler)(recorder, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var statuses []models.Job
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 1)
+	assert.True(t, statuses[0].Running)
+
+	close(release)
+	scheduler.Wait()
+
+	request := httptest.NewRequest(http.MethodGet, "/jobs/image-retry/runs", nil)
+	request.SetPathValue("name", "image-retry")
+	recorder = httptest.NewRecorder()
+	jobs.RunsHandler(scheduler)(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var runs []models.JobRun
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &runs))
+	require.Len(t, runs, 1)
+	assert.Equal(t, models.JobTriggerManual, runs[0].Trigger)
+	assert.NotNil(t, runs[0].FinishedAt)
+
+	for target, expected := range map[string]int{"/jobs/backup/runs": http.StatusNotFound, "/jobs/image-retry/runs?limit=0": http.StatusBadRequest} {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		request.SetPathValue("name", strings.Split(target, "/")[2])
+		recorder := httptest.NewRecorder()
+		jobs.RunsHandler(scheduler)(recorder, request)
+		assert.Equal(t, expected, recorder.Code, target)
+	}
+}
@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is when a job runs.
+type Schedule interface {
+	// Next returns the first time after after that the job runs.
+	Next(after time.Time) time.Time
+}
+
+// shorthands are the @ schedules that stand for a cron expression.
+var shorthands = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// ParseSchedule parses a cron-like schedule: "@every <duration>" (such as
+// "@every 1h30m") runs a job every duration from the previous run, one of
+// @hourly, @daily, @midnight, @weekly and @monthly, or a five-field cron
+// expression of minute (0-59), hour (0-23), day of month (1-31), month (1-12)
+// and day of week (0-6 from Sunday, or 7 for Sunday). Each cron field is "*",
+// or a comma-separated list of values and a-b ranges, each optionally with a
+// /step. As in cron, a job whose day of month and day of week are both
+// restricted runs on days matching either. Times are in the server's time
+// zone.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+		if interval < time.Second {
+			return nil, fmt.Errorf("schedule %q: interval must be at least 1s", spec)
+		}
+		return every(interval), nil
+	}
+
+	expression := spec
+	if strings.HasPrefix(spec, "@") {
+		var ok bool
+		if expression, ok = shorthands[spec]; !ok {
+			return nil, fmt.Errorf("schedule %q is not a known @ schedule", spec)
+		}
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q must have five fields: minute, hour, day of month, month and day of week", spec)
+	}
+
+	var (
+		schedule cron
+		err      error
+	)
+	if schedule.minutes, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("schedule %q: minute: %w", spec, err)
+	}
+	if schedule.hours, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("schedule %q: hour: %w", spec, err)
+	}
+	if schedule.days, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("schedule %q: day of month: %w", spec, err)
+	}
+	if schedule.months, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("schedule %q: month: %w", spec, err)
+	}
+	if schedule.weekdays, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("schedule %q: day of week: %w", spec, err)
+	}
+	if schedule.weekdays&(1<<7) != 0 {
+		// 7 is another name for Sunday.
+		schedule.weekdays |= 1
+	}
+	schedule.anyDay = fields[2] == "*"
+	schedule.anyWeekday = fields[4] == "*"
+
+	return schedule, nil
+}
+
+// every runs a job at a fixed interval.
+type every time.Duration
+
+func (interval every) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(interval))
+}
+
+// cron runs a job at the minutes matching a cron expression. Each field is a
+// bit set of the values it matches.
+type cron struct {
+	minutes, hours, days, months, weekdays uint64
+	// anyDay and anyWeekday record which day fields were "*", for the rule
+	// that restricting both matches days matching either.
+	anyDay, anyWeekday bool
+}
+
+// cronSearchYears bounds how far ahead Next looks, so that an expression no
+// date matches, such as 31 February, ends the search.
+const cronSearchYears = 5
+
+func (schedule cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case schedule.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !schedule.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case schedule.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case schedule.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// matchesDay reports whether the day fields match t's date.
+func (schedule cron) matchesDay(t time.Time) bool {
+	day := schedule.days&(1<<uint(t.Day())) != 0
+	weekday := schedule.weekdays&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case schedule.anyDay && schedule.anyWeekday:
+		return true
+	case schedule.anyDay:
+		return weekday
+	case schedule.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// parseField parses one cron field whose values range from low to high into a
+// bit set.
+func parseField(field string, low, high int) (uint64, error) {
+	var bits uint64
+	for part := range strings.SplitSeq(field, ",") {
+		valueRange, stepText, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return 0, fmt.Errorf("step %q must be a positive number", stepText)
+			}
+		}
+
+		first, last := low, high
+		if valueRange != "*" {
+			startText, endText, isRange := strings.Cut(valueRange, "-")
+
+			var err error
+			if first, err = parseValue(startText, low, high); err != nil {
+				return 0, err
+			}
+			last = first
+			if isRange {
+				if last, err = parseValue(endText, low, high); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// As in cron, a single value with a step runs to the end.
+				last = high
+			}
+			if first > last {
+				return 0, fmt.Errorf("range %q runs backwards", valueRange)
+			}
+		}
+
+		for value := first; value <= last; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	return bits, nil
+}
+
+// parseValue parses a cron field value, which must lie between low and high.
+func parseValue(text string, low, high int) (int, error) {
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("value %q is not a number", text)
+	}
+	if value < low || value > high {
+		return 0, fmt.Errorf("value %d is not between %d and %d", value, low, high)
+	}
+
+	return value, nil
+}
+
+// ParseSchedules parses a semicolon-separated list of name=schedule pairs,
+// such as "backup=0 3 * * *; image-retry=@every 2h", into schedules keyed by
+// job name. Surrounding spaces are removed. Returns an error if an entry has
+// no name, a name is repeated, or a schedule does not parse. An empty list
+// returns no schedules.
+func ParseSchedules(list string) (map[string]string, error) {
+	schedules := map[string]string{}
+	for entry := range strings.SplitSeq(list, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, spec, ok := strings.Cut(entry, "=")
+		name, spec = strings.TrimSpace(name), strings.TrimSpace(spec)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("job schedule %q must be name=schedule", entry)
+		}
+
+		if _, err := ParseSchedule(spec); err != nil {
+			return nil, fmt.Errorf("job %q: %w", name, err)
+		}
+
+		if _, ok := schedules[name]; ok {
+			return nil, fmt.Errorf("job %q is scheduled twice", name)
+		}
+
+		schedules[name] = spec
+	}
+
+	return schedules, nil
+}
@@ -0,0 +1,162 @@
+// Package labels prints QR code labels for storage boxes and cards. Each code
+// links to the quick-count page, so scanning a box label opens the cards in
+// that box and scanning a card label opens the card itself. Boxes are
+// identified by set code, as the collection is stored by set.
+package labels
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/skip2/go-qrcode"
+
+	"swucol/database"
+	"swucol/models"
+	"swucol/templates"
+)
+
+// qrCodeSize is the width and height in pixels of each QR code image; labels
+// print it at a fixed physical size.
+const qrCodeSize = 256
+
+// label is one printable label.
+type label struct {
+	Title    string
+	Subtitle string
+	URL      string
+	QRCode   template.URL
+}
+
+// labelsPage is the view model rendered by the labels template.
+type labelsPage struct {
+	Labels []label
+	Theme  string
+}
+
+// newLabel returns a label linking to target, with its QR code as a PNG data
+// URL.
+func newLabel(title, subtitle, target string) (label, error) {
+	png, err := qrcode.Encode(target, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		return label{}, fmt.Errorf("encode QR code: %w", err)
+	}
+
+	return label{
+		Title:    title,
+		Subtitle: subtitle,
+		URL:      target,
+		QRCode:   template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)),
+	}, nil
+}
+
+// baseURL returns the absolute URL the app is reached at for request, so that
+// printed codes open on the scanning phone. basePath is as returned by
+// server.NormalizeBasePath.
+func baseURL(request *http.Request, basePath string) string {
+	scheme := "http"
+	if request.TLS != nil || request.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + request.Host + basePath
+}
+
+// errInvalidCardID is returned by buildLabels for a card parameter that is not
+// a positive integer.
+var errInvalidCardID = errors.New("card must be a positive integer")
+
+// buildLabels returns a label for every "card" id and "set" code in
+// parameters, cards first. With neither, it returns a box label for every set
+// in the collection.
+func buildLabels(db *database.Database, parameters url.Values, base string) ([]label, error) {
+	sets := parameters["set"]
+	if len(parameters["card"]) == 0 && len(sets) == 0 {
+		var err error
+		if sets, err = db.GetSetCodes(); err != nil {
+			return nil, err
+		}
+	}
+
+	labels := make([]label, 0, len(parameters["card"])+len(sets))
+
+	for _, rawID := range parameters["card"] {
+		id, err := strconv.Atoi(rawID)
+		if err != nil || id <= 0 {
+			return nil, errInvalidCardID
+		}
+
+		card, err := db.GetCardByID(id)
+		if err != nil {
+			return nil, err
+		}
+
+		cardLabel, err := newLabel(card.Name, cardNumber(*card), base+"/quick?id="+strconv.Itoa(card.ID))
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, cardLabel)
+	}
+
+	for _, set := range sets {
+		setCards, err := db.GetCardsBySet(set)
+		if err != nil {
+			return nil, err
+		}
+
+		boxLabel, err := newLabel(set, fmt.Sprintf("%d cards", len(setCards)), base+"/quick?set="+url.QueryEscape(set))
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, boxLabel)
+	}
+
+	return labels, nil
+}
+
+// cardNumber formats a card's set and collector number, such as "SOR 005",
+// or returns "" when they are unknown.
+func cardNumber(card models.Card) string {
+	if card.Set == "" {
+		return ""
+	}
+	return card.Set + " " + card.Number
+}
+
+// PageHandler returns an http.HandlerFunc that serves a printable sheet of QR
+// labels at GET /labels. Repeatable "card" (card ID) and "set" (set code)
+// query parameters choose the labels; without them there is one box label per
+// set in the collection. basePath is the path the app is served under, as
+// returned by server.NormalizeBasePath. Returns 400 Bad Request for an invalid
+// card ID, 404 Not Found for an unknown card, and 500 Internal Server Error
+// for database or template errors.
+func PageHandler(db *database.Database, tmpl templates.Renderer, basePath string) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		labels, err := buildLabels(db, request.URL.Query(), baseURL(request, basePath))
+		switch {
+		case errors.Is(err, errInvalidCardID):
+			templates.RenderError(responseWriter, request, tmpl, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, database.ErrCardNotFound):
+			templates.RenderError(responseWriter, request, tmpl, "card not found", http.StatusNotFound)
+			return
+		case err != nil:
+			slog.ErrorContext(request.Context(), "failed to build labels", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		page := labelsPage{Labels: labels, Theme: db.Settings().Theme}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "labels", page); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render labels template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package labels_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/database"
+	"swucol/internal/testdb"
+	"swucol/labels"
+	"swucol/models"
+	"swucol/templates"
+)
+
+// servePage requests target from PageHandler served under basePath.
+func servePage(t *testing.T, db *database.Database, basePath, target string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	tmpl, err := templates.ParseGlob("../templates/*.html", basePath)
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	request.Host = "swucol.local:8080"
+	recorder := httptest.NewRecorder()
+	labels.PageHandler(db, tmpl, basePath)(recorder, request)
+
+	return recorder
+}
+
+func TestPageHandler_CardLabelLinksToQuickPage(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Jedi Knight", Set: "SOR", Number: "005"}))
+
+	recorder := servePage(t, db, "/swucol", "/labels?card=1")
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, "Luke Skywalker, Jedi Knight")
+	assert.Contains(t, body, "SOR 005")
+	assert.Contains(t, body, "QR code for http://swucol.local:8080/swucol/quick?id=1")
+	assert.Contains(t, body, `src="data:image/png;base64,`)
+}
+
+func TestPageHandler_NoParameters_LabelsEverySet(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCards([]models.NewCard{
+		{Name: "Luke Skywalker, Jedi Knight", Set: "SOR", Number: "005"},
+		{Name: "Chewbacca, Walking Carpet", Set: "SHD", Number: "010"},
+		{Name: "Darth Vader, Dark Lord of the Sith", Set: "SOR", Number: "010"},
+	}))
+
+	recorder := servePage(t, db, "", "/labels")
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, "quick?set=SHD")
+	assert.Contains(t, body, "quick?set=SOR")
+	assert.Contains(t, body, "2 cards")
+}
+
+func TestPageHandler_InvalidOrUnknownCard_ReturnsError(t *testing.T) {
+	db := testdb.New(t)
+
+	assert.Equal(t, http.StatusBadRequest, servePage(t, db, "", "/labels?card=abc").Code)
+	assert.Equal(t, http.StatusNotFound, servePage(t, db, "", "/labels?card=7").Code)
+}
@@ -0,0 +1,249 @@
+// Package loadtest measures the latency of a running server's endpoints, so
+// that performance regressions are caught before they ship. The "loadtest"
+// command seeds a data directory's database with synthetic cards and then
+// requests each Endpoint of the server using it concurrently, reporting
+// latency percentiles. Seeding adds cards, so use a scratch data directory.
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"swucol/database"
+	"swucol/models"
+)
+
+// setSize is the number of synthetic cards per set, since card numbers have
+// at most four digits.
+const setSize = 9999
+
+// csvHeader is the header of the import CSV format.
+const csvHeader = "Set,Card Number,Card Name,Card Title,Card Type,Aspects,Variant Type,Rarity,Foil,Stamp,Artist,Owned Count,Group Owned Count"
+
+// cardTypes and cardAspects are cycled through by Cards so that filters and
+// groupings have realistic spreads to work on. There are no leaders, whose
+// imports would fetch back face images.
+var (
+	cardTypes   = []string{"Unit", "Event", "Upgrade", "Base"}
+	cardAspects = []string{"Aggression|Heroism", "Command|Villainy", "Cunning", "Vigilance|Heroism", "Command"}
+)
+
+// Endpoint is a page or API request whose latency is measured.
+type Endpoint struct {
+	Name string
+	Path string
+}
+
+// DefaultEndpoints are the read-heavy requests the loadtest command measures:
+// live search, the collection and wishlist pages, and the excess list.
+var DefaultEndpoints = []Endpoint{
+	{Name: "search", Path: "/cards/search?q=trooper"},
+	{Name: "search html", Path: "/cards/search/html?q=trooper"},
+	{Name: "index", Path: "/"},
+	{Name: "wishlist", Path: "/wishlist"},
+	{Name: "excess", Path: "/cards/excess"},
+}
+
+// Result is the latency of the requests made to one endpoint. Errors counts
+// requests that failed or responded with a status other than 200 OK; they are
+// left out of the percentiles.
+type Result struct {
+	Endpoint Endpoint
+	Requests int
+	Errors   int
+	P50      time.Duration
+	P95      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+}
+
+// Cards returns count synthetic cards named "Load Test Trooper 00001" and so
+// on, numbered within sets LT1, LT2 and so on, with their owned counts (0 to
+// 3) keyed by name.
+func Cards(count int) ([]models.NewCard, map[string]int) {
+	cards := make([]models.NewCard, count)
+	owned := make(map[string]int, count)
+	for i := range cards {
+		cards[i] = models.NewCard{
+			Name:    fmt.Sprintf("Load Test Trooper %05d", i+1),
+			Set:     fmt.Sprintf("LT%d", i/setSize+1),
+			Number:  fmt.Sprintf("%04d", i%setSize+1),
+			Type:    cardTypes[i%len(cardTypes)],
+			Aspects: cardAspects[i%len(cardAspects)],
+			Rarity:  "Common",
+		}
+		owned[cards[i].Name] = i % 4
+	}
+	return cards, owned
+}
+
+// CSV returns Cards(count) in the import CSV format, for benchmarking imports.
+func CSV(count int) string {
+	cards, owned := Cards(count)
+
+	var builder strings.Builder
+	builder.WriteString(csvHeader + "\n")
+	for _, card := range cards {
+		fmt.Fprintf(&builder, "%s,%s,%s,,%s,%s,Normal,%s,false,,,%d,%d\n",
+			card.Set, card.Number, card.Name, card.Type, card.Aspects, card.Rarity, owned[card.Name], owned[card.Name])
+	}
+	return builder.String()
+}
+
+// Seed inserts Cards(count) into db with their owned counts. Cards are
+// inserted directly rather than imported, so no images are downloaded.
+func Seed(db *database.Database, count int) error {
+	cards, owned := Cards(count)
+
+	if err := db.InsertCards(cards); err != nil {
+		return fmt.Errorf("seed: %w", err)
+	}
+
+	if err := db.SetOwnedCountsByName(owned); err != nil {
+		return fmt.Errorf("seed: %w", err)
+	}
+
+	return nil
+}
+
+// Run requests each endpoint of the server at baseURL requests times from
+// concurrency workers, one endpoint after the other, and returns their
+// results in the same order. token, when set, is sent as a bearer token.
+// Returns an error if requests or concurrency is not positive, or ctx is
+// cancelled.
+func Run(ctx context.Context, client *http.Client, baseURL, token string, endpoints []Endpoint, requests, concurrency int) ([]Result, error) {
+	if requests <= 0 || concurrency <= 0 {
+		return nil, errors.New("requests and concurrency must be positive")
+	}
+
+	results := make([]Result, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		result := measure(ctx, client, baseURL+endpoint.Path, token, requests, concurrency)
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		result.Endpoint = endpoint
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// measure requests url requests times from concurrency workers.
+func measure(ctx context.Context, client *http.Client, url, token string, requests, concurrency int) Result {
+	jobs := make(chan struct{})
+	var (
+		mutex     sync.Mutex
+		durations []time.Duration
+		failures  int
+		workers   sync.WaitGroup
+	)
+
+	for range min(concurrency, requests) {
+		workers.Go(func() {
+			for range jobs {
+				duration, err := get(ctx, client, url, token)
+
+				mutex.Lock()
+				if err != nil {
+					failures++
+				} else {
+					durations = append(durations, duration)
+				}
+				mutex.Unlock()
+			}
+		})
+	}
+
+	for range requests {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	workers.Wait()
+
+	slices.Sort(durations)
+	result := Result{Requests: len(durations) + failures, Errors: failures}
+	if len(durations) > 0 {
+		result.P50 = percentile(durations, 50)
+		result.P95 = percentile(durations, 95)
+		result.P99 = percentile(durations, 99)
+		result.Max = durations[len(durations)-1]
+	}
+
+	return result
+}
+
+// get requests url and returns how long the response took to read in full.
+// Returns an error for a failed request or a status other than 200 OK.
+func get(ctx context.Context, client *http.Client, url, token string) (time.Duration, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	authorize(request, token)
+
+	start := time.Now()
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if _, err := io.Copy(io.Discard, response.Body); err != nil {
+		return 0, err
+	}
+	duration := time.Since(start)
+
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("status %d", response.StatusCode)
+	}
+
+	return duration, nil
+}
+
+// authorize sets token as the request's bearer token when it is not empty.
+func authorize(request *http.Request, token string) {
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// percentile returns the nearest-rank pth percentile of sorted, which must
+// not be empty.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// WriteReport writes results to writer as an aligned table.
+func WriteReport(writer io.Writer, results []Result) error {
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ENDPOINT\tREQUESTS\tERRORS\tP50\tP95\tP99\tMAX")
+	for _, result := range results {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n",
+			result.Endpoint.Name, result.Requests, result.Errors,
+			round(result.P50), round(result.P95), round(result.P99), round(result.Max))
+	}
+
+	if err := table.Flush(); err != nil {
+		return fmt.Errorf("write load test report: %w", err)
+	}
+
+	return nil
+}
+
+// round rounds duration for display.
+func round(duration time.Duration) time.Duration {
+	return duration.Round(10 * time.Microsecond)
+}
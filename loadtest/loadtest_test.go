@@ -0,0 +1,92 @@
+package loadtest_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/cards"
+	"swucol/images"
+	"swucol/internal/testdb"
+	"swucol/loadtest"
+)
+
+func TestSeed_InsertsSyntheticCardsWithOwnedCounts(t *testing.T) {
+	db := testdb.New(t)
+
+	require.NoError(t, loadtest.Seed(db, 25))
+
+	found, err := db.SearchCards("trooper")
+	require.NoError(t, err)
+	assert.Len(t, found, 25)
+
+	owned, err := db.SearchCards("owned>=3")
+	require.NoError(t, err)
+	assert.Len(t, owned, 6)
+}
+
+func TestCSV_IsAValidImport(t *testing.T) {
+	db := testdb.New(t)
+	imagesDir := t.TempDir()
+	// With an image on disk for every card, the import downloads nothing.
+	seeded, _ := loadtest.Cards(10)
+	for _, card := range seeded {
+		path, err := images.FilePath(imagesDir, card.Set, card.Number)
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+	}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/cards/import", strings.NewReader(loadtest.CSV(10)))
+	cards.ImportCardsHandler(db, cards.NewImportLock(), http.DefaultClient, imagesDir, []string{"http://127.0.0.1:0"})(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	found, err := db.SearchCards("trooper")
+	require.NoError(t, err)
+	assert.Len(t, found, 10)
+}
+
+func TestRun_ReportsLatencyAndErrorsPerEndpoint(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, loadtest.Seed(db, 10))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cards/search", cards.SearchCardsHandler(db))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	endpoints := []loadtest.Endpoint{
+		{Name: "search", Path: "/cards/search?q=trooper"},
+		{Name: "missing", Path: "/missing"},
+	}
+	results, err := loadtest.Run(context.Background(), server.Client(), server.URL, "", endpoints, 20, 4)
+
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, 20, results[0].Requests)
+	assert.Zero(t, results[0].Errors)
+	assert.Positive(t, results[0].P50)
+	assert.LessOrEqual(t, results[0].P50, results[0].P99)
+	assert.LessOrEqual(t, results[0].P99, results[0].Max)
+	assert.Equal(t, 20, results[1].Errors, "expected 404 responses to count as errors")
+
+	var report bytes.Buffer
+	require.NoError(t, loadtest.WriteReport(&report, results))
+	assert.True(t, strings.HasPrefix(report.String(), "ENDPOINT"))
+	assert.Contains(t, report.String(), "search")
+}
+
+func TestRun_NonPositiveRequests_ReturnsError(t *testing.T) {
+	_, err := loadtest.Run(context.Background(), http.DefaultClient, "http://127.0.0.1:0", "", loadtest.DefaultEndpoints, 0, 1)
+
+	assert.Error(t, err)
+}
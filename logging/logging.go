@@ -0,0 +1,95 @@
+// Package logging builds the application's slog logger from the configured
+// level and format, and serves the endpoints that read and change the level
+// while the server runs.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"swucol/requestid"
+)
+
+// Log output formats accepted by NewLogger.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ParseLevel parses a level name such as "debug", "info", "warn" or "error",
+// case-insensitively.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: must be debug, info, warn, or error", name)
+	}
+	return level, nil
+}
+
+// NewLogger returns a logger writing to writer in format, FormatText or
+// FormatJSON, that drops records below level. Records logged with a request
+// context carry the request ID.
+func NewLogger(writer io.Writer, format string, level *slog.LevelVar) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case FormatText:
+		handler = slog.NewTextHandler(writer, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(writer, options)
+	default:
+		return nil, fmt.Errorf("invalid log format %q: must be text or json", format)
+	}
+
+	return slog.New(requestid.NewLogHandler(handler)), nil
+}
+
+// levelResponse is the JSON body returned by the log level endpoints.
+type levelResponse struct {
+	Level string `json:"level"`
+}
+
+// GetLevelHandler returns an http.HandlerFunc that handles GET
+// /admin/loglevel. Returns 200 OK with the current level as JSON.
+func GetLevelHandler(level *slog.LevelVar) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		writeLevel(responseWriter, request, level.Level())
+	}
+}
+
+// SetLevelHandler returns an http.HandlerFunc that handles POST
+// /admin/loglevel. The "level" query parameter or form field selects the new
+// level, which applies to every log line from then on until the server
+// restarts. Returns 200 OK with the new level as JSON, or 400 Bad Request for
+// a missing or unknown level.
+func SetLevelHandler(level *slog.LevelVar) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		newLevel, err := ParseLevel(request.FormValue("level"))
+		if err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		previous := level.Level()
+		level.Set(newLevel)
+
+		slog.WarnContext(request.Context(), "log level changed", "from", previous, "to", newLevel)
+
+		writeLevel(responseWriter, request, newLevel)
+	}
+}
+
+// writeLevel responds with level as JSON.
+func writeLevel(responseWriter http.ResponseWriter, request *http.Request, level slog.Level) {
+	responseWriter.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(responseWriter).Encode(levelResponse{Level: level.String()}); err != nil {
+		slog.ErrorContext(request.Context(), "failed to encode log level response", "error", err)
+		http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,77 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/logging"
+)
+
+func TestNewLogger_JSONFormat_WritesJSONRecords(t *testing.T) {
+	var output bytes.Buffer
+	level := new(slog.LevelVar)
+
+	logger, err := logging.NewLogger(&output, "json", level)
+	require.NoError(t, err)
+	logger.Info("started", "port", 8080)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(output.Bytes(), &record))
+	assert.Equal(t, "started", record["msg"])
+	assert.Equal(t, float64(8080), record["port"])
+}
+
+func TestNewLogger_InvalidFormat_ReturnsError(t *testing.T) {
+	_, err := logging.NewLogger(&bytes.Buffer{}, "xml", new(slog.LevelVar))
+
+	assert.ErrorContains(t, err, "invalid log format")
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := logging.ParseLevel("DEBUG")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	_, err = logging.ParseLevel("verbose")
+	assert.ErrorContains(t, err, "invalid log level")
+}
+
+func TestSetLevelHandler_ChangesLevelOfExistingLogger(t *testing.T) {
+	var output bytes.Buffer
+	level := new(slog.LevelVar)
+	logger, err := logging.NewLogger(&output, "text", level)
+	require.NoError(t, err)
+
+	logger.Debug("hidden")
+	assert.Empty(t, output.String())
+
+	recorder := httptest.NewRecorder()
+	logging.SetLevelHandler(level)(recorder, httptest.NewRequest(http.MethodPost, "/admin/loglevel?level=debug", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"level": "DEBUG"}`, recorder.Body.String())
+
+	logger.Debug("shown")
+	assert.Contains(t, output.String(), "shown")
+
+	getRecorder := httptest.NewRecorder()
+	logging.GetLevelHandler(level)(getRecorder, httptest.NewRequest(http.MethodGet, "/admin/loglevel", nil))
+	assert.JSONEq(t, `{"level": "DEBUG"}`, getRecorder.Body.String())
+}
+
+func TestSetLevelHandler_InvalidLevel_Returns400(t *testing.T) {
+	level := new(slog.LevelVar)
+
+	recorder := httptest.NewRecorder()
+	logging.SetLevelHandler(level)(recorder, httptest.NewRequest(http.MethodPost, "/admin/loglevel?level=loud", nil))
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, slog.LevelInfo, level.Level())
+}
@@ -1,34 +1,415 @@
 package main
 
 import (
-	"html/template"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"swucol/about"
+	"swucol/admin"
+	"swucol/apitokens"
+	"swucol/audit"
+	"swucol/backup"
+	"swucol/binder"
+	"swucol/buylist"
 	"swucol/cards"
+	"swucol/cart"
+	"swucol/csrf"
+	"swucol/cubes"
 	"swucol/database"
+	"swucol/datadir"
+	"swucol/events"
+	"swucol/fallback"
+	"swucol/goals"
+	"swucol/images"
+	"swucol/inventory"
+	"swucol/jobs"
+	"swucol/labels"
+	"swucol/loadtest"
+	"swucol/logging"
+	"swucol/metrics"
+	"swucol/models"
+	"swucol/notify"
+	"swucol/packs"
+	"swucol/peersync"
+	"swucol/report"
+	"swucol/requestid"
+	"swucol/roles"
+	"swucol/searches"
+	"swucol/server"
+	"swucol/settings"
+	"swucol/snapshots"
+	"swucol/static"
+	"swucol/templates"
+	"swucol/traits"
+	"swucol/valuation"
+	"swucol/version"
+	"time"
 )
 
+// imagesDir is the local directory card images are stored in and served from,
+// relative to the data directory.
+const imagesDir = datadir.ImagesDir
+
+// defaultImageSources is the remote base URL card images are downloaded from
+// when -image-sources is not set.
+const defaultImageSources = "https://swudb.com/cdn-cgi/image/width=300/images/cards"
+
+// catalogBaseURL is the online card catalog whose set listings
+// POST /cards/import/set/{setcode} imports.
+const catalogBaseURL = "https://api.swu-db.com/cards"
+
+// imageRetrySchedule is the default schedule of the image-retry job, which
+// retries downloads of failed card images.
+const imageRetrySchedule = "@every 1h"
+
+// watchInterval is how often the folder set with -watch-dir is checked for
+// new CSV files.
+const watchInterval = 30 * time.Second
+
+// webhookInterval is how often pending events are posted to the webhook set
+// in SWUCOL_WEBHOOK_URL.
+const webhookInterval = 30 * time.Second
+
+// digestSchedule is the default schedule of the digest job, which checks
+// whether the collection digest is due. How often digests are sent is a
+// setting.
+const digestSchedule = "@hourly"
+
+// buylistMaxAge is how long a fetched vendor buylist is used before POST
+// /buylist/refresh fetches it again.
+const buylistMaxAge = 6 * time.Hour
+
+// valuationSchedule is the default schedule of the valuation job, which
+// checks whether this month's collection valuation is due.
+const valuationSchedule = "@hourly"
+
 // helloHandler responds with "hello world" for GET /hello requests.
 func helloHandler(responseWriter http.ResponseWriter, request *http.Request) {
 	slog.Info("GET /hello received")
 	responseWriter.Write([]byte("hello world\n"))
 }
 
+// runRestore implements the "restore" command, which replaces the local
+// database and fills in missing images from the configured backup bucket.
+// The -snapshot flag selects a snapshot key; the latest is used by default.
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	snapshot := flags.String("snapshot", "", "snapshot object key to restore (default: latest)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, enabled, err := backup.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return errors.New("backups are not configured: set SWUCOL_BACKUP_BUCKET")
+	}
+
+	_, err = backup.New(http.DefaultClient, config).Restore(context.Background(), datadir.DatabaseFile, imagesDir, *snapshot)
+	return err
+}
+
+// runLoadTest implements the "loadtest" command. With -seed it first inserts
+// that many synthetic cards into the data directory's database, so run it
+// against a scratch -data-dir served by the instance at -target. It then
+// requests each of loadtest.DefaultEndpoints -requests times from
+// -concurrency workers and prints their latency percentiles.
+func runLoadTest(args []string) error {
+	flags := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	target := flags.String("target", "http://localhost:8080", "base URL of the server to measure, including any -base-path")
+	seed := flags.Int("seed", 0, "number of synthetic cards to insert into the data directory's database first")
+	requests := flags.Int("requests", 200, "requests per endpoint")
+	concurrency := flags.Int("concurrency", 8, "concurrent requests")
+	token := flags.String("token", os.Getenv("SWUCOL_TOKEN"), "API token to send as a bearer token (env SWUCOL_TOKEN)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *seed < 0 {
+		return errors.New("-seed must not be negative")
+	}
+
+	if *seed > 0 {
+		db, err := database.New(datadir.DatabaseFile)
+		if err != nil {
+			return err
+		}
+		defer db.Shutdown()
+
+		if err := db.RunMigrations(); err != nil {
+			return err
+		}
+		if err := loadtest.Seed(db, *seed); err != nil {
+			return err
+		}
+		slog.Info("seeded synthetic cards", "count", *seed)
+	}
+
+	results, err := loadtest.Run(context.Background(), http.DefaultClient, strings.TrimSuffix(*target, "/"), *token, loadtest.DefaultEndpoints, *requests, *concurrency)
+	if err != nil {
+		return err
+	}
+
+	return loadtest.WriteReport(os.Stdout, results)
+}
+
+// reportCommands are the commands runReport implements.
+var reportCommands = []string{"wishlist", "excess", "search"}
+
+// runReport implements the report commands, which print cards from the data
+// directory's database as a table, or as JSON or CSV with -json or -csv:
+// "wishlist" prints the cards below their minimum owned count with the
+// copies they need, in wishlist order, "excess" those above it with the
+// copies they have to spare, largest surplus first, and "search" the cards
+// matching the query in its arguments, in the search package's syntax, in
+// the default sort order of the settings.
+func runReport(command string, args []string) error {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the cards as JSON")
+	asCSV := flags.Bool("csv", false, "print the cards as CSV")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := report.ParseFlags(*asJSON, *asCSV)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.New(datadir.DatabaseFile)
+	if err != nil {
+		return err
+	}
+	defer db.Shutdown()
+
+	if err := db.RunMigrations(); err != nil {
+		return err
+	}
+
+	switch command {
+	case "wishlist":
+		cardSlice, err := db.GetWishlistCards("")
+		if err != nil {
+			return err
+		}
+		wishlist := cards.WishlistCards(cardSlice, db.Settings())
+		return report.Write(os.Stdout, format, report.WishlistTable(wishlist), wishlist)
+	case "excess":
+		cardSlice, err := db.GetExcessCards()
+		if err != nil {
+			return err
+		}
+		excess := cards.ExcessCards(cardSlice, db.Settings())
+		return report.Write(os.Stdout, format, report.ExcessTable(excess), excess)
+	case "search":
+		cardSlice, err := db.SearchCardsSorted(strings.Join(flags.Args(), " "), database.CardSort(db.Settings().DefaultSort))
+		if err != nil {
+			return err
+		}
+		return report.Write(os.Stdout, format, report.CardTable(cardSlice), cardSlice)
+	}
+
+	return fmt.Errorf("unknown report command %q", command)
+}
+
+// envOrDefault returns the value of the environment variable key, or
+// fallback when it is unset or empty.
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
 func main() {
-	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	})))
+	logLevelName := flag.String("log-level", envOrDefault("SWUCOL_LOG_LEVEL", "info"), "minimum log level: debug, info, warn, or error (env SWUCOL_LOG_LEVEL)")
+	logFormat := flag.String("log-format", envOrDefault("SWUCOL_LOG_FORMAT", logging.FormatText), "log output format: text or json (env SWUCOL_LOG_FORMAT)")
+	addr := flag.String("addr", envOrDefault("SWUCOL_ADDR", ":8080"), "TCP address to listen on (env SWUCOL_ADDR)")
+	unixSocket := flag.String("unix-socket", os.Getenv("SWUCOL_UNIX_SOCKET"), "unix domain socket path to listen on instead of -addr (env SWUCOL_UNIX_SOCKET); a systemd-passed socket takes precedence over both")
+	tlsCert := flag.String("tls-cert", os.Getenv("SWUCOL_TLS_CERT"), "PEM certificate file to serve HTTPS with (env SWUCOL_TLS_CERT)")
+	tlsKey := flag.String("tls-key", os.Getenv("SWUCOL_TLS_KEY"), "PEM key file for -tls-cert (env SWUCOL_TLS_KEY)")
+	autocertHost := flag.String("autocert-host", os.Getenv("SWUCOL_AUTOCERT_HOST"), "hostname to obtain a Let's Encrypt certificate for; serve on :443 (env SWUCOL_AUTOCERT_HOST)")
+	autocertCache := flag.String("autocert-cache", envOrDefault("SWUCOL_AUTOCERT_CACHE", "autocert-cache"), "directory Let's Encrypt certificates are cached in (env SWUCOL_AUTOCERT_CACHE)")
+	trustedProxies := flag.String("trusted-proxies", os.Getenv("SWUCOL_TRUSTED_PROXIES"), "comma-separated proxy IPs or CIDRs whose X-Forwarded-For is trusted (env SWUCOL_TRUSTED_PROXIES)")
+	basePathOption := flag.String("base-path", os.Getenv("SWUCOL_BASE_PATH"), "URL path prefix the app is served under, such as /swucol (env SWUCOL_BASE_PATH)")
+	dataDir := flag.String("data-dir", envOrDefault("SWUCOL_DATA_DIR", "."), "directory holding the database, images and backups; created on first run (env SWUCOL_DATA_DIR)")
+	watchDir := flag.String("watch-dir", os.Getenv("SWUCOL_WATCH_DIR"), "directory checked for CSV files to import automatically, relative to -data-dir; imported files move to its archive subfolder (env SWUCOL_WATCH_DIR)")
+	imageSourceList := flag.String("image-sources", envOrDefault("SWUCOL_IMAGE_SOURCES", defaultImageSources), "comma-separated image URL templates tried in order for each card image; {set}, {number}, {variant} and {foil} placeholders set the URL layout, which defaults to {source}/{set}/{number}.png (env SWUCOL_IMAGE_SOURCES)")
+	marketPrices := flag.String("market-prices", os.Getenv("SWUCOL_MARKET_PRICES"), "URL of a market price list, in the buylist JSON format, the collection is valued against once a month (env SWUCOL_MARKET_PRICES)")
+	jobScheduleList := flag.String("job-schedules", os.Getenv("SWUCOL_JOB_SCHEDULES"), "semicolon-separated name=schedule pairs replacing the default schedules of the backup, image-retry, digest and valuation jobs; a schedule is @every <duration>, @hourly, @daily, @weekly, @monthly or a five-field cron expression (env SWUCOL_JOB_SCHEDULES)")
+	buylistVendorList := flag.String("buylist-vendors", os.Getenv("SWUCOL_BUYLIST_VENDORS"), "comma-separated name=URL pairs of vendor buylists to compare for excess cards, each serving a JSON array of {name, set, number, price} (env SWUCOL_BUYLIST_VENDORS)")
+	defaultRole := flag.String("default-role", envOrDefault("SWUCOL_DEFAULT_ROLE", models.RoleAdmin), "role of requests without an API token or sign-in cookie: viewer, editor or admin (env SWUCOL_DEFAULT_ROLE)")
+	csrfEnabled := flag.Bool("csrf", envOrDefault("SWUCOL_CSRF", "false") == "true", "require a CSRF token on the routes the HTML pages post to; when neither this nor SWUCOL_CSRF is set, it is required once sign-in is in use: while any API token is active, or when -default-role is not admin (env SWUCOL_CSRF)")
+	syncToken := flag.String("sync-token", envOrDefault("SWUCOL_SYNC_TOKEN", ""), "API token sent as a bearer token to the peers of POST /sync/run; on the peer it needs the read and write scopes and the admin role (env SWUCOL_SYNC_TOKEN)")
+	devMode := flag.Bool("dev", envOrDefault("SWUCOL_DEV", "false") == "true", "reload the HTML templates when they change on disk, for template development (env SWUCOL_DEV=true)")
+	flag.Parse()
+
+	csrfSet := os.Getenv("SWUCOL_CSRF") != ""
+	flag.Visit(func(set *flag.Flag) {
+		if set.Name == "csrf" {
+			csrfSet = true
+		}
+	})
+
+	initialLevel, err := logging.ParseLevel(*logLevelName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(initialLevel)
+
+	// Report commands print their results to standard output, so their
+	// logs go to standard error.
+	logOutput := io.Writer(os.Stdout)
+	if args := flag.Args(); len(args) > 0 && slices.Contains(reportCommands, args[0]) {
+		logOutput = os.Stderr
+	}
+
+	logger, err := logging.NewLogger(logOutput, *logFormat, logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+
+	imageSources, err := images.ParseSources(*imageSourceList)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	buylistVendors, err := buylist.ParseVendors(*buylistVendorList)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	slog.Info("starting SWU Collection Manager")
+	if !roles.Valid(*defaultRole) {
+		fmt.Fprintf(os.Stderr, "default role must be one of: %s\n", strings.Join(models.Roles, ", "))
+		os.Exit(2)
+	}
+
+	// Templates and static assets ship with the binary's working directory
+	// rather than the data directory, so their location is resolved before
+	// changing into it.
+	templatesPattern, err := filepath.Abs("templates/*.html")
+	if err != nil {
+		slog.Error("failed to resolve templates directory", "error", err)
+		os.Exit(1)
+	}
+	staticDir, err := filepath.Abs("static")
+	if err != nil {
+		slog.Error("failed to resolve static assets directory", "error", err)
+		os.Exit(1)
+	}
+
+	layout, err := datadir.New(*dataDir)
+	if err != nil {
+		slog.Error("invalid data directory", "error", err)
+		os.Exit(1)
+	}
 
-	db, err := database.New("./swucol.db")
+	firstRun, err := layout.Init()
+	if err != nil {
+		slog.Error("failed to initialize data directory", "error", err)
+		os.Exit(1)
+	}
+
+	// Image paths are stored relative to the data directory, so the process
+	// runs from inside it.
+	if err := os.Chdir(layout.Root); err != nil {
+		slog.Error("failed to enter data directory", "error", err)
+		os.Exit(1)
+	}
+
+	if firstRun {
+		slog.Info("initialized new data directory", "data_dir", layout.Root)
+	}
+
+	if args := flag.Args(); len(args) > 0 && args[0] == "restore" {
+		if err := runRestore(args[1:]); err != nil {
+			slog.Error("restore failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args := flag.Args(); len(args) > 0 && args[0] == "loadtest" {
+		if err := runLoadTest(args[1:]); err != nil {
+			slog.Error("load test failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args := flag.Args(); len(args) > 0 && slices.Contains(reportCommands, args[0]) {
+		if err := runReport(args[0], args[1:]); err != nil {
+			slog.Error("report failed", "command", args[0], "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	serverConfig := server.Config{
+		Addr:             *addr,
+		UnixSocket:       *unixSocket,
+		TLSCert:          *tlsCert,
+		TLSKey:           *tlsKey,
+		AutocertHost:     *autocertHost,
+		AutocertCacheDir: *autocertCache,
+	}
+	if err := serverConfig.Validate(); err != nil {
+		slog.Error("invalid server configuration", "error", err)
+		os.Exit(1)
+	}
+
+	proxies, err := server.ParseTrustedProxies(*trustedProxies)
+	if err != nil {
+		slog.Error("invalid server configuration", "error", err)
+		os.Exit(1)
+	}
+
+	basePath, err := server.NormalizeBasePath(*basePathOption)
+	if err != nil {
+		slog.Error("invalid server configuration", "error", err)
+		os.Exit(1)
+	}
+
+	buildInfo := version.Get()
+	slog.Info("starting SWU Collection Manager",
+		"version", buildInfo.Version,
+		"commit", buildInfo.Commit,
+		"build_date", buildInfo.BuildDate,
+		"go_version", buildInfo.GoVersion,
+	)
+
+	db, err := database.New(datadir.DatabaseFile)
 	if err != nil {
 		slog.Error("failed to open database", "error", err)
 		os.Exit(1)
 	}
 	defer db.Shutdown()
 
+	if rawThreshold := os.Getenv("SWUCOL_SLOW_QUERY_THRESHOLD"); rawThreshold != "" {
+		threshold, err := time.ParseDuration(rawThreshold)
+		if err != nil || threshold < 0 {
+			slog.Error("invalid SWUCOL_SLOW_QUERY_THRESHOLD: must be a non-negative duration such as 250ms", "value", rawThreshold)
+			os.Exit(1)
+		}
+		db.SetSlowQueryThreshold(threshold)
+	}
+
 	if err := db.RunMigrations(); err != nil {
 		slog.Error("failed to run database migrations", "error", err)
 		os.Exit(1)
@@ -36,36 +417,336 @@ func main() {
 
 	slog.Info("database initialized")
 
-	tmpl, err := template.ParseGlob("templates/*.html")
+	// Record collection changes published by the handlers in the audit log.
+	audit.Subscribe(db)
+
+	// Card images are linked and served under their content hashes, so that
+	// browsers can cache them for good.
+	imageHashes := images.NewHashes(imagesDir)
+
+	tmpl, err := templates.ParseTemplates(templatesPattern, basePath, *devMode, imageHashes.HashedPath)
 	if err != nil {
 		slog.Error("failed to load templates", "error", err)
 		os.Exit(1)
 	}
 
-	slog.Info("templates loaded")
+	slog.Info("templates loaded", "reload", *devMode)
 
-	// Serve card images from the local images directory.
-	http.Handle("/images/", http.StripPrefix("/images/", http.FileServer(http.Dir("images"))))
+	backupConfig, backupEnabled, err := backup.ConfigFromEnv()
+	if err != nil {
+		slog.Error("invalid backup configuration", "error", err)
+		os.Exit(1)
+	}
+
+	jobSchedules, err := jobs.ParseSchedules(*jobScheduleList)
+	if err != nil {
+		slog.Error("invalid job schedules", "error", err)
+		os.Exit(1)
+	}
+
+	scheduler := jobs.New(db, jobSchedules)
+	registerJob := func(name, schedule string, run jobs.RunFunc) {
+		if err := scheduler.Register(name, schedule, run); err != nil {
+			slog.Error("invalid job schedule", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if backupEnabled {
+		backupConfig.StagingDir = layout.Backups
+		slog.Info("scheduled backups enabled", "bucket", backupConfig.Bucket, "interval", backupConfig.Interval)
+		backuper := backup.New(http.DefaultClient, backupConfig)
+		registerJob("backup", "@every "+backupConfig.Interval.String(), func(ctx context.Context) error {
+			_, err := backuper.Backup(ctx, db, imagesDir)
+			return err
+		})
+	}
+
+	registerJob("image-retry", imageRetrySchedule, func(ctx context.Context) error {
+		_, err := images.RetryFailed(db, http.DefaultClient, imagesDir, imageSources, images.DefaultRetryPolicy)
+		return err
+	})
+
+	if webhookURL := os.Getenv("SWUCOL_WEBHOOK_URL"); webhookURL != "" {
+		slog.Info("webhook notifications enabled", "interval", webhookInterval)
+		notifier := notify.New(db, http.DefaultClient, webhookURL)
+		notifier.Subscribe()
+		go notifier.Schedule(context.Background(), webhookInterval)
+	}
+
+	smtpConfig, smtpEnabled, err := notify.SMTPConfigFromEnv()
+	if err != nil {
+		slog.Error("invalid SMTP configuration", "error", err)
+		os.Exit(1)
+	}
+
+	if smtpEnabled {
+		slog.Info("email digests enabled", "addr", smtpConfig.Addr)
+	}
+
+	digester := notify.NewDigester(db, http.DefaultClient, smtpConfig)
+	registerJob("digest", digestSchedule, func(ctx context.Context) error {
+		_, err := digester.SendIfDue(ctx, time.Now())
+		return err
+	})
+
+	var valuer *valuation.Valuer
+	if *marketPrices != "" {
+		valuer, err = valuation.NewValuer(db, http.DefaultClient, *marketPrices)
+		if err != nil {
+			slog.Error("invalid market price source", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("monthly collection valuations enabled", "source", *marketPrices)
+		registerJob("valuation", valuationSchedule, func(ctx context.Context) error {
+			_, err := valuer.RecordIfDue(ctx, time.Now())
+			return err
+		})
+	}
+
+	go scheduler.Run(context.Background())
+
+	// Serve the web app manifest, icons and service worker.
+	http.HandleFunc("GET /static/{name}", static.Handler(staticDir))
+	http.HandleFunc("GET /sw.js", static.ServiceWorkerHandler(staticDir))
+
+	// Serve card images from the local images directory under their hashed
+	// names, redirecting the plain file names to them.
+	http.HandleFunc("GET /images/{path...}", imageHashes.Handler(basePath))
+	http.HandleFunc("GET /images/placeholder/{id}", images.PlaceholderHandler(db))
+
+	importLock := cards.NewImportLock()
+
+	if *watchDir != "" {
+		if err := os.MkdirAll(*watchDir, 0o755); err != nil {
+			slog.Error("failed to create watch folder", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("watch folder imports enabled", "dir", *watchDir, "interval", watchInterval)
+		go cards.ScheduleWatchFolder(context.Background(), watchInterval, db, importLock, http.DefaultClient, imagesDir, imageSources, *watchDir)
+	}
+
+	// csrfRequired reports whether protected routes check the CSRF token.
+	// Without -csrf or SWUCOL_CSRF it follows sign-in, checked per request
+	// so that the first token created on the admin page turns it on: browsers
+	// then carry the sign-in cookie, or anonymous requests are limited by
+	// -default-role. A database error fails closed.
+	csrfAuto := !csrfSet
+	csrfRequired := func(request *http.Request) bool {
+		if !csrfAuto || *defaultRole != models.RoleAdmin {
+			return *csrfEnabled || csrfAuto
+		}
+		active, err := db.HasActiveAPITokens()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error checking for API tokens, requiring a CSRF token", "error", err)
+			return true
+		}
+		return active
+	}
+
+	// protect guards the routes the HTML pages post to, including the admin
+	// actions, when csrfRequired. Other JSON API routes stay usable from
+	// scripts without a token, and requests authenticated with an API token
+	// skip the check, since a browser never sends one on its own.
+	protect := func(handler http.HandlerFunc) http.HandlerFunc { return handler }
+	if *csrfEnabled || csrfAuto {
+		protect = func(handler http.HandlerFunc) http.HandlerFunc {
+			protected := csrf.Protect(handler)
+			return func(responseWriter http.ResponseWriter, request *http.Request) {
+				if _, ok := apitokens.FromContext(request.Context()); ok || !csrfRequired(request) {
+					handler(responseWriter, request)
+					return
+				}
+				protected(responseWriter, request)
+			}
+		}
+		if csrfAuto {
+			slog.Info("CSRF protection follows sign-in", "default_role", *defaultRole)
+		} else {
+			slog.Info("CSRF protection enabled")
+		}
+	}
+
+	// fallbacks answers the HTML routes' form posts with full pages when they
+	// come from a browser without JavaScript, or with html_only set.
+	fallbacks := fallback.New(basePath, tmpl)
 
 	// JSON API routes.
-	http.HandleFunc("/hello", helloHandler)
-	http.HandleFunc("POST /cards/import", cards.ImportCardsHandler(db, http.DefaultClient, "images", "https://swudb.com/cdn-cgi/image/width=300/images/cards"))
+	http.HandleFunc("GET /hello", helloHandler)
+	http.HandleFunc("GET /metrics", metrics.Handler(db))
+	http.HandleFunc("GET /admin/loglevel", logging.GetLevelHandler(logLevel))
+	http.HandleFunc("POST /admin/loglevel", protect(logging.SetLevelHandler(logLevel)))
+	http.HandleFunc("POST /cards/import", cards.ImportCardsHandler(db, importLock, http.DefaultClient, imagesDir, imageSources))
+	http.HandleFunc("POST /cards/import/set/{setcode}", cards.ImportSetHandler(db, importLock, http.DefaultClient, imagesDir, imageSources, catalogBaseURL))
+	http.HandleFunc("GET /sets/unreleased", cards.UnreleasedSetsHandler(db))
+	http.HandleFunc("POST /sets/{setcode}/release", cards.ReleaseSetHandler(db))
+	http.HandleFunc("GET /imports", cards.ImportHistoryHandler(db))
+	http.HandleFunc("POST /imports/{id}/rerun", cards.RerunImportHandler(db, importLock, http.DefaultClient, imagesDir, imageSources))
+	http.HandleFunc("GET /cards/import/status", cards.ImportStatusHandler(importLock))
 	http.HandleFunc("GET /cards/search", cards.SearchCardsHandler(db))
+	http.HandleFunc("GET /cards/recent", cards.RecentCardsHandler(db))
+	http.HandleFunc("GET /cards/random", cards.RandomCardHandler(db))
+	http.HandleFunc("GET /cards/excess", cards.ExcessCardsHandler(db))
+
+	buylistRefresher := buylist.NewRefresher(db, http.DefaultClient, buylistVendors, buylistMaxAge)
+	http.HandleFunc("GET /buylist", buylist.CompareHandler(db, buylistRefresher))
+	http.HandleFunc("POST /buylist/refresh", protect(buylist.RefreshHandler(buylistRefresher)))
+	http.HandleFunc("GET /buylist/html", buylist.PageHandler(db, buylistRefresher, tmpl))
 	http.HandleFunc("GET /cards/{id}", cards.GetCardHandler(db))
 	http.HandleFunc("POST /cards/{id}/increment", cards.IncrementCardOwnedHandler(db))
 	http.HandleFunc("POST /cards/{id}/decrement", cards.DecrementCardOwnedHandler(db))
+	http.HandleFunc("POST /cards/{id}/archive", cards.ArchiveCardHandler(db))
+	http.HandleFunc("POST /cards/{id}/unarchive", cards.UnarchiveCardHandler(db))
+	http.HandleFunc("GET /cards/{id}/aliases", cards.GetCardAliasesHandler(db))
+	http.HandleFunc("POST /cards/{id}/aliases", cards.AddCardAliasHandler(db))
+	http.HandleFunc("DELETE /cards/{id}/aliases/{alias}", cards.DeleteCardAliasHandler(db))
+	http.HandleFunc("POST /cards/translations", cards.ImportTranslationsHandler(db))
+	http.HandleFunc("POST /cards/{id}/priority", cards.SetCardPriorityHandler(db))
+	http.HandleFunc("POST /cards/diff", cards.DiffCardsHandler(db))
+	http.HandleFunc("POST /cards/bulk", protect(cards.BulkUpdateCardsHandler(db)))
+	http.HandleFunc("POST /cards/owned/sync", protect(cards.ReplayOwnedHandler(db)))
+	http.HandleFunc("GET /inventory/items", inventory.ListHandler(db))
+	http.HandleFunc("POST /inventory/items", inventory.CreateHandler(db))
+	http.HandleFunc("PUT /inventory/items/{id}", inventory.UpdateHandler(db))
+	http.HandleFunc("DELETE /inventory/items/{id}", inventory.DeleteHandler(db))
+	http.HandleFunc("GET /events/stats", events.StatsHandler(db))
+	http.HandleFunc("GET /goals", goals.ListHandler(db))
+	http.HandleFunc("POST /goals", goals.CreateHandler(db))
+	http.HandleFunc("DELETE /goals/{id}", goals.DeleteHandler(db))
+	http.HandleFunc("GET /cart", cart.ListHandler(db))
+	http.HandleFunc("DELETE /cart", cart.ClearHandler(db))
+	http.HandleFunc("POST /cart/items", cart.AddHandler(db))
+	http.HandleFunc("DELETE /cart/items/{id}", cart.RemoveHandler(db))
+	http.HandleFunc("GET /cart/export/{vendor}", cart.ExportHandler(db))
+	http.HandleFunc("GET /searches", searches.ListHandler(db))
+	http.HandleFunc("POST /searches", searches.CreateHandler(db))
+	http.HandleFunc("PUT /searches/{id}", searches.UpdateHandler(db))
+	http.HandleFunc("DELETE /searches/{id}", searches.DeleteHandler(db))
+	http.HandleFunc("GET /cubes", cubes.ListHandler(db))
+	http.HandleFunc("POST /cubes", cubes.CreateHandler(db))
+	http.HandleFunc("GET /cubes/uncubed", cubes.UncubedHandler(db))
+	http.HandleFunc("GET /cubes/{id}", cubes.GetHandler(db))
+	http.HandleFunc("DELETE /cubes/{id}", protect(cubes.DeleteHandler(db)))
+	http.HandleFunc("PUT /cubes/{id}/cards/{cardID}", cubes.SetCardCountHandler(db))
+	http.HandleFunc("GET /cubes/{id}/export", cubes.ExportHandler(db))
+	http.HandleFunc("GET /cubes/{id}/snippet", cubes.SnippetHandler(db))
+	http.HandleFunc("GET /packs/simulate", packs.SimulatePackHandler(db))
+	http.HandleFunc("POST /snapshots", protect(snapshots.CreateSnapshotHandler(db)))
+	http.HandleFunc("GET /snapshots", snapshots.ListSnapshotsHandler(db))
+	http.HandleFunc("GET /snapshots/{a}/compare/{b}", snapshots.CompareSnapshotsHandler(db))
+	http.HandleFunc("GET /valuations", valuation.ListHandler(db))
+	http.HandleFunc("GET /audit", audit.ListHandler(db))
+	http.HandleFunc("GET /audit/activity", audit.ActivityHandler(db))
+	http.HandleFunc("POST /valuations", protect(valuation.CreateHandler(valuer)))
+	http.HandleFunc("GET /settings", settings.GetSettingsHandler(db))
+	http.HandleFunc("PUT /settings", settings.PutSettingsHandler(db))
+	http.HandleFunc("GET /sync/pull", peersync.PullHandler(db))
+	http.HandleFunc("POST /sync/push", peersync.PushHandler(db))
+	http.HandleFunc("POST /sync/run", peersync.RunHandler(db, http.DefaultClient, *syncToken))
+	http.HandleFunc("POST /admin/db/vacuum", protect(admin.VacuumHandler(db)))
+	http.HandleFunc("POST /admin/db/integrity-check", protect(admin.IntegrityCheckHandler(db)))
+	http.HandleFunc("POST /admin/db/reindex", protect(admin.ReindexHandler(db)))
+	http.HandleFunc("GET /admin/db/backup", admin.BackupHandler(db))
+	http.HandleFunc("POST /admin/db/restore", protect(admin.RestoreHandler(db)))
+	http.HandleFunc("GET /admin/db/duplicates", admin.DuplicatesHandler(db))
+	http.HandleFunc("POST /admin/db/duplicates/merge", protect(admin.MergeDuplicatesHandler(db)))
+	http.HandleFunc("POST /admin/images/gc", protect(images.GarbageCollectHandler(db, imagesDir)))
+	http.HandleFunc("POST /admin/images/optimize", protect(images.OptimizeHandler(db)))
+
+	prefetcher := images.NewPrefetcher(db, http.DefaultClient, imagesDir, imageSources)
+	http.HandleFunc("GET /admin/images/prefetch", images.PrefetchStatusHandler(prefetcher))
+	http.HandleFunc("POST /admin/images/prefetch", protect(images.StartPrefetchHandler(prefetcher)))
+	http.HandleFunc("POST /admin/images/prefetch/pause", protect(images.PausePrefetchHandler(prefetcher)))
+	http.HandleFunc("POST /admin/images/prefetch/resume", protect(images.ResumePrefetchHandler(prefetcher)))
 
 	// HTML / htmx routes.
 	http.HandleFunc("GET /{$}", cards.IndexHandler(db, tmpl))
 	http.HandleFunc("GET /cards/search/html", cards.SearchCardsHTMLHandler(db, tmpl))
-	http.HandleFunc("POST /cards/import/html", cards.ImportCardsHTMLHandler(db, http.DefaultClient, "images", "https://swudb.com/cdn-cgi/image/width=300/images/cards"))
-	http.HandleFunc("POST /cards/{id}/increment/html", cards.IncrementCardOwnedHTMLHandler(db, tmpl))
-	http.HandleFunc("POST /cards/{id}/decrement/html", cards.DecrementCardOwnedHTMLHandler(db, tmpl))
+	http.HandleFunc("GET /cards/recent/html", cards.RecentCardsHTMLHandler(db, tmpl))
+	http.HandleFunc("GET /cards/{id}/zoom", cards.CardZoomHTMLHandler(db, tmpl))
+	http.HandleFunc("POST /cards/import/html", protect(fallbacks.Page(cards.ImportCardsHTMLHandler(db, importLock, http.DefaultClient, imagesDir, imageSources, tmpl))))
+	http.HandleFunc("POST /cards/{id}/increment/html", protect(fallbacks.Redirect(cards.IncrementCardOwnedHTMLHandler(db, tmpl))))
+	http.HandleFunc("POST /cards/{id}/decrement/html", protect(fallbacks.Redirect(cards.DecrementCardOwnedHTMLHandler(db, tmpl))))
 	http.HandleFunc("GET /wishlist", cards.WishlistHandler(db, tmpl))
 	http.HandleFunc("GET /wishlist/search/html", cards.SearchWishlistHTMLHandler(db, tmpl))
+	http.HandleFunc("GET /wishlist/snippet", cards.WishlistSnippetHandler(db))
+	http.HandleFunc("GET /wishlist/snippet/html", cards.WishlistSnippetHTMLHandler(db, tmpl))
+	http.HandleFunc("POST /cards/{id}/priority/html", protect(fallbacks.Redirect(cards.SetCardPriorityHTMLHandler(db, tmpl))))
+	http.HandleFunc("GET /cart/html", cart.PageHandler(db, tmpl))
+	http.HandleFunc("POST /cart/items/html", protect(fallbacks.Redirect(cart.AddHTMLHandler(db, tmpl))))
+	http.HandleFunc("POST /cart/items/{id}/remove/html", protect(fallbacks.Redirect(cart.RemoveHTMLHandler(db, tmpl))))
+	http.HandleFunc("POST /cart/clear/html", protect(fallbacks.Redirect(cart.ClearHTMLHandler(db, tmpl))))
+	http.HandleFunc("POST /cards/diff/html", protect(fallbacks.Page(cards.DiffCardsHTMLHandler(db, tmpl))))
+	http.HandleFunc("POST /cards/{id}/archive/html", protect(fallbacks.Redirect(cards.ArchiveCardHTMLHandler(db))))
+	http.HandleFunc("POST /cards/{id}/unarchive/html", protect(fallbacks.Redirect(cards.UnarchiveCardHTMLHandler(db))))
+	http.HandleFunc("GET /quick", cards.QuickHandler(db, tmpl))
+	http.HandleFunc("GET /quick/card/html", cards.QuickCardHTMLHandler(db, tmpl))
+	http.HandleFunc("GET /labels", labels.PageHandler(db, tmpl, basePath))
+	http.HandleFunc("GET /binder", binder.PageHandler(db, tmpl))
+	http.HandleFunc("GET /traits", traits.PageHandler(db, tmpl))
+	http.HandleFunc("GET /inventory", inventory.PageHandler(db, tmpl))
+	http.HandleFunc("POST /inventory/html", protect(fallbacks.Redirect(inventory.CreateHTMLHandler(db, tmpl))))
+	http.HandleFunc("POST /inventory/items/{id}/increment/html", protect(fallbacks.Redirect(inventory.AdjustQuantityHTMLHandler(db, tmpl, 1))))
+	http.HandleFunc("POST /inventory/items/{id}/decrement/html", protect(fallbacks.Redirect(inventory.AdjustQuantityHTMLHandler(db, tmpl, -1))))
+	http.HandleFunc("DELETE /inventory/items/{id}/html", protect(inventory.DeleteHTMLHandler(db, tmpl)))
+	http.HandleFunc("GET /goals/html", goals.WidgetHTMLHandler(db, tmpl))
+	http.HandleFunc("POST /goals/html", protect(fallbacks.Redirect(goals.CreateHTMLHandler(db, tmpl))))
+	http.HandleFunc("DELETE /goals/{id}/html", protect(goals.DeleteHTMLHandler(db, tmpl)))
+	http.HandleFunc("POST /searches/html", protect(fallbacks.Redirect(searches.CreateHTMLHandler(db, tmpl))))
+	http.HandleFunc("POST /searches/{id}/pin/html", protect(fallbacks.Redirect(searches.PinHTMLHandler(db, tmpl))))
+	http.HandleFunc("DELETE /searches/{id}/html", protect(searches.DeleteHTMLHandler(db, tmpl)))
+	http.HandleFunc("GET /events", events.PageHandler(db, tmpl))
+	http.HandleFunc("POST /events/html", protect(fallbacks.Redirect(events.CreateHTMLHandler(db, tmpl))))
+	http.HandleFunc("DELETE /events/{id}/html", protect(events.DeleteHTMLHandler(db, tmpl)))
+	http.HandleFunc("GET /cubes/html", cubes.IndexHandler(db, tmpl))
+	http.HandleFunc("POST /cubes/html", protect(fallbacks.Redirect(cubes.CreateHTMLHandler(db, tmpl))))
+	http.HandleFunc("GET /cubes/{id}/html", cubes.CubeHandler(db, tmpl))
+	http.HandleFunc("GET /cubes/{id}/snippet/html", cubes.SnippetHTMLHandler(db, tmpl))
+	http.HandleFunc("POST /cubes/{id}/cards/{cardID}/html", protect(fallbacks.Redirect(cubes.SetCardCountHTMLHandler(db, tmpl))))
+	http.HandleFunc("GET /archive", cards.ArchiveHandler(db, tmpl))
+	http.HandleFunc("GET /archive/search/html", cards.SearchArchiveHTMLHandler(db, tmpl))
+	http.HandleFunc("GET /history", snapshots.HistoryHandler(db, tmpl, valuer != nil))
+	http.HandleFunc("GET /audit/html", audit.PageHandler(db, tmpl))
+	http.HandleFunc("GET /settings/html", settings.PageHandler(db, tmpl))
+	http.HandleFunc("POST /settings/html", protect(settings.SaveFormHandler(db, tmpl)))
+	http.HandleFunc("GET /admin", admin.PageHandler(db, tmpl))
+	http.HandleFunc("GET /admin/tokens", apitokens.ListHandler(db))
+	http.HandleFunc("POST /admin/tokens", protect(apitokens.CreateHandler(db)))
+	http.HandleFunc("DELETE /admin/tokens/{id}", protect(apitokens.RevokeHandler(db)))
+	http.HandleFunc("GET /login", roles.LoginPageHandler(db, tmpl))
+	http.HandleFunc("POST /login", protect(roles.LoginHandler(db, tmpl, basePath)))
+	http.HandleFunc("POST /logout", protect(roles.LogoutHandler(basePath)))
+	http.HandleFunc("GET /about", about.Handler(layout))
+	http.HandleFunc("GET /version", version.Handler())
+	http.HandleFunc("GET /jobs", jobs.ListHandler(scheduler))
+	http.HandleFunc("GET /jobs/{name}/runs", jobs.RunsHandler(scheduler))
+	http.HandleFunc("POST /jobs/{name}/run", jobs.TriggerHandler(scheduler))
+
+	// Every other GET is an unknown route: browsers get the 404 error page.
+	// Other methods on unknown paths get 405 Method Not Allowed.
+	http.HandleFunc("GET /", templates.NotFoundHandler(tmpl))
+
+	var routes http.Handler = http.DefaultServeMux
+	if *csrfEnabled || csrfAuto {
+		routes = csrf.Middleware(routes)
+	}
+	routes = roles.Middleware(db, *defaultRole, routes)
+	routes = apitokens.Middleware(db, routes)
+	handler := server.TrustedProxies(proxies, requestid.Middleware(server.BasePath(basePath, routes)))
+
+	listener, err := server.Listen(serverConfig)
+	if err != nil {
+		slog.Error("failed to listen", "error", err)
+		os.Exit(1)
+	}
 
-	slog.Info("server listening", "addr", ":8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	slog.Info("server listening",
+		"network", listener.Addr().Network(),
+		"addr", listener.Addr().String(),
+		"tls", serverConfig.TLSCert != "" || serverConfig.AutocertHost != "",
+		"base_path", basePath,
+	)
+	if err := server.Serve(serverConfig, listener, handler); err != nil {
 		slog.Error("server error", "error", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ImportFailures counts imports that failed, by cause: "invalid" for a file
+// or request that was rejected, "not_found" for an unknown set, "confirm"
+// for a sync that needs confirming, "upstream" for a catalog that could not
+// be fetched, and "internal" for database and file errors.
+var ImportFailures = NewCounter("swucol_import_failures_total", "Failed imports by cause.", "cause")
+
+// ImageDownloadFailures counts card image downloads that failed from a source
+// after every retry, by cause: "status_<code>" for an unexpected HTTP status
+// (a run of status_404 usually means the source's URL layout changed),
+// "network" for a request that got no response, "file" for an image that
+// could not be written, and "other" for anything else.
+var ImageDownloadFailures = NewCounter("swucol_image_download_failures_total", "Failed card image downloads by cause.", "cause")
+
+// counters are the counters Handler exports, in order.
+var counters = []*Counter{ImportFailures, ImageDownloadFailures}
+
+// Counter is a Prometheus counter partitioned by the values of one label. It
+// is safe for concurrent use.
+type Counter struct {
+	name, help, label string
+
+	mutex  sync.Mutex
+	counts map[string]uint64
+}
+
+// NewCounter returns a counter with the given metric name, help text and
+// label name.
+func NewCounter(name, help, label string) *Counter {
+	return &Counter{name: name, help: help, label: label, counts: make(map[string]uint64)}
+}
+
+// Inc adds one to the count for the label value.
+func (counter *Counter) Inc(value string) {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+
+	counter.counts[value]++
+}
+
+// Value returns the count for the label value.
+func (counter *Counter) Value(value string) uint64 {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+
+	return counter.counts[value]
+}
+
+// Write writes the counter in the Prometheus text exposition format, one
+// sample per label value in order.
+func (counter *Counter) Write(writer io.Writer) error {
+	counter.mutex.Lock()
+	values := make([]string, 0, len(counter.counts))
+	for value := range counter.counts {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# HELP %s %s\n", counter.name, counter.help)
+	fmt.Fprintf(&builder, "# TYPE %s counter\n", counter.name)
+	for _, value := range values {
+		fmt.Fprintf(&builder, "%s{%s=%q} %d\n", counter.name, counter.label, value, counter.counts[value])
+	}
+	counter.mutex.Unlock()
+
+	if _, err := io.WriteString(writer, builder.String()); err != nil {
+		return fmt.Errorf("write %s: %w", counter.name, err)
+	}
+
+	return nil
+}
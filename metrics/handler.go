@@ -0,0 +1,32 @@
+// Package metrics serves application metrics in the Prometheus text
+// exposition format: the database's query histograms and error counts, and
+// the failure counters other packages increment.
+package metrics
+
+import (
+	"log/slog"
+	"net/http"
+
+	"swucol/database"
+)
+
+// Handler returns an http.HandlerFunc that handles GET /metrics. Responds
+// with 200 OK and the database query duration histograms and error counts,
+// followed by the import and image download failure counters, in the
+// Prometheus text format.
+func Handler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := db.WriteQueryMetrics(responseWriter); err != nil {
+			slog.ErrorContext(request.Context(), "failed to write metrics", "error", err)
+			return
+		}
+
+		for _, counter := range counters {
+			if err := counter.Write(responseWriter); err != nil {
+				slog.ErrorContext(request.Context(), "failed to write metrics", "error", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/database"
+	"swucol/internal/testdb"
+	"swucol/metrics"
+)
+
+func TestHandler_ExportsQueryHistogramPerMethod(t *testing.T) {
+	db := testdb.New(t)
+
+	_, err := db.SearchCards("luke")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler(db)(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, "# TYPE swucol_db_query_duration_seconds histogram")
+	assert.Contains(t, body, `swucol_db_query_duration_seconds_bucket{method="SearchCards",le="+Inf"} 1`)
+	assert.Contains(t, body, `swucol_db_query_duration_seconds_count{method="RunMigrations"}`)
+}
+
+func TestHandler_ExportsDatabaseErrorsAndFailureCounters(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.RunMigrations())
+	require.NoError(t, db.Shutdown())
+
+	_, err = db.SearchCards("luke")
+	require.Error(t, err)
+	metrics.ImportFailures.Inc("invalid")
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler(db)(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := recorder.Body.String()
+	assert.Contains(t, body, "# TYPE swucol_db_errors_total counter")
+	assert.Contains(t, body, `swucol_db_errors_total{method="SearchCards",cause="other"} 1`)
+	assert.Contains(t, body, "# TYPE swucol_import_failures_total counter")
+	assert.Regexp(t, `swucol_import_failures_total\{cause="invalid"\} [1-9]`, body)
+	assert.Contains(t, body, "# TYPE swucol_image_download_failures_total counter")
+}
@@ -1,22 +1,476 @@
 // Package models defines the shared data structures used across the application.
 package models
 
-// Card represents a card record stored in the database.
+import "time"
+
+// Card represents a card record stored in the database. Archived cards are
+// hidden from search and the wishlist. Set, Number, Type, Aspects and Rarity
+// are copied from the CSV at import and are empty for cards imported before
+// they were tracked. CreatedAt and UpdatedAt are zero for cards inserted
+// before timestamps were tracked. ImageFailed is set when the card's image
+// could not be downloaded and is waiting to be retried. Thumbnail is a small
+// version of Image for the grid and is empty until one has been generated.
+// ImageSource is the image source Image was downloaded from, and is empty when
+// that is not known. BackImage is the image of the back face of a
+// double-sided card such as a leader, and is empty for other cards or when it
+// could not be downloaded. Priority orders the card on the wishlist; see the
+// database package's Priority constants. Cost, Power, HP, Traits and Text are
+// the gameplay attributes copied from the online catalog; see CardAttributes.
+// Unreleased is set for the cards of a set that is still a preview; see
+// UnreleasedSet. PlaysetComplete is set when Owned has reached the minimum
+// owned threshold for the card's type in Settings.
 type Card struct {
-	ID        int    `json:"id"`
-	Name      string `json:"name"`
-	Image     string `json:"image"`
-	Owned     int    `json:"owned"`
-	Mainboard bool   `json:"mainboard"`
+	ID              int       `json:"id"`
+	Name            string    `json:"name"`
+	Image           string    `json:"image"`
+	Thumbnail       string    `json:"thumbnail"`
+	ImageSource     string    `json:"image_source"`
+	BackImage       string    `json:"back_image"`
+	ImageFailed     bool      `json:"image_failed"`
+	Owned           int       `json:"owned"`
+	Mainboard       bool      `json:"mainboard"`
+	Archived        bool      `json:"archived"`
+	Priority        int       `json:"priority"`
+	Set             string    `json:"set"`
+	Number          string    `json:"number"`
+	Type            string    `json:"type"`
+	Aspects         string    `json:"aspects"`
+	Rarity          string    `json:"rarity"`
+	Cost            *int      `json:"cost"`
+	Power           *int      `json:"power"`
+	HP              *int      `json:"hp"`
+	Traits          string    `json:"traits"`
+	Text            string    `json:"text"`
+	Unreleased      bool      `json:"unreleased"`
+	PlaysetComplete bool      `json:"playset_complete"`
+	CreatedAt       time.Time `json:"created_at,omitzero"`
+	UpdatedAt       time.Time `json:"updated_at,omitzero"`
+}
+
+// CardAttributes are the gameplay attributes of a card as listed by the
+// online catalog. Cost, Power and HP are nil when the card has none, such as
+// the power of an event, or they are not known. Traits are joined with "|"
+// like Aspects, and Text is the card's rules text.
+type CardAttributes struct {
+	Cost   *int
+	Power  *int
+	HP     *int
+	Traits string
+	Text   string
+}
+
+// UnreleasedSet is a set imported ahead of its release as a spoiler-season
+// preview. ReleaseDate, in YYYY-MM-DD form, is the day its cards start to
+// count as released, and is empty when that is not known.
+type UnreleasedSet struct {
+	Set         string `json:"set"`
+	ReleaseDate string `json:"release_date"`
+}
+
+// NewCard holds the fields supplied when inserting a card. Image may be empty
+// when no image is available; ImageFailed marks that its download failed.
+// Thumbnail may be empty when none was generated, ImageSource when the image
+// was not downloaded, and BackImage for cards with a single face. Owned is the
+// count the card starts with.
+type NewCard struct {
+	Name        string
+	Image       string
+	Thumbnail   string
+	ImageSource string
+	BackImage   string
+	ImageFailed bool
+	Mainboard   bool
+	Owned       int
+	Set         string
+	Number      string
+	Type        string
+	Aspects     string
+	Rarity      string
 }
 
 // WishlistCard extends Card with a pre-computed Deficit field that indicates
 // how many more copies are needed to meet the minimum owned threshold.
 type WishlistCard struct {
 	Card
-	Deficit int
+	Deficit int `json:"deficit"`
+}
+
+// ExcessCard extends Card with the Surplus of copies owned beyond the minimum
+// owned threshold, which are free to trade.
+type ExcessCard struct {
+	Card
+	Surplus int `json:"surplus"`
+}
+
+// WishlistCompletion records a card reaching its minimum owned threshold and
+// so leaving the wishlist.
+type WishlistCompletion struct {
+	ID          int       `json:"id"`
+	CardID      int       `json:"card_id"`
+	Name        string    `json:"name"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// CollectionSnapshot records the collection's total owned count at a point in
+// time. Per-card counts are stored alongside it in the database.
+type CollectionSnapshot struct {
+	ID         int       `json:"id"`
+	TakenAt    time.Time `json:"taken_at"`
+	TotalOwned int       `json:"total_owned"`
+}
+
+// Valuation records the market value of the collection in a month: owned
+// copies times market price, summed over the cards with a price. Month is
+// formatted as 2006-01.
+type Valuation struct {
+	ID            int       `json:"id"`
+	Month         string    `json:"month"`
+	ValuedAt      time.Time `json:"valued_at"`
+	TotalCents    int       `json:"total_cents"`
+	PricedCards   int       `json:"priced_cards"`
+	UnpricedCards int       `json:"unpriced_cards"`
+}
+
+// APIToken is a bearer token scripts use to call the JSON API. The secret
+// itself is only returned when the token is created; the database keeps a
+// hash of it. Role is one of the Role constants and bounds what the token may
+// do alongside its scopes; a token also signs a browser in with its role.
+// LastUsedAt and RevokedAt are zero until the token is used or revoked.
+type APIToken struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Scopes     []string  `json:"scopes"`
+	Role       string    `json:"role"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at,omitzero"`
+	RevokedAt  time.Time `json:"revoked_at,omitzero"`
+}
+
+// API token scopes. Read allows GET requests, Import allows CSV imports, and
+// Write allows every other change.
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeImport = "import"
+)
+
+// Roles. A viewer can browse, an editor can also change owned counts and the
+// rest of the collection, and an admin can also import, delete and run
+// maintenance.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Roles lists the roles from least to most privileged.
+var Roles = []string{RoleViewer, RoleEditor, RoleAdmin}
+
+// AuditEvent records one change to the collection: who made it (the name of
+// the API token the request used, or "" without one), what it was (one of the
+// Audit constants), and the card it touched. CardID is 0 for changes that are
+// not about a single card, such as bulk updates and imports, and for sync
+// changes, which are matched by name. Detail describes the change, such as
+// the owned count afterwards.
+type AuditEvent struct {
+	ID         int       `json:"id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	User       string    `json:"user"`
+	Action     string    `json:"action"`
+	CardID     int       `json:"card_id,omitempty"`
+	CardName   string    `json:"card_name"`
+	Detail     string    `json:"detail"`
+}
+
+// Audit event actions.
+const (
+	AuditIncrement = "increment"
+	AuditDecrement = "decrement"
+	AuditArchive   = "archive"
+	AuditUnarchive = "unarchive"
+	AuditBulk      = "bulk"
+	AuditImport    = "import"
+	AuditSync      = "sync"
+)
+
+// ActivityDay is the number of audit events on one UTC day, for the activity
+// heatmap. Date is formatted 2006-01-02.
+type ActivityDay struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// Job is the status of a recurring background job, such as backups or image
+// retries. Schedule is its cron-like schedule, NextRun when the schedule next
+// runs it, and LastRun its most recent run, nil before the first.
+type Job struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	NextRun  time.Time `json:"next_run"`
+	Running  bool      `json:"running"`
+	LastRun  *JobRun   `json:"last_run"`
+}
+
+// JobRun is one run of a job, started by its schedule or by hand (Trigger is
+// JobTriggerSchedule or JobTriggerManual). FinishedAt is nil while it runs,
+// and Error is "" unless it failed.
+type JobRun struct {
+	ID         int        `json:"id"`
+	Job        string     `json:"job"`
+	Trigger    string     `json:"trigger"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Error      string     `json:"error"`
+}
+
+// Job run triggers.
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+)
+
+// InventoryItem is an accessory kept alongside the cards, such as a pack of
+// sleeves, a deck box or a playmat. Kind is one of the ItemKind constants.
+type InventoryItem struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Quantity  int       `json:"quantity"`
+	Notes     string    `json:"notes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Inventory item kinds.
+const (
+	ItemKindSleeves = "sleeves"
+	ItemKindDeckBox = "deck_box"
+	ItemKindPlaymat = "playmat"
+	ItemKindOther   = "other"
+)
+
+// Event is a tournament or other event played, with the deck used and the
+// match record. PlayedOn is a date in YYYY-MM-DD form. Decks are identified
+// by name.
+type Event struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	PlayedOn  string    `json:"played_on"`
+	Deck      string    `json:"deck"`
+	Wins      int       `json:"wins"`
+	Losses    int       `json:"losses"`
+	Draws     int       `json:"draws"`
+	Notes     string    `json:"notes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DeckStats totals the events played with one deck. WinRate is the share of
+// matches won, from 0 to 1, and 0 when no matches were recorded.
+type DeckStats struct {
+	Deck    string  `json:"deck"`
+	Events  int     `json:"events"`
+	Wins    int     `json:"wins"`
+	Losses  int     `json:"losses"`
+	Draws   int     `json:"draws"`
+	WinRate float64 `json:"win_rate"`
+}
+
+// Cube is a draft pool built from owned cards. CardCount is the total number
+// of copies assigned to it.
+type Cube struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CardCount int       `json:"card_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CubeCard is a card assigned to a cube with the number of copies in it.
+type CubeCard struct {
+	Card
+	Count int `json:"count"`
+}
+
+// SavedSearch is a named collection search. Query holds the search text as
+// typed in the search box; pinned searches are shown on the index page.
+type SavedSearch struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	Pinned    bool      `json:"pinned"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Goal is a collection goal: owning Target copies of every card matching
+// Query, in the search box syntax, such as "set:LAW rarity:rare" with a
+// target of 3 for a playset of every LAW rare.
+type Goal struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	Target    int       `json:"target"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GoalProgress is how far the collection is towards a Goal. Cards is the
+// number of cards matching the goal's query, Complete how many of them have
+// at least Target copies, and Copies the owned copies counted towards the
+// goal, at most Target per card, out of Needed. Percent is Copies as a
+// whole percentage of Needed, and 100 for a goal no card matches.
+type GoalProgress struct {
+	Goal
+	Cards    int `json:"cards"`
+	Complete int `json:"complete"`
+	Copies   int `json:"copies"`
+	Needed   int `json:"needed"`
+	Percent  int `json:"percent"`
+}
+
+// ImportRecord is an entry in the import history: one finished import, its
+// outcome counts, and the failure message when it failed. Source is where it
+// came from ("api", "html", "catalog", "watch" or "rerun"), FileName the name of the
+// imported file when there was one (the set code for a catalog import), and
+// Changed the number of owned counts a sync import updated. HasFile reports
+// whether the imported file was kept, which allows re-running the import.
+type ImportRecord struct {
+	ID               int       `json:"id"`
+	Source           string    `json:"source"`
+	FileName         string    `json:"file_name"`
+	Mode             string    `json:"mode"`
+	Inserted         int       `json:"inserted"`
+	SkippedExisting  int       `json:"skipped_existing"`
+	SkippedDuplicate int       `json:"skipped_duplicate"`
+	ImageFailures    int       `json:"image_failures"`
+	RowErrors        int       `json:"row_errors"`
+	Changed          int       `json:"changed"`
+	Error            string    `json:"error"`
+	HasFile          bool      `json:"has_file"`
+	StartedAt        time.Time `json:"started_at"`
+	FinishedAt       time.Time `json:"finished_at"`
+}
+
+// Settings holds the app preferences edited on the settings page. The
+// minimum owned counts, one per card type plus UntypedMinimumOwned for cards
+// of any other type, decide which cards are on the wishlist. DefaultSort is
+// one of the database package's CardSort values and ItemsPerPage limits the
+// cards shown at once on the collection page, with 0 showing every card. The
+// import defaults are the options an import uses when it does not set them,
+// and ImportKeepFiles stores each imported file with its import history entry
+// so that the import can be re-run. DecrementFloor and MaxOwnedChange guard
+// owned counts against slips: decrementing a card below DecrementFloor
+// copies, or a sync changing a count by more than MaxOwnedChange or below
+// DecrementFloor, needs confirming. 0 turns either guard off.
+type Settings struct {
+	LeaderMinimumOwned  int    `json:"leader_minimum_owned"`
+	BaseMinimumOwned    int    `json:"base_minimum_owned"`
+	UnitMinimumOwned    int    `json:"unit_minimum_owned"`
+	EventMinimumOwned   int    `json:"event_minimum_owned"`
+	UpgradeMinimumOwned int    `json:"upgrade_minimum_owned"`
+	TokenMinimumOwned   int    `json:"token_minimum_owned"`
+	UntypedMinimumOwned int    `json:"untyped_minimum_owned"`
+	DefaultSort         string `json:"default_sort"`
+	Theme               string `json:"theme"`
+	ItemsPerPage        int    `json:"items_per_page"`
+	ImportLenient       bool   `json:"import_lenient"`
+	ImportUseOwnedCount bool   `json:"import_use_owned_count"`
+	ImportKeepFiles     bool   `json:"import_keep_files"`
+	HTMLOnly            bool   `json:"html_only"`
+	DigestChannel       string `json:"digest_channel"`
+	DigestTarget        string `json:"digest_target"`
+	DigestIntervalDays  int    `json:"digest_interval_days"`
+	DecrementFloor      int    `json:"decrement_floor"`
+	MaxOwnedChange      int    `json:"max_owned_change"`
+}
+
+// UI themes selectable in Settings.
+const (
+	ThemeDark  = "dark"
+	ThemeLight = "light"
+)
+
+// CartItem is a card in a shopping cart with the number of copies to buy.
+type CartItem struct {
+	CardID   int    `json:"card_id"`
+	Name     string `json:"name"`
+	Set      string `json:"set"`
+	Number   string `json:"number"`
+	Quantity int    `json:"quantity"`
 }
 
+// CardTranslation is a card's name in another language, identified by a
+// language tag such as "de" or "pt-br".
+type CardTranslation struct {
+	Language string `json:"language"`
+	Name     string `json:"name"`
+}
+
+// Channels a collection digest can be sent through. An empty DigestChannel
+// turns digests off.
+const (
+	DigestChannelNtfy  = "ntfy"
+	DigestChannelEmail = "email"
+)
+
+// Digest summarises collection changes between Since and Until. The name
+// lists hold at most the limit passed to GetDigest; the counts are totals.
+type Digest struct {
+	Since             time.Time `json:"since"`
+	Until             time.Time `json:"until"`
+	AddedCount        int       `json:"added_count"`
+	Added             []string  `json:"added"`
+	ChangedCount      int       `json:"changed_count"`
+	Changed           []string  `json:"changed"`
+	CompletedCount    int       `json:"completed_count"`
+	Completed         []string  `json:"completed"`
+	WishlistRemaining int       `json:"wishlist_remaining"`
+}
+
+// BuylistPrice is what a vendor pays for one card, in cents. Set and Number
+// are empty when the vendor lists the card by name only.
+type BuylistPrice struct {
+	Vendor     string `json:"vendor"`
+	Name       string `json:"name"`
+	Set        string `json:"set"`
+	Number     string `json:"number"`
+	PriceCents int    `json:"price_cents"`
+}
+
+// OwnedOperation is an increment or decrement of a card's owned count made on
+// a device, possibly while offline, and replayed later. ID, chosen by the
+// device, identifies the operation so that a replay is applied once. Action
+// is AuditIncrement or AuditDecrement, BaseOwned the owned count the device
+// showed before the change, and MadeAt when it was made.
+type OwnedOperation struct {
+	ID        string    `json:"id"`
+	CardID    int       `json:"card_id"`
+	Action    string    `json:"action"`
+	BaseOwned int       `json:"base_owned"`
+	MadeAt    time.Time `json:"made_at"`
+}
+
+// OwnedOperationResult is the outcome of replaying an OwnedOperation: its
+// status and the card's owned count afterwards (0 when the card is unknown).
+type OwnedOperationResult struct {
+	ID     string `json:"id"`
+	CardID int    `json:"card_id"`
+	Status string `json:"status"`
+	Owned  int    `json:"owned"`
+}
+
+// OwnedOperationResult statuses. A conflict is applied on top of the server's
+// count, which another device or page changed since the operation's
+// BaseOwned; a clamped decrement found the count already at 0. A rejected
+// operation was not applied because the owned count protection settings
+// require it to be confirmed; it can be sent again with confirmation.
+const (
+	OperationApplied   = "applied"
+	OperationConflict  = "conflict"
+	OperationClamped   = "clamped"
+	OperationDuplicate = "duplicate"
+	OperationNotFound  = "not_found"
+	OperationInvalid   = "invalid"
+	OperationRejected  = "rejected"
+)
+
 // CardCSV represents a single row from a card collection CSV export.
 // The fields map directly to the CSV column headers.
 type CardCSV struct {
@@ -34,3 +488,37 @@ type CardCSV struct {
 	OwnedCount      string
 	GroupOwnedCount string
 }
+
+// Card types, rarities and aspects as they appear in CSV exports. Values are
+// matched case-insensitively.
+const (
+	CardTypeLeader  = "Leader"
+	CardTypeBase    = "Base"
+	CardTypeUnit    = "Unit"
+	CardTypeEvent   = "Event"
+	CardTypeUpgrade = "Upgrade"
+	CardTypeToken   = "Token"
+
+	RarityCommon    = "Common"
+	RarityUncommon  = "Uncommon"
+	RarityRare      = "Rare"
+	RarityLegendary = "Legendary"
+	RaritySpecial   = "Special"
+
+	AspectVigilance  = "Vigilance"
+	AspectCommand    = "Command"
+	AspectAggression = "Aggression"
+	AspectCunning    = "Cunning"
+	AspectVillainy   = "Villainy"
+	AspectHeroism    = "Heroism"
+)
+
+// CardTypes lists every known card type.
+var CardTypes = []string{CardTypeLeader, CardTypeBase, CardTypeUnit, CardTypeEvent, CardTypeUpgrade, CardTypeToken}
+
+// Rarities lists every known rarity.
+var Rarities = []string{RarityCommon, RarityUncommon, RarityRare, RarityLegendary, RaritySpecial}
+
+// Aspects lists every known aspect. A card's aspects are joined with "|" in
+// the CSV.
+var Aspects = []string{AspectVigilance, AspectCommand, AspectAggression, AspectCunning, AspectVillainy, AspectHeroism}
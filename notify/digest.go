@@ -0,0 +1,206 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"swucol/database"
+	"swucol/models"
+)
+
+// digestLimit is the most card names listed under each heading of a digest.
+const digestLimit = 10
+
+// SMTPConfig is the mail server digests sent by email go through.
+type SMTPConfig struct {
+	// Addr is the server's host:port.
+	Addr string
+	// Username and Password authenticate with PLAIN auth. Both are optional.
+	Username string
+	Password string
+	// From is the sender address.
+	From string
+}
+
+// SMTPConfigFromEnv reads the mail server from SWUCOL_SMTP_* environment
+// variables. Email is optional: when SWUCOL_SMTP_ADDR is unset, it returns
+// false and no error. Returns an error when the address is set but
+// SWUCOL_SMTP_FROM is not.
+func SMTPConfigFromEnv() (SMTPConfig, bool, error) {
+	config := SMTPConfig{
+		Addr:     os.Getenv("SWUCOL_SMTP_ADDR"),
+		Username: os.Getenv("SWUCOL_SMTP_USERNAME"),
+		Password: os.Getenv("SWUCOL_SMTP_PASSWORD"),
+		From:     os.Getenv("SWUCOL_SMTP_FROM"),
+	}
+
+	if config.Addr == "" {
+		return SMTPConfig{}, false, nil
+	}
+
+	if config.From == "" {
+		return SMTPConfig{}, false, errors.New("SWUCOL_SMTP_FROM must be set when SWUCOL_SMTP_ADDR is set")
+	}
+
+	return config, true, nil
+}
+
+// Digester sends the periodic collection digest through the channel chosen
+// in Settings.
+type Digester struct {
+	db         *database.Database
+	httpClient *http.Client
+	smtp       SMTPConfig
+}
+
+// NewDigester returns a Digester that posts ntfy digests with httpClient and
+// sends email digests through smtpConfig. An empty smtpConfig disables email.
+func NewDigester(db *database.Database, httpClient *http.Client, smtpConfig SMTPConfig) *Digester {
+	return &Digester{db: db, httpClient: httpClient, smtp: smtpConfig}
+}
+
+// SendIfDue sends a digest when digests are on and at least
+// DigestIntervalDays have passed since the last one. The digest covers the
+// changes made since the last digest, or over the past interval when none
+// has been sent. Returns whether a digest was sent.
+func (digester *Digester) SendIfDue(ctx context.Context, now time.Time) (bool, error) {
+	settings := digester.db.Settings()
+	if settings.DigestChannel == "" {
+		return false, nil
+	}
+
+	interval := time.Duration(settings.DigestIntervalDays) * 24 * time.Hour
+
+	since, err := digester.db.GetLastDigestTime()
+	if err != nil {
+		return false, err
+	}
+
+	if since.IsZero() {
+		since = now.Add(-interval)
+	} else if now.Sub(since) < interval {
+		return false, nil
+	}
+
+	digest, err := digester.db.GetDigest(since, now, digestLimit)
+	if err != nil {
+		return false, err
+	}
+
+	title, body := FormatDigest(digest)
+
+	switch settings.DigestChannel {
+	case models.DigestChannelNtfy:
+		err = digester.sendNtfy(ctx, settings.DigestTarget, title, body)
+	case models.DigestChannelEmail:
+		err = digester.sendEmail(settings.DigestTarget, title, body)
+	default:
+		err = fmt.Errorf("unknown digest channel %q", settings.DigestChannel)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := digester.db.RecordDigest(settings.DigestChannel, now); err != nil {
+		return false, err
+	}
+
+	slog.Info("collection digest sent", "channel", settings.DigestChannel, "added", digest.AddedCount, "changed", digest.ChangedCount, "completed", digest.CompletedCount)
+
+	return true, nil
+}
+
+// sendNtfy publishes body to the ntfy topic at topicURL with title as the
+// notification title.
+func (digester *Digester) sendNtfy(ctx context.Context, topicURL, title, body string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, topicURL, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build ntfy request: %w", err)
+	}
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	request.Header.Set("Title", title)
+
+	response, err := digester.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("post ntfy: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("post ntfy: unexpected status %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// sendEmail mails body to recipient with title as the subject.
+func (digester *Digester) sendEmail(recipient, title, body string) error {
+	if digester.smtp.Addr == "" {
+		return errors.New("send email: SWUCOL_SMTP_ADDR is not set")
+	}
+
+	var auth smtp.Auth
+	if digester.smtp.Username != "" {
+		host, _, err := net.SplitHostPort(digester.smtp.Addr)
+		if err != nil {
+			return fmt.Errorf("send email: %w", err)
+		}
+		auth = smtp.PlainAuth("", digester.smtp.Username, digester.smtp.Password, host)
+	}
+
+	message := "From: " + digester.smtp.From + "\r\n" +
+		"To: " + recipient + "\r\n" +
+		"Subject: " + title + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		strings.ReplaceAll(body, "\n", "\r\n")
+
+	if err := smtp.SendMail(digester.smtp.Addr, auth, digester.smtp.From, []string{recipient}, []byte(message)); err != nil {
+		return fmt.Errorf("send email: %w", err)
+	}
+
+	return nil
+}
+
+// FormatDigest renders digest as a plain-text notification title and body.
+func FormatDigest(digest models.Digest) (string, string) {
+	title := fmt.Sprintf("Collection digest: %s to %s", digest.Since.Format("Jan 2"), digest.Until.Format("Jan 2"))
+
+	var body strings.Builder
+	writeDigestList(&body, "Added", digest.AddedCount, digest.Added)
+	writeDigestList(&body, "Changed", digest.ChangedCount, digest.Changed)
+	writeDigestList(&body, "Wishlist completed", digest.CompletedCount, digest.Completed)
+	fmt.Fprintf(&body, "Still wanted: %d %s\n", digest.WishlistRemaining, pluralCards(digest.WishlistRemaining))
+
+	return title, body.String()
+}
+
+// writeDigestList writes one digest line: the heading, the count and the
+// listed names, noting how many more there are beyond them.
+func writeDigestList(body *strings.Builder, heading string, count int, names []string) {
+	fmt.Fprintf(body, "%s: %d %s", heading, count, pluralCards(count))
+	if len(names) > 0 {
+		body.WriteString(" (" + strings.Join(names, ", "))
+		if more := count - len(names); more > 0 {
+			fmt.Fprintf(body, " and %d more", more)
+		}
+		body.WriteString(")")
+	}
+	body.WriteString("\n")
+}
+
+// pluralCards returns "card" or "cards" to follow count.
+func pluralCards(count int) string {
+	if count == 1 {
+		return "card"
+	}
+	return "cards"
+}
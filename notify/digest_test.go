@@ -0,0 +1,118 @@
+package notify_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/database"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/notify"
+)
+
+// enableDigest saves settings that send digests through channel to target
+// every week.
+func enableDigest(t *testing.T, db *database.Database, channel, target string) {
+	t.Helper()
+
+	settings := database.DefaultSettings()
+	settings.DigestChannel = channel
+	settings.DigestTarget = target
+	require.NoError(t, db.SaveSettings(settings))
+}
+
+func TestSendIfDue_Ntfy_PostsDigestAndWaitsForInterval(t *testing.T) {
+	db := testdb.New(t)
+	completeCard(t, db, "Han Solo, Reluctant Hero")
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Battlefield Marine", Mainboard: true}))
+
+	var titles, bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		body, err := io.ReadAll(request.Body)
+		require.NoError(t, err)
+		titles = append(titles, request.Header.Get("Title"))
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	enableDigest(t, db, models.DigestChannelNtfy, server.URL+"/collection")
+	digester := notify.NewDigester(db, server.Client(), notify.SMTPConfig{})
+
+	now := time.Now().Add(time.Minute)
+	sent, err := digester.SendIfDue(context.Background(), now)
+	require.NoError(t, err)
+	assert.True(t, sent)
+
+	require.Len(t, bodies, 1)
+	assert.Contains(t, titles[0], "Collection digest")
+	assert.Contains(t, bodies[0], "Added: 2 cards (")
+	assert.Contains(t, bodies[0], "Wishlist completed: 1 card (Han Solo, Reluctant Hero)")
+	assert.Contains(t, bodies[0], "Still wanted: 1 card")
+
+	sent, err = digester.SendIfDue(context.Background(), now.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.False(t, sent, "expected no digest before the interval has passed")
+
+	sent, err = digester.SendIfDue(context.Background(), now.Add(8*24*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, sent)
+	require.Len(t, bodies, 2)
+	assert.Contains(t, bodies[1], "Added: 0 cards\n")
+}
+
+func TestSendIfDue_Off_SendsNothing(t *testing.T) {
+	db := testdb.New(t)
+
+	sent, err := notify.NewDigester(db, http.DefaultClient, notify.SMTPConfig{}).SendIfDue(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.False(t, sent)
+}
+
+func TestSendIfDue_NtfyFailure_DoesNotRecordDigest(t *testing.T) {
+	db := testdb.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	enableDigest(t, db, models.DigestChannelNtfy, server.URL+"/collection")
+
+	_, err := notify.NewDigester(db, server.Client(), notify.SMTPConfig{}).SendIfDue(context.Background(), time.Now())
+	assert.Error(t, err)
+
+	last, err := db.GetLastDigestTime()
+	require.NoError(t, err)
+	assert.True(t, last.IsZero(), "expected a failed digest to be retried")
+}
+
+func TestSendIfDue_EmailWithoutSMTP_ReturnsError(t *testing.T) {
+	db := testdb.New(t)
+	enableDigest(t, db, models.DigestChannelEmail, "collector@example.com")
+
+	_, err := notify.NewDigester(db, http.DefaultClient, notify.SMTPConfig{}).SendIfDue(context.Background(), time.Now())
+	assert.ErrorContains(t, err, "SWUCOL_SMTP_ADDR")
+}
+
+func TestFormatDigest_ListsNamesAndRemainder(t *testing.T) {
+	title, body := notify.FormatDigest(models.Digest{
+		Since:             time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+		Until:             time.Date(2025, time.March, 8, 0, 0, 0, 0, time.UTC),
+		AddedCount:        3,
+		Added:             []string{"Luke Skywalker", "Darth Vader"},
+		WishlistRemaining: 5,
+	})
+
+	assert.Equal(t, "Collection digest: Mar 1 to Mar 8", title)
+	assert.Equal(t, "Added: 3 cards (Luke Skywalker, Darth Vader and 1 more)\n"+
+		"Changed: 0 cards\n"+
+		"Wishlist completed: 0 cards\n"+
+		"Still wanted: 5 cards\n", body)
+}
@@ -0,0 +1,138 @@
+// Package notify delivers collection events to an external webhook so that
+// progress is visible outside the app.
+//
+// Events are read from the database rather than passed in by the handlers
+// that cause them, so an event is only marked delivered once the webhook has
+// accepted it and failed deliveries are retried on the next run. The bus
+// events that can complete a wishlist entry only wake the Notifier up, so
+// that completions go out right away rather than at the next interval.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"swucol/database"
+	"swucol/eventbus"
+	"swucol/models"
+)
+
+// EventWishlistSatisfied is the event type sent when cards reach their minimum
+// owned threshold.
+const EventWishlistSatisfied = "wishlist.satisfied"
+
+// Event is the JSON body posted to the webhook.
+type Event struct {
+	Type        string                      `json:"event"`
+	Completions []models.WishlistCompletion `json:"completions"`
+}
+
+// Notifier posts pending wishlist completions to a webhook URL.
+type Notifier struct {
+	db         *database.Database
+	httpClient *http.Client
+	webhookURL string
+	// wake asks Schedule to deliver before its next tick.
+	wake chan struct{}
+}
+
+// New returns a Notifier that delivers events to webhookURL.
+func New(db *database.Database, httpClient *http.Client, webhookURL string) *Notifier {
+	return &Notifier{db: db, httpClient: httpClient, webhookURL: webhookURL, wake: make(chan struct{}, 1)}
+}
+
+// Subscribe wakes Schedule whenever eventbus.Default publishes an owned count
+// change or a completed import, either of which may complete wishlist
+// entries, until the returned function is called. It does not wait for the
+// delivery.
+func (notifier *Notifier) Subscribe() func() {
+	wake := func(context.Context, eventbus.Event) {
+		select {
+		case notifier.wake <- struct{}{}:
+		default:
+			// A delivery is already pending.
+		}
+	}
+
+	unsubscribeOwned := eventbus.Subscribe(eventbus.OwnedChanged, wake)
+	unsubscribeImport := eventbus.Subscribe(eventbus.ImportCompleted, wake)
+
+	return func() {
+		unsubscribeOwned()
+		unsubscribeImport()
+	}
+}
+
+// DeliverPending posts every wishlist completion not yet delivered as a single
+// EventWishlistSatisfied event and marks them delivered once the webhook
+// responds with a 2xx status. Returns the number of completions delivered.
+func (notifier *Notifier) DeliverPending(ctx context.Context) (int, error) {
+	completions, err := notifier.db.GetUnnotifiedWishlistCompletions()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(completions) == 0 {
+		return 0, nil
+	}
+
+	body, err := json.Marshal(Event{Type: EventWishlistSatisfied, Completions: completions})
+	if err != nil {
+		return 0, fmt.Errorf("encode event: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, notifier.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("build webhook request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := notifier.httpClient.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("post webhook: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return 0, fmt.Errorf("post webhook: unexpected status %d", response.StatusCode)
+	}
+
+	ids := make([]int, len(completions))
+	for i, completion := range completions {
+		ids[i] = completion.ID
+	}
+
+	if err := notifier.db.MarkWishlistCompletionsNotified(ids); err != nil {
+		return 0, err
+	}
+
+	slog.Info("wishlist completions delivered", "count", len(completions))
+
+	return len(completions), nil
+}
+
+// Schedule calls DeliverPending every interval, and whenever a subscribed
+// event wakes it, until ctx is cancelled. Failures are logged and retried at
+// the next tick.
+func (notifier *Notifier) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-notifier.wake:
+		}
+
+		if _, err := notifier.DeliverPending(ctx); err != nil {
+			slog.Error("webhook delivery failed", "error", err)
+		}
+	}
+}
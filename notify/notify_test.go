@@ -0,0 +1,107 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/database"
+	"swucol/eventbus"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/notify"
+)
+
+// completeCard inserts a leader and raises it to its minimum owned threshold,
+// recording a wishlist completion.
+func completeCard(t *testing.T, db *database.Database, name string) {
+	t.Helper()
+
+	require.NoError(t, db.InsertCard(models.NewCard{Name: name, Type: models.CardTypeLeader}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{name: database.LeaderMinimumOwned}))
+}
+
+func TestDeliverPending_PostsEventAndMarksCompletionsDelivered(t *testing.T) {
+	db := testdb.New(t)
+	completeCard(t, db, "Han Solo, Reluctant Hero")
+
+	var received []notify.Event
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+		var event notify.Event
+		require.NoError(t, json.NewDecoder(request.Body).Decode(&event))
+		received = append(received, event)
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := notify.New(db, server.Client(), server.URL)
+
+	delivered, err := notifier.DeliverPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+
+	require.Len(t, received, 1)
+	assert.Equal(t, notify.EventWishlistSatisfied, received[0].Type)
+	require.Len(t, received[0].Completions, 1)
+	assert.Equal(t, "Han Solo, Reluctant Hero", received[0].Completions[0].Name)
+
+	delivered, err = notifier.DeliverPending(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+	assert.Len(t, received, 1, "expected no second request once delivered")
+}
+
+func TestDeliverPending_WebhookError_KeepsCompletionsPending(t *testing.T) {
+	db := testdb.New(t)
+	completeCard(t, db, "Han Solo, Reluctant Hero")
+
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := notify.New(db, server.Client(), server.URL).DeliverPending(context.Background())
+	assert.Error(t, err)
+
+	pending, err := db.GetUnnotifiedWishlistCompletions()
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+}
+
+func TestSubscribe_OwnedChangeDeliversBeforeTheNextTick(t *testing.T) {
+	db := testdb.New(t)
+	completeCard(t, db, "Han Solo, Reluctant Hero")
+
+	received := make(chan notify.Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		var event notify.Event
+		assert.NoError(t, json.NewDecoder(request.Body).Decode(&event))
+		received <- event
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := notify.New(db, server.Client(), server.URL)
+	t.Cleanup(notifier.Subscribe())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Schedule(ctx, time.Hour)
+
+	eventbus.Publish(ctx, eventbus.Event{Type: eventbus.OwnedChanged, CardName: "Han Solo, Reluctant Hero", Cause: models.AuditIncrement, Owned: 3})
+
+	select {
+	case event := <-received:
+		require.Len(t, event.Completions, 1)
+		assert.Equal(t, "Han Solo, Reluctant Hero", event.Completions[0].Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the owned change to trigger a delivery")
+	}
+}
@@ -0,0 +1,65 @@
+package packs
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+
+	"swucol/database"
+)
+
+// simulateResponse is the JSON body returned by SimulatePackHandler.
+type simulateResponse struct {
+	Set   string     `json:"set"`
+	Seed  uint64     `json:"seed"`
+	Cards []PackCard `json:"cards"`
+}
+
+// SimulatePackHandler returns an http.HandlerFunc that handles
+// GET /packs/simulate. It reads the optional "set" query parameter to limit
+// the pool to one set (all sets when absent) and the optional "seed" query
+// parameter to make the pack reproducible. The seed used is echoed in the
+// response so a fun pack can be regenerated later. Returns 200 OK with the
+// simulated pack as JSON, 400 Bad Request for a non-numeric seed, 404 Not
+// Found when the pool is empty, and 500 Internal Server Error for database
+// errors.
+func SimulatePackHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		set := request.URL.Query().Get("set")
+
+		seed := rand.Uint64()
+		if rawSeed := request.URL.Query().Get("seed"); rawSeed != "" {
+			parsed, err := strconv.ParseUint(rawSeed, 10, 64)
+			if err != nil {
+				http.Error(responseWriter, "seed must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+			seed = parsed
+		}
+
+		pool, err := db.GetCardsBySet(set)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading pack pool", "set", set, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		if len(pool) == 0 {
+			http.Error(responseWriter, "no cards found for set", http.StatusNotFound)
+			return
+		}
+
+		pack := Simulate(pool, rand.New(rand.NewPCG(seed, seed)))
+
+		slog.InfoContext(request.Context(), "simulated booster pack", "set", set, "seed", seed, "card_count", len(pack))
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(simulateResponse{Set: set, Seed: seed, Cards: pack}); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode pack response", "set", set, "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package packs_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/database"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/packs"
+)
+
+// simulatePack sends a GET request to SimulatePackHandler with the given
+// raw query string.
+func simulatePack(t *testing.T, db *database.Database, rawQuery string) *http.Response {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodGet, "/packs/simulate?"+rawQuery, nil)
+	recorder := httptest.NewRecorder()
+
+	packs.SimulatePackHandler(db)(recorder, request)
+
+	return recorder.Result()
+}
+
+// simulateResponse mirrors the JSON body returned by SimulatePackHandler.
+type simulateResponse struct {
+	Set   string           `json:"set"`
+	Seed  uint64           `json:"seed"`
+	Cards []packs.PackCard `json:"cards"`
+}
+
+func TestSimulatePackHandler_WithSet_ReturnsCardsFromThatSetOnly(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Law Common", Set: "LAW", Type: "Unit", Rarity: "Common", Mainboard: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Sor Common", Set: "SOR", Type: "Unit", Rarity: "Common", Mainboard: true}))
+
+	response := simulatePack(t, db, "set=LAW&seed=42")
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+
+	var result simulateResponse
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	assert.Equal(t, "LAW", result.Set)
+	assert.Equal(t, uint64(42), result.Seed)
+	require.NotEmpty(t, result.Cards)
+	for _, packCard := range result.Cards {
+		assert.Equal(t, "Law Common", packCard.Card.Name)
+	}
+}
+
+func TestSimulatePackHandler_SameSeed_ReturnsSamePack(t *testing.T) {
+	db := testdb.New(t)
+	for _, name := range []string{"Common A", "Common B", "Common C", "Common D"} {
+		require.NoError(t, db.InsertCard(models.NewCard{Name: name, Set: "LAW", Type: "Unit", Rarity: "Common", Mainboard: true}))
+	}
+
+	var first, second simulateResponse
+	require.NoError(t, json.NewDecoder(simulatePack(t, db, "seed=7").Body).Decode(&first))
+	require.NoError(t, json.NewDecoder(simulatePack(t, db, "seed=7").Body).Decode(&second))
+
+	assert.Equal(t, first.Cards, second.Cards)
+}
+
+func TestSimulatePackHandler_UnknownSet_Returns404(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Law Common", Set: "LAW", Type: "Unit", Rarity: "Common", Mainboard: true}))
+
+	response := simulatePack(t, db, "set=XYZ")
+
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestSimulatePackHandler_InvalidSeed_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	response := simulatePack(t, db, "seed=abc")
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
@@ -0,0 +1,184 @@
+// Package packs simulates Star Wars: Unlimited booster packs using the cards
+// stored in the collection database as the card pool.
+package packs
+
+import (
+	"math/rand/v2"
+	"strings"
+
+	"swucol/models"
+)
+
+// rarityWeight is the relative chance of a slot being filled with a card of
+// the given rarity.
+type rarityWeight struct {
+	rarity string
+	weight int
+}
+
+// slotKind selects which part of the card pool a slot draws from.
+type slotKind int
+
+const (
+	// slotLeader draws from Leader cards only.
+	slotLeader slotKind = iota
+
+	// slotBase draws from Base cards only.
+	slotBase
+
+	// slotMain draws from every card that is neither a Leader nor a Base.
+	slotMain
+
+	// slotAny draws from the whole pool.
+	slotAny
+)
+
+// slot describes a single position in a booster pack.
+type slot struct {
+	name    string
+	kind    slotKind
+	foil    bool
+	weights []rarityWeight
+}
+
+// boosterSlots is the layout of a standard 16-card booster: one leader, one
+// base, nine commons, three uncommons, one rare or legendary, and one foil of
+// any rarity. The weights approximate the published pull rates.
+var boosterSlots = buildBoosterSlots()
+
+// buildBoosterSlots returns the slot layout used for boosterSlots.
+func buildBoosterSlots() []slot {
+	slots := []slot{
+		{name: "leader", kind: slotLeader, weights: []rarityWeight{{"Common", 5}, {"Rare", 1}}},
+		{name: "base", kind: slotBase, weights: []rarityWeight{{"Common", 1}}},
+	}
+
+	for range 9 {
+		slots = append(slots, slot{name: "common", kind: slotMain, weights: []rarityWeight{{"Common", 1}}})
+	}
+
+	for range 3 {
+		slots = append(slots, slot{name: "uncommon", kind: slotMain, weights: []rarityWeight{{"Uncommon", 1}}})
+	}
+
+	slots = append(slots,
+		slot{name: "rare", kind: slotMain, weights: []rarityWeight{{"Rare", 7}, {"Legendary", 1}}},
+		slot{name: "foil", kind: slotAny, foil: true, weights: []rarityWeight{{"Common", 60}, {"Uncommon", 25}, {"Rare", 12}, {"Legendary", 3}}},
+	)
+
+	return slots
+}
+
+// PackCard is a single card pulled from a simulated booster.
+type PackCard struct {
+	Slot string      `json:"slot"`
+	Foil bool        `json:"foil"`
+	Card models.Card `json:"card"`
+}
+
+// Simulate opens a virtual booster from pool using rng. Each slot rolls a
+// rarity from its weights, ignoring rarities with no matching cards, and then
+// picks a card of that rarity. Non-foil slots avoid repeating a card while
+// unused candidates remain. Slots with no candidates at all are left out, so
+// a sparse pool yields a short pack. Returns an empty slice (never nil) for an
+// empty pool.
+func Simulate(pool []models.Card, rng *rand.Rand) []PackCard {
+	pack := []PackCard{}
+	used := make(map[int]bool)
+
+	for _, packSlot := range boosterSlots {
+		candidates := slotCandidates(pool, packSlot)
+
+		rarity, ok := rollRarity(candidates, packSlot.weights, rng)
+		if !ok {
+			continue
+		}
+
+		var ofRarity []models.Card
+		for _, card := range candidates {
+			if strings.EqualFold(card.Rarity, rarity) {
+				ofRarity = append(ofRarity, card)
+			}
+		}
+
+		if !packSlot.foil {
+			var unused []models.Card
+			for _, card := range ofRarity {
+				if !used[card.ID] {
+					unused = append(unused, card)
+				}
+			}
+			if len(unused) > 0 {
+				ofRarity = unused
+			}
+		}
+
+		card := ofRarity[rng.IntN(len(ofRarity))]
+		if !packSlot.foil {
+			used[card.ID] = true
+		}
+
+		pack = append(pack, PackCard{Slot: packSlot.name, Foil: packSlot.foil, Card: card})
+	}
+
+	return pack
+}
+
+// slotCandidates returns the cards in pool that packSlot may draw from.
+func slotCandidates(pool []models.Card, packSlot slot) []models.Card {
+	var candidates []models.Card
+	for _, card := range pool {
+		isLeader := strings.EqualFold(card.Type, "leader")
+		isBase := strings.EqualFold(card.Type, "base")
+
+		switch packSlot.kind {
+		case slotLeader:
+			if !isLeader {
+				continue
+			}
+		case slotBase:
+			if !isBase {
+				continue
+			}
+		case slotMain:
+			if isLeader || isBase {
+				continue
+			}
+		}
+
+		candidates = append(candidates, card)
+	}
+	return candidates
+}
+
+// rollRarity picks a rarity from weights using rng, considering only
+// rarities that at least one candidate has. Returns false when no weighted
+// rarity has any candidates.
+func rollRarity(candidates []models.Card, weights []rarityWeight, rng *rand.Rand) (string, bool) {
+	available := make([]rarityWeight, 0, len(weights))
+	total := 0
+
+	for _, weighted := range weights {
+		for _, card := range candidates {
+			if strings.EqualFold(card.Rarity, weighted.rarity) {
+				available = append(available, weighted)
+				total += weighted.weight
+				break
+			}
+		}
+	}
+
+	if total == 0 {
+		return "", false
+	}
+
+	roll := rng.IntN(total)
+	for _, weighted := range available {
+		if roll < weighted.weight {
+			return weighted.rarity, true
+		}
+		roll -= weighted.weight
+	}
+
+	return "", false
+}
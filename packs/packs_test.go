@@ -0,0 +1,118 @@
+package packs_test
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/models"
+	"swucol/packs"
+)
+
+// newTestRand returns a deterministic random source for reproducible packs.
+func newTestRand() *rand.Rand {
+	return rand.New(rand.NewPCG(1, 2))
+}
+
+// fullPool returns a pool containing enough cards of every rarity and type to
+// fill every booster slot without repeats.
+func fullPool() []models.Card {
+	var pool []models.Card
+	id := 1
+	add := func(count int, cardType, rarity string) {
+		for range count {
+			pool = append(pool, models.Card{ID: id, Name: cardType + " " + rarity, Type: cardType, Rarity: rarity})
+			id++
+		}
+	}
+	add(3, "Leader", "Common")
+	add(2, "Leader", "Rare")
+	add(3, "Base", "Common")
+	add(20, "Unit", "Common")
+	add(10, "Event", "Uncommon")
+	add(5, "Unit", "Rare")
+	add(2, "Unit", "Legendary")
+	return pool
+}
+
+func TestSimulate_FullPool_Returns16CardBooster(t *testing.T) {
+	pack := packs.Simulate(fullPool(), newTestRand())
+
+	require.Len(t, pack, 16)
+
+	slotCounts := map[string]int{}
+	for _, packCard := range pack {
+		slotCounts[packCard.Slot]++
+	}
+	assert.Equal(t, 1, slotCounts["leader"])
+	assert.Equal(t, 1, slotCounts["base"])
+	assert.Equal(t, 9, slotCounts["common"])
+	assert.Equal(t, 3, slotCounts["uncommon"])
+	assert.Equal(t, 1, slotCounts["rare"])
+	assert.Equal(t, 1, slotCounts["foil"])
+}
+
+func TestSimulate_SlotsDrawMatchingTypesAndRarities(t *testing.T) {
+	pack := packs.Simulate(fullPool(), newTestRand())
+
+	for _, packCard := range pack {
+		switch packCard.Slot {
+		case "leader":
+			assert.Equal(t, "Leader", packCard.Card.Type)
+		case "base":
+			assert.Equal(t, "Base", packCard.Card.Type)
+		case "common":
+			assert.Equal(t, "Common", packCard.Card.Rarity)
+			assert.NotEqual(t, "Leader", packCard.Card.Type)
+			assert.NotEqual(t, "Base", packCard.Card.Type)
+		case "uncommon":
+			assert.Equal(t, "Uncommon", packCard.Card.Rarity)
+		case "rare":
+			assert.Contains(t, []string{"Rare", "Legendary"}, packCard.Card.Rarity)
+		case "foil":
+			assert.True(t, packCard.Foil)
+		}
+	}
+}
+
+func TestSimulate_NonFoilSlotsDoNotRepeatCardsWhenPoolAllows(t *testing.T) {
+	pack := packs.Simulate(fullPool(), newTestRand())
+
+	seen := map[int]bool{}
+	for _, packCard := range pack {
+		if packCard.Foil {
+			continue
+		}
+		assert.False(t, seen[packCard.Card.ID], "expected card %d to appear once", packCard.Card.ID)
+		seen[packCard.Card.ID] = true
+	}
+}
+
+func TestSimulate_SparsePool_SkipsSlotsWithoutCandidates(t *testing.T) {
+	pool := []models.Card{
+		{ID: 1, Name: "Only Common", Type: "Unit", Rarity: "Common"},
+	}
+
+	pack := packs.Simulate(pool, newTestRand())
+
+	for _, packCard := range pack {
+		assert.Contains(t, []string{"common", "foil"}, packCard.Slot)
+	}
+	assert.Len(t, pack, 10, "expected nine common slots plus the foil slot")
+}
+
+func TestSimulate_EmptyPool_ReturnsEmptySlice(t *testing.T) {
+	pack := packs.Simulate(nil, newTestRand())
+
+	assert.NotNil(t, pack)
+	assert.Empty(t, pack)
+}
+
+func TestSimulate_SameSeed_ReturnsSamePack(t *testing.T) {
+	first := packs.Simulate(fullPool(), newTestRand())
+	second := packs.Simulate(fullPool(), newTestRand())
+
+	assert.Equal(t, first, second)
+}
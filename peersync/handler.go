@@ -0,0 +1,132 @@
+package peersync
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"swucol/cardservice"
+	"swucol/database"
+)
+
+// PullHandler returns an http.HandlerFunc that handles GET /sync/pull. It
+// reads the optional "since" query parameter (RFC 3339) and responds with
+// every card that changed after it, or every card when absent. Returns 200 OK
+// with the changes as JSON, 400 Bad Request for an invalid since, and 500
+// Internal Server Error for database errors.
+func PullHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		var since time.Time
+		if rawSince := request.URL.Query().Get("since"); rawSince != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, rawSince)
+			if err != nil {
+				http.Error(responseWriter, "since must be an RFC 3339 timestamp", http.StatusBadRequest)
+				return
+			}
+			since = parsed
+		}
+
+		changes, err := collectChanges(db, since)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error collecting sync changes", "since", since, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "sync pull served", "since", since, "card_count", len(changes.Cards))
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(changes); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode sync changes", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// PushHandler returns an http.HandlerFunc that handles POST /sync/push. It
+// decodes a JSON Changes body and merges the cards using last-write-wins with
+// cardservice.CollectionService.ApplyPeerCards, which publishes the owned
+// count changes. Unless the "confirm" query parameter is true, a push
+// changing any existing card's owned count in a way the owned count
+// protection settings guard (see cardservice.NeedsConfirmation) is refused
+// and nothing changes; Run confirms its pushes, whose changes were already
+// made on the pushing instance.
+// Returns 200 OK with the number of cards applied, 400 Bad Request for a
+// malformed body, invalid card or confirm value, 409 Conflict for a push that
+// must be confirmed, and 500 Internal Server Error otherwise.
+func PushHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		confirmed := false
+		if rawConfirm := request.URL.Query().Get("confirm"); rawConfirm != "" {
+			parsed, err := strconv.ParseBool(rawConfirm)
+			if err != nil {
+				http.Error(responseWriter, "confirm must be a boolean", http.StatusBadRequest)
+				return
+			}
+			confirmed = parsed
+		}
+
+		var changes Changes
+		if err := json.NewDecoder(request.Body).Decode(&changes); err != nil {
+			http.Error(responseWriter, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		applied, err := cardservice.NewCollectionService(db).ApplyPeerCards(request.Context(), changes.Cards, confirmed)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch cardservice.KindOf(err) {
+			case cardservice.KindInvalid:
+				status = http.StatusBadRequest
+			case cardservice.KindConfirm:
+				status = http.StatusConflict
+			}
+			http.Error(responseWriter, err.Error(), status)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "sync push applied", "received", len(changes.Cards), "applied", applied)
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(pushResponse{Applied: applied}); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode sync push response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// RunHandler returns an http.HandlerFunc that handles POST /sync/run. It
+// synchronises with the instance whose base URL is given in the "peer" query
+// parameter (e.g. http://laptop:8080), sending token to it (see Run), and
+// responds with a summary of the run.
+// Returns 200 OK on success, 400 Bad Request when peer is missing, and 502 Bad
+// Gateway when the exchange fails.
+func RunHandler(db *database.Database, client *http.Client, token string) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		peer := request.URL.Query().Get("peer")
+		if peer == "" {
+			http.Error(responseWriter, "peer query parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		result, err := Run(request.Context(), db, client, peer, token)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "sync with peer failed", "peer", peer, "error", err)
+			http.Error(responseWriter, "sync failed: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "sync with peer complete", "peer", peer, "pulled", result.Pulled, "applied", result.Applied, "pushed", result.Pushed)
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(result); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode sync result", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package peersync keeps two swucol instances in step by exchanging the cards
+// that changed since the last exchange. Conflicts are resolved per card by
+// last-write-wins on the card's change stamp (updated_at, falling back to
+// created_at); see database.ApplyRemoteCards.
+package peersync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"swucol/cardservice"
+	"swucol/database"
+	"swucol/models"
+)
+
+// Changes is the payload exchanged by the pull and push endpoints. Cursor is
+// the change stamp of the newest card in Cards; the receiver passes it back as
+// "since" on its next pull.
+type Changes struct {
+	Cursor time.Time     `json:"cursor,omitzero"`
+	Cards  []models.Card `json:"cards"`
+}
+
+// Result summarises a sync run against a peer.
+type Result struct {
+	Pulled  int `json:"pulled"`
+	Applied int `json:"applied"`
+	Pushed  int `json:"pushed"`
+}
+
+// pushResponse is the JSON body returned by PushHandler.
+type pushResponse struct {
+	Applied int `json:"applied"`
+}
+
+// changeStamp returns the time card last changed: UpdatedAt, falling back to
+// CreatedAt for cards never modified after insert.
+func changeStamp(card models.Card) time.Time {
+	if card.UpdatedAt.IsZero() {
+		return card.CreatedAt
+	}
+	return card.UpdatedAt
+}
+
+// collectChanges returns the cards changed after since, with Cursor set to the
+// newest change stamp among them. When nothing changed, Cursor is since.
+func collectChanges(db *database.Database, since time.Time) (Changes, error) {
+	cards, err := db.GetCardsChangedSince(since)
+	if err != nil {
+		return Changes{}, err
+	}
+
+	cursor := since
+	for _, card := range cards {
+		if stamp := changeStamp(card); stamp.After(cursor) {
+			cursor = stamp
+		}
+	}
+
+	return Changes{Cursor: cursor, Cards: cards}, nil
+}
+
+// Run synchronises db with the swucol instance at peerURL. It pulls the
+// peer's changes since the last run and applies them locally, publishing the
+// owned count changes like any other sync (see
+// cardservice.CollectionService.ApplyPeerCards), then pushes local changes
+// since the last run to the peer. Cursors are stored per peer so each run only
+// exchanges what changed since the previous one. Cards received from the peer
+// may be pushed straight back; last-write-wins makes that a no-op on the peer.
+//
+// When token is not empty, both requests carry it as a bearer token, so that
+// a peer with API tokens or roles turned on accepts them: the peer's token
+// needs the read and write scopes and the admin role, which POST /sync/push
+// requires.
+func Run(ctx context.Context, db *database.Database, client *http.Client, peerURL, token string) (Result, error) {
+	peerURL = strings.TrimRight(peerURL, "/")
+	if peerURL == "" {
+		return Result{}, errors.New("peer URL must not be empty")
+	}
+
+	cursors, err := db.GetSyncCursors(peerURL)
+	if err != nil {
+		return Result{}, fmt.Errorf("load sync cursors: %w", err)
+	}
+
+	remote, err := pull(ctx, client, peerURL, token, cursors.Remote)
+	if err != nil {
+		return Result{}, fmt.Errorf("pull from peer: %w", err)
+	}
+
+	// Pulling is asked for on this instance, which confirms it.
+	applied, err := cardservice.NewCollectionService(db).ApplyPeerCards(ctx, remote.Cards, true)
+	if err != nil {
+		return Result{}, fmt.Errorf("apply peer changes: %w", err)
+	}
+
+	local, err := collectChanges(db, cursors.Local)
+	if err != nil {
+		return Result{}, fmt.Errorf("collect local changes: %w", err)
+	}
+
+	if len(local.Cards) > 0 {
+		if err := push(ctx, client, peerURL, token, local); err != nil {
+			return Result{}, fmt.Errorf("push to peer: %w", err)
+		}
+	}
+
+	if remote.Cursor.After(cursors.Remote) {
+		cursors.Remote = remote.Cursor
+	}
+	cursors.Local = local.Cursor
+
+	if err := db.SetSyncCursors(peerURL, cursors); err != nil {
+		return Result{}, fmt.Errorf("save sync cursors: %w", err)
+	}
+
+	return Result{Pulled: len(remote.Cards), Applied: applied, Pushed: len(local.Cards)}, nil
+}
+
+// pull fetches the peer's changes since the given cursor.
+func pull(ctx context.Context, client *http.Client, peerURL, token string, since time.Time) (Changes, error) {
+	target := peerURL + "/sync/pull"
+	if !since.IsZero() {
+		target += "?since=" + url.QueryEscape(since.Format(time.RFC3339Nano))
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return Changes{}, fmt.Errorf("create request: %w", err)
+	}
+	authorize(request, token)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return Changes{}, fmt.Errorf("get changes: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return Changes{}, fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	var changes Changes
+	if err := json.NewDecoder(response.Body).Decode(&changes); err != nil {
+		return Changes{}, fmt.Errorf("decode changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// push sends local changes to the peer.
+func push(ctx context.Context, client *http.Client, peerURL, token string, changes Changes) error {
+	body, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("encode changes: %w", err)
+	}
+
+	// The changes were made, and guarded, on this instance already.
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, peerURL+"/sync/push?confirm=true", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	authorize(request, token)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("post changes: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// authorize sets request's Authorization header to token as a bearer token,
+// unless token is empty.
+func authorize(request *http.Request, token string) {
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+}
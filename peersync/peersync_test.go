@@ -0,0 +1,240 @@
+package peersync_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/apitokens"
+	"swucol/database"
+	"swucol/eventbus"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/peersync"
+	"swucol/roles"
+)
+
+// newPeerServer starts an httptest server exposing the pull and push
+// endpoints for db, as a second swucol instance would.
+func newPeerServer(t *testing.T, db *database.Database) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sync/pull", peersync.PullHandler(db))
+	mux.HandleFunc("POST /sync/push", peersync.PushHandler(db))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// insertCard inserts a card with explicit owned count and timestamps.
+func insertCard(t *testing.T, db *database.Database, name string, owned int, stamp string) {
+	t.Helper()
+
+	_, err := db.Connection().Exec(
+		"INSERT INTO cards (name, owned, created_at, updated_at) VALUES (?, ?, ?, ?)",
+		name, owned, stamp, stamp,
+	)
+	require.NoError(t, err)
+}
+
+func TestRun_BothInstancesConverge(t *testing.T) {
+	desktop := testdb.New(t)
+	laptop := testdb.New(t)
+	server := newPeerServer(t, laptop)
+
+	insertCard(t, desktop, "Luke Skywalker, Jedi Knight", 2, "2026-03-01T00:00:00.000000000Z")
+	insertCard(t, desktop, "Chewbacca, Hero of Kessel", 1, "2026-01-01T00:00:00.000000000Z")
+	insertCard(t, laptop, "Luke Skywalker, Jedi Knight", 5, "2026-02-01T00:00:00.000000000Z")
+	insertCard(t, laptop, "Chewbacca, Hero of Kessel", 3, "2026-04-01T00:00:00.000000000Z")
+	insertCard(t, laptop, "Han Solo, Scoundrel", 1, "2026-01-05T00:00:00.000000000Z")
+
+	result, err := peersync.Run(context.Background(), desktop, server.Client(), server.URL, "")
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Pulled)
+	assert.Equal(t, 2, result.Applied, "expected Chewbacca update and Han Solo insert")
+
+	want := map[string]int{
+		"Luke Skywalker, Jedi Knight": 2,
+		"Chewbacca, Hero of Kessel":   3,
+		"Han Solo, Scoundrel":         1,
+	}
+
+	desktopCounts, err := desktop.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, want, desktopCounts)
+
+	laptopCounts, err := laptop.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, want, laptopCounts)
+}
+
+func TestRun_PublishesOwnedChangesOnBothInstances(t *testing.T) {
+	desktop := testdb.New(t)
+	laptop := testdb.New(t)
+	server := newPeerServer(t, laptop)
+	var published []eventbus.Event
+	t.Cleanup(eventbus.Subscribe(eventbus.OwnedChanged, func(ctx context.Context, event eventbus.Event) {
+		published = append(published, event)
+	}))
+
+	insertCard(t, desktop, "Luke Skywalker, Jedi Knight", 2, "2026-03-01T00:00:00.000000000Z")
+	insertCard(t, laptop, "Luke Skywalker, Jedi Knight", 5, "2026-02-01T00:00:00.000000000Z")
+	insertCard(t, laptop, "Han Solo, Scoundrel", 1, "2026-01-05T00:00:00.000000000Z")
+
+	_, err := peersync.Run(context.Background(), desktop, server.Client(), server.URL, "")
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []eventbus.Event{
+		{Type: eventbus.OwnedChanged, CardName: "Han Solo, Scoundrel", Cause: models.AuditSync, Previous: 0, Owned: 1},
+		{Type: eventbus.OwnedChanged, CardName: "Luke Skywalker, Jedi Knight", Cause: models.AuditSync, Previous: 5, Owned: 2},
+	}, published)
+}
+
+func TestRun_PeerRequiringAToken(t *testing.T) {
+	desktop := testdb.New(t)
+	laptop := testdb.New(t)
+	insertCard(t, desktop, "Luke Skywalker, Jedi Knight", 2, "2026-03-01T00:00:00.000000000Z")
+
+	// The laptop lets anonymous requests only view, as a shared instance
+	// would.
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sync/pull", peersync.PullHandler(laptop))
+	mux.HandleFunc("POST /sync/push", peersync.PushHandler(laptop))
+	server := httptest.NewServer(apitokens.Middleware(laptop, roles.Middleware(laptop, models.RoleViewer, mux)))
+	t.Cleanup(server.Close)
+
+	_, err := peersync.Run(context.Background(), desktop, server.Client(), server.URL, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+
+	_, secret, err := laptop.CreateAPIToken("desktop sync", []string{models.ScopeRead, models.ScopeWrite}, models.RoleAdmin)
+	require.NoError(t, err)
+
+	result, err := peersync.Run(context.Background(), desktop, server.Client(), server.URL, secret)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Pushed)
+
+	counts, err := laptop.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"Luke Skywalker, Jedi Knight": 2}, counts)
+}
+
+func TestRun_SecondRunOnlyExchangesNewChanges(t *testing.T) {
+	desktop := testdb.New(t)
+	laptop := testdb.New(t)
+	server := newPeerServer(t, laptop)
+
+	insertCard(t, desktop, "Luke Skywalker, Jedi Knight", 2, "2026-03-01T00:00:00.000000000Z")
+	insertCard(t, laptop, "Chewbacca, Hero of Kessel", 3, "2026-04-01T00:00:00.000000000Z")
+
+	_, err := peersync.Run(context.Background(), desktop, server.Client(), server.URL, "")
+	require.NoError(t, err)
+
+	result, err := peersync.Run(context.Background(), desktop, server.Client(), server.URL, "")
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Pulled)
+	assert.Equal(t, 0, result.Applied)
+}
+
+func TestRun_PeerUnavailable_ReturnsError(t *testing.T) {
+	desktop := testdb.New(t)
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := peersync.Run(context.Background(), desktop, server.Client(), server.URL, "")
+
+	assert.Error(t, err)
+}
+
+func TestPullHandler_InvalidSince_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	request := httptest.NewRequest(http.MethodGet, "/sync/pull?since=yesterday", nil)
+	recorder := httptest.NewRecorder()
+
+	peersync.PullHandler(db)(recorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Result().StatusCode)
+}
+
+func TestPullHandler_ReturnsChangesWithCursor(t *testing.T) {
+	db := testdb.New(t)
+	insertCard(t, db, "Luke Skywalker, Jedi Knight", 2, "2026-03-01T00:00:00.000000000Z")
+
+	request := httptest.NewRequest(http.MethodGet, "/sync/pull", nil)
+	recorder := httptest.NewRecorder()
+
+	peersync.PullHandler(db)(recorder, request)
+
+	response := recorder.Result()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	var changes peersync.Changes
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&changes))
+	require.Len(t, changes.Cards, 1)
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), changes.Cursor)
+}
+
+func TestPushHandler_InvalidCard_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	body, err := json.Marshal(peersync.Changes{Cards: []models.Card{{Name: "Han Solo, Scoundrel", Owned: -1}}})
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodPost, "/sync/push", strings.NewReader(string(body)))
+	recorder := httptest.NewRecorder()
+
+	peersync.PushHandler(db)(recorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Result().StatusCode)
+}
+
+func TestPushHandler_GuardedChange_NeedsConfirming(t *testing.T) {
+	db := testdb.New(t)
+	settings := db.Settings()
+	settings.DecrementFloor = 2
+	require.NoError(t, db.SaveSettings(settings))
+	insertCard(t, db, "Han Solo, Scoundrel", 3, "2026-01-01T00:00:00.000000000Z")
+
+	body, err := json.Marshal(peersync.Changes{Cards: []models.Card{{Name: "Han Solo, Scoundrel", Owned: 0, UpdatedAt: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)}}})
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	peersync.PushHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/sync/push", strings.NewReader(string(body))))
+
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	counts, err := db.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, 3, counts["Han Solo, Scoundrel"])
+
+	recorder = httptest.NewRecorder()
+	peersync.PushHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/sync/push?confirm=true", strings.NewReader(string(body))))
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	counts, err = db.GetOwnedCountsByName()
+	require.NoError(t, err)
+	assert.Equal(t, 0, counts["Han Solo, Scoundrel"])
+}
+
+func TestRunHandler_MissingPeer_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	request := httptest.NewRequest(http.MethodPost, "/sync/run", nil)
+	recorder := httptest.NewRecorder()
+
+	peersync.RunHandler(db, http.DefaultClient, "")(recorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Result().StatusCode)
+}
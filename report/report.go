@@ -0,0 +1,130 @@
+// Package report writes card lists for the command line: as an aligned text
+// table for reading, or as JSON or CSV for piping into other scripts.
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"swucol/models"
+)
+
+// Format is the output format of a report.
+type Format string
+
+const (
+	// FormatTable writes an aligned text table with an upper-case header.
+	FormatTable Format = "table"
+	// FormatJSON writes the cards as a JSON array, in the shape the API
+	// returns them.
+	FormatJSON Format = "json"
+	// FormatCSV writes the table's columns as CSV with a header row.
+	FormatCSV Format = "csv"
+)
+
+// ErrConflictingFormats is returned by ParseFlags when both -json and -csv
+// are given.
+var ErrConflictingFormats = errors.New("-json and -csv cannot be used together")
+
+// ParseFlags returns the format selected by the -json and -csv flags of a
+// report command: FormatTable when neither is set.
+func ParseFlags(asJSON, asCSV bool) (Format, error) {
+	switch {
+	case asJSON && asCSV:
+		return "", ErrConflictingFormats
+	case asJSON:
+		return FormatJSON, nil
+	case asCSV:
+		return FormatCSV, nil
+	}
+	return FormatTable, nil
+}
+
+// Table is a report's columns: a header naming each one and a row of cells
+// per card.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// Write writes a report to writer in format: table as text or CSV, or value,
+// the cards the table was made from, as JSON.
+func Write(writer io.Writer, format Format, table Table, value any) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(value); err != nil {
+			return fmt.Errorf("write report: %w", err)
+		}
+		return nil
+	case FormatCSV:
+		csvWriter := csv.NewWriter(writer)
+		csvWriter.Write(table.Header)
+		csvWriter.WriteAll(table.Rows)
+		if err := csvWriter.Error(); err != nil {
+			return fmt.Errorf("write report: %w", err)
+		}
+		return nil
+	}
+
+	text := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	header := make([]string, len(table.Header))
+	for i, column := range table.Header {
+		header[i] = strings.ToUpper(column)
+	}
+	fmt.Fprintln(text, strings.Join(header, "\t"))
+	for _, row := range table.Rows {
+		fmt.Fprintln(text, strings.Join(row, "\t"))
+	}
+
+	if err := text.Flush(); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+
+	return nil
+}
+
+// cardColumns are the leading columns of every card table.
+var cardColumns = []string{"name", "set", "number", "type", "owned"}
+
+// cardCells returns card's cells for cardColumns.
+func cardCells(card models.Card) []string {
+	return []string{card.Name, card.Set, card.Number, card.Type, strconv.Itoa(card.Owned)}
+}
+
+// CardTable returns the table of a card list: each card with its aspects.
+func CardTable(cards []models.Card) Table {
+	table := Table{Header: slices.Concat(cardColumns, []string{"aspects"})}
+	for _, card := range cards {
+		table.Rows = append(table.Rows, append(cardCells(card), card.Aspects))
+	}
+	return table
+}
+
+// WishlistTable returns the table of a wishlist report: each card with the
+// copies it still needs.
+func WishlistTable(cards []models.WishlistCard) Table {
+	table := Table{Header: slices.Concat(cardColumns, []string{"need"})}
+	for _, card := range cards {
+		table.Rows = append(table.Rows, append(cardCells(card.Card), strconv.Itoa(card.Deficit)))
+	}
+	return table
+}
+
+// ExcessTable returns the table of an excess report: each card with the
+// copies it has to spare.
+func ExcessTable(cards []models.ExcessCard) Table {
+	table := Table{Header: slices.Concat(cardColumns, []string{"surplus"})}
+	for _, card := range cards {
+		table.Rows = append(table.Rows, append(cardCells(card.Card), strconv.Itoa(card.Surplus)))
+	}
+	return table
+}
@@ -0,0 +1,69 @@
+package report_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/models"
+	"swucol/report"
+)
+
+// wishlist is the wishlist the tests write.
+var wishlist = []models.WishlistCard{
+	{Card: models.Card{Name: "Han Solo, Reluctant Hero", Set: "SOR", Number: "198", Type: "Unit", Owned: 2}, Deficit: 4},
+	{Card: models.Card{Name: "Darth Vader, Commanding the First Legion", Set: "SOR", Number: "010", Type: "Leader"}, Deficit: 1},
+}
+
+func TestWrite_Table(t *testing.T) {
+	var output bytes.Buffer
+	require.NoError(t, report.Write(&output, report.FormatTable, report.WishlistTable(wishlist), wishlist))
+
+	assert.Equal(t, ""+
+		"NAME                                      SET  NUMBER  TYPE    OWNED  NEED\n"+
+		"Han Solo, Reluctant Hero                  SOR  198     Unit    2      4\n"+
+		"Darth Vader, Commanding the First Legion  SOR  010     Leader  0      1\n",
+		output.String())
+}
+
+func TestWrite_CSVAndJSON(t *testing.T) {
+	var output bytes.Buffer
+	require.NoError(t, report.Write(&output, report.FormatCSV, report.WishlistTable(wishlist), wishlist))
+	assert.Equal(t, ""+
+		"name,set,number,type,owned,need\n"+
+		"\"Han Solo, Reluctant Hero\",SOR,198,Unit,2,4\n"+
+		"\"Darth Vader, Commanding the First Legion\",SOR,010,Leader,0,1\n",
+		output.String())
+
+	output.Reset()
+	excess := []models.ExcessCard{{Card: models.Card{Name: "Battlefield Marine", Owned: 9}, Surplus: 3}}
+	require.NoError(t, report.Write(&output, report.FormatJSON, report.ExcessTable(excess), excess))
+	var decoded []map[string]any
+	require.NoError(t, json.Unmarshal(output.Bytes(), &decoded))
+	require.Len(t, decoded, 1)
+	assert.Equal(t, "Battlefield Marine", decoded[0]["name"])
+	assert.Equal(t, float64(3), decoded[0]["surplus"])
+}
+
+func TestCardTable(t *testing.T) {
+	table := report.CardTable([]models.Card{{Name: "Luke Skywalker, Faithful Friend", Set: "SOR", Number: "005", Type: "Leader", Owned: 1, Aspects: "Vigilance|Heroism"}})
+
+	assert.Equal(t, []string{"name", "set", "number", "type", "owned", "aspects"}, table.Header)
+	assert.Equal(t, [][]string{{"Luke Skywalker, Faithful Friend", "SOR", "005", "Leader", "1", "Vigilance|Heroism"}}, table.Rows)
+}
+
+func TestParseFlags(t *testing.T) {
+	format, err := report.ParseFlags(false, false)
+	require.NoError(t, err)
+	assert.Equal(t, report.FormatTable, format)
+
+	format, err = report.ParseFlags(false, true)
+	require.NoError(t, err)
+	assert.Equal(t, report.FormatCSV, format)
+
+	_, err = report.ParseFlags(true, true)
+	assert.ErrorIs(t, err, report.ErrConflictingFormats)
+}
@@ -0,0 +1,79 @@
+// Package requestid tags every HTTP request with an ID that is returned in
+// the X-Request-ID response header and added to each log line written with
+// the request's context, so that the lines of one request can be found
+// together.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+	"regexp"
+)
+
+// Header is the request and response header carrying the request ID.
+const Header = "X-Request-ID"
+
+// validID matches incoming request IDs that are reused rather than replaced,
+// so that an ID set by a reverse proxy can be followed across both logs.
+var validID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// contextKey is the context key the request ID is stored under.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, or "" if there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Middleware assigns each request an ID, taken from its X-Request-ID header
+// when that is a plausible ID and generated otherwise, stores it in the
+// request context, and sets it as the X-Request-ID response header.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		id := request.Header.Get(Header)
+		if !validID.MatchString(id) {
+			id = rand.Text()
+		}
+
+		responseWriter.Header().Set(Header, id)
+		next.ServeHTTP(responseWriter, request.WithContext(NewContext(request.Context(), id)))
+	})
+}
+
+// LogHandler is a slog.Handler that adds a "request_id" attribute to records
+// logged with a context carrying a request ID, then passes them to the
+// wrapped handler.
+type LogHandler struct {
+	slog.Handler
+}
+
+// NewLogHandler returns a LogHandler wrapping handler.
+func NewLogHandler(handler slog.Handler) *LogHandler {
+	return &LogHandler{Handler: handler}
+}
+
+// Handle adds the request ID from ctx, if any, to record.
+func (handler *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := FromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return handler.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a LogHandler wrapping the wrapped handler's WithAttrs.
+func (handler *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return NewLogHandler(handler.Handler.WithAttrs(attrs))
+}
+
+// WithGroup returns a LogHandler wrapping the wrapped handler's WithGroup.
+func (handler *LogHandler) WithGroup(name string) slog.Handler {
+	return NewLogHandler(handler.Handler.WithGroup(name))
+}
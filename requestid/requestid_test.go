@@ -0,0 +1,78 @@
+package requestid_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/requestid"
+)
+
+// serveWithLogs runs request through Middleware with a handler that logs one
+// line using the request context, and returns the response and the log output.
+func serveWithLogs(t *testing.T, request *http.Request) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+
+	var logs bytes.Buffer
+	logger := slog.New(requestid.NewLogHandler(slog.NewTextHandler(&logs, nil)))
+
+	handler := requestid.Middleware(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		logger.With("component", "test").InfoContext(request.Context(), "handled")
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	return recorder, logs.String()
+}
+
+func TestMiddleware_GeneratesIDAndAddsItToLogs(t *testing.T) {
+	recorder, logs := serveWithLogs(t, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	id := recorder.Header().Get(requestid.Header)
+	require.NotEmpty(t, id)
+	assert.Contains(t, logs, "request_id="+id)
+	assert.Contains(t, logs, "component=test")
+}
+
+func TestMiddleware_GeneratesDistinctIDs(t *testing.T) {
+	first, _ := serveWithLogs(t, httptest.NewRequest(http.MethodGet, "/", nil))
+	second, _ := serveWithLogs(t, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.NotEqual(t, first.Header().Get(requestid.Header), second.Header().Get(requestid.Header))
+}
+
+func TestMiddleware_ReusesValidIncomingID(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set(requestid.Header, "proxy-1234")
+
+	recorder, logs := serveWithLogs(t, request)
+
+	assert.Equal(t, "proxy-1234", recorder.Header().Get(requestid.Header))
+	assert.Contains(t, logs, "request_id=proxy-1234")
+}
+
+func TestMiddleware_ReplacesInvalidIncomingID(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set(requestid.Header, "bad id\nwith newline")
+
+	recorder, _ := serveWithLogs(t, request)
+
+	id := recorder.Header().Get(requestid.Header)
+	assert.NotEmpty(t, id)
+	assert.NotContains(t, id, " ")
+}
+
+func TestLogHandler_NoRequestID_LeavesRecordUnchanged(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(requestid.NewLogHandler(slog.NewTextHandler(&logs, nil)))
+
+	logger.Info("background work")
+
+	assert.NotContains(t, logs.String(), "request_id")
+}
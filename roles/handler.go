@@ -0,0 +1,110 @@
+package roles
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"swucol/csrf"
+	"swucol/database"
+	"swucol/templates"
+)
+
+// loginPage is the view model rendered by the login template. TokenName is
+// the name of the token the browser is signed in with, or "" when it is not
+// signed in.
+type loginPage struct {
+	Role      string
+	TokenName string
+	Error     string
+	Theme     string
+	CSRFToken string
+}
+
+// LoginPageHandler returns an http.HandlerFunc that serves the sign-in page at
+// GET /login, showing the browser's current role. Returns 500 Internal Server
+// Error for database errors or if template rendering fails.
+func LoginPageHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		page := loginPage{Role: FromContext(request.Context())}
+
+		token, err := cookieToken(db, request)
+		if err != nil && !errors.Is(err, database.ErrAPITokenNotFound) {
+			slog.ErrorContext(request.Context(), "database error authenticating sign-in cookie", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+		if err == nil {
+			page.TokenName = token.Name
+		}
+
+		renderLogin(responseWriter, request, db, tmpl, http.StatusOK, page)
+	}
+}
+
+// LoginHandler returns an http.HandlerFunc that handles POST /login with the
+// form value "token", the secret of an API token. It stores the secret in the
+// sign-in cookie, so that the browser gets the token's role, and redirects to
+// the collection page under basePath. Returns 401 Unauthorized with the
+// sign-in page for an unknown or revoked token, and 500 Internal Server Error
+// for database errors.
+func LoginHandler(db *database.Database, tmpl templates.Renderer, basePath string) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		secret := strings.TrimSpace(request.FormValue("token"))
+
+		token, err := db.AuthenticateAPIToken(secret)
+		if errors.Is(err, database.ErrAPITokenNotFound) {
+			slog.WarnContext(request.Context(), "sign-in with unknown or revoked API token")
+			page := loginPage{Role: FromContext(request.Context()), Error: "Unknown or revoked token."}
+			renderLogin(responseWriter, request, db, tmpl, http.StatusUnauthorized, page)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error signing in", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "browser signed in", "token_id", token.ID, "role", token.Role)
+
+		http.SetCookie(responseWriter, &http.Cookie{
+			Name:     CookieName,
+			Value:    secret,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   request.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+		http.Redirect(responseWriter, request, basePath+"/", http.StatusSeeOther)
+	}
+}
+
+// LogoutHandler returns an http.HandlerFunc that handles POST /logout. It
+// clears the sign-in cookie, so that the browser gets the default role again,
+// and redirects to the sign-in page under basePath.
+func LogoutHandler(basePath string) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		http.SetCookie(responseWriter, &http.Cookie{
+			Name:     CookieName,
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   request.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+		http.Redirect(responseWriter, request, basePath+"/login", http.StatusSeeOther)
+	}
+}
+
+// renderLogin responds with statusCode and the sign-in page.
+func renderLogin(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer, statusCode int, page loginPage) {
+	page.Theme = db.Settings().Theme
+	page.CSRFToken = csrf.Token(request.Context())
+
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	responseWriter.WriteHeader(statusCode)
+	if err := tmpl.ExecuteTemplate(responseWriter, "login", page); err != nil {
+		slog.ErrorContext(request.Context(), "failed to render login template", "error", err)
+	}
+}
@@ -0,0 +1,85 @@
+package roles_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/roles"
+	"swucol/templates"
+)
+
+func TestLoginHandler_ValidToken_SetsCookieAndRedirects(t *testing.T) {
+	db := testdb.New(t)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+	_, secret, err := db.CreateAPIToken("phone", []string{models.ScopeRead}, models.RoleViewer)
+	require.NoError(t, err)
+
+	form := url.Values{"token": {secret}}
+	request := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	roles.LoginHandler(db, tmpl, "/swucol")(recorder, request)
+
+	require.Equal(t, http.StatusSeeOther, recorder.Code, recorder.Body.String())
+	assert.Equal(t, "/swucol/", recorder.Header().Get("Location"))
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, roles.CookieName, cookies[0].Name)
+	assert.Equal(t, secret, cookies[0].Value)
+	assert.True(t, cookies[0].HttpOnly)
+}
+
+func TestLoginHandler_UnknownToken_Returns401(t *testing.T) {
+	db := testdb.New(t)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	form := url.Values{"token": {"swucol_UNKNOWN"}}
+	request := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	roles.LoginHandler(db, tmpl, "")(recorder, request)
+
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Unknown or revoked token.")
+	assert.Empty(t, recorder.Result().Cookies())
+}
+
+func TestLoginPageHandler_ShowsSignedInRole(t *testing.T) {
+	db := testdb.New(t)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+	_, secret, err := db.CreateAPIToken("phone", []string{models.ScopeRead}, models.RoleViewer)
+	require.NoError(t, err)
+
+	handler := roles.Middleware(db, models.RoleAdmin, roles.LoginPageHandler(db, tmpl))
+	request := httptest.NewRequest(http.MethodGet, "/login", nil)
+	request.AddCookie(&http.Cookie{Name: roles.CookieName, Value: secret})
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Contains(t, recorder.Body.String(), "<strong>viewer</strong>, signed in with the token phone")
+	assert.Contains(t, recorder.Body.String(), "Sign out")
+}
+
+func TestLogoutHandler_ClearsCookie(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	roles.LogoutHandler("")(recorder, httptest.NewRequest(http.MethodPost, "/logout", nil))
+
+	assert.Equal(t, http.StatusSeeOther, recorder.Code)
+	assert.Equal(t, "/login", recorder.Header().Get("Location"))
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, roles.CookieName, cookies[0].Name)
+	assert.Less(t, cookies[0].MaxAge, 0)
+}
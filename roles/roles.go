@@ -0,0 +1,144 @@
+// Package roles limits what each person using the app may do. A viewer can
+// browse, an editor can also change owned counts and the rest of the
+// collection, and an admin can also import, delete and run maintenance.
+//
+// A request's role is that of the API token it carries, either as a bearer
+// token (see package apitokens) or, for browsers, in the cookie the sign-in
+// page sets. Requests with neither get the server's default role, which is
+// admin unless the server is configured otherwise, so that a private install
+// keeps working without signing in.
+package roles
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"swucol/apitokens"
+	"swucol/database"
+	"swucol/models"
+)
+
+// CookieName is the cookie a signed-in browser's token secret is stored in.
+const CookieName = "swucol_token"
+
+// contextKey is the context key type for the request's identity.
+type contextKey struct{}
+
+// identity is who Middleware found a request was made by: the role, and the
+// name of the token it authenticated with, which is "" without one.
+type identity struct {
+	role string
+	user string
+}
+
+// FromContext returns the role Middleware gave the request, or admin when
+// Middleware is not in use.
+func FromContext(ctx context.Context) string {
+	who, ok := ctx.Value(contextKey{}).(identity)
+	if !ok {
+		return models.RoleAdmin
+	}
+	return who.role
+}
+
+// User returns the name of the API token the request was made with, as a
+// bearer token or through the sign-in cookie, or "" when it carried none.
+func User(ctx context.Context) string {
+	who, _ := ctx.Value(contextKey{}).(identity)
+	return who.user
+}
+
+// Valid reports whether role is one of models.Roles.
+func Valid(role string) bool {
+	return slices.Contains(models.Roles, role)
+}
+
+// Allows reports whether role may make a request that needs required.
+func Allows(role, required string) bool {
+	return slices.Index(models.Roles, role) >= slices.Index(models.Roles, required)
+}
+
+// Required returns the least privileged role allowed to make request.
+// Browsing, signing in and out, comparing a CSV with the collection and the
+// session's own shopping cart need viewer. Imports, deletes, settings, sync,
+// starting jobs and everything under /admin need admin. Every other change
+// needs editor.
+func Required(request *http.Request) string {
+	path := request.URL.Path
+
+	switch {
+	case strings.HasPrefix(path, "/admin"):
+		return models.RoleAdmin
+	case request.Method == http.MethodGet || request.Method == http.MethodHead:
+		return models.RoleViewer
+	case path == "/login" || path == "/logout" || path == "/cards/diff" || path == "/cards/diff/html" || path == "/cart" || strings.HasPrefix(path, "/cart/"):
+		return models.RoleViewer
+	case request.Method == http.MethodDelete:
+		return models.RoleAdmin
+	case apitokens.IsImportPath(path) || path == "/cards/translations" || strings.HasPrefix(path, "/sets/"):
+		return models.RoleAdmin
+	case path == "/settings" || path == "/settings/html" || strings.HasPrefix(path, "/sync/") || strings.HasPrefix(path, "/jobs/"):
+		return models.RoleAdmin
+	default:
+		return models.RoleEditor
+	}
+}
+
+// Middleware works out each request's role, makes it available through
+// FromContext, and returns 403 Forbidden when the role does not allow the
+// request. It must run inside apitokens.Middleware so that bearer tokens are
+// already authenticated. A sign-in cookie whose token has been revoked is
+// ignored, and the request gets defaultRole.
+func Middleware(db *database.Database, defaultRole string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		who, err := requestIdentity(db, defaultRole, request)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error authenticating sign-in cookie", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		required := Required(request)
+		if !Allows(who.role, required) {
+			slog.WarnContext(request.Context(), "request denied by role", "role", who.role, "required", required, "method", request.Method, "path", request.URL.Path)
+			http.Error(responseWriter, "the "+who.role+" role cannot do this; it needs "+required, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(responseWriter, request.WithContext(context.WithValue(request.Context(), contextKey{}, who)))
+	})
+}
+
+// requestIdentity returns the request's bearer token, or else its sign-in
+// cookie's token, as an identity, or else an anonymous one with defaultRole.
+func requestIdentity(db *database.Database, defaultRole string, request *http.Request) (identity, error) {
+	if token, ok := apitokens.FromContext(request.Context()); ok {
+		return identity{role: token.Role, user: token.Name}, nil
+	}
+
+	token, err := cookieToken(db, request)
+	if errors.Is(err, database.ErrAPITokenNotFound) {
+		return identity{role: defaultRole}, nil
+	}
+	if err != nil {
+		return identity{}, err
+	}
+
+	return identity{role: token.Role, user: token.Name}, nil
+}
+
+// cookieToken returns the token the request's sign-in cookie holds. Returns
+// database.ErrAPITokenNotFound when there is no cookie or its token is
+// unknown or revoked.
+func cookieToken(db *database.Database, request *http.Request) (models.APIToken, error) {
+	cookie, err := request.Cookie(CookieName)
+	if err != nil || cookie.Value == "" {
+		return models.APIToken{}, database.ErrAPITokenNotFound
+	}
+
+	return db.AuthenticateAPIToken(cookie.Value)
+}
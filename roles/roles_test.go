@@ -0,0 +1,138 @@
+package roles_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/apitokens"
+	"swucol/database"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/roles"
+)
+
+// serve sends request through apitokens.Middleware and Middleware with
+// defaultRole and returns the response. The wrapped handler responds 200 OK
+// with the request's role, followed by its user when it has one.
+func serve(t *testing.T, db *database.Database, defaultRole string, request *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+
+	handler := apitokens.Middleware(db, roles.Middleware(db, defaultRole, http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.Write([]byte(roles.FromContext(request.Context())))
+		if user := roles.User(request.Context()); user != "" {
+			responseWriter.Write([]byte(" " + user))
+		}
+	})))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func TestRequired(t *testing.T) {
+	tests := []struct {
+		method   string
+		target   string
+		expected string
+	}{
+		{method: http.MethodGet, target: "/cards/search", expected: models.RoleViewer},
+		{method: http.MethodPost, target: "/login", expected: models.RoleViewer},
+		{method: http.MethodPost, target: "/cards/diff/html", expected: models.RoleViewer},
+		{method: http.MethodPost, target: "/cart/items/html", expected: models.RoleViewer},
+		{method: http.MethodDelete, target: "/cart/items/3", expected: models.RoleViewer},
+		{method: http.MethodPost, target: "/cards/1/increment/html", expected: models.RoleEditor},
+		{method: http.MethodPost, target: "/cards/1/archive", expected: models.RoleEditor},
+		{method: http.MethodPut, target: "/cubes/1/cards/2", expected: models.RoleEditor},
+		{method: http.MethodPost, target: "/cards/import/html", expected: models.RoleAdmin},
+		{method: http.MethodPost, target: "/imports/4/rerun", expected: models.RoleAdmin},
+		{method: http.MethodDelete, target: "/goals/1/html", expected: models.RoleAdmin},
+		{method: http.MethodPut, target: "/settings", expected: models.RoleAdmin},
+		{method: http.MethodPost, target: "/sync/run", expected: models.RoleAdmin},
+		{method: http.MethodGet, target: "/jobs", expected: models.RoleViewer},
+		{method: http.MethodPost, target: "/jobs/backup/run", expected: models.RoleAdmin},
+		{method: http.MethodGet, target: "/admin", expected: models.RoleAdmin},
+		{method: http.MethodPost, target: "/admin/db/vacuum", expected: models.RoleAdmin},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.target, func(t *testing.T) {
+			assert.Equal(t, test.expected, roles.Required(httptest.NewRequest(test.method, test.target, nil)))
+		})
+	}
+}
+
+func TestMiddleware_DefaultRole(t *testing.T) {
+	db := testdb.New(t)
+
+	tests := []struct {
+		name        string
+		defaultRole string
+		method      string
+		target      string
+		expected    int
+	}{
+		{name: "viewer browses", defaultRole: models.RoleViewer, method: http.MethodGet, target: "/", expected: http.StatusOK},
+		{name: "viewer cannot change counts", defaultRole: models.RoleViewer, method: http.MethodPost, target: "/cards/1/increment", expected: http.StatusForbidden},
+		{name: "editor changes counts", defaultRole: models.RoleEditor, method: http.MethodPost, target: "/cards/1/increment", expected: http.StatusOK},
+		{name: "editor cannot import", defaultRole: models.RoleEditor, method: http.MethodPost, target: "/cards/import", expected: http.StatusForbidden},
+		{name: "editor cannot open admin", defaultRole: models.RoleEditor, method: http.MethodGet, target: "/admin", expected: http.StatusForbidden},
+		{name: "admin runs maintenance", defaultRole: models.RoleAdmin, method: http.MethodPost, target: "/admin/db/vacuum", expected: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serve(t, db, test.defaultRole, httptest.NewRequest(test.method, test.target, nil))
+
+			assert.Equal(t, test.expected, recorder.Code, recorder.Body.String())
+		})
+	}
+}
+
+func TestMiddleware_UsesBearerTokenRole(t *testing.T) {
+	db := testdb.New(t)
+	_, secret, err := db.CreateAPIToken("script", []string{models.ScopeRead, models.ScopeWrite}, models.RoleEditor)
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/1/increment", nil)
+	request.Header.Set("Authorization", "Bearer "+secret)
+	recorder := serve(t, db, models.RoleViewer, request)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Equal(t, models.RoleEditor+" script", recorder.Body.String())
+
+	request = httptest.NewRequest(http.MethodDelete, "/goals/1", nil)
+	request.Header.Set("Authorization", "Bearer "+secret)
+	assert.Equal(t, http.StatusForbidden, serve(t, db, models.RoleAdmin, request).Code)
+}
+
+func TestMiddleware_UsesSignInCookieRoleUntilRevoked(t *testing.T) {
+	db := testdb.New(t)
+	token, secret, err := db.CreateAPIToken("phone", []string{models.ScopeRead}, models.RoleViewer)
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.AddCookie(&http.Cookie{Name: roles.CookieName, Value: secret})
+	assert.Equal(t, models.RoleViewer+" phone", serve(t, db, models.RoleAdmin, request).Body.String())
+
+	require.NoError(t, db.RevokeAPIToken(token.ID))
+
+	request = httptest.NewRequest(http.MethodGet, "/", nil)
+	request.AddCookie(&http.Cookie{Name: roles.CookieName, Value: secret})
+	assert.Equal(t, models.RoleAdmin, serve(t, db, models.RoleAdmin, request).Body.String())
+}
+
+func TestFromContext_WithoutMiddleware_ReturnsAdmin(t *testing.T) {
+	assert.Equal(t, models.RoleAdmin, roles.FromContext(context.Background()))
+}
+
+func TestAllows(t *testing.T) {
+	assert.True(t, roles.Allows(models.RoleAdmin, models.RoleEditor))
+	assert.True(t, roles.Allows(models.RoleEditor, models.RoleEditor))
+	assert.False(t, roles.Allows(models.RoleViewer, models.RoleEditor))
+	assert.False(t, roles.Allows(models.RoleEditor, models.RoleAdmin))
+}
@@ -0,0 +1,388 @@
+// Package search parses the compact query syntax accepted by the "q"
+// parameter of the card searches and translates it to SQL over the cards
+// table, so the web pages, the JSON API and any other client filter cards the
+// same way.
+//
+// A query is a list of terms separated by spaces, all of which must match:
+//
+//	set:LAW aspect:heroism owned>=3 -type:base "darth vader"
+//
+// A bare word or "quoted phrase" matches cards whose name, any alias, any
+// localized name or rules text contains it. A field term is field, operator
+// and value; values may be quoted to include spaces. A leading "-" negates a
+// term. The fields are:
+//
+//	name       name, alias or localized name contains the value (":"), or
+//	           equals it ("=")
+//	set        set code, such as LAW
+//	number     collector number within the set
+//	type       card type, such as unit or base
+//	aspect     the card has this aspect
+//	rarity     rarity, such as rare
+//	trait      the card has this trait, such as Rebel
+//	text       rules text contains the value
+//	owned      owned count, compared with :, =, >, >=, < or <=
+//	cost       cost, compared like owned
+//	power      power, compared like owned
+//	hp         HP, compared like owned
+//	mainboard  yes or no
+//	unreleased yes for cards of a set that is still a spoiler preview
+//	complete   yes for cards owned at least up to their minimum owned
+//	           threshold, a complete playset
+//
+// Cards without a cost, power or HP, or whose attributes have not been synced
+// from the catalog, match no comparison on it. Text comparisons ignore case.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"swucol/models"
+)
+
+// ErrInvalidQuery is wrapped by every error Parse returns.
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// Fields a term may name.
+const (
+	FieldName       = "name"
+	FieldSet        = "set"
+	FieldNumber     = "number"
+	FieldType       = "type"
+	FieldAspect     = "aspect"
+	FieldRarity     = "rarity"
+	FieldTrait      = "trait"
+	FieldText       = "text"
+	FieldOwned      = "owned"
+	FieldCost       = "cost"
+	FieldPower      = "power"
+	FieldHP         = "hp"
+	FieldMainboard  = "mainboard"
+	FieldUnreleased = "unreleased"
+	FieldComplete   = "complete"
+)
+
+// numericColumns maps the fields compared as whole numbers to their columns.
+var numericColumns = map[string]string{
+	FieldOwned: "owned",
+	FieldCost:  "cost",
+	FieldPower: "power",
+	FieldHP:    "hp",
+}
+
+// Operators a term may use. Only the numeric fields accept the ordering
+// operators.
+const (
+	OperatorMatch        = ":"
+	OperatorEqual        = "="
+	OperatorGreater      = ">"
+	OperatorGreaterEqual = ">="
+	OperatorLess         = "<"
+	OperatorLessEqual    = "<="
+)
+
+// operators lists the operators longest first, so that ">=" is found before
+// ">" when splitting a term.
+var operators = []string{OperatorGreaterEqual, OperatorLessEqual, OperatorMatch, OperatorEqual, OperatorGreater, OperatorLess}
+
+// fields lists every field in the order error messages name them.
+var fields = []string{FieldName, FieldSet, FieldNumber, FieldType, FieldAspect, FieldRarity, FieldTrait, FieldText, FieldOwned, FieldCost, FieldPower, FieldHP, FieldMainboard, FieldUnreleased, FieldComplete}
+
+// Term is one condition of a Query. A bare word has Field FieldName,
+// Operator OperatorMatch and Bare set, and also matches rules text.
+type Term struct {
+	Field    string
+	Operator string
+	Value    string
+	Negated  bool
+	Bare     bool
+}
+
+// Query is a parsed search. The zero value matches every card.
+type Query struct {
+	Terms []Term
+}
+
+// Parse parses input into a Query. Returns an error wrapping ErrInvalidQuery
+// for an unterminated quote, an unknown field, an operator the field does not
+// support, or a value the field cannot hold.
+func Parse(input string) (Query, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return Query{}, err
+	}
+
+	var query Query
+	for _, token := range tokens {
+		term, err := parseTerm(token)
+		if err != nil {
+			return Query{}, err
+		}
+		query.Terms = append(query.Terms, term)
+	}
+
+	return query, nil
+}
+
+// token is a whitespace-separated piece of the input. quotedFrom is the
+// index in text where a quoted part began, or -1 when nothing was quoted, so
+// that an operator inside quotes is not mistaken for a field separator.
+type token struct {
+	text       string
+	quotedFrom int
+}
+
+// tokenize splits input on whitespace outside double quotes, removing the
+// quotes.
+func tokenize(input string) ([]token, error) {
+	var (
+		tokens  []token
+		current strings.Builder
+		started bool
+		quoted  bool
+		from    = -1
+	)
+
+	for _, character := range input {
+		switch {
+		case character == '"':
+			if !quoted && from == -1 {
+				from = current.Len()
+			}
+			quoted = !quoted
+			started = true
+		case !quoted && (character == ' ' || character == '\t' || character == '\n'):
+			if started {
+				tokens = append(tokens, token{text: current.String(), quotedFrom: from})
+				current.Reset()
+				started, from = false, -1
+			}
+		default:
+			current.WriteRune(character)
+			started = true
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidQuery)
+	}
+	if started {
+		tokens = append(tokens, token{text: current.String(), quotedFrom: from})
+	}
+
+	return tokens, nil
+}
+
+// parseTerm turns one token into a Term.
+func parseTerm(token token) (Term, error) {
+	var term Term
+	text := token.text
+	if len(text) > 1 && text[0] == '-' && token.quotedFrom != 0 {
+		term.Negated = true
+		text = text[1:]
+		if token.quotedFrom > 0 {
+			token.quotedFrom--
+		}
+	}
+
+	field, operator, value, ok := splitTerm(text, token.quotedFrom)
+	if !ok {
+		term.Field, term.Operator, term.Value, term.Bare = FieldName, OperatorMatch, text, true
+		return term, nil
+	}
+
+	term.Field, term.Operator, term.Value = strings.ToLower(field), operator, value
+	if err := validateTerm(term); err != nil {
+		return Term{}, err
+	}
+
+	return term, nil
+}
+
+// splitTerm splits text at its first operator outside the quoted part. It
+// reports false when text has no operator there or nothing before it, in
+// which case the whole text is a bare word.
+func splitTerm(text string, quotedFrom int) (field, operator, value string, ok bool) {
+	index := strings.IndexAny(text, ":=<>")
+	if index <= 0 || (quotedFrom >= 0 && index >= quotedFrom) {
+		return "", "", "", false
+	}
+
+	for _, candidate := range operators {
+		if strings.HasPrefix(text[index:], candidate) {
+			return text[:index], candidate, text[index+len(candidate):], true
+		}
+	}
+
+	return "", "", "", false
+}
+
+// validateTerm checks that term's field exists, supports its operator, and
+// can hold its value.
+func validateTerm(term Term) error {
+	if !slices.Contains(fields, term.Field) {
+		return fmt.Errorf("%w: unknown field %q (fields are %s)", ErrInvalidQuery, term.Field, strings.Join(fields, ", "))
+	}
+
+	if term.Value == "" {
+		return fmt.Errorf("%w: %s needs a value", ErrInvalidQuery, term.Field)
+	}
+
+	if _, ok := numericColumns[term.Field]; ok {
+		if _, err := strconv.Atoi(term.Value); err != nil {
+			return fmt.Errorf("%w: %s must be compared with a whole number, not %q", ErrInvalidQuery, term.Field, term.Value)
+		}
+		return nil
+	}
+
+	if term.Operator != OperatorMatch && term.Operator != OperatorEqual {
+		return fmt.Errorf("%w: %s only supports : and =", ErrInvalidQuery, term.Field)
+	}
+
+	switch term.Field {
+	case FieldType:
+		return validateKnown(term, models.CardTypes)
+	case FieldAspect:
+		return validateKnown(term, models.Aspects)
+	case FieldRarity:
+		return validateKnown(term, models.Rarities)
+	case FieldMainboard, FieldUnreleased, FieldComplete:
+		if _, ok := parseYesNo(term.Value); !ok {
+			return fmt.Errorf("%w: %s must be yes or no, not %q", ErrInvalidQuery, term.Field, term.Value)
+		}
+	}
+
+	return nil
+}
+
+// validateKnown checks that term's value is one of known, ignoring case.
+func validateKnown(term Term, known []string) error {
+	for _, value := range known {
+		if strings.EqualFold(value, term.Value) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown %s %q", ErrInvalidQuery, term.Field, term.Value)
+}
+
+// parseYesNo reads a mainboard, unreleased or complete value.
+func parseYesNo(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "yes", "true", "1":
+		return true, true
+	case "no", "false", "0":
+		return false, true
+	}
+	return false, false
+}
+
+// nameClause matches cards whose name, any alias or any localized name
+// matches a LIKE pattern. All three placeholders must be bound to the same
+// pattern.
+const nameClause = "(name LIKE ? COLLATE NOCASE OR id IN (SELECT card_id FROM card_aliases WHERE alias LIKE ? COLLATE NOCASE) OR id IN (SELECT card_id FROM card_translations WHERE name LIKE ? COLLATE NOCASE))"
+
+// unreleasedClause matches cards of a set marked in unreleased_sets whose
+// release date, if any, has not passed yet. It mirrors the database package's
+// condition of the same name.
+const unreleasedClause = "(set_code <> '' AND set_code IN (SELECT set_code FROM unreleased_sets WHERE release_date = '' OR release_date > date('now')))"
+
+// bareClause matches cards whose name, any alias, any localized name or rules
+// text matches a LIKE pattern. All four placeholders must be bound to the
+// same pattern.
+const bareClause = "(name LIKE ? COLLATE NOCASE OR id IN (SELECT card_id FROM card_aliases WHERE alias LIKE ? COLLATE NOCASE) OR id IN (SELECT card_id FROM card_translations WHERE name LIKE ? COLLATE NOCASE) OR rules_text LIKE ? COLLATE NOCASE)"
+
+// Threshold is an expression over the cards table evaluating to a card's
+// minimum owned threshold, with the arguments for its placeholders. The
+// complete field compares the owned count with it.
+type Threshold struct {
+	Expression string
+	Args       []any
+}
+
+// SQL returns a condition on the cards table matching the query, joined with
+// AND, and the arguments for its placeholders. Returns an empty condition for
+// a query without terms.
+func (query Query) SQL(threshold Threshold) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	for _, term := range query.Terms {
+		condition, termArgs := term.sql(threshold)
+		if term.Negated {
+			condition = "NOT " + condition
+		}
+		conditions = append(conditions, condition)
+		args = append(args, termArgs...)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// sql returns the condition matching term and its arguments, comparing
+// complete terms with threshold. term must have been validated by Parse.
+func (term Term) sql(threshold Threshold) (string, []any) {
+	switch term.Field {
+	case FieldName:
+		pattern := "%" + term.Value + "%"
+		if term.Bare {
+			return bareClause, []any{pattern, pattern, pattern, pattern}
+		}
+		if term.Operator == OperatorEqual {
+			pattern = term.Value
+		}
+		return nameClause, []any{pattern, pattern, pattern}
+	case FieldText:
+		pattern := "%" + term.Value + "%"
+		if term.Operator == OperatorEqual {
+			pattern = term.Value
+		}
+		return "(rules_text LIKE ? COLLATE NOCASE)", []any{pattern}
+	case FieldSet:
+		return "(set_code = ? COLLATE NOCASE)", []any{term.Value}
+	case FieldNumber:
+		return "(card_number = ? COLLATE NOCASE)", []any{term.Value}
+	case FieldType:
+		return "(card_type = ? COLLATE NOCASE)", []any{term.Value}
+	case FieldAspect:
+		return "(id IN (SELECT card_id FROM card_aspects WHERE aspect = ? COLLATE NOCASE))", []any{term.Value}
+	case FieldRarity:
+		return "(rarity = ? COLLATE NOCASE)", []any{term.Value}
+	case FieldTrait:
+		return "(id IN (SELECT card_id FROM card_traits WHERE trait = ? COLLATE NOCASE))", []any{term.Value}
+	case FieldOwned, FieldCost, FieldPower, FieldHP:
+		operator := term.Operator
+		if operator == OperatorMatch {
+			operator = OperatorEqual
+		}
+		number, _ := strconv.Atoi(term.Value)
+		return "(" + numericColumns[term.Field] + " " + operator + " ?)", []any{number}
+	case FieldMainboard:
+		mainboard, _ := parseYesNo(term.Value)
+		value := 0
+		if mainboard {
+			value = 1
+		}
+		return "(mainboard = ?)", []any{value}
+	case FieldUnreleased:
+		unreleased, _ := parseYesNo(term.Value)
+		if !unreleased {
+			return "NOT " + unreleasedClause, nil
+		}
+		return unreleasedClause, nil
+	case FieldComplete:
+		complete, _ := parseYesNo(term.Value)
+		operator := " < "
+		if complete {
+			operator = " >= "
+		}
+		return "(owned" + operator + "(" + threshold.Expression + "))", threshold.Args
+	}
+
+	return "", nil
+}
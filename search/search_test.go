@@ -0,0 +1,107 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/search"
+)
+
+func TestParse_FieldsOperatorsAndNegation(t *testing.T) {
+	query, err := search.Parse(`set:LAW aspect:heroism owned>=3 -type:base "darth vader"`)
+
+	require.NoError(t, err)
+	assert.Equal(t, []search.Term{
+		{Field: search.FieldSet, Operator: search.OperatorMatch, Value: "LAW"},
+		{Field: search.FieldAspect, Operator: search.OperatorMatch, Value: "heroism"},
+		{Field: search.FieldOwned, Operator: search.OperatorGreaterEqual, Value: "3"},
+		{Field: search.FieldType, Operator: search.OperatorMatch, Value: "base", Negated: true},
+		{Field: search.FieldName, Operator: search.OperatorMatch, Value: "darth vader", Bare: true},
+	}, query.Terms)
+}
+
+func TestParse_QuotedValuesAndOperatorsInsideQuotes(t *testing.T) {
+	query, err := search.Parse(`name:"Luke Skywalker" "owned>3" -"r2-d2"`)
+
+	require.NoError(t, err)
+	assert.Equal(t, []search.Term{
+		{Field: search.FieldName, Operator: search.OperatorMatch, Value: "Luke Skywalker"},
+		{Field: search.FieldName, Operator: search.OperatorMatch, Value: "owned>3", Bare: true},
+		{Field: search.FieldName, Operator: search.OperatorMatch, Value: "r2-d2", Negated: true, Bare: true},
+	}, query.Terms)
+}
+
+func TestParse_EmptyInput_MatchesEverything(t *testing.T) {
+	query, err := search.Parse("   ")
+
+	require.NoError(t, err)
+	condition, args := query.SQL(search.Threshold{})
+	assert.Empty(t, condition)
+	assert.Empty(t, args)
+}
+
+func TestParse_InvalidQueries_ReturnErrInvalidQuery(t *testing.T) {
+	for _, input := range []string{
+		`"unterminated`,
+		"colour:red",
+		"set:",
+		"owned>many",
+		"set>LAW",
+		"aspect:purple",
+		"type:vehicle",
+		"mainboard:maybe",
+		"cost<=cheap",
+		"trait>Rebel",
+	} {
+		_, err := search.Parse(input)
+		assert.ErrorIs(t, err, search.ErrInvalidQuery, input)
+	}
+}
+
+func TestQuerySQL_JoinsConditionsWithArguments(t *testing.T) {
+	query, err := search.Parse("luke owned<2 -mainboard:no")
+	require.NoError(t, err)
+
+	condition, args := query.SQL(search.Threshold{})
+
+	assert.Contains(t, condition, "name LIKE ?")
+	assert.Contains(t, condition, "rules_text LIKE ?")
+	assert.Contains(t, condition, " AND (owned < ?) AND NOT (mainboard = ?)")
+	assert.Equal(t, []any{"%luke%", "%luke%", "%luke%", "%luke%", 2, 0}, args)
+}
+
+func TestQuerySQL_GameplayAttributes(t *testing.T) {
+	query, err := search.Parse("cost<=3 power>2 hp:5 trait:Rebel")
+	require.NoError(t, err)
+
+	condition, args := query.SQL(search.Threshold{})
+
+	assert.Equal(t, "(cost <= ?) AND (power > ?) AND (hp = ?) AND (id IN (SELECT card_id FROM card_traits WHERE trait = ? COLLATE NOCASE))", condition)
+	assert.Equal(t, []any{3, 2, 5, "Rebel"}, args)
+}
+
+func TestQuerySQL_CompleteComparesOwnedWithThreshold(t *testing.T) {
+	query, err := search.Parse("complete:true -complete:no")
+	require.NoError(t, err)
+
+	condition, args := query.SQL(search.Threshold{Expression: "CASE WHEN mainboard = 1 THEN ? ELSE ? END", Args: []any{6, 3}})
+
+	assert.Equal(t, "(owned >= (CASE WHEN mainboard = 1 THEN ? ELSE ? END)) AND NOT (owned < (CASE WHEN mainboard = 1 THEN ? ELSE ? END))", condition)
+	assert.Equal(t, []any{6, 3, 6, 3}, args)
+
+	_, err = search.Parse("complete:maybe")
+	assert.ErrorIs(t, err, search.ErrInvalidQuery)
+}
+
+func TestQuerySQL_NameFieldIgnoresRulesText(t *testing.T) {
+	query, err := search.Parse(`name:luke text:"when played"`)
+	require.NoError(t, err)
+
+	condition, args := query.SQL(search.Threshold{})
+
+	assert.NotContains(t, condition, "OR rules_text")
+	assert.Contains(t, condition, " AND (rules_text LIKE ? COLLATE NOCASE)")
+	assert.Equal(t, []any{"%luke%", "%luke%", "%luke%", "%when played%"}, args)
+}
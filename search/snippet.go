@@ -0,0 +1,107 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetContext is the number of bytes of rules text Snippet keeps on each
+// side of the first match, before moving to a rune boundary.
+const snippetContext = 60
+
+// snippetEllipsis marks text Snippet left out at either end.
+const snippetEllipsis = "…"
+
+// SnippetPart is a piece of a snippet. Match is set on the pieces that match
+// a search term, which the card grid highlights.
+type SnippetPart struct {
+	Text  string
+	Match bool
+}
+
+// TextTerms returns the values of the query's terms that search rules text:
+// bare words and text terms, leaving out negated ones, whose matches a
+// snippet cannot show.
+func (query Query) TextTerms() []string {
+	var terms []string
+	for _, term := range query.Terms {
+		if term.Negated || (!term.Bare && term.Field != FieldText) {
+			continue
+		}
+		terms = append(terms, term.Value)
+	}
+	return terms
+}
+
+// Snippet returns the part of text around the first case-insensitive match
+// of any of terms, split into parts with every match in it marked, and with
+// an ellipsis where text was cut. Returns nil when nothing in text matches.
+func Snippet(text string, terms []string) []SnippetPart {
+	matches := findMatches(text, terms)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	start := runeBoundary(text, max(matches[0][0]-snippetContext, 0))
+	end := runeBoundary(text, min(matches[0][1]+snippetContext, len(text)))
+
+	var parts []SnippetPart
+	if start > 0 {
+		parts = append(parts, SnippetPart{Text: snippetEllipsis})
+	}
+
+	position := start
+	for _, match := range matches {
+		if match[0] < position || match[1] > end {
+			continue
+		}
+		if match[0] > position {
+			parts = append(parts, SnippetPart{Text: text[position:match[0]]})
+		}
+		parts = append(parts, SnippetPart{Text: text[match[0]:match[1]], Match: true})
+		position = match[1]
+	}
+	if position < end {
+		parts = append(parts, SnippetPart{Text: text[position:end]})
+	}
+
+	if end < len(text) {
+		parts = append(parts, SnippetPart{Text: snippetEllipsis})
+	}
+
+	return parts
+}
+
+// findMatches returns the start and end byte offsets of the non-overlapping
+// case-insensitive matches of terms in text, in order. Where terms match at
+// the same offset the longest wins.
+func findMatches(text string, terms []string) [][2]int {
+	var matches [][2]int
+	for position := 0; position < len(text); {
+		length := 0
+		for _, term := range terms {
+			end := position + len(term)
+			if term != "" && len(term) > length && end <= len(text) && strings.EqualFold(text[position:end], term) {
+				length = len(term)
+			}
+		}
+
+		if length > 0 {
+			matches = append(matches, [2]int{position, position + length})
+			position += length
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(text[position:])
+		position += size
+	}
+	return matches
+}
+
+// runeBoundary moves offset back to the start of the rune it falls in.
+func runeBoundary(text string, offset int) int {
+	for offset > 0 && offset < len(text) && !utf8.RuneStart(text[offset]) {
+		offset--
+	}
+	return offset
+}
@@ -0,0 +1,47 @@
+package search_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/search"
+)
+
+func TestQueryTextTerms_BareWordsAndTextTermsOnly(t *testing.T) {
+	query, err := search.Parse(`ambush text:"when played" name:luke -shielded set:SOR`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"ambush", "when played"}, query.TextTerms())
+}
+
+func TestSnippet_MarksEveryMatchIgnoringCase(t *testing.T) {
+	parts := search.Snippet("Ambush. When Played: deal 2 damage. When Defeated: draw.", []string{"when"})
+
+	assert.Equal(t, []search.SnippetPart{
+		{Text: "Ambush. "},
+		{Text: "When", Match: true},
+		{Text: " Played: deal 2 damage. "},
+		{Text: "When", Match: true},
+		{Text: " Defeated: draw."},
+	}, parts)
+}
+
+func TestSnippet_LongText_CutsAroundFirstMatch(t *testing.T) {
+	text := strings.Repeat("a", 100) + " Sentinel " + strings.Repeat("b", 100)
+
+	parts := search.Snippet(text, []string{"sentinel"})
+
+	require.Len(t, parts, 5)
+	assert.Equal(t, "…", parts[0].Text)
+	assert.Equal(t, search.SnippetPart{Text: "Sentinel", Match: true}, parts[2])
+	assert.Equal(t, "…", parts[4].Text)
+	assert.Less(t, len(parts[1].Text)+len(parts[3].Text), len(text))
+}
+
+func TestSnippet_NoMatch_ReturnsNil(t *testing.T) {
+	assert.Nil(t, search.Snippet("Restore 2.", []string{"ambush"}))
+	assert.Nil(t, search.Snippet("", []string{"ambush"}))
+}
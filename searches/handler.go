@@ -0,0 +1,268 @@
+// Package searches provides the HTTP handlers for saved searches: named
+// search box queries such as "Villainy rares I own 0 of". The JSON endpoints
+// under /searches are for scripts; the HTML fragment endpoints drive the
+// saved searches panel on the index page, where pinned searches are shown as
+// one-click views.
+package searches
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"swucol/database"
+	"swucol/internal/httpjson"
+	"swucol/models"
+	"swucol/templates"
+)
+
+// searchRequest is the JSON body accepted by CreateHandler and UpdateHandler.
+type searchRequest struct {
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	Pinned bool   `json:"pinned"`
+}
+
+// search returns the saved search described by the request.
+func (payload searchRequest) search() models.SavedSearch {
+	return models.SavedSearch{Name: payload.Name, Query: payload.Query, Pinned: payload.Pinned}
+}
+
+// ListHandler returns an http.HandlerFunc that handles GET /searches. Returns
+// 200 OK with a JSON array of every saved search, pinned ones first (empty
+// when there are none), and 500 Internal Server Error for database errors.
+func ListHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		searches, err := db.GetSavedSearches()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading saved searches", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, searches)
+	}
+}
+
+// CreateHandler returns an http.HandlerFunc that handles POST /searches with
+// a JSON body of the form {"name": "...", "query": "...", "pinned": true}.
+// Returns 201 Created with the stored search as JSON, 400 Bad Request for an
+// invalid body, and 500 Internal Server Error for database errors.
+func CreateHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		var payload searchRequest
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		search := payload.search()
+		if err := database.ValidateSavedSearch(search); err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		created, err := db.CreateSavedSearch(search)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error creating saved search", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "saved search created", "search_id", created.ID)
+
+		httpjson.Write(responseWriter, request, http.StatusCreated, created)
+	}
+}
+
+// UpdateHandler returns an http.HandlerFunc that handles PUT /searches/{id}
+// with the same JSON body as CreateHandler, replacing every field of the
+// search. Returns 200 OK with the stored search as JSON, 400 Bad Request for
+// an invalid id or body, 404 Not Found if no such search exists, and 500
+// Internal Server Error for database errors.
+func UpdateHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, ok := parseSearchID(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		var payload searchRequest
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		search := payload.search()
+		search.ID = id
+		if err := database.ValidateSavedSearch(search); err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		updated, err := db.UpdateSavedSearch(search)
+		if errors.Is(err, database.ErrSavedSearchNotFound) {
+			http.Error(responseWriter, "saved search not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error updating saved search", "search_id", id, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		httpjson.Write(responseWriter, request, http.StatusOK, updated)
+	}
+}
+
+// DeleteHandler returns an http.HandlerFunc that handles DELETE
+// /searches/{id}. Returns 204 No Content once the search is deleted, 400 Bad
+// Request for an invalid id, 404 Not Found if no such search exists, and 500
+// Internal Server Error for database errors.
+func DeleteHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, ok := parseSearchID(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		if !deleteSearch(responseWriter, request, db, id) {
+			return
+		}
+
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// CreateHTMLHandler returns an http.HandlerFunc that handles POST
+// /searches/html with form values "name", "q" (the search box contents) and
+// "pinned". Responds with the re-rendered saved searches panel, 400 Bad
+// Request for invalid values, and 500 Internal Server Error for database or
+// template errors.
+func CreateHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if err := request.ParseForm(); err != nil {
+			templates.RenderError(responseWriter, request, tmpl, "invalid form data", http.StatusBadRequest)
+			return
+		}
+
+		search := models.SavedSearch{
+			Name:   request.PostForm.Get("name"),
+			Query:  request.PostForm.Get("q"),
+			Pinned: request.PostForm.Get("pinned") == "true",
+		}
+		if err := database.ValidateSavedSearch(search); err != nil {
+			templates.RenderError(responseWriter, request, tmpl, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		created, err := db.CreateSavedSearch(search)
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error creating saved search", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "saved search created", "search_id", created.ID)
+
+		renderSearches(responseWriter, request, db, tmpl)
+	}
+}
+
+// PinHTMLHandler returns an http.HandlerFunc that handles POST
+// /searches/{id}/pin/html with form value "pinned" set to "true" or "false".
+// Responds with the re-rendered saved searches panel, 400 Bad Request for an
+// invalid id, 404 Not Found if no such search exists, and 500 Internal Server
+// Error for database or template errors.
+func PinHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, ok := parseSearchID(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		pinned := request.FormValue("pinned") == "true"
+		err := db.SetSavedSearchPinned(id, pinned)
+		if errors.Is(err, database.ErrSavedSearchNotFound) {
+			templates.RenderError(responseWriter, request, tmpl, "saved search not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error pinning saved search", "search_id", id, "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		renderSearches(responseWriter, request, db, tmpl)
+	}
+}
+
+// DeleteHTMLHandler returns an http.HandlerFunc that handles DELETE
+// /searches/{id}/html. Responds with the re-rendered saved searches panel,
+// 400 Bad Request for an invalid id, 404 Not Found if no such search exists,
+// and 500 Internal Server Error for database or template errors.
+func DeleteHTMLHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		id, ok := parseSearchID(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		if !deleteSearch(responseWriter, request, db, id) {
+			return
+		}
+
+		renderSearches(responseWriter, request, db, tmpl)
+	}
+}
+
+// parseSearchID reads the {id} path value. It responds with 400 Bad Request
+// and returns false when the id is not a positive integer.
+func parseSearchID(responseWriter http.ResponseWriter, request *http.Request) (int, bool) {
+	id, err := strconv.Atoi(request.PathValue("id"))
+	if err != nil || id <= 0 {
+		http.Error(responseWriter, "id must be a positive integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// deleteSearch deletes the saved search with the given id. It responds with
+// the error and returns false when the search does not exist or the delete
+// fails.
+func deleteSearch(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, id int) bool {
+	err := db.DeleteSavedSearch(id)
+	if errors.Is(err, database.ErrSavedSearchNotFound) {
+		http.Error(responseWriter, "saved search not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error deleting saved search", "search_id", id, "error", err)
+		http.Error(responseWriter, "database error", http.StatusInternalServerError)
+		return false
+	}
+
+	slog.InfoContext(request.Context(), "saved search deleted", "search_id", id)
+
+	return true
+}
+
+// renderSearches loads every saved search and renders the saved-searches
+// fragment of the index page.
+func renderSearches(responseWriter http.ResponseWriter, request *http.Request, db *database.Database, tmpl templates.Renderer) {
+	searches, err := db.GetSavedSearches()
+	if err != nil {
+		slog.ErrorContext(request.Context(), "database error loading saved searches", "error", err)
+		templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.ExecuteTemplate(responseWriter, "saved-searches", searches); err != nil {
+		slog.ErrorContext(request.Context(), "failed to render saved-searches template", "error", err)
+		http.Error(responseWriter, "template error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,104 @@
+package searches_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/searches"
+	"swucol/templates"
+)
+
+func TestCreateAndUpdateHandlers_StoreSearch(t *testing.T) {
+	db := testdb.New(t)
+
+	createRecorder := httptest.NewRecorder()
+	searches.CreateHandler(db)(createRecorder, httptest.NewRequest(http.MethodPost, "/searches",
+		strings.NewReader(`{"name": "Villainy rares", "query": "vader"}`)))
+
+	require.Equal(t, http.StatusCreated, createRecorder.Code, createRecorder.Body.String())
+	var created models.SavedSearch
+	require.NoError(t, json.Unmarshal(createRecorder.Body.Bytes(), &created))
+	assert.Equal(t, "vader", created.Query)
+	assert.False(t, created.Pinned)
+
+	updateRequest := httptest.NewRequest(http.MethodPut, "/searches/1",
+		strings.NewReader(`{"name": "Villainy rares", "query": "darth vader", "pinned": true}`))
+	updateRequest.SetPathValue("id", "1")
+	updateRecorder := httptest.NewRecorder()
+	searches.UpdateHandler(db)(updateRecorder, updateRequest)
+
+	require.Equal(t, http.StatusOK, updateRecorder.Code, updateRecorder.Body.String())
+
+	listRecorder := httptest.NewRecorder()
+	searches.ListHandler(db)(listRecorder, httptest.NewRequest(http.MethodGet, "/searches", nil))
+	var saved []models.SavedSearch
+	require.NoError(t, json.Unmarshal(listRecorder.Body.Bytes(), &saved))
+	require.Len(t, saved, 1)
+	assert.Equal(t, "darth vader", saved[0].Query)
+	assert.True(t, saved[0].Pinned)
+}
+
+func TestCreateHandler_MissingName_ReturnsBadRequest(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := httptest.NewRecorder()
+	searches.CreateHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/searches",
+		strings.NewReader(`{"name": " ", "query": "vader"}`)))
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "name is required")
+}
+
+func TestDeleteHandler_UnknownSearch_ReturnsNotFound(t *testing.T) {
+	db := testdb.New(t)
+
+	request := httptest.NewRequest(http.MethodDelete, "/searches/42", nil)
+	request.SetPathValue("id", "42")
+	recorder := httptest.NewRecorder()
+	searches.DeleteHandler(db)(recorder, request)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestHTMLHandlers_SaveAndPinSearch(t *testing.T) {
+	db := testdb.New(t)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	form := url.Values{"name": {"Jedi"}, "q": {"skywalker"}}
+	createRequest := httptest.NewRequest(http.MethodPost, "/searches/html", strings.NewReader(form.Encode()))
+	createRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	createRecorder := httptest.NewRecorder()
+	searches.CreateHTMLHandler(db, tmpl)(createRecorder, createRequest)
+
+	require.Equal(t, http.StatusOK, createRecorder.Code, createRecorder.Body.String())
+	assert.Contains(t, createRecorder.Body.String(), "Jedi")
+	assert.NotContains(t, createRecorder.Body.String(), `class="saved-chip"`)
+
+	pinRequest := httptest.NewRequest(http.MethodPost, "/searches/1/pin/html", strings.NewReader("pinned=true"))
+	pinRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	pinRequest.SetPathValue("id", "1")
+	pinRecorder := httptest.NewRecorder()
+	searches.PinHTMLHandler(db, tmpl)(pinRecorder, pinRequest)
+
+	require.Equal(t, http.StatusOK, pinRecorder.Code, pinRecorder.Body.String())
+	assert.Contains(t, pinRecorder.Body.String(), `class="saved-chip" type="button" data-query="skywalker"`)
+	assert.Contains(t, pinRecorder.Body.String(), "Unpin")
+
+	deleteRequest := httptest.NewRequest(http.MethodDelete, "/searches/1/html", nil)
+	deleteRequest.SetPathValue("id", "1")
+	deleteRecorder := httptest.NewRecorder()
+	searches.DeleteHTMLHandler(db, tmpl)(deleteRecorder, deleteRequest)
+
+	require.Equal(t, http.StatusOK, deleteRecorder.Code)
+	assert.Contains(t, deleteRecorder.Body.String(), "No saved searches yet.")
+}
@@ -0,0 +1,292 @@
+// Package server runs the HTTP server with the serving options needed to put
+// swucol on the internet or behind a reverse proxy: TCP, unix socket or
+// systemd socket activation listeners, TLS from a certificate file or Let's
+// Encrypt, client addresses from trusted proxies, and serving under a base
+// path.
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Config selects how Listen and Serve serve. The zero value except for Addr
+// serves plain HTTP.
+type Config struct {
+	// Addr is the TCP address to listen on, such as ":8080".
+	Addr string
+
+	// UnixSocket, when set, is the path of a unix domain socket to listen on
+	// instead of Addr. A stale socket file left by a previous run is
+	// replaced.
+	UnixSocket string
+
+	// TLSCert and TLSKey are paths to a PEM certificate and key. Both or
+	// neither must be set.
+	TLSCert string
+	TLSKey  string
+
+	// AutocertHost, when set, obtains certificates for that hostname from
+	// Let's Encrypt instead, caching them in AutocertCacheDir. The TLS-ALPN
+	// challenge is answered on Addr, which must therefore be reachable on
+	// port 443.
+	AutocertHost     string
+	AutocertCacheDir string
+}
+
+// Validate returns an error describing the first inconsistent option.
+func (config Config) Validate() error {
+	if (config.TLSCert == "") != (config.TLSKey == "") {
+		return errors.New("TLS certificate and key must be set together")
+	}
+	if config.TLSCert != "" && config.AutocertHost != "" {
+		return errors.New("TLS certificate files and autocert cannot both be used")
+	}
+	if config.AutocertHost != "" && config.AutocertCacheDir == "" {
+		return errors.New("autocert requires a cache directory")
+	}
+	return nil
+}
+
+// unixSocketMode is the permission of a socket created for
+// Config.UnixSocket: the owner and group, such as a reverse proxy's, may
+// connect.
+const unixSocketMode = 0o660
+
+// systemdFirstFD is the first file descriptor systemd passes to a socket
+// activated service.
+const systemdFirstFD = 3
+
+// Listen returns the listener to serve on. A socket passed by systemd socket
+// activation (LISTEN_PID and LISTEN_FDS naming this process) takes
+// precedence; only the first passed socket is used. Otherwise Listen creates
+// config.UnixSocket if set, or listens on config.Addr.
+func Listen(config Config) (net.Listener, error) {
+	listener, activated, err := systemdListener()
+	if err != nil || activated {
+		return listener, err
+	}
+
+	if config.UnixSocket != "" {
+		if err := os.Remove(config.UnixSocket); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("remove stale unix socket: %w", err)
+		}
+
+		listener, err := net.Listen("unix", config.UnixSocket)
+		if err != nil {
+			return nil, fmt.Errorf("listen on unix socket: %w", err)
+		}
+
+		if err := os.Chmod(config.UnixSocket, unixSocketMode); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("set unix socket permissions: %w", err)
+		}
+
+		return listener, nil
+	}
+
+	listener, err = net.Listen("tcp", config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", config.Addr, err)
+	}
+
+	return listener, nil
+}
+
+// systemdListener returns the first socket passed by systemd socket
+// activation and true, or false when the process was not socket activated.
+// The activation variables are unset so that child processes do not inherit
+// them.
+func systemdListener() (net.Listener, bool, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, false, nil
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, false, nil
+	}
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	file := os.NewFile(systemdFirstFD, "systemd-socket")
+	defer file.Close()
+
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, true, fmt.Errorf("use systemd socket: %w", err)
+	}
+
+	return listener, true, nil
+}
+
+// Serve serves handler on listener, over TLS when a certificate or autocert
+// host is configured.
+func Serve(config Config, listener net.Listener, handler http.Handler) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{Handler: handler}
+
+	switch {
+	case config.AutocertHost != "":
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(config.AutocertHost),
+			Cache:      autocert.DirCache(config.AutocertCacheDir),
+		}
+		httpServer.TLSConfig = manager.TLSConfig()
+		return httpServer.ServeTLS(listener, "", "")
+	case config.TLSCert != "":
+		httpServer.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		return httpServer.ServeTLS(listener, config.TLSCert, config.TLSKey)
+	default:
+		return httpServer.Serve(listener)
+	}
+}
+
+// NormalizeBasePath turns a base path option such as "swucol", "/swucol/" or
+// "/" into the form used by BasePath and the templates: empty for the root,
+// otherwise a leading slash and no trailing slash.
+func NormalizeBasePath(basePath string) (string, error) {
+	trimmed := strings.Trim(basePath, "/")
+	if trimmed == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(trimmed, "?#") {
+		return "", fmt.Errorf("invalid base path %q", basePath)
+	}
+	return "/" + trimmed, nil
+}
+
+// BasePath serves next under basePath, as returned by NormalizeBasePath.
+// Requests under the base path have it removed before reaching next, and
+// other requests are passed through unchanged, so that it works both when the
+// proxy forwards the full path and when it strips the prefix itself.
+func BasePath(basePath string, next http.Handler) http.Handler {
+	if basePath == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == basePath {
+			http.Redirect(responseWriter, request, basePath+"/", http.StatusMovedPermanently)
+			return
+		}
+
+		rest, ok := strings.CutPrefix(request.URL.Path, basePath+"/")
+		if !ok {
+			next.ServeHTTP(responseWriter, request)
+			return
+		}
+
+		stripped := request.Clone(request.Context())
+		stripped.URL.Path = "/" + rest
+		stripped.URL.RawPath = ""
+		next.ServeHTTP(responseWriter, stripped)
+	})
+}
+
+// ParseTrustedProxies parses a comma-separated list of IP addresses and CIDR
+// prefixes, such as "127.0.0.1,10.0.0.0/8".
+func ParseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for entry := range strings.SplitSeq(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
+// isTrusted reports whether addr is inside one of proxies.
+func isTrusted(proxies []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnixConnection reports whether request arrived on a unix socket listener.
+func isUnixConnection(request *http.Request) bool {
+	localAddr, ok := request.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && localAddr.Network() == "unix"
+}
+
+// TrustedProxies replaces the request's RemoteAddr with the client address
+// from X-Forwarded-For when the connection comes from one of proxies. The
+// header is read from the right, skipping further trusted proxies, so a
+// client cannot spoof its address by sending the header itself. Requests from
+// other peers keep their RemoteAddr and their X-Forwarded-For is ignored.
+// Connections over a unix socket have no peer address; they come from a
+// local process the socket's permissions admit, such as the reverse proxy, so
+// they are trusted whenever any proxy is.
+func TrustedProxies(proxies []netip.Prefix, next http.Handler) http.Handler {
+	if len(proxies) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		if !isUnixConnection(request) {
+			peer, err := netip.ParseAddrPort(request.RemoteAddr)
+			if err != nil || !isTrusted(proxies, peer.Addr()) {
+				next.ServeHTTP(responseWriter, request)
+				return
+			}
+		}
+
+		var hops []string
+		for _, header := range request.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(header, ",")...)
+		}
+
+		for i := len(hops) - 1; i >= 0; i-- {
+			client, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			if i > 0 && isTrusted(proxies, client) {
+				continue
+			}
+
+			forwarded := request.Clone(request.Context())
+			forwarded.RemoteAddr = net.JoinHostPort(client.Unmap().String(), "0")
+			next.ServeHTTP(responseWriter, forwarded)
+			return
+		}
+
+		next.ServeHTTP(responseWriter, request)
+	})
+}
@@ -0,0 +1,155 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/server"
+)
+
+// recordRequest returns a handler that responds with the request path and
+// remote address it received.
+func recordRequest() http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.Write([]byte(request.URL.Path + " " + request.RemoteAddr))
+	})
+}
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := map[string]string{
+		"":         "",
+		"/":        "",
+		"swucol":   "/swucol",
+		"/swucol/": "/swucol",
+		"/a/b/":    "/a/b",
+	}
+	for input, expected := range tests {
+		actual, err := server.NormalizeBasePath(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+
+	_, err := server.NormalizeBasePath("/swucol?x=1")
+	assert.Error(t, err)
+}
+
+func TestBasePath_StripsPrefixAndPassesOtherPathsThrough(t *testing.T) {
+	handler := server.BasePath("/swucol", recordRequest())
+
+	tests := map[string]string{
+		"/swucol/wishlist": "/wishlist",
+		"/swucol/":         "/",
+		"/wishlist":        "/wishlist",
+	}
+	for path, expected := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Contains(t, recorder.Body.String(), expected+" ", path)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/swucol", nil))
+	assert.Equal(t, http.StatusMovedPermanently, recorder.Code)
+	assert.Equal(t, "/swucol/", recorder.Header().Get("Location"))
+}
+
+func TestTrustedProxies_UsesForwardedClientOnlyFromTrustedPeers(t *testing.T) {
+	proxies, err := server.ParseTrustedProxies("127.0.0.1, 10.0.0.0/8")
+	require.NoError(t, err)
+	handler := server.TrustedProxies(proxies, recordRequest())
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		{"trusted proxy", "127.0.0.1:5000", "203.0.113.7", "203.0.113.7:0"},
+		{"chain of trusted proxies", "127.0.0.1:5000", "203.0.113.7, 10.1.2.3", "203.0.113.7:0"},
+		{"spoofed header before client", "127.0.0.1:5000", "198.51.100.1, 203.0.113.7", "203.0.113.7:0"},
+		{"untrusted peer", "192.0.2.1:5000", "203.0.113.7", "192.0.2.1:5000"},
+		{"no header", "127.0.0.1:5000", "", "127.0.0.1:5000"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = test.remoteAddr
+			if test.forwarded != "" {
+				request.Header.Set("X-Forwarded-For", test.forwarded)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, "/ "+test.expected, recorder.Body.String())
+		})
+	}
+}
+
+func TestParseTrustedProxies_InvalidEntry_ReturnsError(t *testing.T) {
+	_, err := server.ParseTrustedProxies("127.0.0.1,proxy.local")
+
+	assert.ErrorContains(t, err, "proxy.local")
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, server.Config{Addr: ":8080"}.Validate())
+	assert.Error(t, server.Config{TLSCert: "cert.pem"}.Validate())
+	assert.Error(t, server.Config{TLSCert: "cert.pem", TLSKey: "key.pem", AutocertHost: "example.com", AutocertCacheDir: "certs"}.Validate())
+	assert.Error(t, server.Config{AutocertHost: "example.com"}.Validate())
+}
+
+func TestListen_UnixSocket_ServesOverSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "swucol.sock")
+	require.NoError(t, os.WriteFile(socketPath, nil, 0o600), "stale file should be replaced")
+
+	listener, err := server.Listen(server.Config{UnixSocket: socketPath})
+	require.NoError(t, err)
+	assert.Equal(t, "unix", listener.Addr().Network())
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o660), info.Mode().Perm())
+
+	proxies, err := server.ParseTrustedProxies("127.0.0.1")
+	require.NoError(t, err)
+	go server.Serve(server.Config{}, listener, server.TrustedProxies(proxies, recordRequest()))
+	t.Cleanup(func() { listener.Close() })
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	request, err := http.NewRequest(http.MethodGet, "http://swucol/wishlist", nil)
+	require.NoError(t, err)
+	request.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	response, err := client.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/wishlist 203.0.113.7:0", string(body))
+}
+
+func TestListen_SystemdVariablesForAnotherProcess_ListensOnAddr(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+
+	listener, err := server.Listen(server.Config{Addr: "127.0.0.1:0"})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	assert.Equal(t, "tcp", listener.Addr().Network())
+}
@@ -0,0 +1,170 @@
+// Package settings provides the HTTP handlers for reading and editing the
+// app preferences stored by the database package.
+package settings
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"swucol/csrf"
+	"swucol/database"
+	"swucol/models"
+	"swucol/roles"
+	"swucol/templates"
+)
+
+// GetSettingsHandler returns an http.HandlerFunc that handles GET /settings.
+// Returns 200 OK with the settings in use as JSON.
+func GetSettingsHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		writeSettings(responseWriter, request, db.Settings())
+	}
+}
+
+// PutSettingsHandler returns an http.HandlerFunc that handles PUT /settings.
+// The JSON body may set any subset of the settings fields; fields it omits
+// keep their current value. Returns 200 OK with the saved settings as JSON,
+// 400 Bad Request for a malformed body or an invalid value, and 500 Internal
+// Server Error for database errors.
+func PutSettingsHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		settings := db.Settings()
+		if err := json.NewDecoder(request.Body).Decode(&settings); err != nil {
+			http.Error(responseWriter, "request body must be a JSON settings object", http.StatusBadRequest)
+			return
+		}
+
+		if err := database.ValidateSettings(settings); err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := db.SaveSettings(settings); err != nil {
+			slog.ErrorContext(request.Context(), "database error saving settings", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "settings saved", "settings", settings)
+
+		writeSettings(responseWriter, request, settings)
+	}
+}
+
+// writeSettings responds with settings as JSON.
+func writeSettings(responseWriter http.ResponseWriter, request *http.Request, settings models.Settings) {
+	responseWriter.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(responseWriter).Encode(settings); err != nil {
+		slog.ErrorContext(request.Context(), "failed to encode settings response", "error", err)
+		http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// settingsPage is the view model rendered by the settings template. Error is
+// the validation message of a rejected form submission.
+type settingsPage struct {
+	Settings  models.Settings
+	Saved     bool
+	Error     string
+	Role      string
+	CSRFToken string
+}
+
+// PageHandler returns an http.HandlerFunc that serves the settings page at
+// GET /settings/html. Returns 500 Internal Server Error if template rendering
+// fails.
+func PageHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		renderPage(responseWriter, request, tmpl, http.StatusOK, settingsPage{Settings: db.Settings()})
+	}
+}
+
+// SaveFormHandler returns an http.HandlerFunc that handles the settings form
+// posted to POST /settings/html. It saves the submitted settings and renders
+// the settings page again with a confirmation. Returns 400 Bad Request with
+// the page and an error message for an invalid value, and 500 Internal Server
+// Error for database or template errors.
+func SaveFormHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		settings, message := parseSettingsForm(request)
+		if message == "" {
+			if err := database.ValidateSettings(settings); err != nil {
+				message = err.Error()
+			}
+		}
+		if message != "" {
+			renderPage(responseWriter, request, tmpl, http.StatusBadRequest, settingsPage{Settings: settings, Error: message})
+			return
+		}
+
+		if err := db.SaveSettings(settings); err != nil {
+			slog.ErrorContext(request.Context(), "database error saving settings", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "settings saved", "settings", settings)
+
+		renderPage(responseWriter, request, tmpl, http.StatusOK, settingsPage{Settings: settings, Saved: true})
+	}
+}
+
+// parseSettingsForm reads settings from the settings form. Unchecked
+// checkboxes are absent from the form and read as false. Returns an error
+// message suitable for display when a number field is not an integer.
+func parseSettingsForm(request *http.Request) (models.Settings, string) {
+	settings := models.Settings{
+		DefaultSort:         request.FormValue("default_sort"),
+		Theme:               request.FormValue("theme"),
+		ImportLenient:       request.FormValue("import_lenient") != "",
+		ImportUseOwnedCount: request.FormValue("import_use_owned_count") != "",
+		ImportKeepFiles:     request.FormValue("import_keep_files") != "",
+		HTMLOnly:            request.FormValue("html_only") != "",
+		DigestChannel:       request.FormValue("digest_channel"),
+		DigestTarget:        strings.TrimSpace(request.FormValue("digest_target")),
+	}
+
+	numbers := []struct {
+		name   string
+		target *int
+	}{
+		{name: "leader_minimum_owned", target: &settings.LeaderMinimumOwned},
+		{name: "base_minimum_owned", target: &settings.BaseMinimumOwned},
+		{name: "unit_minimum_owned", target: &settings.UnitMinimumOwned},
+		{name: "event_minimum_owned", target: &settings.EventMinimumOwned},
+		{name: "upgrade_minimum_owned", target: &settings.UpgradeMinimumOwned},
+		{name: "token_minimum_owned", target: &settings.TokenMinimumOwned},
+		{name: "untyped_minimum_owned", target: &settings.UntypedMinimumOwned},
+		{name: "items_per_page", target: &settings.ItemsPerPage},
+		{name: "digest_interval_days", target: &settings.DigestIntervalDays},
+		{name: "decrement_floor", target: &settings.DecrementFloor},
+		{name: "max_owned_change", target: &settings.MaxOwnedChange},
+	}
+	for _, number := range numbers {
+		parsed, err := strconv.Atoi(request.FormValue(number.name))
+		if err != nil {
+			return settings, number.name + " must be an integer"
+		}
+		*number.target = parsed
+	}
+
+	return settings, ""
+}
+
+// renderPage renders the settings template with statusCode and the
+// request's role and CSRF token.
+func renderPage(responseWriter http.ResponseWriter, request *http.Request, tmpl templates.Renderer, statusCode int, page settingsPage) {
+	page.Role = roles.FromContext(request.Context())
+	page.CSRFToken = csrf.Token(request.Context())
+
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	responseWriter.WriteHeader(statusCode)
+	if err := tmpl.ExecuteTemplate(responseWriter, "settings", page); err != nil {
+		slog.ErrorContext(request.Context(), "failed to render settings template", "error", err)
+		return
+	}
+}
@@ -0,0 +1,213 @@
+package settings_test
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/csrf"
+	"swucol/database"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/roles"
+	"swucol/settings"
+	"swucol/templates"
+)
+
+// newTestTemplates parses the application templates.
+func newTestTemplates(t *testing.T) *template.Template {
+	t.Helper()
+
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	return tmpl
+}
+
+// putSettings sends a PUT request with body to PutSettingsHandler.
+func putSettings(t *testing.T, db *database.Database, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	settings.PutSettingsHandler(db)(recorder, httptest.NewRequest(http.MethodPut, "/settings", strings.NewReader(body)))
+
+	return recorder
+}
+
+func TestGetSettingsHandler_ReturnsDefaults(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := httptest.NewRecorder()
+	settings.GetSettingsHandler(db)(recorder, httptest.NewRequest(http.MethodGet, "/settings", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var got models.Settings
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&got))
+	assert.Equal(t, database.DefaultSettings(), got)
+}
+
+func TestPutSettingsHandler_PartialBody_UpdatesOnlyGivenFields(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := putSettings(t, db, `{"theme":"light","items_per_page":24}`)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	want := database.DefaultSettings()
+	want.Theme = models.ThemeLight
+	want.ItemsPerPage = 24
+	assert.Equal(t, want, db.Settings())
+
+	stored, err := db.GetSettings()
+	require.NoError(t, err)
+	assert.Equal(t, want, stored)
+}
+
+func TestPutSettingsHandler_InvalidBody_Returns400(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "malformed JSON", body: `{"theme":`},
+		{name: "wrong type", body: `{"items_per_page":"lots"}`},
+		{name: "invalid value", body: `{"default_sort":"price"}`},
+		{name: "zero minimum", body: `{"event_minimum_owned":0}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testdb.New(t)
+
+			recorder := putSettings(t, db, tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			assert.Equal(t, database.DefaultSettings(), db.Settings())
+		})
+	}
+}
+
+func TestPageHandler_RendersCurrentSettings(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := httptest.NewRecorder()
+	settings.PageHandler(db, newTestTemplates(t))(recorder, httptest.NewRequest(http.MethodGet, "/settings/html", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `name="unit_minimum_owned" min="1" value="6"`)
+	assert.NotContains(t, string(body), "csrf")
+}
+
+func TestPageHandler_NonAdmin_HidesSaveButton(t *testing.T) {
+	db := testdb.New(t)
+	tmpl := newTestTemplates(t)
+
+	for _, role := range []string{models.RoleViewer, models.RoleEditor} {
+		recorder := httptest.NewRecorder()
+		roles.Middleware(db, role, settings.PageHandler(db, tmpl)).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/settings/html", nil))
+
+		require.Equal(t, http.StatusOK, recorder.Code, role)
+		assert.Contains(t, recorder.Body.String(), ".role-admin {", role)
+		assert.Contains(t, recorder.Body.String(), `class="save-btn role-admin"`, role)
+	}
+
+	recorder := httptest.NewRecorder()
+	settings.PageHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/settings/html", nil))
+
+	assert.NotContains(t, recorder.Body.String(), ".role-admin {")
+}
+
+func TestPageHandler_WithCSRF_RendersToken(t *testing.T) {
+	db := testdb.New(t)
+	handler := csrf.Middleware(settings.PageHandler(db, newTestTemplates(t)))
+
+	request := httptest.NewRequest(http.MethodGet, "/settings/html", nil)
+	request.AddCookie(&http.Cookie{Name: csrf.CookieName, Value: "ABCDEFGHIJKLMNOPQRSTUVWXYZ"})
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, `<meta name="csrf-token" content="ABCDEFGHIJKLMNOPQRSTUVWXYZ">`)
+	assert.Contains(t, body, `<input type="hidden" name="csrf_token" value="ABCDEFGHIJKLMNOPQRSTUVWXYZ">`)
+}
+
+// postSettingsForm posts form to SaveFormHandler.
+func postSettingsForm(t *testing.T, db *database.Database, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/settings/html", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	settings.SaveFormHandler(db, newTestTemplates(t))(recorder, request)
+
+	return recorder
+}
+
+func TestSaveFormHandler_SavesSettings(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := postSettingsForm(t, db, url.Values{
+		"leader_minimum_owned":  {"2"},
+		"base_minimum_owned":    {"1"},
+		"unit_minimum_owned":    {"4"},
+		"event_minimum_owned":   {"2"},
+		"upgrade_minimum_owned": {"2"},
+		"token_minimum_owned":   {"1"},
+		"untyped_minimum_owned": {"4"},
+		"default_sort":          {"recent"},
+		"theme":                 {"light"},
+		"items_per_page":        {"100"},
+		"import_lenient":        {"true"},
+		"digest_channel":        {"ntfy"},
+		"digest_target":         {"https://ntfy.sh/my-collection"},
+		"digest_interval_days":  {"14"},
+		"decrement_floor":       {"1"},
+		"max_owned_change":      {"10"},
+	})
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Settings saved.")
+	assert.Equal(t, models.Settings{
+		LeaderMinimumOwned:  2,
+		BaseMinimumOwned:    1,
+		UnitMinimumOwned:    4,
+		EventMinimumOwned:   2,
+		UpgradeMinimumOwned: 2,
+		TokenMinimumOwned:   1,
+		UntypedMinimumOwned: 4,
+		DefaultSort:         "recent",
+		Theme:               models.ThemeLight,
+		ItemsPerPage:        100,
+		ImportLenient:       true,
+		DigestChannel:       models.DigestChannelNtfy,
+		DigestTarget:        "https://ntfy.sh/my-collection",
+		DigestIntervalDays:  14,
+		DecrementFloor:      1,
+		MaxOwnedChange:      10,
+	}, db.Settings())
+}
+
+func TestSaveFormHandler_InvalidValue_Returns400AndKeepsSettings(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := postSettingsForm(t, db, url.Values{
+		"leader_minimum_owned": {"one"},
+		"unit_minimum_owned":   {"6"},
+		"default_sort":         {"name"},
+		"theme":                {"dark"},
+		"items_per_page":       {"0"},
+	})
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "leader_minimum_owned must be an integer")
+	assert.Equal(t, database.DefaultSettings(), db.Settings())
+}
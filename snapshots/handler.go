@@ -0,0 +1,178 @@
+package snapshots
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"swucol/audit"
+	"swucol/csrf"
+	"swucol/database"
+	"swucol/models"
+	"swucol/roles"
+	"swucol/templates"
+)
+
+// CreateSnapshotHandler returns an http.HandlerFunc that handles
+// POST /snapshots. It records the current owned count of every card. Returns
+// 201 Created with the snapshot as JSON and 500 Internal Server Error for
+// database errors.
+func CreateSnapshotHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		snapshot, err := db.CreateCollectionSnapshot()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error creating collection snapshot", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(request.Context(), "collection snapshot created", "snapshot_id", snapshot.ID, "total_owned", snapshot.TotalOwned)
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(responseWriter).Encode(snapshot); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode snapshot response", "error", err)
+		}
+	}
+}
+
+// ListSnapshotsHandler returns an http.HandlerFunc that handles GET /snapshots.
+// Returns 200 OK with every snapshot as JSON, oldest first, and 500 Internal
+// Server Error for database errors.
+func ListSnapshotsHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		snapshots, err := db.GetCollectionSnapshots()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error listing collection snapshots", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(snapshots); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode snapshots response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// CompareSnapshotsHandler returns an http.HandlerFunc that handles
+// GET /snapshots/{a}/compare/{b}. It responds with the cards gained and lost
+// going from snapshot a to snapshot b. Returns 200 OK with the Comparison as
+// JSON, 400 Bad Request for a non-numeric id, 404 Not Found when either
+// snapshot does not exist, and 500 Internal Server Error for database errors.
+func CompareSnapshotsHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		fromID, err := strconv.Atoi(request.PathValue("a"))
+		if err != nil || fromID <= 0 {
+			http.Error(responseWriter, "snapshot ids must be positive integers", http.StatusBadRequest)
+			return
+		}
+
+		toID, err := strconv.Atoi(request.PathValue("b"))
+		if err != nil || toID <= 0 {
+			http.Error(responseWriter, "snapshot ids must be positive integers", http.StatusBadRequest)
+			return
+		}
+
+		from, fromCounts, err := db.GetCollectionSnapshot(fromID)
+		if errors.Is(err, database.ErrSnapshotNotFound) {
+			http.Error(responseWriter, "snapshot not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading collection snapshot", "snapshot_id", fromID, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		to, toCounts, err := db.GetCollectionSnapshot(toID)
+		if errors.Is(err, database.ErrSnapshotNotFound) {
+			http.Error(responseWriter, "snapshot not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading collection snapshot", "snapshot_id", toID, "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		gained, lost := Compare(fromCounts, toCounts)
+		comparison := Comparison{From: from, To: to, Gained: gained, Lost: lost}
+
+		slog.InfoContext(request.Context(), "compared collection snapshots", "from", fromID, "to", toID, "gained", len(gained), "lost", len(lost))
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(comparison); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode comparison response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// historyPage is the view model rendered by the history template. Valuing
+// is set when a market price source is configured.
+type historyPage struct {
+	Chart          Chart
+	Snapshots      []models.CollectionSnapshot
+	ValuationChart Chart
+	Valuations     []models.Valuation
+	Valuing        bool
+	Activity       audit.Heatmap
+	Theme          string
+	Role           string
+	CSRFToken      string
+}
+
+// HistoryHandler returns an http.HandlerFunc that serves the collection
+// history page at GET /history, charting the total owned count of every
+// snapshot and the value of every monthly valuation over time, and a heatmap
+// of the days with changes over the last year. valuing offers the Value now
+// button. Returns 500 Internal Server Error if a
+// database query or template rendering fails.
+func HistoryHandler(db *database.Database, tmpl templates.Renderer, valuing bool) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		snapshots, err := db.GetCollectionSnapshots()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading collection snapshots", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		valuations, err := db.GetValuations()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading valuations", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		activity, err := audit.Activity(db, time.Now())
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading audit activity", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		page := historyPage{
+			Chart:          BuildChart(snapshots),
+			Snapshots:      snapshots,
+			ValuationChart: BuildValuationChart(valuations),
+			Valuations:     valuations,
+			Valuing:        valuing,
+			Activity:       audit.BuildHeatmap(activity),
+			Theme:          db.Settings().Theme,
+			Role:           roles.FromContext(request.Context()),
+			CSRFToken:      csrf.Token(request.Context()),
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "history", page); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render history template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
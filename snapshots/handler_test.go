@@ -0,0 +1,128 @@
+package snapshots_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/database"
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/roles"
+	"swucol/snapshots"
+	"swucol/templates"
+)
+
+// createSnapshot sends a POST request to CreateSnapshotHandler and decodes the
+// created snapshot.
+func createSnapshot(t *testing.T, db *database.Database) models.CollectionSnapshot {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	snapshots.CreateSnapshotHandler(db)(recorder, httptest.NewRequest(http.MethodPost, "/snapshots", nil))
+	require.Equal(t, http.StatusCreated, recorder.Code)
+
+	var snapshot models.CollectionSnapshot
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&snapshot))
+
+	return snapshot
+}
+
+// compareSnapshots sends a GET request to CompareSnapshotsHandler for the
+// given raw snapshot ids.
+func compareSnapshots(t *testing.T, db *database.Database, rawFrom, rawTo string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/snapshots/%s/compare/%s", rawFrom, rawTo), nil)
+	request.SetPathValue("a", rawFrom)
+	request.SetPathValue("b", rawTo)
+	recorder := httptest.NewRecorder()
+
+	snapshots.CompareSnapshotsHandler(db)(recorder, request)
+
+	return recorder
+}
+
+func TestCompareSnapshotsHandler_ReturnsGainedAndLostBetweenSnapshots(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Luke Skywalker, Faithful Friend", Mainboard: true}))
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Darth Vader, Dark Lord of the Sith", Mainboard: true}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{"Darth Vader, Dark Lord of the Sith": 2}))
+
+	first := createSnapshot(t, db)
+	assert.Equal(t, 2, first.TotalOwned)
+
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{
+		"Luke Skywalker, Faithful Friend":    3,
+		"Darth Vader, Dark Lord of the Sith": 1,
+	}))
+	second := createSnapshot(t, db)
+	assert.Equal(t, 4, second.TotalOwned)
+
+	recorder := compareSnapshots(t, db, fmt.Sprint(first.ID), fmt.Sprint(second.ID))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var comparison snapshots.Comparison
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&comparison))
+	assert.Equal(t, first.ID, comparison.From.ID)
+	assert.Equal(t, second.ID, comparison.To.ID)
+	assert.Equal(t, []snapshots.Change{{Name: "Luke Skywalker, Faithful Friend", From: 0, To: 3, Delta: 3}}, comparison.Gained)
+	assert.Equal(t, []snapshots.Change{{Name: "Darth Vader, Dark Lord of the Sith", From: 2, To: 1, Delta: -1}}, comparison.Lost)
+}
+
+func TestCompareSnapshotsHandler_UnknownSnapshot_Returns404(t *testing.T) {
+	db := testdb.New(t)
+	snapshot := createSnapshot(t, db)
+
+	recorder := compareSnapshots(t, db, fmt.Sprint(snapshot.ID), "99")
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestCompareSnapshotsHandler_InvalidID_Returns400(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := compareSnapshots(t, db, "abc", "1")
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestHistoryHandler_RendersChartOfSnapshots(t *testing.T) {
+	db := testdb.New(t)
+	createSnapshot(t, db)
+	createSnapshot(t, db)
+
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	snapshots.HistoryHandler(db, tmpl, false)(recorder, httptest.NewRequest(http.MethodGet, "/history", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "<polyline")
+	assert.Contains(t, recorder.Body.String(), `class="level-0"`, "expected the activity heatmap")
+	assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+}
+
+func TestHistoryHandler_Viewer_HidesSnapshotButton(t *testing.T) {
+	db := testdb.New(t)
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	roles.Middleware(db, models.RoleViewer, snapshots.HistoryHandler(db, tmpl, false)).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/history", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), ".role-editor, .role-admin {")
+	assert.Contains(t, recorder.Body.String(), `class="snapshot-btn role-editor"`)
+
+	editor := httptest.NewRecorder()
+	roles.Middleware(db, models.RoleEditor, snapshots.HistoryHandler(db, tmpl, false)).ServeHTTP(editor, httptest.NewRequest(http.MethodGet, "/history", nil))
+
+	assert.NotContains(t, editor.Body.String(), ".role-editor, .role-admin {")
+}
@@ -0,0 +1,181 @@
+// Package snapshots compares point-in-time records of the collection's owned
+// counts and charts the collection's size and value over time.
+package snapshots
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"swucol/buylist"
+	"swucol/models"
+)
+
+// Change is a card whose owned count differs between two snapshots.
+type Change struct {
+	Name  string `json:"name"`
+	From  int    `json:"from"`
+	To    int    `json:"to"`
+	Delta int    `json:"delta"`
+}
+
+// Comparison lists the cards gained and lost between two snapshots, each
+// sorted by the size of the change, largest first.
+type Comparison struct {
+	From   models.CollectionSnapshot `json:"from"`
+	To     models.CollectionSnapshot `json:"to"`
+	Gained []Change                  `json:"gained"`
+	Lost   []Change                  `json:"lost"`
+}
+
+// Compare returns the per-card differences going from the counts in before to
+// the counts in after. A card missing from either map counts as zero owned.
+func Compare(before, after map[string]int) (gained, lost []Change) {
+	gained = make([]Change, 0)
+	lost = make([]Change, 0)
+
+	names := make(map[string]struct{}, len(before)+len(after))
+	for name := range before {
+		names[name] = struct{}{}
+	}
+	for name := range after {
+		names[name] = struct{}{}
+	}
+
+	for name := range names {
+		change := Change{Name: name, From: before[name], To: after[name]}
+		change.Delta = change.To - change.From
+		switch {
+		case change.Delta > 0:
+			gained = append(gained, change)
+		case change.Delta < 0:
+			lost = append(lost, change)
+		}
+	}
+
+	sortChanges(gained)
+	sortChanges(lost)
+
+	return gained, lost
+}
+
+// sortChanges orders changes by absolute delta, largest first, then by name.
+func sortChanges(changes []Change) {
+	sort.Slice(changes, func(i, j int) bool {
+		left, right := abs(changes[i].Delta), abs(changes[j].Delta)
+		if left != right {
+			return left > right
+		}
+		return changes[i].Name < changes[j].Name
+	})
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Chart dimensions, in SVG user units.
+const (
+	chartWidth   = 600
+	chartHeight  = 200
+	chartPadding = 20
+)
+
+// ChartPoint is one snapshot or valuation plotted on a chart, with Label as
+// its tooltip.
+type ChartPoint struct {
+	X, Y  float64
+	Label string
+}
+
+// Chart is a line chart of a total over time, laid out for an SVG viewBox of
+// Width by Height.
+type Chart struct {
+	Width, Height int
+	Points        []ChartPoint
+	// Polyline is the points attribute of the SVG polyline joining Points.
+	Polyline string
+	// Baseline is the y coordinate of a total of zero.
+	Baseline int
+	MaxTotal int
+	// MaxLabel is MaxTotal as shown at the top of the y axis.
+	MaxLabel string
+}
+
+// BuildChart lays out snapshots, which must be ordered oldest first, with time
+// on the x axis and total owned count on the y axis. The y axis starts at
+// zero so that growth is not exaggerated. Returns a chart with no points for
+// no snapshots.
+func BuildChart(snapshots []models.CollectionSnapshot) Chart {
+	times := make([]time.Time, len(snapshots))
+	totals := make([]int, len(snapshots))
+	labels := make([]string, len(snapshots))
+	for i, snapshot := range snapshots {
+		times[i], totals[i] = snapshot.TakenAt, snapshot.TotalOwned
+		labels[i] = fmt.Sprintf("%s: %d cards", snapshot.TakenAt.Local().Format("Jan 2 2006 15:04"), snapshot.TotalOwned)
+	}
+
+	chart := plot(times, totals, labels)
+	chart.MaxLabel = strconv.Itoa(chart.MaxTotal)
+	return chart
+}
+
+// BuildValuationChart lays out valuations, which must be ordered oldest
+// first, like BuildChart with the collection's value in cents on the y axis.
+func BuildValuationChart(valuations []models.Valuation) Chart {
+	times := make([]time.Time, len(valuations))
+	totals := make([]int, len(valuations))
+	labels := make([]string, len(valuations))
+	for i, valuation := range valuations {
+		times[i], totals[i] = valuation.ValuedAt, valuation.TotalCents
+		labels[i] = valuation.Month + ": " + buylist.FormatCents(valuation.TotalCents)
+	}
+
+	chart := plot(times, totals, labels)
+	chart.MaxLabel = buylist.FormatCents(chart.MaxTotal)
+	return chart
+}
+
+// plot lays out the points at times with the given totals and labels, which
+// must be ordered oldest first, with the y axis starting at zero.
+func plot(times []time.Time, totals []int, labels []string) Chart {
+	chart := Chart{Width: chartWidth, Height: chartHeight, Baseline: chartHeight - chartPadding}
+	if len(times) == 0 {
+		return chart
+	}
+
+	for _, total := range totals {
+		chart.MaxTotal = max(chart.MaxTotal, total)
+	}
+
+	first := times[0]
+	span := times[len(times)-1].Sub(first).Seconds()
+	plotWidth := float64(chartWidth - 2*chartPadding)
+	plotHeight := float64(chartHeight - 2*chartPadding)
+
+	coordinates := make([]string, 0, len(times))
+	for i, at := range times {
+		x := float64(chartPadding) + plotWidth/2
+		if span > 0 {
+			x = float64(chartPadding) + plotWidth*at.Sub(first).Seconds()/span
+		}
+
+		y := float64(chart.Baseline)
+		if chart.MaxTotal > 0 {
+			y -= plotHeight * float64(totals[i]) / float64(chart.MaxTotal)
+		}
+
+		chart.Points = append(chart.Points, ChartPoint{X: x, Y: y, Label: labels[i]})
+		coordinates = append(coordinates, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+
+	chart.Polyline = strings.Join(coordinates, " ")
+
+	return chart
+}
@@ -0,0 +1,77 @@
+package snapshots_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/models"
+	"swucol/snapshots"
+)
+
+func TestCompare_SplitsGainedAndLostAndIgnoresUnchanged(t *testing.T) {
+	before := map[string]int{"Luke": 1, "Vader": 4, "Leia": 2}
+	after := map[string]int{"Luke": 3, "Vader": 1, "Leia": 2, "Han": 1}
+
+	gained, lost := snapshots.Compare(before, after)
+
+	assert.Equal(t, []snapshots.Change{
+		{Name: "Luke", From: 1, To: 3, Delta: 2},
+		{Name: "Han", From: 0, To: 1, Delta: 1},
+	}, gained)
+	assert.Equal(t, []snapshots.Change{
+		{Name: "Vader", From: 4, To: 1, Delta: -3},
+	}, lost)
+}
+
+func TestCompare_IdenticalCounts_ReturnsEmptySlices(t *testing.T) {
+	counts := map[string]int{"Luke": 1}
+
+	gained, lost := snapshots.Compare(counts, counts)
+
+	assert.NotNil(t, gained)
+	assert.Empty(t, gained)
+	assert.NotNil(t, lost)
+	assert.Empty(t, lost)
+}
+
+func TestBuildChart_PlotsTimeAcrossAndTotalUpFromBaseline(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	chart := snapshots.BuildChart([]models.CollectionSnapshot{
+		{ID: 1, TakenAt: start, TotalOwned: 0},
+		{ID: 2, TakenAt: start.Add(24 * time.Hour), TotalOwned: 50},
+		{ID: 3, TakenAt: start.Add(48 * time.Hour), TotalOwned: 100},
+	})
+
+	require.Len(t, chart.Points, 3)
+	assert.Equal(t, 100, chart.MaxTotal)
+
+	assert.Equal(t, float64(chart.Baseline), chart.Points[0].Y, "zero total should sit on the baseline")
+	assert.Less(t, chart.Points[0].X, chart.Points[1].X)
+	assert.Less(t, chart.Points[1].X, chart.Points[2].X)
+	assert.Greater(t, chart.Points[0].Y, chart.Points[1].Y)
+	assert.Greater(t, chart.Points[1].Y, chart.Points[2].Y)
+	assert.InDelta(t, (chart.Points[0].X+chart.Points[2].X)/2, chart.Points[1].X, 0.01)
+	assert.NotEmpty(t, chart.Polyline)
+}
+
+func TestBuildChart_NoSnapshots_ReturnsEmptyChart(t *testing.T) {
+	chart := snapshots.BuildChart(nil)
+
+	assert.Empty(t, chart.Points)
+	assert.Empty(t, chart.Polyline)
+}
+
+func TestBuildValuationChart_LabelsValuesInCurrencyUnits(t *testing.T) {
+	chart := snapshots.BuildValuationChart([]models.Valuation{
+		{Month: "2026-01", ValuedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), TotalCents: 10050},
+		{Month: "2026-02", ValuedAt: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), TotalCents: 12000},
+	})
+
+	require.Len(t, chart.Points, 2)
+	assert.Equal(t, "2026-01: 100.50", chart.Points[0].Label)
+	assert.Equal(t, "120.00", chart.MaxLabel)
+	assert.Greater(t, chart.Points[0].Y, chart.Points[1].Y)
+}
@@ -0,0 +1,142 @@
+// Package snippet renders card lists as short plain-text snippets for pasting
+// into group chats, such as "3 Darth Vader, 2 Luke Skywalker". The snippets
+// are generated on the server so that the wishlist and cube pages, the API
+// and any other front end all produce the same text.
+package snippet
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"swucol/templates"
+)
+
+// Format is the layout of a snippet.
+type Format string
+
+const (
+	// FormatInline puts every card on one line, separated by commas:
+	// "3 Darth Vader, 2 Luke Skywalker".
+	FormatInline Format = "inline"
+	// FormatLines puts one "3x Darth Vader" card per line.
+	FormatLines Format = "lines"
+	// FormatMarkdown writes a Markdown bullet list, one "- 3x Darth Vader"
+	// card per line.
+	FormatMarkdown Format = "markdown"
+)
+
+// Formats lists the supported formats in the order the pages offer them.
+var Formats = []Format{FormatInline, FormatLines, FormatMarkdown}
+
+// ErrUnknownFormat is returned by ParseFormat for an unsupported format.
+var ErrUnknownFormat = errors.New("format must be one of: inline, lines, markdown")
+
+// ParseFormat returns the format named by raw, compared case-insensitively.
+// An empty raw is FormatInline.
+func ParseFormat(raw string) (Format, error) {
+	format := Format(strings.ToLower(strings.TrimSpace(raw)))
+	if format == "" {
+		return FormatInline, nil
+	}
+	for _, supported := range Formats {
+		if format == supported {
+			return format, nil
+		}
+	}
+	return "", ErrUnknownFormat
+}
+
+// Label returns the format's display name.
+func (format Format) Label() string {
+	switch format {
+	case FormatInline:
+		return "One line"
+	case FormatLines:
+		return "One card per line"
+	case FormatMarkdown:
+		return "Markdown list"
+	}
+	return string(format)
+}
+
+// Item is a card in a snippet with its number of copies.
+type Item struct {
+	Count int
+	Name  string
+}
+
+// Text formats items in format. Items without copies are left out.
+func Text(format Format, items []Item) string {
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.Count <= 0 {
+			continue
+		}
+		switch format {
+		case FormatLines:
+			parts = append(parts, fmt.Sprintf("%dx %s", item.Count, item.Name))
+		case FormatMarkdown:
+			parts = append(parts, fmt.Sprintf("- %dx %s", item.Count, item.Name))
+		default:
+			parts = append(parts, fmt.Sprintf("%d %s", item.Count, item.Name))
+		}
+	}
+
+	if format == FormatInline {
+		return strings.Join(parts, ", ")
+	}
+	return strings.Join(parts, "\n")
+}
+
+// fragment is the view model rendered by the snippet template.
+type fragment struct {
+	// Action is the path the format select reloads the fragment from, and
+	// Query the search query it passes along.
+	Action  string
+	Query   string
+	Format  Format
+	Formats []Format
+	Text    string
+}
+
+// Serve responds to request with items as a snippet in the format named by
+// the "format" query parameter. When tmpl is nil the snippet is written as
+// text/plain; otherwise it is rendered in the snippet fragment, whose format
+// select reloads it from action, a route such as "/wishlist/snippet/html",
+// with the request's "q" parameter. Responds 400 Bad Request for an unknown
+// format and 500 Internal Server Error when the template fails.
+func Serve(responseWriter http.ResponseWriter, request *http.Request, tmpl templates.Renderer, action string, items []Item) {
+	format, err := ParseFormat(request.URL.Query().Get("format"))
+	if err != nil {
+		templates.RenderError(responseWriter, request, tmpl, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	text := Text(format, items)
+
+	if tmpl == nil {
+		responseWriter.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := responseWriter.Write([]byte(text)); err != nil {
+			slog.WarnContext(request.Context(), "failed to write snippet", "error", err)
+		}
+		return
+	}
+
+	page := fragment{
+		Action:  action,
+		Query:   request.URL.Query().Get("q"),
+		Format:  format,
+		Formats: Formats,
+		Text:    text,
+	}
+
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.ExecuteTemplate(responseWriter, "snippet", page); err != nil {
+		slog.ErrorContext(request.Context(), "failed to render snippet template", "error", err)
+		http.Error(responseWriter, "template error", http.StatusInternalServerError)
+		return
+	}
+}
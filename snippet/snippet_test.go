@@ -0,0 +1,36 @@
+package snippet_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/snippet"
+)
+
+func TestText_FormatsEachLayout(t *testing.T) {
+	items := []snippet.Item{
+		{Count: 3, Name: "Darth Vader, Commanding the First Legion"},
+		{Count: 0, Name: "Han Solo"},
+		{Count: 2, Name: "Luke Skywalker, Faithful Friend"},
+	}
+
+	assert.Equal(t, "3 Darth Vader, Commanding the First Legion, 2 Luke Skywalker, Faithful Friend", snippet.Text(snippet.FormatInline, items))
+	assert.Equal(t, "3x Darth Vader, Commanding the First Legion\n2x Luke Skywalker, Faithful Friend", snippet.Text(snippet.FormatLines, items))
+	assert.Equal(t, "- 3x Darth Vader, Commanding the First Legion\n- 2x Luke Skywalker, Faithful Friend", snippet.Text(snippet.FormatMarkdown, items))
+	assert.Empty(t, snippet.Text(snippet.FormatInline, nil))
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := snippet.ParseFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, snippet.FormatInline, format)
+
+	format, err = snippet.ParseFormat(" Markdown ")
+	require.NoError(t, err)
+	assert.Equal(t, snippet.FormatMarkdown, format)
+
+	_, err = snippet.ParseFormat("csv")
+	assert.ErrorIs(t, err, snippet.ErrUnknownFormat)
+}
@@ -0,0 +1,53 @@
+// Package static serves the web app's static assets, which live alongside
+// this file: the web app manifest, icons and service worker that let the UI
+// be installed on a phone's home screen and open the cached page shell and
+// card images offline.
+package static
+
+import (
+	"net/http"
+	"path/filepath"
+	"slices"
+)
+
+// names are the asset files served under GET /static/{name}. The service
+// worker, sw.js, is served at the app root instead, so that its scope covers
+// every page.
+var names = []string{"manifest.json", "icon.svg", "icon-192.png", "icon-512.png"}
+
+// serviceWorker is the name of the service worker script.
+const serviceWorker = "sw.js"
+
+// Handler returns an http.HandlerFunc that handles GET /static/{name},
+// serving the named asset from dir. Returns 404 Not Found for any other name.
+// Assets are served with Cache-Control: no-cache, so browsers revalidate them
+// against their modification time and pick up a new release.
+func Handler(dir string) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		name := request.PathValue("name")
+		if !slices.Contains(names, name) {
+			http.NotFound(responseWriter, request)
+			return
+		}
+
+		serve(responseWriter, request, filepath.Join(dir, name))
+	}
+}
+
+// ServiceWorkerHandler returns an http.HandlerFunc that handles GET /sw.js,
+// serving the service worker script from dir.
+func ServiceWorkerHandler(dir string) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		serve(responseWriter, request, filepath.Join(dir, serviceWorker))
+	}
+}
+
+// serve writes the file at path, which http.ServeFile answers conditionally
+// from its modification time.
+func serve(responseWriter http.ResponseWriter, request *http.Request, path string) {
+	if filepath.Ext(path) == ".json" {
+		responseWriter.Header().Set("Content-Type", "application/manifest+json")
+	}
+	responseWriter.Header().Set("Cache-Control", "no-cache")
+	http.ServeFile(responseWriter, request, path)
+}
@@ -0,0 +1,64 @@
+package static_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/static"
+)
+
+// get requests /static/{name} from static.Handler serving this directory.
+func get(t *testing.T, name string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /static/{name}", static.Handler("."))
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/static/"+name, nil))
+	return recorder
+}
+
+func TestHandler_ServesManifestWithItsIcons(t *testing.T) {
+	recorder := get(t, "manifest.json")
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/manifest+json", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "no-cache", recorder.Header().Get("Cache-Control"))
+
+	var manifest struct {
+		StartURL string `json:"start_url"`
+		Icons    []struct {
+			Src  string `json:"src"`
+			Type string `json:"type"`
+		} `json:"icons"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &manifest))
+	assert.Equal(t, "../", manifest.StartURL)
+	require.NotEmpty(t, manifest.Icons)
+
+	for _, icon := range manifest.Icons {
+		iconRecorder := get(t, icon.Src)
+		assert.Equal(t, http.StatusOK, iconRecorder.Code, icon.Src)
+		assert.Equal(t, icon.Type, iconRecorder.Header().Get("Content-Type"), icon.Src)
+	}
+}
+
+func TestHandler_RejectsOtherFiles(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, get(t, "static.go").Code)
+	assert.Equal(t, http.StatusNotFound, get(t, "sw.js").Code)
+}
+
+func TestServiceWorkerHandler_ServesScript(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	static.ServiceWorkerHandler(".")(recorder, httptest.NewRequest(http.MethodGet, "/sw.js", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/javascript; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), `addEventListener("fetch"`)
+}
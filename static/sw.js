@@ -0,0 +1,108 @@
+// Service worker for the installed web app. It keeps the page shell and the
+// card images in the Cache Storage so that the collection still opens, and
+// shows the cards already seen, without a connection. Every URL is relative
+// to this script, which is served at the app root so that its scope covers
+// every page.
+
+// SHELL_CACHE holds the pages and static assets, and is replaced whenever
+// SHELL_VERSION changes; IMAGE_CACHE holds the card images, which never
+// change once stored.
+const SHELL_VERSION = "v1";
+const SHELL_CACHE = "swucol-shell-" + SHELL_VERSION;
+const IMAGE_CACHE = "swucol-images";
+
+// SHELL_URLS are cached on install: the collection page and the assets every
+// page links to.
+const SHELL_URLS = [
+	"./",
+	"static/manifest.json",
+	"static/icon.svg",
+	"static/icon-192.png",
+	"static/icon-512.png",
+];
+
+const imagesPath = new URL("images/", self.registration.scope).pathname;
+const shellURL = new URL("./", self.registration.scope).href;
+
+self.addEventListener("install", (event) => {
+	event.waitUntil(
+		caches.open(SHELL_CACHE)
+			.then((cache) => cache.addAll(SHELL_URLS))
+			.then(() => self.skipWaiting())
+	);
+});
+
+self.addEventListener("activate", (event) => {
+	event.waitUntil(
+		caches.keys()
+			.then((keys) => Promise.all(keys
+				.filter((key) => key.startsWith("swucol-shell-") && key !== SHELL_CACHE)
+				.map((key) => caches.delete(key))))
+			.then(() => self.clients.claim())
+	);
+});
+
+self.addEventListener("fetch", (event) => {
+	const request = event.request;
+	if (request.method !== "GET") {
+		return;
+	}
+
+	const url = new URL(request.url);
+	if (url.origin === self.location.origin && url.pathname.startsWith(imagesPath)) {
+		event.respondWith(cacheFirst(request));
+		return;
+	}
+
+	if (request.mode === "navigate") {
+		event.respondWith(networkFirst(request));
+	}
+});
+
+// A background sync registered by a page that queued owned count changes
+// offline fires once the connection returns, even if the page is in the
+// background; the open pages hold the queue and replay it.
+self.addEventListener("sync", (event) => {
+	if (event.tag === "owned-queue") {
+		event.waitUntil(
+			self.clients.matchAll({type: "window"})
+				.then((clients) => clients.forEach((client) => client.postMessage("flush-owned-queue")))
+		);
+	}
+});
+
+// cacheFirst answers with the cached image, fetching and caching it when it
+// is not cached yet.
+async function cacheFirst(request) {
+	const cache = await caches.open(IMAGE_CACHE);
+	const cached = await cache.match(request);
+	if (cached) {
+		return cached;
+	}
+
+	const response = await fetch(request);
+	if (response.ok) {
+		cache.put(request, response.clone());
+	}
+	return response;
+}
+
+// networkFirst answers a page load from the network, caching the page, and
+// falls back to the cached copy of the page, then to the cached collection
+// page, when offline.
+async function networkFirst(request) {
+	const cache = await caches.open(SHELL_CACHE);
+	try {
+		const response = await fetch(request);
+		if (response.ok) {
+			cache.put(request, response.clone());
+		}
+		return response;
+	} catch (err) {
+		const cached = await cache.match(request) || await cache.match(shellURL);
+		if (cached) {
+			return cached;
+		}
+		throw err;
+	}
+}
@@ -0,0 +1,62 @@
+package templates
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// errorPage is the view model rendered by the error template.
+type errorPage struct {
+	Status  int
+	Title   string
+	Message string
+}
+
+// wantsPage reports whether request is a browser page load, which accepts
+// HTML and was not sent by htmx. htmx requests, API clients and scripts get
+// errors as plain text.
+func wantsPage(request *http.Request) bool {
+	if request.Header.Get("HX-Request") == "true" {
+		return false
+	}
+
+	return strings.Contains(request.Header.Get("Accept"), "text/html")
+}
+
+// RenderError replies to request with message and statusCode, like
+// http.Error. Browser page loads get the error template, a full page showing
+// the status and message with a link back to the collection; other requests,
+// and any request when tmpl is nil or the error template fails, get
+// message as plain text.
+func RenderError(responseWriter http.ResponseWriter, request *http.Request, tmpl Renderer, message string, statusCode int) {
+	if tmpl == nil || !wantsPage(request) {
+		http.Error(responseWriter, message, statusCode)
+		return
+	}
+
+	page := errorPage{Status: statusCode, Title: http.StatusText(statusCode), Message: message}
+
+	var body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&body, "error", page); err != nil {
+		slog.ErrorContext(request.Context(), "failed to render error template", "status", statusCode, "error", err)
+		http.Error(responseWriter, message, statusCode)
+		return
+	}
+
+	responseWriter.Header().Del("Content-Length")
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	responseWriter.Header().Set("X-Content-Type-Options", "nosniff")
+	responseWriter.WriteHeader(statusCode)
+	responseWriter.Write(body.Bytes())
+}
+
+// NotFoundHandler returns an http.HandlerFunc that answers requests for
+// unknown routes with 404 Not Found, rendered by RenderError.
+func NotFoundHandler(tmpl Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		slog.InfoContext(request.Context(), "unknown route requested", "method", request.Method, "path", request.URL.Path)
+		RenderError(responseWriter, request, tmpl, "The page "+request.URL.Path+" does not exist.", http.StatusNotFound)
+	}
+}
@@ -0,0 +1,219 @@
+// Package templates loads the application's HTML templates, which live
+// alongside this file, together with the functions they call.
+package templates
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"swucol/i18n"
+	"swucol/version"
+)
+
+// Renderer executes the template named name with data. *template.Template is
+// a Renderer, and so is the reloading one ParseTemplates returns in dev mode,
+// so handlers take a Renderer rather than a *template.Template.
+type Renderer interface {
+	ExecuteTemplate(writer io.Writer, name string, data any) error
+}
+
+// aspectSymbols are the symbols aspectIcon shows for each aspect, keyed by
+// its lower-case name.
+var aspectSymbols = map[string]string{
+	"vigilance":  "◆",
+	"command":    "■",
+	"aggression": "▲",
+	"cunning":    "●",
+	"heroism":    "✦",
+	"villainy":   "✖",
+}
+
+// Funcs returns the functions available to the templates:
+//
+//   - path joins its arguments into a URL path and prefixes it with basePath,
+//     so that links keep working when the app is served under a sub-path such
+//     as /swucol. Every absolute link in the templates goes through it.
+//   - imageURL returns the URL of a stored image path, such as a card's Image
+//     or Thumbnail, under basePath, or "" for an empty path. The path is
+//     served as is; ParseTemplates can map it to another, such as a content
+//     hashed one.
+//   - version returns the running build, as shown in the page footer.
+//   - t returns the UI string for a message key in a language, as chosen by
+//     i18n.FromRequest and passed in the page's view model.
+//   - pluralize returns a count followed by a noun, adding an "s" unless the
+//     count is 1 or using the plural given as a third argument: {{pluralize
+//     .Total "card"}} renders "1 card" or "3 cards".
+//   - formatPrice formats a price in cents with two decimal places, such as
+//     1.05.
+//   - aspectIcon renders an aspect as a symbol in a span classed
+//     aspect-icon and aspect-<name>, which the index page colours, titled
+//     with its name; unknown aspects are rendered as their name.
+func Funcs(basePath string) template.FuncMap {
+	return funcs(basePath, nil)
+}
+
+// funcs is Funcs with imageURL serving each image path at imagePath's
+// result, or as is when imagePath is nil.
+func funcs(basePath string, imagePath func(string) string) template.FuncMap {
+	return template.FuncMap{
+		"path": func(parts ...any) string {
+			return basePath + fmt.Sprint(parts...)
+		},
+		"imageURL": func(storedPath string) string {
+			if storedPath == "" {
+				return ""
+			}
+			if imagePath != nil {
+				storedPath = imagePath(storedPath)
+			}
+			return basePath + "/" + storedPath
+		},
+		"version": func() string {
+			return version.Get().String()
+		},
+		"t":           i18n.Translate,
+		"pluralize":   pluralize,
+		"formatPrice": formatPrice,
+		"aspectIcon":  aspectIcon,
+	}
+}
+
+// pluralize returns count followed by singular, or by plural (by default
+// singular with an "s") unless count is 1.
+func pluralize(count int, singular string, plural ...string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, singular)
+	}
+	if len(plural) > 0 {
+		return fmt.Sprintf("%d %s", count, plural[0])
+	}
+	return fmt.Sprintf("%d %ss", count, singular)
+}
+
+// formatPrice formats cents with two decimal places.
+func formatPrice(cents int) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
+
+// aspectIcon returns the symbol of aspect in a span classed with its name.
+func aspectIcon(aspect string) template.HTML {
+	name := strings.ToLower(strings.TrimSpace(aspect))
+	symbol, ok := aspectSymbols[name]
+	if !ok {
+		return template.HTML(template.HTMLEscapeString(aspect))
+	}
+
+	return template.HTML(fmt.Sprintf(`<span class="aspect-icon aspect-%s" title="%s">%s</span>`, name, template.HTMLEscapeString(aspect), symbol))
+}
+
+// ParseGlob parses the templates matching pattern with Funcs(basePath).
+func ParseGlob(pattern, basePath string) (*template.Template, error) {
+	return parseGlob(pattern, basePath, nil)
+}
+
+// parseGlob parses the templates matching pattern with funcs(basePath,
+// imagePath).
+func parseGlob(pattern, basePath string, imagePath func(string) string) (*template.Template, error) {
+	tmpl, err := template.New("").Funcs(funcs(basePath, imagePath)).ParseGlob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("parse templates: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// ParseTemplates parses the templates matching pattern with Funcs(basePath),
+// except that imageURL serves each image at the path imagePath returns for
+// it, such as images.Hashes.HashedPath; a nil imagePath serves images as is.
+// In dev mode the Renderer parses them again before rendering whenever a
+// matching file has been added, removed or modified, so that template edits
+// show on the next page load without a restart; a template that no longer
+// parses fails the render with the parse error.
+func ParseTemplates(pattern, basePath string, dev bool, imagePath func(string) string) (Renderer, error) {
+	if !dev {
+		tmpl, err := parseGlob(pattern, basePath, imagePath)
+		if err != nil {
+			return nil, err
+		}
+		return tmpl, nil
+	}
+
+	reloader := &reloader{pattern: pattern, basePath: basePath, imagePath: imagePath}
+	if _, err := reloader.current(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// reloader is the dev mode Renderer, parsing the templates again when they
+// change.
+type reloader struct {
+	pattern   string
+	basePath  string
+	imagePath func(string) string
+
+	mutex sync.Mutex
+	tmpl  *template.Template
+	// stamp describes the template files tmpl was parsed from.
+	stamp string
+}
+
+// ExecuteTemplate implements Renderer.
+func (reloader *reloader) ExecuteTemplate(writer io.Writer, name string, data any) error {
+	tmpl, err := reloader.current()
+	if err != nil {
+		return err
+	}
+
+	return tmpl.ExecuteTemplate(writer, name, data)
+}
+
+// current returns the templates, parsing them again if their files changed
+// since they were last parsed.
+func (reloader *reloader) current() (*template.Template, error) {
+	stamp, err := filesStamp(reloader.pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+
+	if reloader.tmpl != nil && stamp == reloader.stamp {
+		return reloader.tmpl, nil
+	}
+
+	tmpl, err := parseGlob(reloader.pattern, reloader.basePath, reloader.imagePath)
+	if err != nil {
+		return nil, err
+	}
+	reloader.tmpl, reloader.stamp = tmpl, stamp
+
+	return tmpl, nil
+}
+
+// filesStamp returns the names, sizes and modification times of the files
+// matching pattern, which change whenever one of them does.
+func filesStamp(pattern string) (string, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", fmt.Errorf("list templates: %w", err)
+	}
+
+	var stamp strings.Builder
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("stat template: %w", err)
+		}
+		fmt.Fprintf(&stamp, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+	}
+
+	return stamp.String(), nil
+}
@@ -0,0 +1,161 @@
+package templates_test
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/templates"
+)
+
+// render parses text with templates.Funcs("/swucol") and executes it with
+// data.
+func render(t *testing.T, text string, data any) string {
+	t.Helper()
+
+	tmpl, err := template.New("test").Funcs(templates.Funcs("/swucol")).Parse(text)
+	require.NoError(t, err)
+
+	var output strings.Builder
+	require.NoError(t, tmpl.Execute(&output, data))
+	return output.String()
+}
+
+func TestFuncs_Pluralize(t *testing.T) {
+	assert.Equal(t, "0 cards", render(t, `{{pluralize 0 "card"}}`, nil))
+	assert.Equal(t, "1 card", render(t, `{{pluralize 1 "card"}}`, nil))
+	assert.Equal(t, "3 cards", render(t, `{{pluralize 3 "card"}}`, nil))
+	assert.Equal(t, "2 copies", render(t, `{{pluralize 2 "copy" "copies"}}`, nil))
+}
+
+func TestFuncs_FormatPrice(t *testing.T) {
+	assert.Equal(t, "0.00", render(t, `{{formatPrice 0}}`, nil))
+	assert.Equal(t, "1.05", render(t, `{{formatPrice 105}}`, nil))
+	assert.Equal(t, "123.40", render(t, `{{formatPrice 12340}}`, nil))
+}
+
+func TestFuncs_AspectIcon(t *testing.T) {
+	assert.Equal(t, `<span class="aspect-icon aspect-heroism" title="Heroism">✦</span>`, render(t, `{{aspectIcon "Heroism"}}`, nil))
+	assert.Equal(t, "Force &amp; Fury", render(t, `{{aspectIcon .}}`, "Force & Fury"))
+}
+
+func TestFuncs_ImageURL(t *testing.T) {
+	assert.Equal(t, "/swucol/images/SOR-001.png", render(t, `{{imageURL "images/SOR-001.png"}}`, nil))
+	assert.Equal(t, "", render(t, `{{imageURL ""}}`, nil))
+}
+
+func TestParseTemplates_DevModeReloadsChangedTemplates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.html")
+	require.NoError(t, os.WriteFile(path, []byte(`{{define "page"}}before{{end}}`), 0o644))
+
+	renderer, err := templates.ParseTemplates(filepath.Join(dir, "*.html"), "", true, nil)
+	require.NoError(t, err)
+
+	var output strings.Builder
+	require.NoError(t, renderer.ExecuteTemplate(&output, "page", nil))
+	assert.Equal(t, "before", output.String())
+
+	require.NoError(t, os.WriteFile(path, []byte(`{{define "page"}}after{{end}}`), 0o644))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+
+	output.Reset()
+	require.NoError(t, renderer.ExecuteTemplate(&output, "page", nil))
+	assert.Equal(t, "after", output.String())
+}
+
+func TestParseTemplates_ImageURLUsesImagePath(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "page.html"), []byte(`{{define "page"}}{{imageURL .}}{{end}}`), 0o644))
+
+	hashed := func(storedPath string) string { return "images/0123456789abcdef.png" }
+	renderer, err := templates.ParseTemplates(filepath.Join(dir, "*.html"), "/swucol", false, hashed)
+	require.NoError(t, err)
+
+	var output strings.Builder
+	require.NoError(t, renderer.ExecuteTemplate(&output, "page", "images/SOR-001.png"))
+	assert.Equal(t, "/swucol/images/0123456789abcdef.png", output.String())
+}
+
+func TestParseTemplates_WithoutDevModeKeepsParsedTemplates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.html")
+	require.NoError(t, os.WriteFile(path, []byte(`{{define "page"}}before{{end}}`), 0o644))
+
+	renderer, err := templates.ParseTemplates(filepath.Join(dir, "*.html"), "", false, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{{define "page"}}after{{end}}`), 0o644))
+
+	var output strings.Builder
+	require.NoError(t, renderer.ExecuteTemplate(&output, "page", nil))
+	assert.Equal(t, "before", output.String())
+}
+
+// parseAll parses the application's templates with an empty base path.
+func parseAll(t *testing.T) *template.Template {
+	t.Helper()
+
+	tmpl, err := templates.ParseGlob("*.html", "")
+	require.NoError(t, err)
+	return tmpl
+}
+
+func TestRenderError_RendersErrorPageForBrowsers(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/cubes/7", nil)
+	request.Header.Set("Accept", "text/html,application/xhtml+xml")
+	recorder := httptest.NewRecorder()
+
+	templates.RenderError(recorder, request, parseAll(t), "cube not found", http.StatusNotFound)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+	body := recorder.Body.String()
+	assert.Contains(t, body, "<title>404 Not Found")
+	assert.Contains(t, body, `<p class="error-message">cube not found</p>`)
+	assert.Contains(t, body, `<a href="/">Back to the collection</a>`)
+}
+
+func TestRenderError_WritesPlainTextOtherwise(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		tmpl   templates.Renderer
+	}{
+		{name: "api client", header: http.Header{"Accept": {"application/json"}}, tmpl: parseAll(t)},
+		{name: "htmx", header: http.Header{"Accept": {"text/html"}, "Hx-Request": {"true"}}, tmpl: parseAll(t)},
+		{name: "no templates", header: http.Header{"Accept": {"text/html"}}},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/cubes/7", nil)
+		request.Header = tt.header
+		recorder := httptest.NewRecorder()
+
+		templates.RenderError(recorder, request, tt.tmpl, "cube not found", http.StatusNotFound)
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code, tt.name)
+		assert.Equal(t, "text/plain; charset=utf-8", recorder.Header().Get("Content-Type"), tt.name)
+		assert.Equal(t, "cube not found\n", recorder.Body.String(), tt.name)
+	}
+}
+
+func TestNotFoundHandler_NamesUnknownPath(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/no/such/page", nil)
+	request.Header.Set("Accept", "text/html")
+	recorder := httptest.NewRecorder()
+
+	templates.NotFoundHandler(parseAll(t))(recorder, request)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "The page /no/such/page does not exist.")
+}
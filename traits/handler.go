@@ -0,0 +1,56 @@
+package traits
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"swucol/database"
+	"swucol/models"
+	"swucol/templates"
+)
+
+// traitsPage is the view model rendered by the traits template.
+type traitsPage struct {
+	Traits []database.TraitCount
+	Trait  string
+	Cards  []models.Card
+	Theme  string
+}
+
+// PageHandler returns an http.HandlerFunc that serves the traits browser at
+// GET /traits: every trait of a non-archived card with how many cards have it
+// and how many of those are owned, and, with the optional "trait" query
+// parameter, the cards sharing that trait. Traits come from the catalog, so
+// the page is empty until a set has been imported from it. Returns 500
+// Internal Server Error if the database query or template rendering fails.
+func PageHandler(db *database.Database, tmpl templates.Renderer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		trait := strings.ToUpper(strings.TrimSpace(request.URL.Query().Get("trait")))
+
+		counts, err := db.GetTraitCounts()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error loading trait counts", "error", err)
+			templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		page := traitsPage{Traits: counts, Trait: trait, Theme: db.Settings().Theme}
+
+		if trait != "" {
+			page.Cards, err = db.GetCardsByTrait(trait)
+			if err != nil {
+				slog.ErrorContext(request.Context(), "database error loading cards by trait", "trait", trait, "error", err)
+				templates.RenderError(responseWriter, request, tmpl, "database error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(responseWriter, "traits", page); err != nil {
+			slog.ErrorContext(request.Context(), "failed to render traits template", "error", err)
+			http.Error(responseWriter, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package traits_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/templates"
+	"swucol/traits"
+)
+
+func TestPageHandler_ListsTraitsAndCardsSharingOne(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCards([]models.NewCard{
+		{Name: "Battlefield Marine", Type: "Unit"},
+		{Name: "Alliance X-Wing", Type: "Unit"},
+		{Name: "Death Trooper", Type: "Unit"},
+	}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{"Battlefield Marine": 2}))
+	_, err := db.SetCardAttributes(map[string]models.CardAttributes{
+		"Battlefield Marine": {Traits: "Rebel|Trooper"},
+		"Alliance X-Wing":    {Traits: "Rebel|Vehicle|Fighter"},
+		"Death Trooper":      {Traits: "Imperial|Trooper"},
+	})
+	require.NoError(t, err)
+
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	traits.PageHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/traits?trait=rebel", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, `href="/traits?trait=IMPERIAL"`)
+	assert.Contains(t, body, `REBEL <span class="trait-count">1/2</span>`)
+	assert.Contains(t, body, "Battlefield Marine")
+	assert.Contains(t, body, "Alliance X-Wing")
+	assert.NotContains(t, body, "Death Trooper")
+}
+
+func TestPageHandler_NoTraits_ShowsHint(t *testing.T) {
+	db := testdb.New(t)
+
+	tmpl, err := templates.ParseGlob("../templates/*.html", "")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	traits.PageHandler(db, tmpl)(recorder, httptest.NewRequest(http.MethodGet, "/traits", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "No traits yet")
+}
@@ -0,0 +1,58 @@
+package valuation
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"swucol/database"
+)
+
+// ListHandler returns an http.HandlerFunc that handles GET /valuations.
+// Returns 200 OK with every valuation as JSON, oldest month first, and 500
+// Internal Server Error for database errors.
+func ListHandler(db *database.Database) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		valuations, err := db.GetValuations()
+		if err != nil {
+			slog.ErrorContext(request.Context(), "database error listing valuations", "error", err)
+			http.Error(responseWriter, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(valuations); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode valuations response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// CreateHandler returns an http.HandlerFunc that handles POST /valuations,
+// valuing the collection now and replacing the current month's valuation.
+// valuer is nil when no market price source is configured. Returns 201
+// Created with the valuation as JSON, 409 Conflict when valuer is nil, and
+// 502 Bad Gateway when the market prices cannot be fetched or stored.
+func CreateHandler(valuer *Valuer) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if valuer == nil {
+			http.Error(responseWriter, "no market price source configured; set -market-prices", http.StatusConflict)
+			return
+		}
+
+		valuation, err := valuer.Value(request.Context(), time.Now())
+		if err != nil {
+			slog.ErrorContext(request.Context(), "collection valuation failed", "error", err)
+			http.Error(responseWriter, "valuation failed: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(responseWriter).Encode(valuation); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode valuation response", "error", err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Package valuation records the collection's market value once a month:
+// every owned copy times its market price, from a price list served in the
+// same JSON format as vendor buylists.
+package valuation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"swucol/buylist"
+	"swucol/database"
+	"swucol/models"
+)
+
+// monthLayout formats a valuation's month.
+const monthLayout = "2006-01"
+
+// Valuer values the collection against the market price list at a URL.
+type Valuer struct {
+	db         *database.Database
+	httpClient *http.Client
+	source     buylist.Vendor
+}
+
+// NewValuer returns a Valuer that fetches market prices from priceURL with
+// httpClient. Returns an error if priceURL is not an absolute http or https
+// URL.
+func NewValuer(db *database.Database, httpClient *http.Client, priceURL string) (*Valuer, error) {
+	parsed, err := url.Parse(priceURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("market price source %q is not an http or https URL", priceURL)
+	}
+
+	return &Valuer{db: db, httpClient: httpClient, source: buylist.Vendor{Name: "market", URL: priceURL}}, nil
+}
+
+// Value fetches the market prices, values the collection and saves the
+// result as the valuation of now's month, replacing any it had.
+func (valuer *Valuer) Value(ctx context.Context, now time.Time) (models.Valuation, error) {
+	prices, err := buylist.Fetch(ctx, valuer.httpClient, valuer.source)
+	if err != nil {
+		return models.Valuation{}, err
+	}
+
+	cards, err := valuer.db.GetAllCards()
+	if err != nil {
+		return models.Valuation{}, err
+	}
+
+	valuation := Compute(cards, prices)
+	valuation.Month = now.UTC().Format(monthLayout)
+	valuation.ValuedAt = now
+
+	saved, err := valuer.db.SaveValuation(valuation)
+	if err != nil {
+		return models.Valuation{}, err
+	}
+
+	slog.InfoContext(ctx, "collection valued", "month", saved.Month, "total_cents", saved.TotalCents, "unpriced_cards", saved.UnpricedCards)
+
+	return saved, nil
+}
+
+// RecordIfDue values the collection when now's month has no valuation yet.
+// Returns whether a valuation was recorded.
+func (valuer *Valuer) RecordIfDue(ctx context.Context, now time.Time) (bool, error) {
+	recorded, err := valuer.db.HasValuation(now.UTC().Format(monthLayout))
+	if err != nil || recorded {
+		return false, err
+	}
+
+	if _, err := valuer.Value(ctx, now); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Compute sums owned copies times market price over the owned cards of
+// released sets, matching prices as buylist.PriceIndex does. Owned cards
+// without a price count as unpriced and add nothing to the total.
+func Compute(cards []models.Card, prices []models.BuylistPrice) models.Valuation {
+	index := buylist.NewPriceIndex(prices)
+
+	var valuation models.Valuation
+	for _, card := range cards {
+		if card.Owned <= 0 || card.Unreleased {
+			continue
+		}
+
+		cents, ok := index.Lookup(card)
+		if !ok {
+			valuation.UnpricedCards++
+			continue
+		}
+
+		valuation.PricedCards++
+		valuation.TotalCents += cents * card.Owned
+	}
+
+	return valuation
+}
@@ -0,0 +1,105 @@
+package valuation_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/internal/testdb"
+	"swucol/models"
+	"swucol/valuation"
+)
+
+// newPriceServer serves a market price list in the buylist JSON format.
+func newPriceServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.Write([]byte(`[{"name": "Battlefield Marine", "set": "SOR", "number": "095", "price": 0.10}, {"name": "Darth Vader, Dark Lord of the Sith", "price": 12.5}]`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestCompute_SumsOwnedTimesPrice(t *testing.T) {
+	cards := []models.Card{
+		{Name: "Battlefield Marine", Set: "SOR", Number: "095", Owned: 4},
+		{Name: "Darth Vader, Dark Lord of the Sith", Set: "SOR", Number: "010", Owned: 2},
+		{Name: "Admiral Ackbar", Set: "SOR", Number: "200", Owned: 1},
+		{Name: "Unowned", Set: "SOR", Number: "001"},
+		{Name: "Battlefield Marine", Set: "NEW", Number: "095", Owned: 3, Unreleased: true},
+	}
+	prices := []models.BuylistPrice{
+		{Name: "Battlefield Marine", Set: "SOR", Number: "095", PriceCents: 10},
+		{Name: "Darth Vader, Dark Lord of the Sith", PriceCents: 1250},
+	}
+
+	result := valuation.Compute(cards, prices)
+
+	assert.Equal(t, 2540, result.TotalCents)
+	assert.Equal(t, 2, result.PricedCards)
+	assert.Equal(t, 1, result.UnpricedCards, "unowned and unreleased cards are not counted")
+}
+
+func TestRecordIfDue_ValuesEachMonthOnce(t *testing.T) {
+	db := testdb.New(t)
+	require.NoError(t, db.InsertCard(models.NewCard{Name: "Battlefield Marine", Set: "SOR", Number: "095"}))
+	require.NoError(t, db.SetOwnedCountsByName(map[string]int{"Battlefield Marine": 5}))
+
+	valuer, err := valuation.NewValuer(db, http.DefaultClient, newPriceServer(t).URL)
+	require.NoError(t, err)
+
+	march := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	recorded, err := valuer.RecordIfDue(context.Background(), march)
+	require.NoError(t, err)
+	assert.True(t, recorded)
+
+	recorded, err = valuer.RecordIfDue(context.Background(), march.AddDate(0, 0, 20))
+	require.NoError(t, err)
+	assert.False(t, recorded, "a month is valued once")
+
+	recorded, err = valuer.RecordIfDue(context.Background(), march.AddDate(0, 1, 0))
+	require.NoError(t, err)
+	assert.True(t, recorded)
+
+	valuations, err := db.GetValuations()
+	require.NoError(t, err)
+	require.Len(t, valuations, 2)
+	assert.Equal(t, "2026-03", valuations[0].Month)
+	assert.Equal(t, 50, valuations[0].TotalCents)
+	assert.Equal(t, "2026-04", valuations[1].Month)
+
+	_, err = valuation.NewValuer(db, http.DefaultClient, "prices.json")
+	assert.Error(t, err)
+}
+
+func TestCreateHandler_ValuesNowOrReportsMissingSource(t *testing.T) {
+	db := testdb.New(t)
+
+	recorder := httptest.NewRecorder()
+	valuation.CreateHandler(nil)(recorder, httptest.NewRequest(http.MethodPost, "/valuations", nil))
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	valuer, err := valuation.NewValuer(db, http.DefaultClient, newPriceServer(t).URL)
+	require.NoError(t, err)
+
+	recorder = httptest.NewRecorder()
+	valuation.CreateHandler(valuer)(recorder, httptest.NewRequest(http.MethodPost, "/valuations", nil))
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+
+	listRecorder := httptest.NewRecorder()
+	valuation.ListHandler(db)(listRecorder, httptest.NewRequest(http.MethodGet, "/valuations", nil))
+
+	require.Equal(t, http.StatusOK, listRecorder.Code)
+	var valuations []models.Valuation
+	require.NoError(t, json.Unmarshal(listRecorder.Body.Bytes(), &valuations))
+	assert.Len(t, valuations, 1)
+}
@@ -0,0 +1,112 @@
+// Package version reports which build of swucol is running. Release builds
+// set Version, Commit and BuildDate with the linker:
+//
+//	go build -ldflags "-X swucol/version.Version=v1.2.0 -X swucol/version.Commit=$(git rev-parse HEAD) -X swucol/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// `make build` does this. Builds without the flags fall back to the VCS
+// details the go command records in the binary.
+package version
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Set by -ldflags "-X ..." at build time.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// shortCommitLength is how many characters of the commit String shows.
+const shortCommitLength = 12
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the running build's Info. Commit and BuildDate not set by the
+// linker are taken from the vcs.revision and vcs.time build settings, with a
+// "-dirty" suffix on the commit when the tree had uncommitted changes.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	var revision, revisionTime string
+	var modified bool
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.time":
+			revisionTime = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+
+	if info.Commit == "" && revision != "" {
+		info.Commit = revision
+		if modified {
+			info.Commit += "-dirty"
+		}
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = revisionTime
+	}
+
+	return info
+}
+
+// String formats info for display, such as "v1.2.0 (0123456789ab,
+// 2026-01-02T15:04:05Z)". Missing details are left out.
+func (info Info) String() string {
+	var details []string
+	if info.Commit != "" {
+		commit := info.Commit
+		if len(commit) > shortCommitLength {
+			commit = commit[:shortCommitLength]
+		}
+		details = append(details, commit)
+	}
+	if info.BuildDate != "" {
+		details = append(details, info.BuildDate)
+	}
+
+	if len(details) == 0 {
+		return info.Version
+	}
+
+	return info.Version + " (" + strings.Join(details, ", ") + ")"
+}
+
+// Handler returns an http.HandlerFunc that handles GET /version. Returns 200
+// OK with the running build's Info as JSON.
+func Handler() http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(responseWriter).Encode(Get()); err != nil {
+			slog.ErrorContext(request.Context(), "failed to encode version response", "error", err)
+			http.Error(responseWriter, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
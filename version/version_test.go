@@ -0,0 +1,50 @@
+package version_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"swucol/version"
+)
+
+func TestGet_UsesLinkerValues(t *testing.T) {
+	original := []string{version.Version, version.Commit, version.BuildDate}
+	t.Cleanup(func() {
+		version.Version, version.Commit, version.BuildDate = original[0], original[1], original[2]
+	})
+	version.Version = "v1.2.0"
+	version.Commit = "0123456789abcdef0123456789abcdef01234567"
+	version.BuildDate = "2026-01-02T15:04:05Z"
+
+	info := version.Get()
+
+	assert.Equal(t, version.Info{
+		Version:   "v1.2.0",
+		Commit:    "0123456789abcdef0123456789abcdef01234567",
+		BuildDate: "2026-01-02T15:04:05Z",
+		GoVersion: runtime.Version(),
+	}, info)
+	assert.Equal(t, "v1.2.0 (0123456789ab, 2026-01-02T15:04:05Z)", info.String())
+}
+
+func TestInfoString_WithoutDetails_IsVersionOnly(t *testing.T) {
+	assert.Equal(t, "dev", version.Info{Version: "dev"}.String())
+}
+
+func TestHandler_ReturnsInfoAsJSON(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	version.Handler()(recorder, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	var info version.Info
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &info))
+	assert.Equal(t, version.Get(), info)
+}